@@ -14,7 +14,8 @@ class GameEngine {
             currentPlayer: null,
             map: null,
             tasks: [],
-            objects: []
+            objects: [],
+            projectiles: []
         };
         
         // 渲染设置
@@ -94,6 +95,13 @@ class GameEngine {
                 // 取消当前操作
                 this.cancelCurrentAction();
                 break;
+            case 'Space':
+                // 攻击附近的玩家，由服务器选择目标
+                if (this.onAttackCallback && document.activeElement === document.body) {
+                    e.preventDefault();
+                    this.onAttackCallback();
+                }
+                break;
         }
     }
     
@@ -243,6 +251,7 @@ class GameEngine {
         this.renderBackground();
         this.renderMap();
         this.renderObjects();
+        this.renderProjectiles();
         this.renderPlayers();
         this.renderUI();
         
@@ -338,6 +347,9 @@ class GameEngine {
             case 'collectible':
                 this.renderCollectible(obj);
                 break;
+            case 'npc':
+                this.renderNPC(obj);
+                break;
             default:
                 this.renderDefaultObject(obj);
         }
@@ -369,6 +381,22 @@ class GameEngine {
         this.ctx.fillText('!', 0, 5);
     }
     
+    renderNPC(obj) {
+        // 渲染 NPC，位置由服务器的 physics 消息更新
+        this.ctx.fillStyle = '#4ecdc4';
+        this.ctx.beginPath();
+        this.ctx.arc(0, 0, 10, 0, Math.PI * 2);
+        this.ctx.fill();
+        
+        const name = obj.properties && obj.properties.name;
+        if (name) {
+            this.ctx.fillStyle = '#ffffff';
+            this.ctx.font = '12px Arial';
+            this.ctx.textAlign = 'center';
+            this.ctx.fillText(name, 0, -16);
+        }
+    }
+    
     renderCollectible(obj) {
         // 渲染可收集物品
         const time = performance.now() * 0.005;
@@ -392,6 +420,16 @@ class GameEngine {
         this.ctx.fillRect(-obj.width/2, -obj.height/2, obj.width, obj.height);
     }
     
+    renderProjectiles() {
+        // 渲染飞行中的弹道
+        this.ctx.fillStyle = '#ffd166';
+        for (const projectile of this.gameState.projectiles) {
+            this.ctx.beginPath();
+            this.ctx.arc(projectile.position.x, projectile.position.y, Math.max(projectile.radius, 2), 0, Math.PI * 2);
+            this.ctx.fill();
+        }
+    }
+    
     renderPlayers() {
         // 渲染所有玩家
         for (const [playerId, player] of this.gameState.players) {
@@ -654,6 +692,17 @@ class GameEngine {
         this.gameState.objects = this.gameState.objects.filter(obj => obj.id !== objId);
     }
     
+    updateGameObject(objId, updates) {
+        const obj = this.gameState.objects.find(o => o.id === objId);
+        if (obj) {
+            Object.assign(obj, updates);
+        }
+    }
+    
+    setProjectiles(projectiles) {
+        this.gameState.projectiles = projectiles;
+    }
+    
     setTasks(tasks) {
         this.gameState.tasks = tasks;
     }
@@ -673,4 +722,8 @@ class GameEngine {
     setObjectInteractCallback(callback) {
         this.onObjectInteractCallback = callback;
     }
+    
+    setAttackCallback(callback) {
+        this.onAttackCallback = callback;
+    }
 }
\ No newline at end of file
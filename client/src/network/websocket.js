@@ -1,3 +1,7 @@
+// 客户端实现的协议版本和支持的可选能力，认证和恢复会话时发给服务器
+const PROTOCOL_VERSION = 1;
+const CAPABILITIES = ['delta_sync'];
+
 /**
  * WebSocket 网络通信管理器
  */
@@ -9,6 +13,15 @@ class GameNetwork {
         this.maxReconnectAttempts = 5;
         this.reconnectDelay = 1000;
         
+        // 会话恢复：认证成功后保存令牌，重连时用 resume 恢复房间和位置
+        this.did = null;
+        this.sessionToken = null;
+        
+        // 状态同步：当前应用的房间状态版本，版本不连续时等待服务器的完整快照
+        this.stateVersion = 0;
+        this.awaitingResync = false;
+        this.syncedPlayers = new Set();
+        
         // 消息处理器
         this.messageHandlers = new Map();
         
@@ -22,15 +35,23 @@ class GameNetwork {
     
     setupMessageHandlers() {
         // 注册默认消息处理器
+        this.registerHandler('auth_challenge', (data) => this.handleAuthChallenge(data));
         this.registerHandler('auth', (data) => this.handleAuth(data));
+        this.registerHandler('resume', (data) => this.handleResume(data));
         this.registerHandler('join_room', (data) => this.handleJoinRoom(data));
         this.registerHandler('leave_room', (data) => this.handleLeaveRoom(data));
         this.registerHandler('player_move', (data) => this.handlePlayerMove(data));
+        this.registerHandler('state_sync', (data) => this.handleStateSync(data));
+        this.registerHandler('state_delta', (data) => this.handleStateDelta(data));
+        this.registerHandler('physics', (data) => this.handlePhysics(data));
+        this.registerHandler('combat', (data) => this.handleCombat(data));
+        this.registerHandler('position_correction', (data) => this.handlePositionCorrection(data));
         this.registerHandler('player_update', (data) => this.handlePlayerUpdate(data));
         this.registerHandler('game_state', (data) => this.handleGameState(data));
         this.registerHandler('task_update', (data) => this.handleTaskUpdate(data));
         this.registerHandler('chat', (data) => this.handleChat(data));
         this.registerHandler('credential', (data) => this.handleCredential(data));
+        this.registerHandler('trade', (data) => this.handleTrade(data));
         this.registerHandler('error', (data) => this.handleError(data));
     }
     
@@ -54,6 +75,15 @@ class GameNetwork {
                     this.reconnectAttempts = 0;
                     this.updateConnectionStatus('已连接');
                     
+                    // 断线重连时恢复之前的会话
+                    if (this.sessionToken) {
+                        this.send('resume', {
+                            sessionToken: this.sessionToken,
+                            protocolVersion: PROTOCOL_VERSION,
+                            capabilities: CAPABILITIES
+                        });
+                    }
+                    
                     if (this.onConnectCallback) {
                         this.onConnectCallback();
                     }
@@ -114,6 +144,8 @@ class GameNetwork {
             this.ws.close();
             this.ws = null;
         }
+        // 主动断开后不再恢复会话
+        this.sessionToken = null;
         this.connected = false;
         this.updateConnectionStatus('未连接');
     }
@@ -157,6 +189,12 @@ class GameNetwork {
     }
     
     handleMessage(message) {
+        // 服务器在合并窗口内发出的多条广播，按顺序逐条处理
+        if (message.type === 'batch') {
+            message.data.messages.forEach(m => this.handleMessage(m));
+            return;
+        }
+        
         console.log('Received message:', message);
         
         const handler = this.messageHandlers.get(message.type);
@@ -172,10 +210,37 @@ class GameNetwork {
     }
     
     // 消息处理器
+    // 用钱包私钥签名服务器下发的 nonce，证明持有 DID 的认证密钥后再发送 auth
+    async handleAuthChallenge(message) {
+        if (!this.did || !this.wallet) {
+            return;
+        }
+        try {
+            const signed = await this.wallet.signMessage(message.data.nonce);
+            const signature = Array.from(cryptoUtils.base64ToBuffer(signed.signature))
+                .map(b => b.toString(16).padStart(2, '0')).join('');
+            // 服务端按浏览器语言发送错误和凭证提示
+            const locale = (navigator.languages || [navigator.language]).join(',');
+            this.send('auth', {
+                did: this.did,
+                keyId: `${this.did}#key-1`,
+                nonce: message.data.nonce,
+                signature: signature,
+                locale: locale,
+                protocolVersion: PROTOCOL_VERSION,
+                capabilities: CAPABILITIES
+            });
+        } catch (error) {
+            this.addChatMessage(`认证失败: ${error.message}`, 'error');
+        }
+    }
+    
     handleAuth(message) {
         console.log('Auth response:', message.data);
         
         if (message.data.success) {
+            this.sessionToken = message.data.sessionToken || null;
+            
             // 认证成功，自动加入默认房间
             this.joinRoom('default');
         } else {
@@ -183,6 +248,24 @@ class GameNetwork {
         }
     }
     
+    handleResume(message) {
+        console.log('Session resumed:', message.data);
+        
+        this.sessionToken = message.data.sessionToken || null;
+        
+        if (this.gameEngine && message.data.position) {
+            this.gameEngine.movePlayerTo(message.data.position.x, message.data.position.y);
+        }
+        if (message.data.room) {
+            // 恢复房间状态，错过的消息随后由服务器补发
+            this.handleJoinRoom({ data: { success: true, room: message.data.room, gameState: message.data.gameState } });
+        } else {
+            this.joinRoom('default');
+        }
+        
+        this.addChatMessage(`已重新连接，补发 ${message.data.missed} 条消息`, 'info');
+    }
+    
     handleJoinRoom(message) {
         console.log('Joined room:', message.data);
         
@@ -220,6 +303,128 @@ class GameNetwork {
         }
     }
     
+    handleStateSync(message) {
+        const sync = message.data;
+        
+        if (!sync.full && sync.base !== this.stateVersion) {
+            // 错过了中间的版本，请求一次完整快照并丢弃之后的增量
+            if (!this.awaitingResync) {
+                this.awaitingResync = true;
+                this.send('state_ack', { version: this.stateVersion, resync: true });
+            }
+            return;
+        }
+        
+        if (this.gameEngine) {
+            const currentPlayer = this.gameEngine.gameState.currentPlayer;
+            
+            if (sync.full) {
+                // 完整快照只包含可见范围内的玩家，移除快照之外此前同步过的玩家
+                this.syncedPlayers.forEach(playerId => {
+                    if (!(sync.players && sync.players[playerId]) && !(currentPlayer && currentPlayer.id === playerId)) {
+                        this.gameEngine.removePlayer(playerId);
+                    }
+                });
+                this.syncedPlayers.clear();
+            }
+            
+            Object.entries(sync.players || {}).forEach(([playerId, changes]) => {
+                this.syncedPlayers.add(playerId);
+                // 本地玩家的位置由客户端预测，只接受服务器的修正消息
+                if (currentPlayer && currentPlayer.id === playerId) {
+                    changes = Object.assign({}, changes);
+                    delete changes.position;
+                }
+                if (this.gameEngine.gameState.players.has(playerId)) {
+                    this.gameEngine.updatePlayer(playerId, changes);
+                } else {
+                    this.gameEngine.addPlayer(Object.assign({ id: playerId }, changes));
+                }
+            });
+            
+            // 离开房间或离开视野的玩家不再显示
+            (sync.removed || []).concat(sync.exited || []).forEach(playerId => {
+                this.syncedPlayers.delete(playerId);
+                this.gameEngine.removePlayer(playerId);
+            });
+        }
+        
+        this.stateVersion = sync.version;
+        this.awaitingResync = false;
+        this.send('state_ack', { version: sync.version });
+    }
+    
+    handlePhysics(message) {
+        if (!this.gameEngine) return;
+        
+        const update = message.data;
+        Object.entries(update.objects || {}).forEach(([objectId, position]) => {
+            this.gameEngine.updateGameObject(objectId, { position: position });
+        });
+        // 消息包含所有飞行中的弹道，没有弹道的更新表示弹道都已消失
+        this.gameEngine.setProjectiles(update.projectiles || []);
+    }
+    
+    handleCombat(message) {
+        const event = message.data;
+        
+        if (this.gameEngine) {
+            this.gameEngine.updatePlayer(event.targetId, { health: event.health });
+        }
+        
+        const currentPlayer = this.gameEngine && this.gameEngine.gameState.currentPlayer;
+        if (event.type === 'death' && currentPlayer && currentPlayer.id === event.targetId) {
+            const seconds = Math.max(0, Math.round((new Date(event.respawnAt) - Date.now()) / 1000));
+            this.addChatMessage(`你被击败了，${seconds} 秒后重生`, 'info');
+        }
+    }
+    
+    handleTrade(message) {
+        const { action, trade } = message.data;
+        this.currentTrade = trade.status === 'proposed' || trade.status === 'open' ? trade : null;
+        
+        const currentPlayer = this.gameEngine && this.gameEngine.gameState.currentPlayer;
+        const incoming = currentPlayer && trade.to === currentPlayer.id;
+        switch (trade.status) {
+            case 'proposed':
+                if (action === 'propose') {
+                    this.addChatMessage(incoming ? `${trade.from} 向你发起交易` : `已向 ${trade.to} 发起交易`, 'info');
+                }
+                break;
+            case 'completed':
+                this.addChatMessage('交易完成', 'info');
+                break;
+            case 'cancelled':
+                this.addChatMessage('交易已取消', 'info');
+                break;
+        }
+    }
+    
+    handleStateDelta(message) {
+        if (!this.gameEngine) return;
+        
+        const players = message.data.players || {};
+        const currentPlayer = this.gameEngine.gameState.currentPlayer;
+        
+        Object.entries(players).forEach(([playerId, position]) => {
+            // 本地玩家由客户端预测，只接受服务器的修正消息
+            if (currentPlayer && currentPlayer.id === playerId) return;
+            this.gameEngine.updatePlayer(playerId, { position: position });
+        });
+        
+        (message.data.removed || []).forEach(playerId => {
+            this.gameEngine.removePlayer(playerId);
+        });
+    }
+    
+    handlePositionCorrection(message) {
+        if (!this.gameEngine) return;
+        
+        const position = message.data.position;
+        console.warn('Position corrected by server:', message.data.reason);
+        this.gameEngine.movePlayerTo(position.x, position.y);
+    }
+    
     handlePlayerUpdate(message) {
         if (!this.gameEngine) return;
         
@@ -239,6 +444,10 @@ class GameNetwork {
                 this.gameEngine.updatePlayer(player.id, { status: 'offline' });
                 this.addChatMessage(`${player.nickname} 断开连接`, 'info');
                 break;
+            case 'reconnected':
+                this.gameEngine.updatePlayer(player.id, { status: 'online' });
+                this.addChatMessage(`${player.nickname} 重新连接`, 'info');
+                break;
         }
     }
     
@@ -287,12 +496,31 @@ class GameNetwork {
     
     handleError(message) {
         console.error('Server error:', message.data.message);
+        
+        // 会话无法恢复时重新认证
+        if (message.data.code === 'resume_failed') {
+            this.sessionToken = null;
+            if (this.did) {
+                this.authenticate(this.did);
+            }
+            return;
+        }
+        
+        // 服务器不再支持本客户端的协议版本，需要刷新页面加载新版本
+        if (message.data.code === 'upgrade_required') {
+            this.sessionToken = null;
+            this.addChatMessage('客户端版本过旧，请刷新页面后重试', 'error');
+            return;
+        }
+        
         this.addChatMessage(`错误: ${message.data.message}`, 'error');
     }
     
     // 发送消息的便捷方法
+    // 认证分两步：先申请 nonce，收到 auth_challenge 后签名并发送 auth
     authenticate(did) {
-        return this.send('auth', { did: did });
+        this.did = did;
+        return this.send('auth_challenge');
     }
     
     joinRoom(roomId) {
@@ -321,6 +549,14 @@ class GameNetwork {
         return this.send('chat', { message: message });
     }
     
+    sendTrade(action, data = {}) {
+        return this.send('trade', {
+            action: action,
+            tradeId: this.currentTrade ? this.currentTrade.id : undefined,
+            ...data
+        });
+    }
+    
     // UI 更新方法
     updateConnectionStatus(status) {
         const statusElement = document.getElementById('connectionStatus');
@@ -388,6 +624,10 @@ class GameNetwork {
         gameEngine.setObjectInteractCallback((obj) => {
             this.sendPlayerAction('interact', { objectId: obj.id });
         });
+        
+        gameEngine.setAttackCallback(() => {
+            this.sendPlayerAction('attack');
+        });
     }
     
     setWallet(wallet) {
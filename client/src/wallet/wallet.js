@@ -12,23 +12,47 @@ class PlayerWallet {
         this.loadFromStorage();
     }
     
-    // DID 管理 - 使用Aries-hyperledger-go创建DID
+    // DID 管理 - 在本地生成密钥对，只向服务器注册公钥和 DID 文档
     async createDID(gameId, nickname = '', level = 1) {
         try {
-            // 生成玩家 ID（UUID）
+            // 生成玩家 ID（UUID）和密钥对，私钥不离开客户端
             const playerId = cryptoUtils.generateUUID();
+            const keyPair = await cryptoUtils.generateKeyPair();
+            const did = cryptoUtils.generateDID(gameId, playerId);
+            const didDocument = cryptoUtils.createDIDDocument(did, keyPair.publicKey, gameId, playerId);
 
-            // 调用服务器API使用Aries框架创建DID
-            const response = await fetch('/api/did/create', {
+            // 申请注册 nonce 并用私钥签名，证明持有私钥
+            const nonceResponse = await fetch('/api/did/register/nonce', {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ did: did })
+            });
+            if (!nonceResponse.ok) {
+                const errorText = await nonceResponse.text();
+                throw new Error(`HTTP error! status: ${nonceResponse.status}, message: ${errorText}`);
+            }
+            const { nonce } = await nonceResponse.json();
+            const signed = await cryptoUtils.signMessage(nonce, keyPair.privateKey);
+            const signature = Array.from(cryptoUtils.base64ToBuffer(signed.signature))
+                .map(b => b.toString(16).padStart(2, '0')).join('');
+
+            const response = await fetch('/api/did/register', {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json'
                 },
                 body: JSON.stringify({
+                    did: did,
+                    didDocument: didDocument,
+                    publicKey: keyPair.publicKey,
                     gameId: gameId,
                     playerId: playerId,
                     nickname: nickname,
-                    level: level
+                    level: level,
+                    nonce: nonce,
+                    signature: signature
                 })
             });
 
@@ -40,13 +64,13 @@ class PlayerWallet {
             const data = await response.json();
 
             if (!data.success) {
-                throw new Error(data.message || 'Failed to create DID');
+                throw new Error(data.message || 'Failed to register DID');
             }
 
-            // 保存从服务器返回的DID信息
+            // 保存DID信息
             this.did = data.did;
-            this.privateKey = data.privateKey;
-            this.publicKey = data.publicKey;
+            this.privateKey = keyPair.privateKey;
+            this.publicKey = keyPair.publicKey;
             this.gameId = gameId;
             this.playerId = playerId;
             this.nickname = nickname;
@@ -58,16 +82,15 @@ class PlayerWallet {
             // 更新UI
             this.updateDIDDisplay();
 
-            console.log('DID created successfully with Aries framework:', this.did);
+            console.log('DID registered successfully:', this.did);
             return {
                 did: this.did,
                 publicKey: this.publicKey,
-                privateKey: this.privateKey,
                 created: data.success
             };
 
         } catch (error) {
-            console.error('Failed to create DID with Aries:', error);
+            console.error('Failed to register DID:', error);
             throw error;
         }
     }
@@ -378,7 +401,7 @@ class PlayerWallet {
                 </div>
                 <div class=\"credential-details\">
                     <p><strong>ID:</strong> ${credential.id}</p>
-                    <p><strong>颁发者:</strong> ${credential.issuer?.id || 'Unknown'}</p>
+                    <p><strong>颁发者:</strong> ${credential.issuer?.name || credential.issuer?.id || credential.issuer || 'Unknown'}</p>
                     <p><strong>接收时间:</strong> ${receivedDate}</p>
                     ${this.renderCredentialSubject(credential.credentialSubject || credential.subject)}
                 </div>
@@ -398,8 +421,18 @@ class PlayerWallet {
         
         let html = '<div class=\"credential-subject\">';
         
-        if (subjectData.achievement) {
-            html += `<p><strong>成就:</strong> ${subjectData.achievement}</p>`;
+        // Open Badges 3.0 凭证的成就是包含名称、描述和图片的对象
+        const achievement = subjectData.achievement;
+        if (achievement && typeof achievement === 'object') {
+            if (achievement.image?.id) {
+                html += `<img class=\"credential-badge\" src=\"${achievement.image.id}\" alt=\"${achievement.name}\">`;
+            }
+            html += `<p><strong>成就:</strong> ${achievement.name}</p>`;
+            if (achievement.description) {
+                html += `<p><strong>描述:</strong> ${achievement.description}</p>`;
+            }
+        } else if (achievement) {
+            html += `<p><strong>成就:</strong> ${achievement}</p>`;
         }
         if (subjectData.level) {
             html += `<p><strong>等级:</strong> ${subjectData.level}</p>`;
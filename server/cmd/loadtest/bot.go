@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/pkg/client"
+)
+
+// chatPrefix 模拟玩家聊天消息的前缀，后跟发送时间（Unix 纳秒），收到自己的消息时据此计算延迟
+const chatPrefix = "loadtest "
+
+// msgTypePositionCorrection 服务器拒绝或修正移动时发送的消息
+const msgTypePositionCorrection = "position_correction"
+
+// BotConfig 单个模拟玩家的行为
+type BotConfig struct {
+	GameID   string
+	PlayerID string
+	RoomID   string
+	// MoveInterval、ChatInterval 和 PingInterval 为 0 时不执行相应动作
+	MoveInterval time.Duration
+	ChatInterval time.Duration
+	PingInterval time.Duration
+	// Step 每次移动的距离
+	Step float64
+	// JoinTimeout 等待加入房间回复的超时
+	JoinTimeout time.Duration
+}
+
+// bot 一个模拟玩家：注册 DID、连接并认证、加入房间后按配置的频率移动、聊天和 ping
+type bot struct {
+	config BotConfig
+	stats  *Stats
+
+	conn     *client.Conn
+	playerID string
+
+	position  struct{ x, y float64 }
+	direction float64
+	mutex     sync.Mutex
+}
+
+// runBot 运行一个模拟玩家直到 ctx 结束或连接关闭
+func runBot(ctx context.Context, clientConfig client.Config, config BotConfig, stats *Stats) {
+	b := &bot{config: config, stats: stats, direction: rand.Float64() * 2 * math.Pi}
+
+	identity, err := client.NewIdentity(config.GameID, config.PlayerID)
+	if err != nil {
+		stats.observe(opRegister, 0, err)
+		return
+	}
+	c := client.NewClient(clientConfig, identity)
+
+	start := time.Now()
+	err = c.Register(ctx, config.PlayerID)
+	stats.observe(opRegister, time.Since(start), err)
+	if err != nil {
+		b.failed(ctx, opRegister, err)
+		return
+	}
+
+	start = time.Now()
+	conn, err := c.Connect(ctx)
+	stats.observe(opConnect, time.Since(start), err)
+	if err != nil {
+		b.failed(ctx, opConnect, err)
+		return
+	}
+	defer conn.Close()
+	b.conn = conn
+	b.playerID = conn.Session().PlayerID
+
+	stats.connect()
+	defer stats.disconnect()
+
+	conn.On(client.AllMessages, b.handle)
+	if err := b.join(ctx); err != nil {
+		return
+	}
+	b.loop(ctx)
+}
+
+// join 加入配置的房间并记录耗时
+func (b *bot) join(ctx context.Context) error {
+	waitCtx, cancel := context.WithTimeout(ctx, b.config.JoinTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := b.conn.JoinRoom(client.JoinRoomPayload{RoomID: b.config.RoomID})
+	if err == nil {
+		_, err = b.conn.Wait(waitCtx, client.MsgTypeJoinRoom)
+	}
+	b.stats.observe(opJoin, time.Since(start), err)
+	if err != nil {
+		b.failed(ctx, opJoin, err)
+	}
+	return err
+}
+
+// failed 按原因统计注册、连接或加入房间的失败，压测结束导致的失败不计
+func (b *bot) failed(ctx context.Context, op string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	var httpErr *client.HTTPError
+	var serverErr *client.ServerError
+	code := "network"
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		code = "timeout"
+	case errors.As(err, &httpErr):
+		code = "http_" + strconv.Itoa(httpErr.StatusCode)
+	case errors.As(err, &serverErr):
+		code = serverErr.Code
+	}
+	b.stats.countError(op + ":" + code)
+	slog.Debug("Simulated player failed", logging.KeyPlayerID, b.config.PlayerID, "operation", op, logging.Err(err))
+}
+
+// loop 按各自的间隔移动、聊天和 ping，直到 ctx 结束或重连失败
+func (b *bot) loop(ctx context.Context) {
+	move := newTicker(b.config.MoveInterval)
+	chat := newTicker(b.config.ChatInterval)
+	ping := newTicker(b.config.PingInterval)
+	defer move.stop()
+	defer chat.stop()
+	defer ping.stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.conn.Done():
+			// 只有重连失败时连接才会在压测结束前关闭
+			b.stats.countError("connection_lost")
+			return
+		case <-move.c:
+			b.move()
+		case <-chat.c:
+			b.send(opChat, client.MsgTypeChat, client.ChatPayload{Message: chatPrefix + strconv.FormatInt(time.Now().UnixNano(), 10)})
+			b.stats.chats.Add(1)
+		case <-ping.c:
+			b.send(opPing, client.MsgTypePing, map[string]time.Time{"clientTime": time.Now()})
+		}
+	}
+}
+
+// move 沿当前方向移动一步，偶尔随机转向
+func (b *bot) move() {
+	b.mutex.Lock()
+	if rand.Intn(20) == 0 {
+		b.direction = rand.Float64() * 2 * math.Pi
+	}
+	b.position.x += b.config.Step * math.Cos(b.direction)
+	b.position.y += b.config.Step * math.Sin(b.direction)
+	x, y := b.position.x, b.position.y
+	b.mutex.Unlock()
+
+	if err := b.conn.Move(x, y); err != nil {
+		b.stats.countError("send_failed")
+		return
+	}
+	b.stats.moves.Add(1)
+}
+
+// send 发送计时的消息，发送失败计入该操作的失败次数；耗时在收到回复时记录
+func (b *bot) send(op, msgType string, data interface{}) {
+	if err := b.conn.Send(msgType, data); err != nil {
+		b.stats.observe(op, 0, err)
+	}
+}
+
+// handle 统计收到的消息，并根据回复记录 ping 和聊天的延迟
+func (b *bot) handle(msg client.Message) {
+	b.stats.received.Add(1)
+
+	switch msg.Type {
+	case client.MsgTypeError:
+		var serverErr client.ServerError
+		if msg.Decode(&serverErr) == nil {
+			b.stats.countError(serverErr.Code)
+		}
+
+	case client.MsgTypeResume:
+		b.stats.reconnects.Add(1)
+
+	case client.MsgTypePong:
+		var pong struct {
+			ClientTime time.Time `json:"clientTime"`
+		}
+		if msg.Decode(&pong) == nil && !pong.ClientTime.IsZero() {
+			b.stats.observe(opPing, time.Since(pong.ClientTime), nil)
+		}
+
+	case client.MsgTypeChat:
+		var chat struct {
+			PlayerID string `json:"playerId"`
+			Message  string `json:"message"`
+		}
+		if msg.Decode(&chat) != nil || chat.PlayerID != b.playerID || !strings.HasPrefix(chat.Message, chatPrefix) {
+			return
+		}
+		if sent, err := strconv.ParseInt(strings.TrimPrefix(chat.Message, chatPrefix), 10, 64); err == nil {
+			b.stats.observe(opChat, time.Since(time.Unix(0, sent)), nil)
+		}
+
+	case msgTypePositionCorrection:
+		// 服务器拒绝了移动（如越界或碰到障碍），从修正后的位置换个方向继续
+		var correction struct {
+			Position struct {
+				X float64 `json:"x"`
+				Y float64 `json:"y"`
+			} `json:"position"`
+		}
+		if msg.Decode(&correction) != nil {
+			return
+		}
+		b.stats.corrections.Add(1)
+		b.mutex.Lock()
+		b.position.x, b.position.y = correction.Position.X, correction.Position.Y
+		b.direction = rand.Float64() * 2 * math.Pi
+		b.mutex.Unlock()
+	}
+}
+
+// ticker 间隔为 0 时永不触发的定时器
+type ticker struct {
+	c      <-chan time.Time
+	ticker *time.Ticker
+}
+
+// newTicker 创建定时器
+func newTicker(interval time.Duration) *ticker {
+	t := &ticker{}
+	if interval <= 0 {
+		return t
+	}
+	t.ticker = time.NewTicker(interval)
+	t.c = t.ticker.C
+	return t
+}
+
+// stop 停止定时器
+func (t *ticker) stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+	}
+}
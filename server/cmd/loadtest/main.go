@@ -0,0 +1,141 @@
+// loadtest 模拟大量玩家连接游戏服务器：每个玩家注册 DID、认证、加入房间，按配置的频率移动、聊天和 ping，
+// 结束时输出各操作的延迟分位数和错误率。可以指向本地或远程服务器，用于验证广播和锁的改动
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/pkg/client"
+)
+
+func main() {
+	var (
+		serverURL      = flag.String("url", "http://localhost:8080", "Base URL of the game server (http or https)")
+		players        = flag.Int("players", 50, "Number of simulated players")
+		rooms          = flag.Int("rooms", 1, "Number of rooms the players are spread across")
+		roomPrefix     = flag.String("room-prefix", "loadtest", "Room ID prefix; rooms are named <prefix>-<n>")
+		gameID         = flag.String("game", "loadtest", "Game ID in the simulated players' DIDs")
+		runID          = flag.String("run-id", "", "Player ID prefix making this run's DIDs unique (default: random)")
+		duration       = flag.Duration("duration", time.Minute, "How long to run after the first player starts")
+		rampUp         = flag.Duration("ramp-up", 10*time.Second, "Spread player start times over this period")
+		moveRate       = flag.Float64("move-rate", 5, "Moves per second per player (0 disables moving)")
+		chatRate       = flag.Float64("chat-rate", 0.2, "Chat messages per second per player (0 disables chat)")
+		pingRate       = flag.Float64("ping-rate", 1, "Application-level pings per second per player (0 disables pings)")
+		step           = flag.Float64("step", 10, "Distance of each move")
+		locale         = flag.String("locale", "", "Locale requested for server-sent text")
+		compression    = flag.Bool("compression", false, "Request permessage-deflate compression of WebSocket messages")
+		reportInterval = flag.Duration("report-interval", 10*time.Second, "Print progress at this interval (0 disables)")
+		jsonReport     = flag.Bool("json", false, "Print the final report as JSON")
+		logLevel       = flag.String("log-level", "warn", "Minimum log level: debug, info, warn or error")
+	)
+	flag.Parse()
+
+	if err := logging.Setup(logging.Config{Level: *logLevel, Format: logging.FormatText}, os.Stderr); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	if *players <= 0 || *rooms <= 0 {
+		log.Fatal("-players and -rooms must be positive")
+	}
+	if *runID == "" {
+		*runID = uuid.New().String()[:8]
+	}
+
+	clientConfig := client.DefaultConfig(*serverURL)
+	clientConfig.Locale = *locale
+	clientConfig.EnableCompression = *compression
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-quit
+		cancel()
+	}()
+
+	stats := NewStats()
+	if *reportInterval > 0 {
+		go reportProgress(ctx, stats, *reportInterval)
+	}
+
+	slog.Info("Starting load test", "url", *serverURL, "players", *players, "rooms", *rooms, "run_id", *runID)
+	var wg sync.WaitGroup
+	for i := 0; i < *players; i++ {
+		config := BotConfig{
+			GameID:       *gameID,
+			PlayerID:     fmt.Sprintf("%s-%d", *runID, i),
+			RoomID:       fmt.Sprintf("%s-%d", *roomPrefix, i%*rooms),
+			MoveInterval: interval(*moveRate),
+			ChatInterval: interval(*chatRate),
+			PingInterval: interval(*pingRate),
+			Step:         *step,
+			JoinTimeout:  clientConfig.DialTimeout,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runBot(ctx, clientConfig, config, stats)
+		}()
+
+		// 在 ramp-up 期间均匀地启动玩家
+		if *players > 1 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(*rampUp / time.Duration(*players)):
+			}
+		}
+	}
+	wg.Wait()
+
+	report := stats.Report()
+	if *jsonReport {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		return
+	}
+	report.Print(os.Stdout)
+}
+
+// reportProgress 定期输出当前连接数、收发量和错误数
+func reportProgress(ctx context.Context, stats *Stats, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report := stats.Report()
+			errors := 0
+			for _, count := range report.Errors {
+				errors += count
+			}
+			fmt.Fprintf(os.Stderr, "[%5.0fs] connected %d, moves %d, chats %d, received %d, errors %d\n",
+				report.Duration, report.Connected, report.Moves, report.Chats, report.Received, errors)
+		}
+	}
+}
+
+// interval 将每秒次数换算为间隔，rate 不大于 0 时返回 0
+func interval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
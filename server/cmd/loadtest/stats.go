@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 计时的操作
+const (
+	opRegister = "register"
+	opConnect  = "connect"
+	opJoin     = "join"
+	opPing     = "ping"
+	opChat     = "chat"
+)
+
+// operations 报告中操作的顺序
+var operations = []string{opRegister, opConnect, opJoin, opPing, opChat}
+
+// latencies 一种操作的耗时样本和失败次数
+type latencies struct {
+	samples []time.Duration
+	errors  int
+}
+
+// Stats 所有模拟玩家共享的统计，并发使用安全
+type Stats struct {
+	started time.Time
+
+	mutex      sync.Mutex
+	operations map[string]*latencies
+	errorCodes map[string]int // 服务器 error 消息的错误码或客户端错误 -> 次数
+
+	connected   atomic.Int64
+	peak        atomic.Int64 // 同时连接数的最大值
+	reconnects  atomic.Int64
+	moves       atomic.Int64
+	corrections atomic.Int64
+	chats       atomic.Int64
+	received    atomic.Int64
+}
+
+// NewStats 创建空的统计，从现在开始计时
+func NewStats() *Stats {
+	return &Stats{
+		started:    time.Now(),
+		operations: make(map[string]*latencies),
+		errorCodes: make(map[string]int),
+	}
+}
+
+// observe 记录一次操作的耗时，err 非空时只计为失败
+func (s *Stats) observe(op string, elapsed time.Duration, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	l, exists := s.operations[op]
+	if !exists {
+		l = &latencies{}
+		s.operations[op] = l
+	}
+	if err != nil {
+		l.errors++
+		return
+	}
+	l.samples = append(l.samples, elapsed)
+}
+
+// connect 记录一个玩家连接成功，更新同时连接数的最大值
+func (s *Stats) connect() {
+	current := s.connected.Add(1)
+	for {
+		peak := s.peak.Load()
+		if current <= peak || s.peak.CompareAndSwap(peak, current) {
+			return
+		}
+	}
+}
+
+// disconnect 记录一个玩家断开
+func (s *Stats) disconnect() {
+	s.connected.Add(-1)
+}
+
+// countError 记录服务器发来的 error 消息或不计时操作的客户端错误，如发送失败
+func (s *Stats) countError(code string) {
+	s.mutex.Lock()
+	s.errorCodes[code]++
+	s.mutex.Unlock()
+}
+
+// OperationReport 一种操作的耗时分位数，单位毫秒
+type OperationReport struct {
+	Operation string  `json:"operation"`
+	Count     int     `json:"count"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+	P50       float64 `json:"p50Ms"`
+	P90       float64 `json:"p90Ms"`
+	P99       float64 `json:"p99Ms"`
+	Max       float64 `json:"maxMs"`
+}
+
+// Report 压测结果
+type Report struct {
+	Duration    float64           `json:"durationSeconds"`
+	Connected   int64             `json:"connected"`
+	Peak        int64             `json:"peakConnected"`
+	Reconnects  int64             `json:"reconnects"`
+	Moves       int64             `json:"moves"`
+	Corrections int64             `json:"corrections"`
+	Chats       int64             `json:"chats"`
+	Received    int64             `json:"received"`
+	Operations  []OperationReport `json:"operations"`
+	Errors      map[string]int    `json:"errors"`
+}
+
+// Report 汇总当前的统计
+func (s *Stats) Report() Report {
+	report := Report{
+		Duration:    time.Since(s.started).Seconds(),
+		Connected:   s.connected.Load(),
+		Peak:        s.peak.Load(),
+		Reconnects:  s.reconnects.Load(),
+		Moves:       s.moves.Load(),
+		Corrections: s.corrections.Load(),
+		Chats:       s.chats.Load(),
+		Received:    s.received.Load(),
+		Errors:      make(map[string]int),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for code, count := range s.errorCodes {
+		report.Errors[code] = count
+	}
+	for _, op := range operations {
+		l, exists := s.operations[op]
+		if !exists {
+			continue
+		}
+		samples := append([]time.Duration(nil), l.samples...)
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+		r := OperationReport{
+			Operation: op,
+			Count:     len(samples) + l.errors,
+			Errors:    l.errors,
+			P50:       percentile(samples, 0.50),
+			P90:       percentile(samples, 0.90),
+			P99:       percentile(samples, 0.99),
+		}
+		if r.Count > 0 {
+			r.ErrorRate = float64(l.errors) / float64(r.Count)
+		}
+		if len(samples) > 0 {
+			r.Max = milliseconds(samples[len(samples)-1])
+		}
+		report.Operations = append(report.Operations, r)
+	}
+	return report
+}
+
+// Print 以表格输出报告
+func (r Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "duration %.1fs, peak connected %d, reconnects %d, moves %d (%d corrected), chats %d, messages received %d\n",
+		r.Duration, r.Peak, r.Reconnects, r.Moves, r.Corrections, r.Chats, r.Received)
+	fmt.Fprintf(w, "%-10s %8s %8s %7s %9s %9s %9s %9s\n", "operation", "count", "errors", "err%", "p50(ms)", "p90(ms)", "p99(ms)", "max(ms)")
+	for _, op := range r.Operations {
+		fmt.Fprintf(w, "%-10s %8d %8d %6.2f%% %9.1f %9.1f %9.1f %9.1f\n",
+			op.Operation, op.Count, op.Errors, op.ErrorRate*100, op.P50, op.P90, op.P99, op.Max)
+	}
+
+	codes := make([]string, 0, len(r.Errors))
+	for code := range r.Errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "error %s: %d\n", code, r.Errors[code])
+	}
+}
+
+// percentile 返回已排序样本的分位数，单位毫秒
+func percentile(sorted []time.Duration, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(q*float64(len(sorted))+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return milliseconds(sorted[index])
+}
+
+// milliseconds 将耗时换算为毫秒
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -2,18 +2,38 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/czh0526/game/server/internal/admin"
+	"github.com/czh0526/game/server/internal/api"
+	"github.com/czh0526/game/server/internal/audit"
 	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/internal/certs"
 	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/grpcapi"
+	"github.com/czh0526/game/server/internal/kms"
 	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/metrics"
+	"github.com/czh0526/game/server/internal/mysqlstore"
+	"github.com/czh0526/game/server/internal/ratelimit"
+	"github.com/czh0526/game/server/internal/storage"
+	"github.com/czh0526/game/server/internal/tracing"
 	"github.com/czh0526/game/server/internal/vc"
+	"github.com/czh0526/game/server/internal/webhook"
 )
 
 func main() {
@@ -21,64 +41,591 @@ func main() {
 		addr = flag.String("addr", ":8080", "HTTP server address")
 		staticDir = flag.String("static", "./client", "Static files directory")
 		mysqlDSN = flag.String("mysql-dsn", "root:password@tcp(localhost:3308)/aries_did?parseTime=true", "MySQL data source name")
+		publicURL = flag.String("public-url", "http://localhost:8080", "Public base URL used in credential status entries")
+		storageSpec = flag.String("storage", "", "Storage backend: :memory:, mysql:<dsn>, sqlite:<file> or leveldb:<dir> (default $GAME_STORAGE, then -mysql-dsn)")
+		storageNamespace = flag.String("storage-namespace", "aries", "Table name prefix for MySQL storage")
+		redisAddr = flag.String("redis-addr", "", "Redis address for sharing rooms between instances (empty: single instance)")
+		redisPassword = flag.String("redis-password", "", "Redis password")
+		roomSharding = flag.Bool("room-sharding", false, "With -redis-addr, host each room on a single instance and redirect joins for rooms hosted elsewhere")
+		issuerDIDWeb = flag.Bool("issuer-did-web", false, "Sign credentials as did:web derived from -public-url")
+		apiRate = flag.Float64("api-rate", 10, "Requests per second allowed per IP on /api/did/*, /api/vc/* and /api/leaderboard (0 disables)")
+		apiBurst = flag.Int("api-burst", 20, "Request burst allowed per IP on /api/did/* and /api/vc/*")
+		grpcAddr = flag.String("grpc-addr", "", "Listen address for the internal gRPC API used by other backend services (empty: disabled)")
+		grpcToken = flag.String("grpc-token", os.Getenv("GAME_GRPC_TOKEN"), "Shared token callers present to the internal gRPC API (default $GAME_GRPC_TOKEN)")
+		adminAPIKey = flag.String("admin-api-key", os.Getenv("GAME_ADMIN_API_KEY"), "API key for the /admin API (default $GAME_ADMIN_API_KEY)")
+		adminDIDs = flag.String("admin-dids", "", "Comma-separated DIDs allowed to call the /admin API with signed requests")
+		chatBlocklist = flag.String("chat-blocklist", "", "File with one word per line masked in chat messages")
+		achievementsFile = flag.String("achievements", "", "JSON file with achievement definitions replacing the default achievements")
+		tasksDir = flag.String("tasks-dir", "", "Directory of per-game task definition files named <gameId>.json; reloadable with POST /admin/tasks/reload")
+		roomSoftCap = flag.Int("room-soft-cap", game.DefaultCapacityPolicy().SoftCap, "Players per default-game room before new players spill into sibling rooms (0: spill only when full or overloaded)")
+		roomMaxRooms = flag.Int("room-max-rooms", game.DefaultCapacityPolicy().MaxRooms, "Maximum number of rooms in a default-game room group, including the original room")
+		roomOverflow = flag.String("room-overflow", game.DefaultCapacityPolicy().Overflow, "What happens when every room in a group is full: queue, reject or redirect")
+		defaultLocale = flag.String("locale", game.DefaultLocale, "Language of server-sent text for clients that request none or an unsupported one")
+		trustedIssuers = flag.String("trusted-issuers", "", "Comma-separated issuer DIDs of other games whose credentials players can import")
+		guestPlay = flag.Bool("guest-play", false, "Let clients play as guests with a server-generated did:key that earns no credentials until upgraded to a registered DID")
+		minProtocolVersion = flag.Int("min-protocol-version", game.DefaultProtocolConfig().MinVersion, "Oldest client protocol version accepted; older clients get an upgrade_required error")
+		wsCompression = flag.Bool("ws-compression", game.DefaultCompressionConfig().Enabled, "Negotiate permessage-deflate with WebSocket clients that offer it")
+		wsCompressionThreshold = flag.Int("ws-compression-threshold", game.DefaultCompressionConfig().Threshold, "Only compress WebSocket messages of at least this many bytes (0: compress every message)")
+		wsCompressionLevel = flag.Int("ws-compression-level", game.DefaultCompressionConfig().Level, "flate compression level for WebSocket messages, from -2 (Huffman only) to 9 (best compression)")
+		gameIssuer = flag.Bool("game-issuer", false, "Sign the default game's achievement, level, skill, item and trade credentials with its own issuer DID instead of the server DID")
+		metricsEnabled = flag.Bool("metrics", true, "Expose Prometheus metrics at /metrics")
+		logLevel = flag.String("log-level", "info", "Minimum log level: debug, info, warn or error")
+		logFormat = flag.String("log-format", logging.FormatText, "Log output format: text or json")
+		otlpEndpoint = flag.String("otlp-endpoint", "", "OTLP/HTTP collector base URL for traces, e.g. http://localhost:4318 (empty disables tracing)")
+		traceSampleRatio = flag.Float64("trace-sample-ratio", 1, "Fraction of traces to sample, between 0 and 1")
+		tlsCert = flag.String("tls-cert", "", "TLS certificate file; with -tls-key serves HTTPS and wss (reloaded on SIGHUP)")
+		tlsKey = flag.String("tls-key", "", "TLS private key file")
+		httpRedirectAddr = flag.String("http-redirect-addr", "", "Address of a plain HTTP listener that redirects to HTTPS, e.g. :80 (requires -tls-cert)")
+		storageEncryptionKeys = flag.String("storage-encryption-keys", os.Getenv(storage.EnvEncryptionKeys), "Comma-separated keys encrypting MySQL values at rest, newest first: id=<base64 32-byte key> or a local KMS data key ID (default $GAME_STORAGE_ENCRYPTION_KEYS)")
+		storageReencrypt = flag.Bool("storage-reencrypt", false, "At startup, re-encrypt stored values that are plaintext or use an older key with the first -storage-encryption-keys key")
+		kmsBackend = flag.String("kms", kms.BackendLocal, "Key management backend for issuer signing keys: local or vault")
+		kmsKeystore = flag.String("kms-keystore", "", "Encrypted keystore file for the local KMS (required unless -kms=vault or -kms-ephemeral)")
+		kmsEphemeral = flag.Bool("kms-ephemeral", false, "Development only: keep local KMS keys in memory, so issuer keys and credentials signed with them are lost on exit")
+		kmsPassphrase = flag.String("kms-passphrase", os.Getenv("GAME_KMS_PASSPHRASE"), "Passphrase for -kms-keystore (default $GAME_KMS_PASSPHRASE)")
+		vaultAddr = flag.String("vault-addr", os.Getenv("VAULT_ADDR"), "Vault address for -kms=vault (default $VAULT_ADDR)")
+		vaultToken = flag.String("vault-token", os.Getenv("VAULT_TOKEN"), "Vault token for -kms=vault (default $VAULT_TOKEN)")
+		vaultTransitMount = flag.String("vault-transit-mount", "transit", "Mount path of the Vault transit secrets engine")
+		wsReadLimit = flag.Int64("ws-read-limit", game.DefaultMessageLimitConfig().ReadLimit, "Maximum WebSocket frame size in bytes; larger frames close the connection")
+		maxSessions = flag.Int("max-sessions", game.DefaultConnectionPolicy().MaxSessions, "Maximum concurrent authenticated connections per DID on this instance")
+		matchTeamSize = flag.Int("match-team-size", game.DefaultMatchmakingConfig().TeamSize, "Players per team in matchmade games")
+		matchTeamCount = flag.Int("match-team-count", game.DefaultMatchmakingConfig().TeamCount, "Teams per matchmade game")
+		duplicateLogin = flag.String("duplicate-login", game.DefaultConnectionPolicy().DuplicateLogin, "What happens when a DID at -max-sessions logs in again: kick_old or reject_new")
+		requestTimeout = flag.Duration("request-timeout", game.DefaultRequestTimeout, "Deadline for handling one WebSocket message, including DID resolution and credential checks (0 disables)")
+		storageQueryTimeout = flag.Duration("storage-query-timeout", mysqlstore.DefaultQueryTimeout, "Deadline for each MySQL or SQLite storage statement (0 disables)")
+		storageMaxOpenConns = flag.Int("storage-max-open-conns", mysqlstore.DefaultPoolConfig().MaxOpenConns, "Maximum open MySQL connections (0: unlimited)")
+		storageMaxIdleConns = flag.Int("storage-max-idle-conns", mysqlstore.DefaultPoolConfig().MaxIdleConns, "Maximum idle MySQL connections kept for reuse")
+		storageConnMaxLifetime = flag.Duration("storage-conn-max-lifetime", mysqlstore.DefaultPoolConfig().ConnMaxLifetime, "Close MySQL connections older than this (0: never)")
+		wsRatePolicy = flag.String("ws-rate-policy", string(game.RateLimitThrottle), "Policy for players exceeding WebSocket message limits: throttle or kick")
+		mode = flag.String("mode", api.ModeAries, "Service stack: aries (DIDs stored through the Aries framework) or simple (in-memory DIDs, no Aries)")
+		broadcastWindow = flag.Duration("broadcast-window", game.DefaultBatchConfig().Window, "Coalesce room broadcasts to each player within this window into one batch message (0 sends each broadcast immediately)")
+		interestRadius = flag.Float64("interest-radius", 0, "Players only receive state updates for other players within this distance (0 syncs the whole room)")
+		didCacheSize = flag.Int("did-cache-size", did.DefaultCacheConfig().Size, "Maximum number of cached DID resolution results (0 disables the cache)")
+		didCacheTTL = flag.Duration("did-cache-ttl", did.DefaultCacheConfig().TTL, "How long resolved DID documents are cached")
+		antiCheat = flag.Bool("anticheat", true, "Score suspicious player input (speed, message rate, task completion) and act on thresholds")
+		antiCheatActions = flag.String("anticheat-actions", "flag,shadow_log,kick,ban", "Comma-separated anti-cheat actions to take when their score thresholds are reached")
+		credentialExpiryWarning = flag.Duration("credential-expiry-warning", vc.DefaultExpiryConfig().WarningWindow, "Warn connected holders this long before their credentials expire")
 	)
 	flag.Parse()
 
-	// 初始化Aries服务
-	log.Println("Initializing Aries service with MySQL storage...")
-	ariesSvc, err := aries.NewAriesService(&aries.Config{
-		MySQLDSN: *mysqlDSN,
-		Label:    "game-did-service",
-	})
+	if err := logging.Setup(logging.Config{Level: *logLevel, Format: *logFormat}, os.Stderr); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	serviceMode, err := api.ParseMode(*mode)
+	if err != nil {
+		fatal("Invalid -mode", err)
+	}
+
+	// 链路追踪，未配置 -otlp-endpoint 时不启用
+	traceConfig := tracing.DefaultConfig()
+	traceConfig.Endpoint = *otlpEndpoint
+	traceConfig.SampleRatio = *traceSampleRatio
+	shutdownTracing, err := tracing.Setup(traceConfig)
 	if err != nil {
-		log.Fatalf("Failed to initialize Aries service: %v", err)
+		fatal("Failed to initialize tracing", err)
 	}
-	defer ariesSvc.Close()
-	log.Println("Aries service initialized successfully")
 
-	// 初始化DID服务（使用Aries）
-	didService := did.NewSimpleServiceWithAries(ariesSvc)
+	// 初始化 KMS，颁发者私钥只在 KMS 内使用
+	kmsConfig := kms.DefaultConfig()
+	kmsConfig.Backend = *kmsBackend
+	kmsConfig.KeystorePath = *kmsKeystore
+	kmsConfig.Ephemeral = *kmsEphemeral
+	kmsConfig.Passphrase = *kmsPassphrase
+	if *vaultAddr != "" {
+		kmsConfig.Vault.Addr = *vaultAddr
+	}
+	kmsConfig.Vault.Token = *vaultToken
+	kmsConfig.Vault.Mount = *vaultTransitMount
+	keyManager, err := kms.Open(kmsConfig)
+	if err != nil {
+		fatal("Failed to initialize KMS", err)
+	}
+	slog.Info("KMS initialized", "backend", kmsConfig.Backend, "keystore", kmsConfig.KeystorePath)
+	// 内存中的本地 KMS 进程退出后丢失密钥，不能为需要长期使用的数据提供密钥
+	ephemeralKMS := kmsConfig.Backend == kms.BackendLocal && kmsConfig.Ephemeral
+	if ephemeralKMS {
+		slog.Warn("-kms-ephemeral SET: KMS keys are kept in memory and lost on exit, credentials and Aries keys from this run cannot be verified or unsealed after a restart; use -kms-keystore or -kms=vault outside development")
+	}
 
-	// 初始化VC服务
-	vcService, err := vc.NewSimpleService(didService)
+	// 初始化存储后端，配置密钥时 MySQL 中的值加密存储
+	spec := storage.SpecFromEnv(*storageSpec, storage.BackendMySQL+":"+*mysqlDSN)
+	backend, _ := storage.ParseSpec(spec)
+	storagePool := mysqlstore.DefaultPoolConfig()
+	storagePool.MaxOpenConns = *storageMaxOpenConns
+	storagePool.MaxIdleConns = *storageMaxIdleConns
+	storagePool.ConnMaxLifetime = *storageConnMaxLifetime
+	storageOptions := storage.Options{Namespace: *storageNamespace, QueryTimeout: *storageQueryTimeout, Pool: &storagePool}
+	if *storageEncryptionKeys != "" {
+		dataKeys, _ := keyManager.(kms.DataKeyProvider)
+		if ephemeralKMS {
+			// 内存中的数据密钥重启后变化，用它加密的值将无法读取
+			dataKeys = nil
+		}
+		storageOptions.Encryption, err = storage.ParseEncryptionKeys(*storageEncryptionKeys, dataKeys)
+		if err != nil {
+			fatal("Invalid -storage-encryption-keys", err)
+		}
+	}
+	slog.Info("Initializing storage", "backend", backend, "encrypted", storageOptions.Encryption != nil)
+	storageProvider, err := storage.Open(spec, storageOptions)
 	if err != nil {
-		log.Fatalf("Failed to initialize VC service: %v", err)
+		fatal("Failed to initialize storage", err)
+	}
+	if *storageReencrypt {
+		reencrypter, ok := storageProvider.(interface{ Reencrypt() (int, error) })
+		if !ok || storageOptions.Encryption == nil {
+			fatal("Failed to re-encrypt storage", errors.New("requires the mysql backend and -storage-encryption-keys"))
+		}
+		count, err := reencrypter.Reencrypt()
+		if err != nil {
+			fatal("Failed to re-encrypt storage", err)
+		}
+		slog.Info("Storage re-encrypted", "key", storageOptions.Encryption.ActiveKeyID(), "values", count)
+	}
+
+	// 初始化DID服务：aries 模式通过 Aries 框架保存 DID，simple 模式只保存在内存中
+	var didService *did.SimpleService
+	switch serviceMode {
+	case api.ModeAries:
+		slog.Info("Initializing Aries service")
+		// Aries KMS 中的密钥用 KMS 数据密钥加密后写入存储，内存中的本地 KMS 重启后数据密钥变化，
+		// 之前写入的密钥无法再解密；不支持数据密钥的后端不加密
+		var masterKey []byte
+		if dataKeys, ok := keyManager.(kms.DataKeyProvider); ok {
+			masterKey, err = dataKeys.DataKey("aries-master-key")
+			if err != nil {
+				fatal("Failed to load Aries master key", err)
+			}
+		} else {
+			slog.Warn("KMS backend has no data keys, Aries keys are stored unsealed", "backend", kmsConfig.Backend)
+		}
+		ariesSvc, err := aries.NewAriesService(&aries.Config{
+			Label:     "game-did-service",
+			Provider:  storageProvider,
+			MasterKey: masterKey,
+		})
+		if err != nil {
+			fatal("Failed to initialize Aries service", err)
+		}
+		defer ariesSvc.Close()
+		slog.Info("Aries service initialized")
+
+		didService = did.NewSimpleServiceWithAries(ariesSvc)
+	default:
+		didService = did.NewSimpleService()
+	}
+	didCacheConfig := did.DefaultCacheConfig()
+	didCacheConfig.Size = *didCacheSize
+	didCacheConfig.TTL = *didCacheTTL
+	didService.SetCacheConfig(didCacheConfig)
+
+	// DID 和凭证操作的审计日志，只追加写入存储后端，可通过 /admin/audit/identity 查询
+	auditLog, err := audit.NewLog(storageProvider)
+	if err != nil {
+		fatal("Failed to initialize audit log", err)
+	}
+	didService.SetAuditLog(auditLog)
+
+	// 初始化VC服务，颁发者密钥只保存在 KMS 中；密钥轮换记录保存在存储后端中
+	issuerKeys := keyManager
+	vcService, err := vc.NewSimpleServiceWithKeyRing(didService, issuerKeys, storageProvider)
+	if err != nil {
+		fatal("Failed to initialize VC service", err)
+	}
+	vcService.SetPublicURL(*publicURL)
+	vcService.SetAuditLog(auditLog)
+	if *issuerDIDWeb {
+		if err := vcService.UseWebIssuer(*publicURL); err != nil {
+			fatal("Failed to configure did:web issuer", err)
+		}
+	}
+	slog.Info("Credential issuer configured", "issuer", vcService.IssuerDID())
+
+	// 初始化游戏状态持久化
+	persistence, err := game.NewPersistence(storageProvider, game.DefaultPersistenceConfig())
+	if err != nil {
+		fatal("Failed to initialize game persistence", err)
 	}
 
 	// 初始化游戏服务器
-	gameServer, err := game.NewSimpleServer(didService, vcService)
+	gameServer, err := game.NewSimpleServerWithPersistence(didService, vcService, persistence)
 	if err != nil {
-		log.Fatalf("Failed to initialize game server: %v", err)
+		fatal("Failed to initialize game server", err)
 	}
 
+	// 封禁、禁言和审计日志写入存储，重启后仍然有效
+	moderation, err := game.NewModeration(storageProvider)
+	if err != nil {
+		fatal("Failed to initialize moderation", err)
+	}
+	gameServer.SetModeration(moderation)
+
+	// 房间聊天记录写入存储，屏蔽词在服务器端替换
+	chatHistory, err := game.NewChatHistory(storageProvider, game.DefaultChatConfig())
+	if err != nil {
+		fatal("Failed to initialize chat history", err)
+	}
+	gameServer.SetChatHistory(chatHistory)
+	if *chatBlocklist != "" {
+		data, err := os.ReadFile(*chatBlocklist)
+		if err != nil {
+			fatal("Failed to read chat blocklist", err)
+		}
+		gameServer.AddChatFilter(game.NewProfanityFilter(strings.Split(string(data), "\n")))
+	}
+
+	// 房间游戏事件追加写入存储，可按房间重放
+	eventLog, err := game.NewEventLog(storageProvider, game.DefaultEventLogConfig())
+	if err != nil {
+		fatal("Failed to initialize event log", err)
+	}
+	gameServer.SetEventLog(eventLog)
+
+	// 对局结果写入存储，用于排行榜
+	results, err := game.NewResults(storageProvider)
+	if err != nil {
+		fatal("Failed to initialize results", err)
+	}
+	gameServer.SetResults(results)
+
+	// 公会和成员写入存储
+	guilds, err := game.NewGuilds(storageProvider)
+	if err != nil {
+		fatal("Failed to initialize guilds", err)
+	}
+	gameServer.SetGuilds(guilds)
+
+	// 成就进度写入存储，定义来自 -achievements 文件或默认成就
+	achievements, err := game.NewAchievements(storageProvider)
+	if err != nil {
+		fatal("Failed to initialize achievements", err)
+	}
+	definitions := game.DefaultAchievements()
+	if *achievementsFile != "" {
+		data, err := os.ReadFile(*achievementsFile)
+		if err != nil {
+			fatal("Failed to read achievements", err)
+		}
+		definitions = nil
+		if err := json.Unmarshal(data, &definitions); err != nil {
+			fatal("Invalid achievements file", err)
+		}
+	}
+	for _, definition := range definitions {
+		if err := achievements.Register(definition); err != nil {
+			fatal("Invalid achievement definition", err)
+		}
+	}
+	gameServer.SetAchievements(achievements)
+
+	// 任务定义按游戏从文件加载，替换游戏模式自带的任务；启动时任一文件无效则退出
+	if *tasksDir != "" {
+		counts, err := gameServer.LoadTasks(*tasksDir)
+		if err != nil {
+			fatal("Invalid task definitions", err)
+		}
+		slog.Info("Loaded task definitions", "dir", *tasksDir, "games", counts)
+	}
+
+	// 收件箱写入存储，离线期间的通知、凭证和邀请在玩家下次认证时推送
+	inbox, err := game.NewInbox(storageProvider)
+	if err != nil {
+		fatal("Failed to initialize inbox", err)
+	}
+	gameServer.SetInbox(inbox)
+	vcService.SetIssuedNotifier(gameServer)
+	vcService.SetClaimSource(gameServer)
+
+	// 向外部服务推送玩家加入、任务完成、凭证颁发和对局结束事件，订阅通过 /admin/webhooks 管理
+	webhooks, err := webhook.NewDispatcher(storageProvider, webhook.DefaultConfig())
+	if err != nil {
+		fatal("Failed to initialize webhooks", err)
+	}
+	gameServer.SetWebhooks(webhooks)
+	vcService.SetWebhooks(webhooks)
+
+	// 默认游戏的凭证由游戏自己的颁发者签发
+	if *gameIssuer {
+		issuerDID, err := gameServer.UseGameIssuer("default")
+		if err != nil {
+			fatal("Failed to create game issuer", err)
+		}
+		slog.Info("Default game credentials are signed by the game issuer", "issuer", issuerDID)
+	}
+
+	// 玩家可在认证或加入房间时导入信任的其他游戏颁发的凭证
+	if *trustedIssuers != "" {
+		if err := gameServer.TrustIssuers("default", strings.Split(*trustedIssuers, ",")...); err != nil {
+			fatal("Invalid -trusted-issuers", err)
+		}
+	}
+
+	// 默认游戏的房间达到软上限或负载过高时分流到同组房间
+	capacityPolicy := game.DefaultCapacityPolicy()
+	capacityPolicy.SoftCap = *roomSoftCap
+	capacityPolicy.MaxRooms = *roomMaxRooms
+	capacityPolicy.Overflow = *roomOverflow
+	if err := gameServer.SetCapacityPolicy("default", capacityPolicy); err != nil {
+		fatal("Invalid room capacity policy", err)
+	}
+
+	// 服务端消息按玩家认证时协商的语言发送，静态目录下的 locales/{语言}.json 覆盖或补充内置消息
+	catalog := game.NewCatalog(*defaultLocale)
+	loaded, err := catalog.LoadDir(filepath.Join(*staticDir, game.LocalesDir))
+	if err != nil {
+		fatal("Failed to load locale bundles", err)
+	}
+	gameServer.SetCatalog(catalog)
+	slog.Info("Loaded message catalog", "locales", catalog.Locales(), "bundles", loaded)
+
+	// 同一 DID 的并发连接数和重复登录处理
+	if err := gameServer.SetConnectionPolicy(game.ConnectionPolicy{MaxSessions: *maxSessions, DuplicateLogin: *duplicateLogin}); err != nil {
+		fatal("Invalid connection policy", err)
+	}
+
+	// 匹配的队伍规模
+	if *matchTeamSize <= 0 || *matchTeamCount < 2 {
+		fatal("Invalid matchmaking config", errors.New("-match-team-size must be positive and -match-team-count at least 2"))
+	}
+	matchmakingConfig := game.DefaultMatchmakingConfig()
+	matchmakingConfig.TeamSize = *matchTeamSize
+	matchmakingConfig.TeamCount = *matchTeamCount
+	gameServer.SetMatchmakingConfig(matchmakingConfig)
+
+	// 单条消息的处理时限，超时或服务器关闭时取消消息中的 DID 解析和凭证验证
+	if err := gameServer.SetRequestTimeout(*requestTimeout); err != nil {
+		fatal("Invalid -request-timeout", err)
+	}
+
+	// 访客模式，访客不能进入 guestPolicy 为 deny 的游戏
+	gameServer.SetGuestPlay(*guestPlay)
+
+	// 接受的客户端协议版本
+	if err := gameServer.SetProtocolConfig(game.ProtocolConfig{MinVersion: *minProtocolVersion}); err != nil {
+		fatal("Invalid -min-protocol-version", err)
+	}
+
+	// WebSocket 帧压缩
+	if err := gameServer.SetCompressionConfig(game.CompressionConfig{
+		Enabled:   *wsCompression,
+		Threshold: *wsCompressionThreshold,
+		Level:     *wsCompressionLevel,
+	}); err != nil {
+		fatal("Invalid WebSocket compression configuration", err)
+	}
+
+	// WebSocket 消息限流
+	rateLimitConfig := game.DefaultRateLimitConfig()
+	rateLimitConfig.Policy, err = game.ParseRateLimitPolicy(*wsRatePolicy)
+	if err != nil {
+		fatal("Invalid -ws-rate-policy", err)
+	}
+	gameServer.SetRateLimitConfig(rateLimitConfig)
+	messageLimitConfig := game.DefaultMessageLimitConfig()
+	messageLimitConfig.ReadLimit = *wsReadLimit
+	gameServer.SetMessageLimitConfig(messageLimitConfig)
+	batchConfig := game.DefaultBatchConfig()
+	batchConfig.Window = *broadcastWindow
+	gameServer.SetBatchConfig(batchConfig)
+	gameServer.SetInterestConfig(game.InterestConfig{Radius: *interestRadius})
+
+	// 反作弊只执行 -anticheat-actions 中列出的动作，其余阈值只记录违规
+	antiCheatConfig := game.DefaultAntiCheatConfig()
+	antiCheatConfig.Enabled = *antiCheat
+	enabledActions := make(map[game.AntiCheatAction]bool)
+	for _, name := range strings.Split(*antiCheatActions, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		action, err := game.ParseAntiCheatAction(name)
+		if err != nil {
+			fatal("Invalid -anticheat-actions", err)
+		}
+		enabledActions[action] = true
+	}
+	var thresholds []game.AntiCheatThreshold
+	for _, threshold := range antiCheatConfig.Thresholds {
+		if enabledActions[threshold.Action] {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	antiCheatConfig.Thresholds = thresholds
+	gameServer.SetAntiCheatConfig(antiCheatConfig)
+
+	// 多实例部署时通过 Redis 共享房间成员和在线状态，并经消息总线扇出房间广播
+	if *redisAddr != "" {
+		redisConfig := game.DefaultRedisConfig()
+		redisConfig.Addr = *redisAddr
+		redisConfig.Password = *redisPassword
+		roomBackend, err := game.NewRedisRoomBackend(redisConfig)
+		if err != nil {
+			fatal("Failed to connect to Redis", err)
+		}
+		gameServer.SetRoomBackend(roomBackend)
+
+		bus, err := game.NewRedisMessageBus(redisConfig)
+		if err != nil {
+			fatal("Failed to connect to Redis", err)
+		}
+		if err := gameServer.SetMessageBus(bus); err != nil {
+			fatal("Failed to subscribe to Redis", err)
+		}
+		slog.Info("Sharing room state via Redis", "addr", *redisAddr)
+
+		if *roomSharding {
+			instance, err := game.InstanceFromURL(*publicURL)
+			if err != nil {
+				fatal("Invalid -public-url for room sharding", err)
+			}
+			directory, err := game.NewRedisRoomDirectory(redisConfig)
+			if err != nil {
+				fatal("Failed to connect to Redis", err)
+			}
+			gameServer.SetRoomDirectory(directory, instance)
+			slog.Info("Sharding rooms across instances", "addr", instance.Addr())
+		}
+	}
+
+	// 初始化 DIDComm 中继，玩家 DID 文档发布该服务端点。路由密钥由颁发者 KMS 中的种子密钥派生，
+	// 重启后保持不变，已发布的 DID 文档仍然有效
+	routingKey, err := aries.RoutingKeyFromKMS(issuerKeys)
+	if err != nil {
+		fatal("Failed to load DIDComm routing key", err)
+	}
+	didcommService, err := aries.NewDIDCommService(*publicURL+"/didcomm", gameServer, routingKey)
+	if err != nil {
+		fatal("Failed to initialize DIDComm service", err)
+	}
+	gameServer.SetDIDComm(didcommService)
+	didService.SetDIDCommEndpoint(didcommService.ServiceEndpoint())
+	connectionService := aries.NewConnectionService(didcommService.Endpoint(), gameServer, didauth.DIDFromContext)
+	vcService.EnableDIDComm(didcommService, gameServer)
+
+	// 提醒在线玩家续期即将过期的凭证
+	expiryConfig := vc.DefaultExpiryConfig()
+	expiryConfig.WarningWindow = *credentialExpiryWarning
+	stopExpiryScheduler := vcService.StartExpiryScheduler(expiryConfig, gameServer)
+
 	// 设置HTTP路由
 	mux := http.NewServeMux()
 
 	// 静态文件服务
 	mux.Handle("/", http.FileServer(http.Dir(*staticDir)))
 
-	// API路由 - DID管理
-	mux.HandleFunc("/api/did/create", didService.HandleCreateDIDWithAries)
-	mux.HandleFunc("/api/did/register", didService.HandleRegisterDID)
-	mux.HandleFunc("/api/did/resolve", didService.HandleResolveDID)
+	// DID、VC 和游戏路由，DID 认证令牌的 aud 和 htu 必须指向 -public-url
+	authConfig := didauth.DefaultConfig()
+	authConfig.PublicURL = *publicURL
+	didAuth := didauth.NewAuthenticator(didService, authConfig)
+	api.Register(mux, didAuth, didService, vcService, gameServer)
 
-	// API路由 - VC管理
-	mux.HandleFunc("/api/vc/issue", vcService.HandleIssueCredential)
-	mux.HandleFunc("/api/vc/verify", vcService.HandleVerifyCredential)
+	// DIDComm 消息入口、带外邀请和连接
+	api.RegisterDIDComm(mux, didAuth, didcommService, connectionService)
+
+	// Prometheus 指标
+	if *metricsEnabled {
+		mux.Handle("/metrics", metrics.Handler())
+	}
+
+	// 游戏目录公开查询，修改需要管理员认证，未启用管理接口时只读
+	gamesHandler := admin.ReadOnly(http.HandlerFunc(gameServer.HandleGames))
+
+	// 运维管理接口，未配置 API Key 或管理员 DID 时不启用
+	if *adminAPIKey != "" || *adminDIDs != "" {
+		adminConfig := admin.DefaultConfig()
+		adminConfig.APIKey = *adminAPIKey
+		adminConfig.DIDAuth = didAuth
+		if *adminDIDs != "" {
+			adminConfig.AdminDIDs = strings.Split(*adminDIDs, ",")
+		}
+		adminService, err := admin.NewService(adminConfig, gameServer, vcService, didService)
+		if err != nil {
+			fatal("Failed to initialize admin API", err)
+		}
+		adminService.SetAuditLog(auditLog)
+		adminService.SetWebhooks(webhooks)
+		mux.Handle(admin.PathPrefix, adminService)
+		gamesHandler = adminService.RequireForWrites(http.HandlerFunc(gameServer.HandleGames))
+		slog.Info("Admin API enabled")
+	}
+	mux.Handle(game.GamesPath, gamesHandler)
+	mux.Handle(game.GamesPath+"/", gamesHandler)
+
+	// 内部 gRPC API，供其他后端服务调用，未配置监听地址时不启用
+	var internalServer *grpcapi.Server
+	if *grpcAddr != "" {
+		internalServer, err = grpcapi.NewServer(grpcapi.Config{Token: *grpcToken}, didService, vcService, gameServer)
+		if err != nil {
+			fatal("Failed to initialize internal gRPC API", err)
+		}
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			fatal("Failed to listen for internal gRPC API", err)
+		}
+		go func() {
+			slog.Info("Starting internal gRPC API", "addr", *grpcAddr)
+			if err := internalServer.Serve(lis); err != nil {
+				fatal("Internal gRPC API failed", err)
+			}
+		}()
+	}
 
-	// WebSocket游戏连接
-	mux.HandleFunc("/ws/game", gameServer.HandleWebSocket)
+	// 按 IP 限制 DID/VC 和管理接口，避免滥用请求占用 roomMutex 和存储
+	apiLimiter := ratelimit.NewLimiter(ratelimit.Rate{Limit: *apiRate, Burst: *apiBurst})
 
 	server := &http.Server{
 		Addr:    *addr,
-		Handler: mux,
+		Handler: logging.Middleware(tracing.Middleware(audit.Middleware(ratelimit.Middleware(apiLimiter, []string{"/api/did/", "/api/vc/", game.LeaderboardPath, game.GuildsPath, game.AchievementsPath, game.InboxPath, game.GamesPath, admin.PathPrefix}, mux)))),
+	}
+
+	// TLS，证书在收到 SIGHUP 时重新加载，便于证书续期后无需重启
+	var redirectServer *http.Server
+	if *tlsCert != "" || *tlsKey != "" {
+		certReloader, err := certs.NewReloader(*tlsCert, *tlsKey)
+		if err != nil {
+			fatal("Failed to load TLS certificate", err)
+		}
+		server.TLSConfig = certReloader.TLSConfig()
+		slog.Info("TLS enabled", "cert", *tlsCert, "expires", certReloader.NotAfter())
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := certReloader.Reload(); err != nil {
+					slog.Error("Failed to reload TLS certificate, keeping the current one", logging.Err(err))
+					continue
+				}
+				slog.Info("Reloaded TLS certificate", "expires", certReloader.NotAfter())
+			}
+		}()
+
+		if *httpRedirectAddr != "" {
+			redirectServer = &http.Server{
+				Addr:    *httpRedirectAddr,
+				Handler: certs.RedirectHandler(*addr),
+			}
+			go func() {
+				slog.Info("Redirecting HTTP to HTTPS", "addr", *httpRedirectAddr)
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					fatal("HTTP redirect server failed to start", err)
+				}
+			}()
+		}
+	} else if *httpRedirectAddr != "" {
+		fatal("Invalid -http-redirect-addr", errors.New("requires -tls-cert and -tls-key"))
 	}
 
 	// 启动服务器
 	go func() {
-		log.Printf("Starting server on %s", *addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		slog.Info("Starting server", "addr", *addr, "tls", server.TLSConfig != nil)
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Server failed to start", err)
 		}
 	}()
 
@@ -87,13 +634,40 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	stopExpiryScheduler()
+
+	// 先通知并关闭 WebSocket 连接、写入游戏状态，HTTP 关闭不会等待已升级的连接
+	if err := gameServer.Shutdown(ctx); err != nil {
+		slog.Error("Failed to shut down game server cleanly", logging.Err(err))
+	}
+
+	if redirectServer != nil {
+		redirectServer.Shutdown(ctx)
+	}
+	if internalServer != nil {
+		internalServer.GracefulStop()
+	}
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
 	}
+	webhooks.Close()
+	if err := storageProvider.Close(); err != nil {
+		slog.Error("Failed to close storage", logging.Err(err))
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", logging.Err(err))
+	}
+
+	slog.Info("Server exited")
+}
 
-	log.Println("Server exited")
-}
\ No newline at end of file
+// fatal 记录错误并退出进程
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
@@ -0,0 +1,122 @@
+// scenario 执行 YAML 描述的集成测试场景。未指定 -url 时每个场景在新的进程内内存服务器上运行，
+// 可以直接在 CI 中执行；指定 -url 时对已运行的服务器执行。任一场景失败时以状态码 1 退出
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/scenario"
+)
+
+func main() {
+	var (
+		serverURL = flag.String("url", "", "Base URL of a running game server (default: start an in-process server per scenario)")
+		timeout   = flag.Duration("timeout", scenario.DefaultTimeout, "Default time to wait for each expected message")
+		verbose   = flag.Bool("v", false, "Log every step and saved variable")
+		logLevel  = flag.String("log-level", "error", "Minimum log level of the in-process server: debug, info, warn or error")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <scenario.yaml|directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := logging.Setup(logging.Config{Level: *logLevel, Format: logging.FormatText}, os.Stderr); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	files, err := scenarioFiles(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failed := 0
+	for _, file := range files {
+		start := time.Now()
+		name, err := runFile(file, *serverURL, *timeout, *verbose)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s (%s, %s)\n  %v\n", name, file, elapsed, strings.ReplaceAll(err.Error(), "\n", "\n  "))
+			continue
+		}
+		fmt.Printf("PASS %s (%s, %s)\n", name, file, elapsed)
+	}
+
+	fmt.Printf("%d passed, %d failed\n", len(files)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runFile 加载并执行一个场景文件，返回场景名
+func runFile(file, serverURL string, timeout time.Duration, verbose bool) (string, error) {
+	s, err := scenario.Load(file)
+	if err != nil {
+		return file, err
+	}
+
+	if serverURL == "" {
+		server, err := scenario.StartServer()
+		if err != nil {
+			return s.Name, err
+		}
+		defer server.Close()
+		serverURL = server.URL
+	}
+
+	runner := scenario.NewRunner(serverURL)
+	runner.Timeout = timeout
+	if verbose {
+		runner.Logf = func(format string, args ...interface{}) {
+			fmt.Printf("  "+format+"\n", args...)
+		}
+	}
+	return s.Name, runner.Run(context.Background(), s)
+}
+
+// scenarioFiles 展开参数中的目录为其中的 .yaml 和 .yml 文件，按文件名排序
+func scenarioFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		var found []string
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				found = append(found, filepath.Join(arg, entry.Name()))
+			}
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no scenario files in %s", strings.Join(args, ", "))
+	}
+	return files, nil
+}
@@ -0,0 +1,655 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/vc"
+	"github.com/czh0526/game/server/internal/webhook"
+	pkgvc "github.com/czh0526/game/server/pkg/vc"
+)
+
+// PathPrefix 管理接口的路由前缀
+const PathPrefix = "/admin/"
+
+// maxBodySize 管理请求体的大小上限
+const maxBodySize = 1 << 20
+
+// defaultAuditLimit 审计日志默认返回的记录数
+const defaultAuditLimit = 100
+
+// 房间事件重放每次返回的记录数
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// Config 管理接口配置，APIKey 和 AdminDIDs 至少设置一项
+type Config struct {
+	APIKey    string                 // 以 Authorization: Bearer <key> 认证
+	AdminDIDs []string               // 可用认证密钥签名请求的管理员 DID
+	DIDAuth   *didauth.Authenticator // 校验管理员 DID 的 JWT 或 HTTP Signature，设置 AdminDIDs 时必须提供
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{}
+}
+
+// Service 运维管理接口：查看玩家和房间、踢出和封禁玩家、发布公告、强制撤销凭证和切换维护模式
+type Service struct {
+	config     Config
+	admins     map[string]bool
+	gameServer *game.SimpleServer
+	vcService  *vc.SimpleService
+	didService *did.SimpleService
+	auditLog   *audit.Log
+	webhooks   *webhook.Dispatcher
+	mux        *http.ServeMux
+}
+
+// NewService 创建管理接口服务
+func NewService(config Config, gameServer *game.SimpleServer, vcService *vc.SimpleService, didService *did.SimpleService) (*Service, error) {
+	if config.APIKey == "" && len(config.AdminDIDs) == 0 {
+		return nil, errors.New("admin API requires an API key or at least one admin DID")
+	}
+	if len(config.AdminDIDs) > 0 && config.DIDAuth == nil {
+		return nil, errors.New("admin DIDs require a DID authenticator")
+	}
+
+	s := &Service{
+		config:     config,
+		admins:     make(map[string]bool, len(config.AdminDIDs)),
+		gameServer: gameServer,
+		vcService:  vcService,
+		didService: didService,
+		mux:        http.NewServeMux(),
+	}
+	for _, adminDID := range config.AdminDIDs {
+		s.admins[adminDID] = true
+	}
+
+	s.mux.HandleFunc(PathPrefix+"players", s.handlePlayers)
+	s.mux.HandleFunc(PathPrefix+"players/kick", s.handleKick)
+	s.mux.HandleFunc(PathPrefix+"players/ban", s.handleSanction(game.SanctionBan))
+	s.mux.HandleFunc(PathPrefix+"players/unban", s.handleLift(game.SanctionBan))
+	s.mux.HandleFunc(PathPrefix+"players/mute", s.handleSanction(game.SanctionMute))
+	s.mux.HandleFunc(PathPrefix+"players/unmute", s.handleLift(game.SanctionMute))
+	s.mux.HandleFunc(PathPrefix+"bans", s.handleSanctions(game.SanctionBan))
+	s.mux.HandleFunc(PathPrefix+"mutes", s.handleSanctions(game.SanctionMute))
+	s.mux.HandleFunc(PathPrefix+"audit", s.handleAudit)
+	s.mux.HandleFunc(PathPrefix+"audit/identity", s.handleIdentityAudit)
+	s.mux.HandleFunc(PathPrefix+"announce", s.handleAnnounce)
+	s.mux.HandleFunc(PathPrefix+"inbox", s.handleNotify)
+	s.mux.HandleFunc(PathPrefix+"rooms", s.handleRooms)
+	s.mux.HandleFunc(PathPrefix+"rooms/", s.handleRoom)
+	s.mux.HandleFunc(PathPrefix+"credentials/revoke", s.handleRevoke)
+	s.mux.HandleFunc(PathPrefix+"credentials/achievement", s.handleIssueAchievement)
+	s.mux.HandleFunc(PathPrefix+"credentials/issue", s.handleIssueCredential)
+	s.mux.HandleFunc(PathPrefix+"issuer/keys", s.handleIssuerKeys)
+	s.mux.HandleFunc(PathPrefix+"issuer/rotate", s.handleRotateIssuerKey)
+	s.mux.HandleFunc(PathPrefix+"trust", s.handleTrust)
+	s.mux.HandleFunc(PathPrefix+"trust/", s.handleTrustedIssuer)
+	s.mux.HandleFunc(PathPrefix+"maintenance", s.handleMaintenance)
+	s.mux.HandleFunc(PathPrefix+"tasks", s.handleTasks)
+	s.mux.HandleFunc(PathPrefix+"tasks/reload", s.handleReloadTasks)
+	s.mux.HandleFunc(PathPrefix+"webhooks", s.handleWebhooks)
+	s.mux.HandleFunc(PathPrefix+"webhooks/", s.handleWebhook)
+	s.mux.HandleFunc(PathPrefix+"anticheat", s.handleAntiCheatReports)
+	s.mux.HandleFunc(PathPrefix+"anticheat/", s.handleAntiCheatReport)
+	s.mux.HandleFunc(PathPrefix+"anticheat/reset", s.handleAntiCheatReset)
+
+	return s, nil
+}
+
+// SetAuditLog 设置 DID 和凭证操作的审计日志，供 /admin/audit/identity 查询
+func (s *Service) SetAuditLog(auditLog *audit.Log) {
+	s.auditLog = auditLog
+}
+
+// ServeHTTP 认证后分发管理请求
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.serveAuthenticated(w, r, s.mux)
+}
+
+// RequireForWrites 包装公开查询、由管理员修改的接口：GET 和 HEAD 请求直接通过，其他请求需要与管理接口相同的认证
+func (s *Service) RequireForWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.serveAuthenticated(w, r, next)
+	})
+}
+
+// ReadOnly 未启用管理接口时包装 RequireForWrites 所用的接口，拒绝所有修改请求
+func ReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "Admin API is not enabled", http.StatusForbidden)
+	})
+}
+
+// serveAuthenticated 认证请求，在上下文中记录操作者后交给 next 处理
+func (s *Service) serveAuthenticated(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	actor, err := s.authenticate(r)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Rejected admin request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr, logging.Err(err))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		logging.FromContext(r.Context()).Info("Admin request", "actor", actor, "method", r.Method, "path", r.URL.Path)
+	}
+	ctx := audit.WithCaller(r.Context(), "admin:"+actor)
+	next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, actorKey{}, actor)))
+}
+
+// actorKey 请求上下文中操作者标识的键
+type actorKey struct{}
+
+// actorFrom 返回认证得到的操作者，写入审计日志
+func actorFrom(r *http.Request) string {
+	actor, _ := r.Context().Value(actorKey{}).(string)
+	return actor
+}
+
+// KickRequest 踢出、封禁或禁言玩家请求
+type KickRequest struct {
+	DID      string `json:"did"`
+	Reason   string `json:"reason,omitempty"`
+	Duration string `json:"duration,omitempty"` // 封禁和禁言的时长，如 "24h"，为空表示永久
+}
+
+// AnnounceRequest 公告请求，RoomID 为空时发送给本实例所有在线玩家
+type AnnounceRequest struct {
+	Message string `json:"message"`
+	RoomID  string `json:"roomId,omitempty"`
+}
+
+// RevokeRequest 强制撤销凭证请求
+type RevokeRequest struct {
+	CredentialID string `json:"credentialId"`
+}
+
+// IssueAchievementRequest 颁发成就凭证请求。玩家已有这项成就的有效凭证时返回原凭证，
+// Reissue 为 true 时重新颁发并撤销原凭证
+type IssueAchievementRequest struct {
+	PlayerDID   string `json:"playerDid"`
+	GameID      string `json:"gameId"`
+	PlayerID    string `json:"playerId,omitempty"` // 为空时取玩家 DID 的最后一段
+	Achievement string `json:"achievement"`
+	Score       int    `json:"score,omitempty"`
+	Reissue     bool   `json:"reissue,omitempty"`
+}
+
+// IssueCredentialRequest 颁发管理员指定类型和主体的凭证请求，玩家不能通过 /api/vc/issue 申请这些类型
+type IssueCredentialRequest struct {
+	PlayerDID string                  `json:"playerDid"`
+	Type      string                  `json:"type"`
+	Subject   pkgvc.CredentialSubject `json:"credentialSubject"`
+	ExpiresAt *time.Time              `json:"expiresAt,omitempty"`
+}
+
+// RotateKeyRequest 轮换颁发者签名密钥请求
+type RotateKeyRequest struct {
+	Overlap string `json:"overlap,omitempty"` // 旧密钥继续发布在 DID 文档中的时长，如 "168h"，为空时使用默认值
+}
+
+// MaintenanceRequest 切换游戏维护模式请求
+type MaintenanceRequest struct {
+	GameID  string `json:"gameId"`
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+func (s *Service) handlePlayers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"players": s.gameServer.ConnectedPlayers(),
+	})
+}
+
+func (s *Service) handleKick(w http.ResponseWriter, r *http.Request) {
+	var req KickRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.DID == "" {
+		http.Error(w, "did is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.gameServer.KickPlayer(req.DID, req.Reason, actorFrom(r)); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to kick player: %v", err), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"did":     req.DID,
+	})
+}
+
+// handleSanction 封禁或禁言玩家
+func (s *Service) handleSanction(kind game.SanctionKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req KickRequest
+		if !decodePost(w, r, &req) {
+			return
+		}
+		if req.DID == "" {
+			http.Error(w, "did is required", http.StatusBadRequest)
+			return
+		}
+
+		var duration time.Duration
+		if req.Duration != "" {
+			var err error
+			duration, err = time.ParseDuration(req.Duration)
+			if err != nil || duration <= 0 {
+				http.Error(w, "duration must be a positive duration such as 24h", http.StatusBadRequest)
+				return
+			}
+		}
+
+		apply := s.gameServer.BanPlayer
+		if kind == game.SanctionMute {
+			apply = s.gameServer.MutePlayer
+		}
+		sanction, err := apply(req.DID, req.Reason, duration, actorFrom(r))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to %s player: %v", kind, err), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"success":  true,
+			"sanction": sanction,
+		})
+	}
+}
+
+// handleLift 解除封禁或禁言
+func (s *Service) handleLift(kind game.SanctionKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req KickRequest
+		if !decodePost(w, r, &req) {
+			return
+		}
+		if req.DID == "" {
+			http.Error(w, "did is required", http.StatusBadRequest)
+			return
+		}
+
+		lift := s.gameServer.UnbanPlayer
+		if kind == game.SanctionMute {
+			lift = s.gameServer.UnmutePlayer
+		}
+		lifted, err := lift(req.DID, actorFrom(r))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to lift %s: %v", kind, err), http.StatusInternalServerError)
+			return
+		}
+		if !lifted {
+			http.Error(w, fmt.Sprintf("Player has no active %s", kind), http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"success": true,
+			"did":     req.DID,
+		})
+	}
+}
+
+// handleSanctions 列出仍然有效的封禁或禁言
+func (s *Service) handleSanctions(kind game.SanctionKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"sanctions": s.gameServer.Sanctions(kind),
+		})
+	}
+}
+
+// handleAudit 查询审计日志，可按 did 过滤，limit 默认 100
+func (s *Service) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultAuditLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, err := s.gameServer.AuditLog(r.URL.Query().Get("did"), limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"entries": entries,
+	})
+}
+
+// handleIdentityAudit 查询 DID 和凭证操作的审计日志，可按 category、operation、subject、caller、
+// outcome 和 since（RFC 3339）过滤，limit 默认 100
+func (s *Service) handleIdentityAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.auditLog == nil {
+		http.Error(w, "Identity audit log is not enabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultAuditLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter := audit.Filter{
+		Category:  query.Get("category"),
+		Operation: query.Get("operation"),
+		Subject:   query.Get("subject"),
+		Caller:    query.Get("caller"),
+		Outcome:   query.Get("outcome"),
+	}
+	if value := query.Get("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.Since = since
+	}
+
+	entries, err := s.auditLog.Query(filter, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"entries": entries,
+	})
+}
+
+func (s *Service) handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	var req AnnounceRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.gameServer.Announce(req.Message, req.RoomID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to announce: %v", err), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+	})
+}
+
+func (s *Service) handleRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"rooms": s.gameServer.Rooms(),
+	})
+}
+
+func (s *Service) handleRoom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roomID := strings.TrimPrefix(r.URL.Path, PathPrefix+"rooms/")
+	if strings.HasSuffix(roomID, "/events") {
+		s.handleRoomEvents(w, r, strings.TrimSuffix(roomID, "/events"))
+		return
+	}
+
+	room, err := s.gameServer.Room(roomID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, room)
+}
+
+// handleRoomEvents 重放房间事件日志，after 为上次返回的最后一个序号，limit 默认 100
+func (s *Service) handleRoomEvents(w http.ResponseWriter, r *http.Request, roomID string) {
+	var after uint64
+	if value := r.URL.Query().Get("after"); value != "" {
+		var err error
+		if after, err = strconv.ParseUint(value, 10, 64); err != nil {
+			http.Error(w, "after must be a sequence number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultEventLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxEventLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxEventLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := s.gameServer.RoomEvents(roomID, after, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to replay events: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"roomId": roomID,
+		"events": events,
+	})
+}
+
+func (s *Service) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	var req RevokeRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.CredentialID == "" {
+		http.Error(w, "credentialId is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.vcService.RevokeCredentialContext(r.Context(), req.CredentialID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to revoke credential: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":      true,
+		"credentialId": req.CredentialID,
+	})
+}
+
+func (s *Service) handleIssueAchievement(w http.ResponseWriter, r *http.Request) {
+	var req IssueAchievementRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.PlayerDID == "" || req.GameID == "" || req.Achievement == "" {
+		http.Error(w, "playerDid, gameId and achievement are required", http.StatusBadRequest)
+		return
+	}
+	if req.PlayerID == "" {
+		req.PlayerID = req.PlayerDID[strings.LastIndex(req.PlayerDID, ":")+1:]
+	}
+
+	issue := s.vcService.IssueAchievementCredential
+	if req.Reissue {
+		issue = s.vcService.ReissueAchievementCredential
+	}
+	credential, err := issue(r.Context(), req.PlayerDID, req.GameID, req.PlayerID, req.Achievement, req.Score)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to issue achievement credential: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"credential": credential,
+		"reissued":   req.Reissue,
+	})
+}
+
+func (s *Service) handleIssueCredential(w http.ResponseWriter, r *http.Request) {
+	var req IssueCredentialRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.PlayerDID == "" || req.Type == "" {
+		http.Error(w, "playerDid and type are required", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := s.vcService.IssueAdminCredential(r.Context(), req.PlayerDID, req.Type, req.Subject, req.ExpiresAt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to issue credential: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"credential": credential,
+	})
+}
+
+func (s *Service) handleIssuerKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"issuer": s.vcService.IssuerDID(),
+		"keys":   s.vcService.IssuerKeys(),
+	})
+}
+
+func (s *Service) handleRotateIssuerKey(w http.ResponseWriter, r *http.Request) {
+	var req RotateKeyRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+
+	overlap := vc.DefaultKeyOverlap
+	if req.Overlap != "" {
+		var err error
+		if overlap, err = time.ParseDuration(req.Overlap); err != nil || overlap < 0 {
+			http.Error(w, "overlap must be a non-negative duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	key, err := s.vcService.RotateIssuerKey(overlap)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to rotate issuer key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":            true,
+		"verificationMethod": s.vcService.IssuerDID() + key.Fragment,
+		"key":                key,
+	})
+}
+
+func (s *Service) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{
+			"games": s.gameServer.Maintenance(),
+		})
+	case http.MethodPost:
+		var req MaintenanceRequest
+		if !decodePost(w, r, &req) {
+			return
+		}
+		if req.GameID == "" {
+			http.Error(w, "gameId is required", http.StatusBadRequest)
+			return
+		}
+
+		s.gameServer.SetMaintenance(req.GameID, req.Enabled, req.Message)
+		writeJSON(w, map[string]interface{}{
+			"success": true,
+			"games":   s.gameServer.Maintenance(),
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodePost 校验 POST 方法并解码 JSON 请求体，失败时已写入错误响应
+func decodePost(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,232 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/storage"
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+const testAPIKey = "secret"
+
+// newTestAdminAPI 创建以 API 密钥认证、连接内存游戏服务器的管理接口
+func newTestAdminAPI(t *testing.T) (*Service, *game.SimpleServer) {
+	t.Helper()
+	didService := did.NewSimpleService()
+	vcService, err := vc.NewSimpleService(didService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gameServer, err := game.NewSimpleServer(didService, vcService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gameServer.Close() })
+
+	config := DefaultConfig()
+	config.APIKey = testAPIKey
+	service, err := NewService(config, gameServer, vcService, didService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, gameServer
+}
+
+// call 以 API 密钥发送管理请求，body 不为空时编码为 JSON，返回状态码和解码后的响应
+func call(t *testing.T, service *Service, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader *strings.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = strings.NewReader(string(data))
+	} else {
+		reader = strings.NewReader("")
+	}
+	request := httptest.NewRequest(method, path, reader)
+	request.Header.Set("Authorization", "Bearer "+testAPIKey)
+	recorder := httptest.NewRecorder()
+	service.ServeHTTP(recorder, request)
+
+	var response map[string]interface{}
+	if strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s: invalid JSON response: %v", method, path, err)
+		}
+	}
+	return recorder.Code, response
+}
+
+func TestAdminRequiresAuthentication(t *testing.T) {
+	service, _ := newTestAdminAPI(t)
+
+	recorder := httptest.NewRecorder()
+	service.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, PathPrefix+"players", nil))
+	if recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("unauthenticated request: status %d, WWW-Authenticate %q", recorder.Code, recorder.Header().Get("WWW-Authenticate"))
+	}
+	if code, _ := call(t, service, http.MethodGet, PathPrefix+"players", nil); code != http.StatusOK {
+		t.Errorf("authenticated request: status %d", code)
+	}
+}
+
+func TestBanListAndUnban(t *testing.T) {
+	service, gameServer := newTestAdminAPI(t)
+	const player = "did:example:cheater"
+
+	code, response := call(t, service, http.MethodPost, PathPrefix+"players/ban", KickRequest{DID: player, Reason: "speed hack", Duration: "24h"})
+	if code != http.StatusOK {
+		t.Fatalf("ban: status %d", code)
+	}
+	sanction := response["sanction"].(map[string]interface{})
+	if sanction["actor"] != "api-key" || sanction["reason"] != "speed hack" || sanction["until"] == nil {
+		t.Errorf("sanction %v", sanction)
+	}
+
+	code, response = call(t, service, http.MethodGet, PathPrefix+"bans", nil)
+	if bans, _ := response["sanctions"].([]interface{}); code != http.StatusOK || len(bans) != 1 {
+		t.Fatalf("bans: status %d, %v", code, response)
+	}
+	// 封禁记入游戏审计日志
+	entries, err := gameServer.AuditLog(player, 10)
+	if err != nil || len(entries) != 1 || entries[0].Actor != "api-key" {
+		t.Errorf("audit log %+v, %v", entries, err)
+	}
+
+	if code, _ := call(t, service, http.MethodPost, PathPrefix+"players/unban", KickRequest{DID: player}); code != http.StatusOK {
+		t.Errorf("unban: status %d", code)
+	}
+	if code, _ := call(t, service, http.MethodPost, PathPrefix+"players/unban", KickRequest{DID: player}); code != http.StatusNotFound {
+		t.Errorf("second unban: status %d, want %d", code, http.StatusNotFound)
+	}
+	if _, response := call(t, service, http.MethodGet, PathPrefix+"bans", nil); len(response["sanctions"].([]interface{})) != 0 {
+		t.Errorf("ban still listed: %v", response)
+	}
+}
+
+func TestAdminRejectsInvalidRequests(t *testing.T) {
+	service, _ := newTestAdminAPI(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"ban without did", http.MethodPost, PathPrefix + "players/ban", KickRequest{}, http.StatusBadRequest},
+		{"negative duration", http.MethodPost, PathPrefix + "players/mute", KickRequest{DID: "did:example:a", Duration: "-1h"}, http.StatusBadRequest},
+		{"unparsable duration", http.MethodPost, PathPrefix + "players/ban", KickRequest{DID: "did:example:a", Duration: "forever"}, http.StatusBadRequest},
+		{"kick offline player", http.MethodPost, PathPrefix + "players/kick", KickRequest{DID: "did:example:offline"}, http.StatusNotFound},
+		{"ban with GET", http.MethodGet, PathPrefix + "players/ban", nil, http.StatusMethodNotAllowed},
+		{"list players with POST", http.MethodPost, PathPrefix + "players", nil, http.StatusMethodNotAllowed},
+		{"empty announcement", http.MethodPost, PathPrefix + "announce", AnnounceRequest{Message: "  "}, http.StatusBadRequest},
+		{"announcement to unknown room", http.MethodPost, PathPrefix + "announce", AnnounceRequest{Message: "hi", RoomID: "missing"}, http.StatusNotFound},
+		{"unknown room", http.MethodGet, PathPrefix + "rooms/missing", nil, http.StatusNotFound},
+		{"revoke without id", http.MethodPost, PathPrefix + "credentials/revoke", RevokeRequest{}, http.StatusBadRequest},
+		{"achievement without name", http.MethodPost, PathPrefix + "credentials/achievement", IssueAchievementRequest{PlayerDID: "did:example:a", GameID: "g"}, http.StatusBadRequest},
+		{"maintenance without game", http.MethodPost, PathPrefix + "maintenance", MaintenanceRequest{Enabled: true}, http.StatusBadRequest},
+		{"negative key overlap", http.MethodPost, PathPrefix + "issuer/rotate", RotateKeyRequest{Overlap: "-1h"}, http.StatusBadRequest},
+		{"event limit too large", http.MethodGet, PathPrefix + "rooms/lobby/events?limit=5000", nil, http.StatusBadRequest},
+		{"identity audit disabled", http.MethodGet, PathPrefix + "audit/identity", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if code, _ := call(t, service, tt.method, tt.path, tt.body); code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestMaintenanceToggle(t *testing.T) {
+	service, gameServer := newTestAdminAPI(t)
+
+	code, response := call(t, service, http.MethodPost, PathPrefix+"maintenance", MaintenanceRequest{GameID: "chess", Enabled: true})
+	if code != http.StatusOK {
+		t.Fatalf("enable maintenance: status %d", code)
+	}
+	if games := response["games"].(map[string]interface{}); games["chess"] != "game chess is under maintenance" {
+		t.Errorf("games %v", games)
+	}
+	if _, enabled := gameServer.Maintenance()["chess"]; !enabled {
+		t.Error("game server is not in maintenance mode")
+	}
+
+	call(t, service, http.MethodPost, PathPrefix+"maintenance", MaintenanceRequest{GameID: "chess"})
+	if _, response := call(t, service, http.MethodGet, PathPrefix+"maintenance", nil); len(response["games"].(map[string]interface{})) != 0 {
+		t.Errorf("maintenance still enabled: %v", response)
+	}
+}
+
+func TestRoomsAndEvents(t *testing.T) {
+	service, gameServer := newTestAdminAPI(t)
+	if _, err := gameServer.CreateRoom("lobby", "chess", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	code, response := call(t, service, http.MethodGet, PathPrefix+"rooms/lobby", nil)
+	if code != http.StatusOK || response["id"] != "lobby" || response["gameId"] != "chess" {
+		t.Errorf("room: status %d, %v", code, response)
+	}
+	code, response = call(t, service, http.MethodGet, PathPrefix+"rooms", nil)
+	if rooms, _ := response["rooms"].([]interface{}); code != http.StatusOK || len(rooms) != 1 {
+		t.Errorf("rooms: status %d, %v", code, response)
+	}
+	code, response = call(t, service, http.MethodGet, PathPrefix+"rooms/lobby/events?after=0", nil)
+	if code != http.StatusOK || response["roomId"] != "lobby" {
+		t.Errorf("events: status %d, %v", code, response)
+	}
+}
+
+func TestIdentityAuditQuery(t *testing.T) {
+	service, _ := newTestAdminAPI(t)
+	auditLog, err := audit.NewLog(storage.NewMemoryProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.SetAuditLog(auditLog)
+
+	// 管理请求以 admin:{操作者} 记为调用方
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auditLog.Record(r.Context(), audit.CategoryVC, audit.OpRevoke, "urn:uuid:1", nil)
+	})
+	request := httptest.NewRequest(http.MethodPost, "/api/vc/revoke", nil)
+	request.Header.Set("Authorization", "Bearer "+testAPIKey)
+	service.RequireForWrites(handler).ServeHTTP(httptest.NewRecorder(), request)
+
+	code, response := call(t, service, http.MethodGet, PathPrefix+"audit/identity?category=vc&caller=admin:api-key", nil)
+	entries, _ := response["entries"].([]interface{})
+	if code != http.StatusOK || len(entries) != 1 {
+		t.Fatalf("identity audit: status %d, %v", code, response)
+	}
+	if entry := entries[0].(map[string]interface{}); entry["operation"] != audit.OpRevoke || entry["subject"] != "urn:uuid:1" {
+		t.Errorf("entry %v", entry)
+	}
+
+	if code, _ := call(t, service, http.MethodGet, PathPrefix+"audit/identity?since=yesterday", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid since: status %d", code)
+	}
+	if code, _ := call(t, service, http.MethodGet, PathPrefix+"audit/identity?limit=0", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid limit: status %d", code)
+	}
+}
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	handler := ReadOnly(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for method, want := range map[string]int{http.MethodGet: http.StatusOK, http.MethodHead: http.StatusOK, http.MethodPost: http.StatusForbidden, http.MethodDelete: http.StatusForbidden} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, "/api/games", nil))
+		if recorder.Code != want {
+			t.Errorf("%s: status %d, want %d", method, recorder.Code, want)
+		}
+	}
+}
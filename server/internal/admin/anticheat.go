@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AntiCheatResetRequest 清除玩家反作弊记录请求
+type AntiCheatResetRequest struct {
+	DID string `json:"did"`
+}
+
+// handleAntiCheatReports 按分数从高到低列出有违规记录的玩家
+func (s *Service) handleAntiCheatReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"reports": s.gameServer.AntiCheatReports(),
+	})
+}
+
+// handleAntiCheatReport GET /admin/anticheat/{did} 返回玩家的完整报告，包括影子日志
+func (s *Service) handleAntiCheatReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := s.gameServer.AntiCheatReport(strings.TrimPrefix(r.URL.Path, PathPrefix+"anticheat/"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, report)
+}
+
+// handleAntiCheatReset 清除玩家的分数和违规记录，不解除已执行的封禁
+func (s *Service) handleAntiCheatReset(w http.ResponseWriter, r *http.Request) {
+	var req AntiCheatResetRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.DID == "" {
+		http.Error(w, "did is required", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": s.gameServer.ResetAntiCheat(req.DID, actorFrom(r)),
+		"did":     req.DID,
+	})
+}
@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/didauth"
+)
+
+// authenticate 校验 API Key 或管理员 DID 认证，返回操作者标识用于日志。
+// 管理员 DID 与其他接口一样使用 didauth 的 JWT 或 HTTP Signature，令牌和签名只能使用一次
+func (s *Service) authenticate(r *http.Request) (string, error) {
+	token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if bearer && s.config.APIKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.APIKey)) == 1 {
+		return "api-key", nil
+	}
+
+	if s.config.DIDAuth == nil {
+		if bearer {
+			return "", errors.New("invalid API key")
+		}
+		return "", errors.New("missing credentials")
+	}
+	adminDID, err := s.config.DIDAuth.Authenticate(r)
+	switch {
+	case errors.Is(err, didauth.ErrNoCredentials) && bearer:
+		return "", errors.New("invalid API key")
+	case err != nil:
+		return "", err
+	case !s.admins[adminDID]:
+		return "", fmt.Errorf("%s is not an administrator", adminDID)
+	}
+	return adminDID, nil
+}
@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+const testPublicURL = "https://game.example.com"
+
+// testAdmin 测试用的 did:key 及其认证密钥
+type testAdmin struct {
+	did        string
+	keyID      string
+	privateKey ed25519.PrivateKey
+}
+
+func newTestAdmin(t *testing.T) testAdmin {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	didKey := pkgdid.NewKeyDID(publicKey)
+	return testAdmin{did: didKey, keyID: didKey + "#" + strings.TrimPrefix(didKey, pkgdid.KeyDIDPrefix), privateKey: privateKey}
+}
+
+// token 签发绑定到 method 和 path 的管理员 JWT
+func (a testAdmin) token(t *testing.T, jti, method, path string) string {
+	t.Helper()
+	now := time.Now()
+	token, err := vc.SignCompactJWT("JWT", a.keyID, didauth.Claims{
+		Issuer:    a.did,
+		Audience:  testPublicURL,
+		JWTID:     jti,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		Method:    method,
+		URI:       testPublicURL + path,
+	}, a.privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newTestAdminService(t *testing.T, apiKey string, admins ...string) *Service {
+	t.Helper()
+	authConfig := didauth.DefaultConfig()
+	authConfig.PublicURL = testPublicURL
+
+	config := DefaultConfig()
+	config.APIKey = apiKey
+	config.AdminDIDs = admins
+	config.DIDAuth = didauth.NewAuthenticator(did.NewSimpleService(), authConfig)
+	service, err := NewService(config, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return service
+}
+
+// serveWrite 以 authorization 发送 POST 请求，返回状态码和处理函数看到的操作者
+func serveWrite(service *Service, authorization string) (int, string) {
+	var actor string
+	handler := service.RequireForWrites(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = actorFrom(r)
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/api/games", nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder.Code, actor
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	service := newTestAdminService(t, "secret")
+
+	if code, actor := serveWrite(service, "Bearer secret"); code != http.StatusOK || actor != "api-key" {
+		t.Errorf("API key: status %d, actor %q", code, actor)
+	}
+	for _, authorization := range []string{"", "Bearer wrong"} {
+		if code, _ := serveWrite(service, authorization); code != http.StatusUnauthorized {
+			t.Errorf("%q: status %d, want %d", authorization, code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestAuthenticateAdminDID(t *testing.T) {
+	admin, other := newTestAdmin(t), newTestAdmin(t)
+	service := newTestAdminService(t, "", admin.did)
+
+	token := admin.token(t, "1", http.MethodPost, "/api/games")
+	if code, actor := serveWrite(service, "Bearer "+token); code != http.StatusOK || actor != admin.did {
+		t.Fatalf("admin token: status %d, actor %q", code, actor)
+	}
+	// 管理员令牌与其他 DID 认证一样只能使用一次
+	if code, _ := serveWrite(service, "Bearer "+token); code != http.StatusUnauthorized {
+		t.Errorf("replayed admin token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not an administrator", other.token(t, "2", http.MethodPost, "/api/games")},
+		{"other path", admin.token(t, "3", http.MethodPost, "/admin/players/ban")},
+		{"other method", admin.token(t, "4", http.MethodDelete, "/api/games")},
+	}
+	for _, tt := range tests {
+		if code, _ := serveWrite(service, "Bearer "+tt.token); code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d", tt.name, code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestNewServiceRequiresDIDAuthForAdminDIDs(t *testing.T) {
+	config := DefaultConfig()
+	config.AdminDIDs = []string{"did:example:admin"}
+	if _, err := NewService(config, nil, nil, nil); err == nil {
+		t.Error("admin DIDs accepted without a DID authenticator")
+	}
+}
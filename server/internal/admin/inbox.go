@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/game"
+)
+
+// NotifyRequest 向玩家收件箱发送系统通知请求
+type NotifyRequest struct {
+	DID   string `json:"did"`
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+// handleNotify 向玩家的收件箱发送系统通知，玩家不在线时在下次认证时推送
+func (s *Service) handleNotify(w http.ResponseWriter, r *http.Request) {
+	var req NotifyRequest
+	if !decodePost(w, r, &req) {
+		return
+	}
+	if req.DID == "" || strings.TrimSpace(req.Title) == "" {
+		http.Error(w, "did and title are required", http.StatusBadRequest)
+		return
+	}
+
+	mail, err := s.gameServer.Notify(req.DID, game.MailSystem, req.Title, req.Body, nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to send notification: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"message": mail,
+	})
+}
@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/czh0526/game/server/internal/game"
+)
+
+// handleTasks 返回各游戏当前的任务定义
+func (s *Service) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"games": s.gameServer.TaskDefinitions(),
+	})
+}
+
+// handleReloadTasks 重新加载任务定义目录，任一文件无效时保留原有定义并返回 400。
+// 新定义只用于之后创建的房间
+func (s *Service) handleReloadTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	counts, err := s.gameServer.ReloadTasks()
+	if errors.Is(err, game.ErrNoTaskDirectory) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload tasks: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"games":   counts,
+	})
+}
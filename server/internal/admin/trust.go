@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+// handleTrust GET 列出信任登记表和本地颁发者，POST 登记颁发者或替换其策略
+func (s *Service) handleTrust(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var gameIssuers []string
+		for _, issuer := range s.vcService.GameIssuers() {
+			gameIssuers = append(gameIssuers, issuer)
+		}
+		sort.Strings(gameIssuers)
+		localIssuers := append([]string{s.vcService.IssuerDID()}, gameIssuers...)
+		writeJSON(w, map[string]interface{}{
+			"issuers":      s.vcService.TrustedIssuers(),
+			"localIssuers": localIssuers,
+		})
+	case http.MethodPost:
+		var req vc.TrustedIssuer
+		if !decodePost(w, r, &req) {
+			return
+		}
+
+		issuer, err := s.vcService.SetTrustedIssuer(req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update trusted issuer: %v", err), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, issuer)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTrustedIssuer DELETE /admin/trust/{did} 从信任登记表删除颁发者
+func (s *Service) handleTrustedIssuer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	issuerDID := strings.TrimPrefix(r.URL.Path, PathPrefix+"trust/")
+	err := s.vcService.RemoveTrustedIssuer(issuerDID)
+	if errors.Is(err, vc.ErrTrustedIssuerNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to remove trusted issuer: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"did":     issuerDID,
+	})
+}
@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/webhook"
+)
+
+// WebhookRequest 登记 webhook 订阅请求
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"` // 为空时自动生成
+}
+
+// SetWebhooks 设置 webhook 投递器，供 /admin/webhooks 管理订阅
+func (s *Service) SetWebhooks(dispatcher *webhook.Dispatcher) {
+	s.webhooks = dispatcher
+}
+
+// handleWebhooks GET 列出订阅，POST 登记订阅并返回签名密钥
+func (s *Service) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		http.Error(w, "Webhooks are not enabled", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{
+			"subscriptions": s.webhooks.Subscriptions(),
+			"events":        webhook.EventTypes,
+		})
+	case http.MethodPost:
+		var req WebhookRequest
+		if !decodePost(w, r, &req) {
+			return
+		}
+
+		subscription, err := s.webhooks.Subscribe(req.URL, req.Events, req.Secret)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to register webhook: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(subscription)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWebhook DELETE /admin/webhooks/{id} 删除订阅
+func (s *Service) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		http.Error(w, "Webhooks are not enabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, PathPrefix+"webhooks/")
+	err := s.webhooks.Unsubscribe(id)
+	if errors.Is(err, webhook.ErrSubscriptionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to remove webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"id":      id,
+	})
+}
@@ -0,0 +1,187 @@
+// Package api 定义 HTTP 层使用的服务接口并注册 REST 和 WebSocket 路由，
+// 路由只依赖接口，启动时可以按 -mode 选择不同的 DID/VC/游戏服务实现
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/vc"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+)
+
+// 服务栈模式
+const (
+	ModeSimple = "simple" // DID 只保存在内存中，不初始化 Aries
+	ModeAries  = "aries"  // 通过 Aries 框架和存储后端保存 DID
+)
+
+// ParseMode 校验 -mode 参数
+func ParseMode(mode string) (string, error) {
+	switch mode {
+	case ModeSimple, ModeAries:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown mode %q, expected %s or %s", mode, ModeSimple, ModeAries)
+}
+
+// DIDService HTTP 层使用的 DID 服务
+type DIDService interface {
+	didauth.Resolver
+
+	HandleWebDIDDocument(w http.ResponseWriter, r *http.Request)
+	HandleCreateDIDWithAries(w http.ResponseWriter, r *http.Request)
+	HandleRegistrationNonce(w http.ResponseWriter, r *http.Request)
+	HandleRegisterDID(w http.ResponseWriter, r *http.Request)
+	HandleResolveDID(w http.ResponseWriter, r *http.Request)
+	HandleUpdateDID(w http.ResponseWriter, r *http.Request)
+	HandleDIDHistory(w http.ResponseWriter, r *http.Request)
+	HandleResolveIdentifier(w http.ResponseWriter, r *http.Request)
+}
+
+// VCService HTTP 层使用的凭证服务
+type VCService interface {
+	HandleIssueCredential(w http.ResponseWriter, r *http.Request)
+	HandleVerifyCredential(w http.ResponseWriter, r *http.Request)
+	HandleRevokeCredential(w http.ResponseWriter, r *http.Request)
+	HandleRenewCredential(w http.ResponseWriter, r *http.Request)
+	HandleRenewalChain(w http.ResponseWriter, r *http.Request)
+	HandleClaimChallenge(w http.ResponseWriter, r *http.Request)
+	HandleClaimCredentials(w http.ResponseWriter, r *http.Request)
+	HandleStatusList(w http.ResponseWriter, r *http.Request)
+	HandleListCredentials(w http.ResponseWriter, r *http.Request)
+	HandleIssuerMetadata(w http.ResponseWriter, r *http.Request)
+	HandleCreateCredentialOffer(w http.ResponseWriter, r *http.Request)
+	HandleCredentialOffer(w http.ResponseWriter, r *http.Request)
+	HandleToken(w http.ResponseWriter, r *http.Request)
+	HandleOIDCCredential(w http.ResponseWriter, r *http.Request)
+	HandleVerifyPresentation(w http.ResponseWriter, r *http.Request)
+}
+
+// GameBackend HTTP 层使用的游戏服务器
+type GameBackend interface {
+	HandleWebSocket(w http.ResponseWriter, r *http.Request)
+	HandleLeaderboard(w http.ResponseWriter, r *http.Request)
+	HandleProfile(w http.ResponseWriter, r *http.Request)
+	HandleGuilds(w http.ResponseWriter, r *http.Request)
+	HandleAchievements(w http.ResponseWriter, r *http.Request)
+	HandleInbox(w http.ResponseWriter, r *http.Request)
+}
+
+// DIDCommRelay HTTP 层使用的 DIDComm 中继
+type DIDCommRelay interface {
+	HandleInbound(w http.ResponseWriter, r *http.Request)
+}
+
+// ConnectionService HTTP 层使用的 DIDComm 邀请和连接服务，处理函数从请求上下文读取已认证的 DID
+type ConnectionService interface {
+	HandleCreateInvitation(w http.ResponseWriter, r *http.Request)
+	HandleConnections(w http.ResponseWriter, r *http.Request)
+	HandleRespondConnection(w http.ResponseWriter, r *http.Request)
+}
+
+// 两种模式都使用这些实现，DID 服务的存储方式由构造函数决定
+var (
+	_ DIDService  = (*did.SimpleService)(nil)
+	_ VCService   = (*vc.SimpleService)(nil)
+	_ GameBackend = (*game.SimpleServer)(nil)
+
+	_ DIDCommRelay      = (*aries.DIDCommService)(nil)
+	_ ConnectionService = (*aries.ConnectionService)(nil)
+)
+
+// Register 注册 DID、VC 和游戏路由。更新 DID、列出、颁发和撤销凭证以及修改玩家资料需要请求方用 DID 认证密钥签名的 JWT 或 HTTP Signature，
+// 由 didAuth 校验；didAuth 应与 RegisterDIDComm 共用，使已用的令牌在所有路由上都不能重放
+func Register(mux *http.ServeMux, didAuth *didauth.Authenticator, dids DIDService, credentials VCService, backend GameBackend) {
+	// DID 管理
+	mux.HandleFunc(pkgdid.WellKnownDIDPath, dids.HandleWebDIDDocument)
+	mux.HandleFunc("/api/did/create", dids.HandleCreateDIDWithAries)
+	mux.HandleFunc("/api/did/register/nonce", dids.HandleRegistrationNonce)
+	mux.HandleFunc("/api/did/register", dids.HandleRegisterDID)
+	mux.HandleFunc("/api/did/resolve", dids.HandleResolveDID)
+	mux.Handle("/api/did/update", didAuth.Require(requireSubject(http.HandlerFunc(dids.HandleUpdateDID))))
+	mux.HandleFunc("/api/did/history", dids.HandleDIDHistory)
+	mux.HandleFunc(did.IdentifiersPath, dids.HandleResolveIdentifier)
+
+	// VC 管理
+	mux.Handle("/api/vc/issue", didAuth.Require(http.HandlerFunc(credentials.HandleIssueCredential)))
+	mux.HandleFunc("/api/vc/verify", credentials.HandleVerifyCredential)
+	mux.Handle("/api/vc/revoke", didAuth.Require(http.HandlerFunc(credentials.HandleRevokeCredential)))
+	mux.Handle("/api/vc/renew", didAuth.Require(http.HandlerFunc(credentials.HandleRenewCredential)))
+	mux.HandleFunc("/api/vc/renewals", credentials.HandleRenewalChain)
+	mux.Handle("/api/vc/claim/challenge", didAuth.Require(http.HandlerFunc(credentials.HandleClaimChallenge)))
+	mux.Handle("/api/vc/claim", didAuth.Require(http.HandlerFunc(credentials.HandleClaimCredentials)))
+	mux.HandleFunc("/api/vc/status/", credentials.HandleStatusList)
+	mux.Handle("/api/vc/list", didAuth.Require(http.HandlerFunc(credentials.HandleListCredentials)))
+
+	// OIDC4VCI 凭证领取
+	mux.HandleFunc(vc.IssuerMetadataPath, credentials.HandleIssuerMetadata)
+	mux.Handle("/api/vc/oidc4vci/offer", didAuth.Require(http.HandlerFunc(credentials.HandleCreateCredentialOffer)))
+	mux.HandleFunc("/api/vc/oidc4vci/offer/", credentials.HandleCredentialOffer)
+	mux.HandleFunc("/api/vc/oidc4vci/token", credentials.HandleToken)
+	mux.HandleFunc("/api/vc/oidc4vci/credential", credentials.HandleOIDCCredential)
+
+	// VP 验证
+	mux.HandleFunc("/api/vp/verify", credentials.HandleVerifyPresentation)
+
+	// 排行榜、玩家资料、公会名册、成就和收件箱
+	mux.HandleFunc(game.LeaderboardPath, backend.HandleLeaderboard)
+	mux.HandleFunc(game.LeaderboardPath+"/rank", backend.HandleLeaderboard)
+	mux.Handle(game.ProfilePath, didAuth.Optional(http.HandlerFunc(backend.HandleProfile)))
+	mux.HandleFunc(game.GuildsPath, backend.HandleGuilds)
+	mux.HandleFunc(game.GuildsPath+"/", backend.HandleGuilds)
+	mux.HandleFunc(game.AchievementsPath, backend.HandleAchievements)
+	mux.HandleFunc(game.AchievementsPath+"/", backend.HandleAchievements)
+	mux.Handle(game.InboxPath+"/", didAuth.Optional(http.HandlerFunc(backend.HandleInbox)))
+
+	// WebSocket 游戏连接
+	mux.HandleFunc("/ws/game", backend.HandleWebSocket)
+}
+
+// RegisterDIDComm 注册 DIDComm 消息入口以及带外邀请和连接路由，邀请和连接操作需要 DID 认证
+func RegisterDIDComm(mux *http.ServeMux, didAuth *didauth.Authenticator, relay DIDCommRelay, connections ConnectionService) {
+	mux.HandleFunc("/didcomm", relay.HandleInbound)
+	mux.Handle("/api/didcomm/invitation", didAuth.Require(http.HandlerFunc(connections.HandleCreateInvitation)))
+	mux.Handle("/api/didcomm/connections", didAuth.Require(http.HandlerFunc(connections.HandleConnections)))
+	mux.Handle("/api/didcomm/connections/respond", didAuth.Require(http.HandlerFunc(connections.HandleRespondConnection)))
+}
+
+// maxSubjectBodySize requireSubject 读取的请求体上限，与 DID 认证签名校验的上限一致
+const maxSubjectBodySize = 1 << 20
+
+// requireSubject 要求 JSON 请求体的 did 字段等于 didAuth.Require 注入的已认证 DID，
+// 读取后还原请求体供 next 解码
+func requireSubject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, ok := didauth.DIDFromContext(r.Context())
+		if !ok {
+			http.Error(w, "DID authentication is required", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSubjectBodySize))
+		if err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		var subject struct {
+			DID string `json:"did"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&subject); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if subject.DID != authenticated {
+			http.Error(w, "request must be authenticated by the DID it modifies", http.StatusForbidden)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/vc"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	pkgvc "github.com/czh0526/game/server/pkg/vc"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []string{ModeSimple, ModeAries} {
+		if got, err := ParseMode(mode); err != nil || got != mode {
+			t.Errorf("ParseMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := ParseMode("aries-v2"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestRequireSubjectBindsBodyToAuthenticatedDID(t *testing.T) {
+	const body = `{"did":"did:player:alice","deactivate":true}`
+	var received string
+	handler := requireSubject(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+	}))
+
+	tests := []struct {
+		name   string
+		caller string
+		body   string
+		want   int
+	}{
+		{"own DID", "did:player:alice", body, http.StatusOK},
+		{"other DID", "did:player:mallory", body, http.StatusForbidden},
+		{"unauthenticated", "", body, http.StatusUnauthorized},
+		{"missing did", "did:player:alice", `{"deactivate":true}`, http.StatusForbidden},
+		{"invalid body", "did:player:alice", `{`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		received = ""
+		r := httptest.NewRequest(http.MethodPost, "/api/did/update", strings.NewReader(tt.body))
+		if tt.caller != "" {
+			r = r.WithContext(didauth.WithDID(r.Context(), tt.caller))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusOK && received != tt.body {
+			t.Errorf("%s: handler read %q, want the original body", tt.name, received)
+		}
+	}
+}
+
+const testPublicURL = "https://game.example.com"
+
+// testPlayer 测试用的 did:key 及其认证密钥
+type testPlayer struct {
+	did        string
+	keyID      string
+	privateKey ed25519.PrivateKey
+}
+
+func newTestPlayer(t *testing.T) testPlayer {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	didKey := pkgdid.NewKeyDID(publicKey)
+	return testPlayer{did: didKey, keyID: didKey + "#" + strings.TrimPrefix(didKey, pkgdid.KeyDIDPrefix), privateKey: privateKey}
+}
+
+// authorize 为请求签发绑定到其方法和地址的 DID 认证 JWT
+func (p testPlayer) authorize(t *testing.T, r *http.Request) {
+	t.Helper()
+	now := time.Now()
+	token, err := pkgvc.SignCompactJWT("JWT", p.keyID, didauth.Claims{
+		Issuer:    p.did,
+		Audience:  testPublicURL,
+		JWTID:     now.Format(time.RFC3339Nano),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		Method:    r.Method,
+		URI:       testPublicURL + r.URL.RequestURI(),
+	}, p.privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+}
+
+// newTestMux 以 simple 模式的服务注册全部路由
+func newTestMux(t *testing.T) *http.ServeMux {
+	t.Helper()
+	didService := did.NewSimpleService()
+	vcService, err := vc.NewSimpleService(didService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gameServer, err := game.NewSimpleServer(didService, vcService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gameServer.Close() })
+
+	authConfig := didauth.DefaultConfig()
+	authConfig.PublicURL = testPublicURL
+	mux := http.NewServeMux()
+	Register(mux, didauth.NewAuthenticator(didService, authConfig), didService, vcService, gameServer)
+	return mux
+}
+
+func serve(mux *http.ServeMux, r *http.Request) int {
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, r)
+	return recorder.Code
+}
+
+func TestProtectedRoutesRequireDIDAuthentication(t *testing.T) {
+	mux := newTestMux(t)
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/api/did/update"},
+		{http.MethodPost, "/api/vc/issue"},
+		{http.MethodPost, "/api/vc/revoke"},
+		{http.MethodPost, "/api/vc/renew"},
+		{http.MethodPost, "/api/vc/claim/challenge"},
+		{http.MethodPost, "/api/vc/claim"},
+		{http.MethodGet, "/api/vc/list"},
+		{http.MethodPost, "/api/vc/oidc4vci/offer"},
+	}
+	for _, route := range routes {
+		if code := serve(mux, httptest.NewRequest(route.method, route.path, strings.NewReader("{}"))); code != http.StatusUnauthorized {
+			t.Errorf("%s %s without authentication: status %d, want %d", route.method, route.path, code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestPublicRoutesDoNotRequireAuthentication(t *testing.T) {
+	mux := newTestMux(t)
+	player := newTestPlayer(t)
+
+	paths := []string{
+		vc.IssuerMetadataPath,
+		"/api/did/resolve?did=" + url.QueryEscape(player.did),
+		game.LeaderboardPath,
+	}
+	for _, path := range paths {
+		if code := serve(mux, httptest.NewRequest(http.MethodGet, path, nil)); code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want %d", path, code, http.StatusOK)
+		}
+	}
+}
+
+func TestAuthenticatedDIDReachesHandlers(t *testing.T) {
+	mux := newTestMux(t)
+	alice, bob := newTestPlayer(t), newTestPlayer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/vc/list", nil)
+	alice.authorize(t, r)
+	if code := serve(mux, r); code != http.StatusOK {
+		t.Errorf("authenticated credential list: status %d", code)
+	}
+
+	// 已认证的 DID 只能更新自己的 DID 文档
+	r = httptest.NewRequest(http.MethodPost, "/api/did/update", strings.NewReader(`{"did":"`+bob.did+`"}`))
+	alice.authorize(t, r)
+	if code := serve(mux, r); code != http.StatusForbidden {
+		t.Errorf("update of another DID: status %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestProfileAuthenticationIsOptional(t *testing.T) {
+	mux := newTestMux(t)
+	player := newTestPlayer(t)
+	path := game.ProfilePath + player.did
+
+	if code := serve(mux, httptest.NewRequest(http.MethodGet, path, nil)); code == http.StatusUnauthorized {
+		t.Error("anonymous profile request rejected")
+	}
+
+	// 带有无效认证的请求仍然被拒绝
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	player.authorize(t, r)
+	r.URL.Path = path + "/other"
+	if code := serve(mux, r); code != http.StatusUnauthorized {
+		t.Errorf("profile request with a token for another URL: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
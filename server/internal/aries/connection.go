@@ -0,0 +1,436 @@
+package aries
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Out-of-band and DID Exchange protocol message types
+const (
+	OOBInvitationType       = "https://didcomm.org/out-of-band/2.0/invitation"
+	DIDExchangeRequestType  = "https://didcomm.org/didexchange/1.1/request"
+	DIDExchangeResponseType = "https://didcomm.org/didexchange/1.1/response"
+	ProblemReportType       = "https://didcomm.org/report-problem/2.0/problem-report"
+)
+
+// Connection states, following the DID Exchange protocol roles
+const (
+	ConnectionRequested = "requested"
+	ConnectionCompleted = "completed"
+	ConnectionAbandoned = "abandoned"
+)
+
+// invitationTTL is how long an unused out-of-band invitation stays valid
+const invitationTTL = 24 * time.Hour
+
+// Connection errors
+var (
+	ErrInvitationNotFound = errors.New("invitation not found or expired")
+	ErrConnectionNotFound = errors.New("connection not found")
+)
+
+// Invitation is an out-of-band 2.0 invitation message
+type Invitation struct {
+	Type string         `json:"type"`
+	ID   string         `json:"id"`
+	From string         `json:"from"`
+	Body InvitationBody `json:"body"`
+}
+
+// InvitationBody is the body of an out-of-band invitation
+type InvitationBody struct {
+	GoalCode string   `json:"goal_code,omitempty"`
+	Goal     string   `json:"goal,omitempty"`
+	Accept   []string `json:"accept"`
+}
+
+// Connection is a pairwise connection between an inviter and an invitee DID
+type Connection struct {
+	ID           string    `json:"id"`
+	InvitationID string    `json:"invitationId"`
+	InviterDID   string    `json:"inviterDid"`
+	InviteeDID   string    `json:"inviteeDid"`
+	Label        string    `json:"label,omitempty"`
+	State        string    `json:"state"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// MessageSender packs a plaintext message from msg.From and delivers it to each DID in msg.To
+type MessageSender interface {
+	SendDIDComm(ctx context.Context, msg *Message) error
+}
+
+// ConnectionService creates out-of-band invitations and tracks DID Exchange
+// connections between players and external agents. The server mediates the
+// handshake: requests and responses are submitted over HTTP by the
+// authenticated parties and relayed to the other side as DIDComm messages.
+type ConnectionService struct {
+	endpoint string
+	sender   MessageSender
+	identify func(ctx context.Context) (string, bool)
+
+	mutex       sync.RWMutex
+	invitations map[string]*invitationRecord
+	connections map[string]*Connection
+}
+
+type invitationRecord struct {
+	invitation *Invitation
+	expiresAt  time.Time
+}
+
+// NewConnectionService creates a connection service. endpoint is the DIDComm
+// endpoint used in invitation URLs; identify returns the authenticated DID of a request.
+func NewConnectionService(endpoint string, sender MessageSender, identify func(ctx context.Context) (string, bool)) *ConnectionService {
+	return &ConnectionService{
+		endpoint:    endpoint,
+		sender:      sender,
+		identify:    identify,
+		invitations: make(map[string]*invitationRecord),
+		connections: make(map[string]*Connection),
+	}
+}
+
+// CreateInvitation creates a single-use out-of-band invitation from inviterDID
+func (s *ConnectionService) CreateInvitation(inviterDID, goalCode, goal string) (*Invitation, string, error) {
+	invitation := &Invitation{
+		Type: OOBInvitationType,
+		ID:   uuid.New().String(),
+		From: inviterDID,
+		Body: InvitationBody{
+			GoalCode: goalCode,
+			Goal:     goal,
+			Accept:   []string{"didcomm/v2"},
+		},
+	}
+
+	data, err := json.Marshal(invitation)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal invitation: %w", err)
+	}
+	invitationURL := s.endpoint + "?_oob=" + base64.RawURLEncoding.EncodeToString(data)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for id, record := range s.invitations {
+		if now.After(record.expiresAt) {
+			delete(s.invitations, id)
+		}
+	}
+	s.invitations[invitation.ID] = &invitationRecord{invitation: invitation, expiresAt: now.Add(invitationTTL)}
+
+	return invitation, invitationURL, nil
+}
+
+// ParseInvitationURL decodes the invitation carried in the _oob parameter of an invitation URL
+func ParseInvitationURL(invitationURL string) (*Invitation, error) {
+	parsed, err := url.Parse(invitationURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse invitation URL: %w", err)
+	}
+	encoded := parsed.Query().Get("_oob")
+	if encoded == "" {
+		return nil, errors.New("invitation URL has no _oob parameter")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode invitation: %w", err)
+	}
+	var invitation Invitation
+	if err := json.Unmarshal(data, &invitation); err != nil {
+		return nil, fmt.Errorf("failed to parse invitation: %w", err)
+	}
+	if invitation.Type != OOBInvitationType {
+		return nil, fmt.Errorf("unsupported invitation type: %s", invitation.Type)
+	}
+	return &invitation, nil
+}
+
+// RequestConnection accepts an invitation on behalf of inviteeDID and sends a
+// DID Exchange request to the inviter. The invitation is consumed.
+func (s *ConnectionService) RequestConnection(ctx context.Context, inviteeDID, invitationID, label string) (*Connection, error) {
+	s.mutex.Lock()
+	record, exists := s.invitations[invitationID]
+	if !exists || time.Now().After(record.expiresAt) {
+		s.mutex.Unlock()
+		return nil, ErrInvitationNotFound
+	}
+	if record.invitation.From == inviteeDID {
+		s.mutex.Unlock()
+		return nil, errors.New("cannot accept your own invitation")
+	}
+	delete(s.invitations, invitationID)
+
+	now := time.Now()
+	connection := &Connection{
+		ID:           uuid.New().String(),
+		InvitationID: invitationID,
+		InviterDID:   record.invitation.From,
+		InviteeDID:   inviteeDID,
+		Label:        label,
+		State:        ConnectionRequested,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.connections[connection.ID] = connection
+	result := *connection
+	s.mutex.Unlock()
+
+	s.notify(ctx, &Message{
+		Type:           DIDExchangeRequestType,
+		From:           inviteeDID,
+		To:             []string{connection.InviterDID},
+		ThreadID:       connection.ID,
+		ParentThreadID: invitationID,
+	}, map[string]interface{}{"label": label, "did": inviteeDID})
+
+	return &result, nil
+}
+
+// RespondConnection lets the inviter accept or reject a requested connection.
+// Accepting completes the connection and sends a DID Exchange response to the invitee;
+// rejecting abandons it and sends a problem report.
+func (s *ConnectionService) RespondConnection(ctx context.Context, inviterDID, connectionID string, accept bool) (*Connection, error) {
+	s.mutex.Lock()
+	connection, exists := s.connections[connectionID]
+	if !exists || connection.InviterDID != inviterDID {
+		s.mutex.Unlock()
+		return nil, ErrConnectionNotFound
+	}
+	if connection.State != ConnectionRequested {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("connection is %s", connection.State)
+	}
+
+	connection.State = ConnectionCompleted
+	if !accept {
+		connection.State = ConnectionAbandoned
+	}
+	connection.UpdatedAt = time.Now()
+	result := *connection
+	s.mutex.Unlock()
+
+	msg := &Message{
+		Type:           DIDExchangeResponseType,
+		From:           inviterDID,
+		To:             []string{result.InviteeDID},
+		ThreadID:       result.ID,
+		ParentThreadID: result.InvitationID,
+	}
+	body := map[string]interface{}{"did": inviterDID}
+	if !accept {
+		msg.Type = ProblemReportType
+		body = map[string]interface{}{"code": "e.p.request_not_accepted", "comment": "connection request rejected"}
+	}
+	s.notify(ctx, msg, body)
+
+	return &result, nil
+}
+
+// Connections returns the connections of a DID, newest first
+func (s *ConnectionService) Connections(didID string) []Connection {
+	s.mutex.RLock()
+	var connections []Connection
+	for _, connection := range s.connections {
+		if connection.InviterDID == didID || connection.InviteeDID == didID {
+			connections = append(connections, *connection)
+		}
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].CreatedAt.After(connections[j].CreatedAt)
+	})
+	return connections
+}
+
+// Connected reports whether two DIDs have a completed connection
+func (s *ConnectionService) Connected(didA, didB string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, connection := range s.connections {
+		if connection.State != ConnectionCompleted {
+			continue
+		}
+		if (connection.InviterDID == didA && connection.InviteeDID == didB) ||
+			(connection.InviterDID == didB && connection.InviteeDID == didA) {
+			return true
+		}
+	}
+	return false
+}
+
+// notify relays a protocol message; the connection state is kept on the server,
+// so a recipient that is unreachable can still pick it up with GET /api/didcomm/connections
+func (s *ConnectionService) notify(ctx context.Context, msg *Message, body interface{}) {
+	if s.sender == nil {
+		return
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		slog.Warn("Failed to marshal connection message", "type", msg.Type, "error", err)
+		return
+	}
+	msg.Body = data
+
+	if err := s.sender.SendDIDComm(ctx, msg); err != nil {
+		slog.Warn("Failed to deliver connection message", "type", msg.Type, "to", msg.To, "error", err)
+	}
+}
+
+// CreateInvitationRequest invitation creation request
+type CreateInvitationRequest struct {
+	GoalCode string `json:"goalCode,omitempty"`
+	Goal     string `json:"goal,omitempty"`
+}
+
+// CreateInvitationResponse invitation creation response
+type CreateInvitationResponse struct {
+	Invitation    *Invitation `json:"invitation"`
+	InvitationURL string      `json:"invitationUrl"`
+}
+
+// ConnectionRequest accepts an invitation by URL or ID
+type ConnectionRequest struct {
+	InvitationURL string `json:"invitationUrl,omitempty"`
+	InvitationID  string `json:"invitationId,omitempty"`
+	Label         string `json:"label,omitempty"`
+}
+
+// RespondConnectionRequest inviter decision on a connection request
+type RespondConnectionRequest struct {
+	ConnectionID string `json:"connectionId"`
+	Accept       bool   `json:"accept"`
+}
+
+// HandleCreateInvitation handles POST /api/didcomm/invitation for the authenticated DID
+func (s *ConnectionService) HandleCreateInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	inviterDID, ok := s.identify(r.Context())
+	if !ok {
+		http.Error(w, "DID authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateInvitationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	invitation, invitationURL, err := s.CreateInvitation(inviterDID, req.GoalCode, req.Goal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CreateInvitationResponse{Invitation: invitation, InvitationURL: invitationURL})
+}
+
+// HandleConnections handles /api/didcomm/connections: GET lists the connections of the
+// authenticated DID, POST accepts an invitation and sends a DID Exchange request
+func (s *ConnectionService) HandleConnections(w http.ResponseWriter, r *http.Request) {
+	didID, ok := s.identify(r.Context())
+	if !ok {
+		http.Error(w, "DID authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"connections": s.Connections(didID)})
+	case http.MethodPost:
+		var req ConnectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		invitationID := req.InvitationID
+		if req.InvitationURL != "" {
+			invitation, err := ParseInvitationURL(req.InvitationURL)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			invitationID = invitation.ID
+		}
+		if invitationID == "" {
+			http.Error(w, "invitationUrl or invitationId is required", http.StatusBadRequest)
+			return
+		}
+
+		connection, err := s.RequestConnection(r.Context(), didID, invitationID, req.Label)
+		switch {
+		case errors.Is(err, ErrInvitationNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(connection)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleRespondConnection handles POST /api/didcomm/connections/respond by the inviter
+func (s *ConnectionService) HandleRespondConnection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	inviterDID, ok := s.identify(r.Context())
+	if !ok {
+		http.Error(w, "DID authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req RespondConnectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	connection, err := s.RespondConnection(r.Context(), inviterDID, req.ConnectionID, req.Accept)
+	switch {
+	case errors.Is(err, ErrConnectionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(connection)
+}
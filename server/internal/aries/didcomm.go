@@ -0,0 +1,434 @@
+package aries
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/kms"
+	"github.com/czh0526/game/server/pkg/multibase"
+)
+
+// DIDComm v2 media types and protocol URIs
+const (
+	MediaTypeEncrypted = "application/didcomm-encrypted+json"
+	MediaTypePlain     = "application/didcomm-plain+json"
+
+	ForwardMessageType = "https://didcomm.org/routing/2.0/forward"
+	ServiceTypeDIDComm = "DIDCommMessaging"
+)
+
+// maxInboundMessageSize limits the size of inbound DIDComm messages
+const maxInboundMessageSize = 1 << 20
+
+// outboundTimeout limits delivery to remote DIDComm endpoints
+const outboundTimeout = 10 * time.Second
+
+// routingSeedKeyID is the KMS key the routing key is derived from
+const routingSeedKeyID = "didcomm-routing"
+
+// routingKeyLabel is signed with the routing seed key to derive the routing key
+const routingKeyLabel = "game-server didcomm routing key v1"
+
+// x25519Multicodec is the multicodec prefix for X25519 public keys (0xec)
+var x25519Multicodec = []byte{0xec, 0x01}
+
+// Message is a DIDComm v2 plaintext message
+type Message struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	From           string          `json:"from,omitempty"`
+	To             []string        `json:"to,omitempty"`
+	ThreadID       string          `json:"thid,omitempty"`
+	ParentThreadID string          `json:"pthid,omitempty"` // invitation that started the protocol
+	CreatedTime    int64           `json:"created_time,omitempty"`
+	Body           json.RawMessage `json:"body"`
+	Attachments    []Attachment    `json:"attachments,omitempty"`
+}
+
+// Attachment is a DIDComm v2 attachment
+type Attachment struct {
+	ID        string         `json:"id,omitempty"`
+	MediaType string         `json:"media_type,omitempty"`
+	Data      AttachmentData `json:"data"`
+}
+
+// AttachmentData holds inline attachment content
+type AttachmentData struct {
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+// forwardBody is the body of a routing/2.0 forward message
+type forwardBody struct {
+	Next string `json:"next"`
+}
+
+// MessageRouter delivers DIDComm messages to locally connected players
+type MessageRouter interface {
+	// DeliverDIDComm delivers a packed message to the player owning recipientDID
+	DeliverDIDComm(recipientDID string, packed json.RawMessage) error
+}
+
+// ProtocolHandler handles a plaintext protocol message addressed to a DID served by this server
+type ProtocolHandler func(ctx context.Context, msg *Message) error
+
+// SenderKeyResolver resolves the key agreement key named by the skid of an authcrypt message.
+// It must only return keys that authenticate the DID the skid belongs to.
+type SenderKeyResolver interface {
+	DIDCommSenderKey(ctx context.Context, skid string) (*ecdh.PublicKey, error)
+}
+
+// ErrUnsupportedMessage is returned when no protocol handler accepts a message
+var ErrUnsupportedMessage = errors.New("unsupported message type")
+
+// ErrSenderNotAuthenticated is returned when a protocol message is not authcrypted by its from DID
+var ErrSenderNotAuthenticated = errors.New("message sender is not authenticated")
+
+// DIDCommService acts as a DIDComm v2 mediator for players connected to this server
+type DIDCommService struct {
+	endpoint   string
+	did        string
+	kid        string
+	privateKey *ecdh.PrivateKey
+	router     MessageRouter
+	senderKeys SenderKeyResolver
+	client     *http.Client
+
+	mutex    sync.RWMutex
+	handlers map[string]ProtocolHandler // keyed by protocol URI, e.g. https://didcomm.org/issue-credential/2.0
+	served   map[string]bool            // DIDs whose messages are handled by local protocol handlers
+}
+
+// NewDIDCommService creates a mediator whose X25519 routing key is published as did:key.
+// A nil privateKey generates a fresh key, which changes the routing key on every start;
+// use RoutingKeyFromKMS to keep it stable. Protocol messages are only accepted from senders
+// resolved through router when it implements SenderKeyResolver.
+func NewDIDCommService(endpoint string, router MessageRouter, privateKey *ecdh.PrivateKey) (*DIDCommService, error) {
+	if privateKey == nil {
+		var err error
+		if privateKey, err = ecdh.X25519().GenerateKey(rand.Reader); err != nil {
+			return nil, fmt.Errorf("failed to generate routing key: %w", err)
+		}
+	}
+
+	fingerprint := multibase.EncodeBase58BTC(append(append([]byte(nil), x25519Multicodec...), privateKey.PublicKey().Bytes()...))
+	did := "did:key:" + fingerprint
+
+	senderKeys, _ := router.(SenderKeyResolver)
+	return &DIDCommService{
+		endpoint:   endpoint,
+		did:        did,
+		kid:        did + "#" + fingerprint,
+		privateKey: privateKey,
+		router:     router,
+		senderKeys: senderKeys,
+		client:     &http.Client{Timeout: outboundTimeout},
+		handlers:   make(map[string]ProtocolHandler),
+		served:     make(map[string]bool),
+	}, nil
+}
+
+// RoutingKeyFromKMS derives the routing key from a dedicated Ed25519 key in keys, creating it on
+// first use. Ed25519 signatures are deterministic, so the same key manager always yields the
+// same routing key and the private seed never leaves it.
+func RoutingKeyFromKMS(keys kms.KeyManager) (*ecdh.PrivateKey, error) {
+	if _, err := kms.EnsureKey(keys, routingSeedKeyID, kms.KeyTypeEd25519); err != nil {
+		return nil, fmt.Errorf("failed to load routing seed key: %w", err)
+	}
+	signature, err := keys.Sign(routingSeedKeyID, []byte(routingKeyLabel), crypto.Hash(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive routing key: %w", err)
+	}
+
+	seed := sha256.Sum256(append([]byte(routingKeyLabel), signature...))
+	return ecdh.X25519().NewPrivateKey(seed[:])
+}
+
+// HandleProtocol registers the handler for messages of a protocol addressed to servedDID
+func (s *DIDCommService) HandleProtocol(protocol, servedDID string, handler ProtocolHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[protocol] = handler
+	s.served[servedDID] = true
+}
+
+// Serves reports whether messages to didID are handled by this server rather than relayed
+func (s *DIDCommService) Serves(didID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.served[didID]
+}
+
+// Dispatch passes a plaintext message to the handler of its protocol. The protocol
+// is the message type without the final message name.
+func (s *DIDCommService) Dispatch(ctx context.Context, msg *Message) error {
+	protocol := msg.Type
+	if i := strings.LastIndexByte(protocol, '/'); i >= 0 {
+		protocol = protocol[:i]
+	}
+
+	s.mutex.RLock()
+	handler, exists := s.handlers[protocol]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMessage, msg.Type)
+	}
+	return handler(ctx, msg)
+}
+
+// Endpoint returns the inbound endpoint URL advertised in DID documents
+func (s *DIDCommService) Endpoint() string {
+	return s.endpoint
+}
+
+// RoutingKey returns the key id senders must wrap forward messages to
+func (s *DIDCommService) RoutingKey() string {
+	return s.kid
+}
+
+// ServiceEndpoint returns the DIDCommMessaging service endpoint entry for DID documents
+func (s *DIDCommService) ServiceEndpoint() map[string]interface{} {
+	return map[string]interface{}{
+		"uri":         s.endpoint,
+		"accept":      []string{"didcomm/v2"},
+		"routingKeys": []string{s.kid},
+	}
+}
+
+// HandleInbound handles POST /didcomm: unwraps forward messages and routes them to players,
+// and dispatches other messages addressed to DIDs served here to their protocol handler
+func (s *DIDCommService) HandleInbound(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxInboundMessageSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var jwe JWE
+	if err := json.Unmarshal(data, &jwe); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid encrypted message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var senderKey SenderKeyFunc
+	if s.senderKeys != nil {
+		senderKey = func(skid string) (*ecdh.PublicKey, error) {
+			return s.senderKeys.DIDCommSenderKey(r.Context(), skid)
+		}
+	}
+	plaintext, skid, err := decryptJWE(&jwe, s.kid, s.privateKey, senderKey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to unpack message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var msg Message
+	if err := json.Unmarshal(plaintext, &msg); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid plaintext message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if msg.Type != ForwardMessageType {
+		if !s.servesAny(msg.To) {
+			http.Error(w, "Message is not addressed to a DID served here", http.StatusNotFound)
+			return
+		}
+		// protocol handlers act on behalf of msg.From, so it must be the authcrypt sender
+		if err := checkSender(&msg, skid); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err := s.Dispatch(r.Context(), &msg); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to handle message: %v", err), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := s.routeForward(&msg); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to route message: %v", err), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// checkSender verifies that msg.From is the DID of the authcrypt sender key skid
+func checkSender(msg *Message, skid string) error {
+	if skid == "" {
+		return fmt.Errorf("%w: protocol messages must be authcrypted", ErrSenderNotAuthenticated)
+	}
+	senderDID, _, _ := strings.Cut(skid, "#")
+	if msg.From == "" || msg.From != senderDID {
+		return fmt.Errorf("%w: from %q does not match sender key %s", ErrSenderNotAuthenticated, msg.From, skid)
+	}
+	return nil
+}
+
+// servesAny reports whether any recipient DID is served by local protocol handlers
+func (s *DIDCommService) servesAny(recipients []string) bool {
+	for _, recipient := range recipients {
+		if s.Serves(recipient) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeForward delivers the attached encrypted message to the player named in body.next
+func (s *DIDCommService) routeForward(msg *Message) error {
+	var body forwardBody
+	if err := json.Unmarshal(msg.Body, &body); err != nil {
+		return fmt.Errorf("failed to parse forward body: %w", err)
+	}
+	if body.Next == "" {
+		return errors.New("forward message has no next recipient")
+	}
+	if len(msg.Attachments) == 0 || len(msg.Attachments[0].Data.JSON) == 0 {
+		return errors.New("forward message has no attachment")
+	}
+
+	// next may be a DID or a DID URL of the recipient key
+	recipientDID := body.Next
+	if i := strings.IndexByte(recipientDID, '#'); i >= 0 {
+		recipientDID = recipientDID[:i]
+	}
+
+	return s.router.DeliverDIDComm(recipientDID, msg.Attachments[0].Data.JSON)
+}
+
+// Pack encrypts a plaintext message to the given recipient keys
+func (s *DIDCommService) Pack(msg *Message, recipients []Recipient) (*JWE, error) {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if msg.CreatedTime == 0 {
+		msg.CreatedTime = time.Now().Unix()
+	}
+
+	plaintext, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return encryptJWE(plaintext, recipients)
+}
+
+// PackAuth authcrypts a plaintext message from sender to the given recipient keys.
+// msg.From must be the DID the sender key belongs to.
+func (s *DIDCommService) PackAuth(msg *Message, sender Sender, recipients []Recipient) (*JWE, error) {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if msg.CreatedTime == 0 {
+		msg.CreatedTime = time.Now().Unix()
+	}
+
+	plaintext, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return encryptAuthJWE(plaintext, sender, recipients)
+}
+
+// Forward wraps a packed message in forward messages for each mediator routing key, innermost first
+func (s *DIDCommService) Forward(next string, packed *JWE, routingKeys []string) (*JWE, error) {
+	for i := len(routingKeys) - 1; i >= 0; i-- {
+		publicKey, err := X25519FromDIDKey(routingKeys[i])
+		if err != nil {
+			return nil, err
+		}
+
+		inner, err := json.Marshal(packed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal packed message: %w", err)
+		}
+		body, err := json.Marshal(forwardBody{Next: next})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal forward body: %w", err)
+		}
+
+		packed, err = s.Pack(&Message{
+			Type:        ForwardMessageType,
+			To:          []string{routingKeys[i]},
+			Body:        body,
+			Attachments: []Attachment{{MediaType: MediaTypeEncrypted, Data: AttachmentData{JSON: inner}}},
+		}, []Recipient{{KID: routingKeys[i], PublicKey: publicKey}})
+		if err != nil {
+			return nil, err
+		}
+		next = routingKeys[i]
+	}
+	return packed, nil
+}
+
+// X25519FromDIDKey extracts the X25519 public key from a did:key URL
+func X25519FromDIDKey(kid string) (*ecdh.PublicKey, error) {
+	fingerprint := strings.TrimPrefix(kid, "did:key:")
+	if i := strings.IndexByte(fingerprint, '#'); i >= 0 {
+		fingerprint = fingerprint[:i]
+	}
+
+	decoded, err := multibase.Decode(fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode did:key %s: %w", kid, err)
+	}
+	if !bytes.HasPrefix(decoded, x25519Multicodec) {
+		return nil, fmt.Errorf("did:key %s is not an X25519 key", kid)
+	}
+	return ecdh.X25519().NewPublicKey(decoded[len(x25519Multicodec):])
+}
+
+// Send delivers a packed message to a remote DIDComm endpoint
+func (s *DIDCommService) Send(endpoint string, packed *JWE) error {
+	return s.SendContext(context.Background(), endpoint, packed)
+}
+
+// SendContext is like Send but abandons the delivery when ctx is cancelled or its deadline passes
+func (s *DIDCommService) SendContext(ctx context.Context, endpoint string, packed *JWE) error {
+	data, err := json.Marshal(packed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal packed message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", MediaTypeEncrypted)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("remote endpoint returned %d", resp.StatusCode)
+	}
+
+	slog.Info("Delivered DIDComm message", "endpoint", endpoint)
+	return nil
+}
@@ -0,0 +1,55 @@
+package aries
+
+import (
+	"testing"
+
+	"github.com/czh0526/game/server/internal/kms"
+)
+
+func TestRoutingKeyFromKMSIsStable(t *testing.T) {
+	keys, err := kms.NewLocalKMS("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := RoutingKeyFromKMS(keys)
+	if err != nil {
+		t.Fatalf("RoutingKeyFromKMS: %v", err)
+	}
+	second, err := RoutingKeyFromKMS(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Equal(second) {
+		t.Error("routing key changed between derivations from the same key manager")
+	}
+
+	other, _ := kms.NewLocalKMS("", "")
+	third, err := RoutingKeyFromKMS(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Equal(third) {
+		t.Error("different key managers derived the same routing key")
+	}
+}
+
+func TestCheckSender(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		skid string
+		ok   bool
+	}{
+		{"authcrypted by from", "did:example:alice", "did:example:alice#key-1", true},
+		{"anoncrypt", "did:example:alice", "", false},
+		{"other sender", "did:example:alice", "did:example:mallory#key-1", false},
+		{"no from", "", "did:example:alice#key-1", false},
+	}
+	for _, tt := range tests {
+		err := checkSender(&Message{From: tt.from}, tt.skid)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package aries
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"filippo.io/edwards25519"
+)
+
+// JWE algorithms used for DIDComm v2 anoncrypt
+const (
+	AlgECDHESA256KW = "ECDH-ES+A256KW"
+	EncA256GCM      = "A256GCM"
+)
+
+// JWE algorithms used for DIDComm v2 authcrypt, which also authenticates the sender key
+const (
+	AlgECDH1PUA256KW = "ECDH-1PU+A256KW"
+	EncA256CBCHS512  = "A256CBC-HS512"
+)
+
+// JWE is a DIDComm v2 encrypted message in general JSON serialization
+type JWE struct {
+	Protected  string         `json:"protected"`
+	Recipients []JWERecipient `json:"recipients"`
+	IV         string         `json:"iv"`
+	Ciphertext string         `json:"ciphertext"`
+	Tag        string         `json:"tag"`
+}
+
+// JWERecipient holds the wrapped content encryption key for one recipient
+type JWERecipient struct {
+	Header       JWERecipientHeader `json:"header"`
+	EncryptedKey string             `json:"encrypted_key"`
+}
+
+// JWERecipientHeader identifies the recipient key
+type JWERecipientHeader struct {
+	KID string `json:"kid"`
+}
+
+// jweProtectedHeader is the shared protected header. Skid and Apu name the sender key of
+// authcrypt messages and are empty for anoncrypt.
+type jweProtectedHeader struct {
+	Typ  string `json:"typ"`
+	Alg  string `json:"alg"`
+	Enc  string `json:"enc"`
+	Skid string `json:"skid,omitempty"`
+	Apu  string `json:"apu,omitempty"`
+	Apv  string `json:"apv"`
+	Epk  JWK    `json:"epk"`
+}
+
+// JWK is an OKP public key in JWK format
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// Recipient is a key agreement key that a message is encrypted to
+type Recipient struct {
+	KID       string
+	PublicKey *ecdh.PublicKey
+}
+
+// Sender is the key agreement key an authcrypt message is encrypted from
+type Sender struct {
+	KID        string
+	PrivateKey *ecdh.PrivateKey
+}
+
+// SenderKeyFunc returns the public key named by the skid of an authcrypt message
+type SenderKeyFunc func(skid string) (*ecdh.PublicKey, error)
+
+var b64 = base64.RawURLEncoding
+
+// encryptJWE encrypts plaintext for all recipients using ECDH-ES+A256KW and A256GCM
+func encryptJWE(plaintext []byte, recipients []Recipient) (*JWE, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	kids := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		kids = append(kids, recipient.KID)
+	}
+	apv := recipientsAPV(kids)
+
+	header := jweProtectedHeader{
+		Typ: MediaTypeEncrypted,
+		Alg: AlgECDHESA256KW,
+		Enc: EncA256GCM,
+		Apv: b64.EncodeToString(apv),
+		Epk: JWK{Kty: "OKP", Crv: "X25519", X: b64.EncodeToString(ephemeral.PublicKey().Bytes())},
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal protected header: %w", err)
+	}
+	protected := b64.EncodeToString(headerJSON)
+
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, fmt.Errorf("failed to generate content key: %w", err)
+	}
+
+	jwe := &JWE{Protected: protected}
+	for _, recipient := range recipients {
+		shared, err := ephemeral.ECDH(recipient.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive shared secret for %s: %w", recipient.KID, err)
+		}
+
+		kek := concatKDF(shared, AlgECDHESA256KW, nil, apv, 256)
+		wrapped, err := aesKeyWrap(kek, cek)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap content key: %w", err)
+		}
+
+		jwe.Recipients = append(jwe.Recipients, JWERecipient{
+			Header:       JWERecipientHeader{KID: recipient.KID},
+			EncryptedKey: b64.EncodeToString(wrapped),
+		})
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate iv: %w", err)
+	}
+
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	tagStart := len(sealed) - gcm.Overhead()
+
+	jwe.IV = b64.EncodeToString(iv)
+	jwe.Ciphertext = b64.EncodeToString(sealed[:tagStart])
+	jwe.Tag = b64.EncodeToString(sealed[tagStart:])
+	return jwe, nil
+}
+
+// encryptAuthJWE encrypts plaintext from sender to all recipients using ECDH-1PU+A256KW and
+// A256CBC-HS512. The content is encrypted first because the key wrap binds the content tag.
+func encryptAuthJWE(plaintext []byte, sender Sender, recipients []Recipient) (*JWE, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	kids := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		kids = append(kids, recipient.KID)
+	}
+	apu := []byte(sender.KID)
+	apv := recipientsAPV(kids)
+
+	header := jweProtectedHeader{
+		Typ:  MediaTypeEncrypted,
+		Alg:  AlgECDH1PUA256KW,
+		Enc:  EncA256CBCHS512,
+		Skid: sender.KID,
+		Apu:  b64.EncodeToString(apu),
+		Apv:  b64.EncodeToString(apv),
+		Epk:  JWK{Kty: "OKP", Crv: "X25519", X: b64.EncodeToString(ephemeral.PublicKey().Bytes())},
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal protected header: %w", err)
+	}
+	protected := b64.EncodeToString(headerJSON)
+
+	cek := make([]byte, 64)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, fmt.Errorf("failed to generate content key: %w", err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate iv: %w", err)
+	}
+	ciphertext, tag, err := cbcHMACSeal(cek, iv, plaintext, []byte(protected))
+	if err != nil {
+		return nil, err
+	}
+
+	jwe := &JWE{
+		Protected:  protected,
+		IV:         b64.EncodeToString(iv),
+		Ciphertext: b64.EncodeToString(ciphertext),
+		Tag:        b64.EncodeToString(tag),
+	}
+	for _, recipient := range recipients {
+		ephemeralShared, err := ephemeral.ECDH(recipient.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive shared secret for %s: %w", recipient.KID, err)
+		}
+		senderShared, err := sender.PrivateKey.ECDH(recipient.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive sender secret for %s: %w", recipient.KID, err)
+		}
+
+		kek := concatKDFTag(append(ephemeralShared, senderShared...), AlgECDH1PUA256KW, apu, apv, 256, tag)
+		wrapped, err := aesKeyWrap(kek, cek)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap content key: %w", err)
+		}
+
+		jwe.Recipients = append(jwe.Recipients, JWERecipient{
+			Header:       JWERecipientHeader{KID: recipient.KID},
+			EncryptedKey: b64.EncodeToString(wrapped),
+		})
+	}
+	return jwe, nil
+}
+
+// decryptJWE decrypts an anoncrypt or authcrypt JWE addressed to the given key. For authcrypt
+// it looks up the sender key with senderKey and returns the authenticated skid; anoncrypt
+// messages return an empty skid. A nil senderKey rejects authcrypt messages.
+func decryptJWE(jwe *JWE, kid string, privateKey *ecdh.PrivateKey, senderKey SenderKeyFunc) (plaintext []byte, skid string, err error) {
+	headerJSON, err := b64.DecodeString(jwe.Protected)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode protected header: %w", err)
+	}
+
+	var header jweProtectedHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, "", fmt.Errorf("failed to parse protected header: %w", err)
+	}
+	switch {
+	case header.Alg == AlgECDHESA256KW && header.Enc == EncA256GCM:
+	case header.Alg == AlgECDH1PUA256KW && header.Enc == EncA256CBCHS512:
+		if header.Skid == "" {
+			return nil, "", errors.New("authcrypt message has no skid")
+		}
+		if senderKey == nil {
+			return nil, "", errors.New("authcrypt messages are not accepted here")
+		}
+	default:
+		return nil, "", fmt.Errorf("unsupported JWE algorithm: %s/%s", header.Alg, header.Enc)
+	}
+	if header.Epk.Crv != "X25519" {
+		return nil, "", fmt.Errorf("unsupported ephemeral key curve: %s", header.Epk.Crv)
+	}
+
+	var recipient *JWERecipient
+	for i := range jwe.Recipients {
+		if jwe.Recipients[i].Header.KID == kid {
+			recipient = &jwe.Recipients[i]
+			break
+		}
+	}
+	if recipient == nil {
+		return nil, "", fmt.Errorf("message is not encrypted to %s", kid)
+	}
+
+	epkBytes, err := b64.DecodeString(header.Epk.X)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode ephemeral key: %w", err)
+	}
+	epk, err := ecdh.X25519().NewPublicKey(epkBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+
+	apv, err := b64.DecodeString(header.Apv)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode apv: %w", err)
+	}
+
+	shared, err := privateKey.ECDH(epk)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to derive shared secret: %w", err)
+	}
+
+	wrapped, err := b64.DecodeString(recipient.EncryptedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode encrypted key: %w", err)
+	}
+	iv, err := b64.DecodeString(jwe.IV)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode iv: %w", err)
+	}
+	ciphertext, err := b64.DecodeString(jwe.Ciphertext)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	tag, err := b64.DecodeString(jwe.Tag)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode tag: %w", err)
+	}
+
+	if header.Alg == AlgECDH1PUA256KW {
+		plaintext, err := decryptAuthContent(&header, shared, privateKey, senderKey, wrapped, iv, ciphertext, tag, []byte(jwe.Protected), apv)
+		if err != nil {
+			return nil, "", err
+		}
+		return plaintext, header.Skid, nil
+	}
+
+	cek, err := aesKeyUnwrap(concatKDF(shared, AlgECDHESA256KW, nil, apv, 256), wrapped)
+	if err != nil {
+		return nil, "", err
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(iv) != gcm.NonceSize() {
+		return nil, "", errors.New("invalid iv length")
+	}
+
+	plaintext, err = gcm.Open(nil, iv, append(ciphertext, tag...), []byte(jwe.Protected))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt message: %w", err)
+	}
+	return plaintext, "", nil
+}
+
+// decryptAuthContent unwraps the content key of an authcrypt message with the ephemeral and
+// sender shared secrets and decrypts the content
+func decryptAuthContent(header *jweProtectedHeader, ephemeralShared []byte, privateKey *ecdh.PrivateKey, senderKey SenderKeyFunc,
+	wrapped, iv, ciphertext, tag, aad, apv []byte) ([]byte, error) {
+	apu, err := b64.DecodeString(header.Apu)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode apu: %w", err)
+	}
+	if string(apu) != header.Skid {
+		return nil, errors.New("apu does not match skid")
+	}
+
+	senderPublic, err := senderKey(header.Skid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sender key %s: %w", header.Skid, err)
+	}
+	senderShared, err := privateKey.ECDH(senderPublic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive sender secret: %w", err)
+	}
+
+	kek := concatKDFTag(append(append([]byte(nil), ephemeralShared...), senderShared...), AlgECDH1PUA256KW, apu, apv, 256, tag)
+	cek, err := aesKeyUnwrap(kek, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return cbcHMACOpen(cek, iv, ciphertext, tag, aad)
+}
+
+// recipientsAPV computes apv as the SHA-256 of the sorted recipient kids joined by "."
+func recipientsAPV(kids []string) []byte {
+	sorted := append([]string(nil), kids...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, ".")))
+	return sum[:]
+}
+
+// concatKDF implements the single-round Concat KDF from RFC 7518 section 4.6.2
+func concatKDF(z []byte, alg string, apu, apv []byte, keyBits int) []byte {
+	return concatKDFTag(z, alg, apu, apv, keyBits, nil)
+}
+
+// concatKDFTag is concatKDF with the content tag appended to SuppPubInfo as ECDH-1PU
+// key wrapping requires; a nil tag gives the plain Concat KDF
+func concatKDFTag(z []byte, alg string, apu, apv []byte, keyBits int, tag []byte) []byte {
+	h := sha256.New()
+
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], 1)
+	h.Write(buf[:])
+	h.Write(z)
+
+	for _, field := range [][]byte{[]byte(alg), apu, apv} {
+		binary.BigEndian.PutUint32(buf[:], uint32(len(field)))
+		h.Write(buf[:])
+		h.Write(field)
+	}
+
+	binary.BigEndian.PutUint32(buf[:], uint32(keyBits))
+	h.Write(buf[:])
+	if tag != nil {
+		binary.BigEndian.PutUint32(buf[:], uint32(len(tag)))
+		h.Write(buf[:])
+		h.Write(tag)
+	}
+
+	return h.Sum(nil)[:keyBits/8]
+}
+
+var keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// aesKeyWrap implements AES Key Wrap from RFC 3394
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, errors.New("key to wrap must be a multiple of 8 bytes")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key wrap cipher: %w", err)
+	}
+
+	n := len(key) / 8
+	a := append([]byte(nil), keyWrapIV...)
+	r := append([]byte(nil), key...)
+	buf := make([]byte, 16)
+
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(buf[:8], a)
+			copy(buf[8:], r[i*8:(i+1)*8])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(r[i*8:(i+1)*8], buf[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+// aesKeyUnwrap implements AES Key Unwrap from RFC 3394
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, errors.New("invalid wrapped key length")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key wrap cipher: %w", err)
+	}
+
+	n := len(wrapped)/8 - 1
+	a := append([]byte(nil), wrapped[:8]...)
+	r := append([]byte(nil), wrapped[8:]...)
+	buf := make([]byte, 16)
+
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[i*8:(i+1)*8])
+			block.Decrypt(buf, buf)
+
+			copy(a, buf[:8])
+			copy(r[i*8:(i+1)*8], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a, keyWrapIV) != 1 {
+		return nil, errors.New("failed to unwrap content key: integrity check failed")
+	}
+	return r, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// cbcHMACSeal encrypts with AES_256_CBC_HMAC_SHA_512 from RFC 7518 section 5.2.5: the first
+// half of the 64-byte key authenticates, the second half encrypts
+func cbcHMACSeal(key, iv, plaintext, aad []byte) (ciphertext, tag []byte, err error) {
+	if len(key) != 64 {
+		return nil, nil, errors.New("A256CBC-HS512 requires a 64-byte key")
+	}
+	block, err := aes.NewCipher(key[32:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create content cipher: %w", err)
+	}
+
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte(nil), plaintext...), make([]byte, padding)...)
+	for i := len(plaintext); i < len(padded); i++ {
+		padded[i] = byte(padding)
+	}
+	ciphertext = make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	return ciphertext, cbcHMACTag(key[:32], iv, ciphertext, aad), nil
+}
+
+// cbcHMACOpen checks the tag and decrypts a cbcHMACSeal ciphertext
+func cbcHMACOpen(key, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	if len(key) != 64 {
+		return nil, errors.New("A256CBC-HS512 requires a 64-byte key")
+	}
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid iv or ciphertext length")
+	}
+	if !hmac.Equal(tag, cbcHMACTag(key[:32], iv, ciphertext, aad)) {
+		return nil, errors.New("failed to decrypt message: authentication tag mismatch")
+	}
+
+	block, err := aes.NewCipher(key[32:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content cipher: %w", err)
+	}
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(padded, ciphertext)
+
+	padding := int(padded[len(padded)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("failed to decrypt message: invalid padding")
+	}
+	return padded[:len(padded)-padding], nil
+}
+
+// cbcHMACTag is the first 32 bytes of HMAC-SHA-512 over AAD || IV || ciphertext || AL
+func cbcHMACTag(macKey, iv, ciphertext, aad []byte) []byte {
+	mac := hmac.New(sha512.New, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	var al [8]byte
+	binary.BigEndian.PutUint64(al[:], uint64(len(aad))*8)
+	mac.Write(al[:])
+	return mac.Sum(nil)[:32]
+}
+
+// Ed25519PublicToX25519 converts an Ed25519 public key to its X25519 (Montgomery) form
+func Ed25519PublicToX25519(publicKey ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	point, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Ed25519 public key: %w", err)
+	}
+	return ecdh.X25519().NewPublicKey(point.BytesMontgomery())
+}
+
+// Ed25519PrivateToX25519 converts an Ed25519 private key to an X25519 private key
+func Ed25519PrivateToX25519(privateKey ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	digest := sha512.Sum512(privateKey.Seed())
+	return ecdh.X25519().NewPrivateKey(digest[:32])
+}
@@ -0,0 +1,157 @@
+package aries
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func newTestRecipient(t *testing.T, kid string) (Recipient, ed25519.PrivateKey) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agreementKey, err := Ed25519PublicToX25519(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Recipient{KID: kid, PublicKey: agreementKey}, privateKey
+}
+
+func TestJWERoundTrip(t *testing.T) {
+	alice, aliceKey := newTestRecipient(t, "did:example:alice#key-x25519-1")
+	bob, bobKey := newTestRecipient(t, "did:example:bob#key-x25519-1")
+	_, mallory := newTestRecipient(t, "did:example:mallory#key-x25519-1")
+	plaintext := []byte(`{"type":"https://didcomm.org/basicmessage/2.0/message"}`)
+
+	jwe, err := encryptJWE(plaintext, []Recipient{alice, bob})
+	if err != nil {
+		t.Fatalf("encryptJWE: %v", err)
+	}
+	for kid, privateKey := range map[string]ed25519.PrivateKey{alice.KID: aliceKey, bob.KID: bobKey} {
+		agreementKey, err := Ed25519PrivateToX25519(privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, _, err := decryptJWE(jwe, kid, agreementKey, nil)
+		if err != nil {
+			t.Fatalf("decryptJWE for %s: %v", kid, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("decrypted %s for %s", decrypted, kid)
+		}
+	}
+
+	// Another key cannot unwrap the content key, even under a listed kid
+	malloryKey, _ := Ed25519PrivateToX25519(mallory)
+	if _, _, err := decryptJWE(jwe, alice.KID, malloryKey, nil); err == nil {
+		t.Error("decrypted with a key the message was not encrypted to")
+	}
+}
+
+func TestJWERejectsTampering(t *testing.T) {
+	recipient, privateKey := newTestRecipient(t, "did:example:alice#key-x25519-1")
+	agreementKey, _ := Ed25519PrivateToX25519(privateKey)
+
+	tests := map[string]func(*JWE){
+		"ciphertext": func(j *JWE) { j.Ciphertext = flipFirst(j.Ciphertext) },
+		"tag":        func(j *JWE) { j.Tag = flipFirst(j.Tag) },
+		"protected":  func(j *JWE) { j.Protected = flipFirst(j.Protected) },
+		"recipient":  func(j *JWE) { j.Recipients[0].Header.KID = "did:example:bob#key-x25519-1" },
+	}
+	for name, tamper := range tests {
+		jwe, err := encryptJWE([]byte("hello"), []Recipient{recipient})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tamper(jwe)
+		if _, _, err := decryptJWE(jwe, recipient.KID, agreementKey, nil); err == nil {
+			t.Errorf("%s: tampered message decrypted", name)
+		}
+	}
+}
+
+func TestAuthJWERoundTrip(t *testing.T) {
+	recipient, recipientKey := newTestRecipient(t, "did:example:bob#key-1")
+	agreementKey, _ := Ed25519PrivateToX25519(recipientKey)
+	senderRecipient, senderEd := newTestRecipient(t, "did:example:alice#key-1")
+	senderKey, _ := Ed25519PrivateToX25519(senderEd)
+	sender := Sender{KID: senderRecipient.KID, PrivateKey: senderKey}
+	_, malloryEd := newTestRecipient(t, "did:example:mallory#key-1")
+	malloryKey, _ := Ed25519PrivateToX25519(malloryEd)
+
+	resolve := func(skid string) (*ecdh.PublicKey, error) {
+		if skid != sender.KID {
+			return nil, errors.New("unknown sender")
+		}
+		return senderRecipient.PublicKey, nil
+	}
+
+	jwe, err := encryptAuthJWE([]byte("hello"), sender, []Recipient{recipient})
+	if err != nil {
+		t.Fatalf("encryptAuthJWE: %v", err)
+	}
+	decrypted, skid, err := decryptJWE(jwe, recipient.KID, agreementKey, resolve)
+	if err != nil {
+		t.Fatalf("decryptJWE: %v", err)
+	}
+	if string(decrypted) != "hello" || skid != sender.KID {
+		t.Errorf("decrypted %q from %q", decrypted, skid)
+	}
+
+	if _, _, err := decryptJWE(jwe, recipient.KID, agreementKey, nil); err == nil {
+		t.Error("authcrypt message accepted without a sender key resolver")
+	}
+
+	// A sender claiming alice's skid without her private key cannot produce a valid message
+	forged, err := encryptAuthJWE([]byte("hello"), Sender{KID: sender.KID, PrivateKey: malloryKey}, []Recipient{recipient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := decryptJWE(forged, recipient.KID, agreementKey, resolve); err == nil {
+		t.Error("decrypted a message forged under another sender's skid")
+	}
+
+	tampered, _ := encryptAuthJWE([]byte("hello"), sender, []Recipient{recipient})
+	tampered.Ciphertext = flipFirst(tampered.Ciphertext)
+	if _, _, err := decryptJWE(tampered, recipient.KID, agreementKey, resolve); err == nil {
+		t.Error("tampered authcrypt message decrypted")
+	}
+}
+
+// flipFirst changes the first base64url character, which always changes the decoded bytes
+func flipFirst(value string) string {
+	replacement := "A"
+	if value[0] == 'A' {
+		replacement = "B"
+	}
+	return replacement + value[1:]
+}
+
+func TestAESKeyWrapRFC3394(t *testing.T) {
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	want := "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5"
+
+	wrapped, err := aesKeyWrap(kek, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(wrapped) != want {
+		t.Fatalf("wrapped %x, want %s", wrapped, want)
+	}
+	unwrapped, err := aesKeyUnwrap(kek, wrapped)
+	if err != nil || !bytes.Equal(unwrapped, key) {
+		t.Fatalf("unwrapped %x, %v", unwrapped, err)
+	}
+
+	wrapped[0] ^= 1
+	if _, err := aesKeyUnwrap(kek, wrapped); err == nil {
+		t.Error("unwrapped a corrupted key")
+	}
+}
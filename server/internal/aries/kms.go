@@ -0,0 +1,205 @@
+package aries
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/kms"
+)
+
+// kmsStoreName is the store holding the local KMS key records
+const kmsStoreName = "kms"
+
+// keyRecord is a private key persisted in the KMS store
+type keyRecord struct {
+	Type  kms.KeyType `json:"type"`
+	Nonce []byte      `json:"nonce,omitempty"`
+	// Key is the PKCS#8 private key, sealed with the master key when one is configured
+	Key []byte `json:"key"`
+}
+
+// LocalKMS is a key manager that persists keys in the Aries storage provider, so
+// keys created through AriesService survive restarts and are shared by all nodes
+// using the same MySQL database. It implements kms.KeyManager.
+type LocalKMS struct {
+	store storage.Store
+	// lock seals key records; nil stores them unsealed, like the Aries noop secret lock
+	lock  cipher.AEAD
+	mutex sync.RWMutex
+	keys  map[string]crypto.Signer
+}
+
+// NewLocalKMS opens the KMS store. masterKey must be 32 bytes or empty.
+func NewLocalKMS(provider storage.Provider, masterKey []byte) (*LocalKMS, error) {
+	store, err := provider.OpenStore(kmsStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open KMS store: %w", err)
+	}
+
+	k := &LocalKMS{store: store, keys: make(map[string]crypto.Signer)}
+	if len(masterKey) > 0 {
+		block, err := aes.NewCipher(masterKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create master key lock: %w", err)
+		}
+		if k.lock, err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("failed to create master key lock: %w", err)
+		}
+	}
+	return k, nil
+}
+
+// CreateKey generates a key and stores it under keyID
+func (k *LocalKMS) CreateKey(keyID string, keyType kms.KeyType) (crypto.PublicKey, error) {
+	if keyID == "" {
+		return nil, errors.New("key ID is required")
+	}
+
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case kms.KeyTypeEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case kms.KeyTypeECDSAP256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	plaintext, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if _, err := k.store.Get(keyID); err == nil {
+		return nil, kms.ErrKeyExists
+	} else if !errors.Is(err, storage.ErrDataNotFound) {
+		return nil, fmt.Errorf("failed to read key %s: %w", keyID, err)
+	}
+
+	record, err := k.seal(keyID, keyType, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key record: %w", err)
+	}
+	if err := k.store.Put(keyID, data); err != nil {
+		return nil, fmt.Errorf("failed to store key %s: %w", keyID, err)
+	}
+	k.keys[keyID] = key
+
+	return key.Public(), nil
+}
+
+// PublicKey returns the public key of keyID
+func (k *LocalKMS) PublicKey(keyID string) (crypto.PublicKey, error) {
+	key, err := k.load(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return key.Public(), nil
+}
+
+// Sign signs with keyID following the crypto.Signer conventions
+func (k *LocalKMS) Sign(keyID string, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	key, err := k.load(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return key.Sign(rand.Reader, digest, opts)
+}
+
+// load returns the private key of keyID, reading it from storage on first use
+func (k *LocalKMS) load(keyID string) (crypto.Signer, error) {
+	k.mutex.RLock()
+	key, cached := k.keys[keyID]
+	k.mutex.RUnlock()
+	if cached {
+		return key, nil
+	}
+
+	data, err := k.store.Get(keyID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, kms.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", keyID, err)
+	}
+
+	var record keyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse key record %s: %w", keyID, err)
+	}
+	plaintext, err := k.open(keyID, &record)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %w", keyID, err)
+	}
+	key, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not a signing key", keyID)
+	}
+
+	k.mutex.Lock()
+	k.keys[keyID] = key
+	k.mutex.Unlock()
+	return key, nil
+}
+
+// seal encrypts the private key with the master key, bound to keyID
+func (k *LocalKMS) seal(keyID string, keyType kms.KeyType, plaintext []byte) (*keyRecord, error) {
+	if k.lock == nil {
+		return &keyRecord{Type: keyType, Key: plaintext}, nil
+	}
+
+	nonce := make([]byte, k.lock.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return &keyRecord{
+		Type:  keyType,
+		Nonce: nonce,
+		Key:   k.lock.Seal(nil, nonce, plaintext, []byte(keyID)),
+	}, nil
+}
+
+// open decrypts a key record sealed by seal
+func (k *LocalKMS) open(keyID string, record *keyRecord) ([]byte, error) {
+	switch {
+	case k.lock == nil && record.Nonce == nil:
+		return record.Key, nil
+	case k.lock == nil:
+		return nil, fmt.Errorf("key %s is sealed but no master key is configured", keyID)
+	case len(record.Nonce) != k.lock.NonceSize():
+		return nil, fmt.Errorf("key %s is not sealed with the master key", keyID)
+	}
+
+	plaintext, err := k.lock.Open(nil, record.Nonce, record.Key, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal key %s: wrong master key or corrupted record", keyID)
+	}
+	return plaintext, nil
+}
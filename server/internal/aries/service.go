@@ -2,23 +2,35 @@ package aries
 
 import (
 	"crypto/ed25519"
-	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 
-	"github.com/hyperledger/aries-framework-go/component/storage/mysql"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	gamestorage "github.com/czh0526/game/server/internal/storage"
 )
 
-// AriesService wraps simplified Aries functionality for DID operations
+// AriesService wraps simplified Aries functionality for DID operations. It owns
+// a local KMS, a VDR registry and a credential wallet, all persisted through the
+// same storage provider.
 type AriesService struct {
-	storageProvider *mysql.Provider
+	storageProvider storage.Provider
+	kms             *LocalKMS
+	vdr             *VDRRegistry
+	wallet          *Wallet
 }
 
 // Config Aries configuration
 type Config struct {
 	MySQLDSN string
 	Label    string
+	// StorageNamespace prefixes all storage table names (default "aries")
+	StorageNamespace string
+	// Provider, when set, is used instead of opening MySQLDSN
+	Provider storage.Provider
+	// MasterKey seals KMS keys at rest (32 bytes); keys are stored unsealed when empty
+	MasterKey []byte
 }
 
 // Doc simplified DID document structure
@@ -51,31 +63,47 @@ type VerificationRelationship struct {
 
 // NewAriesService creates a new Aries service
 func NewAriesService(config *Config) (*AriesService, error) {
-	// Create MySQL storage provider using aries-framework-go-ext
-	// DSN format: user:password@tcp(host:port)/?interpolateParams=true&multiStatements=true
-	dsn := config.MySQLDSN
-	storageProvider, err := mysql.NewProvider(dsn)
+	storageProvider := config.Provider
+	if storageProvider == nil {
+		// Create MySQL storage provider; the schema is migrated on startup
+		// DSN format: user:password@tcp(host:port)/database?parseTime=true
+		var err error
+		storageProvider, err = gamestorage.Open(gamestorage.BackendMySQL+":"+config.MySQLDSN, gamestorage.Options{
+			Namespace: config.StorageNamespace,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage provider: %w", err)
+		}
+	}
+
+	keys, err := NewLocalKMS(storageProvider, config.MasterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	vdr := NewVDRRegistry(NewPlayerVDR(storageProvider), KeyVDR{})
+
+	wallet, err := NewWallet(storageProvider, keys, vdr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create storage provider: %w", err)
+		return nil, err
 	}
 
 	return &AriesService{
 		storageProvider: storageProvider,
+		kms:             keys,
+		vdr:             vdr,
+		wallet:          wallet,
 	}, nil
 }
 
-// CreatePlayerDID creates a new player DID using Aries framework
-func (s *AriesService) CreatePlayerDID(gameID, playerID string) (*CreatePlayerDIDResponse, error) {
-	// Generate Ed25519 key pair
-	publicKeyBytes, privateKeyBytes, err := ed25519.GenerateKey(rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate key pair: %w", err)
+// RegisterPlayerDID stores the DID document of a player whose Ed25519 key pair was
+// generated by the client. publicKey is the hex-encoded public key.
+func (s *AriesService) RegisterPlayerDID(gameID, playerID, publicKey string) (*CreatePlayerDIDResponse, error) {
+	publicKeyBytes, err := hex.DecodeString(publicKey)
+	if err != nil || len(publicKeyBytes) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key")
 	}
 
-	// Encode keys as hex
-	publicKey := hex.EncodeToString(publicKeyBytes)
-	privateKey := hex.EncodeToString(privateKeyBytes)
-
 	// Create DID string: did:player:{gameID}:{playerID}
 	didStr := fmt.Sprintf("did:player:%s:%s", gameID, playerID)
 
@@ -126,13 +154,17 @@ func (s *AriesService) CreatePlayerDID(gameID, playerID string) (*CreatePlayerDI
 		DID:       didStr,
 		DIDDoc:    doc,
 		PublicKey:  publicKey,
-		PrivateKey: privateKey,
 	}, nil
 }
 
 // ResolveDID resolves a DID from storage
 func (s *AriesService) ResolveDID(didStr string) (*Doc, error) {
-	store, err := s.storageProvider.OpenStore("did_store")
+	return readDoc(s.storageProvider, didStr)
+}
+
+// readDoc loads a DID document stored by RegisterPlayerDID
+func readDoc(provider storage.Provider, didStr string) (*Doc, error) {
+	store, err := provider.OpenStore("did_store")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open DID store: %w", err)
 	}
@@ -155,7 +187,26 @@ type CreatePlayerDIDResponse struct {
 	DID       string
 	DIDDoc    *Doc
 	PublicKey string
-	PrivateKey string
+}
+
+// KMS returns the key manager holding keys created through the Aries service
+func (s *AriesService) KMS() *LocalKMS {
+	return s.kms
+}
+
+// VDR returns the VDR registry; additional DID methods can be registered on it
+func (s *AriesService) VDR() *VDRRegistry {
+	return s.vdr
+}
+
+// Wallet returns the credential wallet
+func (s *AriesService) Wallet() *Wallet {
+	return s.wallet
+}
+
+// StorageProvider returns the underlying storage provider so other services can share it
+func (s *AriesService) StorageProvider() storage.Provider {
+	return s.storageProvider
 }
 
 // Close closes the Aries service
@@ -0,0 +1,128 @@
+package aries
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+)
+
+// VDR errors
+var (
+	ErrDIDNotFound        = errors.New("DID not found")
+	ErrMethodNotSupported = errors.New("DID method not supported")
+)
+
+// VDR resolves the DIDs of one or more DID methods
+type VDR interface {
+	// Accept reports whether the VDR handles the DID method
+	Accept(method string) bool
+	// Read resolves a DID, returning ErrDIDNotFound when it does not exist
+	Read(didID string) (*pkgdid.DIDDocument, error)
+}
+
+// VDRRegistry dispatches resolution to the first registered VDR accepting the DID method
+type VDRRegistry struct {
+	mutex sync.RWMutex
+	vdrs  []VDR
+}
+
+// NewVDRRegistry creates a registry with the given VDRs
+func NewVDRRegistry(vdrs ...VDR) *VDRRegistry {
+	return &VDRRegistry{vdrs: vdrs}
+}
+
+// Register adds a VDR, consulted after the ones already registered
+func (r *VDRRegistry) Register(vdr VDR) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.vdrs = append(r.vdrs, vdr)
+}
+
+// Resolve resolves a DID with the VDR registered for its method
+func (r *VDRRegistry) Resolve(didID string) (*pkgdid.DIDDocument, error) {
+	parts := strings.SplitN(didID, ":", 3)
+	if len(parts) != 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid DID: %s", didID)
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, vdr := range r.vdrs {
+		if vdr.Accept(parts[1]) {
+			return vdr.Read(didID)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrMethodNotSupported, parts[1])
+}
+
+// PlayerVDR resolves did:player documents stored by RegisterPlayerDID
+type PlayerVDR struct {
+	provider storage.Provider
+}
+
+// NewPlayerVDR creates a did:player VDR backed by the DID store
+func NewPlayerVDR(provider storage.Provider) *PlayerVDR {
+	return &PlayerVDR{provider: provider}
+}
+
+// Accept reports whether method is "player"
+func (v *PlayerVDR) Accept(method string) bool {
+	return method == "player"
+}
+
+// Read loads a player DID document from the DID store
+func (v *PlayerVDR) Read(didID string) (*pkgdid.DIDDocument, error) {
+	if !pkgdid.IsValidPlayerDID(didID) {
+		return nil, fmt.Errorf("invalid did:player: %s", didID)
+	}
+
+	doc, err := readDoc(v.provider, didID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrDIDNotFound, didID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Stored documents carry the hex-encoded key in publicKeyJwk.x
+	document := &pkgdid.DIDDocument{
+		Context: doc.Context,
+		ID:      doc.ID,
+		Service: []pkgdid.Service{},
+	}
+	for _, method := range doc.VerificationMethod {
+		if method.PublicKey == nil {
+			continue
+		}
+		document.VerificationMethod = append(document.VerificationMethod, pkgdid.VerificationMethod{
+			ID:         method.ID,
+			Type:       method.Type,
+			Controller: method.Controller,
+			PublicKey:  method.PublicKey.X,
+		})
+		document.Authentication = append(document.Authentication, method.ID)
+	}
+	for _, relationship := range doc.AssertionMethod {
+		document.AssertionMethod = append(document.AssertionMethod, relationship.VerificationMethod.ID)
+	}
+	return document, nil
+}
+
+// KeyVDR resolves did:key identifiers by expanding the key they embed
+type KeyVDR struct{}
+
+// Accept reports whether method is "key"
+func (KeyVDR) Accept(method string) bool {
+	return method == "key"
+}
+
+// Read expands the did:key identifier into a DID document
+func (KeyVDR) Read(didID string) (*pkgdid.DIDDocument, error) {
+	return pkgdid.KeyDIDDocument(didID)
+}
@@ -0,0 +1,175 @@
+package aries
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/kms"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// walletStoreName is the store holding wallet credentials
+const walletStoreName = "wallet"
+
+// holderTag tags wallet credentials with a hash of the holder DID, since tag
+// values cannot contain the colons of a DID
+const holderTag = "holder"
+
+// ErrCredentialNotFound is returned when the wallet has no credential with the given ID
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// Wallet stores verifiable credentials for holders and signs credentials and
+// presentations with keys held by the KMS. Proofs are verified against DID
+// documents resolved through the VDR registry.
+type Wallet struct {
+	store storage.Store
+	keys  kms.KeyManager
+	vdr   *VDRRegistry
+}
+
+// NewWallet opens the wallet store
+func NewWallet(provider storage.Provider, keys kms.KeyManager, vdr *VDRRegistry) (*Wallet, error) {
+	store, err := provider.OpenStore(walletStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wallet store: %w", err)
+	}
+	return &Wallet{store: store, keys: keys, vdr: vdr}, nil
+}
+
+// Add stores a credential for holderDID, replacing any credential with the same ID
+func (w *Wallet) Add(holderDID string, credential *vc.SimpleCredential) error {
+	if credential == nil || credential.ID == "" {
+		return errors.New("credential ID is required")
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential: %w", err)
+	}
+	if err := w.store.Put(credential.ID, data, storage.Tag{Name: holderTag, Value: holderHash(holderDID)}); err != nil {
+		return fmt.Errorf("failed to store credential: %w", err)
+	}
+	return nil
+}
+
+// Get returns the credential with the given ID
+func (w *Wallet) Get(credentialID string) (*vc.SimpleCredential, error) {
+	data, err := w.store.Get(credentialID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrCredentialNotFound, credentialID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return vc.CredentialFromJSON(data)
+}
+
+// Query returns all credentials stored for holderDID
+func (w *Wallet) Query(holderDID string) ([]*vc.SimpleCredential, error) {
+	iter, err := w.store.Query(holderTag + ":" + holderHash(holderDID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query credentials: %w", err)
+	}
+	defer iter.Close()
+
+	var credentials []*vc.SimpleCredential
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate credentials: %w", err)
+		}
+		if !more {
+			return credentials, nil
+		}
+
+		value, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credential: %w", err)
+		}
+		credential, err := vc.CredentialFromJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+}
+
+// Remove deletes a credential from the wallet
+func (w *Wallet) Remove(credentialID string) error {
+	if err := w.store.Delete(credentialID); err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return nil
+}
+
+// Issue signs a credential with an eddsa-jcs-2022 Data Integrity proof using the KMS key keyID
+func (w *Wallet) Issue(credential *vc.SimpleCredential, keyID, verificationMethod string) error {
+	signer, err := kms.Signer(w.keys, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to load signing key: %w", err)
+	}
+	return vc.SignCredential(credential, signer, verificationMethod, vc.ProofTypeDataIntegrity)
+}
+
+// Prove creates a presentation of the holder's stored credentials, signed with the KMS key keyID
+func (w *Wallet) Prove(holderDID, keyID, verificationMethod, challenge, domain string, credentialIDs ...string) (*vc.SimplePresentation, error) {
+	credentials := make([]*vc.SimpleCredential, 0, len(credentialIDs))
+	for _, id := range credentialIDs {
+		credential, err := w.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	presentation, err := vc.CreatePresentation(holderDID, credentials...)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := kms.Signer(w.keys, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing key: %w", err)
+	}
+	if err := vc.SignPresentation(presentation, signer, verificationMethod, vc.ProofTypeDataIntegrity, challenge, domain); err != nil {
+		return nil, err
+	}
+	return presentation, nil
+}
+
+// Verify checks the credential proof against the issuer key resolved through the VDR registry
+func (w *Wallet) Verify(credential *vc.SimpleCredential) error {
+	if credential == nil || credential.Proof == nil {
+		return errors.New("credential has no proof")
+	}
+
+	document, err := w.vdr.Resolve(credential.Issuer)
+	if err != nil {
+		return fmt.Errorf("failed to resolve issuer: %w", err)
+	}
+	for _, method := range document.VerificationMethod {
+		if method.ID != credential.Proof.VerificationMethod {
+			continue
+		}
+		publicKey, err := method.CryptoPublicKey()
+		if err != nil {
+			return err
+		}
+		edKey, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("verification method %s is not an Ed25519 key", method.ID)
+		}
+		return vc.VerifyProof(credential, edKey)
+	}
+	return fmt.Errorf("verification method %s not found in %s", credential.Proof.VerificationMethod, credential.Issuer)
+}
+
+func holderHash(holderDID string) string {
+	sum := sha256.Sum256([]byte(holderDID))
+	return hex.EncodeToString(sum[:])
+}
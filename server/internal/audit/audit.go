@@ -0,0 +1,226 @@
+// Package audit 记录 DID 和凭证操作的只追加审计日志：谁在什么时间创建、解析、更新了 DID，
+// 谁颁发、验证、撤销了凭证，以及操作结果。记录写入存储提供者，只追加不修改
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// storeName 审计日志的存储名称
+const storeName = "identity_audit"
+
+// 记录的标签，按类别查询
+const (
+	recordTypeTag   = "type"
+	recordTypeEntry = "audit"
+	categoryTag     = "category"
+)
+
+// 操作类别
+const (
+	CategoryDID = "did"
+	CategoryVC  = "vc"
+)
+
+// 操作
+const (
+	OpCreate     = "create"
+	OpResolve    = "resolve"
+	OpUpdate     = "update"
+	OpDeactivate = "deactivate"
+	OpIssue      = "issue"
+	OpVerify     = "verify"
+	OpRevoke     = "revoke"
+	OpErase      = "erase" // 应玩家请求删除或匿名化其个人数据
+	OpClaim      = "claim" // 将凭证转给持有者的新 DID
+)
+
+// 操作结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// CallerServer 服务器自身发起的操作（如游戏颁发成就凭证、轮换颁发者密钥）的调用方
+const CallerServer = "server"
+
+// Entry 一条审计记录
+type Entry struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Category  string    `json:"category"`
+	Operation string    `json:"operation"`
+	// Subject 操作对象：DID 或凭证 ID
+	Subject string `json:"subject"`
+	// Caller 已认证的 DID、admin:{操作者}、remote:{客户端地址} 或 server
+	Caller  string `json:"caller"`
+	Outcome string `json:"outcome"`
+	// Message 失败原因
+	Message string `json:"message,omitempty"`
+}
+
+// Filter 审计记录查询条件，空字段不过滤
+type Filter struct {
+	Category  string
+	Operation string
+	Subject   string
+	Caller    string
+	Outcome   string
+	Since     time.Time
+}
+
+// matches 记录是否满足查询条件
+func (f Filter) matches(entry *Entry) bool {
+	return (f.Category == "" || entry.Category == f.Category) &&
+		(f.Operation == "" || entry.Operation == f.Operation) &&
+		(f.Subject == "" || entry.Subject == f.Subject) &&
+		(f.Caller == "" || entry.Caller == f.Caller) &&
+		(f.Outcome == "" || entry.Outcome == f.Outcome) &&
+		(f.Since.IsZero() || !entry.Timestamp.Before(f.Since))
+}
+
+// Log 审计日志。nil 日志不记录任何内容，未启用审计的服务可以直接调用 Record
+type Log struct {
+	store storage.Store
+}
+
+// NewLog 创建审计日志
+func NewLog(provider storage.Provider) (*Log, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open audit store: %w", err)
+	}
+	return &Log{store: store}, nil
+}
+
+// Record 记录一次操作，调用方取自 ctx，err 不为空时记为失败。
+// 写入失败只记录日志，不影响已执行的操作
+func (l *Log) Record(ctx context.Context, category, operation, subject string, err error) {
+	if l == nil {
+		return
+	}
+
+	entry := Entry{
+		ID:        uuid.New().String(),
+		Timestamp: time.Now(),
+		Category:  category,
+		Operation: operation,
+		Subject:   subject,
+		Caller:    CallerFromContext(ctx),
+		Outcome:   OutcomeSuccess,
+	}
+	if err != nil {
+		entry.Outcome = OutcomeFailure
+		entry.Message = err.Error()
+	}
+
+	if err := l.append(entry); err != nil {
+		logging.FromContext(ctx).Error("Failed to record audit entry", "category", category, "operation", operation, "subject", subject, logging.Err(err))
+	}
+}
+
+func (l *Log) append(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+
+	err = l.store.Put(entryKey(entry), data,
+		storage.Tag{Name: recordTypeTag, Value: recordTypeEntry},
+		storage.Tag{Name: categoryTag, Value: entry.Category},
+	)
+	if err != nil {
+		return fmt.Errorf("save audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query 返回满足条件的审计记录，从新到旧，limit 不大于 0 时返回全部
+func (l *Log) Query(filter Filter, limit int) ([]Entry, error) {
+	expression := fmt.Sprintf("%s:%s", recordTypeTag, recordTypeEntry)
+	if filter.Category != "" {
+		expression = fmt.Sprintf("%s:%s", categoryTag, filter.Category)
+	}
+
+	iter, err := l.store.Query(expression)
+	if err != nil {
+		return nil, fmt.Errorf("query audit entries: %w", err)
+	}
+	defer iter.Close()
+
+	var entries []Entry
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate audit entries: %w", err)
+		}
+		if !more {
+			break
+		}
+
+		value, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read audit entry: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return nil, fmt.Errorf("unmarshal audit entry: %w", err)
+		}
+		if filter.matches(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Timestamp.After(entries[j].Timestamp) })
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// entryKey 以时间戳开头，记录 ID 保证键唯一，已有记录不会被覆盖
+func entryKey(entry Entry) string {
+	return fmt.Sprintf("audit:%020d:%s", entry.Timestamp.UnixNano(), entry.ID)
+}
+
+type callerKey struct{}
+
+// WithCaller 返回携带调用方标识的上下文
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext 返回上下文中的调用方，没有时为服务器自身
+func CallerFromContext(ctx context.Context) string {
+	if caller, ok := ctx.Value(callerKey{}).(string); ok && caller != "" {
+		return caller
+	}
+	return CallerServer
+}
+
+// Middleware 以客户端地址作为 HTTP 请求的调用方，DID 认证和管理接口认证成功后会替换为认证得到的身份
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		next.ServeHTTP(w, r.WithContext(WithCaller(r.Context(), "remote:"+host)))
+	})
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/czh0526/game/server/internal/storage"
+)
+
+func newTestLog(t *testing.T) *Log {
+	t.Helper()
+	log, err := NewLog(storage.NewMemoryProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	log := newTestLog(t)
+	player := WithCaller(context.Background(), "did:example:alice")
+
+	log.Record(player, CategoryDID, OpCreate, "did:example:alice", nil)
+	log.Record(context.Background(), CategoryVC, OpIssue, "urn:uuid:1", nil)
+	log.Record(player, CategoryVC, OpVerify, "urn:uuid:1", errors.New("signature mismatch"))
+
+	all, err := log.Query(Filter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("got %d entries, want 3", len(all))
+	}
+	// 从新到旧
+	if all[0].Operation != OpVerify || all[2].Operation != OpCreate {
+		t.Errorf("entries not newest first: %s, %s, %s", all[0].Operation, all[1].Operation, all[2].Operation)
+	}
+
+	failed := all[0]
+	if failed.Outcome != OutcomeFailure || failed.Message != "signature mismatch" || failed.Caller != "did:example:alice" {
+		t.Errorf("failed entry %+v", failed)
+	}
+	if issued := all[1]; issued.Outcome != OutcomeSuccess || issued.Message != "" || issued.Caller != CallerServer {
+		t.Errorf("server entry %+v", issued)
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	log := newTestLog(t)
+	alice := WithCaller(context.Background(), "did:example:alice")
+	bob := WithCaller(context.Background(), "did:example:bob")
+
+	log.Record(alice, CategoryDID, OpResolve, "did:example:bob", nil)
+	log.Record(bob, CategoryDID, OpUpdate, "did:example:bob", errors.New("unauthorized"))
+	log.Record(bob, CategoryVC, OpRevoke, "urn:uuid:2", nil)
+	since := time.Now()
+	log.Record(alice, CategoryVC, OpIssue, "urn:uuid:3", nil)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		limit  int
+		want   int
+	}{
+		{"category", Filter{Category: CategoryDID}, 0, 2},
+		{"operation", Filter{Operation: OpRevoke}, 0, 1},
+		{"subject", Filter{Subject: "did:example:bob"}, 0, 2},
+		{"caller", Filter{Caller: "did:example:bob"}, 0, 2},
+		{"outcome", Filter{Outcome: OutcomeFailure}, 0, 1},
+		{"since", Filter{Since: since}, 0, 1},
+		{"combined", Filter{Category: CategoryVC, Caller: "did:example:alice"}, 0, 1},
+		{"limit", Filter{}, 2, 2},
+		{"no match", Filter{Category: CategoryDID, Operation: OpIssue}, 0, 0},
+	}
+	for _, tt := range tests {
+		entries, err := log.Query(tt.filter, tt.limit)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("%s: got %d entries, want %d", tt.name, len(entries), tt.want)
+		}
+	}
+}
+
+func TestNilLogRecordsNothing(t *testing.T) {
+	var log *Log
+	// 未启用审计时 Record 不做任何事
+	log.Record(context.Background(), CategoryDID, OpCreate, "did:example:alice", nil)
+
+	if _, err := NewLog(nil); err == nil {
+		t.Error("NewLog accepted a nil provider")
+	}
+}
+
+func TestMiddlewareRecordsRemoteCaller(t *testing.T) {
+	var caller string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller = CallerFromContext(r.Context())
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/api/did", nil)
+	request.RemoteAddr = "203.0.113.7:51234"
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if caller != "remote:203.0.113.7" {
+		t.Errorf("caller = %q", caller)
+	}
+
+	if got := CallerFromContext(WithCaller(context.Background(), "")); got != CallerServer {
+		t.Errorf("empty caller = %q, want %q", got, CallerServer)
+	}
+}
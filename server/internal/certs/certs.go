@@ -0,0 +1,93 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Reloader 从证书和私钥文件加载 TLS 证书，Reload 后新的握手使用新证书，已建立的连接不受影响
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	cert  *tls.Certificate
+	mutex sync.RWMutex
+}
+
+// NewReloader 加载证书和私钥文件
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both certificate and key files are required")
+	}
+
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新读取证书和私钥文件，失败时继续使用原证书
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("parse certificate: %w", err)
+		}
+	}
+
+	r.mutex.Lock()
+	r.cert = &cert
+	r.mutex.Unlock()
+	return nil
+}
+
+// NotAfter 返回当前证书的过期时间
+func (r *Reloader) NotAfter() time.Time {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert.Leaf.NotAfter
+}
+
+// GetCertificate 供 tls.Config 在握手时获取当前证书
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig 返回使用当前证书的 TLS 配置，最低版本为 TLS 1.2
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// RedirectHandler 将 HTTP 请求永久重定向到 HTTPS。httpsAddr 为 HTTPS 监听地址，
+// 端口不是 443 时保留在重定向地址中
+func RedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
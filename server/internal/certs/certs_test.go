@@ -0,0 +1,165 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate 生成 localhost 的自签名证书，写入 dir 中的 cert.pem 和 key.pem
+func writeCertificate(t *testing.T, dir string, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// servedNotAfter 经 TLS 握手返回服务器当前提供的证书的过期时间
+func servedNotAfter(t *testing.T, url string) time.Time {
+	t.Helper()
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("negotiated TLS version %x", resp.TLS.Version)
+	}
+	return resp.TLS.PeerCertificates[0].NotAfter
+}
+
+func TestReloadServesNewCertificate(t *testing.T) {
+	dir := t.TempDir()
+	firstExpiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	certFile, keyFile := writeCertificate(t, dir, firstExpiry)
+
+	reloader, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloader.NotAfter().Equal(firstExpiry) {
+		t.Errorf("NotAfter = %v, want %v", reloader.NotAfter(), firstExpiry)
+	}
+
+	// httptest 的 StartTLS 会加入自己的证书，这里直接以 reloader 的配置提供 TLS
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})}
+	go server.Serve(listener)
+	defer server.Close()
+	url := "https://" + listener.Addr().String()
+
+	if got := servedNotAfter(t, url); !got.Equal(firstExpiry) {
+		t.Fatalf("served certificate expires %v, want %v", got, firstExpiry)
+	}
+
+	// 证书轮换后新的握手使用新证书
+	secondExpiry := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	writeCertificate(t, dir, secondExpiry)
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := servedNotAfter(t, url); !got.Equal(secondExpiry) {
+		t.Errorf("served certificate expires %v after reload, want %v", got, secondExpiry)
+	}
+}
+
+func TestFailedReloadKeepsCurrentCertificate(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	certFile, keyFile := writeCertificate(t, dir, expiry)
+
+	reloader, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("reloaded a corrupt certificate")
+	}
+	if !reloader.NotAfter().Equal(expiry) {
+		t.Errorf("NotAfter = %v after failed reload, want %v", reloader.NotAfter(), expiry)
+	}
+	if cert, err := reloader.GetCertificate(nil); err != nil || cert == nil {
+		t.Errorf("GetCertificate after failed reload = %v, %v", cert, err)
+	}
+}
+
+func TestNewReloaderRequiresFiles(t *testing.T) {
+	if _, err := NewReloader("", "key.pem"); err == nil {
+		t.Error("missing certificate file accepted")
+	}
+	if _, err := NewReloader(filepath.Join(t.TempDir(), "cert.pem"), filepath.Join(t.TempDir(), "key.pem")); err == nil {
+		t.Error("nonexistent files accepted")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsAddr string
+		host      string
+		target    string
+		want      string
+	}{
+		{":443", "game.example.com", "/rooms?id=1", "https://game.example.com/rooms?id=1"},
+		{":443", "game.example.com:80", "/", "https://game.example.com/"},
+		{":8443", "game.example.com:8080", "/ws", "https://game.example.com:8443/ws"},
+		{"0.0.0.0:8443", "[::1]:8080", "/", "https://[::1]:8443/"},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		request.Host = tt.host
+		recorder := httptest.NewRecorder()
+		RedirectHandler(tt.httpsAddr).ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusMovedPermanently || recorder.Header().Get("Location") != tt.want {
+			t.Errorf("%s%s via %s: %d %s, want %s", tt.host, tt.target, tt.httpsAddr, recorder.Code, recorder.Header().Get("Location"), tt.want)
+		}
+	}
+}
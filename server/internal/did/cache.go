@@ -0,0 +1,146 @@
+package did
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheConfig DID 解析结果缓存配置
+type CacheConfig struct {
+	Size        int           // 最多缓存的 DID 数，超出时淘汰最久未使用的条目，0 关闭缓存
+	TTL         time.Duration // 解析成功结果的缓存时间
+	NegativeTTL time.Duration // notFound、invalidDid 等确定性失败结果的缓存时间，0 不缓存失败结果
+}
+
+// DefaultCacheConfig 返回默认缓存配置
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		Size:        10000,
+		TTL:         5 * time.Minute,
+		NegativeTTL: 30 * time.Second,
+	}
+}
+
+// cacheEntry 缓存的解析结果，err 不为空时为失败结果
+type cacheEntry struct {
+	didID     string
+	document  interface{}
+	metadata  *DocumentMetadata
+	err       error
+	expiresAt time.Time
+}
+
+// resolutionCache 带 TTL 的 LRU 解析结果缓存
+type resolutionCache struct {
+	config  CacheConfig
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // 最近使用的条目在前
+
+	// generation 每次失效时递增，解析期间发生过失效的结果不再写入缓存，避免缓存旧文档
+	generation uint64
+}
+
+func newResolutionCache(config CacheConfig) *resolutionCache {
+	return &resolutionCache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get 返回未过期的缓存条目，以及未命中时写回结果需要传给 put 的 generation
+func (c *resolutionCache) get(didID string) (*cacheEntry, uint64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[didID]
+	if !exists {
+		didCacheLookupsTotal.With("miss").Inc()
+		return nil, c.generation, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element, "expired")
+		didCacheLookupsTotal.With("miss").Inc()
+		return nil, c.generation, false
+	}
+
+	c.order.MoveToFront(element)
+	if entry.err != nil {
+		didCacheLookupsTotal.With("negative_hit").Inc()
+	} else {
+		didCacheLookupsTotal.With("hit").Inc()
+	}
+	return entry, c.generation, true
+}
+
+// put 缓存解析结果。只缓存成功结果和确定性的失败结果，获取外部文档失败等内部错误不缓存
+func (c *resolutionCache) put(didID string, generation uint64, document interface{}, metadata *DocumentMetadata, err error) {
+	ttl := c.config.TTL
+	if err != nil {
+		switch ResolutionErrorCode(err) {
+		case ResolutionNotFound, ResolutionInvalidDID, ResolutionMethodNotSupported:
+			ttl = c.config.NegativeTTL
+		default:
+			return
+		}
+	}
+	if c.config.Size <= 0 || ttl <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if element, exists := c.entries[didID]; exists {
+		c.remove(element, "replaced")
+	}
+	c.entries[didID] = c.order.PushFront(&cacheEntry{
+		didID:     didID,
+		document:  document,
+		metadata:  metadata,
+		err:       err,
+		expiresAt: time.Now().Add(ttl),
+	})
+	didCacheEntries.Inc()
+
+	for c.order.Len() > c.config.Size {
+		c.remove(c.order.Back(), "capacity")
+	}
+}
+
+// invalidate 删除 DID 的缓存条目，DID 注册或文档变化时调用
+func (c *resolutionCache) invalidate(didID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	if element, exists := c.entries[didID]; exists {
+		c.remove(element, "invalidated")
+	}
+}
+
+// purge 清空缓存，影响所有文档的配置变化时调用
+func (c *resolutionCache) purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back(), "invalidated")
+	}
+}
+
+// remove 删除条目并记录淘汰原因，调用方需持有 c.mutex
+func (c *resolutionCache) remove(element *list.Element, reason string) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.didID)
+	didCacheEntries.Dec()
+	didCacheEvictionsTotal.With(reason).Inc()
+}
@@ -0,0 +1,42 @@
+package did
+
+import (
+	"context"
+	"time"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// EraseDID 应玩家请求删除本服务保存的 DID 数据：DID 被停用，公钥、游戏和玩家 ID 以及历史版本被删除，
+// 只保留停用后的文档，避免同一标识符被重新注册。DID 不由本服务保存（如 did:key）时返回 false
+func (s *SimpleService) EraseDID(ctx context.Context, didID string) (erased bool, err error) {
+	defer func() {
+		if erased {
+			s.auditLog.Record(ctx, audit.CategoryDID, audit.OpErase, didID, err)
+		}
+	}()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, exists := s.dids[didID]
+	if !exists {
+		return false, nil
+	}
+
+	now := time.Now()
+	tombstone := &did.SimpleDID{
+		ID:          current.ID,
+		CreatedAt:   current.CreatedAt,
+		Version:     current.Version + 1,
+		UpdatedAt:   &now,
+		Deactivated: true,
+	}
+	s.dids[didID] = tombstone
+	delete(s.history, didID)
+	s.recordHistory(tombstone, OperationDeactivate)
+	s.cache.invalidate(didID)
+
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package did
+
+import "github.com/czh0526/game/server/internal/metrics"
+
+// DID 操作和解析指标，通过 /metrics 暴露
+var (
+	didOperationsTotal  = metrics.NewCounterVec("did_operations_total", "DID documents created or updated by operation.", "operation")
+	didResolutionsTotal = metrics.NewCounterVec("did_resolutions_total", "DID resolutions by method and result.", "method", "result")
+
+	didCacheLookupsTotal   = metrics.NewCounterVec("did_cache_lookups_total", "DID resolution cache lookups by result (hit, negative_hit or miss).", "result")
+	didCacheEvictionsTotal = metrics.NewCounterVec("did_cache_evictions_total", "DID resolution cache entries removed by reason.", "reason")
+	didCacheEntries        = metrics.NewGauge("did_cache_entries", "Number of cached DID resolution results.")
+)
+
+// observeResolution 记录一次 DID 解析，result 为 ok 或解析错误码；未知方法统一记为 other，避免标签无限增长
+func observeResolution(didID, result string) {
+	method := didMethod(didID)
+	switch method {
+	case "player", "key", "web":
+	default:
+		method = "other"
+	}
+	didResolutionsTotal.With(method, result).Inc()
+}
@@ -0,0 +1,124 @@
+package did
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// registrationNonceTTL 注册 nonce 的有效期
+const registrationNonceTTL = 5 * time.Minute
+
+// 注册 nonce 错误
+var (
+	ErrRegistrationNonce = errors.New("registration nonce is missing, expired or already used")
+	ErrKeyPossession     = errors.New("signature does not prove possession of the private key")
+)
+
+// registrationNonce 颁发给某个待注册 DID 的一次性 nonce
+type registrationNonce struct {
+	value     string
+	expiresAt time.Time
+}
+
+// RegistrationNonceRequest 申请注册 nonce 的请求
+type RegistrationNonceRequest struct {
+	DID string `json:"did"`
+}
+
+// RegistrationNonceResponse 注册 nonce 响应，客户端用私钥签名 nonce 后随注册请求提交
+type RegistrationNonceResponse struct {
+	DID       string `json:"did"`
+	Nonce     string `json:"nonce"`
+	ExpiresIn int    `json:"expiresIn"`
+}
+
+// HandleRegistrationNonce 处理注册 nonce 申请，注册流程的第一步
+func (s *SimpleService) HandleRegistrationNonce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RegistrationNonceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.DID == "" {
+		http.Error(w, "did is required", http.StatusBadRequest)
+		return
+	}
+	if !did.IsValidPlayerDID(req.DID) {
+		http.Error(w, "invalid DID format", http.StatusBadRequest)
+		return
+	}
+
+	nonce, err := s.IssueRegistrationNonce(req.DID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	response := RegistrationNonceResponse{
+		DID:       req.DID,
+		Nonce:     nonce,
+		ExpiresIn: int(registrationNonceTTL.Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// IssueRegistrationNonce 为尚未注册的 DID 生成一次性 nonce，覆盖之前颁发的 nonce
+func (s *SimpleService) IssueRegistrationNonce(didID string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.dids[didID]; exists {
+		return "", fmt.Errorf("DID already exists: %s", didID)
+	}
+
+	// 顺带清理过期的 nonce，避免未完成的注册堆积
+	now := time.Now()
+	for id, nonce := range s.registrationNonces {
+		if now.After(nonce.expiresAt) {
+			delete(s.registrationNonces, id)
+		}
+	}
+
+	nonce := uuid.New().String()
+	s.registrationNonces[didID] = &registrationNonce{
+		value:     nonce,
+		expiresAt: now.Add(registrationNonceTTL),
+	}
+	return nonce, nil
+}
+
+// verifyRegistrationProof 消耗 DID 的注册 nonce，并校验 signature 是 publicKey 对应私钥对 nonce 的签名。
+// nonce 无论校验是否通过都只能使用一次。调用方需持有写锁
+func (s *SimpleService) verifyRegistrationProof(didID, publicKeyHex, nonce, signatureHex string) error {
+	issued, exists := s.registrationNonces[didID]
+	delete(s.registrationNonces, didID)
+	if !exists || nonce == "" || issued.value != nonce || time.Now().After(issued.expiresAt) {
+		return ErrRegistrationNonce
+	}
+
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("publicKey must be a hex-encoded Ed25519 public key")
+	}
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil || !ed25519.Verify(publicKey, []byte(nonce), signature) {
+		return ErrKeyPossession
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/tracing"
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// IdentifiersPath DID Resolution HTTP 绑定的路径前缀
+const IdentifiersPath = "/1.0/identifiers/"
+
+// 解析结果的媒体类型
+const (
+	MediaTypeDIDResolution = `application/ld+json;profile="https://w3id.org/did-resolution"`
+	MediaTypeDIDLDJSON     = "application/did+ld+json"
+	MediaTypeDIDJSON       = "application/did+json"
+)
+
+// DID 解析错误码
+const (
+	ResolutionInvalidDID         = "invalidDid"
+	ResolutionNotFound           = "notFound"
+	ResolutionMethodNotSupported = "methodNotSupported"
+	ResolutionInvalidOptions     = "invalidOptions"
+	ResolutionInternalError      = "internalError"
+)
+
+// webDIDFetchTimeout 获取外部 did:web 文档的超时时间
+const webDIDFetchTimeout = 5 * time.Second
+
+// maxWebDIDDocumentSize 外部 did:web 文档的大小上限
+const maxWebDIDDocumentSize = 1 << 20
+
+// ResolutionResult DID 解析结果
+type ResolutionResult struct {
+	Context               string              `json:"@context"`
+	DIDDocument           interface{}         `json:"didDocument"`
+	DIDResolutionMetadata *ResolutionMetadata `json:"didResolutionMetadata"`
+	DIDDocumentMetadata   *DocumentMetadata   `json:"didDocumentMetadata"`
+}
+
+// ResolutionMetadata 解析过程元数据
+type ResolutionMetadata struct {
+	ContentType string    `json:"contentType,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	Retrieved   time.Time `json:"retrieved"`
+	Duration    int64     `json:"duration"` // 毫秒
+}
+
+// DocumentMetadata DID 文档元数据
+type DocumentMetadata struct {
+	Created     *time.Time `json:"created,omitempty"`
+	Updated     *time.Time `json:"updated,omitempty"`
+	VersionID   string     `json:"versionId,omitempty"`
+	Deactivated bool       `json:"deactivated,omitempty"`
+
+	// 解析历史版本时指向下一个版本
+	NextUpdate    *time.Time `json:"nextUpdate,omitempty"`
+	NextVersionID string     `json:"nextVersionId,omitempty"`
+}
+
+// resolutionError 带解析错误码的错误
+type resolutionError struct {
+	code string
+	err  error
+}
+
+func (e *resolutionError) Error() string {
+	return e.err.Error()
+}
+
+func newResolutionError(code string, format string, args ...interface{}) error {
+	return &resolutionError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// Resolve 按 DID 方法解析标识符：did:player 和本机托管的 did:web 查本地登记表，did:key 直接展开，外部 did:web 通过 HTTPS 获取
+func (s *SimpleService) Resolve(didID string) (*ResolutionResult, error) {
+	return s.ResolveContext(context.Background(), didID)
+}
+
+// ResolveContext 同 Resolve，在 ctx 的追踪中记录解析跨度，ctx 取消或到期时中止解析
+func (s *SimpleService) ResolveContext(ctx context.Context, didID string) (*ResolutionResult, error) {
+	return s.ResolveWithOptions(ctx, didID, ResolveOptions{})
+}
+
+// ResolveWithOptions 按解析选项解析 DID，指定 versionId 或 versionTime 时从历史版本中解析，不经过缓存
+func (s *SimpleService) ResolveWithOptions(ctx context.Context, didID string, options ResolveOptions) (*ResolutionResult, error) {
+	ctx, span := tracing.Start(ctx, "did.resolve", tracing.String("did.method", didMethod(didID)))
+	defer span.End()
+
+	started := time.Now()
+
+	var (
+		document interface{}
+		metadata *DocumentMetadata
+		err      error
+	)
+	switch {
+	case ctx.Err() != nil:
+		// 请求已取消或超过截止时间时不再解析
+		err = ctx.Err()
+	case options.versioned():
+		document, metadata, err = s.resolveVersion(didID, options)
+	default:
+		document, metadata, err = s.resolveCached(ctx, didID)
+	}
+
+	result := &ResolutionResult{
+		Context:     "https://w3id.org/did-resolution/v1",
+		DIDDocument: document,
+		DIDResolutionMetadata: &ResolutionMetadata{
+			Method:    didMethod(didID),
+			Retrieved: started,
+			Duration:  time.Since(started).Milliseconds(),
+		},
+		DIDDocumentMetadata: metadata,
+	}
+	if result.DIDDocumentMetadata == nil {
+		result.DIDDocumentMetadata = &DocumentMetadata{}
+	}
+
+	if err != nil {
+		code := ResolutionErrorCode(err)
+		result.DIDResolutionMetadata.Error = code
+		result.DIDResolutionMetadata.Message = err.Error()
+		observeResolution(didID, code)
+		span.RecordError(err)
+		return result, err
+	}
+
+	result.DIDResolutionMetadata.ContentType = MediaTypeDIDLDJSON
+	observeResolution(didID, "ok")
+	return result, nil
+}
+
+// resolveCached 先查解析结果缓存，未命中时按方法解析并缓存结果
+func (s *SimpleService) resolveCached(ctx context.Context, didID string) (interface{}, *DocumentMetadata, error) {
+	cache := s.resolutionCache()
+	entry, generation, ok := cache.get(didID)
+	if ok {
+		return entry.document, entry.metadata, entry.err
+	}
+
+	document, metadata, err := s.resolveByMethod(ctx, didID)
+	cache.put(didID, generation, document, metadata, err)
+	return document, metadata, err
+}
+
+// resolveByMethod 按 DID 方法分派解析
+func (s *SimpleService) resolveByMethod(ctx context.Context, didID string) (interface{}, *DocumentMetadata, error) {
+	if !strings.HasPrefix(didID, "did:") || didMethod(didID) == "" {
+		return nil, nil, newResolutionError(ResolutionInvalidDID, "invalid DID: %s", didID)
+	}
+
+	switch didMethod(didID) {
+	case "player":
+		if !did.IsValidPlayerDID(didID) {
+			return nil, nil, newResolutionError(ResolutionInvalidDID, "invalid did:player: %s", didID)
+		}
+		return s.resolveLocal(didID)
+	case "key":
+		document, err := did.KeyDIDDocument(didID)
+		if err != nil {
+			return nil, nil, newResolutionError(ResolutionInvalidDID, "%v", err)
+		}
+		return document, &DocumentMetadata{}, nil
+	case "web":
+		s.mutex.RLock()
+		_, hosted := s.dids[didID]
+		s.mutex.RUnlock()
+
+		if hosted {
+			return s.resolveLocal(didID)
+		}
+		document, err := fetchWebDIDDocument(ctx, didID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return document, &DocumentMetadata{}, nil
+	default:
+		return nil, nil, newResolutionError(ResolutionMethodNotSupported, "DID method not supported: %s", didMethod(didID))
+	}
+}
+
+// resolveLocal 解析本地登记的 DID，并根据历史记录填写文档元数据
+func (s *SimpleService) resolveLocal(didID string) (interface{}, *DocumentMetadata, error) {
+	s.mutex.RLock()
+	playerDID, exists := s.dids[didID]
+	history := s.history[didID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return nil, nil, newResolutionError(ResolutionNotFound, "DID not found: %s", didID)
+	}
+
+	metadata := &DocumentMetadata{VersionID: strconv.Itoa(playerDID.Version), Deactivated: playerDID.Deactivated}
+	if len(history) > 0 {
+		created := history[0].Timestamp
+		metadata.Created = &created
+	}
+	if len(history) > 1 {
+		updated := history[len(history)-1].Timestamp
+		metadata.Updated = &updated
+	}
+
+	return s.document(playerDID), metadata, nil
+}
+
+// fetchWebDIDDocument 通过 HTTPS 获取外部 did:web 文档
+func fetchWebDIDDocument(ctx context.Context, didID string) (json.RawMessage, error) {
+	documentURL, err := did.WebDIDDocumentURL(didID)
+	if err != nil {
+		return nil, newResolutionError(ResolutionInvalidDID, "%v", err)
+	}
+
+	ctx, span := tracing.StartKind(ctx, "did.web.fetch", tracing.SpanKindClient, tracing.String("http.url", documentURL))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", documentURL, err)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	client := &http.Client{Timeout: webDIDFetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("fetch %s: %w", documentURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, newResolutionError(ResolutionNotFound, "DID document not found at %s", documentURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: unexpected status %d", documentURL, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxWebDIDDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", documentURL, err)
+	}
+
+	var document struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("parse DID document: %w", err)
+	}
+	if document.ID != didID {
+		return nil, fmt.Errorf("DID document id %q does not match %s", document.ID, didID)
+	}
+
+	return json.RawMessage(data), nil
+}
+
+// ResolutionErrorCode 返回解析错误码，没有错误码的错误视为内部错误
+func ResolutionErrorCode(err error) string {
+	var resErr *resolutionError
+	if errors.As(err, &resErr) {
+		return resErr.code
+	}
+	return ResolutionInternalError
+}
+
+// resolutionStatus 返回解析错误码对应的 HTTP 状态码
+func resolutionStatus(code string) int {
+	switch code {
+	case ResolutionInvalidDID, ResolutionInvalidOptions:
+		return http.StatusBadRequest
+	case ResolutionNotFound:
+		return http.StatusNotFound
+	case ResolutionMethodNotSupported:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// didMethod 返回 DID 的方法名
+func didMethod(didID string) string {
+	parts := strings.SplitN(didID, ":", 3)
+	if len(parts) < 3 || parts[0] != "did" {
+		return ""
+	}
+	return parts[1]
+}
+
+// HandleResolveIdentifier 处理 GET /1.0/identifiers/{did}，遵循 DID Resolution HTTP 绑定
+//
+// Accept 为 application/did+ld+json 或 application/did+json 时只返回 DID 文档，否则返回完整的解析结果。
+// 查询参数 versionId 或 versionTime（RFC 3339）解析历史版本。
+func (s *SimpleService) HandleResolveIdentifier(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 使用转义路径，保留 did:web 中编码的端口（%3A）
+	didID := strings.TrimPrefix(r.URL.EscapedPath(), IdentifiersPath)
+	if didID == "" {
+		http.Error(w, "DID is required", http.StatusBadRequest)
+		return
+	}
+
+	options, err := parseResolveOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.ResolveWithOptions(r.Context(), didID, options)
+	s.auditLog.Record(r.Context(), audit.CategoryDID, audit.OpResolve, didID, err)
+
+	status := http.StatusOK
+	switch {
+	case err != nil:
+		status = resolutionStatus(result.DIDResolutionMetadata.Error)
+	case result.DIDDocumentMetadata.Deactivated:
+		status = http.StatusGone
+	}
+
+	accept := r.Header.Get("Accept")
+	if err == nil && (strings.Contains(accept, MediaTypeDIDLDJSON) || strings.Contains(accept, MediaTypeDIDJSON)) {
+		contentType := MediaTypeDIDLDJSON
+		if strings.Contains(accept, MediaTypeDIDJSON) {
+			contentType = MediaTypeDIDJSON
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result.DIDDocument)
+		return
+	}
+
+	w.Header().Set("Content-Type", MediaTypeDIDResolution)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
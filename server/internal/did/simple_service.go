@@ -1,6 +1,10 @@
 package did
 
 import (
+	"context"
+	"errors"
+	"crypto/ed25519"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -8,15 +12,32 @@ import (
 	"time"
 
 	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/internal/audit"
 	"github.com/czh0526/game/server/pkg/did"
 )
 
 // SimpleService 简化的DID服务
 type SimpleService struct {
 	dids      map[string]*did.SimpleDID
+	history   map[string][]*DIDHistoryEntry
 	mutex     sync.RWMutex
 	ariesSvc  *aries.AriesService
 	useAries  bool
+
+	// DIDComm 服务端点，设置后在解析的 DID 文档中发布 DIDCommMessaging 服务
+	didcommEndpoint map[string]interface{}
+
+	// 本服务器托管的 did:web 标识符，通过 /.well-known/did.json 发布
+	webDID string
+
+	// 待注册 DID 的一次性 nonce，注册时用于证明持有私钥
+	registrationNonces map[string]*registrationNonce
+
+	// 解析结果缓存，DID 注册、更新时失效
+	cache *resolutionCache
+
+	// 审计日志，为空时不记录
+	auditLog *audit.Log
 }
 
 // RegisterDIDRequest 注册DID请求（客户端已生成密钥对）
@@ -28,6 +49,10 @@ type RegisterDIDRequest struct {
 	PlayerID   string           `json:"playerId"`
 	Nickname   string           `json:"nickname,omitempty"`
 	Level      int              `json:"level,omitempty"`
+	// Nonce 通过 /api/did/register/nonce 获取的注册 nonce
+	Nonce     string `json:"nonce"`
+	// Signature 私钥对 nonce 的 Ed25519 签名（hex），证明客户端持有私钥
+	Signature string `json:"signature"`
 }
 
 // RegisterDIDResponse 注册DID响应
@@ -48,7 +73,10 @@ type ResolveDIDResponse struct {
 func NewSimpleService() *SimpleService {
 	return &SimpleService{
 		dids:     make(map[string]*did.SimpleDID),
+		history:  make(map[string][]*DIDHistoryEntry),
 		useAries: false,
+		registrationNonces: make(map[string]*registrationNonce),
+		cache:              newResolutionCache(DefaultCacheConfig()),
 	}
 }
 
@@ -56,12 +84,16 @@ func NewSimpleService() *SimpleService {
 func NewSimpleServiceWithAries(ariesSvc *aries.AriesService) *SimpleService {
 	return &SimpleService{
 		dids:     make(map[string]*did.SimpleDID),
+		history:  make(map[string][]*DIDHistoryEntry),
 		ariesSvc: ariesSvc,
 		useAries: true,
+		registrationNonces: make(map[string]*registrationNonce),
+		cache:              newResolutionCache(DefaultCacheConfig()),
 	}
 }
 
-// HandleRegisterDID 处理注册DID请求（客户端已生成密钥对）
+// HandleRegisterDID 处理注册DID请求（客户端已生成密钥对），
+// 请求需携带注册 nonce 及其签名以证明持有私钥
 func (s *SimpleService) HandleRegisterDID(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -87,6 +119,10 @@ func (s *SimpleService) HandleRegisterDID(w http.ResponseWriter, r *http.Request
 		http.Error(w, "gameId is required", http.StatusBadRequest)
 		return
 	}
+	if req.Nonce == "" || req.Signature == "" {
+		http.Error(w, "nonce and signature are required: request a nonce from /api/did/register/nonce and sign it with the private key", http.StatusBadRequest)
+		return
+	}
 
 	// 验证 DID 格式
 	if !did.IsValidPlayerDID(req.DID) {
@@ -115,10 +151,19 @@ func (s *SimpleService) HandleRegisterDID(w http.ResponseWriter, r *http.Request
 	s.mutex.Lock()
 	if _, exists := s.dids[req.DID]; exists {
 		s.mutex.Unlock()
+		s.auditLog.Record(r.Context(), audit.CategoryDID, audit.OpCreate, req.DID, errors.New("DID already exists"))
 		http.Error(w, "DID already exists", http.StatusConflict)
 		return
 	}
 
+	// 验证客户端持有公钥对应的私钥
+	if err := s.verifyRegistrationProof(req.DID, req.PublicKey, req.Nonce, req.Signature); err != nil {
+		s.mutex.Unlock()
+		s.auditLog.Record(r.Context(), audit.CategoryDID, audit.OpCreate, req.DID, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	// 创建 SimpleDID 对象（不包含私钥）
 	playerDID := &did.SimpleDID{
 		ID:        req.DID,
@@ -130,7 +175,10 @@ func (s *SimpleService) HandleRegisterDID(w http.ResponseWriter, r *http.Request
 
 	// 存储DID
 	s.dids[req.DID] = playerDID
+	s.recordHistory(playerDID, OperationCreate)
+	s.cache.invalidate(req.DID)
 	s.mutex.Unlock()
+	s.auditLog.Record(r.Context(), audit.CategoryDID, audit.OpCreate, req.DID, nil)
 
 	// 构建响应
 	response := RegisterDIDResponse{
@@ -157,20 +205,15 @@ func (s *SimpleService) HandleResolveDID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 查找DID
-	s.mutex.RLock()
-	playerDID, exists := s.dids[didID]
-	s.mutex.RUnlock()
-
-	if !exists {
-		http.Error(w, "DID not found", http.StatusNotFound)
+	response, err := s.ResolveDIDContext(r.Context(), didID)
+	s.auditLog.Record(r.Context(), audit.CategoryDID, audit.OpResolve, didID, err)
+	switch {
+	case errors.Is(err, did.ErrDeactivated):
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), resolutionStatus(ResolutionErrorCode(err)))
 		return
-	}
-
-	// 构建响应
-	response := ResolveDIDResponse{
-		DID:    didID,
-		DIDDoc: playerDID.ToDIDDocument(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -179,24 +222,94 @@ func (s *SimpleService) HandleResolveDID(w http.ResponseWriter, r *http.Request)
 
 // ResolveDID 程序化解析DID
 func (s *SimpleService) ResolveDID(didID string) (*ResolveDIDResponse, error) {
-	s.mutex.RLock()
-	playerDID, exists := s.dids[didID]
-	s.mutex.RUnlock()
+	return s.ResolveDIDContext(context.Background(), didID)
+}
 
-	if !exists {
-		return nil, fmt.Errorf("DID not found: %s", didID)
+// ResolveDIDContext 同 ResolveDID，在 ctx 的追踪中记录解析跨度。
+// 除本地登记的 DID 外，did:key 直接展开，外部 did:web 通过 HTTPS 获取并规范化，无需预先注册。
+// 已停用的 DID 返回 did.ErrDeactivated，调用方据此拒绝认证、颁发凭证和验证凭证主体
+func (s *SimpleService) ResolveDIDContext(ctx context.Context, didID string) (*ResolveDIDResponse, error) {
+	result, err := s.ResolveContext(ctx, didID)
+	if err != nil {
+		return nil, err
+	}
+	if result.DIDDocumentMetadata.Deactivated {
+		return nil, fmt.Errorf("%w: %s", did.ErrDeactivated, didID)
+	}
+
+	var document *did.DIDDocument
+	switch resolved := result.DIDDocument.(type) {
+	case *did.DIDDocument:
+		document = resolved
+	case json.RawMessage:
+		if document, err = did.ParseDIDDocument(resolved); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected DID document type %T", result.DIDDocument)
 	}
 
 	return &ResolveDIDResponse{
 		DID:    didID,
-		DIDDoc: playerDID.ToDIDDocument(),
+		DIDDoc: document,
 	}, nil
 }
 
+// SetDIDCommEndpoint 设置 DIDComm 服务端点
+func (s *SimpleService) SetDIDCommEndpoint(endpoint map[string]interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.didcommEndpoint = endpoint
+	s.cache.purge()
+}
+
+// SetAuditLog 启用审计日志，记录 DID 的创建、解析、更新和停用。应在处理请求前调用
+func (s *SimpleService) SetAuditLog(auditLog *audit.Log) {
+	s.auditLog = auditLog
+}
+
+// SetCacheConfig 设置 DID 解析结果缓存，已缓存的结果被丢弃。应在处理请求前调用
+func (s *SimpleService) SetCacheConfig(config CacheConfig) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.cache.purge()
+	s.cache = newResolutionCache(config)
+}
+
+// resolutionCache 返回当前的解析结果缓存
+func (s *SimpleService) resolutionCache() *resolutionCache {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.cache
+}
+
+// document 生成 DID 文档，并附加服务器托管的 DIDComm 服务
+func (s *SimpleService) document(playerDID *did.SimpleDID) *did.DIDDocument {
+	doc := playerDID.ToDIDDocument()
+
+	s.mutex.RLock()
+	endpoint := s.didcommEndpoint
+	s.mutex.RUnlock()
+
+	if endpoint != nil {
+		doc.Service = append(doc.Service, did.Service{
+			ID:              playerDID.ID + "#didcomm",
+			Type:            aries.ServiceTypeDIDComm,
+			ServiceEndpoint: endpoint,
+		})
+	}
+	return doc
+}
+
 // CreateDIDWithAriesRequest 通过Aries创建DID的请求
 type CreateDIDWithAriesRequest struct {
 	GameID   string `json:"gameId"`
 	PlayerID string `json:"playerId"`
+	// PublicKey 客户端生成的 Ed25519 公钥（hex），服务器不再生成玩家私钥
+	PublicKey string `json:"publicKey"`
 	Nickname string `json:"nickname,omitempty"`
 	Level    int    `json:"level,omitempty"`
 }
@@ -206,13 +319,18 @@ type CreateDIDWithAriesResponse struct {
 	Success    bool   `json:"success"`
 	DID        string `json:"did"`
 	PublicKey  string `json:"publicKey"`
-	PrivateKey string `json:"privateKey"`
 	Message    string `json:"message,omitempty"`
 	Timestamp  string `json:"timestamp"`
 }
 
-// HandleCreateDIDWithAries 处理通过Aries创建DID的请求
+// HandleCreateDIDWithAries 处理通过Aries创建DID的请求。
+//
+// 已弃用：服务器不再生成和返回玩家私钥，请求需携带客户端生成的公钥；
+// 新客户端应使用 /api/did/register
 func (s *SimpleService) HandleCreateDIDWithAries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</api/did/register>; rel="successor-version"`)
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -239,21 +357,31 @@ func (s *SimpleService) HandleCreateDIDWithAries(w http.ResponseWriter, r *http.
 		http.Error(w, "playerId is required", http.StatusBadRequest)
 		return
 	}
+	if req.PublicKey == "" {
+		http.Error(w, "publicKey is required: server-side key generation is no longer supported, generate the key pair on the client", http.StatusBadRequest)
+		return
+	}
+	if key, err := hex.DecodeString(req.PublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+		http.Error(w, "publicKey must be a hex-encoded Ed25519 public key", http.StatusBadRequest)
+		return
+	}
 
-	// 使用Aries服务创建DID
-	ariesResponse, err := s.ariesSvc.CreatePlayerDID(req.GameID, req.PlayerID)
+	// 使用Aries服务保存客户端公钥对应的DID文档
+	ariesResponse, err := s.ariesSvc.RegisterPlayerDID(req.GameID, req.PlayerID, req.PublicKey)
 	if err != nil {
+		s.auditLog.Record(r.Context(), audit.CategoryDID, audit.OpCreate, fmt.Sprintf("did:player:%s:%s", req.GameID, req.PlayerID), err)
 		http.Error(w, fmt.Sprintf("Failed to create DID with Aries: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	s.auditLog.Record(r.Context(), audit.CategoryDID, audit.OpCreate, ariesResponse.DID, nil)
+
 	// 构建响应
 	response := CreateDIDWithAriesResponse{
 		Success:    true,
 		DID:        ariesResponse.DID,
 		PublicKey:  ariesResponse.PublicKey,
-		PrivateKey: ariesResponse.PrivateKey,
-		Message:    "DID created successfully with Aries framework",
+		Message:    "DID created successfully with Aries framework; this endpoint is deprecated, use /api/did/register",
 		Timestamp:  time.Now().Format(time.RFC3339),
 	}
 
@@ -261,6 +389,35 @@ func (s *SimpleService) HandleCreateDIDWithAries(w http.ResponseWriter, r *http.
 	json.NewEncoder(w).Encode(response)
 }
 
+// RegisterDID 程序化注册DID（如服务器颁发者DID），不保存私钥
+func (s *SimpleService) RegisterDID(playerDID *did.SimpleDID) error {
+	return s.RegisterDIDContext(context.Background(), playerDID)
+}
+
+// RegisterDIDContext 同 RegisterDID，审计日志中的调用方取自 ctx
+func (s *SimpleService) RegisterDIDContext(ctx context.Context, playerDID *did.SimpleDID) (err error) {
+	defer func() { s.auditLog.Record(ctx, audit.CategoryDID, audit.OpCreate, playerDID.ID, err) }()
+
+	if !did.IsValidPlayerDID(playerDID.ID) && !did.IsWebDID(playerDID.ID) {
+		return fmt.Errorf("invalid DID format: %s", playerDID.ID)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.dids[playerDID.ID]; exists {
+		return fmt.Errorf("DID already exists: %s", playerDID.ID)
+	}
+
+	public := playerDID.Clone()
+	public.PrivateKey = ""
+	s.dids[playerDID.ID] = public
+	s.recordHistory(public, OperationCreate)
+	s.cache.invalidate(playerDID.ID)
+
+	return nil
+}
+
 // GetDID 获取DID
 func (s *SimpleService) GetDID(didID string) (*did.SimpleDID, error) {
 	s.mutex.RLock()
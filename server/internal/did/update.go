@@ -0,0 +1,140 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// DID 历史操作类型
+const (
+	OperationCreate     = "create"
+	OperationUpdate     = "update"
+	OperationDeactivate = "deactivate"
+)
+
+// DIDHistoryEntry DID文档历史记录
+type DIDHistoryEntry struct {
+	Version   int              `json:"version"`
+	Operation string           `json:"operation"`
+	Timestamp time.Time        `json:"timestamp"`
+	Document  *did.DIDDocument `json:"didDocument"`
+}
+
+// UpdateDIDResponse 更新DID响应
+type UpdateDIDResponse struct {
+	Success   bool             `json:"success"`
+	DID       string           `json:"did"`
+	Version   int              `json:"version"`
+	DIDDoc    *did.DIDDocument `json:"didDocument"`
+	Timestamp string           `json:"timestamp"`
+}
+
+// HandleUpdateDID 处理DID更新请求（新增或停用验证密钥，或停用整个DID）
+func (s *SimpleService) HandleUpdateDID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req did.DIDUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.DID == "" {
+		http.Error(w, "did is required", http.StatusBadRequest)
+		return
+	}
+	if req.SigningKeyID == "" || req.Signature == "" {
+		http.Error(w, "signingKeyId and signature are required", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.UpdateDIDContext(r.Context(), &req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update DID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := UpdateDIDResponse{
+		Success:   true,
+		DID:       updated.ID,
+		Version:   updated.Version,
+		DIDDoc:    updated.ToDIDDocument(),
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateDID 应用经签名的DID更新操作
+func (s *SimpleService) UpdateDID(update *did.DIDUpdate) (*did.SimpleDID, error) {
+	return s.UpdateDIDContext(context.Background(), update)
+}
+
+// UpdateDIDContext 同 UpdateDID，审计日志中的调用方取自 ctx
+func (s *SimpleService) UpdateDIDContext(ctx context.Context, update *did.DIDUpdate) (updated *did.SimpleDID, err error) {
+	defer func() {
+		operation := audit.OpUpdate
+		if update.Deactivate {
+			operation = audit.OpDeactivate
+		}
+		s.auditLog.Record(ctx, audit.CategoryDID, operation, update.DID, err)
+	}()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, exists := s.dids[update.DID]
+	if !exists {
+		return nil, fmt.Errorf("DID not found: %s", update.DID)
+	}
+
+	// 在副本上应用更新，失败时不影响当前文档
+	updated = current.Clone()
+	if err := updated.ApplyUpdate(update); err != nil {
+		return nil, err
+	}
+
+	operation := OperationUpdate
+	if update.Deactivate {
+		operation = OperationDeactivate
+	}
+	s.dids[update.DID] = updated
+	s.recordHistory(updated, operation)
+	s.cache.invalidate(update.DID)
+
+	return updated.Clone(), nil
+}
+
+// GetDIDHistory 查询DID文档的历史版本
+func (s *SimpleService) GetDIDHistory(didID string) ([]*DIDHistoryEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries, exists := s.history[didID]
+	if !exists {
+		return nil, fmt.Errorf("DID not found: %s", didID)
+	}
+
+	return append([]*DIDHistoryEntry(nil), entries...), nil
+}
+
+// recordHistory 记录DID文档版本，调用方需持有写锁
+func (s *SimpleService) recordHistory(playerDID *did.SimpleDID, operation string) {
+	s.history[playerDID.ID] = append(s.history[playerDID.ID], &DIDHistoryEntry{
+		Version:   playerDID.Version,
+		Operation: operation,
+		Timestamp: time.Now(),
+		Document:  playerDID.ToDIDDocument(),
+	})
+	didOperationsTotal.With(operation).Inc()
+}
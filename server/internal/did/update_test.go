@@ -0,0 +1,101 @@
+package did
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// newRegisteredDID 在服务中登记玩家 DID，返回 DID 和 #key-1 的十六进制私钥
+func newRegisteredDID(t *testing.T, service *SimpleService) (*did.SimpleDID, string) {
+	t.Helper()
+	playerDID, err := did.CreatePlayerDID("test", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := playerDID.PrivateKey
+	if err := service.RegisterDID(playerDID); err != nil {
+		t.Fatalf("RegisterDID: %v", err)
+	}
+	return playerDID, privateKey
+}
+
+func signedUpdate(t *testing.T, service *SimpleService, didID, privateKey string, modify func(*did.DIDUpdate)) *did.DIDUpdate {
+	t.Helper()
+	current, err := service.GetDID(didID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := &did.DIDUpdate{DID: didID, PreviousVersion: current.Version, SigningKeyID: didID + "#key-1"}
+	modify(update)
+	if err := update.Sign(privateKey); err != nil {
+		t.Fatal(err)
+	}
+	return update
+}
+
+func TestUpdateInvalidatesResolutionCache(t *testing.T) {
+	service := NewSimpleService()
+	playerDID, privateKey := newRegisteredDID(t, service)
+
+	// 先解析一次使文档进入缓存
+	if _, err := service.ResolveDIDContext(context.Background(), playerDID.ID); err != nil {
+		t.Fatalf("ResolveDIDContext: %v", err)
+	}
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := signedUpdate(t, service, playerDID.ID, privateKey, func(u *did.DIDUpdate) {
+		u.AddKeys = []did.NewVerificationKey{{PublicKey: hex.EncodeToString(publicKey), Relationships: []string{did.RelationshipAuthentication}}}
+	})
+	if _, err := service.UpdateDID(update); err != nil {
+		t.Fatalf("UpdateDID: %v", err)
+	}
+
+	resolved, err := service.ResolveDIDContext(context.Background(), playerDID.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resolved.DIDDoc.AuthenticationKey(playerDID.ID + "#key-2"); !ok {
+		t.Error("resolution returned the cached document from before the update")
+	}
+
+	// 历史版本仍可解析
+	result, err := service.ResolveWithOptions(context.Background(), playerDID.ID, ResolveOptions{VersionID: "0"})
+	if err != nil {
+		t.Fatalf("resolve version 0: %v", err)
+	}
+	if result.DIDDocumentMetadata.NextVersionID != "1" {
+		t.Errorf("next version %q, want 1", result.DIDDocumentMetadata.NextVersionID)
+	}
+	history, err := service.GetDIDHistory(playerDID.ID)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history %d entries, %v", len(history), err)
+	}
+}
+
+func TestDeactivatedDIDDoesNotResolve(t *testing.T) {
+	service := NewSimpleService()
+	playerDID, privateKey := newRegisteredDID(t, service)
+
+	update := signedUpdate(t, service, playerDID.ID, privateKey, func(u *did.DIDUpdate) { u.Deactivate = true })
+	if _, err := service.UpdateDID(update); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	if _, err := service.ResolveDIDContext(context.Background(), playerDID.ID); !errors.Is(err, did.ErrDeactivated) {
+		t.Errorf("resolving a deactivated DID: %v", err)
+	}
+
+	// 停用后不能再更新
+	update = signedUpdate(t, service, playerDID.ID, privateKey, func(*did.DIDUpdate) {})
+	if _, err := service.UpdateDID(update); err == nil {
+		t.Error("deactivated DID was updated")
+	}
+}
@@ -0,0 +1,125 @@
+package did
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ResolveOptions DID 解析选项，指定时解析历史版本
+type ResolveOptions struct {
+	VersionID   string     // 按版本号解析
+	VersionTime *time.Time // 解析该时间点生效的版本
+}
+
+func (o ResolveOptions) versioned() bool {
+	return o.VersionID != "" || o.VersionTime != nil
+}
+
+// parseResolveOptions 从查询参数 versionId、versionTime 解析选项
+func parseResolveOptions(query url.Values) (ResolveOptions, error) {
+	options := ResolveOptions{VersionID: query.Get("versionId")}
+	if value := query.Get("versionTime"); value != "" {
+		versionTime, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return ResolveOptions{}, fmt.Errorf("versionTime must be an RFC 3339 timestamp: %s", value)
+		}
+		options.VersionTime = &versionTime
+	}
+	return options, nil
+}
+
+// resolveVersion 从历史记录中解析指定版本，只支持本地登记的 DID
+func (s *SimpleService) resolveVersion(didID string, options ResolveOptions) (interface{}, *DocumentMetadata, error) {
+	if options.VersionID != "" && options.VersionTime != nil {
+		return nil, nil, newResolutionError(ResolutionInvalidOptions, "versionId and versionTime cannot be used together")
+	}
+
+	s.mutex.RLock()
+	history := s.history[didID]
+	s.mutex.RUnlock()
+
+	if len(history) == 0 {
+		return nil, nil, newResolutionError(ResolutionNotFound, "no version history for %s", didID)
+	}
+
+	index := -1
+	if options.VersionID != "" {
+		version, err := strconv.Atoi(options.VersionID)
+		if err != nil {
+			return nil, nil, newResolutionError(ResolutionInvalidOptions, "versionId must be a number: %s", options.VersionID)
+		}
+		for i, entry := range history {
+			if entry.Version == version {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, nil, newResolutionError(ResolutionNotFound, "version %d of %s not found", version, didID)
+		}
+	} else {
+		// 历史按时间顺序追加，取该时间点之前的最后一个版本
+		for i, entry := range history {
+			if entry.Timestamp.After(*options.VersionTime) {
+				break
+			}
+			index = i
+		}
+		if index < 0 {
+			return nil, nil, newResolutionError(ResolutionNotFound, "%s did not exist at %s", didID, options.VersionTime.Format(time.RFC3339))
+		}
+	}
+
+	entry := history[index]
+	created := history[0].Timestamp
+	metadata := &DocumentMetadata{
+		Created:     &created,
+		VersionID:   strconv.Itoa(entry.Version),
+		Deactivated: entry.Operation == OperationDeactivate,
+	}
+	if index > 0 {
+		updated := entry.Timestamp
+		metadata.Updated = &updated
+	}
+	if index+1 < len(history) {
+		next := history[index+1]
+		nextUpdate := next.Timestamp
+		metadata.NextUpdate = &nextUpdate
+		metadata.NextVersionID = strconv.Itoa(next.Version)
+	}
+
+	return entry.Document, metadata, nil
+}
+
+// DIDHistoryResponse DID 文档历史响应
+type DIDHistoryResponse struct {
+	DID      string             `json:"did"`
+	Versions []*DIDHistoryEntry `json:"versions"`
+}
+
+// HandleDIDHistory 处理 GET /api/did/history?did=...，按时间顺序列出 DID 文档的所有版本，用于审计
+func (s *SimpleService) HandleDIDHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	didID := r.URL.Query().Get("did")
+	if didID == "" {
+		http.Error(w, "did parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := s.GetDIDHistory(didID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DIDHistoryResponse{DID: didID, Versions: versions})
+}
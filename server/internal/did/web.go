@@ -0,0 +1,46 @@
+package did
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// HostWebDID 注册由本服务器托管的 did:web 标识符，其 DID 文档通过 HandleWebDIDDocument 发布
+func (s *SimpleService) HostWebDID(webDID *did.SimpleDID) error {
+	if !did.IsWebDID(webDID.ID) {
+		return fmt.Errorf("not a did:web identifier: %s", webDID.ID)
+	}
+
+	if err := s.RegisterDID(webDID); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.webDID = webDID.ID
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// HandleWebDIDDocument 处理 GET /.well-known/did.json，返回托管的 did:web 文档
+func (s *SimpleService) HandleWebDIDDocument(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mutex.RLock()
+	webDID, exists := s.dids[s.webDID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		http.Error(w, "did:web is not configured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/did+json")
+	json.NewEncoder(w).Encode(s.document(webDID))
+}
@@ -0,0 +1,276 @@
+package didauth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// ErrNoCredentials 请求没有携带 DID 认证信息
+var ErrNoCredentials = errors.New("missing DID credentials")
+
+// Resolver 解析请求方 DID 文档，由 did.SimpleService 实现
+type Resolver interface {
+	ResolveDIDContext(ctx context.Context, didID string) (*did.ResolveDIDResponse, error)
+}
+
+// Config DID 认证配置
+type Config struct {
+	// PublicURL 服务的公开地址，JWT htu 的 scheme 和 host 必须与之一致；未设置时拒绝所有 JWT
+	PublicURL        string
+	Audience         string        // JWT 的 aud 必须等于该值，为空时使用 PublicURL
+	MaxClockSkew     time.Duration // JWT iat/exp 和 HTTP 签名 Date 头允许的时钟偏差
+	MaxTokenLifetime time.Duration // JWT exp 与 iat 之间的最长间隔
+}
+
+// DefaultConfig 返回默认 DID 认证配置
+func DefaultConfig() Config {
+	return Config{
+		MaxClockSkew:     5 * time.Minute,
+		MaxTokenLifetime: time.Hour,
+	}
+}
+
+// Claims DID 认证 JWT 的声明。aud、jti、htm 和 htu 必须存在：令牌只能用于本服务的该方法和地址，
+// 并且只能使用一次，被截获后不能重放或用于其他接口
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud"`
+	JWTID     string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Method    string `json:"htm"`
+	URI       string `json:"htu"`
+}
+
+// Authenticator 校验绑定到玩家 DID 的 HTTP 请求：
+// Authorization: Bearer <JWT>（iss 为 DID，kid 为其认证密钥）或 HTTP Signature（keyId 为认证密钥）
+type Authenticator struct {
+	resolver Resolver
+	config   Config
+	origin   *url.URL // PublicURL 解析后的 scheme 和 host
+	replay   *replayCache
+	now      func() time.Time
+}
+
+// NewAuthenticator 创建 DID 认证器，未设置的配置项使用默认值
+func NewAuthenticator(resolver Resolver, config Config) *Authenticator {
+	defaults := DefaultConfig()
+	if config.MaxClockSkew <= 0 {
+		config.MaxClockSkew = defaults.MaxClockSkew
+	}
+	if config.MaxTokenLifetime <= 0 {
+		config.MaxTokenLifetime = defaults.MaxTokenLifetime
+	}
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+	if config.Audience == "" {
+		config.Audience = config.PublicURL
+	}
+
+	a := &Authenticator{resolver: resolver, config: config, replay: newReplayCache(), now: time.Now}
+	if origin, err := url.Parse(config.PublicURL); err == nil && origin.Scheme != "" && origin.Host != "" {
+		a.origin = origin
+	}
+	return a
+}
+
+type contextKey struct{}
+
+// WithDID 返回带有已认证 DID 的上下文，该 DID 同时作为审计日志中的调用方
+func WithDID(ctx context.Context, didID string) context.Context {
+	return context.WithValue(audit.WithCaller(ctx, didID), contextKey{}, didID)
+}
+
+// DIDFromContext 返回中间件注入的已认证 DID
+func DIDFromContext(ctx context.Context) (string, bool) {
+	didID, ok := ctx.Value(contextKey{}).(string)
+	return didID, ok && didID != ""
+}
+
+// Require 要求请求带有有效的 DID 认证，失败时返回 401（请求体超过签名校验上限时返回 413），成功时将 DID 注入请求上下文
+func (a *Authenticator) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		didID, err := a.Authenticate(r)
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithDID(r.Context(), didID)))
+	})
+}
+
+// Optional 请求带有 DID 认证时校验并注入 DID，认证无效时返回 401；没有认证信息的请求原样交给 next
+func (a *Authenticator) Optional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		didID, err := a.Authenticate(r)
+		switch {
+		case errors.Is(err, ErrNoCredentials):
+			next.ServeHTTP(w, r)
+		case err != nil:
+			fail(w, err)
+		default:
+			next.ServeHTTP(w, r.WithContext(WithDID(r.Context(), didID)))
+		}
+	})
+}
+
+// Authenticate 校验请求的 DID 认证并返回请求方 DID。
+// 不是 JWT 形式的 Bearer 令牌（如游戏会话令牌）视为没有 DID 认证信息
+func (a *Authenticator) Authenticate(r *http.Request) (string, error) {
+	authorization := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(authorization, "Bearer "):
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if strings.Count(token, ".") != 2 {
+			return "", ErrNoCredentials
+		}
+		return a.authenticateJWT(r, token)
+	case strings.HasPrefix(authorization, "Signature "):
+		return a.authenticateSignature(r, strings.TrimPrefix(authorization, "Signature "))
+	case r.Header.Get(HeaderSignature) != "":
+		return a.authenticateSignature(r, r.Header.Get(HeaderSignature))
+	}
+	return "", ErrNoCredentials
+}
+
+// authenticateJWT 校验 DID 签名的 JWT
+func (a *Authenticator) authenticateJWT(r *http.Request, token string) (string, error) {
+	var (
+		header vc.JWTHeader
+		claims Claims
+	)
+	signingInput, signature, err := vc.ParseCompactJWT(token, &header, &claims)
+	if err != nil {
+		return "", err
+	}
+	if claims.Issuer == "" {
+		return "", errors.New("JWT iss is required")
+	}
+	if claims.Subject != "" && claims.Subject != claims.Issuer {
+		return "", errors.New("JWT sub must equal iss")
+	}
+	if !strings.HasPrefix(header.KID, claims.Issuer+"#") {
+		return "", errors.New("JWT kid does not belong to the issuer")
+	}
+	if a.origin == nil {
+		return "", errors.New("server public URL is not configured")
+	}
+	if claims.Audience == "" || strings.TrimSuffix(claims.Audience, "/") != a.config.Audience {
+		return "", errors.New("JWT aud does not match")
+	}
+	if claims.JWTID == "" {
+		return "", errors.New("JWT jti is required")
+	}
+
+	now := a.now()
+	issuedAt, expiresAt := time.Unix(claims.IssuedAt, 0), time.Unix(claims.ExpiresAt, 0)
+	switch {
+	case claims.ExpiresAt == 0:
+		return "", errors.New("JWT exp is required")
+	case now.After(expiresAt.Add(a.config.MaxClockSkew)):
+		return "", errors.New("JWT has expired")
+	case issuedAt.After(now.Add(a.config.MaxClockSkew)):
+		return "", errors.New("JWT iat is in the future")
+	case expiresAt.Sub(issuedAt) > a.config.MaxTokenLifetime:
+		return "", fmt.Errorf("JWT lifetime exceeds %s", a.config.MaxTokenLifetime)
+	}
+
+	if claims.Method == "" || claims.URI == "" {
+		return "", errors.New("JWT htm and htu are required")
+	}
+	if !strings.EqualFold(claims.Method, r.Method) {
+		return "", errors.New("JWT htm does not match the request method")
+	}
+	target, err := url.Parse(claims.URI)
+	if err != nil || !strings.EqualFold(target.Scheme, a.origin.Scheme) || !strings.EqualFold(target.Host, a.origin.Host) || target.Path != r.URL.Path {
+		return "", errors.New("JWT htu does not match the request URL")
+	}
+
+	publicKey, err := a.authenticationKey(r.Context(), header.KID)
+	if err != nil {
+		return "", err
+	}
+	if err := vc.VerifyJWTSignature(header.Alg, signingInput, signature, publicKey); err != nil {
+		return "", err
+	}
+	// 令牌在有效期内只能使用一次
+	if !a.replay.use("jti "+claims.Issuer+" "+claims.JWTID, expiresAt.Add(a.config.MaxClockSkew), now) {
+		return "", errors.New("JWT has already been used")
+	}
+	return claims.Issuer, nil
+}
+
+// authenticationKey 解析验证方法所属的 DID，返回其认证密钥的公钥
+func (a *Authenticator) authenticationKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	didID, _, _ := strings.Cut(keyID, "#")
+	resolved, err := a.resolver.ResolveDIDContext(ctx, didID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", didID, err)
+	}
+
+	method, ok := resolved.DIDDoc.AuthenticationKey(keyID)
+	if !ok {
+		return nil, fmt.Errorf("not an authentication key: %s", keyID)
+	}
+	return method.CryptoPublicKey()
+}
+
+// replayPruneInterval 清理已过期重放记录的最短间隔
+const replayPruneInterval = time.Minute
+
+// replayCache 记录仍在有效期内的已用 JWT ID 和 HTTP 签名，拒绝重放。记录只保存在本实例的内存中
+type replayCache struct {
+	seen     map[string]time.Time
+	prunedAt time.Time
+	mutex    sync.Mutex
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{seen: make(map[string]time.Time)}
+}
+
+// use 记录 key 直到 expiresAt，key 已被记录且未过期时返回 false
+func (c *replayCache) use(key string, expiresAt, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now.Sub(c.prunedAt) >= replayPruneInterval {
+		for seenKey, until := range c.seen {
+			if now.After(until) {
+				delete(c.seen, seenKey)
+			}
+		}
+		c.prunedAt = now
+	}
+	if until, exists := c.seen[key]; exists && !now.After(until) {
+		return false
+	}
+	c.seen[key] = expiresAt
+	return true
+}
+
+// fail 按认证失败的原因回复 401 或 413
+func fail(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrBodyTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	unauthorized(w, err)
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Add("WWW-Authenticate", `Bearer realm="did"`)
+	w.Header().Add("WWW-Authenticate", `Signature realm="did",headers="(request-target) date digest"`)
+	http.Error(w, fmt.Sprintf("DID authentication failed: %v", err), http.StatusUnauthorized)
+}
@@ -0,0 +1,215 @@
+package didauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/czh0526/game/server/internal/did"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// keyResolver 将 did:key 展开为 DID 文档
+type keyResolver struct{}
+
+func (keyResolver) ResolveDIDContext(_ context.Context, didID string) (*did.ResolveDIDResponse, error) {
+	document, err := pkgdid.KeyDIDDocument(didID)
+	if err != nil {
+		return nil, err
+	}
+	return &did.ResolveDIDResponse{DID: didID, DIDDoc: document}, nil
+}
+
+// testKey 测试用的 did:key 及其认证密钥
+type testKey struct {
+	did        string
+	keyID      string
+	privateKey ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T) testKey {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	didKey := pkgdid.NewKeyDID(publicKey)
+	return testKey{did: didKey, keyID: didKey + "#" + strings.TrimPrefix(didKey, pkgdid.KeyDIDPrefix), privateKey: privateKey}
+}
+
+func (k testKey) token(t *testing.T, claims Claims) string {
+	t.Helper()
+	token, err := vc.SignCompactJWT("JWT", k.keyID, claims, k.privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// sign 为请求添加覆盖 (request-target)、date 和 digest 的 HTTP Signature，digest 按 signedBody 计算
+func (k testKey) sign(t *testing.T, r *http.Request, signedBody []byte) {
+	t.Helper()
+	headers := []string{"(request-target)", "date", "digest"}
+	r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	r.Header.Set("Digest", Digest(signedBody))
+	signingString, err := SigningString(r, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := ed25519.Sign(k.privateKey, []byte(signingString))
+	r.Header.Set(HeaderSignature, `keyId="`+k.keyID+`",algorithm="ed25519",headers="`+strings.Join(headers, " ")+`",signature="`+base64.StdEncoding.EncodeToString(signature)+`"`)
+}
+
+// testPublicURL 测试认证器的公开地址
+const testPublicURL = "https://game.example.com"
+
+func newTestAuthenticator() *Authenticator {
+	config := DefaultConfig()
+	config.PublicURL = testPublicURL
+	return NewAuthenticator(keyResolver{}, config)
+}
+
+// serve 经新认证器的 Require 处理请求，返回状态码和处理函数看到的 DID
+func serve(r *http.Request) (int, string) {
+	return serveWith(newTestAuthenticator(), r)
+}
+
+// serveWith 经 a 的 Require 处理请求
+func serveWith(a *Authenticator, r *http.Request) (int, string) {
+	var authenticated string
+	handler := a.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, _ = DIDFromContext(r.Context())
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder.Code, authenticated
+}
+
+func TestJWTRequiresMethodAndURI(t *testing.T) {
+	key := newTestKey(t)
+	now := time.Now()
+	valid := Claims{Issuer: key.did, Audience: testPublicURL, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(), Method: http.MethodPost, URI: testPublicURL + "/api/vc/issue"}
+
+	tests := []struct {
+		name   string
+		modify func(*Claims)
+		want   int
+	}{
+		{"bound to the request", func(*Claims) {}, http.StatusOK},
+		{"missing htm", func(c *Claims) { c.Method = "" }, http.StatusUnauthorized},
+		{"missing htu", func(c *Claims) { c.URI = "" }, http.StatusUnauthorized},
+		{"other method", func(c *Claims) { c.Method = http.MethodDelete }, http.StatusUnauthorized},
+		{"other path", func(c *Claims) { c.URI = testPublicURL + "/api/vc/revoke" }, http.StatusUnauthorized},
+		{"other host", func(c *Claims) { c.URI = "https://evil.example.com/api/vc/issue" }, http.StatusUnauthorized},
+		{"other scheme", func(c *Claims) { c.URI = "http://game.example.com/api/vc/issue" }, http.StatusUnauthorized},
+		{"missing aud", func(c *Claims) { c.Audience = "" }, http.StatusUnauthorized},
+		{"other aud", func(c *Claims) { c.Audience = "https://evil.example.com" }, http.StatusUnauthorized},
+		{"missing jti", func(c *Claims) { c.JWTID = "" }, http.StatusUnauthorized},
+		{"expired", func(c *Claims) { c.ExpiresAt = now.Add(-time.Hour).Unix() }, http.StatusUnauthorized},
+	}
+	for i, tt := range tests {
+		claims := valid
+		claims.JWTID = fmt.Sprintf("token-%d", i)
+		tt.modify(&claims)
+		r := httptest.NewRequest(http.MethodPost, "/api/vc/issue", nil)
+		r.Header.Set("Authorization", "Bearer "+key.token(t, claims))
+
+		code, authenticated := serve(r)
+		if code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, code, tt.want)
+		}
+		if code == http.StatusOK && authenticated != key.did {
+			t.Errorf("%s: authenticated as %q", tt.name, authenticated)
+		}
+	}
+}
+
+func TestJWTCannotBeReplayed(t *testing.T) {
+	key := newTestKey(t)
+	now := time.Now()
+	token := key.token(t, Claims{Issuer: key.did, Audience: testPublicURL, JWTID: "once", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(), Method: http.MethodPost, URI: testPublicURL + "/api/vc/issue"})
+	authenticator := newTestAuthenticator()
+
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		r := httptest.NewRequest(http.MethodPost, "/api/vc/issue", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		if code, _ := serveWith(authenticator, r); code != want {
+			t.Errorf("use %d: status %d, want %d", i+1, code, want)
+		}
+	}
+}
+
+func TestJWTRequiresPublicURL(t *testing.T) {
+	key := newTestKey(t)
+	now := time.Now()
+	r := httptest.NewRequest(http.MethodPost, "/api/vc/issue", nil)
+	r.Header.Set("Authorization", "Bearer "+key.token(t, Claims{Issuer: key.did, Audience: testPublicURL, JWTID: "1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix(), Method: http.MethodPost, URI: testPublicURL + "/api/vc/issue"}))
+
+	// 不知道自己的公开地址就无法校验 aud 和 htu，不接受任何 JWT
+	if code, _ := serveWith(NewAuthenticator(keyResolver{}, DefaultConfig()), r); code != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestHTTPSignatureCannotBeReplayed(t *testing.T) {
+	key := newTestKey(t)
+	body := []byte(`{"credentialId":"urn:uuid:1"}`)
+	authenticator := newTestAuthenticator()
+
+	first := httptest.NewRequest(http.MethodPost, "/api/vc/revoke", bytes.NewReader(body))
+	key.sign(t, first, body)
+	replayed := httptest.NewRequest(http.MethodPost, "/api/vc/revoke", bytes.NewReader(body))
+	replayed.Header = first.Header.Clone()
+
+	if code, _ := serveWith(authenticator, first); code != http.StatusOK {
+		t.Fatalf("signed request: status %d", code)
+	}
+	if code, _ := serveWith(authenticator, replayed); code != http.StatusUnauthorized {
+		t.Errorf("replayed request: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestHTTPSignatureCoversBody(t *testing.T) {
+	key := newTestKey(t)
+	body := []byte(`{"credentialId":"urn:uuid:1"}`)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/vc/revoke", bytes.NewReader(body))
+	key.sign(t, r, body)
+	if code, authenticated := serve(r); code != http.StatusOK || authenticated != key.did {
+		t.Fatalf("signed request: status %d, DID %q", code, authenticated)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/api/vc/revoke", bytes.NewReader([]byte(`{"credentialId":"urn:uuid:2"}`)))
+	key.sign(t, r, body)
+	if code, _ := serve(r); code != http.StatusUnauthorized {
+		t.Errorf("tampered body: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestHTTPSignatureRejectsOversizeBody(t *testing.T) {
+	key := newTestKey(t)
+	// 签名覆盖前 maxBodySize 字节，之后附加的内容不能绕过摘要校验
+	signed := bytes.Repeat([]byte("a"), maxBodySize)
+	body := append(append([]byte(nil), signed...), []byte(`,"admin":true`)...)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/vc/revoke", bytes.NewReader(body))
+	key.sign(t, r, signed)
+	if code, _ := serve(r); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/api/vc/revoke", bytes.NewReader(signed))
+	key.sign(t, r, signed)
+	if code, _ := serve(r); code != http.StatusOK {
+		t.Errorf("body at the limit: status %d, want %d", code, http.StatusOK)
+	}
+}
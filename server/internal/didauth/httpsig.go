@@ -0,0 +1,178 @@
+package didauth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// HeaderSignature HTTP Signature 请求头，也可以用 Authorization: Signature <参数> 传递
+const HeaderSignature = "Signature"
+
+// maxBodySize 校验 Digest 时读取的最大请求体
+const maxBodySize = 1 << 20
+
+// ErrBodyTooLarge 带 HTTP Signature 的请求体超过 maxBodySize，无法完整校验摘要
+var ErrBodyTooLarge = errors.New("request body exceeds 1 MiB")
+
+// signatureParams Signature 头的参数
+type signatureParams struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+// parseSignature 解析 keyId="...",algorithm="...",headers="...",signature="..." 形式的参数
+func parseSignature(value string) (*signatureParams, error) {
+	params := &signatureParams{}
+	for _, part := range strings.Split(value, ",") {
+		name, quoted, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed signature parameter: %s", part)
+		}
+		value := strings.Trim(quoted, `"`)
+		switch name {
+		case "keyId":
+			params.KeyID = value
+		case "algorithm":
+			params.Algorithm = value
+		case "headers":
+			params.Headers = strings.Fields(strings.ToLower(value))
+		case "signature":
+			signature, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, errors.New("invalid signature encoding")
+			}
+			params.Signature = signature
+		}
+	}
+
+	if params.KeyID == "" || len(params.Signature) == 0 {
+		return nil, errors.New("keyId and signature are required")
+	}
+	// 实际算法由密钥类型决定，algorithm 只用于拒绝不支持的声明
+	switch params.Algorithm {
+	case "", "hs2019", "ed25519", "ecdsa-p256-sha256":
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", params.Algorithm)
+	}
+	if len(params.Headers) == 0 {
+		params.Headers = []string{"date"}
+	}
+	return params, nil
+}
+
+// SigningString 按 headers 的顺序拼接签名内容，每行为小写头名、冒号、空格和值，
+// (request-target) 为小写方法、空格和请求 URI。客户端用它生成签名
+func SigningString(r *http.Request, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		name = strings.ToLower(name)
+		var value string
+		switch name {
+		case "(request-target)":
+			value = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case "host":
+			value = r.Host
+		default:
+			values := r.Header.Values(name)
+			if len(values) == 0 {
+				return "", fmt.Errorf("signed header %s is missing", name)
+			}
+			value = strings.Join(values, ", ")
+		}
+		lines = append(lines, name+": "+value)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Digest 返回请求体的 Digest 头值（SHA-256=<base64>）
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// authenticateSignature 校验 HTTP Signature。签名必须覆盖 (request-target) 和 Date，
+// 带有请求体时还必须覆盖与请求体一致的 Digest。Ed25519 密钥直接签名，P-256 密钥使用 SHA-256 和 r || s 定长格式；
+// 同一签名只接受一次
+func (a *Authenticator) authenticateSignature(r *http.Request, value string) (string, error) {
+	params, err := parseSignature(value)
+	if err != nil {
+		return "", err
+	}
+
+	covered := make(map[string]bool, len(params.Headers))
+	for _, name := range params.Headers {
+		covered[name] = true
+	}
+	if !covered["(request-target)"] || !covered["date"] {
+		return "", errors.New("signature must cover (request-target) and date")
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return "", errors.New("invalid Date header")
+	}
+	if skew := a.now().Sub(date); skew > a.config.MaxClockSkew || skew < -a.config.MaxClockSkew {
+		return "", errors.New("request date outside the allowed window")
+	}
+
+	// 读取请求体校验摘要，之后还原供处理函数解码
+	if r.Body != nil && r.Body != http.NoBody {
+		// 多读一个字节以发现超限的请求体，截断后校验摘要会让签名只覆盖请求体的前一部分
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			return "", fmt.Errorf("read body: %w", err)
+		}
+		if len(body) > maxBodySize {
+			return "", ErrBodyTooLarge
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if len(body) > 0 {
+			if !covered["digest"] {
+				return "", errors.New("signature must cover digest for requests with a body")
+			}
+			if r.Header.Get("Digest") != Digest(body) {
+				return "", errors.New("digest does not match the request body")
+			}
+		}
+	}
+
+	signingString, err := SigningString(r, params.Headers)
+	if err != nil {
+		return "", err
+	}
+	publicKey, err := a.authenticationKey(r.Context(), params.KeyID)
+	if err != nil {
+		return "", err
+	}
+
+	switch publicKey.(type) {
+	case ed25519.PublicKey:
+		err = vc.VerifyJWTSignature(vc.JWTAlgEdDSA, signingString, params.Signature, publicKey)
+	case *ecdsa.PublicKey:
+		err = vc.VerifyJWTSignature(vc.JWTAlgES256, signingString, params.Signature, publicKey)
+	default:
+		err = fmt.Errorf("unsupported key type %T", publicKey)
+	}
+	if err != nil {
+		return "", err
+	}
+	// 同一签名在 Date 允许的时间窗口内只能使用一次
+	if !a.replay.use("signature "+base64.StdEncoding.EncodeToString(params.Signature), date.Add(a.config.MaxClockSkew), a.now()) {
+		return "", errors.New("signature has already been used")
+	}
+
+	didID, _, _ := strings.Cut(params.KeyID, "#")
+	return didID, nil
+}
@@ -0,0 +1,637 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// 成就消息类型
+const (
+	MsgTypeAchievements        = "achievements"         // 查询玩家在游戏中的成就进度
+	MsgTypeAchievementUnlocked = "achievement_unlocked" // 玩家解锁了成就
+)
+
+// AchievementsPath 成就接口路径，玩家进度为 AchievementsPath + "/{did}"
+const AchievementsPath = "/api/achievements"
+
+// 成就条件中可用的玩家统计，此外每个游戏事件计入 "{事件类型}" 和 "{事件类型}:{目标}" 两个统计
+const (
+	StatLevel   = "level"   // 玩家当前等级
+	StatMatches = "matches" // 完成的对局数
+	StatWins    = "wins"    // 获胜的对局数
+)
+
+const (
+	// achievementsStoreName 成就进度的存储名称
+	achievementsStoreName = "game_achievements"
+
+	recordTypeAchievementProgress = "achievement_progress"
+)
+
+// ErrAchievementExists 成就 ID 已被注册
+var ErrAchievementExists = errors.New("achievement already registered")
+
+// 成就凭证格式
+const (
+	// AchievementFormatGame 游戏自有格式的 AchievementCredential
+	AchievementFormatGame = "game"
+	// AchievementFormatOpenBadges Open Badges 3.0 格式的 OpenBadgeCredential，可以导入标准徽章钱包和学习平台
+	AchievementFormatOpenBadges = "openbadges"
+)
+
+// AchievementCredentialTemplate 解锁成就时颁发的 AchievementCredential 的内容
+type AchievementCredentialTemplate struct {
+	Score int `json:"score"`
+	// Attributes 合并到凭证主体的属性中
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	// Format 凭证格式，为空时为 AchievementFormatGame；以下字段只用于 Open Badges 格式
+	Format string `json:"format,omitempty"`
+	// URL 成就的公开页面地址，作为 Open Badges 成就的 ID，为空时为 urn:game:{游戏ID}:achievement:{成就ID}
+	URL string `json:"url,omitempty"`
+	// Image 徽章图片地址
+	Image string `json:"image,omitempty"`
+	// Description 徽章钱包中显示的描述，为空时使用成就定义的描述
+	Description string `json:"description,omitempty"`
+	// Criteria 获得条件的说明，为空时使用描述；CriteriaURL 条件说明页面的地址
+	Criteria    string `json:"criteria,omitempty"`
+	CriteriaURL string `json:"criteriaUrl,omitempty"`
+	// Alignment 成就对应的外部能力框架或课程目标，每项需要 targetName 和 targetUrl
+	Alignment []vc.BadgeAlignment `json:"alignment,omitempty"`
+	// IssuerName 徽章钱包中显示的颁发者名称
+	IssuerName string `json:"issuerName,omitempty"`
+}
+
+// validate 校验凭证格式和 Open Badges 字段
+func (t *AchievementCredentialTemplate) validate() error {
+	switch t.Format {
+	case "", AchievementFormatGame, AchievementFormatOpenBadges:
+	default:
+		return fmt.Errorf("unknown credential format: %s", t.Format)
+	}
+	for i, alignment := range t.Alignment {
+		if alignment.TargetName == "" || alignment.TargetURL == "" {
+			return fmt.Errorf("alignment %d: targetName and targetUrl are required", i)
+		}
+	}
+	return nil
+}
+
+// badge 按模板生成 Open Badges 成就描述
+func (t *AchievementCredentialTemplate) badge(gameID string, definition AchievementDefinition) vc.BadgeAchievement {
+	badge := vc.BadgeAchievement{
+		ID:          t.URL,
+		Name:        definition.Name,
+		Description: t.Description,
+		Criteria:    vc.BadgeCriteria{ID: t.CriteriaURL, Narrative: t.Criteria},
+		Alignment:   t.Alignment,
+	}
+	if badge.ID == "" {
+		badge.ID = "urn:game:" + gameID + ":achievement:" + definition.ID
+	}
+	if badge.Description == "" {
+		badge.Description = definition.Description
+	}
+	if badge.Description == "" {
+		badge.Description = definition.Name
+	}
+	if badge.Criteria.Narrative == "" {
+		badge.Criteria.Narrative = badge.Description
+	}
+	if t.Image != "" {
+		badge.Image = &vc.BadgeImage{ID: t.Image, Caption: definition.Name}
+	}
+	if t.IssuerName != "" {
+		badge.Creator = &vc.BadgeProfile{Name: t.IssuerName}
+	}
+	return badge
+}
+
+// AchievementDefinition 成就定义，条件满足时解锁，与任务无关
+type AchievementDefinition struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	// GameID 成就所属的游戏，为空时适用于所有游戏，进度按游戏分别统计
+	GameID string `json:"gameId,omitempty"`
+	// Criteria 解锁条件，如 "kill >= 10 && wins >= 3"，见 compileCriteria
+	Criteria string `json:"criteria"`
+	// Credential 不为空时解锁后颁发成就凭证，凭证中的成就名为 Name
+	Credential *AchievementCredentialTemplate `json:"credential,omitempty"`
+}
+
+// AchievementCondition 条件中一项比较的进度
+type AchievementCondition struct {
+	Stat     string  `json:"stat"`
+	Op       string  `json:"op"`
+	Current  float64 `json:"current"`
+	Required float64 `json:"required"`
+	Met      bool    `json:"met"`
+}
+
+// AchievementStatus 玩家在一项成就上的进度
+type AchievementStatus struct {
+	AchievementDefinition
+	Unlocked   bool                   `json:"unlocked"`
+	UnlockedAt *time.Time             `json:"unlockedAt,omitempty"`
+	Conditions []AchievementCondition `json:"conditions"`
+}
+
+// AchievementProgress 玩家在一个游戏中的统计和已解锁的成就
+type AchievementProgress struct {
+	DID      string               `json:"did"`
+	GameID   string               `json:"gameId"`
+	Stats    map[string]float64   `json:"stats"`
+	Unlocked map[string]time.Time `json:"unlocked"`
+}
+
+// achievementRule 编译后的成就定义
+type achievementRule struct {
+	definition AchievementDefinition
+	criteria   criterion
+}
+
+// DefaultAchievements 返回默认成就
+func DefaultAchievements() []AchievementDefinition {
+	return []AchievementDefinition{
+		{
+			ID:          "welcome",
+			Name:        "Welcome to the Game",
+			Description: "Complete the welcome task",
+			Icon:        "achievement-welcome",
+			Criteria:    EventTaskCompleted + ":welcome_task >= 1",
+			Credential:  &AchievementCredentialTemplate{Score: 100},
+		},
+		{
+			ID:          "first_blood",
+			Name:        "First Blood",
+			Description: "Defeat another player",
+			Icon:        "achievement-first-blood",
+			Criteria:    EventKill + " >= 1",
+			Credential:  &AchievementCredentialTemplate{Score: 100},
+		},
+		{
+			ID:          "explorer",
+			Name:        "Explorer",
+			Description: "Interact with 20 map objects",
+			Icon:        "achievement-explorer",
+			Criteria:    EventInteraction + " >= 20",
+			Credential:  &AchievementCredentialTemplate{Score: 200},
+		},
+		{
+			ID:          "champion",
+			Name:        "Champion",
+			Description: "Win 5 matches",
+			Icon:        "achievement-champion",
+			Criteria:    StatWins + " >= 5",
+			Credential:  &AchievementCredentialTemplate{Score: 500},
+		},
+		{
+			ID:          "seasoned",
+			Name:        "Seasoned",
+			Description: "Reach level 10",
+			Icon:        "achievement-seasoned",
+			Criteria:    StatLevel + " >= 10",
+			Credential:  &AchievementCredentialTemplate{Score: 300},
+		},
+	}
+}
+
+// Achievements 成就定义和玩家进度。只统计成就条件中出现的统计，进度在内存中缓存并在变化时同步写入存储
+type Achievements struct {
+	store storage.Store
+
+	rules   []*achievementRule
+	tracked map[string]bool // 条件中出现的统计
+
+	progress map[string]*AchievementProgress // 游戏 ID/DID -> 进度
+	mutex    sync.Mutex
+}
+
+// NewAchievements 创建成就模块并加载存储中的玩家进度，成就定义通过 Register 注册
+func NewAchievements(provider storage.Provider) (*Achievements, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(achievementsStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open achievements store: %w", err)
+	}
+
+	a := &Achievements{
+		store:    store,
+		tracked:  make(map[string]bool),
+		progress: make(map[string]*AchievementProgress),
+	}
+	err = queryRecords(store, recordTypeTag, recordTypeAchievementProgress, func(data []byte) error {
+		var progress AchievementProgress
+		if err := json.Unmarshal(data, &progress); err != nil {
+			return fmt.Errorf("unmarshal achievement progress: %w", err)
+		}
+		a.progress[progressKey(progress.GameID, progress.DID)] = &progress
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Register 注册成就定义，条件表达式在注册时编译
+func (a *Achievements) Register(definition AchievementDefinition) error {
+	if definition.ID == "" || definition.Name == "" {
+		return errors.New("achievement id and name are required")
+	}
+	criteria, err := compileCriteria(definition.Criteria)
+	if err != nil {
+		return fmt.Errorf("achievement %s: %w", definition.ID, err)
+	}
+	if definition.Credential != nil {
+		if err := definition.Credential.validate(); err != nil {
+			return fmt.Errorf("achievement %s: %w", definition.ID, err)
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, rule := range a.rules {
+		if rule.definition.ID == definition.ID {
+			return fmt.Errorf("%w: %s", ErrAchievementExists, definition.ID)
+		}
+	}
+	a.rules = append(a.rules, &achievementRule{definition: definition, criteria: criteria})
+	for _, c := range criteria.comparisons() {
+		a.tracked[c.stat] = true
+	}
+	return nil
+}
+
+// Definitions 返回适用于游戏的成就定义，gameID 为空时返回所有定义
+func (a *Achievements) Definitions(gameID string) []AchievementDefinition {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	definitions := make([]AchievementDefinition, 0, len(a.rules))
+	for _, rule := range a.rules {
+		if gameID == "" || rule.applies(gameID) {
+			definitions = append(definitions, rule.definition)
+		}
+	}
+	return definitions
+}
+
+// Add 将统计增加 delta 并返回因此解锁的成就，条件中没有出现的统计被忽略
+func (a *Achievements) Add(playerDID, gameID string, deltas map[string]float64) ([]AchievementDefinition, error) {
+	return a.update(playerDID, gameID, func(stats map[string]float64) bool {
+		changed := false
+		for stat, delta := range deltas {
+			if a.tracked[stat] && delta != 0 {
+				stats[stat] += delta
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// Set 设置统计的值并返回因此解锁的成就，条件中没有出现的统计被忽略
+func (a *Achievements) Set(playerDID, gameID, stat string, value float64) ([]AchievementDefinition, error) {
+	return a.update(playerDID, gameID, func(stats map[string]float64) bool {
+		if !a.tracked[stat] || stats[stat] == value {
+			return false
+		}
+		stats[stat] = value
+		return true
+	})
+}
+
+// Progress 返回玩家在游戏中每项成就的进度
+func (a *Achievements) Progress(playerDID, gameID string) []AchievementStatus {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	progress := a.progress[progressKey(gameID, playerDID)]
+	var stats map[string]float64
+	if progress != nil {
+		stats = progress.Stats
+	}
+
+	statuses := make([]AchievementStatus, 0, len(a.rules))
+	for _, rule := range a.rules {
+		if !rule.applies(gameID) {
+			continue
+		}
+
+		status := AchievementStatus{AchievementDefinition: rule.definition}
+		if progress != nil {
+			if unlockedAt, unlocked := progress.Unlocked[rule.definition.ID]; unlocked {
+				status.Unlocked = true
+				status.UnlockedAt = &unlockedAt
+			}
+		}
+		for _, c := range rule.criteria.comparisons() {
+			status.Conditions = append(status.Conditions, AchievementCondition{
+				Stat:     c.stat,
+				Op:       c.op,
+				Current:  stats[c.stat],
+				Required: c.value,
+				Met:      c.eval(stats),
+			})
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// PlayerProgress 返回玩家在各游戏中的统计和已解锁的成就
+func (a *Achievements) PlayerProgress(playerDID string) []AchievementProgress {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var progress []AchievementProgress
+	for _, p := range a.progress {
+		if p.DID == playerDID {
+			progress = append(progress, *p)
+		}
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].GameID < progress[j].GameID })
+	return progress
+}
+
+// ErasePlayer 删除玩家在所有游戏中的统计和成就进度，返回删除的游戏数
+func (a *Achievements) ErasePlayer(playerDID string) (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	erased := 0
+	for key, progress := range a.progress {
+		if progress.DID != playerDID {
+			continue
+		}
+		if err := a.store.Delete(key); err != nil {
+			return erased, fmt.Errorf("delete achievement progress: %w", err)
+		}
+		delete(a.progress, key)
+		erased++
+	}
+	return erased, nil
+}
+
+// Transfer 将玩家在所有游戏中的统计和成就进度转给另一个 DID，返回转移的游戏数。
+// 目标 DID 在同一游戏中已有进度时保留目标的进度
+func (a *Achievements) Transfer(fromDID, toDID string) (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	transferred := 0
+	for key, progress := range a.progress {
+		if progress.DID != fromDID {
+			continue
+		}
+		target := progressKey(progress.GameID, toDID)
+		if _, exists := a.progress[target]; !exists {
+			moved := *progress
+			moved.DID = toDID
+			data, err := json.Marshal(&moved)
+			if err != nil {
+				return transferred, fmt.Errorf("marshal achievement progress: %w", err)
+			}
+			if err := a.store.Put(target, data, storage.Tag{Name: recordTypeTag, Value: recordTypeAchievementProgress}); err != nil {
+				return transferred, fmt.Errorf("save achievement progress: %w", err)
+			}
+			a.progress[target] = &moved
+			transferred++
+		}
+		if err := a.store.Delete(key); err != nil {
+			return transferred, fmt.Errorf("delete achievement progress: %w", err)
+		}
+		delete(a.progress, key)
+	}
+	return transferred, nil
+}
+
+// update 修改玩家统计，统计变化时评估尚未解锁的成就并写入存储
+func (a *Achievements) update(playerDID, gameID string, change func(stats map[string]float64) bool) ([]AchievementDefinition, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key := progressKey(gameID, playerDID)
+	progress, exists := a.progress[key]
+	if !exists {
+		progress = &AchievementProgress{
+			DID:      playerDID,
+			GameID:   gameID,
+			Stats:    make(map[string]float64),
+			Unlocked: make(map[string]time.Time),
+		}
+	}
+	if !change(progress.Stats) {
+		return nil, nil
+	}
+	a.progress[key] = progress
+
+	var unlocked []AchievementDefinition
+	now := time.Now()
+	for _, rule := range a.rules {
+		if _, done := progress.Unlocked[rule.definition.ID]; done || !rule.applies(gameID) {
+			continue
+		}
+		if rule.criteria.eval(progress.Stats) {
+			progress.Unlocked[rule.definition.ID] = now
+			unlocked = append(unlocked, rule.definition)
+		}
+	}
+
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return unlocked, fmt.Errorf("marshal achievement progress: %w", err)
+	}
+	if err := a.store.Put(key, data, storage.Tag{Name: recordTypeTag, Value: recordTypeAchievementProgress}); err != nil {
+		return unlocked, fmt.Errorf("save achievement progress: %w", err)
+	}
+	return unlocked, nil
+}
+
+// applies 成就是否适用于游戏
+func (r *achievementRule) applies(gameID string) bool {
+	return r.definition.GameID == "" || r.definition.GameID == gameID
+}
+
+func progressKey(gameID, playerDID string) string {
+	return "progress:" + gameID + "/" + playerDID
+}
+
+// SetAchievements 使用持久化的成就模块替换默认的内存实现，应在接受连接前调用
+func (s *SimpleServer) SetAchievements(achievements *Achievements) {
+	s.achievements = achievements
+}
+
+// RegisterAchievement 注册成就定义
+func (s *SimpleServer) RegisterAchievement(definition AchievementDefinition) error {
+	return s.achievements.Register(definition)
+}
+
+// recordEventStats 将游戏事件计入玩家统计
+func (s *SimpleServer) recordEventStats(room *GameRoom, player *Player, eventType, target string) {
+	deltas := map[string]float64{eventType: 1}
+	if target != "" {
+		deltas[eventType+":"+target] = 1
+	}
+	unlocked, err := s.achievements.Add(player.DID, room.GameID, deltas)
+	s.unlockAchievements(room.GameID, player, unlocked, err)
+}
+
+// recordLevelStat 更新玩家的等级统计
+func (s *SimpleServer) recordLevelStat(room *GameRoom, player *Player, level int) {
+	unlocked, err := s.achievements.Set(player.DID, room.GameID, StatLevel, float64(level))
+	s.unlockAchievements(room.GameID, player, unlocked, err)
+}
+
+// recordMatchStats 将对局结果计入房间内玩家的统计
+func (s *SimpleServer) recordMatchStats(room *GameRoom, match *MatchResult) {
+	room.mutex.RLock()
+	players := make(map[*Player]bool, len(match.Players))
+	for _, result := range match.Players {
+		if player, exists := room.Players[result.PlayerID]; exists {
+			players[player] = result.Won
+		}
+	}
+	room.mutex.RUnlock()
+
+	for player, won := range players {
+		deltas := map[string]float64{StatMatches: 1}
+		if won {
+			deltas[StatWins] = 1
+		}
+		unlocked, err := s.achievements.Add(player.DID, room.GameID, deltas)
+		s.unlockAchievements(room.GameID, player, unlocked, err)
+	}
+}
+
+// unlockAchievements 通知玩家解锁的成就，并按定义颁发成就凭证
+func (s *SimpleServer) unlockAchievements(gameID string, player *Player, unlocked []AchievementDefinition, err error) {
+	if err != nil {
+		player.log().Error("Failed to update achievement progress", logging.Err(err))
+	}
+
+	for _, definition := range unlocked {
+		achievementsUnlockedTotal.With(definition.ID).Inc()
+		player.log().Info("Player unlocked achievement", "achievement", definition.ID, "game_id", gameID)
+
+		data := map[string]interface{}{
+			"gameId":      gameID,
+			"achievement": definition,
+		}
+		if definition.Credential != nil && player.DID != "" && !player.isGuest() {
+			credential, err := s.issueDefinedAchievement(gameID, player, definition)
+			if err != nil {
+				player.log().Error("Failed to issue achievement credential", "achievement", definition.ID, logging.Err(err))
+			} else {
+				data["credential"] = credential
+			}
+		}
+
+		if player.Connection != nil {
+			writeMessage(player.Connection, Message{
+				Type:      MsgTypeAchievementUnlocked,
+				PlayerID:  player.ID,
+				Data:      data,
+				Timestamp: time.Now(),
+			})
+		}
+	}
+}
+
+// issueDefinedAchievement 按成就定义的凭证模板颁发成就凭证
+func (s *SimpleServer) issueDefinedAchievement(gameID string, player *Player, definition AchievementDefinition) (*vc.SimpleCredential, error) {
+	template := definition.Credential
+	attributes := map[string]interface{}{
+		"achievementId": definition.ID,
+	}
+	if definition.Icon != "" {
+		attributes["icon"] = definition.Icon
+	}
+	for key, value := range template.Attributes {
+		attributes[key] = value
+	}
+
+	if template.Format == AchievementFormatOpenBadges {
+		return s.vcService.IssueOpenBadgeCredential(player.traceContext(), player.DID, gameID, player.ID,
+			template.badge(gameID, definition), template.Score, attributes)
+	}
+	return s.vcService.IssueAchievementCredentialWithAttributes(player.traceContext(), player.DID, gameID, player.ID,
+		definition.Name, template.Score, attributes)
+}
+
+// handleAchievements 返回玩家在所在房间的游戏（或指定游戏）中的成就进度
+func (s *SimpleServer) handleAchievements(player *Player, payload *AchievementsPayload) {
+	gameID := payload.GameID
+	if gameID == "" {
+		gameID = defaultGameID
+		if room := player.Room; room != nil {
+			gameID = room.GameID
+		}
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeAchievements,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"gameId":       gameID,
+			"achievements": s.achievements.Progress(player.DID, gameID),
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// HandleAchievements 处理 GET /api/achievements?game=...（成就定义）和 GET /api/achievements/{did}?game=...（玩家进度）
+func (s *SimpleServer) HandleAchievements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	gameID := r.URL.Query().Get("game")
+	playerDID := strings.Trim(strings.TrimPrefix(r.URL.Path, AchievementsPath), "/")
+	if playerDID == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"game":         gameID,
+			"achievements": s.achievements.Definitions(gameID),
+		})
+		return
+	}
+
+	if gameID == "" {
+		gameID = defaultGameID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"did":          playerDID,
+		"game":         gameID,
+		"achievements": s.achievements.Progress(playerDID, gameID),
+	})
+}
+
+// registerDefaultAchievements 注册默认成就
+func (s *SimpleServer) registerDefaultAchievements() error {
+	for _, definition := range DefaultAchievements() {
+		if err := s.RegisterAchievement(definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package game
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+// MsgTypeAnnouncement 管理员发布的公告
+const MsgTypeAnnouncement = "announcement"
+
+// defaultGameID 通过 join_room 创建的房间所属的游戏
+const defaultGameID = "default"
+
+// 管理操作错误
+var (
+	ErrPlayerNotConnected = errors.New("player is not connected to this instance")
+	ErrRoomNotFound       = errors.New("room not found")
+)
+
+// PlayerInfo 管理接口中的玩家概要
+type PlayerInfo struct {
+	ID       string    `json:"id"`
+	DID      string    `json:"did"`
+	Nickname string    `json:"nickname"`
+	Status   string    `json:"status"`
+	RoomID   string    `json:"roomId,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// RoomInfo 管理接口中的房间概要
+type RoomInfo struct {
+	ID                  string                     `json:"id"`
+	Name                string                     `json:"name"`
+	GameID              string                     `json:"gameId"`
+	MaxPlayers          int                        `json:"maxPlayers"`
+	Status              string                     `json:"status"`
+	Tick                uint64                     `json:"tick"`
+	Players             []PlayerInfo               `json:"players"`
+	RequiredCredentials []string                   `json:"requiredCredentials,omitempty"`
+	RequiredProofs      []vc.CredentialRequirement `json:"requiredProofs,omitempty"`
+	MinLevel            int                        `json:"minLevel,omitempty"`
+	PasswordProtected   bool                       `json:"passwordProtected,omitempty"`
+	Teams               []*Team                    `json:"teams,omitempty"`
+	Mode                string                     `json:"mode"`
+	OwnerID             string                     `json:"ownerId,omitempty"`
+	Group               string                     `json:"group,omitempty"`
+	Load                RoomLoad                   `json:"load"`
+	CreatedAt           time.Time                  `json:"createdAt"`
+}
+
+func playerInfo(player *Player) PlayerInfo {
+	info := PlayerInfo{
+		ID:       player.ID,
+		DID:      player.DID,
+		Nickname: player.Nickname,
+		Status:   player.Status,
+		LastSeen: player.LastSeen,
+	}
+	if room := player.Room; room != nil {
+		info.RoomID = room.ID
+	}
+	return info
+}
+
+// ConnectedPlayers 返回连接在本实例上的玩家
+func (s *SimpleServer) ConnectedPlayers() []PlayerInfo {
+	players := make([]PlayerInfo, 0)
+	for _, player := range s.players.all() {
+		if player.Connection != nil {
+			players = append(players, playerInfo(player))
+		}
+	}
+
+	sort.Slice(players, func(i, j int) bool { return players[i].Nickname < players[j].Nickname })
+	return players
+}
+
+// Rooms 返回本实例上的所有房间
+func (s *SimpleServer) Rooms() []RoomInfo {
+	s.roomMutex.RLock()
+	rooms := make([]*GameRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.roomMutex.RUnlock()
+
+	infos := make([]RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		infos = append(infos, roomInfo(room))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Room 返回房间详情
+func (s *SimpleServer) Room(roomID string) (*RoomInfo, error) {
+	s.roomMutex.RLock()
+	room, exists := s.rooms[roomID]
+	s.roomMutex.RUnlock()
+	if !exists {
+		return nil, ErrRoomNotFound
+	}
+
+	info := roomInfo(room)
+	return &info, nil
+}
+
+// CreateRoom 在本实例上创建房间并返回房间详情，房间已存在时返回已有房间；roomID 为空时生成随机 ID。
+// 启用分片且房间由其他实例托管时返回 *RoomRedirectError
+func (s *SimpleServer) CreateRoom(roomID, gameID string, options *RoomOptions) (*RoomInfo, error) {
+	if roomID == "" {
+		roomID = uuid.New().String()
+	}
+	if gameID == "" {
+		gameID = defaultGameID
+	}
+
+	room, err := s.getOrCreateRoom(roomID, gameID, options)
+	if err != nil {
+		return nil, err
+	}
+	info := roomInfo(room)
+	return &info, nil
+}
+
+func roomInfo(room *GameRoom) RoomInfo {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+
+	info := RoomInfo{
+		ID:                  room.ID,
+		Name:                room.Name,
+		GameID:              room.GameID,
+		MaxPlayers:          room.MaxPlayers,
+		Status:              room.GameState.Status,
+		Tick:                room.GameState.Tick,
+		Players:             make([]PlayerInfo, 0, len(room.Players)),
+		RequiredCredentials: room.RequiredCredentials,
+		RequiredProofs:      room.RequiredProofs,
+		MinLevel:            room.MinLevel,
+		PasswordProtected:   room.PasswordProtected,
+		Teams:               room.Teams,
+		Mode:                room.Mode,
+		OwnerID:             room.OwnerID,
+		Group:               room.Group,
+		CreatedAt:           room.CreatedAt,
+	}
+	info.Load.Players = len(room.Players)
+	info.Load.MessageRate, info.Load.TickUtilization = room.load.snapshot()
+	for _, player := range room.Players {
+		info.Players = append(info.Players, playerInfo(player))
+	}
+	return info
+}
+
+// findPlayerByDID 按 DID 查找本实例上的玩家
+func (s *SimpleServer) findPlayerByDID(playerDID string) *Player {
+	return s.players.getByDID(playerDID)
+}
+
+// KickPlayer 断开玩家连接并记录审计日志，玩家可以重新连接
+func (s *SimpleServer) KickPlayer(playerDID, reason, actor string) error {
+	if err := s.disconnectPlayer(playerDID, ErrCodeKicked, reason); err != nil {
+		return err
+	}
+
+	s.audit(AuditEntry{Action: AuditKick, Target: playerDID, Actor: actor, Reason: reason})
+	return nil
+}
+
+// disconnectPlayer 向玩家的所有连接发送错误后关闭连接
+func (s *SimpleServer) disconnectPlayer(playerDID string, code ErrorCode, reason string) error {
+	player := s.findPlayerByDID(playerDID)
+	if player == nil || player.Connection == nil {
+		return ErrPlayerNotConnected
+	}
+
+	if reason == "" {
+		reason = "disconnected by administrator"
+	}
+	// 被断开的玩家不能凭会话令牌重连
+	s.sessions.revoke(player.ID)
+	conns := []*websocket.Conn{player.Connection}
+	for _, entry := range s.connections.list(playerDID) {
+		if entry.conn != player.Connection {
+			conns = append(conns, entry.conn)
+		}
+	}
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	for _, conn := range conns {
+		s.sendError(conn, code, reason)
+		writeClose(conn, closeFrame, time.Now().Add(s.heartbeat.WriteWait))
+		// 关闭连接使读循环退出，由 handleConnection 完成断线清理
+		conn.Close()
+	}
+
+	slog.Info("Disconnected player", logging.KeyPlayerDID, playerDID, "code", code, "reason", reason)
+	return nil
+}
+
+// Announce 向房间内或本实例所有在线玩家发布公告，房间公告经消息总线送达其他实例
+func (s *SimpleServer) Announce(text, roomID string) error {
+	msg := Message{
+		Type:   MsgTypeAnnouncement,
+		RoomID: roomID,
+		Data: map[string]interface{}{
+			"message": text,
+		},
+		Timestamp: time.Now(),
+	}
+
+	if roomID != "" {
+		s.roomMutex.RLock()
+		room, exists := s.rooms[roomID]
+		s.roomMutex.RUnlock()
+		if !exists {
+			return ErrRoomNotFound
+		}
+		s.broadcastToRoom(room, msg, "")
+		return nil
+	}
+
+	for _, player := range s.players.all() {
+		if player.Connection != nil {
+			writeMessage(player.Connection, msg)
+		}
+	}
+	return nil
+}
+
+// SetMaintenance 开启或关闭游戏的维护模式。维护期间不能加入该游戏的房间或匹配，已在房间内的玩家不受影响
+func (s *SimpleServer) SetMaintenance(gameID string, enabled bool, message string) {
+	s.adminMutex.Lock()
+	defer s.adminMutex.Unlock()
+
+	if !enabled {
+		delete(s.maintenance, gameID)
+		slog.Info("Maintenance mode disabled", "game_id", gameID)
+		return
+	}
+
+	if message == "" {
+		message = "game " + gameID + " is under maintenance"
+	}
+	s.maintenance[gameID] = message
+	slog.Info("Maintenance mode enabled", "game_id", gameID)
+}
+
+// Maintenance 返回处于维护模式的游戏及提示信息
+func (s *SimpleServer) Maintenance() map[string]string {
+	s.adminMutex.RLock()
+	defer s.adminMutex.RUnlock()
+
+	games := make(map[string]string, len(s.maintenance))
+	for gameID, message := range s.maintenance {
+		games[gameID] = message
+	}
+	return games
+}
+
+// maintenanceFor 游戏处于维护模式时返回提示信息
+func (s *SimpleServer) maintenanceFor(gameID string) (string, bool) {
+	s.adminMutex.RLock()
+	defer s.adminMutex.RUnlock()
+
+	message, exists := s.maintenance[gameID]
+	return message, exists
+}
+
+// gameIDForRoom 返回房间所属的游戏，房间不存在时为加入后将创建的默认游戏
+func (s *SimpleServer) gameIDForRoom(roomID string) string {
+	s.roomMutex.RLock()
+	defer s.roomMutex.RUnlock()
+
+	if room, exists := s.rooms[roomID]; exists {
+		return room.GameID
+	}
+	return defaultGameID
+}
@@ -0,0 +1,526 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// AntiCheatAction 违规分数达到阈值时的处理
+type AntiCheatAction string
+
+const (
+	// AntiCheatFlag 在报告中标记玩家，供管理员复查
+	AntiCheatFlag AntiCheatAction = "flag"
+	// AntiCheatShadowLog 在不通知玩家的情况下记录其之后发送的每条消息
+	AntiCheatShadowLog AntiCheatAction = "shadow_log"
+	// AntiCheatKick 断开玩家连接
+	AntiCheatKick AntiCheatAction = "kick"
+	// AntiCheatBan 封禁玩家 BanDuration
+	AntiCheatBan AntiCheatAction = "ban"
+)
+
+// 违规类型
+const (
+	// ViolationSpeed 移动距离超过最大速度允许的距离
+	ViolationSpeed = "speed"
+	// ViolationActionRate 一个统计窗口内发送某类消息的次数超过上限
+	ViolationActionRate = "action_rate"
+	// ViolationTaskPrerequisite 玩家在加入房间后过早完成任务，或自己没有产生过任务目标对应的事件
+	ViolationTaskPrerequisite = "task_prerequisite"
+)
+
+// antiCheatActor 反作弊自动处罚在审计日志中的操作者
+const antiCheatActor = "anticheat"
+
+// AuditAntiCheatReset 管理员清除玩家的反作弊记录
+const AuditAntiCheatReset = "anticheat_reset"
+
+// ErrNoAntiCheatReport 玩家没有反作弊记录
+var ErrNoAntiCheatReport = errors.New("player has no anti-cheat report")
+
+// AntiCheatThreshold 分数达到 Score 时执行 Action，分数衰减到阈值以下后可再次触发
+type AntiCheatThreshold struct {
+	Score  float64         `json:"score"`
+	Action AntiCheatAction `json:"action"`
+}
+
+// AntiCheatConfig 反作弊配置
+type AntiCheatConfig struct {
+	Enabled bool
+	// Weights 违规类型 -> 每次违规增加的分数，不大于 0 时不检查该类违规
+	Weights map[string]float64
+	// SpeedTolerance 移动距离达到允许距离的该倍数才记为违规，低于该倍数的超速只做位置修正
+	SpeedTolerance float64
+	// RateWindow 消息频率的统计窗口
+	RateWindow time.Duration
+	// MaxRates 消息类型 -> 一个窗口内的最多消息数
+	MaxRates map[string]int
+	// MinObjectiveTime 完成任务目标中每个所需事件的最短时间，加入房间后完成任务的用时不能少于所需事件数乘以该时间
+	MinObjectiveTime time.Duration
+	// HalfLife 分数的半衰期，不大于 0 时分数不衰减
+	HalfLife   time.Duration
+	Thresholds []AntiCheatThreshold
+	// BanDuration ban 动作的封禁时长，0 表示永久
+	BanDuration time.Duration
+	// MaxViolations、MaxShadowLog 每个报告保留的最近违规和影子日志条数
+	MaxViolations int
+	MaxShadowLog  int
+}
+
+// DefaultAntiCheatConfig 返回默认反作弊配置：偶发的网络抖动或合作完成任务只累积少量分数，
+// 持续违规依次触发标记、影子日志、踢出和封禁
+func DefaultAntiCheatConfig() AntiCheatConfig {
+	return AntiCheatConfig{
+		Enabled: true,
+		Weights: map[string]float64{
+			ViolationSpeed:            2,
+			ViolationActionRate:       3,
+			ViolationTaskPrerequisite: 5,
+		},
+		SpeedTolerance: 1.5,
+		RateWindow:     10 * time.Second,
+		MaxRates: map[string]int{
+			MsgTypePlayerMove:   450,
+			MsgTypePlayerAction: 150,
+		},
+		MinObjectiveTime: 250 * time.Millisecond,
+		HalfLife:         10 * time.Minute,
+		Thresholds: []AntiCheatThreshold{
+			{Score: 10, Action: AntiCheatFlag},
+			{Score: 20, Action: AntiCheatShadowLog},
+			{Score: 40, Action: AntiCheatKick},
+			{Score: 80, Action: AntiCheatBan},
+		},
+		BanDuration:   24 * time.Hour,
+		MaxViolations: 50,
+		MaxShadowLog:  200,
+	}
+}
+
+// ParseAntiCheatAction 解析反作弊动作名称
+func ParseAntiCheatAction(name string) (AntiCheatAction, error) {
+	switch action := AntiCheatAction(name); action {
+	case AntiCheatFlag, AntiCheatShadowLog, AntiCheatKick, AntiCheatBan:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown anti-cheat action: %s", name)
+	}
+}
+
+// Violation 一次违规
+type Violation struct {
+	Type      string    `json:"type"`
+	Detail    string    `json:"detail"`
+	Score     float64   `json:"score"`
+	RoomID    string    `json:"roomId,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AntiCheatActionRecord 已执行的反作弊动作
+type AntiCheatActionRecord struct {
+	Action    AntiCheatAction `json:"action"`
+	Score     float64         `json:"score"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// ShadowLogEntry 影子日志中的一条玩家消息
+type ShadowLogEntry struct {
+	Type      string      `json:"type"`
+	RoomID    string      `json:"roomId,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// AntiCheatReport 玩家的反作弊报告，Score 为查询时衰减后的分数
+type AntiCheatReport struct {
+	DID          string                  `json:"did"`
+	PlayerID     string                  `json:"playerId"`
+	Score        float64                 `json:"score"`
+	Flagged      bool                    `json:"flagged"`
+	ShadowLogged bool                    `json:"shadowLogged"`
+	Violations   []Violation             `json:"violations"`
+	Actions      []AntiCheatActionRecord `json:"actions,omitempty"`
+	ShadowLog    []ShadowLogEntry        `json:"shadowLog,omitempty"`
+	UpdatedAt    time.Time               `json:"updatedAt"`
+}
+
+// cheatTracker 一名玩家的反作弊状态
+type cheatTracker struct {
+	report    AntiCheatReport
+	scoredAt  time.Time
+	triggered map[AntiCheatAction]bool
+
+	// 当前房间及加入后产生的事件数，键为 "类型" 和 "类型/目标"
+	roomID   string
+	joinedAt time.Time
+	events   map[string]int
+
+	// 当前统计窗口内按类型的消息数，每个窗口每类消息最多记一次违规
+	windowStart time.Time
+	messages    map[string]int
+	rateFlagged map[string]bool
+}
+
+// AntiCheat 按玩家 DID 累积违规分数，分数随时间衰减，达到阈值时返回需执行的动作。
+// 记录只保存在实例内存中
+type AntiCheat struct {
+	config   AntiCheatConfig
+	trackers map[string]*cheatTracker
+	mutex    sync.Mutex
+}
+
+// NewAntiCheat 创建反作弊模块
+func NewAntiCheat(config AntiCheatConfig) *AntiCheat {
+	thresholds := append([]AntiCheatThreshold(nil), config.Thresholds...)
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i].Score < thresholds[j].Score })
+	config.Thresholds = thresholds
+
+	return &AntiCheat{
+		config:   config,
+		trackers: make(map[string]*cheatTracker),
+	}
+}
+
+// tracker 返回玩家的反作弊状态，调用方需持有锁
+func (a *AntiCheat) tracker(player *Player) *cheatTracker {
+	tracker, exists := a.trackers[player.DID]
+	if !exists {
+		tracker = &cheatTracker{
+			report:    AntiCheatReport{DID: player.DID},
+			triggered: make(map[AntiCheatAction]bool),
+			events:    make(map[string]int),
+			messages:  make(map[string]int),
+		}
+		a.trackers[player.DID] = tracker
+	}
+	tracker.report.PlayerID = player.ID
+	return tracker
+}
+
+// decay 按半衰期衰减分数，并允许再次触发分数已回落到阈值以下的动作，调用方需持有锁
+func (a *AntiCheat) decay(tracker *cheatTracker, now time.Time) {
+	if a.config.HalfLife > 0 && !tracker.scoredAt.IsZero() {
+		elapsed := now.Sub(tracker.scoredAt)
+		tracker.report.Score *= math.Pow(0.5, elapsed.Seconds()/a.config.HalfLife.Seconds())
+	}
+	tracker.scoredAt = now
+
+	for _, threshold := range a.config.Thresholds {
+		if tracker.report.Score < threshold.Score {
+			delete(tracker.triggered, threshold.Action)
+		}
+	}
+}
+
+// checks 是否检查该类违规
+func (a *AntiCheat) checks(violationType string) bool {
+	return a.config.Enabled && a.config.Weights[violationType] > 0
+}
+
+// record 记录一次违规，返回分数新达到的阈值对应的动作
+func (a *AntiCheat) record(player *Player, violation Violation) []AntiCheatAction {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker := a.tracker(player)
+	a.decay(tracker, violation.Timestamp)
+
+	violation.Score = a.config.Weights[violation.Type]
+	report := &tracker.report
+	report.Score += violation.Score
+	report.UpdatedAt = violation.Timestamp
+	report.Violations = append(report.Violations, violation)
+	if limit := a.config.MaxViolations; limit > 0 && len(report.Violations) > limit {
+		report.Violations = report.Violations[len(report.Violations)-limit:]
+	}
+
+	var actions []AntiCheatAction
+	for _, threshold := range a.config.Thresholds {
+		if report.Score < threshold.Score || tracker.triggered[threshold.Action] {
+			continue
+		}
+		tracker.triggered[threshold.Action] = true
+		report.Actions = append(report.Actions, AntiCheatActionRecord{Action: threshold.Action, Score: report.Score, Timestamp: violation.Timestamp})
+		switch threshold.Action {
+		case AntiCheatFlag:
+			report.Flagged = true
+		case AntiCheatShadowLog:
+			report.ShadowLogged = true
+		}
+		actions = append(actions, threshold.Action)
+	}
+	return actions
+}
+
+// joined 玩家加入房间，重新开始统计房间内的事件
+func (a *AntiCheat) joined(player *Player, roomID string, now time.Time) {
+	if !a.config.Enabled {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker := a.tracker(player)
+	tracker.roomID = roomID
+	tracker.joinedAt = now
+	tracker.events = make(map[string]int)
+}
+
+// observeEvent 记录玩家在当前房间产生的游戏事件
+func (a *AntiCheat) observeEvent(player *Player, roomID, eventType, target string) {
+	if !a.config.Enabled {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker := a.tracker(player)
+	if tracker.roomID != roomID {
+		return
+	}
+	tracker.events[eventType]++
+	if target != "" {
+		tracker.events[eventType+"/"+target]++
+	}
+}
+
+// taskViolation 检查玩家完成任务的前提：加入房间后的用时足够产生所需的事件，且自己产生过每个目标对应的事件。
+// 满足时返回空字符串
+func (a *AntiCheat) taskViolation(player *Player, roomID string, task *Task, now time.Time) string {
+	if !a.config.Enabled {
+		return ""
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker := a.tracker(player)
+	if tracker.roomID != roomID {
+		return ""
+	}
+	required := 0
+	var missing []string
+	for _, objective := range task.Objectives {
+		required += objective.Required
+		key := objective.Type
+		if objective.Target != ObjectiveTargetAny && objective.Target != "" {
+			key += "/" + objective.Target
+		}
+		if tracker.events[key] == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf("task %s completed without player events: %s", task.ID, strings.Join(missing, ", "))
+	}
+	if elapsed := now.Sub(tracker.joinedAt); elapsed < time.Duration(required)*a.config.MinObjectiveTime {
+		return fmt.Sprintf("task %s with %d required events completed %dms after joining", task.ID, required, elapsed.Milliseconds())
+	}
+	return ""
+}
+
+// observeMessage 统计玩家发送的消息，当前窗口内某类消息首次超过 MaxRates 时返回违规说明
+func (a *AntiCheat) observeMessage(player *Player, msgType string, now time.Time) string {
+	limit, limited := a.config.MaxRates[msgType]
+	if !a.config.Enabled || !limited || a.config.RateWindow <= 0 {
+		return ""
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker := a.tracker(player)
+	if now.Sub(tracker.windowStart) >= a.config.RateWindow {
+		tracker.windowStart = now
+		tracker.messages = make(map[string]int)
+		tracker.rateFlagged = make(map[string]bool)
+	}
+	tracker.messages[msgType]++
+	if tracker.messages[msgType] <= limit || tracker.rateFlagged[msgType] {
+		return ""
+	}
+	tracker.rateFlagged[msgType] = true
+	return fmt.Sprintf("more than %d %s messages in %s", limit, msgType, a.config.RateWindow)
+}
+
+// shadowLog 玩家处于影子日志状态时记录消息
+func (a *AntiCheat) shadowLog(player *Player, msgType, roomID string, data interface{}) {
+	if !a.config.Enabled {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker, exists := a.trackers[player.DID]
+	if !exists || !tracker.report.ShadowLogged {
+		return
+	}
+	report := &tracker.report
+	report.ShadowLog = append(report.ShadowLog, ShadowLogEntry{Type: msgType, RoomID: roomID, Data: data, Timestamp: time.Now()})
+	if limit := a.config.MaxShadowLog; limit > 0 && len(report.ShadowLog) > limit {
+		report.ShadowLog = report.ShadowLog[len(report.ShadowLog)-limit:]
+	}
+}
+
+// forget 玩家断开连接时删除已无意义的状态：分数已衰减到接近 0 且没有被标记或影子日志
+func (a *AntiCheat) forget(playerDID string, now time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker, exists := a.trackers[playerDID]
+	if !exists {
+		return
+	}
+	a.decay(tracker, now)
+	if tracker.report.Score < 0.01 && !tracker.report.Flagged && !tracker.report.ShadowLogged {
+		delete(a.trackers, playerDID)
+	}
+}
+
+// snapshot 返回报告的副本，分数衰减到 now，调用方需持有锁
+func (a *AntiCheat) snapshot(tracker *cheatTracker, now time.Time) AntiCheatReport {
+	a.decay(tracker, now)
+	report := tracker.report
+	report.Violations = append([]Violation(nil), report.Violations...)
+	report.Actions = append([]AntiCheatActionRecord(nil), report.Actions...)
+	report.ShadowLog = append([]ShadowLogEntry(nil), report.ShadowLog...)
+	return report
+}
+
+// Reports 返回有违规记录的玩家报告，按分数从高到低排列，不包含影子日志
+func (a *AntiCheat) Reports() []AntiCheatReport {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	reports := make([]AntiCheatReport, 0)
+	for _, tracker := range a.trackers {
+		if len(tracker.report.Violations) == 0 {
+			continue
+		}
+		report := a.snapshot(tracker, now)
+		report.ShadowLog = nil
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Score > reports[j].Score })
+	return reports
+}
+
+// Report 返回玩家的完整报告
+func (a *AntiCheat) Report(playerDID string) (*AntiCheatReport, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker, exists := a.trackers[playerDID]
+	if !exists || len(tracker.report.Violations) == 0 {
+		return nil, ErrNoAntiCheatReport
+	}
+	report := a.snapshot(tracker, time.Now())
+	return &report, nil
+}
+
+// Reset 清除玩家的分数、违规、动作和影子日志，玩家没有报告时返回 false
+func (a *AntiCheat) Reset(playerDID string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tracker, exists := a.trackers[playerDID]
+	if !exists || len(tracker.report.Violations) == 0 {
+		return false
+	}
+	tracker.report = AntiCheatReport{DID: playerDID, PlayerID: tracker.report.PlayerID}
+	tracker.triggered = make(map[AntiCheatAction]bool)
+	return true
+}
+
+// SetAntiCheatConfig 替换反作弊配置并清除已有记录，应在接受连接前调用
+func (s *SimpleServer) SetAntiCheatConfig(config AntiCheatConfig) {
+	s.antiCheat = NewAntiCheat(config)
+}
+
+// AntiCheatReports 返回本实例上有违规记录的玩家报告
+func (s *SimpleServer) AntiCheatReports() []AntiCheatReport {
+	return s.antiCheat.Reports()
+}
+
+// AntiCheatReport 返回玩家的完整反作弊报告，包括影子日志
+func (s *SimpleServer) AntiCheatReport(playerDID string) (*AntiCheatReport, error) {
+	return s.antiCheat.Report(playerDID)
+}
+
+// ResetAntiCheat 清除玩家的反作弊记录并写入审计日志，已执行的踢出和封禁不受影响
+func (s *SimpleServer) ResetAntiCheat(playerDID, actor string) bool {
+	if !s.antiCheat.Reset(playerDID) {
+		return false
+	}
+	s.audit(AuditEntry{Action: AuditAntiCheatReset, Target: playerDID, Actor: actor})
+	return true
+}
+
+// reportViolation 记录玩家的违规并执行分数新达到的阈值对应的动作。调用方不能持有房间锁
+func (s *SimpleServer) reportViolation(player *Player, roomID, violationType, detail string) {
+	if !s.antiCheat.checks(violationType) {
+		return
+	}
+
+	violation := Violation{Type: violationType, Detail: detail, RoomID: roomID, Timestamp: time.Now()}
+	actions := s.antiCheat.record(player, violation)
+	antiCheatViolationsTotal.With(violationType).Inc()
+	player.log().Info("Anti-cheat violation", "violation", violationType, "detail", detail)
+
+	reason := fmt.Sprintf("anti-cheat: %s (%s)", violationType, detail)
+	for _, action := range actions {
+		antiCheatActionsTotal.With(string(action)).Inc()
+		switch action {
+		case AntiCheatFlag:
+			player.log().Warn("Player flagged by anti-cheat", "violation", violationType, "detail", detail)
+		case AntiCheatShadowLog:
+			player.log().Warn("Shadow logging player messages", "violation", violationType)
+		case AntiCheatKick:
+			if err := s.KickPlayer(player.DID, reason, antiCheatActor); err != nil && err != ErrPlayerNotConnected {
+				player.log().Error("Failed to kick player", logging.Err(err))
+			}
+		case AntiCheatBan:
+			if _, err := s.BanPlayer(player.DID, reason, s.antiCheat.config.BanDuration, antiCheatActor); err != nil {
+				slog.Error("Failed to ban player", logging.KeyPlayerDID, player.DID, logging.Err(err))
+			}
+		}
+	}
+}
+
+// checkMove 记录明显超出速度上限的移动，excess 为请求的移动距离与允许距离之比
+func (s *SimpleServer) checkMove(player *Player, roomID string, excess float64) {
+	if excess < s.antiCheat.config.SpeedTolerance {
+		return
+	}
+	s.reportViolation(player, roomID, ViolationSpeed, fmt.Sprintf("moved %.1fx the allowed distance", excess))
+}
+
+// checkTaskCompletion 检查玩家完成任务的前提
+func (s *SimpleServer) checkTaskCompletion(room *GameRoom, player *Player, task *Task) {
+	if detail := s.antiCheat.taskViolation(player, room.ID, task, time.Now()); detail != "" {
+		s.reportViolation(player, room.ID, ViolationTaskPrerequisite, detail)
+	}
+}
+
+// checkMessageRate 统计玩家发送的消息，持续超出频率上限时记录违规
+func (s *SimpleServer) checkMessageRate(player *Player, msgType string) {
+	if detail := s.antiCheat.observeMessage(player, msgType, time.Now()); detail != "" {
+		roomID := ""
+		if player.Room != nil {
+			roomID = player.Room.ID
+		}
+		s.reportViolation(player, roomID, ViolationActionRate, detail)
+	}
+}
@@ -0,0 +1,203 @@
+package game
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MsgTypeBatch 合并窗口内发给玩家的多条房间广播，data.messages 按发送顺序排列
+const MsgTypeBatch = "batch"
+
+// CoalesceRule 同一批次中同类型消息的合并方式
+type CoalesceRule string
+
+const (
+	// CoalesceAppend 依次保留每条消息
+	CoalesceAppend CoalesceRule = "append"
+	// CoalesceLatest 合并为一条消息：state_delta 和 physics 中同一玩家或物体的位置以最新的为准，
+	// 其他类型只保留最新的消息
+	CoalesceLatest CoalesceRule = "latest"
+)
+
+// BatchConfig 房间广播的合并配置
+type BatchConfig struct {
+	// Window 合并窗口，窗口内发给同一玩家的房间广播合并为一条 batch 消息，0 表示逐条发送
+	Window time.Duration
+	// MaxMessages 批次达到该条数时立即发送，不大于 0 时只按窗口发送
+	MaxMessages int
+	// Rules 消息类型 -> 合并方式，未列出的类型按 append 处理
+	Rules map[string]CoalesceRule
+}
+
+// DefaultBatchConfig 返回默认合并配置：窗口为一个 tick，位置更新合并，聊天等事件依次保留
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		Window:      time.Second / DefaultTickRate,
+		MaxMessages: 64,
+		Rules: map[string]CoalesceRule{
+			MsgTypeStateDelta: CoalesceLatest,
+			MsgTypePhysics:    CoalesceLatest,
+			MsgTypeChat:       CoalesceAppend,
+		},
+	}
+}
+
+// SetBatchConfig 替换房间广播的合并配置，应在接受连接前调用
+func (s *SimpleServer) SetBatchConfig(config BatchConfig) {
+	s.batching = config
+}
+
+// Batch 合并后发送的房间广播
+type Batch struct {
+	Messages []Message `json:"messages"`
+}
+
+// broadcastOutbox 玩家在当前合并窗口内待发送的房间广播，由 Player.outboxMutex 保护
+type broadcastOutbox struct {
+	messages []Message
+	timer    *time.Timer
+}
+
+// queueBroadcast 将房间广播加入玩家的待发送队列，窗口结束或队列已满时发送；未启用合并时直接发送
+func (s *SimpleServer) queueBroadcast(player *Player, msg Message) {
+	if s.batching.Window <= 0 {
+		s.deliverOrBuffer(player, msg)
+		return
+	}
+
+	player.outboxMutex.Lock()
+	outbox := &player.outbox
+	outbox.messages = s.coalesce(outbox.messages, msg)
+	full := s.batching.MaxMessages > 0 && len(outbox.messages) >= s.batching.MaxMessages
+	if !full && outbox.timer == nil {
+		outbox.timer = time.AfterFunc(s.batching.Window, func() { s.flushBroadcasts(player) })
+	}
+	player.outboxMutex.Unlock()
+
+	if full {
+		s.flushBroadcasts(player)
+	}
+}
+
+// coalesce 按合并规则将消息加入队列。合并后的消息移到队尾，保持与其他消息的先后顺序
+func (s *SimpleServer) coalesce(queued []Message, msg Message) []Message {
+	if s.batching.Rules[msg.Type] != CoalesceLatest {
+		return append(queued, msg)
+	}
+
+	for i := len(queued) - 1; i >= 0; i-- {
+		if queued[i].Type != msg.Type || queued[i].RoomID != msg.RoomID {
+			continue
+		}
+		merged, ok := mergeLatest(queued[i], msg)
+		if !ok {
+			break
+		}
+		broadcastsCoalesced.With(msg.Type).Inc()
+		queued = append(queued[:i], queued[i+1:]...)
+		return append(queued, merged)
+	}
+	return append(queued, msg)
+}
+
+// flushBroadcasts 发送玩家队列中的房间广播，多条消息合并为一条 batch 消息
+func (s *SimpleServer) flushBroadcasts(player *Player) {
+	player.outboxMutex.Lock()
+	messages := player.outbox.messages
+	if player.outbox.timer != nil {
+		player.outbox.timer.Stop()
+	}
+	player.outbox = broadcastOutbox{}
+	player.outboxMutex.Unlock()
+
+	player.missedMutex.Lock()
+	connected := player.Connection != nil
+	player.missedMutex.Unlock()
+
+	// 断线的玩家逐条缓存，以便补发时仍能跳过会被完整状态取代的位置更新
+	if len(messages) <= 1 || !connected {
+		for _, msg := range messages {
+			s.deliverOrBuffer(player, msg)
+		}
+		return
+	}
+
+	s.deliverOrBuffer(player, Message{
+		Type:      MsgTypeBatch,
+		PlayerID:  player.ID,
+		Data:      Batch{Messages: messages},
+		Timestamp: time.Now(),
+	})
+}
+
+// mergeLatest 将同类型的两条消息合并为一条，next 中的字段优先。无法解析载荷时返回 false，两条消息都保留
+func mergeLatest(prev, next Message) (Message, bool) {
+	switch next.Type {
+	case MsgTypeStateDelta:
+		var older, newer StateDelta
+		if !decodeData(prev.Data, &older) || !decodeData(next.Data, &newer) {
+			return Message{}, false
+		}
+		next.Data = mergeStateDeltas(&older, &newer)
+	case MsgTypePhysics:
+		var older, newer PhysicsUpdate
+		if !decodeData(prev.Data, &older) || !decodeData(next.Data, &newer) {
+			return Message{}, false
+		}
+		next.Data = mergePhysicsUpdates(&older, &newer)
+	}
+	return next, true
+}
+
+// mergeStateDeltas 合并两个位置增量，同一玩家的位置以 newer 为准。
+// 返回新的增量，不修改参数，参数可能被其他玩家的队列共享
+func mergeStateDeltas(older, newer *StateDelta) *StateDelta {
+	merged := &StateDelta{Tick: newer.Tick, Players: make(map[string]Position)}
+	for playerID, position := range older.Players {
+		merged.Players[playerID] = position
+	}
+	removed := make(map[string]bool)
+	for _, playerID := range older.Removed {
+		removed[playerID] = true
+	}
+	for playerID, position := range newer.Players {
+		merged.Players[playerID] = position
+		delete(removed, playerID)
+	}
+	for _, playerID := range newer.Removed {
+		delete(merged.Players, playerID)
+		removed[playerID] = true
+	}
+	for playerID := range removed {
+		merged.Removed = append(merged.Removed, playerID)
+	}
+	return merged
+}
+
+// mergePhysicsUpdates 合并两个物理更新：物体位置以 newer 为准，弹道取 newer 中飞行的弹道，
+// 消失的弹道和碰撞依次保留。返回新的更新，不修改参数
+func mergePhysicsUpdates(older, newer *PhysicsUpdate) *PhysicsUpdate {
+	merged := &PhysicsUpdate{
+		Tick:        newer.Tick,
+		Objects:     make(map[string]Position),
+		Projectiles: newer.Projectiles,
+		Removed:     append(append([]string(nil), older.Removed...), newer.Removed...),
+		Collisions:  append(append([]*Collision(nil), older.Collisions...), newer.Collisions...),
+	}
+	for objectID, position := range older.Objects {
+		merged.Objects[objectID] = position
+	}
+	for objectID, position := range newer.Objects {
+		merged.Objects[objectID] = position
+	}
+	return merged
+}
+
+// decodeData 将消息载荷转换为 v。本实例的载荷是具体类型，经消息总线转发的载荷是 JSON 解码后的 map
+func decodeData(data interface{}, v interface{}) bool {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(encoded, v) == nil
+}
@@ -0,0 +1,155 @@
+package game
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// 消息总线主题
+const (
+	busTopicRoom     = "room"     // 房间广播（含聊天、玩家状态和位置快照）
+	busTopicPresence = "presence" // 玩家在线状态变化
+	busTopicWhisper  = "whisper"  // 发给其他实例上玩家的私聊
+	busTopicGuild    = "guild"    // 发给其他实例上公会成员的公会聊天和成员变化
+	busTopicInbox    = "inbox"    // 通知其他实例推送其连接的玩家收到的收件箱消息
+	// busTopicSession 见 connections.go
+)
+
+// MessageBus 跨实例的消息总线，实例通过它将房间广播和在线状态扇出给其他实例上连接的玩家
+type MessageBus interface {
+	// Publish 向主题发布消息
+	Publish(topic string, data []byte) error
+	// Subscribe 注册主题的处理函数，同一实例发布的消息也会被收到
+	Subscribe(topic string, handler func(data []byte)) error
+	// Close 取消所有订阅并释放资源
+	Close() error
+}
+
+// roomBroadcast 总线上的房间广播
+type roomBroadcast struct {
+	Origin          string  `json:"origin"`
+	RoomID          string  `json:"roomId"`
+	TeamID          string  `json:"teamId,omitempty"`
+	ExcludePlayerID string  `json:"excludePlayerId,omitempty"`
+	Message         Message `json:"message"`
+}
+
+// presenceUpdate 总线上的在线状态变化
+type presenceUpdate struct {
+	Origin   string    `json:"origin"`
+	PlayerID string    `json:"playerId"`
+	Status   string    `json:"status"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// SetMessageBus 设置消息总线并订阅其他实例的房间广播和在线状态，需在处理连接前调用
+func (s *SimpleServer) SetMessageBus(bus MessageBus) error {
+	if s.instanceID == "" {
+		s.instanceID = uuid.New().String()
+	}
+
+	if err := bus.Subscribe(busTopicRoom, s.handleRoomBroadcast); err != nil {
+		return err
+	}
+	if err := bus.Subscribe(busTopicPresence, s.handlePresenceUpdate); err != nil {
+		return err
+	}
+	if err := bus.Subscribe(busTopicWhisper, s.handleWhisperBroadcast); err != nil {
+		return err
+	}
+	if err := bus.Subscribe(busTopicGuild, s.handleGuildBroadcast); err != nil {
+		return err
+	}
+	if err := bus.Subscribe(busTopicInbox, s.handleInboxNotice); err != nil {
+		return err
+	}
+	if err := bus.Subscribe(busTopicSession, s.handleSessionTakeover); err != nil {
+		return err
+	}
+
+	s.bus = bus
+	return nil
+}
+
+// publish 向总线发布消息，未配置总线时不做处理
+func (s *SimpleServer) publish(topic string, v interface{}) {
+	if s.bus == nil {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to encode message", "topic", topic, logging.Err(err))
+		return
+	}
+	if err := s.bus.Publish(topic, data); err != nil {
+		slog.Error("Failed to publish message", "topic", topic, logging.Err(err))
+	}
+}
+
+// publishRoom 将房间广播转发给其他实例
+func (s *SimpleServer) publishRoom(roomID string, msg Message, excludePlayerID string) {
+	s.publish(busTopicRoom, roomBroadcast{
+		Origin:          s.instanceID,
+		RoomID:          roomID,
+		ExcludePlayerID: excludePlayerID,
+		Message:         msg,
+	})
+}
+
+// publishPresence 将玩家在线状态变化通知其他实例
+func (s *SimpleServer) publishPresence(player *Player) {
+	s.publish(busTopicPresence, presenceUpdate{
+		Origin:   s.instanceID,
+		PlayerID: player.ID,
+		Status:   player.Status,
+		LastSeen: player.LastSeen,
+	})
+}
+
+// handleRoomBroadcast 将其他实例的房间广播发送给本实例上的房间玩家
+func (s *SimpleServer) handleRoomBroadcast(data []byte) {
+	var broadcast roomBroadcast
+	if err := json.Unmarshal(data, &broadcast); err != nil {
+		slog.Warn("Invalid room broadcast on message bus", logging.Err(err))
+		return
+	}
+	if broadcast.Origin == s.instanceID {
+		return
+	}
+
+	s.roomMutex.RLock()
+	room, exists := s.rooms[broadcast.RoomID]
+	s.roomMutex.RUnlock()
+	if !exists {
+		return
+	}
+
+	s.deliverToLocalPlayers(room, broadcast.Message, broadcast.ExcludePlayerID, broadcast.TeamID)
+}
+
+// handlePresenceUpdate 同步其他实例上玩家的在线状态，
+// 玩家连接在本实例或仍占据本实例房间位置时以本地状态为准，避免阻止空闲回收
+func (s *SimpleServer) handlePresenceUpdate(data []byte) {
+	var update presenceUpdate
+	if err := json.Unmarshal(data, &update); err != nil {
+		slog.Warn("Invalid presence update on message bus", logging.Err(err))
+		return
+	}
+	if update.Origin == s.instanceID {
+		return
+	}
+
+	player, exists := s.players.get(update.PlayerID)
+	if !exists || player.Connection != nil || player.Room != nil {
+		return
+	}
+
+	player.Status = update.Status
+	player.LastSeen = update.LastSeen
+}
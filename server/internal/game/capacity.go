@@ -0,0 +1,467 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/internal/i18n"
+)
+
+// MsgTypeRoomQueue 房间组已满时玩家的排队状态
+const MsgTypeRoomQueue = "room_queue"
+
+// 房间组已满时的溢出策略
+const (
+	OverflowQueue    = "queue"    // 排队等待同组房间空出位置
+	OverflowReject   = "reject"   // 拒绝加入
+	OverflowRedirect = "redirect" // 超过软上限加入同组中人数最少、未达到人数上限的房间
+)
+
+// 排队状态
+const (
+	RoomQueueQueued    = "queued"    // 排队中，position 为当前位置（从 1 开始）
+	RoomQueueCancelled = "cancelled" // 玩家离开队列
+)
+
+// roomLoadWindow 房间负载的统计窗口
+const roomLoadWindow = time.Second
+
+// CapacityPolicy 游戏的房间容量策略：房间达到软上限或负载过高时，新加入的玩家分流到自动创建的同组房间
+// （如 default-2、default-3），同组房间都已满时按溢出策略处理
+type CapacityPolicy struct {
+	SoftCap int `json:"softCap,omitempty"` // 房间人数达到该值后分流，0 表示不按人数分流
+	// MaxLoad tick 处理耗时占 tick 间隔的比例上限（0-1），近似房间占用的 CPU，0 表示不检查
+	MaxLoad float64 `json:"maxLoad,omitempty"`
+	// MaxMessageRate 房间每秒收到的消息数上限，0 表示不检查
+	MaxMessageRate float64 `json:"maxMessageRate,omitempty"`
+	MaxRooms       int     `json:"maxRooms"`           // 同组房间数上限，包括原房间
+	Overflow       string  `json:"overflow"`           // queue、reject 或 redirect
+	MaxQueue       int     `json:"maxQueue,omitempty"` // 每个房间组的排队人数上限，排满后拒绝
+}
+
+// DefaultCapacityPolicy 返回默认游戏的容量策略
+func DefaultCapacityPolicy() *CapacityPolicy {
+	return &CapacityPolicy{
+		SoftCap:  8,
+		MaxLoad:  0.8,
+		MaxRooms: 10,
+		Overflow: OverflowQueue,
+		MaxQueue: 50,
+	}
+}
+
+// validate 校验策略
+func (p *CapacityPolicy) validate() error {
+	switch p.Overflow {
+	case OverflowQueue, OverflowReject, OverflowRedirect:
+	default:
+		return fmt.Errorf("unsupported overflow policy: %s", p.Overflow)
+	}
+	if p.SoftCap < 0 || p.MaxLoad < 0 || p.MaxMessageRate < 0 || p.MaxQueue < 0 {
+		return fmt.Errorf("capacity limits must not be negative")
+	}
+	if p.MaxRooms < 1 {
+		return fmt.Errorf("maxRooms must be at least 1")
+	}
+	return nil
+}
+
+// enabled 是否设置了任一分流条件
+func (p *CapacityPolicy) enabled() bool {
+	return p != nil && (p.SoftCap > 0 || p.MaxLoad > 0 || p.MaxMessageRate > 0)
+}
+
+// RoomLoad 房间在最近一个统计窗口内的负载
+type RoomLoad struct {
+	Players     int     `json:"players"`
+	MessageRate float64 `json:"messageRate"` // 每秒收到的消息数
+	// TickUtilization tick 处理耗时占窗口时长的比例，近似房间占用的 CPU
+	TickUtilization float64 `json:"tickUtilization"`
+}
+
+// roomLoad 统计房间收到的消息和 tick 耗时，每个窗口结束时更新负载
+type roomLoad struct {
+	mutex       sync.Mutex
+	windowStart time.Time
+	messages    int
+	busy        time.Duration
+
+	messageRate float64
+	utilization float64
+}
+
+// message 记录一条发给房间的消息
+func (l *roomLoad) message() {
+	l.mutex.Lock()
+	l.messages++
+	l.mutex.Unlock()
+}
+
+// recordTick 记录一个 tick 的处理耗时
+func (l *roomLoad) recordTick(start time.Time, elapsed time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.windowStart.IsZero() {
+		l.windowStart = start
+	}
+	l.busy += elapsed
+
+	end := start.Add(elapsed)
+	if window := end.Sub(l.windowStart); window >= roomLoadWindow {
+		l.messageRate = float64(l.messages) / window.Seconds()
+		l.utilization = l.busy.Seconds() / window.Seconds()
+		l.windowStart = end
+		l.messages = 0
+		l.busy = 0
+	}
+}
+
+// snapshot 返回最近一个窗口的消息速率和 tick 耗时比例
+func (l *roomLoad) snapshot() (float64, float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.messageRate, l.utilization
+}
+
+// queuedJoin 等待同组房间空出位置的加入请求
+type queuedJoin struct {
+	player  *Player
+	payload *JoinRoomPayload
+}
+
+// overflowQueues 按房间组排队的玩家，每名玩家同时只在一个队列中
+type overflowQueues struct {
+	mutex   sync.Mutex
+	waiting map[string][]*queuedJoin
+}
+
+func newOverflowQueues() *overflowQueues {
+	return &overflowQueues{waiting: make(map[string][]*queuedJoin)}
+}
+
+// push 将玩家加入房间组的队尾并返回位置，队列已满时返回 false
+func (q *overflowQueues) push(group string, player *Player, payload *JoinRoomPayload, limit int) (int, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	waiting := q.waiting[group]
+	if limit > 0 && len(waiting) >= limit {
+		return 0, false
+	}
+	q.waiting[group] = append(waiting, &queuedJoin{player: player, payload: payload})
+	return len(waiting) + 1, true
+}
+
+// remove 将玩家移出所在的队列，返回原来的房间组
+func (q *overflowQueues) remove(playerID string) (string, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for group, waiting := range q.waiting {
+		for i, join := range waiting {
+			if join.player.ID != playerID {
+				continue
+			}
+			waiting = append(waiting[:i:i], waiting[i+1:]...)
+			if len(waiting) == 0 {
+				delete(q.waiting, group)
+			} else {
+				q.waiting[group] = waiting
+			}
+			return group, true
+		}
+	}
+	return "", false
+}
+
+// pop 取出房间组队首的玩家
+func (q *overflowQueues) pop(group string) *queuedJoin {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	waiting := q.waiting[group]
+	if len(waiting) == 0 {
+		return nil
+	}
+	if len(waiting) == 1 {
+		delete(q.waiting, group)
+	} else {
+		q.waiting[group] = waiting[1:]
+	}
+	return waiting[0]
+}
+
+// queued 返回房间组当前排队的玩家
+func (q *overflowQueues) queued(group string) []*Player {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	players := make([]*Player, 0, len(q.waiting[group]))
+	for _, join := range q.waiting[group] {
+		players = append(players, join.player)
+	}
+	return players
+}
+
+// SetCapacityPolicy 设置游戏的房间容量策略，nil 表示不分流，应在接受连接前调用
+func (s *SimpleServer) SetCapacityPolicy(gameID string, policy *CapacityPolicy) error {
+	s.gamesMutex.Lock()
+	defer s.gamesMutex.Unlock()
+
+	info, ok := s.games[gameID]
+	if !ok {
+		return fmt.Errorf("game not found: %s", gameID)
+	}
+	if policy != nil {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+	}
+	info.Settings.Capacity = policy
+	return nil
+}
+
+// capacityPolicy 返回游戏启用的容量策略，未启用时返回 nil
+func (s *SimpleServer) capacityPolicy(gameID string) *CapacityPolicy {
+	info, ok := s.Game(gameID)
+	if !ok || !info.Settings.Capacity.enabled() {
+		return nil
+	}
+	return info.Settings.Capacity
+}
+
+// placeInRoomGroup 按游戏的容量策略为加入 room 的玩家选择房间：room 达到软上限或负载过高时分流到同组房间，
+// 同组房间都已满时按溢出策略排队、拒绝或超过软上限加入。返回 nil 时已通知玩家
+func (s *SimpleServer) placeInRoomGroup(player *Player, room *GameRoom, payload *JoinRoomPayload) *GameRoom {
+	policy := s.capacityPolicy(room.GameID)
+	// 有密码的房间不分流，已在房间内的玩家重新加入时不受限制
+	if policy == nil || room.PasswordProtected || player.Room == room {
+		return room
+	}
+
+	target, err := s.roomWithCapacity(room, policy)
+	var redirect *RoomRedirectError
+	if errors.As(err, &redirect) {
+		s.sendRoomRedirect(player, redirect)
+		return nil
+	}
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeJoinFailed, "error.create_overflow_room", i18n.Params{"error": err})
+		return nil
+	}
+	if target != nil {
+		if target != room {
+			roomOverflowTotal.With("spilled").Inc()
+			player.log().Info("Player spilled into sibling room", "requested_room", room.ID, "room", target.ID)
+		}
+		return target
+	}
+
+	group := room.group()
+	switch policy.Overflow {
+	case OverflowRedirect:
+		if target := s.leastPopulatedRoom(group); target != nil {
+			roomOverflowTotal.With("redirected").Inc()
+			return target
+		}
+	case OverflowQueue:
+		if position, ok := s.overflow.push(group, player, payload, policy.MaxQueue); ok {
+			roomOverflowTotal.With("queued").Inc()
+			s.sendRoomQueue(player, group, RoomQueueQueued, position)
+			player.log().Info("Player queued for full room group", "room_group", group, "position", position)
+			return nil
+		}
+	}
+
+	roomOverflowTotal.With("rejected").Inc()
+	s.sendEntryRejection(player, ErrCodeEntryRejected, room.ID, &EntryRejection{
+		Reason:  RejectRoomFull,
+		Message: "room and its overflow rooms are full",
+		Details: map[string]interface{}{"group": group},
+	})
+	return nil
+}
+
+// roomWithCapacity 返回同组中未达到软上限和负载上限的房间，优先 room 本身；
+// 都已满且同组房间数未达上限时创建下一个同组房间，否则返回 nil
+func (s *SimpleServer) roomWithCapacity(room *GameRoom, policy *CapacityPolicy) (*GameRoom, error) {
+	if !s.overCapacity(room, policy) {
+		return room, nil
+	}
+
+	group := room.group()
+	rooms := s.groupRooms(group)
+	for _, candidate := range rooms {
+		if !s.overCapacity(candidate, policy) {
+			return candidate, nil
+		}
+	}
+	if len(rooms) >= policy.MaxRooms {
+		return nil, nil
+	}
+
+	var siblingID string
+	s.roomMutex.RLock()
+	for n := 2; ; n++ {
+		siblingID = fmt.Sprintf("%s-%d", group, n)
+		if _, exists := s.rooms[siblingID]; !exists {
+			break
+		}
+	}
+	s.roomMutex.RUnlock()
+
+	room.mutex.RLock()
+	options := &RoomOptions{
+		RequiredCredentials: room.RequiredCredentials,
+		RequiredProofs:      room.RequiredProofs,
+		MinLevel:            room.MinLevel,
+		Teams:               len(room.Teams),
+		Mode:                room.Mode,
+		Group:               group,
+	}
+	room.mutex.RUnlock()
+
+	return s.getOrCreateRoom(siblingID, room.GameID, options)
+}
+
+// overCapacity 房间是否达到软上限、人数上限或负载上限
+func (s *SimpleServer) overCapacity(room *GameRoom, policy *CapacityPolicy) bool {
+	room.mutex.RLock()
+	members := s.roomMemberCount(room)
+	maxPlayers := room.MaxPlayers
+	room.mutex.RUnlock()
+
+	if members >= maxPlayers || (policy.SoftCap > 0 && members >= policy.SoftCap) {
+		return true
+	}
+	messageRate, utilization := room.load.snapshot()
+	return (policy.MaxLoad > 0 && utilization >= policy.MaxLoad) ||
+		(policy.MaxMessageRate > 0 && messageRate >= policy.MaxMessageRate)
+}
+
+// groupRooms 返回本实例上同组的房间，原房间在前，其余按创建时间排列
+func (s *SimpleServer) groupRooms(group string) []*GameRoom {
+	s.roomMutex.RLock()
+	var rooms []*GameRoom
+	for _, room := range s.rooms {
+		if room.group() == group {
+			rooms = append(rooms, room)
+		}
+	}
+	s.roomMutex.RUnlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		if (rooms[i].ID == group) != (rooms[j].ID == group) {
+			return rooms[i].ID == group
+		}
+		return rooms[i].CreatedAt.Before(rooms[j].CreatedAt)
+	})
+	return rooms
+}
+
+// leastPopulatedRoom 返回同组中人数最少且未达到人数上限的房间
+func (s *SimpleServer) leastPopulatedRoom(group string) *GameRoom {
+	var (
+		target *GameRoom
+		fewest int
+	)
+	for _, room := range s.groupRooms(group) {
+		room.mutex.RLock()
+		members := s.roomMemberCount(room)
+		full := members >= room.MaxPlayers
+		room.mutex.RUnlock()
+		if !full && (target == nil || members < fewest) {
+			target, fewest = room, members
+		}
+	}
+	return target
+}
+
+// admitQueued 房间组有玩家离开后，按顺序让排队的玩家重新加入
+func (s *SimpleServer) admitQueued(group string) {
+	for len(s.overflow.queued(group)) > 0 {
+		if !s.groupHasCapacity(group) {
+			return
+		}
+		join := s.overflow.pop(group)
+		if join == nil {
+			return
+		}
+		s.notifyQueuePositions(group)
+		if join.player.Connection == nil {
+			continue
+		}
+		join.player.log().Info("Admitting queued player", "room_group", group)
+		s.handleJoinRoom(join.player, join.payload)
+	}
+}
+
+// groupHasCapacity 房间组是否能再接纳一名玩家：原房间已删除、有房间未满或还能创建同组房间
+func (s *SimpleServer) groupHasCapacity(group string) bool {
+	rooms := s.groupRooms(group)
+	if len(rooms) == 0 || rooms[0].ID != group {
+		return true
+	}
+	policy := s.capacityPolicy(rooms[0].GameID)
+	if policy == nil || len(rooms) < policy.MaxRooms {
+		return true
+	}
+	for _, room := range rooms {
+		if !s.overCapacity(room, policy) {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelQueuedJoin 将玩家移出排队，加入其他房间、离开房间或断开连接时调用
+func (s *SimpleServer) cancelQueuedJoin(player *Player) {
+	group, removed := s.overflow.remove(player.ID)
+	if !removed {
+		return
+	}
+	if player.Connection != nil {
+		s.sendRoomQueue(player, group, RoomQueueCancelled, 0)
+	}
+	s.notifyQueuePositions(group)
+}
+
+// notifyQueuePositions 向房间组中排队的玩家推送新的位置
+func (s *SimpleServer) notifyQueuePositions(group string) {
+	for i, player := range s.overflow.queued(group) {
+		s.sendRoomQueue(player, group, RoomQueueQueued, i+1)
+	}
+}
+
+// sendRoomQueue 推送玩家的排队状态
+func (s *SimpleServer) sendRoomQueue(player *Player, group, status string, position int) {
+	if player.Connection == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"status": status,
+		"roomId": group,
+	}
+	if position > 0 {
+		data["position"] = position
+	}
+	writeMessage(player.Connection, Message{
+		Type:      MsgTypeRoomQueue,
+		PlayerID:  player.ID,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// group 返回房间所在的房间组
+func (r *GameRoom) group() string {
+	if r.Group != "" {
+		return r.Group
+	}
+	return r.ID
+}
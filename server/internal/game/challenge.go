@@ -0,0 +1,98 @@
+package game
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+)
+
+// MsgTypeAuthChallenge 申请认证 nonce。auth 和 upgrade_guest 必须附带 DID 认证密钥对该 nonce 的签名
+const MsgTypeAuthChallenge = "auth_challenge"
+
+// authChallengeTTL 认证 nonce 的有效期
+const authChallengeTTL = time.Minute
+
+// errAuthNonce nonce 缺失、过期或已使用
+var errAuthNonce = errors.New("auth nonce is missing, expired or already used, request a new auth_challenge")
+
+// authChallenge 连接上最近颁发的认证 nonce，新的申请覆盖之前的 nonce
+type authChallenge struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+// DIDProof 证明连接持有 DID 认证密钥：KeyID 是 DID 文档 authentication 中的验证方法，
+// Signature 是该密钥对 auth_challenge 下发的 Nonce 的 hex 编码 Ed25519 签名
+type DIDProof struct {
+	KeyID     string `json:"keyId"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// validate 校验证明字段
+func (p *DIDProof) validate(v *ValidationError) {
+	if p.KeyID == "" {
+		v.add("keyId", "is required")
+	}
+	if p.Nonce == "" {
+		v.add("nonce", "is required, request one with auth_challenge")
+	}
+	if p.Signature == "" {
+		v.add("signature", "is required")
+	}
+}
+
+// issueAuthChallenge 为连接生成一次性 nonce 并发送给客户端
+func (s *SimpleServer) issueAuthChallenge(conn *websocket.Conn, challenge *authChallenge) {
+	challenge.nonce = uuid.New().String()
+	challenge.expiresAt = time.Now().Add(authChallengeTTL)
+
+	writeMessage(conn, Message{
+		Type: MsgTypeAuthChallenge,
+		Data: map[string]interface{}{
+			"nonce":     challenge.nonce,
+			"expiresIn": int(authChallengeTTL.Seconds()),
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// verifyDIDProof 消耗连接的 nonce，并校验 proof 是 doc 的认证密钥对该 nonce 的签名。
+// nonce 无论校验是否通过都只能使用一次
+func verifyDIDProof(challenge *authChallenge, doc *pkgdid.DIDDocument, proof *DIDProof) error {
+	issued := *challenge
+	*challenge = authChallenge{}
+	if issued.nonce == "" || proof.Nonce != issued.nonce || time.Now().After(issued.expiresAt) {
+		return errAuthNonce
+	}
+
+	if keyDID, _, _ := strings.Cut(proof.KeyID, "#"); keyDID != doc.ID {
+		return fmt.Errorf("key %s does not belong to %s", proof.KeyID, doc.ID)
+	}
+	method, ok := doc.AuthenticationKey(proof.KeyID)
+	if !ok {
+		return fmt.Errorf("%s is not an authentication key", proof.KeyID)
+	}
+	publicKey, err := method.CryptoPublicKey()
+	if err != nil {
+		return err
+	}
+	edKey, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported key type %T", publicKey)
+	}
+
+	signature, err := hex.DecodeString(proof.Signature)
+	if err != nil || !ed25519.Verify(edKey, []byte(proof.Nonce), signature) {
+		return errors.New("signature does not match the auth nonce")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package game
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+)
+
+// newProofIdentity 生成 did:key 文档和签名其认证 nonce 的函数
+func newProofIdentity(t *testing.T) (*pkgdid.DIDDocument, func(nonce string) *DIDProof) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := pkgdid.KeyDIDDocument(pkgdid.NewKeyDID(publicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc, func(nonce string) *DIDProof {
+		return &DIDProof{
+			KeyID:     doc.Authentication[0],
+			Nonce:     nonce,
+			Signature: hex.EncodeToString(ed25519.Sign(privateKey, []byte(nonce))),
+		}
+	}
+}
+
+func TestVerifyDIDProof(t *testing.T) {
+	doc, sign := newProofIdentity(t)
+	_, signOther := newProofIdentity(t)
+
+	issue := func() *authChallenge {
+		return &authChallenge{nonce: "nonce-1", expiresAt: time.Now().Add(authChallengeTTL)}
+	}
+
+	challenge := issue()
+	if err := verifyDIDProof(challenge, doc, sign("nonce-1")); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+	// nonce 只能使用一次
+	if err := verifyDIDProof(challenge, doc, sign("nonce-1")); !errors.Is(err, errAuthNonce) {
+		t.Errorf("replayed proof: err = %v, want errAuthNonce", err)
+	}
+
+	tests := []struct {
+		name      string
+		challenge *authChallenge
+		proof     *DIDProof
+	}{
+		{"no challenge", &authChallenge{}, sign("nonce-1")},
+		{"expired", &authChallenge{nonce: "nonce-1", expiresAt: time.Now().Add(-time.Second)}, sign("nonce-1")},
+		{"other nonce", issue(), sign("nonce-2")},
+		{"other DID's key", issue(), signOther("nonce-1")},
+		{"forged signature", issue(), &DIDProof{KeyID: doc.Authentication[0], Nonce: "nonce-1", Signature: signOther("nonce-1").Signature}},
+		{"bare DID", issue(), &DIDProof{KeyID: doc.Authentication[0], Nonce: "nonce-1"}},
+	}
+	for _, tt := range tests {
+		if err := verifyDIDProof(tt.challenge, doc, tt.proof); err == nil {
+			t.Errorf("%s: proof accepted", tt.name)
+		}
+		if tt.challenge.nonce != "" {
+			t.Errorf("%s: nonce not consumed after a failed attempt", tt.name)
+		}
+	}
+}
+
+func TestDIDProofRequiredByPayloads(t *testing.T) {
+	payloads := map[string]Payload{
+		MsgTypeAuth:         &AuthPayload{DID: "did:player:alice"},
+		MsgTypeUpgradeGuest: &UpgradeGuestPayload{DID: "did:player:alice"},
+	}
+	for msgType, payload := range payloads {
+		var validation *ValidationError
+		if err := payload.Validate(); !errors.As(err, &validation) || len(validation.Fields) != 3 {
+			t.Errorf("%s without a DID proof: err = %v, want keyId, nonce and signature errors", msgType, err)
+		}
+	}
+}
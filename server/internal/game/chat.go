@@ -0,0 +1,593 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// 聊天消息类型
+const (
+	MsgTypeChatHistory = "chat_history" // 分页获取房间频道的聊天记录
+	MsgTypeChatMute    = "chat_mute"    // 管理玩家自己的屏蔽列表
+	MsgTypeWhisper     = "whisper"      // 发给单个玩家的私聊
+)
+
+// DefaultChatChannel 未指定频道时使用的房间频道
+const DefaultChatChannel = "general"
+
+// 屏蔽列表操作
+const (
+	ChatMuteAdd    = "mute"
+	ChatMuteRemove = "unmute"
+	ChatMuteList   = "list"
+)
+
+const (
+	// chatStoreName 聊天记录的存储名称
+	chatStoreName = "game_chat"
+
+	recordTypeChat = "chat"
+	// chatChannelTag 按房间频道查询聊天记录的标签
+	chatChannelTag = "channel"
+)
+
+// ChatMessage 一条聊天消息，To 不为空时为私聊，私聊不写入聊天记录
+type ChatMessage struct {
+	ID        string    `json:"id"`
+	RoomID    string    `json:"roomId,omitempty"`
+	Channel   string    `json:"channel,omitempty"`
+	PlayerID  string    `json:"playerId"`
+	Nickname  string    `json:"nickname"`
+	To        string    `json:"to,omitempty"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// whisperBroadcast 总线上转发的私聊
+type whisperBroadcast struct {
+	Origin  string  `json:"origin"`
+	To      string  `json:"to"`
+	Message Message `json:"message"`
+}
+
+// ChatFilter 聊天过滤钩子，返回替换后的消息文本，返回错误时消息被拒绝
+type ChatFilter func(player *Player, channel, message string) (string, error)
+
+// ChatConfig 聊天记录配置
+type ChatConfig struct {
+	HistorySize int // 每个房间频道保留的消息数
+	PageSize    int // chat_history 默认返回的消息数
+	MaxPageSize int // chat_history 单页最多返回的消息数
+}
+
+// DefaultChatConfig 返回默认聊天记录配置
+func DefaultChatConfig() ChatConfig {
+	return ChatConfig{
+		HistorySize: 200,
+		PageSize:    50,
+		MaxPageSize: 100,
+	}
+}
+
+// ChatHistory 房间频道的聊天记录，最近的消息缓存在内存中并同步写入存储
+type ChatHistory struct {
+	store    storage.Store
+	config   ChatConfig
+	channels map[string][]ChatMessage
+	mutex    sync.Mutex
+}
+
+// NewChatHistory 创建聊天记录
+func NewChatHistory(provider storage.Provider, config ChatConfig) (*ChatHistory, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(chatStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open chat store: %w", err)
+	}
+
+	defaults := DefaultChatConfig()
+	if config.HistorySize <= 0 {
+		config.HistorySize = defaults.HistorySize
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = defaults.PageSize
+	}
+	if config.MaxPageSize < config.PageSize {
+		config.MaxPageSize = config.PageSize
+	}
+
+	return &ChatHistory{
+		store:    store,
+		config:   config,
+		channels: make(map[string][]ChatMessage),
+	}, nil
+}
+
+// Append 写入一条房间频道消息，超出保留数量的旧消息被删除
+func (h *ChatHistory) Append(msg ChatMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal chat message: %w", err)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	channel := chatChannel(msg.RoomID, msg.Channel)
+	messages, err := h.load(channel)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.Put(chatKey(msg), data,
+		storage.Tag{Name: recordTypeTag, Value: recordTypeChat},
+		storage.Tag{Name: chatChannelTag, Value: channel})
+	if err != nil {
+		return fmt.Errorf("save chat message: %w", err)
+	}
+
+	h.channels[channel] = h.trim(append(messages, msg))
+	return nil
+}
+
+// Page 返回 before 之前（不含）的最近 limit 条消息，按时间从旧到新；
+// before 为空时从最新的消息开始，more 表示是否还有更早的消息
+func (h *ChatHistory) Page(roomID, channel, before string, limit int) ([]ChatMessage, bool, error) {
+	if limit <= 0 {
+		limit = h.config.PageSize
+	}
+	if limit > h.config.MaxPageSize {
+		limit = h.config.MaxPageSize
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	messages, err := h.load(chatChannel(roomID, channel))
+	if err != nil {
+		return nil, false, err
+	}
+
+	end := len(messages)
+	if before != "" {
+		end = -1
+		for i, msg := range messages {
+			if msg.ID == before {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, false, fmt.Errorf("message not found in history: %s", before)
+		}
+	}
+
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+	page := make([]ChatMessage, end-start)
+	copy(page, messages[start:end])
+	return page, start > 0, nil
+}
+
+// PlayerMessages 返回玩家在所有房间频道中仍保留的消息，按时间从旧到新
+func (h *ChatHistory) PlayerMessages(playerID string) ([]ChatMessage, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	messages, err := h.playerMessages(playerID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Timestamp.Before(messages[j].Timestamp) })
+	return messages, nil
+}
+
+// ErasePlayer 处理玩家发送的所有消息：pseudonym 为空时删除，否则改为以 pseudonym 和 nickname 署名并保留内容。
+// 返回处理的消息数
+func (h *ChatHistory) ErasePlayer(playerID, pseudonym, nickname string) (int, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	messages, err := h.playerMessages(playerID)
+	if err != nil {
+		return 0, err
+	}
+
+	for erased, msg := range messages {
+		// 频道缓存在下次访问时从存储重新加载
+		channel := chatChannel(msg.RoomID, msg.Channel)
+		delete(h.channels, channel)
+
+		if pseudonym == "" {
+			err = h.store.Delete(chatKey(msg))
+		} else {
+			msg.PlayerID = pseudonym
+			msg.Nickname = nickname
+			var data []byte
+			if data, err = json.Marshal(msg); err == nil {
+				err = h.store.Put(chatKey(msg), data,
+					storage.Tag{Name: recordTypeTag, Value: recordTypeChat},
+					storage.Tag{Name: chatChannelTag, Value: channel})
+			}
+		}
+		if err != nil {
+			return erased, fmt.Errorf("erase chat message: %w", err)
+		}
+	}
+	return len(messages), nil
+}
+
+// playerMessages 从存储读取玩家发送的消息，调用方需持有锁
+func (h *ChatHistory) playerMessages(playerID string) ([]ChatMessage, error) {
+	messages := []ChatMessage{}
+	err := queryRecords(h.store, recordTypeTag, recordTypeChat, func(data []byte) error {
+		var msg ChatMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("unmarshal chat message: %w", err)
+		}
+		if msg.PlayerID == playerID {
+			messages = append(messages, msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// load 返回频道的消息，首次访问时从存储加载，调用方需持有锁
+func (h *ChatHistory) load(channel string) ([]ChatMessage, error) {
+	if messages, loaded := h.channels[channel]; loaded {
+		return messages, nil
+	}
+
+	messages := []ChatMessage{}
+	err := queryRecords(h.store, chatChannelTag, channel, func(data []byte) error {
+		var msg ChatMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("unmarshal chat message: %w", err)
+		}
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Timestamp.Before(messages[j].Timestamp) })
+	messages = h.trim(messages)
+	h.channels[channel] = messages
+	return messages, nil
+}
+
+// trim 删除超出保留数量的旧消息，调用方需持有锁
+func (h *ChatHistory) trim(messages []ChatMessage) []ChatMessage {
+	excess := len(messages) - h.config.HistorySize
+	if excess <= 0 {
+		return messages
+	}
+
+	for _, msg := range messages[:excess] {
+		if err := h.store.Delete(chatKey(msg)); err != nil {
+			slog.Error("Failed to delete expired chat message", "message_id", msg.ID, logging.Err(err))
+		}
+	}
+	return append([]ChatMessage(nil), messages[excess:]...)
+}
+
+func chatChannel(roomID, channel string) string {
+	return roomID + "/" + channel
+}
+
+func chatKey(msg ChatMessage) string {
+	return fmt.Sprintf("chat:%s:%020d:%s", chatChannel(msg.RoomID, msg.Channel), msg.Timestamp.UnixNano(), msg.ID)
+}
+
+// NewProfanityFilter 返回将屏蔽词替换为 * 的聊天过滤器，匹配不区分大小写，英文词按整词匹配
+func NewProfanityFilter(words []string) ChatFilter {
+	patterns := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word == "" {
+			continue
+		}
+		pattern := regexp.QuoteMeta(word)
+		if isASCIIWord(word) {
+			pattern = `\b` + pattern + `\b`
+		}
+		patterns = append(patterns, pattern)
+	}
+	if len(patterns) == 0 {
+		return func(_ *Player, _, message string) (string, error) { return message, nil }
+	}
+
+	re := regexp.MustCompile(`(?i)(?:` + strings.Join(patterns, "|") + `)`)
+	return func(_ *Player, _, message string) (string, error) {
+		return re.ReplaceAllStringFunc(message, func(match string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(match))
+		}), nil
+	}
+}
+
+func isASCIIWord(word string) bool {
+	for _, r := range word {
+		if r >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// SetChatHistory 使用持久化的聊天记录替换默认的内存实现，应在接受连接前调用
+func (s *SimpleServer) SetChatHistory(history *ChatHistory) {
+	s.chatHistory = history
+}
+
+// AddChatFilter 添加聊天过滤钩子，按添加顺序依次执行，应在接受连接前调用
+func (s *SimpleServer) AddChatFilter(filter ChatFilter) {
+	s.chatFilters = append(s.chatFilters, filter)
+}
+
+// handleChat 处理 chat 消息：指定 to 时私聊该玩家，否则发送到所在房间的频道
+func (s *SimpleServer) handleChat(player *Player, payload *ChatPayload) {
+	if mute, muted := s.moderation.Active(SanctionMute, player.DID); muted {
+		s.sendErrorToPlayer(player, ErrCodeMuted, mute.message(s.catalog, player.Locale))
+		return
+	}
+	if payload.To == "" && payload.Channel != GuildChatChannel && player.Room == nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.chat_join_room", nil)
+		return
+	}
+	if payload.To == "" && payload.Channel == TeamChatChannel && player.TeamID == "" {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.chat_join_team", nil)
+		return
+	}
+
+	text := payload.Message
+	for _, filter := range s.chatFilters {
+		var err error
+		if text, err = filter(player, payload.Channel, text); err != nil {
+			s.sendErrorToPlayer(player, ErrCodeChatRejected, err.Error())
+			return
+		}
+	}
+
+	msg := ChatMessage{
+		ID:        uuid.New().String(),
+		PlayerID:  player.ID,
+		Nickname:  player.Nickname,
+		Message:   text,
+		Timestamp: time.Now(),
+	}
+
+	if payload.To != "" {
+		msg.To = payload.To
+		s.sendWhisper(player, msg)
+		return
+	}
+	if payload.Channel == GuildChatChannel {
+		s.handleGuildChat(player, msg)
+		return
+	}
+
+	room := player.Room
+	msg.RoomID = room.ID
+	msg.Channel = payload.Channel
+	teamID := ""
+	if payload.Channel == TeamChatChannel {
+		teamID = player.TeamID
+		msg.Channel = teamChatChannel(teamID)
+	}
+	if err := s.chatHistory.Append(msg); err != nil {
+		room.log().Error("Failed to save chat message", logging.Err(err))
+	}
+
+	chat := Message{
+		Type:      MsgTypeChat,
+		PlayerID:  player.ID,
+		RoomID:    room.ID,
+		Data:      msg,
+		Timestamp: msg.Timestamp,
+	}
+	if teamID != "" {
+		s.broadcastToTeam(room, teamID, chat, "")
+	} else {
+		s.broadcastToRoom(room, chat, "")
+	}
+
+	s.emitEvent(room, player, EventChat, "")
+}
+
+// sendWhisper 投递私聊，接收方不在本实例时经消息总线转发，并回显给发送方
+func (s *SimpleServer) sendWhisper(sender *Player, msg ChatMessage) {
+	whisper := Message{
+		Type:      MsgTypeWhisper,
+		PlayerID:  sender.ID,
+		Data:      msg,
+		Timestamp: msg.Timestamp,
+	}
+
+	target, local := s.players.get(msg.To)
+
+	switch {
+	case local && target.Connection != nil:
+		s.deliverWhisper(target, whisper)
+	case s.bus != nil:
+		s.publish(busTopicWhisper, whisperBroadcast{
+			Origin:  s.instanceID,
+			To:      msg.To,
+			Message: whisper,
+		})
+	default:
+		s.sendLocalizedErrorToPlayer(sender, ErrCodeWhisperFailed, "error.player_offline", i18n.Params{"player": msg.To})
+		return
+	}
+
+	writeMessage(sender.Connection, whisper)
+}
+
+// deliverWhisper 将私聊发给本实例上的接收方，接收方屏蔽了发送方时静默丢弃
+func (s *SimpleServer) deliverWhisper(target *Player, whisper Message) {
+	if target.Connection == nil || target.hasMuted(whisper.PlayerID) {
+		return
+	}
+	writeMessage(target.Connection, whisper)
+}
+
+// handleWhisperBroadcast 投递其他实例转发的私聊
+func (s *SimpleServer) handleWhisperBroadcast(data []byte) {
+	var broadcast whisperBroadcast
+	if err := json.Unmarshal(data, &broadcast); err != nil {
+		slog.Warn("Invalid whisper on message bus", logging.Err(err))
+		return
+	}
+	if broadcast.Origin == s.instanceID {
+		return
+	}
+
+	target, exists := s.players.get(broadcast.To)
+	if exists {
+		s.deliverWhisper(target, broadcast.Message)
+	}
+}
+
+// handleChatHistory 返回玩家所在房间某个频道或所在公会频道的聊天记录
+func (s *SimpleServer) handleChatHistory(player *Player, payload *ChatHistoryPayload) {
+	room := player.Room
+	roomID := ""
+	channel := payload.Channel
+	switch {
+	case channel == GuildChatChannel:
+		guild, err := s.guilds.MemberGuild(player.DID)
+		if err != nil {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.history_join_guild", nil)
+			return
+		}
+		channel = guildChatChannel(guild.ID)
+	case room == nil:
+		s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.history_join_room", nil)
+		return
+	default:
+		roomID = room.ID
+	}
+
+	if channel == TeamChatChannel {
+		if player.TeamID == "" {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.history_join_team", nil)
+			return
+		}
+		channel = teamChatChannel(player.TeamID)
+	}
+
+	messages, more, err := s.chatHistory.Page(roomID, channel, payload.Before, payload.Limit)
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.history_failed", i18n.Params{"error": err})
+		return
+	}
+
+	// 屏蔽的玩家的历史消息同样不返回
+	visible := make([]ChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if !player.hasMuted(msg.PlayerID) {
+			visible = append(visible, msg)
+		}
+	}
+
+	data := map[string]interface{}{
+		"channel":  payload.Channel,
+		"messages": visible,
+		"hasMore":  more,
+	}
+	if more && len(messages) > 0 {
+		data["before"] = messages[0].ID
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:      MsgTypeChatHistory,
+		PlayerID:  player.ID,
+		RoomID:    roomID,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// handleChatMute 修改或查看玩家的屏蔽列表，屏蔽的玩家发送的聊天和私聊不再推送给该玩家
+func (s *SimpleServer) handleChatMute(player *Player, payload *ChatMutePayload) {
+	switch payload.Action {
+	case ChatMuteAdd:
+		if payload.PlayerID == player.ID {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.mute_self", nil)
+			return
+		}
+		player.setMuted(payload.PlayerID, true)
+		s.persistPlayer(player)
+	case ChatMuteRemove:
+		player.setMuted(payload.PlayerID, false)
+		s.persistPlayer(player)
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeChatMute,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"action": payload.Action,
+			"muted":  player.mutedPlayerIDs(),
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// hasMuted 玩家是否屏蔽了 playerID
+func (p *Player) hasMuted(playerID string) bool {
+	p.muteMutex.RLock()
+	defer p.muteMutex.RUnlock()
+
+	return p.mutedPlayers[playerID]
+}
+
+func (p *Player) setMuted(playerID string, muted bool) {
+	p.muteMutex.Lock()
+	defer p.muteMutex.Unlock()
+
+	if !muted {
+		delete(p.mutedPlayers, playerID)
+		return
+	}
+	if p.mutedPlayers == nil {
+		p.mutedPlayers = make(map[string]bool)
+	}
+	p.mutedPlayers[playerID] = true
+}
+
+// mutedPlayerIDs 返回排序后的屏蔽列表
+func (p *Player) mutedPlayerIDs() []string {
+	p.muteMutex.RLock()
+	defer p.muteMutex.RUnlock()
+
+	ids := make([]string, 0, len(p.mutedPlayers))
+	for playerID := range p.mutedPlayers {
+		ids = append(ids, playerID)
+	}
+	sort.Strings(ids)
+	return ids
+}
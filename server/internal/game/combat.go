@@ -0,0 +1,280 @@
+package game
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// MsgTypeCombat 玩家受到伤害、死亡和重生
+const MsgTypeCombat = "combat"
+
+// 战斗事件类型
+const (
+	CombatDamage  = "damage"
+	CombatDeath   = "death"
+	CombatRespawn = "respawn"
+)
+
+// 伤害来源
+const (
+	DamageAttack     = "attack"
+	DamageProjectile = "projectile"
+)
+
+// CombatConfig 战斗配置
+type CombatConfig struct {
+	AttackDamage   int           // 近战攻击的基础伤害
+	AttackRange    float64       // 攻击者与目标碰撞边缘之间的最大距离（像素）
+	AttackCooldown time.Duration // 两次攻击之间的最短间隔
+	RespawnDelay   time.Duration // 死亡到重生的时间
+	Invulnerable   time.Duration // 重生后不受伤害的时间
+	FriendlyFire   bool          // 是否允许伤害队友
+}
+
+// DefaultCombatConfig 返回默认战斗配置
+func DefaultCombatConfig() CombatConfig {
+	return CombatConfig{
+		AttackDamage:   10,
+		AttackRange:    24,
+		AttackCooldown: 500 * time.Millisecond,
+		RespawnDelay:   3 * time.Second,
+		Invulnerable:   2 * time.Second,
+	}
+}
+
+// SetCombatConfig 替换战斗配置，应在接受连接前调用
+func (s *SimpleServer) SetCombatConfig(config CombatConfig) {
+	s.combat = config
+}
+
+// DamageModifier 游戏模式可以实现该接口调整伤害，例如按等级、装备或技能加成，返回不大于 0 的值时不造成伤害。
+// attacker 为 nil 表示伤害不来自玩家。与 GameMode 的其他回调一样在持有 room.mutex 时调用
+type DamageModifier interface {
+	ModifyDamage(room *GameRoom, attacker, target *Player, damage int, cause string) int
+}
+
+// CombatEvent combat 消息数据
+type CombatEvent struct {
+	Type              string     `json:"type"`
+	TargetID          string     `json:"targetId"`
+	AttackerID        string     `json:"attackerId,omitempty"`
+	Cause             string     `json:"cause,omitempty"`
+	Damage            int        `json:"damage,omitempty"`
+	Health            int        `json:"health"`
+	Position          *Position  `json:"position,omitempty"`          // 重生位置
+	RespawnAt         *time.Time `json:"respawnAt,omitempty"`         // 死亡时给出重生时间
+	InvulnerableUntil *time.Time `json:"invulnerableUntil,omitempty"` // 重生后的无敌截止时间
+
+	attacker *Player
+	target   *Player
+}
+
+// dead 判断玩家是否处于死亡等待重生的状态，调用方需持有 room.mutex
+func (p *Player) dead() bool {
+	return !p.respawnAt.IsZero()
+}
+
+// revive 清除死亡状态并恢复生命值，调用方需持有 room.mutex
+func (p *Player) revive() {
+	p.respawnAt = time.Time{}
+	if p.Health <= 0 {
+		p.Health = p.MaxHealth
+	}
+}
+
+// resolveAttack 处理一次近战攻击：未指定目标时攻击范围内最近的可攻击玩家。调用方需持有 room.mutex
+func (s *SimpleServer) resolveAttack(room *GameRoom, attacker *Player, targetID string, now time.Time) []*CombatEvent {
+	if now.Sub(attacker.lastAttackAt) < s.combat.AttackCooldown {
+		return nil
+	}
+	attacker.lastAttackAt = now
+
+	reach := s.combat.AttackRange + 2*s.movement.PlayerRadius
+	inReach := func(target *Player) bool {
+		return target != attacker && !target.dead() && s.canDamage(attacker, target) &&
+			math.Hypot(target.Position.X-attacker.Position.X, target.Position.Y-attacker.Position.Y) <= reach
+	}
+
+	var target *Player
+	if targetID != "" {
+		if candidate, exists := room.Players[targetID]; exists && inReach(candidate) {
+			target = candidate
+		}
+	} else {
+		candidates := make([]*Player, 0, len(room.Players))
+		for _, candidate := range room.Players {
+			if inReach(candidate) {
+				candidates = append(candidates, candidate)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			di := math.Hypot(candidates[i].Position.X-attacker.Position.X, candidates[i].Position.Y-attacker.Position.Y)
+			dj := math.Hypot(candidates[j].Position.X-attacker.Position.X, candidates[j].Position.Y-attacker.Position.Y)
+			if di != dj {
+				return di < dj
+			}
+			return candidates[i].ID < candidates[j].ID
+		})
+		if len(candidates) > 0 {
+			target = candidates[0]
+		}
+	}
+	if target == nil {
+		return nil
+	}
+
+	return s.applyDamage(room, attacker, target, s.combat.AttackDamage, DamageAttack, now)
+}
+
+// canDamage 判断攻击者能否伤害目标，关闭友军伤害时同队玩家互不伤害
+func (s *SimpleServer) canDamage(attacker, target *Player) bool {
+	if attacker == nil || attacker == target || s.combat.FriendlyFire {
+		return true
+	}
+	return attacker.TeamID == "" || attacker.TeamID != target.TeamID
+}
+
+// applyDamage 对目标造成伤害，生命值降到 0 时目标死亡并等待重生。已死亡、处于无敌时间内
+// 或不能被攻击者伤害的目标不受影响。调用方需持有 room.mutex
+func (s *SimpleServer) applyDamage(room *GameRoom, attacker, target *Player, damage int, cause string, now time.Time) []*CombatEvent {
+	if target.dead() || now.Before(target.invulnerableUntil) || !s.canDamage(attacker, target) {
+		return nil
+	}
+	if modifier, ok := room.mode.(DamageModifier); ok {
+		damage = modifier.ModifyDamage(room, attacker, target, damage, cause)
+	}
+	if damage <= 0 {
+		return nil
+	}
+
+	target.Health -= damage
+	if target.Health < 0 {
+		target.Health = 0
+	}
+
+	hit := &CombatEvent{
+		Type:     CombatDamage,
+		TargetID: target.ID,
+		Cause:    cause,
+		Damage:   damage,
+		Health:   target.Health,
+		attacker: attacker,
+		target:   target,
+	}
+	if attacker != nil {
+		hit.AttackerID = attacker.ID
+	}
+	if target.Health > 0 {
+		return []*CombatEvent{hit}
+	}
+
+	target.respawnAt = now.Add(s.combat.RespawnDelay)
+	respawnAt := target.respawnAt
+	death := *hit
+	death.Type = CombatDeath
+	death.Damage = 0
+	death.RespawnAt = &respawnAt
+	return []*CombatEvent{hit, &death}
+}
+
+// applyProjectileHits 按弹道伤害结算命中玩家的碰撞，调用方需持有 room.mutex
+func (s *SimpleServer) applyProjectileHits(room *GameRoom, collisions []*Collision, now time.Time) []*CombatEvent {
+	var events []*CombatEvent
+	for _, collision := range collisions {
+		if collision.Type != CollisionPlayer || collision.Damage <= 0 {
+			continue
+		}
+		target, exists := room.Players[collision.PlayerID]
+		if !exists {
+			continue
+		}
+		// 发射者已离开房间时伤害不归属任何玩家
+		attacker := room.Players[collision.OwnerID]
+		events = append(events, s.applyDamage(room, attacker, target, collision.Damage, DamageProjectile, now)...)
+	}
+	return events
+}
+
+// respawnPlayers 让到达重生时间的玩家在出生点满血重生，并在一段时间内不受伤害。调用方需持有 room.mutex
+func (s *SimpleServer) respawnPlayers(room *GameRoom, now time.Time) []*CombatEvent {
+	var events []*CombatEvent
+	for _, player := range room.Players {
+		if !player.dead() || now.Before(player.respawnAt) {
+			continue
+		}
+
+		player.respawnAt = time.Time{}
+		player.Health = player.MaxHealth
+		player.Position = room.respawnPoint(player)
+		player.lastMoveAt = time.Time{}
+		player.invulnerableUntil = now.Add(s.combat.Invulnerable)
+
+		position := player.Position
+		invulnerableUntil := player.invulnerableUntil
+		events = append(events, &CombatEvent{
+			Type:              CombatRespawn,
+			TargetID:          player.ID,
+			Health:            player.Health,
+			Position:          &position,
+			InvulnerableUntil: &invulnerableUntil,
+			target:            player,
+		})
+	}
+	return events
+}
+
+// respawnPoint 返回玩家的重生位置：队伍出生点，或离其他存活玩家最远的地图出生点。调用方需持有 room.mutex
+func (r *GameRoom) respawnPoint(player *Player) Position {
+	if team := r.teamOf(player.ID); team != nil {
+		if point, ok := team.spawnPoint(team.memberIndex(player.ID)); ok {
+			return point
+		}
+	}
+
+	points := r.GameState.Map.SpawnPoints
+	if len(points) == 0 {
+		return player.Position
+	}
+
+	best, bestDistance := points[0], -1.0
+	for _, point := range points {
+		nearest := math.Inf(1)
+		for _, other := range r.Players {
+			if other == player || other.dead() {
+				continue
+			}
+			nearest = math.Min(nearest, math.Hypot(other.Position.X-point.X, other.Position.Y-point.Y))
+		}
+		if nearest > bestDistance {
+			best, bestDistance = point, nearest
+		}
+	}
+	return best
+}
+
+// announceCombat 向房间广播战斗事件，死亡时记录击杀和死亡事件，重生时修正玩家位置
+func (s *SimpleServer) announceCombat(room *GameRoom, events []*CombatEvent) {
+	for _, event := range events {
+		s.broadcastToRoom(room, Message{
+			Type:      MsgTypeCombat,
+			PlayerID:  event.TargetID,
+			RoomID:    room.ID,
+			Data:      event,
+			Timestamp: time.Now(),
+		}, "")
+
+		switch event.Type {
+		case CombatDeath:
+			s.persistPlayer(event.target)
+			s.emitEvent(room, event.target, EventDeath, event.AttackerID)
+			if event.attacker != nil {
+				s.emitEvent(room, event.attacker, EventKill, event.TargetID)
+			}
+			room.log().Info("Player killed", "target", event.TargetID, "attacker", event.AttackerID, "cause", event.Cause)
+		case CombatRespawn:
+			s.persistPlayer(event.target)
+			s.sendPositionCorrection(event.target, room.ID, moveResult{Position: *event.Position, Corrected: true, Reason: CombatRespawn})
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package game
+
+import (
+	"bufio"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// CompressionConfig WebSocket 帧压缩（permessage-deflate，不保留上下文）配置
+type CompressionConfig struct {
+	// Enabled 是否与请求压缩的客户端协商 permessage-deflate
+	Enabled bool
+	// Threshold 只压缩编码后不小于该字节数的消息，移动、ping 等小消息压缩的收益抵不上开销
+	Threshold int
+	// Level flate 压缩级别，从 -2（只做 Huffman 编码）到 9（压缩率最高）
+	Level int
+}
+
+// DefaultCompressionConfig 返回默认压缩配置：压缩 1KB 以上的消息（房间、游戏状态、凭证等），使用最快的压缩级别
+func DefaultCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Enabled:   true,
+		Threshold: 1024,
+		Level:     flate.BestSpeed,
+	}
+}
+
+// SetCompressionConfig 替换压缩配置，应在接受连接前调用
+func (s *SimpleServer) SetCompressionConfig(config CompressionConfig) error {
+	if config.Threshold < 0 {
+		return fmt.Errorf("compression threshold must not be negative, got %d", config.Threshold)
+	}
+	if config.Level < flate.HuffmanOnly || config.Level > flate.BestCompression {
+		return fmt.Errorf("compression level must be between %d and %d, got %d", flate.HuffmanOnly, flate.BestCompression, config.Level)
+	}
+	s.compression = config
+	s.upgrader.EnableCompression = config.Enabled
+	return nil
+}
+
+// compressionResponseWriter 使升级后的连接经 compressedConn 写出，以统计压缩后的字节数
+type compressionResponseWriter struct {
+	http.ResponseWriter
+	threshold int
+}
+
+// Hijack 实现 http.Hijacker
+func (w *compressionResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &compressedConn{Conn: conn, threshold: w.threshold}, rw, nil
+}
+
+// compressedConn 协商了 permessage-deflate 的连接，记录写出的字节数
+type compressedConn struct {
+	net.Conn
+	threshold int
+	written   atomic.Int64
+}
+
+// Write 写出并累计字节数
+func (c *compressedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// compressionWriter 压缩已启用且客户端请求了 permessage-deflate 时包装 w，否则原样返回
+func (s *SimpleServer) compressionWriter(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if !s.compression.Enabled || !offersDeflate(r.Header) {
+		return w
+	}
+	return &compressionResponseWriter{ResponseWriter: w, threshold: s.compression.Threshold}
+}
+
+// configureCompression 设置协商了压缩的连接的压缩级别。超过阈值的消息才逐条开启压缩
+func (s *SimpleServer) configureCompression(conn *websocket.Conn) {
+	if _, ok := conn.NetConn().(*compressedConn); !ok {
+		return
+	}
+	conn.SetCompressionLevel(s.compression.Level)
+	conn.EnableWriteCompression(false)
+	compressionConnectionsTotal.Inc()
+}
+
+// compressionNegotiated 判断连接是否协商了 permessage-deflate
+func compressionNegotiated(conn *websocket.Conn) bool {
+	_, ok := conn.NetConn().(*compressedConn)
+	return ok
+}
+
+// writeCompressed 按阈值决定是否压缩并发送一帧，压缩的消息记录压缩前后的大小。
+// 压缩后的大小由连接写出的字节数计算，包含帧头
+func writeCompressed(conn *websocket.Conn, messageType int, data []byte) error {
+	metered, ok := conn.NetConn().(*compressedConn)
+	if !ok {
+		return conn.WriteMessage(messageType, data)
+	}
+
+	compress := len(data) >= metered.threshold
+	conn.EnableWriteCompression(compress)
+	if !compress {
+		compressionMessagesTotal.With("skipped").Inc()
+		return conn.WriteMessage(messageType, data)
+	}
+
+	before := metered.written.Load()
+	if err := conn.WriteMessage(messageType, data); err != nil {
+		return err
+	}
+	written := metered.written.Load() - before
+	compressionMessagesTotal.With("compressed").Inc()
+	compressionInputBytesTotal.Add(float64(len(data)))
+	compressionOutputBytesTotal.Add(float64(written))
+	compressionRatio.Observe(float64(written) / float64(len(data)))
+	return nil
+}
+
+// offersDeflate 判断握手请求的 Sec-WebSocket-Extensions 中是否包含 permessage-deflate
+func offersDeflate(header http.Header) bool {
+	for _, value := range header.Values("Sec-WebSocket-Extensions") {
+		for _, extension := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(extension, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// 同一 DID 的连接数达到上限时的处理策略
+const (
+	// DuplicateLoginKickOld 新连接接管玩家，最早认证的连接收到 session_replaced 后被断开
+	DuplicateLoginKickOld = "kick_old"
+	// DuplicateLoginRejectNew 拒绝新连接的认证，返回 already_connected
+	DuplicateLoginRejectNew = "reject_new"
+)
+
+// busTopicSession 通知其他实例断开被新登录接管的 DID 的连接
+const busTopicSession = "session"
+
+// ConnectionPolicy 同一 DID 的并发连接策略。上限按实例计算；只允许一个连接且策略为 kick_old 时，
+// 新登录还会经消息总线断开该 DID 在其他实例上的连接
+type ConnectionPolicy struct {
+	MaxSessions    int    // 同一 DID 同时认证的连接数上限，多个连接共享同一玩家，服务器消息发往最近认证的连接
+	DuplicateLogin string // 达到上限时的处理：kick_old 或 reject_new
+}
+
+// DefaultConnectionPolicy 返回默认策略：每个 DID 一个连接，已有连接时拒绝新登录，
+// 旧连接断开或心跳超时后才能再次登录，新连接不能踢掉正在游戏的玩家
+func DefaultConnectionPolicy() ConnectionPolicy {
+	return ConnectionPolicy{
+		MaxSessions:    1,
+		DuplicateLogin: DuplicateLoginRejectNew,
+	}
+}
+
+// validate 校验策略
+func (p ConnectionPolicy) validate() error {
+	if p.MaxSessions < 1 {
+		return fmt.Errorf("max sessions must be at least 1, got %d", p.MaxSessions)
+	}
+	switch p.DuplicateLogin {
+	case DuplicateLoginKickOld, DuplicateLoginRejectNew:
+		return nil
+	default:
+		return fmt.Errorf("unknown duplicate login policy: %s", p.DuplicateLogin)
+	}
+}
+
+// SetConnectionPolicy 替换并发连接策略，应在接受连接前调用
+func (s *SimpleServer) SetConnectionPolicy(policy ConnectionPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	s.connectionPolicy = policy
+	return nil
+}
+
+// connection 一个已认证的连接
+type connection struct {
+	conn  *websocket.Conn
+	since time.Time
+}
+
+// Connections 本实例上按 DID 登记的已认证连接，每个 DID 的连接按认证时间排序
+type Connections struct {
+	byDID map[string][]*connection
+	mutex sync.Mutex
+}
+
+// NewConnections 创建连接登记表
+func NewConnections() *Connections {
+	return &Connections{
+		byDID: make(map[string][]*connection),
+	}
+}
+
+// admit 按策略登记 DID 的新连接，返回因此需要断开的旧连接；策略拒绝新连接时返回 false。
+// 同一连接重复认证时只更新其认证时间
+func (c *Connections) admit(playerDID string, conn *websocket.Conn, policy ConnectionPolicy) ([]*connection, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	existing := make([]*connection, 0, len(c.byDID[playerDID])+1)
+	for _, entry := range c.byDID[playerDID] {
+		if entry.conn != conn {
+			existing = append(existing, entry)
+		}
+	}
+
+	var evicted []*connection
+	if over := len(existing) - policy.MaxSessions + 1; over > 0 {
+		if policy.DuplicateLogin == DuplicateLoginRejectNew {
+			return nil, false
+		}
+		evicted = existing[:over]
+		existing = existing[over:]
+	}
+
+	c.byDID[playerDID] = append(existing, &connection{conn: conn, since: time.Now()})
+	return evicted, true
+}
+
+// release 注销连接，返回该 DID 剩余连接中最近认证的一个，没有剩余连接时返回 nil
+func (c *Connections) release(playerDID string, conn *websocket.Conn) *connection {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	remaining := c.byDID[playerDID][:0]
+	for _, entry := range c.byDID[playerDID] {
+		if entry.conn != conn {
+			remaining = append(remaining, entry)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(c.byDID, playerDID)
+		return nil
+	}
+	c.byDID[playerDID] = remaining
+	return remaining[len(remaining)-1]
+}
+
+// move 将 oldDID 的所有连接改登记到 newDID 下，用于访客升级为登记的 DID
+func (c *Connections) move(oldDID, newDID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if entries, exists := c.byDID[oldDID]; exists {
+		c.byDID[newDID] = append(c.byDID[newDID], entries...)
+		delete(c.byDID, oldDID)
+	}
+}
+
+// list 返回 DID 的所有连接
+func (c *Connections) list(playerDID string) []*connection {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]*connection(nil), c.byDID[playerDID]...)
+}
+
+// Count 返回 DID 在本实例上已认证的连接数
+func (c *Connections) Count(playerDID string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.byDID[playerDID])
+}
+
+// sessionTakeover 总线上的登录接管通知
+type sessionTakeover struct {
+	Origin string `json:"origin"`
+	DID    string `json:"did"`
+}
+
+// admitConnection 按并发连接策略登记认证的连接并断开被接管的旧连接，策略拒绝时已向连接发送错误并返回 false
+func (s *SimpleServer) admitConnection(playerDID string, conn *websocket.Conn, locale string) bool {
+	policy := s.connectionPolicy
+	evicted, ok := s.connections.admit(playerDID, conn, policy)
+	if !ok {
+		duplicateLoginsTotal.With("rejected").Inc()
+		s.sendLocalizedError(conn, locale, ErrCodeAlreadyConnected, "error.already_connected", i18n.Params{"limit": policy.MaxSessions})
+		return false
+	}
+
+	for _, entry := range evicted {
+		duplicateLoginsTotal.With("kicked").Inc()
+		s.closeReplaced(entry.conn, locale)
+	}
+	if policy.MaxSessions == 1 && policy.DuplicateLogin == DuplicateLoginKickOld {
+		s.publish(busTopicSession, sessionTakeover{Origin: s.instanceID, DID: playerDID})
+	}
+	return true
+}
+
+// closeReplaced 通知被接管的连接后将其关闭，连接的读循环随后退出并注销连接
+func (s *SimpleServer) closeReplaced(conn *websocket.Conn, locale string) {
+	s.sendLocalizedError(conn, locale, ErrCodeSessionReplaced, "error.session_replaced", nil)
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session replaced")
+	writeClose(conn, closeFrame, time.Now().Add(s.heartbeat.WriteWait))
+	conn.Close()
+}
+
+// releaseConnection 连接断开时注销连接。断开的是玩家当前连接且该 DID 还有其他连接时，
+// 玩家交给最近认证的连接，保留房间成员身份；没有其他连接时按断线处理
+func (s *SimpleServer) releaseConnection(player *Player, conn *websocket.Conn) {
+	next := s.connections.release(player.DID, conn)
+
+	player.missedMutex.Lock()
+	current := player.Connection == conn
+	if current && next != nil {
+		player.Connection = next.conn
+	}
+	player.missedMutex.Unlock()
+
+	if !current {
+		return
+	}
+	if next == nil {
+		s.handleDisconnect(player)
+		return
+	}
+
+	duplicateLoginsTotal.With("handover").Inc()
+	player.log().Info("Player handed over to another connection")
+	if room := player.Room; room != nil {
+		s.resetStateSync(room, player)
+	}
+}
+
+// handleSessionTakeover 断开被其他实例上的新登录接管的本地连接
+func (s *SimpleServer) handleSessionTakeover(data []byte) {
+	var takeover sessionTakeover
+	if err := json.Unmarshal(data, &takeover); err != nil {
+		slog.Warn("Invalid session takeover on message bus", logging.Err(err))
+		return
+	}
+	if takeover.Origin == s.instanceID {
+		return
+	}
+
+	player := s.findPlayerByDID(takeover.DID)
+	for _, entry := range s.connections.list(takeover.DID) {
+		duplicateLoginsTotal.With("kicked").Inc()
+		s.closeReplaced(entry.conn, player.locale())
+	}
+}
@@ -0,0 +1,26 @@
+package game
+
+import (
+	"time"
+
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+// MsgTypeCredentialExpiring 玩家持有的凭证即将过期，可通过 /api/vc/renew 续期
+const MsgTypeCredentialExpiring = "credential_expiring"
+
+// NotifyCredentialExpiring 向持有该凭证的在线玩家推送过期提醒，实现 vc.ExpiryNotifier
+func (s *SimpleServer) NotifyCredentialExpiring(warning *vc.ExpiryWarning) bool {
+	holder := s.findConnectedPlayerByDID(warning.HolderDID)
+	if holder == nil {
+		return false
+	}
+
+	err := writeMessage(holder.Connection, Message{
+		Type:      MsgTypeCredentialExpiring,
+		PlayerID:  holder.ID,
+		Data:      warning,
+		Timestamp: time.Now(),
+	})
+	return err == nil
+}
@@ -0,0 +1,177 @@
+package game
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// MsgTypeCredentialsImported 导入外部凭证的结果
+const MsgTypeCredentialsImported = "credentials_imported"
+
+// maxImportedCredentials auth 或 join_room 一次最多导入的凭证数
+const maxImportedCredentials = 10
+
+// ImportedCredential 已导入的外部凭证
+type ImportedCredential struct {
+	CredentialID string `json:"credentialId"`
+	Type         string `json:"type"`
+	Issuer       string `json:"issuer"`
+}
+
+// ImportRejection 未能导入的外部凭证及原因
+type ImportRejection struct {
+	CredentialID string `json:"credentialId,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// importCredentials 验证玩家出示的其他游戏颁发的凭证，并按游戏配置发放对应奖励。
+// 每个凭证只导入一次，结果以 credentials_imported 消息发给玩家
+func (s *SimpleServer) importCredentials(player *Player, gameID string, credentials []json.RawMessage) {
+	if len(credentials) == 0 {
+		return
+	}
+
+	var settings GameSettings
+	if info, ok := s.Game(gameID); ok {
+		settings = info.Settings
+	}
+
+	imported := []ImportedCredential{}
+	rejected := []ImportRejection{}
+	for _, data := range credentials {
+		credential, err := vc.CredentialFromJSON(data)
+		if err != nil {
+			rejected = append(rejected, ImportRejection{Reason: "invalid credential"})
+			continue
+		}
+
+		bonus, reason := s.checkImport(player, credential, settings)
+		if reason != "" {
+			rejected = append(rejected, ImportRejection{CredentialID: credential.ID, Reason: reason})
+			continue
+		}
+
+		s.applyCredentialBonus(player, bonus)
+		imported = append(imported, ImportedCredential{
+			CredentialID: credential.ID,
+			Type:         bonus.CredentialType,
+			Issuer:       credential.Issuer,
+		})
+		player.log().Info("Player imported credential", "type", bonus.CredentialType, "issuer", credential.Issuer)
+	}
+
+	if len(imported) > 0 {
+		s.persistPlayer(player)
+	}
+
+	if player.Connection != nil {
+		player.importMutex.Lock()
+		titles := append([]string{}, player.Titles...)
+		player.importMutex.Unlock()
+
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeCredentialsImported,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"gameId":   gameID,
+				"imported": imported,
+				"rejected": rejected,
+				"level":    player.Level,
+				"titles":   titles,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// checkImport 检查凭证能否导入，返回对应的奖励；不能导入时返回原因，并将可导入的凭证标记为已导入
+func (s *SimpleServer) checkImport(player *Player, credential *vc.SimpleCredential, settings GameSettings) (*CredentialBonus, string) {
+	if credential.CredentialSubject.ID != player.DID {
+		return nil, "credential is not issued to player"
+	}
+
+	var bonus *CredentialBonus
+	for i := range settings.CredentialBonuses {
+		if hasCredentialType(credential, settings.CredentialBonuses[i].CredentialType) {
+			bonus = &settings.CredentialBonuses[i]
+			break
+		}
+	}
+	if bonus == nil {
+		return nil, "credential type is not recognized"
+	}
+
+	if valid, message := s.vcService.VerifyTrustedCredential(player.traceContext(), credential, settings.TrustedIssuers); !valid {
+		return nil, message
+	}
+
+	player.importMutex.Lock()
+	defer player.importMutex.Unlock()
+	if player.importedCredentials[credential.ID] {
+		return nil, "credential already imported"
+	}
+	if player.importedCredentials == nil {
+		player.importedCredentials = make(map[string]bool)
+	}
+	player.importedCredentials[credential.ID] = true
+	return bonus, ""
+}
+
+// applyCredentialBonus 发放导入凭证的奖励：提升初始等级、授予称号和发放道具
+func (s *SimpleServer) applyCredentialBonus(player *Player, bonus *CredentialBonus) {
+	if bonus.StartingLevel > 0 {
+		room := player.Room
+		if room != nil {
+			room.mutex.Lock()
+		}
+		if player.Level < bonus.StartingLevel {
+			player.Level = bonus.StartingLevel
+			if xp := s.experience.XPForLevel(bonus.StartingLevel); player.Experience < xp {
+				player.Experience = xp
+			}
+		}
+		if room != nil {
+			room.mutex.Unlock()
+		}
+	}
+
+	if bonus.Title != "" {
+		player.importMutex.Lock()
+		if !containsString(player.Titles, bonus.Title) {
+			player.Titles = append(player.Titles, bonus.Title)
+		}
+		player.importMutex.Unlock()
+	}
+
+	for _, reward := range bonus.Items {
+		if _, err := s.GrantItem(player, itemFromReward(reward)); err != nil {
+			player.log().Error("Failed to grant imported item", logging.Err(err))
+		}
+	}
+}
+
+// importedCredentialIDs 返回玩家已导入的外部凭证 ID，用于持久化
+func (p *Player) importedCredentialIDs() []string {
+	p.importMutex.Lock()
+	defer p.importMutex.Unlock()
+
+	ids := make([]string, 0, len(p.importedCredentials))
+	for id := range p.importedCredentials {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func hasCredentialType(credential *vc.SimpleCredential, credType string) bool {
+	for _, t := range credential.Type {
+		if t == credType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// criterion 编译后的成就条件表达式
+type criterion interface {
+	// eval 根据玩家统计判断条件是否满足，缺少的统计视为 0
+	eval(stats map[string]float64) bool
+	// comparisons 返回表达式中的所有比较，用于计算进度和需要跟踪的统计
+	comparisons() []*comparison
+}
+
+// comparison 统计与常量的比较，如 kill >= 10
+type comparison struct {
+	stat  string
+	op    string
+	value float64
+}
+
+func (c *comparison) eval(stats map[string]float64) bool {
+	current := stats[c.stat]
+	switch c.op {
+	case ">=":
+		return current >= c.value
+	case ">":
+		return current > c.value
+	case "<=":
+		return current <= c.value
+	case "<":
+		return current < c.value
+	case "==":
+		return current == c.value
+	case "!=":
+		return current != c.value
+	}
+	return false
+}
+
+func (c *comparison) comparisons() []*comparison {
+	return []*comparison{c}
+}
+
+// logical 多个条件的 && 或 ||
+type logical struct {
+	and   bool
+	terms []criterion
+}
+
+func (l *logical) eval(stats map[string]float64) bool {
+	for _, term := range l.terms {
+		if term.eval(stats) != l.and {
+			return !l.and
+		}
+	}
+	return l.and
+}
+
+func (l *logical) comparisons() []*comparison {
+	var all []*comparison
+	for _, term := range l.terms {
+		all = append(all, term.comparisons()...)
+	}
+	return all
+}
+
+// compileCriteria 编译成就条件表达式。表达式由比较（统计 运算符 数字）、&&、|| 和括号组成，&& 优先于 ||，
+// 例如 "kill >= 10 && (wins >= 3 || level >= 20)"。统计名由字母、数字、'_'、'-'、'.' 和 ':' 组成，以字母或 '_' 开头
+func compileCriteria(expression string) (criterion, error) {
+	tokens, err := tokenizeCriteria(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("criteria is empty")
+	}
+
+	p := &criteriaParser{tokens: tokens}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in criteria", p.tokens[p.pos])
+	}
+	return c, nil
+}
+
+// criteriaOperators 按长度从长到短排列，先匹配两个字符的运算符
+var criteriaOperators = []string{"&&", "||", ">=", "<=", "==", "!=", ">", "<", "(", ")"}
+
+func tokenizeCriteria(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		ch := rune(expression[i])
+		if unicode.IsSpace(ch) {
+			i++
+			continue
+		}
+
+		matched := false
+		for _, op := range criteriaOperators {
+			if strings.HasPrefix(expression[i:], op) {
+				tokens = append(tokens, op)
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		start := i
+		for i < len(expression) && isCriteriaNameChar(rune(expression[i])) {
+			i++
+		}
+		if start == i {
+			return nil, fmt.Errorf("unexpected character %q in criteria", expression[i])
+		}
+		tokens = append(tokens, expression[start:i])
+	}
+	return tokens, nil
+}
+
+func isCriteriaNameChar(ch rune) bool {
+	return ch < unicode.MaxASCII && (unicode.IsLetter(ch) || unicode.IsDigit(ch) || strings.ContainsRune("_-.:", ch))
+}
+
+// criteriaParser 递归下降解析器
+type criteriaParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *criteriaParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *criteriaParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *criteriaParser) parseOr() (criterion, error) {
+	return p.parseLogical("||", false, p.parseAnd)
+}
+
+func (p *criteriaParser) parseAnd() (criterion, error) {
+	return p.parseLogical("&&", true, p.parseTerm)
+}
+
+func (p *criteriaParser) parseLogical(op string, and bool, operand func() (criterion, error)) (criterion, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	terms := []criterion{first}
+	for p.peek() == op {
+		p.next()
+		term, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &logical{and: and, terms: terms}, nil
+}
+
+func (p *criteriaParser) parseTerm() (criterion, error) {
+	if p.peek() == "(" {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in criteria")
+		}
+		return c, nil
+	}
+
+	stat := p.next()
+	if stat == "" || !(unicode.IsLetter(rune(stat[0])) || stat[0] == '_') {
+		return nil, fmt.Errorf("expected a stat name in criteria, got %q", stat)
+	}
+	op := p.next()
+	switch op {
+	case ">=", ">", "<=", "<", "==", "!=":
+	default:
+		return nil, fmt.Errorf("expected a comparison after %s, got %q", stat, op)
+	}
+	raw := p.next()
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected a number after %s %s, got %q", stat, op, raw)
+	}
+	return &comparison{stat: stat, op: op, value: value}, nil
+}
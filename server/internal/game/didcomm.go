@@ -0,0 +1,255 @@
+package game
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// MsgTypeDIDComm 玩家间 DIDComm 加密消息，客户端发送明文内容，服务器推送加密后的消息
+const MsgTypeDIDComm = "didcomm"
+
+// ErrRecipientOffline 接收方未连接到本服务器
+var ErrRecipientOffline = errors.New("recipient is not connected")
+
+// SetDIDComm 启用 DIDComm 中继，玩家可通过 didcomm 消息互发加密消息
+func (s *SimpleServer) SetDIDComm(service *aries.DIDCommService) {
+	s.didcomm = service
+}
+
+// DeliverDIDComm 将加密消息推送给持有该 DID 的在线玩家，实现 aries.MessageRouter
+func (s *SimpleServer) DeliverDIDComm(recipientDID string, packed json.RawMessage) error {
+	recipient := s.findConnectedPlayerByDID(recipientDID)
+	if recipient == nil {
+		return fmt.Errorf("deliver to %s: %w", recipientDID, ErrRecipientOffline)
+	}
+
+	return writeMessage(recipient.Connection, Message{
+		Type:     MsgTypeDIDComm,
+		PlayerID: recipient.ID,
+		Data: map[string]interface{}{
+			"message": packed,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// SendDIDComm 以 msg.From 的身份加密消息并投递给 msg.To 中的每个 DID，实现 aries.MessageSender
+func (s *SimpleServer) SendDIDComm(ctx context.Context, msg *aries.Message) error {
+	if s.didcomm == nil {
+		return errors.New("DIDComm messaging is not enabled")
+	}
+
+	for _, recipientDID := range msg.To {
+		resolved, err := s.didService.ResolveDIDContext(ctx, recipientDID)
+		if err != nil {
+			return fmt.Errorf("resolve %s: %w", recipientDID, err)
+		}
+		recipients, err := keyAgreementRecipients(resolved.DIDDoc)
+		if err != nil {
+			return err
+		}
+
+		packed, err := s.didcomm.Pack(msg, recipients)
+		if err != nil {
+			return fmt.Errorf("pack message: %w", err)
+		}
+		if err := s.deliverPacked(ctx, recipientDID, resolved.DIDDoc, packed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findConnectedPlayerByDID 查找持有该 DID 且在线的玩家
+func (s *SimpleServer) findConnectedPlayerByDID(playerDID string) *Player {
+	if player := s.players.getByDID(playerDID); player != nil && player.Connection != nil {
+		return player
+	}
+	return nil
+}
+
+// handleDIDComm 以发送方 DID 加密消息并投递：接收方在线时直接推送，否则投递到其 DID 文档中的 DIDComm 端点
+func (s *SimpleServer) handleDIDComm(player *Player, payload *DIDCommPayload) {
+	if s.didcomm == nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeMessageUndeliverable, "error.didcomm_disabled", nil)
+		return
+	}
+
+	// 发给服务器自身（如凭证颁发者）的协议消息在本地处理，发送方为已认证的玩家 DID
+	if s.didcomm.Serves(payload.To) {
+		s.dispatchDIDComm(player, payload)
+		return
+	}
+
+	resolved, err := s.didService.ResolveDIDContext(player.traceContext(), payload.To)
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeInvalidDID, "error.resolve_recipient", i18n.Params{"error": err})
+		return
+	}
+
+	recipients, err := keyAgreementRecipients(resolved.DIDDoc)
+	if err != nil {
+		s.sendErrorToPlayer(player, ErrCodeMessageUndeliverable, err.Error())
+		return
+	}
+
+	msg := &aries.Message{
+		Type:        payload.Type,
+		From:        player.DID,
+		To:          []string{payload.To},
+		ThreadID:    payload.ThreadID,
+		Body:        payload.Body,
+		Attachments: payload.Attachments,
+	}
+	packed, err := s.didcomm.Pack(msg, recipients)
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeMessageUndeliverable, "error.pack_message", i18n.Params{"error": err})
+		return
+	}
+
+	if err := s.deliverPacked(player.traceContext(), payload.To, resolved.DIDDoc, packed); err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeMessageUndeliverable, "error.deliver_message", i18n.Params{"error": err})
+		return
+	}
+
+	s.confirmDIDComm(player, msg.ID, payload.To)
+}
+
+// dispatchDIDComm 将玩家发给本服务器 DID 的明文消息交给对应的协议处理函数
+func (s *SimpleServer) dispatchDIDComm(player *Player, payload *DIDCommPayload) {
+	msg := &aries.Message{
+		ID:          uuid.New().String(),
+		Type:        payload.Type,
+		From:        player.DID,
+		To:          []string{payload.To},
+		ThreadID:    payload.ThreadID,
+		CreatedTime: time.Now().Unix(),
+		Body:        payload.Body,
+		Attachments: payload.Attachments,
+	}
+	if err := s.didcomm.Dispatch(player.traceContext(), msg); err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeMessageUndeliverable, "error.handle_message", i18n.Params{"error": err})
+		return
+	}
+
+	s.confirmDIDComm(player, msg.ID, payload.To)
+}
+
+// confirmDIDComm 通知发送方消息已投递
+func (s *SimpleServer) confirmDIDComm(player *Player, messageID, to string) {
+	player.log().Info("Player sent DIDComm message", "message_id", messageID, "to", to)
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeDIDComm,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"id":        messageID,
+			"to":        to,
+			"delivered": true,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// deliverPacked 投递加密消息，外部端点的消息按 routingKeys 包装为 forward 消息
+func (s *SimpleServer) deliverPacked(ctx context.Context, recipientDID string, doc *did.DIDDocument, packed *aries.JWE) error {
+	data, err := json.Marshal(packed)
+	if err != nil {
+		return fmt.Errorf("marshal packed message: %w", err)
+	}
+
+	err = s.DeliverDIDComm(recipientDID, data)
+	if err == nil || !errors.Is(err, ErrRecipientOffline) {
+		return err
+	}
+
+	uri, routingKeys := didcommEndpoint(doc)
+	if uri == "" || uri == s.didcomm.Endpoint() {
+		return err
+	}
+
+	forwarded, err := s.didcomm.Forward(recipientDID, packed, routingKeys)
+	if err != nil {
+		return fmt.Errorf("wrap forward message: %w", err)
+	}
+	return s.didcomm.SendContext(ctx, uri, forwarded)
+}
+
+// keyAgreementRecipients 将 DID 文档中的 Ed25519 验证密钥转换为 X25519 密钥协商密钥
+func keyAgreementRecipients(doc *did.DIDDocument) ([]aries.Recipient, error) {
+	var recipients []aries.Recipient
+	for _, method := range doc.VerificationMethod {
+		publicKey, err := hex.DecodeString(method.PublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			continue
+		}
+
+		agreementKey, err := aries.Ed25519PublicToX25519(publicKey)
+		if err != nil {
+			continue
+		}
+		recipients = append(recipients, aries.Recipient{KID: method.ID, PublicKey: agreementKey})
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("recipient %s has no usable key agreement keys", doc.ID)
+	}
+	return recipients, nil
+}
+
+// DIDCommSenderKey 解析 authcrypt 消息 skid 所属的 DID，返回该 DID 认证密钥对应的 X25519 密钥，
+// 实现 aries.SenderKeyResolver。只接受认证密钥，确保发送方能以该 DID 的身份行事
+func (s *SimpleServer) DIDCommSenderKey(ctx context.Context, skid string) (*ecdh.PublicKey, error) {
+	senderDID, _, _ := strings.Cut(skid, "#")
+	resolved, err := s.didService.ResolveDIDContext(ctx, senderDID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", senderDID, err)
+	}
+
+	method, ok := resolved.DIDDoc.AuthenticationKey(skid)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an authentication key of %s", skid, senderDID)
+	}
+	publicKey, err := hex.DecodeString(method.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", skid)
+	}
+	return aries.Ed25519PublicToX25519(publicKey)
+}
+
+// didcommEndpoint 从 DID 文档中读取 DIDCommMessaging 服务的 uri 和 routingKeys
+func didcommEndpoint(doc *did.DIDDocument) (string, []string) {
+	for _, service := range doc.Service {
+		if service.Type != aries.ServiceTypeDIDComm {
+			continue
+		}
+
+		uri, _ := service.ServiceEndpoint["uri"].(string)
+		var routingKeys []string
+		switch keys := service.ServiceEndpoint["routingKeys"].(type) {
+		case []string:
+			routingKeys = keys
+		case []interface{}:
+			for _, key := range keys {
+				if k, ok := key.(string); ok {
+					routingKeys = append(routingKeys, k)
+				}
+			}
+		}
+		return uri, routingKeys
+	}
+	return "", nil
+}
@@ -0,0 +1,114 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// EntryRejectReason 进入房间被拒绝的原因，随错误一起返回给客户端
+type EntryRejectReason string
+
+const (
+	// RejectPasswordRequired 房间需要密码但未提供
+	RejectPasswordRequired EntryRejectReason = "password_required"
+	// RejectWrongPassword 密码错误
+	RejectWrongPassword EntryRejectReason = "wrong_password"
+	// RejectLevelTooLow 玩家等级低于房间要求
+	RejectLevelTooLow EntryRejectReason = "level_too_low"
+	// RejectPresentationInvalid 凭证表述未通过验证（签名、挑战值、持有者或撤销状态）
+	RejectPresentationInvalid EntryRejectReason = "presentation_invalid"
+	// RejectCredentialMissing 表述中缺少房间要求的凭证类型
+	RejectCredentialMissing EntryRejectReason = "credential_missing"
+	// RejectRequirementNotMet Present Proof 表述中没有满足房间属性条件（如等级下限）的凭证
+	RejectRequirementNotMet EntryRejectReason = "requirement_not_met"
+	// RejectInviteInvalid 邀请码格式或签名不正确
+	RejectInviteInvalid EntryRejectReason = "invite_invalid"
+	// RejectInviteExpired 邀请码已过期
+	RejectInviteExpired EntryRejectReason = "invite_expired"
+	// RejectInviteUsedUp 邀请码的使用次数已用完
+	RejectInviteUsedUp EntryRejectReason = "invite_used_up"
+	// RejectRoomFull 房间及其同组房间都已满，且溢出策略不允许排队或超员加入
+	RejectRoomFull EntryRejectReason = "room_full"
+	// RejectGuestsNotAllowed 游戏不接受访客，玩家的 DID 未在本服务登记
+	RejectGuestsNotAllowed EntryRejectReason = "guests_not_allowed"
+)
+
+// EntryRejection 进入房间被拒绝的结构化原因
+type EntryRejection struct {
+	Reason  EntryRejectReason
+	Message string
+	Details map[string]interface{}
+}
+
+// checkEntry 检查密码和等级要求，凭证要求由表述流程单独验证；持有邀请码的玩家不需要密码
+func (r *GameRoom) checkEntry(player *Player, password string, invited bool) *EntryRejection {
+	if r.passwordHash != "" && !invited {
+		if password == "" {
+			return &EntryRejection{Reason: RejectPasswordRequired, Message: "room requires a password"}
+		}
+		if !verifyRoomPassword(r.passwordHash, password) {
+			return &EntryRejection{Reason: RejectWrongPassword, Message: "wrong room password"}
+		}
+	}
+
+	if player.Level < r.MinLevel {
+		return &EntryRejection{
+			Reason:  RejectLevelTooLow,
+			Message: fmt.Sprintf("room requires level %d", r.MinLevel),
+			Details: map[string]interface{}{
+				"minLevel": r.MinLevel,
+				"level":    player.Level,
+			},
+		}
+	}
+
+	return nil
+}
+
+// sendEntryRejection 以结构化错误通知玩家进入房间被拒绝
+func (s *SimpleServer) sendEntryRejection(player *Player, code ErrorCode, roomID string, rejection *EntryRejection) {
+	if player.Connection == nil {
+		return
+	}
+
+	details := map[string]interface{}{"roomId": roomID}
+	for key, value := range rejection.Details {
+		details[key] = value
+	}
+
+	s.sendProtocolError(player.Connection, &ProtocolError{
+		Code:    code,
+		Message: rejection.Message,
+		Reason:  string(rejection.Reason),
+		Details: details,
+	})
+}
+
+// hashRoomPassword 生成带随机盐的房间密码摘要，格式为 <盐>$<SHA-256>
+func hashRoomPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate password salt: %w", err)
+	}
+
+	saltHex := hex.EncodeToString(salt)
+	return saltHex + "$" + roomPasswordDigest(saltHex, password), nil
+}
+
+// verifyRoomPassword 比较密码与摘要
+func verifyRoomPassword(hash, password string) bool {
+	salt, digest, found := strings.Cut(hash, "$")
+	if !found {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(digest), []byte(roomPasswordDigest(salt, password))) == 1
+}
+
+func roomPasswordDigest(salt, password string) string {
+	digest := sha256.Sum256([]byte(salt + password))
+	return hex.EncodeToString(digest[:])
+}
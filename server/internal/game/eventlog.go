@@ -0,0 +1,265 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+const (
+	// eventLogStoreName 游戏事件日志的存储名称
+	eventLogStoreName = "game_events"
+
+	recordTypeEvent = "event"
+	// eventRoomTag 按房间查询事件的标签
+	eventRoomTag = "room"
+)
+
+// EventLogConfig 事件日志配置
+type EventLogConfig struct {
+	Window        int           // 每个房间在 GameState.Events 中保留的最近事件数
+	FlushInterval time.Duration // 写回批次刷新间隔
+	BatchSize     int           // 待写入事件达到该数量时立即刷新
+}
+
+// DefaultEventLogConfig 返回默认事件日志配置
+func DefaultEventLogConfig() EventLogConfig {
+	return EventLogConfig{
+		Window:        100,
+		FlushInterval: time.Second,
+		BatchSize:     200,
+	}
+}
+
+// EventRecord 事件日志中的一条记录，Seq 在房间内从 1 开始递增
+type EventRecord struct {
+	RoomID string     `json:"roomId"`
+	Seq    uint64     `json:"seq"`
+	Event  *GameEvent `json:"event"`
+}
+
+// EventLog 房间游戏事件的追加日志，事件按房间分配序号后批量写入存储，可按房间重放
+type EventLog struct {
+	store  storage.Store
+	config EventLogConfig
+
+	// 房间最后分配的序号，首次追加时从存储加载
+	seqs    map[string]uint64
+	pending []storage.Operation
+	mutex   sync.Mutex
+
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	once    sync.Once
+}
+
+// NewEventLog 创建事件日志并启动后台刷新协程
+func NewEventLog(provider storage.Provider, config EventLogConfig) (*EventLog, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(eventLogStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open event store: %w", err)
+	}
+
+	defaults := DefaultEventLogConfig()
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+
+	eventLog := &EventLog{
+		store:   store,
+		config:  config,
+		seqs:    make(map[string]uint64),
+		flushCh: make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go eventLog.run()
+	return eventLog, nil
+}
+
+func (l *EventLog) run() {
+	defer close(l.doneCh)
+
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.flushAndLog()
+		case <-l.flushCh:
+			l.flushAndLog()
+		case <-l.stopCh:
+			l.flushAndLog()
+			return
+		}
+	}
+}
+
+// Close 停止后台协程并写入所有待写事件
+func (l *EventLog) Close() error {
+	l.once.Do(func() {
+		close(l.stopCh)
+	})
+	<-l.doneCh
+
+	return l.Flush()
+}
+
+// Append 为事件分配房间内的序号并加入待写队列
+func (l *EventLog) Append(roomID string, event *GameEvent) error {
+	l.mutex.Lock()
+	seq, err := l.lastSeq(roomID)
+	if err != nil {
+		l.mutex.Unlock()
+		return err
+	}
+	seq++
+
+	event.Seq = seq
+	data, err := json.Marshal(EventRecord{RoomID: roomID, Seq: seq, Event: event})
+	if err != nil {
+		l.mutex.Unlock()
+		return fmt.Errorf("marshal event record: %w", err)
+	}
+
+	l.seqs[roomID] = seq
+	l.pending = append(l.pending, storage.Operation{
+		Key:   eventKey(roomID, seq),
+		Value: data,
+		Tags: []storage.Tag{
+			{Name: recordTypeTag, Value: recordTypeEvent},
+			{Name: eventRoomTag, Value: roomID},
+		},
+	})
+	full := len(l.pending) >= l.config.BatchSize
+	l.mutex.Unlock()
+
+	if full {
+		select {
+		case l.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// lastSeq 返回房间最后分配的序号，调用方需持有锁
+func (l *EventLog) lastSeq(roomID string) (uint64, error) {
+	if seq, loaded := l.seqs[roomID]; loaded {
+		return seq, nil
+	}
+
+	var seq uint64
+	err := l.query(roomID, func(record *EventRecord) {
+		if record.Seq > seq {
+			seq = record.Seq
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	l.seqs[roomID] = seq
+	return seq, nil
+}
+
+// Flush 批量写入所有待写事件
+func (l *EventLog) Flush() error {
+	l.mutex.Lock()
+	ops := l.pending
+	l.pending = nil
+	l.mutex.Unlock()
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err := l.store.Batch(ops); err != nil {
+		// 写入失败时放回队列头部，保持事件顺序
+		l.mutex.Lock()
+		l.pending = append(ops, l.pending...)
+		l.mutex.Unlock()
+		return fmt.Errorf("batch write events: %w", err)
+	}
+	return nil
+}
+
+func (l *EventLog) flushAndLog() {
+	if err := l.Flush(); err != nil {
+		slog.Error("Failed to flush event log", logging.Err(err))
+	}
+}
+
+// Replay 按序号顺序返回房间在 after 之后的事件，limit 为 0 时返回全部
+func (l *EventLog) Replay(roomID string, after uint64, limit int) ([]*EventRecord, error) {
+	if err := l.Flush(); err != nil {
+		return nil, err
+	}
+
+	var records []*EventRecord
+	err := l.query(roomID, func(record *EventRecord) {
+		if record.Seq > after {
+			records = append(records, record)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// query 遍历存储中房间的所有事件
+func (l *EventLog) query(roomID string, visit func(record *EventRecord)) error {
+	return queryRecords(l.store, eventRoomTag, roomID, func(data []byte) error {
+		var record EventRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("unmarshal event record: %w", err)
+		}
+		visit(&record)
+		return nil
+	})
+}
+
+func eventKey(roomID string, seq uint64) string {
+	return fmt.Sprintf("event:%s:%020d", roomID, seq)
+}
+
+// SetEventLog 使用持久化的事件日志替换默认的内存实现并关闭原日志，应在接受连接前调用
+func (s *SimpleServer) SetEventLog(eventLog *EventLog) {
+	if s.eventLog != nil {
+		if err := s.eventLog.Close(); err != nil {
+			slog.Error("Failed to close event log", logging.Err(err))
+		}
+	}
+	s.eventLog = eventLog
+}
+
+// RoomEvents 重放房间在 after 之后的事件，房间已删除时仍可查询其历史
+func (s *SimpleServer) RoomEvents(roomID string, after uint64, limit int) ([]*EventRecord, error) {
+	return s.eventLog.Replay(roomID, after, limit)
+}
@@ -0,0 +1,175 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// MsgTypeLevelUp 玩家升级通知
+const MsgTypeLevelUp = "level_up"
+
+// ExperienceConfig 经验和等级曲线配置
+type ExperienceConfig struct {
+	// 从 1 级升到 2 级所需经验，之后每级所需经验按 Growth 倍增长
+	BaseXP   int
+	Growth   float64
+	MaxLevel int
+	// 按游戏事件类型发放的经验，未列出的事件不发放
+	EventXP map[string]int
+	// 升级时是否颁发等级凭证
+	IssueCredentials bool
+}
+
+// DefaultExperienceConfig 返回默认经验配置
+func DefaultExperienceConfig() ExperienceConfig {
+	return ExperienceConfig{
+		BaseXP:   100,
+		Growth:   1.5,
+		MaxLevel: 50,
+		EventXP: map[string]int{
+			EventInteraction:   5,
+			EventKill:          20,
+			EventTaskCompleted: 50,
+		},
+		IssueCredentials: true,
+	}
+}
+
+// XPForLevel 返回达到指定等级所需的累计经验
+func (c ExperienceConfig) XPForLevel(level int) int {
+	total := 0
+	for l := 1; l < level; l++ {
+		total += int(math.Round(float64(c.BaseXP) * math.Pow(c.Growth, float64(l-1))))
+	}
+	return total
+}
+
+// LevelForXP 返回累计经验对应的等级，不超过 MaxLevel
+func (c ExperienceConfig) LevelForXP(xp int) int {
+	level := 1
+	for level < c.MaxLevel && xp >= c.XPForLevel(level+1) {
+		level++
+	}
+	return level
+}
+
+// SetExperienceConfig 替换经验和等级曲线配置，应在接受连接前调用
+func (s *SimpleServer) SetExperienceConfig(config ExperienceConfig) {
+	s.experience = config
+}
+
+// GrantExperience 为房间内的玩家增加经验，跨过等级阈值时广播 level_up 并颁发等级凭证
+func (s *SimpleServer) GrantExperience(room *GameRoom, player *Player, amount int, source string) {
+	if amount <= 0 {
+		return
+	}
+
+	room.mutex.Lock()
+	previous := player.Level
+	player.Experience += amount
+	level := s.experience.LevelForXP(player.Experience)
+	// 等级只升不降，管理员设置的等级高于经验对应等级时保持不变
+	if level > player.Level {
+		player.Level = level
+	}
+	experience := player.Experience
+	level = player.Level
+	room.mutex.Unlock()
+
+	s.persistPlayer(player)
+	if level == previous {
+		return
+	}
+
+	player.log().Info("Player leveled up", "level", level, "experience", experience, "source", source)
+	s.recordLevelStat(room, player, level)
+
+	data := map[string]interface{}{
+		"level":         level,
+		"previousLevel": previous,
+		"experience":    experience,
+		"source":        source,
+	}
+	data["skillPoints"] = s.skillPoints(player)
+	if level < s.experience.MaxLevel {
+		data["nextLevelXP"] = s.experience.XPForLevel(level + 1)
+	}
+	s.broadcastToRoom(room, Message{
+		Type:      MsgTypeLevelUp,
+		PlayerID:  player.ID,
+		RoomID:    room.ID,
+		Data:      data,
+		Timestamp: time.Now(),
+	}, "")
+
+	if s.experience.IssueCredentials {
+		s.issueLevelCredential(room, player, level)
+	}
+}
+
+// CredentialClaims 按玩家当前等级生成自助申请的 LevelCredential 主体，实现 vc.ClaimSource。
+// 声明只取自服务器保存的玩家状态，访客和本实例上没有记录的玩家不能申请
+func (s *SimpleServer) CredentialClaims(ctx context.Context, playerDID, credType string) (vc.CredentialSubject, error) {
+	if credType != "LevelCredential" {
+		return vc.CredentialSubject{}, fmt.Errorf("no claims for credential type %s", credType)
+	}
+	player := s.findPlayerByDID(playerDID)
+	if player == nil || player.isGuest() {
+		return vc.CredentialSubject{}, ErrPlayerNotFound
+	}
+
+	gameID := "default"
+	if room := player.Room; room != nil {
+		gameID = room.GameID
+	}
+	return vc.CredentialSubject{
+		PlayerID: player.ID,
+		GameID:   gameID,
+		Level:    player.Level,
+		Attributes: map[string]interface{}{
+			"category": "level",
+		},
+	}, nil
+}
+
+// issueLevelCredential 为升级的玩家颁发等级凭证
+func (s *SimpleServer) issueLevelCredential(room *GameRoom, player *Player, level int) {
+	if player.isGuest() {
+		return
+	}
+	credential, err := s.vcService.IssueLevelCredential(player.traceContext(), player.DID, room.GameID, player.ID, level)
+	if err != nil {
+		player.log().Error("Failed to issue level credential", logging.Err(err))
+		return
+	}
+
+	if player.Connection != nil {
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeCredential,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"credential": credential,
+				"message":    s.localize(player, "credential.level", i18n.Params{"level": level}),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// experienceFromReward 返回经验奖励的经验值
+func experienceFromReward(reward *Reward) int {
+	switch value := reward.Value.(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,208 @@
+package game
+
+import (
+	"time"
+)
+
+// MsgTypeStateDelta 其他实例上房间玩家的位置增量，本实例的玩家通过 state_sync 同步
+const MsgTypeStateDelta = "state_delta"
+
+// DefaultTickRate 默认每秒模拟次数
+const DefaultTickRate = 20
+
+// 玩家输入类型
+const (
+	inputMove   = "move"
+	inputAttack = "attack"
+)
+
+// playerInput 等待在下一个 tick 处理的玩家输入
+type playerInput struct {
+	Seq        uint64 // 服务器分配的序号，保证处理顺序确定
+	PlayerID   string
+	Type       string
+	Position   Position
+	TargetID   string
+	ReceivedAt time.Time
+}
+
+// StateDelta 位置增量，仅包含自上次同步以来变化的部分
+type StateDelta struct {
+	Tick    uint64              `json:"tick"`
+	Players map[string]Position `json:"players,omitempty"`
+	Removed []string            `json:"removed,omitempty"`
+}
+
+// empty 判断快照是否没有任何变化
+func (d *StateDelta) empty() bool {
+	return len(d.Players) == 0 && len(d.Removed) == 0
+}
+
+// enqueueInput 将玩家输入加入房间队列
+func (r *GameRoom) enqueueInput(input playerInput) {
+	r.inputMutex.Lock()
+	defer r.inputMutex.Unlock()
+
+	r.inputSeq++
+	input.Seq = r.inputSeq
+	r.inputs = append(r.inputs, input)
+}
+
+// drainInputs 取出所有待处理输入，按序号排列
+func (r *GameRoom) drainInputs() []playerInput {
+	r.inputMutex.Lock()
+	defer r.inputMutex.Unlock()
+
+	inputs := r.inputs
+	r.inputs = nil
+	return inputs
+}
+
+// stopLoop 停止房间模拟循环，可重复调用
+func (r *GameRoom) stopLoop() {
+	r.stopOnce.Do(func() {
+		close(r.loopStop)
+	})
+}
+
+// startRoomLoop 为房间启动固定频率的模拟循环
+func (s *SimpleServer) startRoomLoop(room *GameRoom) {
+	room.loopStop = make(chan struct{})
+	room.sync.players = make(map[string]PlayerState)
+	room.physics.config = s.physics
+	room.mutex.Lock()
+	room.spawnMapNPCs()
+	room.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(s.tickInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-room.loopStop:
+				return
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				started := time.Now()
+				s.tickRoom(room, now)
+				room.load.recordTick(started, time.Since(started))
+			}
+		}
+	}()
+}
+
+// tickInterval 返回一个 tick 的时长
+func (s *SimpleServer) tickInterval() time.Duration {
+	return time.Second / time.Duration(s.tickRate)
+}
+
+// tickRoom 推进一个 tick：按顺序处理输入、推进物理模拟和 NPC、推进游戏状态并同步状态增量
+func (s *SimpleServer) tickRoom(room *GameRoom, now time.Time) {
+	inputs := room.drainInputs()
+
+	var corrections []*Player
+	var results []moveResult
+	var speeding []*Player
+	var excess []float64
+	var moved []*Player
+	var combat []*CombatEvent
+
+	room.mutex.Lock()
+	room.GameState.Tick++
+	tick := room.GameState.Tick
+
+	for _, input := range inputs {
+		player, exists := room.Players[input.PlayerID]
+		// 死亡的玩家在重生前不能移动和攻击
+		if !exists || player.dead() {
+			continue
+		}
+
+		switch input.Type {
+		case inputMove:
+			elapsed := s.movement.MaxInterval
+			if !player.lastMoveAt.IsZero() {
+				elapsed = input.ReceivedAt.Sub(player.lastMoveAt)
+			}
+
+			result := validateMove(room.GameState.Map, player.Position, input.Position, elapsed, s.movement)
+			if result.Corrected {
+				corrections = append(corrections, player)
+				results = append(results, result)
+			}
+			if result.Excess > 0 {
+				speeding = append(speeding, player)
+				excess = append(excess, result.Excess)
+			}
+			if result.Position != player.Position {
+				player.Position = result.Position
+				player.lastMoveAt = input.ReceivedAt
+				moved = append(moved, player)
+			}
+		case inputAttack:
+			combat = append(combat, s.resolveAttack(room, player, input.TargetID, input.ReceivedAt)...)
+		}
+	}
+
+	physics := room.stepPhysics(tick, s.tickInterval(), now, s.movement.PlayerRadius)
+	if physics != nil {
+		combat = append(combat, s.applyProjectileHits(room, physics.Collisions, now)...)
+	}
+	npcMoves, npcCombat := s.tickNPCs(room, now)
+	combat = append(combat, npcCombat...)
+	if len(npcMoves) > 0 {
+		if physics == nil {
+			physics = &PhysicsUpdate{Tick: tick, Objects: make(map[string]Position)}
+		}
+		for objectID, position := range npcMoves {
+			physics.Objects[objectID] = position
+		}
+	}
+	combat = append(combat, s.respawnPlayers(room, now)...)
+	if room.mode != nil {
+		room.mode.OnTick(room, tick, now)
+	}
+	result := room.checkResult(now)
+
+	delta := room.advanceSync(tick)
+	room.mutex.Unlock()
+
+	if result != nil {
+		s.announceResult(room, result)
+	}
+
+	s.announceCombat(room, combat)
+	for i, player := range corrections {
+		s.sendPositionCorrection(player, room.ID, results[i])
+	}
+	for i, player := range speeding {
+		s.checkMove(player, room.ID, excess[i])
+	}
+	for _, player := range moved {
+		s.persistPlayer(player)
+		s.emitEvent(room, player, EventMovement, "")
+	}
+
+	// 每个实例独立模拟自己的房间，物理更新只发给本实例的玩家
+	if physics != nil {
+		s.broadcastToLocalPlayers(room, Message{
+			Type:      MsgTypePhysics,
+			RoomID:    room.ID,
+			Data:      physics,
+			Timestamp: now,
+		}, "")
+	}
+	if delta != nil {
+		s.syncLocalPlayers(room, delta)
+		if positions := delta.positions(); !positions.empty() {
+			s.publishRoom(room.ID, Message{
+				Type:      MsgTypeStateDelta,
+				RoomID:    room.ID,
+				Data:      positions,
+				Timestamp: now,
+			}, "")
+		}
+	}
+}
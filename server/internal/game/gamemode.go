@@ -0,0 +1,310 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MsgTypeGameOver 房间分出胜负时广播的结果
+const MsgTypeGameOver = "game_over"
+
+// 房间游戏状态
+const (
+	GameStatusWaiting  = "waiting"
+	GameStatusPlaying  = "playing"
+	GameStatusFinished = "finished"
+)
+
+// 内置游戏模式
+const (
+	ModeFreeRoam = "free-roam"
+	ModeTaskRace = "task-race"
+)
+
+// DefaultGameMode 创建房间时未指定模式使用的游戏模式
+const DefaultGameMode = ModeFreeRoam
+
+// GameMode 游戏模式插件，决定房间的初始状态、玩家加入和每个 tick 的处理以及胜负判定。
+// 同一个模式实例由所有房间共享，房间相关的状态应保存在 GameState.Properties 中；
+// 除 Init 外的回调都在持有 room.mutex 时调用，不能阻塞或再次获取房间锁
+type GameMode interface {
+	// Name 模式名称，创建房间时按名称选择模式
+	Name() string
+	// Init 返回新房间的初始游戏状态
+	Init(room *GameRoom) *GameState
+	// OnPlayerJoin 玩家加入房间后调用，此时玩家已分配出生点和队伍
+	OnPlayerJoin(room *GameRoom, player *Player)
+	// OnTick 每个模拟 tick 在处理完玩家输入后调用
+	OnTick(room *GameRoom, tick uint64, now time.Time)
+	// OnEvent 房间记录游戏事件后调用
+	OnEvent(room *GameRoom, event *GameEvent)
+	// CheckWinCondition 返回对局结果，尚未分出胜负时返回 nil
+	CheckWinCondition(room *GameRoom) *GameResult
+}
+
+// GameResult 对局结果，Winner 为玩家或队伍 ID，平局时为空
+type GameResult struct {
+	Winner string         `json:"winner,omitempty"`
+	Reason string         `json:"reason"`
+	Scores map[string]int `json:"scores,omitempty"`
+}
+
+// RegisterGameMode 注册游戏模式，应在接受连接前调用
+func (s *SimpleServer) RegisterGameMode(mode GameMode) error {
+	name := mode.Name()
+	if name == "" {
+		return errors.New("game mode name is required")
+	}
+	if _, exists := s.gameModes[name]; exists {
+		return fmt.Errorf("game mode already registered: %s", name)
+	}
+
+	s.gameModes[name] = mode
+	return nil
+}
+
+// GameModes 返回已注册的游戏模式名称
+func (s *SimpleServer) GameModes() []string {
+	names := make([]string, 0, len(s.gameModes))
+	for name := range s.gameModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gameMode 按名称查找游戏模式，名称为空时返回默认模式
+func (s *SimpleServer) gameMode(name string) (GameMode, bool) {
+	if name == "" {
+		name = DefaultGameMode
+	}
+	mode, exists := s.gameModes[name]
+	return mode, exists
+}
+
+// checkResult 判定房间胜负，分出胜负时结束对局并返回结果，调用方需持有 room.mutex
+func (r *GameRoom) checkResult(now time.Time) *GameResult {
+	if r.mode == nil || r.GameState.Status == GameStatusFinished {
+		return nil
+	}
+
+	result := r.mode.CheckWinCondition(r)
+	if result == nil {
+		return nil
+	}
+
+	r.GameState.Status = GameStatusFinished
+	r.GameState.EndTime = &now
+	r.GameState.Properties["result"] = result
+	return result
+}
+
+// announceResult 保存房间状态和对局结果，并向房间广播 game_over
+func (s *SimpleServer) announceResult(room *GameRoom, result *GameResult) {
+	room.mutex.RLock()
+	match := s.matchResult(room, result)
+	room.mutex.RUnlock()
+
+	s.saveResult(match)
+	s.publishMatchFinished(match)
+	s.recordMatchStats(room, match)
+	s.persistRoom(room)
+	s.broadcastToRoom(room, Message{
+		Type:      MsgTypeGameOver,
+		RoomID:    room.ID,
+		Data:      result,
+		Timestamp: time.Now(),
+	}, "")
+
+	room.log().Info("Room finished", "winner", result.Winner, "reason", result.Reason)
+}
+
+// FreeRoamMode 自由探索模式：玩家加入即开始，没有胜负
+type FreeRoamMode struct{}
+
+// Name 实现 GameMode
+func (FreeRoamMode) Name() string { return ModeFreeRoam }
+
+// Init 使用默认地图和新手任务
+func (FreeRoamMode) Init(room *GameRoom) *GameState {
+	return defaultGameState()
+}
+
+// OnPlayerJoin 第一个玩家加入时开始游戏
+func (FreeRoamMode) OnPlayerJoin(room *GameRoom, player *Player) {
+	startGame(room.GameState, time.Now())
+}
+
+// OnTick 实现 GameMode
+func (FreeRoamMode) OnTick(room *GameRoom, tick uint64, now time.Time) {}
+
+// OnEvent 实现 GameMode
+func (FreeRoamMode) OnEvent(room *GameRoom, event *GameEvent) {}
+
+// CheckWinCondition 自由探索模式不会结束
+func (FreeRoamMode) CheckWinCondition(room *GameRoom) *GameResult { return nil }
+
+// 竞速模式的结束原因
+const (
+	RaceResultMajority  = "majority"            // 领先者完成的任务已超过半数
+	RaceResultCompleted = "all_tasks_completed" // 所有任务都已完成
+	RaceResultTimeLimit = "time_limit"          // 达到时间限制
+)
+
+// raceScoresKey 竞速模式在 GameState.Properties 中保存各玩家完成任务数的键
+const raceScoresKey = "scores"
+
+// TaskRaceMode 任务竞速模式：人数达到 MinPlayers 后开放所有检查点任务，
+// 每个任务只能由最先完成的玩家获得，完成任务最多的玩家获胜
+type TaskRaceMode struct {
+	MinPlayers int           // 开始比赛所需的玩家数
+	TimeLimit  time.Duration // 比赛时长，0 表示不限时
+}
+
+// NewTaskRaceMode 创建默认配置的任务竞速模式
+func NewTaskRaceMode() *TaskRaceMode {
+	return &TaskRaceMode{
+		MinPlayers: 2,
+		TimeLimit:  5 * time.Minute,
+	}
+}
+
+// Name 实现 GameMode
+func (m *TaskRaceMode) Name() string { return ModeTaskRace }
+
+// Init 在默认地图上放置检查点，每个检查点对应一个尚未开放的任务
+func (m *TaskRaceMode) Init(room *GameRoom) *GameState {
+	state := defaultGameState()
+	state.Tasks = nil
+
+	checkpoints := []Position{{X: 150, Y: 450}, {X: 400, Y: 120}, {X: 650, Y: 450}, {X: 400, Y: 300}, {X: 700, Y: 100}}
+	for i, position := range checkpoints {
+		id := fmt.Sprintf("checkpoint-%d", i+1)
+		state.Map.Objects = append(state.Map.Objects, &MapObject{
+			ID:         id,
+			Type:       "checkpoint",
+			Position:   position,
+			Width:      20,
+			Height:     20,
+			Properties: map[string]interface{}{},
+		})
+		state.Tasks = append(state.Tasks, &Task{
+			ID:          "race-" + id,
+			Name:        fmt.Sprintf("Checkpoint %d", i+1),
+			Description: "Be the first to reach the checkpoint",
+			Type:        "race",
+			Status:      TaskLocked,
+			Objectives: []*Objective{
+				{
+					ID:          "reach-" + id,
+					Description: "Interact with " + id,
+					Type:        EventInteraction,
+					Target:      id,
+					Required:    1,
+				},
+			},
+		})
+	}
+
+	state.Properties[raceScoresKey] = map[string]interface{}{}
+	return state
+}
+
+// OnPlayerJoin 人数达到要求时开始比赛并开放任务
+func (m *TaskRaceMode) OnPlayerJoin(room *GameRoom, player *Player) {
+	if len(room.Players) < m.MinPlayers {
+		return
+	}
+	if startGame(room.GameState, time.Now()) {
+		for _, task := range room.GameState.Tasks {
+			if task.Status == TaskLocked {
+				task.Status = TaskAvailable
+			}
+		}
+	}
+}
+
+// OnTick 实现 GameMode
+func (m *TaskRaceMode) OnTick(room *GameRoom, tick uint64, now time.Time) {}
+
+// OnEvent 比赛进行中时，玩家每完成一个任务得一分
+func (m *TaskRaceMode) OnEvent(room *GameRoom, event *GameEvent) {
+	if event.Type != EventTaskCompleted || room.GameState.Status != GameStatusPlaying {
+		return
+	}
+
+	scores := raceScores(room.GameState)
+	scores[event.PlayerID]++
+
+	stored := make(map[string]interface{}, len(scores))
+	for playerID, score := range scores {
+		stored[playerID] = score
+	}
+	room.GameState.Properties[raceScoresKey] = stored
+}
+
+// CheckWinCondition 领先者过半、所有任务完成或时间用尽时结束比赛
+func (m *TaskRaceMode) CheckWinCondition(room *GameRoom) *GameResult {
+	state := room.GameState
+	if state.Status != GameStatusPlaying {
+		return nil
+	}
+
+	scores := raceScores(state)
+	leader, best, tied := "", 0, false
+	completed := 0
+	for playerID, score := range scores {
+		completed += score
+		switch {
+		case score > best:
+			leader, best, tied = playerID, score, false
+		case score == best:
+			tied = true
+		}
+	}
+
+	var reason string
+	switch {
+	case best*2 > len(state.Tasks):
+		reason = RaceResultMajority
+	case completed >= len(state.Tasks):
+		reason = RaceResultCompleted
+	case m.TimeLimit > 0 && state.StartTime != nil && time.Since(*state.StartTime) >= m.TimeLimit:
+		reason = RaceResultTimeLimit
+	default:
+		return nil
+	}
+
+	result := &GameResult{Reason: reason, Scores: scores}
+	if !tied {
+		result.Winner = leader
+	}
+	return result
+}
+
+// raceScores 读取各玩家完成的任务数，兼容从存储恢复后的 JSON 数值
+func raceScores(state *GameState) map[string]int {
+	scores := make(map[string]int)
+	stored, _ := state.Properties[raceScoresKey].(map[string]interface{})
+	for playerID, value := range stored {
+		switch score := value.(type) {
+		case int:
+			scores[playerID] = score
+		case float64:
+			scores[playerID] = int(score)
+		}
+	}
+	return scores
+}
+
+// startGame 将等待中的对局切换为进行中，返回是否发生了切换
+func startGame(state *GameState, now time.Time) bool {
+	if state.Status != GameStatusWaiting {
+		return false
+	}
+	state.Status = GameStatusPlaying
+	state.StartTime = &now
+	return true
+}
@@ -0,0 +1,501 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GamesPath 游戏目录接口路径，单个游戏为 GamesPath + "/{id}"
+const GamesPath = "/api/games"
+
+// GameInfo 游戏信息
+type GameInfo struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Settings GameSettings `json:"settings"`
+	// IssuerDID 游戏自己的颁发者 DID，为空时游戏凭证由服务器颁发者签发
+	IssuerDID string `json:"issuerDid,omitempty"`
+	// Status 游戏状态，只在 Games 和 /api/games 返回的副本中填写，由维护模式决定
+	Status GameStatus `json:"status,omitempty"`
+	// MaintenanceMessage 维护模式的提示信息
+	MaintenanceMessage string `json:"maintenanceMessage,omitempty"`
+}
+
+// GameSettings 游戏配置
+type GameSettings struct {
+	// TrustedIssuers 信任的其他游戏的颁发者 DID，玩家可以导入这些颁发者颁发的凭证
+	TrustedIssuers []string `json:"trustedIssuers,omitempty"`
+	// CredentialBonuses 导入的凭证类型对应的奖励
+	CredentialBonuses []CredentialBonus `json:"credentialBonuses,omitempty"`
+	// Capacity 房间容量策略，为空时房间只受人数上限限制
+	Capacity *CapacityPolicy `json:"capacity,omitempty"`
+	// MaxPlayers 新建房间的人数上限，为 0 时使用 defaultRoomMaxPlayers
+	MaxPlayers int `json:"maxPlayers,omitempty"`
+	// GuestPolicy 访客能否进入游戏的房间和匹配，为空时允许
+	GuestPolicy GuestPolicy `json:"guestPolicy,omitempty"`
+}
+
+// GameStatus 游戏状态
+type GameStatus string
+
+const (
+	// GameActive 玩家可以加入游戏的房间和匹配
+	GameActive GameStatus = "active"
+	// GameMaintenance 游戏处于维护模式，见 SetMaintenance
+	GameMaintenance GameStatus = "maintenance"
+)
+
+// GuestPolicy 访客策略。访客是 DID 未在本服务登记的玩家，如直接使用 did:key 或外部 did:web 认证
+type GuestPolicy string
+
+const (
+	// GuestAllow 访客和登记的玩家一样可以进入
+	GuestAllow GuestPolicy = "allow"
+	// GuestDeny 访客不能进入房间和匹配
+	GuestDeny GuestPolicy = "deny"
+)
+
+// 游戏配置的取值范围
+const (
+	defaultRoomMaxPlayers = 10
+	maxRoomMaxPlayers     = 1000
+	maxGameNameLength     = 64
+)
+
+// gameIDPattern 游戏 ID 只能包含小写字母、数字、连字符和下划线
+var gameIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// 游戏目录的错误
+var (
+	ErrGameNotFound      = errors.New("game not found")
+	ErrGameExists        = errors.New("game already exists")
+	ErrDefaultGame       = errors.New("the default game cannot be deleted")
+	ErrInvalidGameStatus = errors.New("invalid game status")
+)
+
+// GameValidationError 游戏信息未通过校验
+type GameValidationError struct {
+	Message string
+}
+
+func (e *GameValidationError) Error() string {
+	return e.Message
+}
+
+func invalidGame(format string, args ...interface{}) error {
+	return &GameValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// validate 校验游戏名称和配置
+func (info *GameInfo) validate() error {
+	if !gameIDPattern.MatchString(info.ID) {
+		return invalidGame("game ID must be 1-64 lowercase letters, digits, '-' or '_': %q", info.ID)
+	}
+	if info.Name == "" || len(info.Name) > maxGameNameLength {
+		return invalidGame("game name must be 1-%d characters", maxGameNameLength)
+	}
+	return info.Settings.validate()
+}
+
+// validate 校验游戏配置
+func (settings *GameSettings) validate() error {
+	for _, issuer := range settings.TrustedIssuers {
+		if !strings.HasPrefix(issuer, "did:") {
+			return invalidGame("invalid issuer DID: %s", issuer)
+		}
+	}
+	for _, bonus := range settings.CredentialBonuses {
+		if bonus.CredentialType == "" {
+			return invalidGame("credential bonus requires a credentialType")
+		}
+		if bonus.StartingLevel < 0 {
+			return invalidGame("startingLevel must not be negative")
+		}
+	}
+	if settings.Capacity != nil {
+		if err := settings.Capacity.validate(); err != nil {
+			return invalidGame("%v", err)
+		}
+	}
+	if settings.MaxPlayers < 0 || settings.MaxPlayers > maxRoomMaxPlayers {
+		return invalidGame("maxPlayers must be between 0 and %d", maxRoomMaxPlayers)
+	}
+	switch settings.GuestPolicy {
+	case "", GuestAllow, GuestDeny:
+	default:
+		return invalidGame("unsupported guest policy: %s", settings.GuestPolicy)
+	}
+	return nil
+}
+
+// clone 返回游戏信息的副本，修改副本不影响目录中的游戏
+func (info *GameInfo) clone() *GameInfo {
+	copied := *info
+	copied.Settings = info.Settings.clone()
+	return &copied
+}
+
+// clone 返回配置的副本
+func (settings *GameSettings) clone() GameSettings {
+	copied := *settings
+	copied.TrustedIssuers = append([]string(nil), settings.TrustedIssuers...)
+	copied.CredentialBonuses = append([]CredentialBonus(nil), settings.CredentialBonuses...)
+	if settings.Capacity != nil {
+		capacity := *settings.Capacity
+		copied.Capacity = &capacity
+	}
+	return copied
+}
+
+// CredentialBonus 导入某类外部凭证时发放的奖励
+type CredentialBonus struct {
+	CredentialType string `json:"credentialType"`
+	// StartingLevel 玩家等级低于该值时提升到该等级
+	StartingLevel int    `json:"startingLevel,omitempty"`
+	Title         string `json:"title,omitempty"`
+	// Items 发放的道具，格式与任务的道具奖励相同
+	Items []*Reward `json:"items,omitempty"`
+}
+
+// defaultGameInfo 默认游戏的信息，未信任任何外部颁发者，使用默认的房间容量策略
+func defaultGameInfo() *GameInfo {
+	return &GameInfo{
+		ID:   defaultGameID,
+		Name: "Default Game",
+		Settings: GameSettings{
+			Capacity: DefaultCapacityPolicy(),
+			CredentialBonuses: []CredentialBonus{
+				{CredentialType: "LevelCredential", StartingLevel: 5},
+				{CredentialType: "AchievementCredential", Title: "Veteran"},
+				{
+					CredentialType: "ItemCredential",
+					Items: []*Reward{{
+						Type:       RewardItem,
+						Value:      "Traveler's Token",
+						Properties: map[string]interface{}{"type": "token", "rarity": RarityUncommon},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// RegisterGame 注册或替换游戏信息，应在接受连接前调用
+func (s *SimpleServer) RegisterGame(info *GameInfo) error {
+	if info.ID == "" {
+		return errors.New("game ID is required")
+	}
+
+	s.gamesMutex.Lock()
+	s.games[info.ID] = info
+	s.gamesMutex.Unlock()
+	return nil
+}
+
+// Game 返回已注册的游戏信息。运行时的修改替换目录中的游戏而不修改已返回的信息，调用方不能修改返回值
+func (s *SimpleServer) Game(gameID string) (*GameInfo, bool) {
+	s.gamesMutex.RLock()
+	defer s.gamesMutex.RUnlock()
+
+	info, ok := s.games[gameID]
+	return info, ok
+}
+
+// Games 返回按 ID 排序的游戏目录副本，并填写各游戏的状态
+func (s *SimpleServer) Games() []*GameInfo {
+	s.gamesMutex.RLock()
+	games := make([]*GameInfo, 0, len(s.games))
+	for _, info := range s.games {
+		games = append(games, info.clone())
+	}
+	s.gamesMutex.RUnlock()
+
+	sort.Slice(games, func(i, j int) bool { return games[i].ID < games[j].ID })
+	for _, info := range games {
+		s.fillGameStatus(info)
+	}
+	return games
+}
+
+// GameDetails 返回单个游戏的副本并填写状态
+func (s *SimpleServer) GameDetails(gameID string) (*GameInfo, error) {
+	info, ok := s.Game(gameID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGameNotFound, gameID)
+	}
+
+	copied := info.clone()
+	s.fillGameStatus(copied)
+	return copied, nil
+}
+
+// fillGameStatus 按维护模式填写游戏状态
+func (s *SimpleServer) fillGameStatus(info *GameInfo) {
+	info.Status = GameActive
+	info.MaintenanceMessage = ""
+	if message, inMaintenance := s.maintenanceFor(info.ID); inMaintenance {
+		info.Status = GameMaintenance
+		info.MaintenanceMessage = message
+	}
+}
+
+// CreateGame 在运行时向目录添加游戏，游戏信息需通过校验。游戏目录只保存在本实例的内存中
+func (s *SimpleServer) CreateGame(info *GameInfo) (*GameInfo, error) {
+	info = info.clone()
+	info.Status = ""
+	info.MaintenanceMessage = ""
+	if err := info.validate(); err != nil {
+		return nil, err
+	}
+
+	s.gamesMutex.Lock()
+	if _, exists := s.games[info.ID]; exists {
+		s.gamesMutex.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrGameExists, info.ID)
+	}
+	s.games[info.ID] = info
+	s.gamesMutex.Unlock()
+
+	slog.Info("Game created", "game_id", info.ID)
+	return s.GameDetails(info.ID)
+}
+
+// UpdateGame 修改游戏名称和配置，name 为空时保持不变，settings 为 nil 时配置不变、否则整体替换。
+// 颁发者 DID 不能通过此方法修改；新的人数上限和容量策略只影响之后创建的房间
+func (s *SimpleServer) UpdateGame(gameID, name string, settings *GameSettings) (*GameInfo, error) {
+	s.gamesMutex.Lock()
+	current, ok := s.games[gameID]
+	if !ok {
+		s.gamesMutex.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrGameNotFound, gameID)
+	}
+
+	updated := current.clone()
+	if name != "" {
+		updated.Name = name
+	}
+	if settings != nil {
+		updated.Settings = settings.clone()
+	}
+	if err := updated.validate(); err != nil {
+		s.gamesMutex.Unlock()
+		return nil, err
+	}
+	s.games[gameID] = updated
+	s.gamesMutex.Unlock()
+
+	slog.Info("Game updated", "game_id", gameID)
+	return s.GameDetails(gameID)
+}
+
+// SetGameStatus 切换游戏状态：maintenance 开启维护模式，active 关闭维护模式
+func (s *SimpleServer) SetGameStatus(gameID string, status GameStatus, message string) (*GameInfo, error) {
+	if _, ok := s.Game(gameID); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGameNotFound, gameID)
+	}
+
+	switch status {
+	case GameActive:
+		s.SetMaintenance(gameID, false, "")
+	case GameMaintenance:
+		s.SetMaintenance(gameID, true, message)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidGameStatus, status)
+	}
+	return s.GameDetails(gameID)
+}
+
+// DeleteGame 从目录中删除游戏并关闭其维护模式。已有房间不受影响，此后按未注册的游戏处理（不导入外部凭证、不分流）
+func (s *SimpleServer) DeleteGame(gameID string) error {
+	if gameID == defaultGameID {
+		return ErrDefaultGame
+	}
+
+	s.gamesMutex.Lock()
+	_, ok := s.games[gameID]
+	delete(s.games, gameID)
+	s.gamesMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrGameNotFound, gameID)
+	}
+
+	s.SetMaintenance(gameID, false, "")
+	slog.Info("Game deleted", "game_id", gameID)
+	return nil
+}
+
+// TrustIssuers 将颁发者 DID 加入游戏的信任列表，应在接受连接前调用
+func (s *SimpleServer) TrustIssuers(gameID string, issuers ...string) error {
+	s.gamesMutex.Lock()
+	defer s.gamesMutex.Unlock()
+
+	info, ok := s.games[gameID]
+	if !ok {
+		return fmt.Errorf("game not found: %s", gameID)
+	}
+
+	for _, issuer := range issuers {
+		if !strings.HasPrefix(issuer, "did:") {
+			return fmt.Errorf("invalid issuer DID: %s", issuer)
+		}
+		if !containsString(info.Settings.TrustedIssuers, issuer) {
+			info.Settings.TrustedIssuers = append(info.Settings.TrustedIssuers, issuer)
+		}
+	}
+	return nil
+}
+
+// UseGameIssuer 为游戏创建自己的颁发者 DID，此后该游戏的成就、等级等凭证由它签发，
+// 其他游戏可将该 DID 加入信任列表以导入这些凭证；应在接受连接前调用
+func (s *SimpleServer) UseGameIssuer(gameID string) (string, error) {
+	if _, ok := s.Game(gameID); !ok {
+		return "", fmt.Errorf("game not found: %s", gameID)
+	}
+
+	issuerDID, err := s.vcService.RegisterGameIssuer(gameID)
+	if err != nil {
+		return "", fmt.Errorf("register game issuer: %w", err)
+	}
+
+	s.gamesMutex.Lock()
+	defer s.gamesMutex.Unlock()
+
+	if info, ok := s.games[gameID]; ok {
+		info.IssuerDID = issuerDID
+	}
+	return issuerDID, nil
+}
+
+// roomMaxPlayers 返回游戏新建房间的人数上限
+func (s *SimpleServer) roomMaxPlayers(gameID string) int {
+	if info, ok := s.Game(gameID); ok && info.Settings.MaxPlayers > 0 {
+		return info.Settings.MaxPlayers
+	}
+	return defaultRoomMaxPlayers
+}
+
+// guestRejection 游戏不接受访客且玩家的 DID 未在本服务登记时返回拒绝原因
+func (s *SimpleServer) guestRejection(player *Player, gameID string) *EntryRejection {
+	info, ok := s.Game(gameID)
+	if !ok || info.Settings.GuestPolicy != GuestDeny {
+		return nil
+	}
+	if _, err := s.didService.GetDID(player.DID); err == nil {
+		return nil
+	}
+	return &EntryRejection{
+		Reason:  RejectGuestsNotAllowed,
+		Message: "game " + gameID + " does not admit guests, register your DID first",
+		Details: map[string]interface{}{"gameId": gameID},
+	}
+}
+
+// GameUpdate PATCH /api/games/{id} 的请求体，省略的字段不变
+type GameUpdate struct {
+	Name     string        `json:"name,omitempty"`
+	Settings *GameSettings `json:"settings,omitempty"`
+	Status   GameStatus    `json:"status,omitempty"`
+	// Message 切换到维护模式时的提示信息
+	Message string `json:"message,omitempty"`
+}
+
+// HandleGames 处理游戏目录：GET /api/games 列出游戏，POST 创建游戏；
+// GET、PATCH、DELETE /api/games/{id} 查看、修改和删除单个游戏。修改请求的认证由调用方负责
+func (s *SimpleServer) HandleGames(w http.ResponseWriter, r *http.Request) {
+	gameID := strings.Trim(strings.TrimPrefix(r.URL.Path, GamesPath), "/")
+	if gameID == "" {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"games": s.Games(),
+			})
+		case http.MethodPost:
+			var info GameInfo
+			if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+				return
+			}
+			created, err := s.CreateGame(&info)
+			if err != nil {
+				writeGameError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	var (
+		info *GameInfo
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		info, err = s.GameDetails(gameID)
+	case http.MethodPatch:
+		var update GameUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		// 先校验状态，避免名称和配置已修改后才拒绝请求
+		switch update.Status {
+		case "", GameActive, GameMaintenance:
+		default:
+			writeGameError(w, fmt.Errorf("%w: %s", ErrInvalidGameStatus, update.Status))
+			return
+		}
+		if update.Name != "" || update.Settings != nil {
+			info, err = s.UpdateGame(gameID, update.Name, update.Settings)
+		}
+		if err == nil && update.Status != "" {
+			info, err = s.SetGameStatus(gameID, update.Status, update.Message)
+		}
+		if err == nil && info == nil {
+			info, err = s.GameDetails(gameID)
+		}
+	case http.MethodDelete:
+		if err := s.DeleteGame(gameID); err != nil {
+			writeGameError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		writeGameError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// writeGameError 按游戏目录的错误类型写入状态码
+func writeGameError(w http.ResponseWriter, err error) {
+	var validationErr *GameValidationError
+	switch {
+	case errors.Is(err, ErrGameNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrGameExists), errors.Is(err, ErrDefaultGame):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvalidGameStatus), errors.As(err, &validationErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
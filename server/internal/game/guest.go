@@ -0,0 +1,174 @@
+package game
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+)
+
+// 访客消息类型
+const (
+	// MsgTypeGuestAuth 以访客身份认证，服务器为本次会话生成临时 did:key
+	MsgTypeGuestAuth = "guest_auth"
+	// MsgTypeUpgradeGuest 访客将进度迁移到本服务登记的 DID
+	MsgTypeUpgradeGuest = "upgrade_guest"
+)
+
+// ErrGuestRestricted 访客不能进行需要持久身份的操作
+var ErrGuestRestricted = errors.New("guests cannot do this, register a DID and upgrade first")
+
+// SetGuestPlay 开启或关闭访客模式，应在接受连接前调用。访客不能进入 guestPolicy 为 deny 的游戏
+func (s *SimpleServer) SetGuestPlay(enabled bool) {
+	s.guestPlay = enabled
+}
+
+// isGuest 玩家是否为访客
+func (p *Player) isGuest() bool {
+	p.profileMutex.Lock()
+	defer p.profileMutex.Unlock()
+
+	return p.guest
+}
+
+// handleGuestAuth 为访客生成临时 did:key 并开始会话。私钥不保存，访客断线后只能凭会话令牌恢复，
+// 超过恢复窗口后玩家和成就进度被丢弃
+func (s *SimpleServer) handleGuestAuth(ctx context.Context, conn *websocket.Conn, payload *GuestAuthPayload, logger *slog.Logger) *Player {
+	locale := s.catalog.Negotiate(payload.Locale)
+	if !s.guestPlay {
+		s.sendLocalizedError(conn, locale, ErrCodeGuestFailed, "error.guest_failed", i18n.Params{"error": "guest play is disabled"})
+		return nil
+	}
+
+	protocol, ok := s.negotiateProtocol(conn, payload.ProtocolOffer, locale)
+	if !ok {
+		return nil
+	}
+
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		logger.Error("Failed to generate guest key", logging.Err(err))
+		s.sendLocalizedError(conn, locale, ErrCodeGuestFailed, "error.guest_failed", i18n.Params{"error": "cannot create a guest identity"})
+		return nil
+	}
+	guestDID := pkgdid.NewKeyDID(publicKey)
+
+	if !s.admitConnection(guestDID, conn, locale) {
+		return nil
+	}
+	player := s.players.getOrCreate(guestDID, func() *Player {
+		player := newPlayer(guestDID)
+		player.Nickname = fmt.Sprintf("Guest_%s", guestDID[len(guestDID)-8:])
+		player.guest = true
+		return player
+	})
+	s.startSession(ctx, conn, player, locale, protocol, logger)
+	guestSessionsTotal.With("started").Inc()
+	return player
+}
+
+// handleUpgradeGuest 将访客改为本服务登记的 DID：玩家 ID、房间、等级、背包和技能保留并开始持久化，
+// 成就进度和对局结果迁移到新 DID。访客需像 auth 一样签名 auth_challenge 的 nonce 证明持有新 DID；
+// 新 DID 在本服务已有玩家时拒绝，应直接以该 DID 认证
+func (s *SimpleServer) handleUpgradeGuest(ctx context.Context, conn *websocket.Conn, player *Player, payload *UpgradeGuestPayload, challenge *authChallenge, logger *slog.Logger) {
+	fail := func(err error) {
+		s.sendLocalizedError(conn, player.Locale, ErrCodeGuestFailed, "error.guest_failed", i18n.Params{"error": err})
+	}
+
+	if !player.isGuest() {
+		fail(errors.New("only guest players can upgrade"))
+		return
+	}
+	if _, err := s.didService.GetDID(payload.DID); err != nil {
+		fail(fmt.Errorf("%s is not registered on this server", payload.DID))
+		return
+	}
+	resolved, err := s.didService.ResolveDIDContext(ctx, payload.DID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	// 访客必须证明持有新 DID 的认证密钥，否则可以把进度迁移到他人的 DID 上
+	if err := verifyDIDProof(challenge, resolved.DIDDoc, &payload.DIDProof); err != nil {
+		s.sendLocalizedError(conn, player.Locale, ErrCodeAuthFailed, "error.auth_failed", i18n.Params{"error": err})
+		return
+	}
+	if ban, banned := s.moderation.Active(SanctionBan, payload.DID); banned {
+		s.sendError(conn, ErrCodeBanned, ban.message(s.catalog, player.Locale))
+		return
+	}
+
+	guestDID := player.DID
+	if !s.players.rekey(player, payload.DID) {
+		fail(fmt.Errorf("%s already has progress on this server, authenticate with it instead", payload.DID))
+		return
+	}
+	s.connections.move(guestDID, payload.DID)
+	player.profileMutex.Lock()
+	player.guest = false
+	player.profileMutex.Unlock()
+	player.logger = logger.With(logging.KeyPlayerDID, player.DID, logging.KeyPlayerID, player.ID)
+
+	achievements, err := s.achievements.Transfer(guestDID, player.DID)
+	if err != nil {
+		player.log().Error("Failed to migrate guest achievement progress", logging.Err(err))
+	}
+	matches, err := s.results.ReplacePlayer(guestDID, PlayerResult{DID: player.DID, PlayerID: player.ID, Nickname: player.Nickname})
+	if err != nil {
+		player.log().Error("Failed to migrate guest match results", logging.Err(err))
+	}
+	s.persistPlayer(player)
+	s.persistInventory(player)
+	s.setPresence(player)
+
+	writeMessage(conn, Message{
+		Type:     MsgTypeUpgradeGuest,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"success":      true,
+			"did":          player.DID,
+			"guestDid":     guestDID,
+			"achievements": achievements,
+			"matches":      matches,
+		},
+		Timestamp: time.Now(),
+	})
+	if room := player.Room; room != nil {
+		s.broadcastToRoom(room, Message{
+			Type:     MsgTypePlayerUpdate,
+			PlayerID: player.ID,
+			RoomID:   room.ID,
+			Data: map[string]interface{}{
+				"action": "upgraded",
+				"player": player,
+			},
+			Timestamp: time.Now(),
+		}, player.ID)
+	}
+
+	guestSessionsTotal.With("upgraded").Inc()
+	player.log().Info("Guest upgraded", "guest_did", guestDID)
+}
+
+// forgetGuest 丢弃会话已过期的访客：移出房间和匹配队列，删除成就进度并注销玩家。对局结果保留
+func (s *SimpleServer) forgetGuest(player *Player) {
+	s.matchmaker.Dequeue(player.ID)
+	s.cancelQueuedJoin(player)
+	s.leaveRoom(player)
+	if _, err := s.achievements.ErasePlayer(player.DID); err != nil {
+		player.log().Error("Failed to erase guest achievement progress", logging.Err(err))
+	}
+	s.players.remove(player)
+
+	guestSessionsTotal.With("expired").Inc()
+	player.log().Info("Guest session expired")
+}
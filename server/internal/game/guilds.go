@@ -0,0 +1,697 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// MsgTypeGuild 公会操作和公会成员变化通知
+const MsgTypeGuild = "guild"
+
+// GuildChatChannel 公会频道，所有在线的公会成员都能收到，不需要在房间内
+const GuildChatChannel = "guild"
+
+// GuildsPath 公会接口路径，公会名册为 GuildsPath + "/{id}"
+const GuildsPath = "/api/guilds"
+
+// 公会操作类型
+const (
+	GuildInfo       = "info"       // 返回玩家所在公会的名册
+	GuildCreate     = "create"     // 创建公会，创建者成为会长
+	GuildJoin       = "join"       // 加入公会
+	GuildLeave      = "leave"      // 离开公会，会长离开时由资历最高的成员接任，最后一名成员离开时公会解散
+	GuildPromote    = "promote"    // 会长将成员提升为官员，或将官员提升为会长（会长降为官员）
+	GuildCredential = "credential" // 颁发 GuildMembershipCredential，证明玩家的公会身份
+)
+
+// 公会职位
+const (
+	GuildRoleLeader  = "leader"
+	GuildRoleOfficer = "officer"
+	GuildRoleMember  = "member"
+)
+
+// MaxGuildMembers 公会成员上限
+const MaxGuildMembers = 50
+
+const (
+	// guildsStoreName 公会的存储名称
+	guildsStoreName = "game_guilds"
+
+	recordTypeGuild = "guild"
+)
+
+// 公会错误
+var (
+	ErrGuildNotFound       = errors.New("guild not found")
+	ErrGuildNameTaken      = errors.New("guild name is already taken")
+	ErrAlreadyInGuild      = errors.New("player is already in a guild")
+	ErrNotInGuild          = errors.New("player is not in a guild")
+	ErrGuildFull           = errors.New("guild is full")
+	ErrGuildPermission     = errors.New("only the guild leader can promote members")
+	ErrGuildMemberNotFound = errors.New("player is not a member of the guild")
+)
+
+// guildRoleRank 职位的高低，用于名册排序和选出接任的会长
+var guildRoleRank = map[string]int{
+	GuildRoleLeader:  2,
+	GuildRoleOfficer: 1,
+	GuildRoleMember:  0,
+}
+
+// GuildMember 公会成员，以 DID 标识
+type GuildMember struct {
+	DID      string    `json:"did"`
+	PlayerID string    `json:"playerId"`
+	Nickname string    `json:"nickname"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joinedAt"`
+	// CredentialID 成员当前有效的 GuildMembershipCredential，离开公会或职位变化时撤销
+	CredentialID string `json:"credentialId,omitempty"`
+}
+
+// Guild 公会，成员按职位和加入时间排序
+type Guild struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Tag       string         `json:"tag,omitempty"`
+	Members   []*GuildMember `json:"members"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// GuildSummary 公会列表中的一项
+type GuildSummary struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Tag       string    `json:"tag,omitempty"`
+	Leader    string    `json:"leader"`
+	Members   int       `json:"members"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// member 返回 DID 对应的成员
+func (g *Guild) member(playerDID string) *GuildMember {
+	for _, member := range g.Members {
+		if member.DID == playerDID {
+			return member
+		}
+	}
+	return nil
+}
+
+// leader 返回会长
+func (g *Guild) leader() *GuildMember {
+	for _, member := range g.Members {
+		if member.Role == GuildRoleLeader {
+			return member
+		}
+	}
+	return nil
+}
+
+// memberDIDs 返回所有成员的 DID
+func (g *Guild) memberDIDs() []string {
+	dids := make([]string, 0, len(g.Members))
+	for _, member := range g.Members {
+		dids = append(dids, member.DID)
+	}
+	return dids
+}
+
+// sortMembers 按职位从高到低、加入时间从早到晚排序成员
+func (g *Guild) sortMembers() {
+	sort.SliceStable(g.Members, func(i, j int) bool {
+		a, b := g.Members[i], g.Members[j]
+		if guildRoleRank[a.Role] != guildRoleRank[b.Role] {
+			return guildRoleRank[a.Role] > guildRoleRank[b.Role]
+		}
+		return a.JoinedAt.Before(b.JoinedAt)
+	})
+}
+
+// snapshot 复制公会，用于在锁外发送
+func (g *Guild) snapshot() *Guild {
+	copied := *g
+	copied.Members = make([]*GuildMember, len(g.Members))
+	for i, member := range g.Members {
+		m := *member
+		copied.Members[i] = &m
+	}
+	return &copied
+}
+
+// summary 返回公会列表中的一项
+func (g *Guild) summary() GuildSummary {
+	summary := GuildSummary{
+		ID:        g.ID,
+		Name:      g.Name,
+		Tag:       g.Tag,
+		Members:   len(g.Members),
+		CreatedAt: g.CreatedAt,
+	}
+	if leader := g.leader(); leader != nil {
+		summary.Leader = leader.DID
+	}
+	return summary
+}
+
+// Guilds 公会及其成员，公会在内存中缓存并同步写入存储。每名玩家（按 DID）同时只能属于一个公会
+type Guilds struct {
+	store    storage.Store
+	guilds   map[string]*Guild
+	byMember map[string]string // DID -> 公会 ID
+	names    map[string]string // 小写的公会名 -> 公会 ID
+	mutex    sync.RWMutex
+}
+
+// NewGuilds 创建公会模块并加载存储中的公会
+func NewGuilds(provider storage.Provider) (*Guilds, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(guildsStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open guilds store: %w", err)
+	}
+
+	g := &Guilds{
+		store:    store,
+		guilds:   make(map[string]*Guild),
+		byMember: make(map[string]string),
+		names:    make(map[string]string),
+	}
+	err = queryRecords(store, recordTypeTag, recordTypeGuild, func(data []byte) error {
+		var guild Guild
+		if err := json.Unmarshal(data, &guild); err != nil {
+			return fmt.Errorf("unmarshal guild: %w", err)
+		}
+		g.index(&guild)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Create 创建公会，founder 成为会长
+func (g *Guilds) Create(name, tag string, founder GuildMember) (*Guild, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if _, exists := g.byMember[founder.DID]; exists {
+		return nil, ErrAlreadyInGuild
+	}
+	if _, taken := g.names[strings.ToLower(name)]; taken {
+		return nil, ErrGuildNameTaken
+	}
+
+	now := time.Now()
+	founder.Role = GuildRoleLeader
+	founder.JoinedAt = now
+	founder.CredentialID = ""
+	guild := &Guild{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Tag:       tag,
+		Members:   []*GuildMember{&founder},
+		CreatedAt: now,
+	}
+	if err := g.save(guild); err != nil {
+		return nil, err
+	}
+	g.index(guild)
+	return guild.snapshot(), nil
+}
+
+// Join 以普通成员身份加入公会
+func (g *Guilds) Join(guildID string, member GuildMember) (*Guild, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if _, exists := g.byMember[member.DID]; exists {
+		return nil, ErrAlreadyInGuild
+	}
+	guild, exists := g.guilds[guildID]
+	if !exists {
+		return nil, ErrGuildNotFound
+	}
+	if len(guild.Members) >= MaxGuildMembers {
+		return nil, ErrGuildFull
+	}
+
+	member.Role = GuildRoleMember
+	member.JoinedAt = time.Now()
+	member.CredentialID = ""
+	updated := guild.snapshot()
+	updated.Members = append(updated.Members, &member)
+	if err := g.save(updated); err != nil {
+		return nil, err
+	}
+	g.index(updated)
+	return updated.snapshot(), nil
+}
+
+// Leave 将玩家移出所在公会，返回离开后的公会和离开的成员；最后一名成员离开时公会被删除，返回的公会没有成员
+func (g *Guilds) Leave(playerDID string) (*Guild, *GuildMember, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	guild, err := g.memberGuild(playerDID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated := guild.snapshot()
+	var left *GuildMember
+	members := updated.Members[:0]
+	for _, member := range updated.Members {
+		if member.DID == playerDID {
+			left = member
+			continue
+		}
+		members = append(members, member)
+	}
+	updated.Members = members
+
+	if len(updated.Members) == 0 {
+		if err := g.store.Delete(guildKey(guild.ID)); err != nil {
+			return nil, nil, fmt.Errorf("delete guild: %w", err)
+		}
+		g.unindex(guild)
+		return updated, left, nil
+	}
+
+	// 会长离开时由职位最高、加入最早的成员接任
+	if left.Role == GuildRoleLeader {
+		updated.Members[0].Role = GuildRoleLeader
+	}
+	if err := g.save(updated); err != nil {
+		return nil, nil, err
+	}
+	g.unindex(guild)
+	g.index(updated)
+	return updated.snapshot(), left, nil
+}
+
+// Promote 由会长提升成员的职位：普通成员升为官员，官员升为会长，原会长降为官员。返回更新后的公会和职位发生变化的成员
+func (g *Guilds) Promote(actorDID, targetDID string) (*Guild, []*GuildMember, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	guild, err := g.memberGuild(actorDID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated := guild.snapshot()
+	actor := updated.member(actorDID)
+	if actor.Role != GuildRoleLeader {
+		return nil, nil, ErrGuildPermission
+	}
+	target := updated.member(targetDID)
+	if target == nil {
+		return nil, nil, ErrGuildMemberNotFound
+	}
+
+	changed := []*GuildMember{target}
+	switch target.Role {
+	case GuildRoleMember:
+		target.Role = GuildRoleOfficer
+	case GuildRoleOfficer:
+		target.Role = GuildRoleLeader
+		actor.Role = GuildRoleOfficer
+		changed = append(changed, actor)
+	default:
+		return nil, nil, errors.New("cannot promote the guild leader")
+	}
+
+	updated.sortMembers()
+	if err := g.save(updated); err != nil {
+		return nil, nil, err
+	}
+	g.index(updated)
+	return updated.snapshot(), changed, nil
+}
+
+// SetCredential 记录成员当前的 GuildMembershipCredential，返回被替换的凭证 ID
+func (g *Guilds) SetCredential(playerDID, credentialID string) (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	guild, err := g.memberGuild(playerDID)
+	if err != nil {
+		return "", err
+	}
+
+	updated := guild.snapshot()
+	member := updated.member(playerDID)
+	previous := member.CredentialID
+	member.CredentialID = credentialID
+	if err := g.save(updated); err != nil {
+		return "", err
+	}
+	g.index(updated)
+	return previous, nil
+}
+
+// Guild 返回公会
+func (g *Guilds) Guild(guildID string) (*Guild, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	guild, exists := g.guilds[guildID]
+	if !exists {
+		return nil, ErrGuildNotFound
+	}
+	return guild.snapshot(), nil
+}
+
+// MemberGuild 返回玩家所在的公会
+func (g *Guilds) MemberGuild(playerDID string) (*Guild, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	guild, err := g.memberGuild(playerDID)
+	if err != nil {
+		return nil, err
+	}
+	return guild.snapshot(), nil
+}
+
+// List 按成员数从多到少返回所有公会
+func (g *Guilds) List() []GuildSummary {
+	g.mutex.RLock()
+	summaries := make([]GuildSummary, 0, len(g.guilds))
+	for _, guild := range g.guilds {
+		summaries = append(summaries, guild.summary())
+	}
+	g.mutex.RUnlock()
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Members != summaries[j].Members {
+			return summaries[i].Members > summaries[j].Members
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
+
+// memberGuild 返回玩家所在的公会，调用方需持有锁
+func (g *Guilds) memberGuild(playerDID string) (*Guild, error) {
+	guildID, exists := g.byMember[playerDID]
+	if !exists {
+		return nil, ErrNotInGuild
+	}
+	return g.guilds[guildID], nil
+}
+
+// save 写入公会，调用方需持有锁
+func (g *Guilds) save(guild *Guild) error {
+	data, err := json.Marshal(guild)
+	if err != nil {
+		return fmt.Errorf("marshal guild: %w", err)
+	}
+	if err := g.store.Put(guildKey(guild.ID), data, storage.Tag{Name: recordTypeTag, Value: recordTypeGuild}); err != nil {
+		return fmt.Errorf("save guild: %w", err)
+	}
+	return nil
+}
+
+// index 缓存公会并更新成员和名称索引，调用方需持有锁
+func (g *Guilds) index(guild *Guild) {
+	g.guilds[guild.ID] = guild
+	g.names[strings.ToLower(guild.Name)] = guild.ID
+	for _, member := range guild.Members {
+		g.byMember[member.DID] = guild.ID
+	}
+}
+
+// unindex 从缓存和索引中删除公会，调用方需持有锁
+func (g *Guilds) unindex(guild *Guild) {
+	delete(g.guilds, guild.ID)
+	delete(g.names, strings.ToLower(guild.Name))
+	for _, member := range guild.Members {
+		if g.byMember[member.DID] == guild.ID {
+			delete(g.byMember, member.DID)
+		}
+	}
+}
+
+func guildKey(id string) string {
+	return "guild:" + id
+}
+
+func guildChatChannel(guildID string) string {
+	return GuildChatChannel + ":" + guildID
+}
+
+// guildBroadcast 总线上转发给公会成员的消息，携带成员 DID，接收方实例不需要查询公会
+type guildBroadcast struct {
+	Origin  string   `json:"origin"`
+	Members []string `json:"members"`
+	Message Message  `json:"message"`
+}
+
+// SetGuilds 使用持久化的公会模块替换默认的内存实现，应在接受连接前调用
+func (s *SimpleServer) SetGuilds(guilds *Guilds) {
+	s.guilds = guilds
+}
+
+// handleGuild 处理 guild 消息
+func (s *SimpleServer) handleGuild(player *Player, payload *GuildPayload) {
+	var (
+		guild   *Guild
+		changed []*GuildMember
+		err     error
+	)
+
+	member := GuildMember{DID: player.DID, PlayerID: player.ID, Nickname: player.Nickname}
+	switch payload.Action {
+	case GuildInfo:
+		guild, err = s.guilds.MemberGuild(player.DID)
+	case GuildCreate, GuildJoin:
+		// 公会成员按 DID 登记并获得成员凭证，访客需先升级
+		switch {
+		case player.isGuest():
+			err = ErrGuestRestricted
+		case payload.Action == GuildCreate:
+			guild, err = s.guilds.Create(payload.Name, payload.Tag, member)
+		default:
+			guild, err = s.guilds.Join(payload.GuildID, member)
+		}
+	case GuildLeave:
+		var left *GuildMember
+		guild, left, err = s.guilds.Leave(player.DID)
+		if err == nil {
+			s.revokeGuildCredential(left)
+		}
+	case GuildPromote:
+		guild, changed, err = s.guilds.Promote(player.DID, payload.DID)
+	case GuildCredential:
+		guild, err = s.guilds.MemberGuild(player.DID)
+	}
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeGuildFailed, "error.guild_failed", i18n.Params{"action": payload.Action, "error": err})
+		return
+	}
+
+	update := Message{
+		Type:     MsgTypeGuild,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"action": payload.Action,
+			"guild":  guild,
+		},
+		Timestamp: time.Now(),
+	}
+	switch payload.Action {
+	case GuildInfo, GuildCredential:
+		writeMessage(player.Connection, update)
+	case GuildLeave:
+		writeMessage(player.Connection, update)
+		s.sendToGuild(guild, update, "")
+	default:
+		s.sendToGuild(guild, update, "")
+	}
+
+	switch payload.Action {
+	case GuildCreate, GuildJoin:
+		slog.Info("Player joined guild", logging.KeyPlayerDID, player.DID, "guild_id", guild.ID, "action", payload.Action)
+		if payload.Credential {
+			s.issueGuildCredential(player, guild)
+		}
+	case GuildLeave:
+		slog.Info("Player left guild", logging.KeyPlayerDID, player.DID, "guild_id", guild.ID, "disbanded", len(guild.Members) == 0)
+	case GuildPromote:
+		// 已有成员凭证的成员职位变化后重新颁发
+		for _, promoted := range changed {
+			if promoted.CredentialID == "" {
+				continue
+			}
+			if target := s.findConnectedPlayerByDID(promoted.DID); target != nil {
+				s.issueGuildCredential(target, guild)
+				continue
+			}
+			s.revokeGuildCredential(promoted)
+			if _, err := s.guilds.SetCredential(promoted.DID, ""); err != nil {
+				slog.Error("Failed to clear guild membership credential", logging.KeyPlayerDID, promoted.DID, logging.Err(err))
+			}
+		}
+	case GuildCredential:
+		s.issueGuildCredential(player, guild)
+	}
+}
+
+// issueGuildCredential 向成员颁发 GuildMembershipCredential，撤销其之前的成员凭证
+func (s *SimpleServer) issueGuildCredential(player *Player, guild *Guild) {
+	member := guild.member(player.DID)
+	if member == nil || player.DID == "" || player.isGuest() {
+		return
+	}
+
+	gameID := "default"
+	if room := player.Room; room != nil {
+		gameID = room.GameID
+	}
+
+	credential, err := s.vcService.IssueGuildMembershipCredential(player.traceContext(), player.DID, gameID, player.ID, guild.ID, guild.Name, member.Role)
+	if err != nil {
+		player.log().Error("Failed to issue guild membership credential", "guild_id", guild.ID, logging.Err(err))
+		return
+	}
+	previous, err := s.guilds.SetCredential(player.DID, credential.ID)
+	if err != nil {
+		player.log().Error("Failed to record guild membership credential", "guild_id", guild.ID, logging.Err(err))
+	}
+	s.revokeGuildCredential(&GuildMember{DID: player.DID, CredentialID: previous})
+
+	if player.Connection != nil {
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeCredential,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"credential": credential,
+				"message":    s.localize(player, "credential.guild", i18n.Params{"name": guild.Name}),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// revokeGuildCredential 撤销成员的 GuildMembershipCredential，失败时只记录日志
+func (s *SimpleServer) revokeGuildCredential(member *GuildMember) {
+	if member == nil || member.CredentialID == "" {
+		return
+	}
+	if err := s.vcService.RevokeCredential(member.CredentialID); err != nil {
+		slog.Error("Failed to revoke guild membership credential", logging.KeyPlayerDID, member.DID, "credential_id", member.CredentialID, logging.Err(err))
+	}
+}
+
+// handleGuildChat 将聊天消息发送给所有在线的公会成员并写入公会频道的聊天记录
+func (s *SimpleServer) handleGuildChat(player *Player, msg ChatMessage) {
+	guild, err := s.guilds.MemberGuild(player.DID)
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeChatRejected, "error.chat_join_guild", nil)
+		return
+	}
+
+	msg.Channel = guildChatChannel(guild.ID)
+	if err := s.chatHistory.Append(msg); err != nil {
+		player.log().Error("Failed to save guild chat message", "guild_id", guild.ID, logging.Err(err))
+	}
+
+	s.sendToGuild(guild, Message{
+		Type:      MsgTypeChat,
+		PlayerID:  player.ID,
+		Data:      msg,
+		Timestamp: msg.Timestamp,
+	}, "")
+}
+
+// sendToGuild 将消息发给本实例上在线的公会成员，并经消息总线转发给其他实例上的成员
+func (s *SimpleServer) sendToGuild(guild *Guild, msg Message, excludePlayerID string) {
+	members := guild.memberDIDs()
+	s.deliverToGuild(members, msg, excludePlayerID)
+	s.publish(busTopicGuild, guildBroadcast{
+		Origin:  s.instanceID,
+		Members: members,
+		Message: msg,
+	})
+}
+
+// deliverToGuild 将消息发给本实例上在线的成员，屏蔽了发送方的成员收不到聊天消息
+func (s *SimpleServer) deliverToGuild(members []string, msg Message, excludePlayerID string) {
+	var targets []*Player
+	for _, member := range members {
+		player := s.players.getByDID(member)
+		if player != nil && player.Connection != nil && player.ID != excludePlayerID {
+			targets = append(targets, player)
+		}
+	}
+
+	for _, player := range targets {
+		if msg.Type == MsgTypeChat && player.hasMuted(msg.PlayerID) {
+			continue
+		}
+		writeMessage(player.Connection, msg)
+	}
+}
+
+// handleGuildBroadcast 投递其他实例转发的公会消息
+func (s *SimpleServer) handleGuildBroadcast(data []byte) {
+	var broadcast guildBroadcast
+	if err := json.Unmarshal(data, &broadcast); err != nil {
+		slog.Warn("Invalid guild message on message bus", logging.Err(err))
+		return
+	}
+	if broadcast.Origin == s.instanceID {
+		return
+	}
+	s.deliverToGuild(broadcast.Members, broadcast.Message, "")
+}
+
+// HandleGuilds 处理 GET /api/guilds（公会列表）和 GET /api/guilds/{id}（公会名册）
+func (s *SimpleServer) HandleGuilds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	guildID := strings.Trim(strings.TrimPrefix(r.URL.Path, GuildsPath), "/")
+	if guildID == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"guilds": s.guilds.List(),
+		})
+		return
+	}
+
+	guild, err := s.guilds.Guild(guildID)
+	if errors.Is(err, ErrGuildNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// 名册不公开成员的凭证 ID
+	for _, member := range guild.Members {
+		member.CredentialID = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guild)
+}
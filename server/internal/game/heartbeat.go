@@ -0,0 +1,192 @@
+package game
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// 心跳消息类型，供客户端测量延迟
+const (
+	MsgTypePing = "ping"
+	MsgTypePong = "pong"
+)
+
+// HeartbeatConfig 连接心跳与空闲回收配置
+type HeartbeatConfig struct {
+	PingInterval time.Duration // 服务器发送 ping 的间隔，必须小于 PongWait
+	PongWait     time.Duration // 读超时，期间未收到任何消息或 pong 即断开连接
+	WriteWait    time.Duration // 每次写消息或控制帧的超时
+	OfflineGrace time.Duration // 离线玩家保留房间位置的宽限期
+	ReapInterval time.Duration // 回收检查间隔
+}
+
+// DefaultHeartbeatConfig 返回默认心跳配置
+func DefaultHeartbeatConfig() HeartbeatConfig {
+	return HeartbeatConfig{
+		PingInterval: 25 * time.Second,
+		PongWait:     60 * time.Second,
+		WriteWait:    10 * time.Second,
+		OfflineGrace: 2 * time.Minute,
+		ReapInterval: 30 * time.Second,
+	}
+}
+
+// connRTTs 连接 -> *connRTT，心跳测量的往返时延
+var connRTTs sync.Map
+
+// connRTT 由心跳 ping/pong 测量的连接往返时延
+type connRTT struct {
+	pending  string    // 最近一次 ping 的载荷，只有与之相同的 pong 计入测量
+	sentAt   time.Time // 最近一次 ping 的发送时间
+	smoothed time.Duration
+	mutex    sync.Mutex
+}
+
+// ping 记录一次 ping 并返回其载荷
+func (r *connRTT) ping(now time.Time) []byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.pending = strconv.FormatInt(now.UnixNano(), 36)
+	r.sentAt = now
+	return []byte(r.pending)
+}
+
+// pong 以与最近一次 ping 匹配的 pong 更新平滑往返时延。
+// 客户端无法预知 ping 的载荷，不能通过提前或伪造的 pong 降低测得的延迟
+func (r *connRTT) pong(appData string, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.pending == "" || appData != r.pending {
+		return
+	}
+	r.pending = ""
+
+	sample := now.Sub(r.sentAt)
+	if r.smoothed == 0 {
+		r.smoothed = sample
+	} else {
+		r.smoothed = (7*r.smoothed + sample) / 8
+	}
+}
+
+// connectionRTT 返回连接的平滑往返时延，尚未完成测量时返回 false
+func connectionRTT(conn *websocket.Conn) (time.Duration, bool) {
+	value, ok := connRTTs.Load(conn)
+	if !ok {
+		return 0, false
+	}
+	r := value.(*connRTT)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.smoothed, r.smoothed > 0
+}
+
+// startHeartbeat 设置读超时和 pong 处理，连接建立后立即并定期发送 ping 以测量往返时延，返回停止函数
+func (s *SimpleServer) startHeartbeat(conn *websocket.Conn) func() {
+	rtt := &connRTT{}
+	connRTTs.Store(conn, rtt)
+
+	conn.SetReadDeadline(time.Now().Add(s.heartbeat.PongWait))
+	conn.SetPongHandler(func(appData string) error {
+		rtt.pong(appData, time.Now())
+		return conn.SetReadDeadline(time.Now().Add(s.heartbeat.PongWait))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.heartbeat.PingInterval)
+		defer ticker.Stop()
+
+		for {
+			// WriteControl 可与其他写操作并发调用
+			now := time.Now()
+			if err := conn.WriteControl(websocket.PingMessage, rtt.ping(now), now.Add(s.heartbeat.WriteWait)); err != nil {
+				slog.Info("Ping failed", logging.Err(err))
+				return
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		connRTTs.Delete(conn)
+	}
+}
+
+// handlePing 回复应用层 ping，客户端可据此计算往返延迟
+func (s *SimpleServer) handlePing(conn *websocket.Conn, payload *PingPayload) {
+	writeMessage(conn, Message{
+		Type:      MsgTypePong,
+		Data:      payload,
+		Timestamp: time.Now(),
+	})
+}
+
+// runReaper 定期回收超过宽限期的离线玩家，直到 stop 关闭
+func (s *SimpleServer) runReaper(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.heartbeat.ReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.reapIdlePlayers(now)
+			s.sweepSessions(now)
+			s.moderation.sweep()
+		}
+	}
+}
+
+// reapIdlePlayers 将离线超过宽限期的玩家移出房间并释放相关资源
+func (s *SimpleServer) reapIdlePlayers(now time.Time) {
+	var idle []*Player
+
+	for _, player := range s.players.all() {
+		if player.Status == "offline" && player.Room != nil && now.Sub(player.LastSeen) > s.heartbeat.OfflineGrace {
+			idle = append(idle, player)
+		}
+	}
+
+	for _, player := range idle {
+		room := player.Room
+		if room == nil || player.Status != "offline" {
+			continue
+		}
+
+		s.matchmaker.Dequeue(player.ID)
+		player.pendingPresentation = nil
+		player.clearMissed()
+		s.leaveRoom(player)
+		s.persistPlayer(player)
+
+		s.broadcastToRoom(room, Message{
+			Type:     MsgTypePlayerUpdate,
+			PlayerID: player.ID,
+			RoomID:   room.ID,
+			Data: map[string]interface{}{
+				"action": "left",
+				"reason": "idle",
+				"player": player,
+			},
+			Timestamp: now,
+		}, player.ID)
+
+		room.log().Info("Reaped idle player", logging.KeyPlayerDID, player.DID, logging.KeyPlayerID, player.ID)
+	}
+}
@@ -0,0 +1,520 @@
+package game
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// MsgTypeInbox 收件箱操作和新消息推送
+const MsgTypeInbox = "inbox"
+
+// InboxPath 收件箱接口路径，玩家的收件箱为 InboxPath + "/{did}"
+const InboxPath = "/api/inbox"
+
+// 收件箱操作
+const (
+	InboxList    = "list"    // 返回收件箱中的消息
+	InboxRead    = "read"    // 将消息标记为已读，未指定 ID 时标记全部
+	InboxDelete  = "delete"  // 删除消息，未指定 ID 时清空收件箱
+	InboxDeliver = "deliver" // 服务器推送新消息，认证时一次推送离线期间收到的所有消息
+)
+
+// 收件箱消息类型
+const (
+	MailSystem     = "system"     // 系统通知
+	MailCredential = "credential" // 服务器为玩家颁发了凭证
+	MailInvite     = "invite"     // 其他玩家发来的房间邀请
+)
+
+// MaxInboxMessages 每名玩家保留的消息数，超出时删除最早的消息
+const MaxInboxMessages = 100
+
+const (
+	// inboxStoreName 收件箱的存储名称
+	inboxStoreName = "game_inbox"
+
+	recordTypeMail = "mail"
+	// inboxOwnerTag 按收件人查询消息的标签，DID 中含有 ':'，标签值为 DID 的摘要
+	inboxOwnerTag = "owner"
+)
+
+// ErrMailNotFound 收件箱中没有指定的消息
+var ErrMailNotFound = errors.New("inbox message not found")
+
+// Mail 收件箱中的一条消息
+type Mail struct {
+	ID    string      `json:"id"`
+	DID   string      `json:"did"`
+	Kind  string      `json:"kind"`
+	Title string      `json:"title"`
+	Body  string      `json:"body,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+	Read  bool        `json:"read"`
+	// Delivered 是否已推送给玩家，未推送的消息在玩家下次认证时推送
+	Delivered bool       `json:"delivered"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+}
+
+// Inbox 按玩家 DID 保存的收件箱。每次操作都读写存储，多个实例共享存储时看到同样的收件箱
+type Inbox struct {
+	store storage.Store
+	mutex sync.Mutex
+}
+
+// NewInbox 创建收件箱
+func NewInbox(provider storage.Provider) (*Inbox, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(inboxStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open inbox store: %w", err)
+	}
+	return &Inbox{store: store}, nil
+}
+
+// Send 将消息放入收件人的收件箱，超出保留数量时删除最早的消息
+func (i *Inbox) Send(mail *Mail) error {
+	if mail.DID == "" {
+		return errors.New("recipient DID is required")
+	}
+	mail.ID = uuid.New().String()
+	mail.CreatedAt = time.Now()
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if err := i.save(mail); err != nil {
+		return err
+	}
+
+	mails, err := i.load(mail.DID)
+	if err != nil {
+		return err
+	}
+	for len(mails) > MaxInboxMessages {
+		oldest := mails[0]
+		mails = mails[1:]
+		if err := i.store.Delete(mailKey(oldest.ID)); err != nil {
+			return fmt.Errorf("delete inbox message: %w", err)
+		}
+	}
+	return nil
+}
+
+// Messages 按时间从新到旧返回玩家的消息和未读消息数
+func (i *Inbox) Messages(playerDID string, unreadOnly bool) ([]*Mail, int, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	mails, err := i.load(playerDID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	messages := make([]*Mail, 0, len(mails))
+	for j := len(mails) - 1; j >= 0; j-- {
+		if !unreadOnly || !mails[j].Read {
+			messages = append(messages, mails[j])
+		}
+	}
+	return messages, countUnread(mails), nil
+}
+
+// Unread 返回玩家的未读消息数
+func (i *Inbox) Unread(playerDID string) (int, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	mails, err := i.load(playerDID)
+	if err != nil {
+		return 0, err
+	}
+	return countUnread(mails), nil
+}
+
+// TakeUndelivered 按时间从旧到新返回尚未推送的消息并将其标记为已推送，同时返回未读消息数
+func (i *Inbox) TakeUndelivered(playerDID string) ([]*Mail, int, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	mails, err := i.load(playerDID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var undelivered []*Mail
+	for _, mail := range mails {
+		if mail.Delivered {
+			continue
+		}
+		mail.Delivered = true
+		if err := i.save(mail); err != nil {
+			return nil, 0, err
+		}
+		undelivered = append(undelivered, mail)
+	}
+	return undelivered, countUnread(mails), nil
+}
+
+// MarkRead 将消息标记为已读，ids 为空时标记所有消息，返回标记的消息数和剩余的未读消息数
+func (i *Inbox) MarkRead(playerDID string, ids []string) (int, int, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	mails, err := i.load(playerDID)
+	if err != nil {
+		return 0, 0, err
+	}
+	selected, err := selectMails(mails, ids)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	updated := 0
+	for _, mail := range selected {
+		if mail.Read {
+			continue
+		}
+		mail.Read = true
+		mail.ReadAt = &now
+		// 读过的消息不必再推送
+		mail.Delivered = true
+		if err := i.save(mail); err != nil {
+			return updated, countUnread(mails), err
+		}
+		updated++
+	}
+	return updated, countUnread(mails), nil
+}
+
+// Delete 删除消息，ids 为空时清空收件箱，返回删除的消息数
+func (i *Inbox) Delete(playerDID string, ids []string) (int, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	mails, err := i.load(playerDID)
+	if err != nil {
+		return 0, err
+	}
+	selected, err := selectMails(mails, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	for deleted, mail := range selected {
+		if err := i.store.Delete(mailKey(mail.ID)); err != nil {
+			return deleted, fmt.Errorf("delete inbox message: %w", err)
+		}
+	}
+	return len(selected), nil
+}
+
+// load 从存储读取玩家的所有消息，按时间从旧到新排列，调用方需持有锁
+func (i *Inbox) load(playerDID string) ([]*Mail, error) {
+	var mails []*Mail
+	err := queryRecords(i.store, inboxOwnerTag, inboxOwner(playerDID), func(data []byte) error {
+		var mail Mail
+		if err := json.Unmarshal(data, &mail); err != nil {
+			return fmt.Errorf("unmarshal inbox message: %w", err)
+		}
+		if mail.DID == playerDID {
+			mails = append(mails, &mail)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mails, func(a, b int) bool {
+		if !mails[a].CreatedAt.Equal(mails[b].CreatedAt) {
+			return mails[a].CreatedAt.Before(mails[b].CreatedAt)
+		}
+		return mails[a].ID < mails[b].ID
+	})
+	return mails, nil
+}
+
+// save 写入一条消息，调用方需持有锁
+func (i *Inbox) save(mail *Mail) error {
+	data, err := json.Marshal(mail)
+	if err != nil {
+		return fmt.Errorf("marshal inbox message: %w", err)
+	}
+	err = i.store.Put(mailKey(mail.ID), data,
+		storage.Tag{Name: recordTypeTag, Value: recordTypeMail},
+		storage.Tag{Name: inboxOwnerTag, Value: inboxOwner(mail.DID)})
+	if err != nil {
+		return fmt.Errorf("save inbox message: %w", err)
+	}
+	return nil
+}
+
+// selectMails 按 ID 选择消息，ids 为空时选择全部，任一 ID 不存在时返回 ErrMailNotFound
+func selectMails(mails []*Mail, ids []string) ([]*Mail, error) {
+	if len(ids) == 0 {
+		return mails, nil
+	}
+
+	byID := make(map[string]*Mail, len(mails))
+	for _, mail := range mails {
+		byID[mail.ID] = mail
+	}
+	selected := make([]*Mail, 0, len(ids))
+	for _, id := range ids {
+		mail, exists := byID[id]
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrMailNotFound, id)
+		}
+		selected = append(selected, mail)
+	}
+	return selected, nil
+}
+
+func countUnread(mails []*Mail) int {
+	unread := 0
+	for _, mail := range mails {
+		if !mail.Read {
+			unread++
+		}
+	}
+	return unread
+}
+
+func inboxOwner(playerDID string) string {
+	digest := sha256.Sum256([]byte(playerDID))
+	return hex.EncodeToString(digest[:16])
+}
+
+func mailKey(id string) string {
+	return "mail:" + id
+}
+
+// inboxNotice 总线上的新消息通知，收件人连接在其他实例上时由该实例推送
+type inboxNotice struct {
+	Origin string `json:"origin"`
+	DID    string `json:"did"`
+}
+
+// SetInbox 使用持久化的收件箱替换默认的内存实现，应在接受连接前调用
+func (s *SimpleServer) SetInbox(inbox *Inbox) {
+	s.inbox = inbox
+}
+
+// Notify 向玩家的收件箱发送消息：玩家在线时立即推送，否则在玩家下次认证时推送
+func (s *SimpleServer) Notify(playerDID, kind, title, body string, data interface{}) (*Mail, error) {
+	mail := &Mail{
+		DID:   playerDID,
+		Kind:  kind,
+		Title: title,
+		Body:  body,
+		Data:  data,
+	}
+	if err := s.inbox.Send(mail); err != nil {
+		return nil, err
+	}
+	inboxMessagesTotal.With(kind).Inc()
+
+	if player := s.findConnectedPlayerByDID(playerDID); player != nil {
+		s.deliverInbox(player)
+	} else {
+		s.publish(busTopicInbox, inboxNotice{Origin: s.instanceID, DID: playerDID})
+	}
+	return mail, nil
+}
+
+// NotifyCredentialIssued 将颁发的凭证记入持有者的收件箱，实现 vc.IssuedNotifier。
+// 凭证可能在持有锁时颁发，收件箱在新的协程中写入
+func (s *SimpleServer) NotifyCredentialIssued(credential *vc.SimpleCredential) {
+	holder := credential.CredentialSubject.ID
+	if holder == "" || len(credential.Type) == 0 {
+		return
+	}
+
+	credentialType := credential.Type[len(credential.Type)-1]
+	data := map[string]interface{}{
+		"credentialId":   credential.ID,
+		"type":           credential.Type,
+		"issuer":         credential.Issuer,
+		"gameId":         credential.CredentialSubject.GameID,
+		"issuanceDate":   credential.IssuanceDate,
+		"expirationDate": credential.ExpirationDate,
+	}
+	go func() {
+		if _, err := s.Notify(holder, MailCredential, s.localizeFor(holder, "inbox.credential", i18n.Params{"type": credentialType}), "", data); err != nil {
+			slog.Error("Failed to notify credential holder", "did", holder, "credential_id", credential.ID, logging.Err(err))
+		}
+	}()
+}
+
+// deliverInbox 推送玩家尚未收到的消息
+func (s *SimpleServer) deliverInbox(player *Player) {
+	mails, unread, err := s.inbox.TakeUndelivered(player.DID)
+	if err != nil {
+		player.log().Error("Failed to load inbox", logging.Err(err))
+		return
+	}
+	if len(mails) == 0 {
+		return
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeInbox,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"action":   InboxDeliver,
+			"messages": mails,
+			"unread":   unread,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// handleInboxNotice 推送其他实例放入本实例上玩家收件箱的消息
+func (s *SimpleServer) handleInboxNotice(data []byte) {
+	var notice inboxNotice
+	if err := json.Unmarshal(data, &notice); err != nil {
+		slog.Warn("Invalid inbox notice on message bus", logging.Err(err))
+		return
+	}
+	if notice.Origin == s.instanceID {
+		return
+	}
+	if player := s.findConnectedPlayerByDID(notice.DID); player != nil {
+		s.deliverInbox(player)
+	}
+}
+
+// handleInbox 处理 inbox 消息：列出、标记已读或删除自己的消息
+func (s *SimpleServer) handleInbox(player *Player, payload *InboxPayload) {
+	response := map[string]interface{}{"action": payload.Action}
+	var err error
+	switch payload.Action {
+	case InboxList:
+		var (
+			mails  []*Mail
+			unread int
+		)
+		mails, unread, err = s.inbox.Messages(player.DID, payload.UnreadOnly)
+		response["messages"] = mails
+		response["unread"] = unread
+	case InboxRead:
+		var updated, unread int
+		updated, unread, err = s.inbox.MarkRead(player.DID, payload.IDs)
+		response["updated"] = updated
+		response["unread"] = unread
+	case InboxDelete:
+		var deleted, unread int
+		deleted, err = s.inbox.Delete(player.DID, payload.IDs)
+		if err == nil {
+			unread, err = s.inbox.Unread(player.DID)
+		}
+		response["deleted"] = deleted
+		response["unread"] = unread
+	}
+	if err != nil {
+		s.sendErrorToPlayer(player, ErrCodeInboxFailed, err.Error())
+		return
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:      MsgTypeInbox,
+		PlayerID:  player.ID,
+		Data:      response,
+		Timestamp: time.Now(),
+	})
+}
+
+// inboxRequest POST {did}/read 的请求体
+type inboxRequest struct {
+	IDs []string `json:"ids,omitempty"`
+}
+
+// HandleInbox 处理玩家收件箱，需 DID 认证或该玩家的会话令牌：
+// GET /api/inbox/{did} 列出消息（?unread=true 只返回未读消息），POST /api/inbox/{did}/read 标记已读，
+// DELETE /api/inbox/{did} 清空收件箱，DELETE /api/inbox/{did}/{id} 删除一条消息
+func (s *SimpleServer) HandleInbox(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(r.URL.Path, InboxPath), "/"), "/", 2)
+	playerDID := parts[0]
+	var sub string
+	if len(parts) == 2 {
+		sub = parts[1]
+	}
+	if playerDID == "" {
+		http.Error(w, "did is required", http.StatusBadRequest)
+		return
+	}
+	if !s.profileOwner(r, playerDID) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="player"`)
+		http.Error(w, "DID authentication or session token of the player is required", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		response map[string]interface{}
+		err      error
+	)
+	switch {
+	case r.Method == http.MethodGet && sub == "":
+		var (
+			mails  []*Mail
+			unread int
+		)
+		mails, unread, err = s.inbox.Messages(playerDID, r.URL.Query().Get("unread") == "true")
+		response = map[string]interface{}{"did": playerDID, "messages": mails, "unread": unread}
+	case r.Method == http.MethodPost && sub == InboxRead:
+		var req inboxRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		var updated, unread int
+		updated, unread, err = s.inbox.MarkRead(playerDID, req.IDs)
+		response = map[string]interface{}{"did": playerDID, "updated": updated, "unread": unread}
+	case r.Method == http.MethodDelete:
+		var ids []string
+		if sub != "" {
+			ids = []string{sub}
+		}
+		var deleted int
+		deleted, err = s.inbox.Delete(playerDID, ids)
+		response = map[string]interface{}{"did": playerDID, "deleted": deleted}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrMailNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
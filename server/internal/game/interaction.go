@@ -0,0 +1,184 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MsgTypeInteraction 玩家与地图物体交互后向房间广播的结果
+const MsgTypeInteraction = "interaction"
+
+// 内置可交互物体类型
+const (
+	ObjectDoor       = "door"
+	ObjectChest      = "chest"
+	ObjectNPC        = "npc"
+	ObjectCheckpoint = "checkpoint"
+)
+
+var (
+	// ErrNothingToInteract 玩家附近没有可交互的物体
+	ErrNothingToInteract = errors.New("nothing to interact with nearby")
+	// ErrObjectOutOfRange 指定的物体不存在或不在交互距离内
+	ErrObjectOutOfRange = errors.New("object is not within reach")
+)
+
+// InteractionHandler 某类地图物体的交互处理，返回的结果随 interaction 消息广播给房间；
+// 调用时不持有房间锁，修改物体属性前需自行获取 room.mutex
+type InteractionHandler func(room *GameRoom, player *Player, object *MapObject) (map[string]interface{}, error)
+
+// RegisterInteraction 注册某类物体的交互处理，已注册的类型会被替换，应在接受连接前调用
+func (s *SimpleServer) RegisterInteraction(objectType string, handler InteractionHandler) {
+	s.interactions[objectType] = handler
+}
+
+// registerDefaultInteractions 注册门、宝箱、NPC 和检查点的交互处理
+func (s *SimpleServer) registerDefaultInteractions() {
+	s.RegisterInteraction(ObjectDoor, s.interactDoor)
+	s.RegisterInteraction(ObjectChest, s.interactChest)
+	s.RegisterInteraction(ObjectNPC, s.interactNPC)
+	// 检查点只触发交互事件，由任务和游戏模式处理
+	s.RegisterInteraction(ObjectCheckpoint, func(*GameRoom, *Player, *MapObject) (map[string]interface{}, error) {
+		return nil, nil
+	})
+}
+
+// handleInteract 找到玩家交互距离内的物体并执行该类物体的交互处理：
+// 指定 objectId 时该物体必须在交互距离内，否则选择最近的可交互物体
+func (s *SimpleServer) handleInteract(player *Player, payload *ActionPayload) {
+	room := player.Room
+
+	object, err := s.resolveInteraction(room, player, payload.ObjectID)
+	if err != nil {
+		s.sendErrorToPlayer(player, ErrCodeInteractFailed, err.Error())
+		return
+	}
+
+	var result map[string]interface{}
+	if handler, exists := s.interactions[object.Type]; exists {
+		if result, err = handler(room, player, object); err != nil {
+			s.sendErrorToPlayer(player, ErrCodeInteractFailed, err.Error())
+			return
+		}
+	}
+
+	s.broadcastToRoom(room, Message{
+		Type:     MsgTypeInteraction,
+		PlayerID: player.ID,
+		RoomID:   room.ID,
+		Data: map[string]interface{}{
+			"objectId":   object.ID,
+			"objectType": object.Type,
+			"result":     result,
+		},
+		Timestamp: time.Now(),
+	}, "")
+
+	player.log().Info("Player interacted with object", "object_id", object.ID)
+	s.emitEvent(room, player, EventInteraction, object.ID)
+}
+
+// resolveInteraction 返回玩家要交互的物体
+func (s *SimpleServer) resolveInteraction(room *GameRoom, player *Player, objectID string) (*MapObject, error) {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+
+	nearby := room.GameState.Map.FindObjectsNear(player.Position, s.movement.InteractRange+s.movement.PlayerRadius)
+	for _, object := range nearby {
+		if objectID != "" && object.ID == objectID {
+			return object, nil
+		}
+		if _, interactive := s.interactions[object.Type]; objectID == "" && interactive {
+			return object, nil
+		}
+	}
+
+	if objectID != "" {
+		return nil, fmt.Errorf("%w: %s", ErrObjectOutOfRange, objectID)
+	}
+	return nil, ErrNothingToInteract
+}
+
+// interactDoor 开关门，门默认关闭并阻挡移动；门设置了 key 属性时需持有该类型的道具才能打开
+func (s *SimpleServer) interactDoor(room *GameRoom, player *Player, object *MapObject) (map[string]interface{}, error) {
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+
+	if object.Properties == nil {
+		object.Properties = make(map[string]interface{})
+	}
+	open, _ := object.Properties["open"].(bool)
+	if key, _ := object.Properties["key"].(string); key != "" && !open && !player.Inventory.HasItemType(key) {
+		return nil, fmt.Errorf("door requires %s", key)
+	}
+
+	open = !open
+	object.Properties["open"] = open
+	object.Properties["solid"] = !open
+	return map[string]interface{}{"open": open}, nil
+}
+
+// interactChest 打开宝箱，第一个打开的玩家获得 item 属性指定的道具
+func (s *SimpleServer) interactChest(room *GameRoom, player *Player, object *MapObject) (map[string]interface{}, error) {
+	room.mutex.Lock()
+	if object.Properties == nil {
+		object.Properties = make(map[string]interface{})
+	}
+	if opened, _ := object.Properties["opened"].(bool); opened {
+		room.mutex.Unlock()
+		return nil, errors.New("chest is empty")
+	}
+	object.Properties["opened"] = true
+	name, _ := object.Properties["item"].(string)
+	rarity, _ := object.Properties["rarity"].(string)
+	room.mutex.Unlock()
+
+	result := map[string]interface{}{"opened": true}
+	if name == "" {
+		return result, nil
+	}
+
+	item, err := s.GrantItem(player, &Item{Name: name, Type: name, Rarity: rarity})
+	if err != nil {
+		// 背包已满时宝箱保持未打开状态
+		room.mutex.Lock()
+		object.Properties["opened"] = false
+		room.mutex.Unlock()
+		return nil, fmt.Errorf("open chest: %w", err)
+	}
+	result["item"] = item
+	return result, nil
+}
+
+// interactNPC 返回 NPC 的对话。NPC 设置了 taskId 属性时玩家同时接受该任务；
+// 设置了 achievement 属性时为每名玩家颁发一次该成就凭证
+func (s *SimpleServer) interactNPC(room *GameRoom, player *Player, object *MapObject) (map[string]interface{}, error) {
+	room.mutex.Lock()
+	name := object.Properties["name"]
+	dialogue := object.Properties["dialogue"]
+	taskID, _ := object.Properties["taskId"].(string)
+	achievement, _ := object.Properties["achievement"].(string)
+	grant := false
+	if achievement != "" {
+		if room.npcGrants == nil {
+			room.npcGrants = make(map[string]bool)
+		}
+		key := object.ID + "/" + player.ID
+		grant = !room.npcGrants[key]
+		room.npcGrants[key] = true
+	}
+	room.mutex.Unlock()
+
+	if taskID != "" {
+		s.acceptTask(player, taskID)
+	}
+	if grant {
+		s.issueAchievement(room, player, achievement)
+	}
+
+	return map[string]interface{}{
+		"name":     name,
+		"dialogue": dialogue,
+	}, nil
+}
@@ -0,0 +1,164 @@
+package game
+
+import (
+	"math"
+)
+
+// exitRadiusFactor 未设置 ExitRadius 时离开视野的半径与 Radius 的比例
+const exitRadiusFactor = 1.2
+
+// InterestConfig 兴趣管理配置，玩家只收到附近玩家的状态同步
+type InterestConfig struct {
+	// Radius 玩家进入该半径后可见，0 表示同步整个房间
+	Radius float64
+	// ExitRadius 可见的玩家超出该半径后离开视野，大于 Radius 以免在边界附近反复进出；
+	// 小于 Radius 时使用 Radius 的 1.2 倍
+	ExitRadius float64
+}
+
+// DefaultInterestConfig 返回默认兴趣管理配置：同步整个房间
+func DefaultInterestConfig() InterestConfig {
+	return InterestConfig{}
+}
+
+// SetInterestConfig 替换兴趣管理配置，应在接受连接前调用
+func (s *SimpleServer) SetInterestConfig(config InterestConfig) {
+	if config.ExitRadius < config.Radius {
+		config.ExitRadius = config.Radius * exitRadiusFactor
+	}
+	s.interest = config
+}
+
+// enabled 是否按距离过滤状态同步
+func (c InterestConfig) enabled() bool {
+	return c.Radius > 0
+}
+
+// interestView 某个版本的房间玩家状态及其网格索引，单元边长为 ExitRadius，
+// 查询一个玩家的附近玩家只需检查周围 3x3 个单元
+type interestView struct {
+	config InterestConfig
+	states map[string]PlayerState
+	cells  map[cellKey][]string
+}
+
+// newInterestView 复制玩家状态并建立网格索引，调用方需持有 room.mutex
+func newInterestView(states map[string]PlayerState, config InterestConfig) *interestView {
+	v := &interestView{
+		config: config,
+		states: make(map[string]PlayerState, len(states)),
+		cells:  make(map[cellKey][]string),
+	}
+	for playerID, state := range states {
+		v.states[playerID] = state
+		key := v.cell(state.Position.X, state.Position.Y)
+		v.cells[key] = append(v.cells[key], playerID)
+	}
+	return v
+}
+
+func (v *interestView) cell(x, y float64) cellKey {
+	return cellKey{
+		X: int(math.Floor(x / v.config.ExitRadius)),
+		Y: int(math.Floor(y / v.config.ExitRadius)),
+	}
+}
+
+// near 返回 Radius 内和 ExitRadius 内的玩家
+func (v *interestView) near(pos Position) (inner, outer map[string]bool) {
+	inner = make(map[string]bool)
+	outer = make(map[string]bool)
+
+	center := v.cell(pos.X, pos.Y)
+	for x := center.X - 1; x <= center.X+1; x++ {
+		for y := center.Y - 1; y <= center.Y+1; y++ {
+			for _, playerID := range v.cells[cellKey{X: x, Y: y}] {
+				other := v.states[playerID].Position
+				distance := math.Hypot(other.X-pos.X, other.Y-pos.Y)
+				if distance <= v.config.ExitRadius {
+					outer[playerID] = true
+				}
+				if distance <= v.config.Radius {
+					inner[playerID] = true
+				}
+			}
+		}
+	}
+	return inner, outer
+}
+
+// filter 将房间增量过滤为玩家可见范围内的增量并更新可见集合：进入视野的玩家带有全部字段并列在 Entered 中，
+// 离开视野的玩家列在 Exited 中。可见范围内没有变化时返回 nil。调用方需持有 player.syncMutex
+func (v *interestView) filter(player *Player, delta *StateSync) *StateSync {
+	// 刚加入房间的玩家还没有同步的状态，按当前位置计算
+	pos := player.Position
+	if state, exists := v.states[player.ID]; exists {
+		pos = state.Position
+	}
+
+	sync := &player.sync
+	wasVisible := func(playerID string) bool {
+		return sync.visible == nil || sync.visible[playerID]
+	}
+
+	inner, outer := v.near(pos)
+	visible := make(map[string]bool, len(outer))
+	for playerID := range outer {
+		if inner[playerID] || wasVisible(playerID) {
+			visible[playerID] = true
+		}
+	}
+
+	filtered := &StateSync{
+		Version: delta.Version,
+		Base:    sync.sent,
+		Tick:    delta.Tick,
+		Status:  delta.Status,
+		Players: make(map[string]*PlayerStateDelta),
+	}
+	for playerID := range visible {
+		if !wasVisible(playerID) {
+			filtered.Entered = append(filtered.Entered, playerID)
+			filtered.Players[playerID] = diffPlayerState(nil, v.states[playerID])
+		} else if changes, changed := delta.Players[playerID]; changed {
+			filtered.Players[playerID] = changes
+		}
+	}
+	for _, playerID := range delta.Removed {
+		if wasVisible(playerID) {
+			filtered.Removed = append(filtered.Removed, playerID)
+		}
+	}
+	for playerID := range v.states {
+		if wasVisible(playerID) && !visible[playerID] {
+			filtered.Exited = append(filtered.Exited, playerID)
+		}
+	}
+
+	sync.visible = visible
+	if filtered.empty() {
+		return nil
+	}
+	return filtered
+}
+
+// filterSnapshot 将完整快照过滤为 Radius 内的玩家，并以此重置可见集合。调用方需持有 player.syncMutex
+func (c InterestConfig) filterSnapshot(player *Player, snapshot *StateSync) *StateSync {
+	self, exists := snapshot.Players[player.ID]
+	if !exists || self.Position == nil {
+		player.sync.visible = nil
+		return snapshot
+	}
+
+	visible := make(map[string]bool)
+	players := make(map[string]*PlayerStateDelta)
+	for playerID, state := range snapshot.Players {
+		if math.Hypot(state.Position.X-self.Position.X, state.Position.Y-self.Position.Y) <= c.Radius {
+			visible[playerID] = true
+			players[playerID] = state
+		}
+	}
+	player.sync.visible = visible
+	snapshot.Players = players
+	return snapshot
+}
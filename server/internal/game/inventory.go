@@ -0,0 +1,491 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// MsgTypeInventory 背包操作及背包状态推送
+const MsgTypeInventory = "inventory"
+
+// 背包操作类型
+const (
+	InventoryList    = "list"
+	InventoryRemove  = "remove"
+	InventoryEquip   = "equip"
+	InventoryUnequip = "unequip"
+)
+
+// 道具稀有度，由低到高
+const (
+	RarityCommon    = "common"
+	RarityUncommon  = "uncommon"
+	RarityRare      = "rare"
+	RarityEpic      = "epic"
+	RarityLegendary = "legendary"
+)
+
+var rarityRank = map[string]int{
+	RarityCommon:    0,
+	RarityUncommon:  1,
+	RarityRare:      2,
+	RarityEpic:      3,
+	RarityLegendary: 4,
+}
+
+// DefaultInventoryCapacity 默认背包格数
+const DefaultInventoryCapacity = 30
+
+// 背包错误
+var (
+	ErrInventoryFull = errors.New("inventory is full")
+	ErrItemNotFound  = errors.New("item not found")
+	ErrNotEquippable = errors.New("item is not equippable")
+	ErrItemInTrade   = errors.New("item is held in a trade")
+	ErrItemEquipped  = errors.New("item is equipped")
+)
+
+// Item 背包中的道具
+type Item struct {
+	ID           string                 `json:"id"`
+	Type         string                 `json:"type"`
+	Name         string                 `json:"name"`
+	Rarity       string                 `json:"rarity"`
+	Quantity     int                    `json:"quantity"`
+	Stackable    bool                   `json:"stackable,omitempty"`
+	Slot         string                 `json:"slot,omitempty"` // 可装备的槽位，为空表示不可装备
+	Equipped     bool                   `json:"equipped"`
+	CredentialID string                 `json:"credentialId,omitempty"` // 稀有道具的所有权凭证
+	TradeID      string                 `json:"tradeId,omitempty"`      // 托管在进行中的交易里，不能移除、装备或再次交易
+	AcquiredAt   time.Time              `json:"acquiredAt"`
+	Properties   map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Inventory 玩家背包
+type Inventory struct {
+	Capacity int               `json:"capacity"`
+	Items    map[string]*Item  `json:"items"`
+	Equipped map[string]string `json:"equipped"` // 槽位 -> 道具ID
+	mutex    sync.RWMutex
+}
+
+// NewInventory 创建空背包
+func NewInventory(capacity int) *Inventory {
+	if capacity <= 0 {
+		capacity = DefaultInventoryCapacity
+	}
+	return &Inventory{
+		Capacity: capacity,
+		Items:    make(map[string]*Item),
+		Equipped: make(map[string]string),
+	}
+}
+
+// Add 添加道具，可堆叠道具与同类型道具合并，返回背包中的道具
+func (inv *Inventory) Add(item *Item) (*Item, error) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+
+	if item.Quantity <= 0 {
+		item.Quantity = 1
+	}
+	if stack := inv.stackFor(item); stack != nil {
+		stack.Quantity += item.Quantity
+		return stack, nil
+	}
+	if len(inv.Items) >= inv.Capacity {
+		return nil, ErrInventoryFull
+	}
+	return inv.insert(item), nil
+}
+
+// stackFor 返回可堆叠道具可以合并进去的同类型道具，托管中的道具不参与合并，调用方需持有 inv.mutex
+func (inv *Inventory) stackFor(item *Item) *Item {
+	if !item.Stackable {
+		return nil
+	}
+	for _, existing := range inv.Items {
+		if existing.Stackable && existing.Type == item.Type && existing.TradeID == "" {
+			return existing
+		}
+	}
+	return nil
+}
+
+// insert 将道具放入新的格子，调用方需持有 inv.mutex 并已检查容量
+func (inv *Inventory) insert(item *Item) *Item {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+	if item.Rarity == "" {
+		item.Rarity = RarityCommon
+	}
+	if item.AcquiredAt.IsZero() {
+		item.AcquiredAt = time.Now()
+	}
+	item.Equipped = false
+	item.TradeID = ""
+
+	inv.Items[item.ID] = item
+	return item
+}
+
+// Remove 移除指定数量的道具，数量为 0 或超过持有数量时移除全部
+func (inv *Inventory) Remove(itemID string, quantity int) (*Item, error) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+
+	item, exists := inv.Items[itemID]
+	if !exists {
+		return nil, ErrItemNotFound
+	}
+	if item.TradeID != "" {
+		return nil, ErrItemInTrade
+	}
+
+	if quantity > 0 && quantity < item.Quantity {
+		item.Quantity -= quantity
+		return item, nil
+	}
+
+	if item.Equipped {
+		delete(inv.Equipped, item.Slot)
+	}
+	delete(inv.Items, itemID)
+	return item, nil
+}
+
+// Equip 装备道具，同槽位已装备的道具会被卸下
+func (inv *Inventory) Equip(itemID string) (*Item, error) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+
+	item, exists := inv.Items[itemID]
+	if !exists {
+		return nil, ErrItemNotFound
+	}
+	if item.Slot == "" {
+		return nil, ErrNotEquippable
+	}
+	if item.TradeID != "" {
+		return nil, ErrItemInTrade
+	}
+
+	if previousID, occupied := inv.Equipped[item.Slot]; occupied {
+		if previous, ok := inv.Items[previousID]; ok {
+			previous.Equipped = false
+		}
+	}
+
+	item.Equipped = true
+	inv.Equipped[item.Slot] = item.ID
+	return item, nil
+}
+
+// Unequip 卸下指定槽位的道具
+func (inv *Inventory) Unequip(slot string) (*Item, error) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+
+	itemID, occupied := inv.Equipped[slot]
+	if !occupied {
+		return nil, fmt.Errorf("slot %s is empty", slot)
+	}
+	delete(inv.Equipped, slot)
+
+	item, exists := inv.Items[itemID]
+	if !exists {
+		return nil, ErrItemNotFound
+	}
+	item.Equipped = false
+	return item, nil
+}
+
+// Escrow 将道具托管到交易中，数量少于持有数量时拆出一份新的道具，数量为 0 时托管全部。
+// 已装备或已在交易中的道具不能托管
+func (inv *Inventory) Escrow(itemID string, quantity int, tradeID string) (*Item, error) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+
+	item, exists := inv.Items[itemID]
+	if !exists {
+		return nil, ErrItemNotFound
+	}
+	if item.TradeID != "" {
+		return nil, ErrItemInTrade
+	}
+	if item.Equipped {
+		return nil, ErrItemEquipped
+	}
+	if quantity > item.Quantity {
+		return nil, fmt.Errorf("only %d of item %s", item.Quantity, itemID)
+	}
+
+	if quantity <= 0 || quantity == item.Quantity {
+		item.TradeID = tradeID
+		copied := *item
+		return &copied, nil
+	}
+
+	if len(inv.Items) >= inv.Capacity {
+		return nil, ErrInventoryFull
+	}
+	split := *item
+	split.ID = uuid.New().String()
+	split.Quantity = quantity
+	split.CredentialID = ""
+	split.TradeID = tradeID
+	item.Quantity -= quantity
+	inv.Items[split.ID] = &split
+
+	copied := split
+	return &copied, nil
+}
+
+// Release 归还交易中托管的道具，可堆叠道具合并回同类型道具
+func (inv *Inventory) Release(tradeID string) {
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+
+	for itemID, item := range inv.Items {
+		if item.TradeID != tradeID {
+			continue
+		}
+		item.TradeID = ""
+		if stack := inv.stackFor(item); stack != nil && stack != item {
+			stack.Quantity += item.Quantity
+			delete(inv.Items, itemID)
+		}
+	}
+}
+
+// escrowed 返回托管在交易中的道具，调用方需持有 inv.mutex
+func (inv *Inventory) escrowed(tradeID string) []*Item {
+	var items []*Item
+	for _, item := range inv.Items {
+		if item.TradeID == tradeID {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// slotsNeeded 收下这些道具需要的新格子数，调用方需持有 inv.mutex
+func (inv *Inventory) slotsNeeded(items []*Item) int {
+	slots := 0
+	merged := make(map[string]bool)
+	for _, item := range items {
+		if item.Stackable && (merged[item.Type] || inv.stackFor(item) != nil) {
+			continue
+		}
+		if item.Stackable {
+			merged[item.Type] = true
+		}
+		slots++
+	}
+	return slots
+}
+
+// exchangeEscrow 原子地交换两个背包中托管在交易里的道具，任一方放不下时不做任何修改。
+// 返回双方收到的道具（可能是合并后的堆叠）及其原有的所有权凭证。
+// 同时锁住两个背包，调用方需保证不会并发交换，见 Trades.mutex
+func exchangeEscrow(tradeID string, a, b *Inventory) (toA, toB []*Item, credentials []string, err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	fromA, fromB := a.escrowed(tradeID), b.escrowed(tradeID)
+	if len(a.Items)-len(fromA)+a.slotsNeeded(fromB) > a.Capacity {
+		return nil, nil, nil, ErrInventoryFull
+	}
+	if len(b.Items)-len(fromB)+b.slotsNeeded(fromA) > b.Capacity {
+		return nil, nil, nil, ErrInventoryFull
+	}
+
+	move := func(items []*Item, from, to *Inventory) []*Item {
+		received := make([]*Item, 0, len(items))
+		for _, item := range items {
+			delete(from.Items, item.ID)
+			if item.CredentialID != "" {
+				credentials = append(credentials, item.CredentialID)
+				item.CredentialID = ""
+			}
+			item.AcquiredAt = time.Now()
+			if stack := to.stackFor(item); stack != nil {
+				stack.Quantity += item.Quantity
+				received = append(received, stack)
+				continue
+			}
+			received = append(received, to.insert(item))
+		}
+		return received
+	}
+	toB = move(fromA, a, b)
+	toA = move(fromB, b, a)
+	return toA, toB, credentials, nil
+}
+
+// HasItemType 判断背包中是否有该类型的道具
+func (inv *Inventory) HasItemType(itemType string) bool {
+	inv.mutex.RLock()
+	defer inv.mutex.RUnlock()
+
+	for _, item := range inv.Items {
+		if item.Type == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot 复制背包内容，用于序列化
+func (inv *Inventory) Snapshot() *InventoryRecord {
+	inv.mutex.RLock()
+	defer inv.mutex.RUnlock()
+
+	record := &InventoryRecord{
+		Capacity: inv.Capacity,
+		Items:    make([]*Item, 0, len(inv.Items)),
+	}
+	for _, item := range inv.Items {
+		copied := *item
+		record.Items = append(record.Items, &copied)
+	}
+	return record
+}
+
+// inventoryFromRecord 从持久化记录恢复背包
+func inventoryFromRecord(record *InventoryRecord) *Inventory {
+	inv := NewInventory(record.Capacity)
+	for _, item := range record.Items {
+		// 交易只保存在内存中，重启后托管的道具回到背包
+		item.TradeID = ""
+		inv.Items[item.ID] = item
+		if item.Equipped && item.Slot != "" {
+			inv.Equipped[item.Slot] = item.ID
+		}
+	}
+	return inv
+}
+
+// requiresCredential 判断道具是否需要颁发所有权凭证
+func requiresCredential(item *Item, minRarity string) bool {
+	if minRarity == "" {
+		return false
+	}
+	return rarityRank[item.Rarity] >= rarityRank[minRarity]
+}
+
+// GrantItem 向玩家发放道具，稀有道具同时颁发 ItemCredential 以便跨游戏证明所有权
+func (s *SimpleServer) GrantItem(player *Player, item *Item) (*Item, error) {
+	added, err := player.Inventory.Add(item)
+	if err != nil {
+		return nil, err
+	}
+
+	s.issueItemCredential(player, added)
+	s.persistInventory(player)
+	s.sendInventory(player, "added", added)
+	return added, nil
+}
+
+// issueItemCredential 稀有道具还没有所有权凭证时向玩家颁发 ItemCredential
+func (s *SimpleServer) issueItemCredential(player *Player, item *Item) {
+	if !requiresCredential(item, s.itemCredentialRarity) || item.CredentialID != "" || player.isGuest() {
+		return
+	}
+
+	gameID := "default"
+	if room := player.Room; room != nil {
+		gameID = room.GameID
+	}
+
+	credential, err := s.vcService.IssueItemCredential(player.traceContext(), player.DID, gameID, player.ID, item.ID, item.Name, item.Rarity)
+	if err != nil {
+		player.log().Error("Failed to issue item credential", "item_id", item.ID, logging.Err(err))
+		return
+	}
+	item.CredentialID = credential.ID
+	if player.Connection != nil {
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeCredential,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"credential": credential,
+				"message":    s.localize(player, "credential.item", i18n.Params{"name": item.Name}),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// handleInventory 处理 inventory 消息
+func (s *SimpleServer) handleInventory(player *Player, payload *InventoryPayload) {
+	var (
+		item *Item
+		err  error
+	)
+
+	switch payload.Action {
+	case InventoryList:
+		s.sendInventory(player, InventoryList, nil)
+		return
+	case InventoryRemove:
+		item, err = player.Inventory.Remove(payload.ItemID, payload.Quantity)
+	case InventoryEquip:
+		item, err = player.Inventory.Equip(payload.ItemID)
+	case InventoryUnequip:
+		item, err = player.Inventory.Unequip(payload.Slot)
+	}
+
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeInventoryFailed, "error.inventory_failed", i18n.Params{"action": payload.Action, "error": err})
+		return
+	}
+
+	s.persistInventory(player)
+	s.sendInventory(player, payload.Action, item)
+}
+
+// sendInventory 向玩家推送背包状态
+func (s *SimpleServer) sendInventory(player *Player, action string, item *Item) {
+	if player.Connection == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"action":    action,
+		"inventory": player.Inventory.Snapshot(),
+	}
+	if item != nil {
+		data["item"] = item
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:      MsgTypeInventory,
+		PlayerID:  player.ID,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// persistInventory 持久化玩家背包
+func (s *SimpleServer) persistInventory(player *Player) {
+	if s.persistence == nil || !player.persistent() {
+		return
+	}
+
+	record := player.Inventory.Snapshot()
+	record.PlayerID = player.ID
+	if err := s.persistence.SaveInventory(record); err != nil {
+		player.log().Error("Failed to persist inventory", logging.Err(err))
+	}
+}
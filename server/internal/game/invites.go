@@ -0,0 +1,237 @@
+package game
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/internal/i18n"
+)
+
+// MsgTypeRoomInvite 房主请求邀请码及邀请码推送
+const MsgTypeRoomInvite = "room_invite"
+
+// 邀请码有效期和使用次数限制
+const (
+	DefaultInviteTTL = 10 * time.Minute
+	MaxInviteTTL     = 24 * time.Hour
+	maxInviteUses    = 100
+)
+
+// 邀请码格式：base64url(ID 9 字节 || 过期时间 4 字节 || HMAC 前 8 字节)
+const (
+	inviteIDSize     = 9
+	inviteExpirySize = 4
+	inviteMACSize    = 8
+)
+
+// 邀请码错误
+var (
+	ErrInviteInvalid = errors.New("invalid invite code")
+	ErrInviteExpired = errors.New("invite code has expired")
+	ErrInviteUsedUp  = errors.New("invite code has been used up")
+	ErrNotRoomOwner  = errors.New("only the room owner can create invite codes")
+)
+
+// Invite 房间邀请码
+type Invite struct {
+	Code      string    `json:"code"`
+	RoomID    string    `json:"roomId"`
+	CreatedBy string    `json:"createdBy"`
+	MaxUses   int       `json:"maxUses"`
+	Uses      int       `json:"uses"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Invites 签名的短期邀请码，支持单次和多次使用
+type Invites struct {
+	key     []byte
+	invites map[string]*Invite
+	mutex   sync.Mutex
+}
+
+// NewInvites 创建邀请码管理器，签名密钥在每次启动时随机生成
+func NewInvites() (*Invites, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate invite key: %w", err)
+	}
+
+	return &Invites{
+		key:     key,
+		invites: make(map[string]*Invite),
+	}, nil
+}
+
+// Create 为房间生成邀请码，maxUses 为 1 时只能使用一次
+func (i *Invites) Create(roomID, createdBy string, maxUses int, ttl time.Duration) (*Invite, error) {
+	raw := make([]byte, inviteIDSize+inviteExpirySize, inviteIDSize+inviteExpirySize+inviteMACSize)
+	if _, err := rand.Read(raw[:inviteIDSize]); err != nil {
+		return nil, fmt.Errorf("generate invite ID: %w", err)
+	}
+
+	// 过期时间按秒截断，与编码在邀请码中的值一致
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	binary.BigEndian.PutUint32(raw[inviteIDSize:], uint32(expiresAt.Unix()))
+	raw = append(raw, i.sign(raw)...)
+
+	invite := &Invite{
+		Code:      base64.RawURLEncoding.EncodeToString(raw),
+		RoomID:    roomID,
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.prune(time.Now())
+	i.invites[invite.Code] = invite
+	return invite, nil
+}
+
+// Check 校验邀请码的签名、有效期和剩余次数，不消耗使用次数
+func (i *Invites) Check(code string) (*Invite, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.check(code, time.Now())
+}
+
+// Use 校验并消耗一次邀请码
+func (i *Invites) Use(code string) (*Invite, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	invite, err := i.check(code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	invite.Uses++
+	if invite.Uses >= invite.MaxUses {
+		delete(i.invites, code)
+	}
+	copied := *invite
+	return &copied, nil
+}
+
+// RevokeRoom 删除房间的所有邀请码
+func (i *Invites) RevokeRoom(roomID string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for code, invite := range i.invites {
+		if invite.RoomID == roomID {
+			delete(i.invites, code)
+		}
+	}
+}
+
+// check 调用方需持有 i.mutex
+func (i *Invites) check(code string, now time.Time) (*Invite, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(code)
+	if err != nil || len(raw) != inviteIDSize+inviteExpirySize+inviteMACSize {
+		return nil, ErrInviteInvalid
+	}
+	signed, mac := raw[:inviteIDSize+inviteExpirySize], raw[inviteIDSize+inviteExpirySize:]
+	if !hmac.Equal(mac, i.sign(signed)) {
+		return nil, ErrInviteInvalid
+	}
+
+	// 先用签名中的过期时间判断，已过期的邀请码可能已被清理
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint32(signed[inviteIDSize:])), 0)
+	if !now.Before(expiresAt) {
+		return nil, ErrInviteExpired
+	}
+
+	invite, exists := i.invites[code]
+	if !exists {
+		// 签名有效但不在列表中：已用完或房间已关闭
+		return nil, ErrInviteUsedUp
+	}
+	return invite, nil
+}
+
+// sign 返回邀请码内容的截断 HMAC-SHA256
+func (i *Invites) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, i.key)
+	mac.Write(data)
+	return mac.Sum(nil)[:inviteMACSize]
+}
+
+// prune 删除已过期的邀请码，调用方需持有 i.mutex
+func (i *Invites) prune(now time.Time) {
+	for code, invite := range i.invites {
+		if !now.Before(invite.ExpiresAt) {
+			delete(i.invites, code)
+		}
+	}
+}
+
+// handleRoomInvite 处理 room_invite 消息，房主为所在房间生成邀请码；没有房主的房间任何成员都可以邀请。
+// 指定受邀玩家时邀请码同时放入其收件箱
+func (s *SimpleServer) handleRoomInvite(player *Player, payload *RoomInvitePayload) {
+	room := player.Room
+	if room == nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeInviteFailed, "error.not_in_room", nil)
+		return
+	}
+
+	room.mutex.RLock()
+	ownerID := room.OwnerID
+	room.mutex.RUnlock()
+	if ownerID != "" && ownerID != player.ID {
+		s.sendErrorToPlayer(player, ErrCodeInviteFailed, ErrNotRoomOwner.Error())
+		return
+	}
+
+	ttl := DefaultInviteTTL
+	if payload.TTLSeconds > 0 {
+		ttl = time.Duration(payload.TTLSeconds) * time.Second
+	}
+	invite, err := s.invites.Create(room.ID, player.ID, payload.MaxUses, ttl)
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeInviteFailed, "error.create_invite", i18n.Params{"error": err})
+		return
+	}
+
+	if payload.To != "" {
+		_, err := s.Notify(payload.To, MailInvite, s.localizeFor(payload.To, "inbox.invite", i18n.Params{"nickname": player.Nickname}), "", map[string]interface{}{
+			"code":         invite.Code,
+			"roomId":       room.ID,
+			"gameId":       room.GameID,
+			"from":         player.DID,
+			"fromNickname": player.Nickname,
+			"expiresAt":    invite.ExpiresAt,
+		})
+		if err != nil {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeInviteFailed, "error.send_invite", i18n.Params{"error": err})
+			return
+		}
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:      MsgTypeRoomInvite,
+		PlayerID:  player.ID,
+		RoomID:    room.ID,
+		Data:      invite,
+		Timestamp: time.Now(),
+	})
+}
+
+// inviteRejection 将邀请码错误转换为进入房间被拒绝的原因
+func inviteRejection(err error) *EntryRejection {
+	reason := RejectInviteInvalid
+	switch {
+	case errors.Is(err, ErrInviteExpired):
+		reason = RejectInviteExpired
+	case errors.Is(err, ErrInviteUsedUp):
+		reason = RejectInviteUsedUp
+	}
+	return &EntryRejection{Reason: reason, Message: err.Error()}
+}
@@ -0,0 +1,188 @@
+package game
+
+import (
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/i18n"
+)
+
+// DefaultLocale 客户端未指定或指定了不支持的语言时使用的语言
+const DefaultLocale = "en"
+
+// LocalesDir 静态文件目录下存放语言包的子目录，语言包覆盖或补充内置的消息
+const LocalesDir = "locales"
+
+// builtinMessages 内置的服务端消息，键按功能分组
+var builtinMessages = map[string]i18n.Bundle{
+	"en": {
+		"credential.achievement": "Credential earned: {name}",
+		"credential.level":       "Reached level {level}",
+		"credential.item":        "Item credential earned: {name}",
+		"credential.skill":       "Skill credential earned: {name}",
+		"credential.guild":       "Guild credential earned: {name}",
+		"credential.trade":       "Trade credential earned: trade with {counterparty}",
+
+		"inbox.credential": "New credential: {type}",
+		"inbox.invite":     "{nickname} invited you to a room",
+
+		"sanction.ban":        "you are banned",
+		"sanction.ban_until":  "you are banned until {until}",
+		"sanction.mute":       "you are muted",
+		"sanction.mute_until": "you are muted until {until}",
+		"sanction.reason":     "{message}: {reason}",
+
+		"error.unauthenticated":       "authenticate before sending {type}",
+		"error.invalid_did":           "Invalid DID: {error}",
+		"error.auth_failed":           "Authentication failed: {error}",
+		"error.guest_failed":          "Guest play failed: {error}",
+		"error.resume_failed":         "Session token is invalid or has expired, authenticate again",
+		"error.upgrade_required":      "protocol version {version} is no longer supported, upgrade to version {minVersion} or later",
+		"error.already_connected":     "this DID already has {limit} active session(s)",
+		"error.session_replaced":      "signed in from another connection",
+		"error.rate_limited":          "too many {type} messages, retry in {wait}ms",
+		"error.rate_limit_disconnect": "too many messages, disconnecting",
+		"error.room_not_found":        "Room no longer exists",
+		"error.not_in_room":           "Not in a room",
+		"error.create_room":           "Failed to create room: {error}",
+		"error.create_overflow_room":  "Failed to create overflow room: {error}",
+		"error.join_room":             "Failed to join room: {error}",
+		"error.player_offline":        "player is not online: {player}",
+		"error.chat_join_room":        "join a room before chatting",
+		"error.chat_join_team":        "join a team before using team chat",
+		"error.chat_join_guild":       "join a guild before using guild chat",
+		"error.history_join_room":     "join a room before fetching chat history",
+		"error.history_join_team":     "join a team before fetching team chat history",
+		"error.history_join_guild":    "join a guild before fetching guild chat history",
+		"error.history_failed":        "Failed to load chat history: {error}",
+		"error.mute_self":             "cannot mute yourself",
+		"error.team_join_room":        "join a room before joining a team",
+		"error.no_pending_request":    "No pending presentation request",
+		"error.request_expired":       "Presentation request expired",
+		"error.invalid_presentation":  "Invalid presentation: {error}",
+		"error.create_invite":         "Failed to create invite: {error}",
+		"error.send_invite":           "Failed to send invite: {error}",
+		"error.didcomm_disabled":      "DIDComm messaging is not enabled",
+		"error.resolve_recipient":     "Failed to resolve recipient: {error}",
+		"error.pack_message":          "Failed to pack message: {error}",
+		"error.deliver_message":       "Failed to deliver message: {error}",
+		"error.handle_message":        "Failed to handle message: {error}",
+		"error.inventory_failed":      "Inventory {action} failed: {error}",
+		"error.skills_failed":         "Skills {action} failed: {error}",
+		"error.guild_failed":          "Guild {action} failed: {error}",
+		"error.trade_failed":          "Trade {action} failed: {error}",
+	},
+	"zh-CN": {
+		"credential.achievement": "获得凭证: {name}",
+		"credential.level":       "达到等级: {level}",
+		"credential.item":        "获得道具凭证: {name}",
+		"credential.skill":       "获得技能凭证: {name}",
+		"credential.guild":       "获得公会凭证: {name}",
+		"credential.trade":       "获得交易凭证: 与 {counterparty} 的交易",
+
+		"inbox.credential": "新凭证: {type}",
+		"inbox.invite":     "{nickname} 邀请你加入房间",
+
+		"sanction.ban":        "你已被封禁",
+		"sanction.ban_until":  "你已被封禁至 {until}",
+		"sanction.mute":       "你已被禁言",
+		"sanction.mute_until": "你已被禁言至 {until}",
+		"sanction.reason":     "{message}: {reason}",
+
+		"error.unauthenticated":       "发送 {type} 消息前请先认证",
+		"error.invalid_did":           "无效的 DID: {error}",
+		"error.auth_failed":           "认证失败：{error}",
+		"error.guest_failed":          "访客操作失败：{error}",
+		"error.resume_failed":         "会话令牌无效或已过期，请重新认证",
+		"error.upgrade_required":      "不再支持协议版本 {version}，请升级到版本 {minVersion} 或更高",
+		"error.already_connected":     "该 DID 已有 {limit} 个活动会话",
+		"error.session_replaced":      "已在其他连接上登录",
+		"error.rate_limited":          "{type} 消息过多，请在 {wait}ms 后重试",
+		"error.rate_limit_disconnect": "消息过多，连接已断开",
+		"error.room_not_found":        "房间已不存在",
+		"error.not_in_room":           "不在房间中",
+		"error.create_room":           "创建房间失败: {error}",
+		"error.create_overflow_room":  "创建溢出房间失败: {error}",
+		"error.join_room":             "加入房间失败: {error}",
+		"error.player_offline":        "玩家不在线: {player}",
+		"error.chat_join_room":        "请先加入房间再聊天",
+		"error.chat_join_team":        "请先加入队伍再使用队伍聊天",
+		"error.chat_join_guild":       "请先加入公会再使用公会聊天",
+		"error.history_join_room":     "请先加入房间再获取聊天记录",
+		"error.history_join_team":     "请先加入队伍再获取队伍聊天记录",
+		"error.history_join_guild":    "请先加入公会再获取公会聊天记录",
+		"error.history_failed":        "加载聊天记录失败: {error}",
+		"error.mute_self":             "不能屏蔽自己",
+		"error.team_join_room":        "请先加入房间再加入队伍",
+		"error.no_pending_request":    "没有待处理的出示请求",
+		"error.request_expired":       "出示请求已过期",
+		"error.invalid_presentation":  "无效的出示: {error}",
+		"error.create_invite":         "创建邀请失败: {error}",
+		"error.send_invite":           "发送邀请失败: {error}",
+		"error.didcomm_disabled":      "未启用 DIDComm 消息",
+		"error.resolve_recipient":     "解析接收方失败: {error}",
+		"error.pack_message":          "打包消息失败: {error}",
+		"error.deliver_message":       "投递消息失败: {error}",
+		"error.handle_message":        "处理消息失败: {error}",
+		"error.inventory_failed":      "背包操作 {action} 失败: {error}",
+		"error.skills_failed":         "技能操作 {action} 失败: {error}",
+		"error.guild_failed":          "公会操作 {action} 失败: {error}",
+		"error.trade_failed":          "交易操作 {action} 失败: {error}",
+	},
+}
+
+// NewCatalog 创建包含内置消息的消息目录，fallback 为空时使用 DefaultLocale
+func NewCatalog(fallback string) *i18n.Catalog {
+	if fallback == "" {
+		fallback = DefaultLocale
+	}
+	catalog := i18n.NewCatalog(fallback)
+	for locale, messages := range builtinMessages {
+		catalog.Add(locale, messages)
+	}
+	return catalog
+}
+
+// SetCatalog 替换服务端消息目录，应在接受连接前调用
+func (s *SimpleServer) SetCatalog(catalog *i18n.Catalog) {
+	s.catalog = catalog
+}
+
+// localize 按玩家协商的语言生成消息，player 为空时使用默认语言
+func (s *SimpleServer) localize(player *Player, key string, params i18n.Params) string {
+	return s.catalog.Translate(player.locale(), key, params)
+}
+
+// localizeFor 按 DID 对应的本实例玩家的语言生成消息，玩家不在本实例时使用默认语言
+func (s *SimpleServer) localizeFor(playerDID, key string, params i18n.Params) string {
+	return s.localize(s.findPlayerByDID(playerDID), key, params)
+}
+
+// localizedError 生成带消息键和参数的错误，客户端可以据此自行翻译
+func (s *SimpleServer) localizedError(locale string, code ErrorCode, key string, params i18n.Params) *ProtocolError {
+	return &ProtocolError{
+		Code:    code,
+		Message: s.catalog.Translate(locale, key, params),
+		Key:     key,
+		Params:  params,
+	}
+}
+
+// sendLocalizedError 按连接协商的语言发送错误，连接尚未认证时 locale 为空
+func (s *SimpleServer) sendLocalizedError(conn *websocket.Conn, locale string, code ErrorCode, key string, params i18n.Params) {
+	s.sendProtocolError(conn, s.localizedError(locale, code, key, params))
+}
+
+// sendLocalizedErrorToPlayer 按玩家协商的语言发送错误
+func (s *SimpleServer) sendLocalizedErrorToPlayer(player *Player, code ErrorCode, key string, params i18n.Params) {
+	if player.Connection != nil {
+		s.sendLocalizedError(player.Connection, player.locale(), code, key, params)
+	}
+}
+
+// locale 返回玩家认证时协商的语言，player 为空时返回空字符串
+func (p *Player) locale() string {
+	if p == nil {
+		return ""
+	}
+	return p.Locale
+}
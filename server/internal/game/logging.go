@@ -0,0 +1,24 @@
+package game
+
+import (
+	"log/slog"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// log 返回带有玩家 DID、ID 和所在房间字段的日志记录器；已连接的玩家还带有连接 ID
+func (p *Player) log() *slog.Logger {
+	logger := p.logger
+	if logger == nil {
+		logger = slog.Default().With(logging.KeyPlayerDID, p.DID, logging.KeyPlayerID, p.ID)
+	}
+	if room := p.Room; room != nil {
+		logger = logger.With(logging.KeyRoomID, room.ID)
+	}
+	return logger
+}
+
+// log 返回带有房间 ID 字段的日志记录器
+func (r *GameRoom) log() *slog.Logger {
+	return slog.Default().With(logging.KeyRoomID, r.ID)
+}
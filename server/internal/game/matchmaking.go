@@ -0,0 +1,529 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// 匹配相关消息类型
+const (
+	MsgTypeQueueMatch   = "queue_match"
+	MsgTypeCancelMatch  = "cancel_match"
+	MsgTypeMatchFound   = "match_found"
+	MsgTypeMatchTimeout = "match_timeout"
+)
+
+// MatchmakingConfig 匹配配置
+type MatchmakingConfig struct {
+	TeamSize      int           // 每队人数
+	TeamCount     int           // 队伍数量
+	LevelBand     int           // 初始等级差容忍度
+	ScoreBand     int           // 初始分数差容忍度
+	LatencyBand   int           // 初始延迟差容忍度（毫秒），延迟为心跳测得的往返时延
+	WidenInterval time.Duration // 每等待该时长，容忍度扩大一倍初始值
+	BackfillAfter time.Duration // 等待超过该时长后允许补位进入已有对局
+	QueueTimeout  time.Duration // 等待超时时间，超时后移出队列
+	TickInterval  time.Duration // 匹配检查间隔
+}
+
+// DefaultMatchmakingConfig 返回默认匹配配置
+func DefaultMatchmakingConfig() MatchmakingConfig {
+	return MatchmakingConfig{
+		TeamSize:      2,
+		TeamCount:     2,
+		LevelBand:     2,
+		ScoreBand:     200,
+		LatencyBand:   50,
+		WidenInterval: 10 * time.Second,
+		BackfillAfter: 20 * time.Second,
+		QueueTimeout:  60 * time.Second,
+		TickInterval:  time.Second,
+	}
+}
+
+// SetMatchmakingConfig 替换匹配配置，应在接受连接前调用
+func (s *SimpleServer) SetMatchmakingConfig(config MatchmakingConfig) {
+	s.matchmaker.setConfig(config)
+}
+
+// matchSize 返回一局所需的玩家数量
+func (c MatchmakingConfig) matchSize() int {
+	return c.TeamSize * c.TeamCount
+}
+
+// matchTicket 匹配队列中的一条记录，分数和延迟由服务器测得，不采用客户端上报的值
+type matchTicket struct {
+	Player     *Player
+	Mode       string
+	Level      int
+	Score      int // 玩家在该游戏已完成对局中的平均得分
+	Latency    int // 心跳测得的往返时延（毫秒），-1 表示尚未测得
+	EnqueuedAt time.Time
+}
+
+// widen 根据等待时长计算当前容忍度的倍数
+func (t *matchTicket) widen(now time.Time, interval time.Duration) int {
+	if interval <= 0 {
+		return 1
+	}
+	return 1 + int(now.Sub(t.EnqueuedAt)/interval)
+}
+
+// accepts 判断候选玩家是否落在该记录当前的匹配范围内
+func (t *matchTicket) accepts(other *matchTicket, config MatchmakingConfig, now time.Time) bool {
+	factor := t.widen(now, config.WidenInterval)
+
+	if abs(t.Level-other.Level) > config.LevelBand*factor {
+		return false
+	}
+	if abs(t.Score-other.Score) > config.ScoreBand*factor {
+		return false
+	}
+	// 尚未测得延迟的玩家不按延迟筛选
+	if t.Latency >= 0 && other.Latency >= 0 && abs(t.Latency-other.Latency) > config.LatencyBand*factor {
+		return false
+	}
+	return true
+}
+
+// Match 匹配成功的对局
+type Match struct {
+	ID    string
+	Mode  string
+	Teams [][]*matchTicket
+}
+
+// Matchmaker 按等级、分数和延迟分段的匹配队列
+type Matchmaker struct {
+	config MatchmakingConfig
+	queues map[string][]*matchTicket // 模式 -> 队列（按入队时间排序）
+	mutex  sync.Mutex
+}
+
+// NewMatchmaker 创建匹配器
+func NewMatchmaker(config MatchmakingConfig) *Matchmaker {
+	return &Matchmaker{
+		config: config.normalize(),
+		queues: make(map[string][]*matchTicket),
+	}
+}
+
+// normalize 将无效的队伍配置替换为最小可用值
+func (c MatchmakingConfig) normalize() MatchmakingConfig {
+	if c.TeamSize <= 0 {
+		c.TeamSize = 1
+	}
+	if c.TeamCount <= 0 {
+		c.TeamCount = 2
+	}
+	return c
+}
+
+func (m *Matchmaker) setConfig(config MatchmakingConfig) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.config = config.normalize()
+}
+
+// Enqueue 将玩家加入匹配队列，已在队列中的玩家会被替换
+func (m *Matchmaker) Enqueue(ticket *matchTicket) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.removeLocked(ticket.Player.ID)
+	m.queues[ticket.Mode] = append(m.queues[ticket.Mode], ticket)
+}
+
+// Dequeue 将玩家移出匹配队列
+func (m *Matchmaker) Dequeue(playerID string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.removeLocked(playerID)
+}
+
+func (m *Matchmaker) removeLocked(playerID string) bool {
+	for mode, queue := range m.queues {
+		for i, ticket := range queue {
+			if ticket.Player.ID == playerID {
+				m.queues[mode] = append(queue[:i], queue[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// QueueLength 返回指定模式的排队人数
+func (m *Matchmaker) QueueLength(mode string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.queues[mode])
+}
+
+// matchResult 一次匹配检查的结果
+type matchResult struct {
+	Matches  []*Match
+	Backfill []*matchTicket // 等待时间足够长、可补位进入已有对局的记录
+	Expired  []*matchTicket
+}
+
+// process 执行一次匹配检查：组成对局、标记可补位和超时的记录
+func (m *Matchmaker) process(now time.Time) *matchResult {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := &matchResult{}
+	size := m.config.matchSize()
+
+	for mode, queue := range m.queues {
+		remaining := make([]*matchTicket, 0, len(queue))
+		used := make(map[*matchTicket]bool)
+
+		// 以等待最久的记录为锚点，从其匹配范围内挑选玩家
+		for _, anchor := range queue {
+			if used[anchor] {
+				continue
+			}
+
+			group := []*matchTicket{anchor}
+			for _, candidate := range queue {
+				if len(group) == size {
+					break
+				}
+				if candidate == anchor || used[candidate] {
+					continue
+				}
+				if anchor.accepts(candidate, m.config, now) && candidate.accepts(anchor, m.config, now) {
+					group = append(group, candidate)
+				}
+			}
+
+			if len(group) == size {
+				for _, ticket := range group {
+					used[ticket] = true
+				}
+				result.Matches = append(result.Matches, &Match{
+					ID:    uuid.New().String(),
+					Mode:  mode,
+					Teams: m.balanceTeams(group),
+				})
+			}
+		}
+
+		for _, ticket := range queue {
+			if used[ticket] {
+				continue
+			}
+
+			waited := now.Sub(ticket.EnqueuedAt)
+			switch {
+			case m.config.QueueTimeout > 0 && waited >= m.config.QueueTimeout:
+				result.Expired = append(result.Expired, ticket)
+			case m.config.BackfillAfter > 0 && waited >= m.config.BackfillAfter:
+				result.Backfill = append(result.Backfill, ticket)
+				remaining = append(remaining, ticket)
+			default:
+				remaining = append(remaining, ticket)
+			}
+		}
+
+		if len(remaining) == 0 {
+			delete(m.queues, mode)
+		} else {
+			m.queues[mode] = remaining
+		}
+	}
+
+	return result
+}
+
+// balanceTeams 按等级和分数蛇形分配队伍，使各队实力接近
+func (m *Matchmaker) balanceTeams(group []*matchTicket) [][]*matchTicket {
+	sorted := make([]*matchTicket, len(group))
+	copy(sorted, group)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Level != sorted[j].Level {
+			return sorted[i].Level > sorted[j].Level
+		}
+		return sorted[i].Score > sorted[j].Score
+	})
+
+	teams := make([][]*matchTicket, m.config.TeamCount)
+	for i, ticket := range sorted {
+		round := i / m.config.TeamCount
+		index := i % m.config.TeamCount
+		if round%2 == 1 {
+			index = m.config.TeamCount - 1 - index
+		}
+		teams[index] = append(teams[index], ticket)
+	}
+	return teams
+}
+
+// runMatchmaking 定期执行匹配检查，直到 stop 关闭
+func (s *SimpleServer) runMatchmaking(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.matchmaker.config.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.processMatchmaking(now)
+		}
+	}
+}
+
+// processMatchmaking 处理一次匹配结果：创建房间、补位和超时通知
+func (s *SimpleServer) processMatchmaking(now time.Time) {
+	result := s.matchmaker.process(now)
+
+	for _, match := range result.Matches {
+		s.startMatch(match)
+	}
+
+	for _, ticket := range result.Backfill {
+		room := s.findBackfillRoom(ticket.Mode)
+		if room == nil {
+			continue
+		}
+		if !s.matchmaker.Dequeue(ticket.Player.ID) {
+			continue
+		}
+		s.assignBackfillTeam(room, ticket.Player)
+		go s.joinMatchedRoom(ticket.Player, room, true)
+	}
+
+	for _, ticket := range result.Expired {
+		if ticket.Player.Connection == nil {
+			continue
+		}
+		writeMessage(ticket.Player.Connection, Message{
+			Type:     MsgTypeMatchTimeout,
+			PlayerID: ticket.Player.ID,
+			Data: map[string]interface{}{
+				"mode":   ticket.Mode,
+				"waited": now.Sub(ticket.EnqueuedAt).Seconds(),
+			},
+			Timestamp: now,
+		})
+	}
+}
+
+// startMatch 为匹配成功的玩家创建房间并通知所有参与者
+func (s *SimpleServer) startMatch(match *Match) {
+	roomID := "match-" + match.ID
+	// 匹配模式与已注册的游戏模式同名时使用该游戏模式
+	options := &RoomOptions{}
+	if _, found := s.gameMode(match.Mode); found {
+		options.Mode = match.Mode
+	}
+	room, err := s.getOrCreateRoom(roomID, match.Mode, options)
+	if err != nil {
+		slog.Error("Failed to create room for match", "match_id", match.ID, logging.Err(err))
+		return
+	}
+
+	teams := make(map[string]string)
+	for i, team := range match.Teams {
+		for _, ticket := range team {
+			teams[ticket.Player.ID] = fmt.Sprintf("team-%d", i+1)
+		}
+	}
+
+	room.mutex.Lock()
+	room.MaxPlayers = s.matchmaker.config.matchSize()
+	// 队员在加入房间时进入匹配分配的队伍
+	room.Teams = newTeams(len(match.Teams), room.GameState.Map.SpawnPoints)
+	for i, team := range match.Teams {
+		for _, ticket := range team {
+			room.Teams[i].Members = append(room.Teams[i].Members, ticket.Player.ID)
+		}
+	}
+	room.GameState.Properties["matchId"] = match.ID
+	room.GameState.Properties["matchMode"] = match.Mode
+	room.GameState.Properties["teams"] = teams
+	room.mutex.Unlock()
+
+	for _, team := range match.Teams {
+		for _, ticket := range team {
+			go s.joinMatchedRoom(ticket.Player, room, false)
+		}
+	}
+
+	room.log().Info("Match formed", "match_id", match.ID, "mode", match.Mode, "players", len(teams))
+}
+
+// joinMatchedRoom 通知玩家匹配成功并加入对局房间。
+// 在匹配器协程之外执行，并持有玩家的 handlerMutex，与玩家连接上的 join_room、leave_room 等消息的处理串行，
+// 一名玩家处理消息较慢时也不会阻塞其他玩家的匹配
+func (s *SimpleServer) joinMatchedRoom(player *Player, room *GameRoom, backfill bool) {
+	player.handlerMutex.Lock()
+	defer player.handlerMutex.Unlock()
+
+	// 等待期间断开连接的玩家不再加入
+	if player.Connection == nil {
+		return
+	}
+	s.notifyMatchFound(player, room, backfill)
+	s.completeJoinRoom(player, room)
+}
+
+// findBackfillRoom 查找同模式下仍有空位的对局房间
+func (s *SimpleServer) findBackfillRoom(mode string) *GameRoom {
+	s.roomMutex.RLock()
+	defer s.roomMutex.RUnlock()
+
+	for _, room := range s.rooms {
+		room.mutex.RLock()
+		roomMode, _ := room.GameState.Properties["matchMode"].(string)
+		open := roomMode == mode && len(room.Players) < room.MaxPlayers
+		room.mutex.RUnlock()
+
+		if open {
+			return room
+		}
+	}
+	return nil
+}
+
+// assignBackfillTeam 将补位玩家分配到人数最少的队伍
+func (s *SimpleServer) assignBackfillTeam(room *GameRoom, player *Player) {
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+
+	teams, ok := room.GameState.Properties["teams"].(map[string]string)
+	if !ok {
+		return
+	}
+
+	// 只统计仍在房间内的玩家
+	counts := make(map[string]int)
+	for i := 1; i <= s.matchmaker.config.TeamCount; i++ {
+		counts[fmt.Sprintf("team-%d", i)] = 0
+	}
+	for playerID, team := range teams {
+		if _, present := room.Players[playerID]; present {
+			counts[team]++
+		}
+	}
+
+	best := ""
+	for i := 1; i <= s.matchmaker.config.TeamCount; i++ {
+		team := fmt.Sprintf("team-%d", i)
+		if best == "" || counts[team] < counts[best] {
+			best = team
+		}
+	}
+	teams[player.ID] = best
+}
+
+// notifyMatchFound 向玩家发送 match_found 消息
+func (s *SimpleServer) notifyMatchFound(player *Player, room *GameRoom, backfill bool) {
+	if player.Connection == nil {
+		return
+	}
+
+	room.mutex.RLock()
+	matchID, _ := room.GameState.Properties["matchId"].(string)
+	teams, _ := room.GameState.Properties["teams"].(map[string]string)
+	team := teams[player.ID]
+	room.mutex.RUnlock()
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeMatchFound,
+		PlayerID: player.ID,
+		RoomID:   room.ID,
+		Data: map[string]interface{}{
+			"matchId":  matchID,
+			"team":     team,
+			"backfill": backfill,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// handleQueueMatch 处理 queue_match 消息，将玩家加入匹配队列
+func (s *SimpleServer) handleQueueMatch(player *Player, payload *QueueMatchPayload) {
+	mode := payload.Mode
+	// 匹配成功后以模式作为房间所属的游戏
+	if message, inMaintenance := s.maintenanceFor(mode); inMaintenance {
+		s.sendErrorToPlayer(player, ErrCodeMaintenance, message)
+		return
+	}
+	if rejection := s.guestRejection(player, mode); rejection != nil {
+		s.sendEntryRejection(player, ErrCodeEntryRejected, "", rejection)
+		return
+	}
+
+	latency := -1
+	if rtt, measured := connectionRTT(player.Connection); measured {
+		latency = int(rtt.Milliseconds())
+	}
+	ticket := &matchTicket{
+		Player:     player,
+		Mode:       mode,
+		Level:      player.Level,
+		Score:      s.matchScore(player, mode),
+		Latency:    latency,
+		EnqueuedAt: time.Now(),
+	}
+
+	s.matchmaker.Enqueue(ticket)
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeQueueMatch,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"success":   true,
+			"mode":      mode,
+			"queueSize": s.matchmaker.QueueLength(mode),
+			"teamSize":  s.matchmaker.config.TeamSize,
+			"teamCount": s.matchmaker.config.TeamCount,
+		},
+		Timestamp: time.Now(),
+	})
+
+	player.log().Info("Player queued for match", "mode", mode)
+}
+
+// matchScore 返回玩家在该游戏已完成对局中的平均得分，没有对局记录时为 0
+func (s *SimpleServer) matchScore(player *Player, gameID string) int {
+	entry, err := s.results.Rank(player.DID, LeaderboardQuery{GameID: gameID})
+	if err != nil || entry.Matches == 0 {
+		return 0
+	}
+	return entry.Score / entry.Matches
+}
+
+// handleCancelMatch 处理 cancel_match 消息，将玩家移出匹配队列
+func (s *SimpleServer) handleCancelMatch(player *Player) {
+	removed := s.matchmaker.Dequeue(player.ID)
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeCancelMatch,
+		PlayerID: player.ID,
+		Data: map[string]interface{}{
+			"success": removed,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,111 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	gamestorage "github.com/czh0526/game/server/internal/storage"
+)
+
+func TestConnRTTOnlyCountsMatchingPongs(t *testing.T) {
+	rtt := &connRTT{}
+	start := time.Now()
+
+	// 未发送 ping 时收到的 pong 和载荷不符的 pong 都不计入
+	rtt.pong("anything", start)
+	payload := rtt.ping(start)
+	rtt.pong("forged", start.Add(time.Millisecond))
+	if rtt.smoothed != 0 {
+		t.Fatalf("unsolicited pong measured %v", rtt.smoothed)
+	}
+
+	rtt.pong(string(payload), start.Add(80*time.Millisecond))
+	if rtt.smoothed != 80*time.Millisecond {
+		t.Fatalf("first sample %v, want 80ms", rtt.smoothed)
+	}
+	// 同一 ping 的重复 pong 不再计入
+	rtt.pong(string(payload), start.Add(time.Second))
+	if rtt.smoothed != 80*time.Millisecond {
+		t.Fatalf("duplicate pong changed the estimate to %v", rtt.smoothed)
+	}
+
+	next := start.Add(time.Second)
+	payload = rtt.ping(next)
+	rtt.pong(string(payload), next.Add(160*time.Millisecond))
+	if rtt.smoothed != 90*time.Millisecond {
+		t.Errorf("smoothed %v, want 90ms", rtt.smoothed)
+	}
+}
+
+func TestMatchTicketAccepts(t *testing.T) {
+	config := DefaultMatchmakingConfig()
+	now := time.Now()
+	ticket := func(level, score, latency int) *matchTicket {
+		return &matchTicket{Level: level, Score: score, Latency: latency, EnqueuedAt: now}
+	}
+
+	tests := []struct {
+		name  string
+		other *matchTicket
+		want  bool
+	}{
+		{"within bands", ticket(11, 1100, 60), true},
+		{"level too far", ticket(20, 1000, 40), false},
+		{"score too far", ticket(10, 1500, 40), false},
+		{"latency too far", ticket(10, 1000, 200), false},
+		{"latency not measured", ticket(10, 1000, -1), true},
+	}
+	anchor := ticket(10, 1000, 40)
+	for _, tt := range tests {
+		if got := anchor.accepts(tt.other, config, now); got != tt.want {
+			t.Errorf("%s: accepts = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 等待越久范围越宽
+	widened := now.Add(2 * config.WidenInterval)
+	if !anchor.accepts(ticket(10, 1500, 40), config, widened) {
+		t.Error("score band did not widen with waiting time")
+	}
+}
+
+func TestMatchScoreUsesRecordedResults(t *testing.T) {
+	results, err := NewResults(gamestorage.NewMemoryProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SimpleServer{results: results}
+	player := &Player{ID: "alice", DID: "did:example:alice"}
+
+	if score := s.matchScore(player, "arena"); score != 0 {
+		t.Errorf("score without results = %d, want 0", score)
+	}
+
+	for _, score := range []int{100, 300} {
+		err := results.Record(&MatchResult{
+			GameID:     "arena",
+			Players:    []*PlayerResult{{PlayerID: player.ID, DID: player.DID, Score: score}},
+			FinishedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if score := s.matchScore(player, "arena"); score != 200 {
+		t.Errorf("score = %d, want the average 200", score)
+	}
+	if score := s.matchScore(player, "other"); score != 0 {
+		t.Errorf("score in another game = %d, want 0", score)
+	}
+}
+
+func TestSetMatchmakingConfigNormalizesTeams(t *testing.T) {
+	matchmaker := NewMatchmaker(DefaultMatchmakingConfig())
+	matchmaker.setConfig(MatchmakingConfig{TeamSize: 3, TeamCount: 0})
+	if matchmaker.config.TeamSize != 3 || matchmaker.config.TeamCount != 2 {
+		t.Errorf("config %+v", matchmaker.config)
+	}
+	if size := matchmaker.config.matchSize(); size != 6 {
+		t.Errorf("match size %d, want 6", size)
+	}
+}
@@ -0,0 +1,107 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MessageLimitConfig WebSocket 消息大小限制配置
+type MessageLimitConfig struct {
+	// ReadLimit 单个帧的最大字节数，超过时连接以 close 1009 断开
+	ReadLimit int64
+	// DefaultMaxSize 未在 MaxSizes 中列出的消息类型 data 字段的最大字节数
+	DefaultMaxSize int
+	// MaxSizes 消息类型 -> data 字段的最大字节数，携带凭证的消息需要更大的上限
+	MaxSizes map[string]int
+	// MaxViolations 连续多少条格式错误或超限的消息后断开连接（close 1008），不大于 0 时不断开
+	MaxViolations int
+}
+
+// DefaultMessageLimitConfig 返回默认消息大小限制
+func DefaultMessageLimitConfig() MessageLimitConfig {
+	return MessageLimitConfig{
+		ReadLimit:      128 * 1024,
+		DefaultMaxSize: 4 * 1024,
+		MaxSizes: map[string]int{
+			MsgTypeAuth:         64 * 1024,
+			MsgTypeJoinRoom:     64 * 1024,
+			MsgTypePresentation: 64 * 1024,
+			MsgTypeDIDComm:      64 * 1024,
+		},
+		MaxViolations: 5,
+	}
+}
+
+// SetMessageLimitConfig 替换消息大小限制，应在接受连接前调用
+func (s *SimpleServer) SetMessageLimitConfig(config MessageLimitConfig) {
+	s.messageLimits = config
+}
+
+// maxMessageSize 返回该类型消息 data 字段的最大字节数
+func (s *SimpleServer) maxMessageSize(msgType string) int {
+	if limit, ok := s.messageLimits.MaxSizes[msgType]; ok {
+		return limit
+	}
+	return s.messageLimits.DefaultMaxSize
+}
+
+// checkMessageSize 检查消息的 data 字段是否超过该类型的上限
+func (s *SimpleServer) checkMessageSize(msgType string, size int) *ProtocolError {
+	limit := s.maxMessageSize(msgType)
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+	return &ProtocolError{
+		Code:    ErrCodeMessageTooLarge,
+		Message: fmt.Sprintf("%s data is %d bytes, limit is %d", msgType, size, limit),
+		Type:    msgType,
+		Fields:  []FieldError{{Field: "data", Message: fmt.Sprintf("must be at most %d bytes", limit)}},
+		Details: map[string]interface{}{"size": size, "limit": limit},
+	}
+}
+
+// invalidMessageError 返回消息无法解码或缺少 type 字段时的错误，detail 中给出解码错误
+func invalidMessageError(err error) *ProtocolError {
+	protocolErr := &ProtocolError{
+		Code:    ErrCodeInvalidMessage,
+		Message: "message must be an object with a type field",
+	}
+	if err != nil {
+		protocolErr.Details = map[string]interface{}{"detail": err.Error()}
+	} else {
+		protocolErr.Fields = []FieldError{{Field: "type", Message: "is required"}}
+	}
+	return protocolErr
+}
+
+// protocolViolation 错误是否由客户端发送格式错误的消息引起，载荷字段校验失败不计入
+func protocolViolation(code ErrorCode) bool {
+	switch code {
+	case ErrCodeInvalidMessage, ErrCodeMessageTooLarge, ErrCodeUnknownType, ErrCodeInvalidPayload:
+		return true
+	default:
+		return false
+	}
+}
+
+// rejectMessage 回复协议错误，violations 为该连接连续发送格式错误消息的次数，
+// 达到 MaxViolations 时断开连接，返回 false 表示应断开连接
+func (s *SimpleServer) rejectMessage(conn *websocket.Conn, logger *slog.Logger, protocolErr *ProtocolError, violations *int) bool {
+	s.sendProtocolError(conn, protocolErr)
+	if !protocolViolation(protocolErr.Code) {
+		return true
+	}
+
+	*violations++
+	if s.messageLimits.MaxViolations <= 0 || *violations < s.messageLimits.MaxViolations {
+		return true
+	}
+
+	logger.Warn("Disconnecting after repeated invalid messages", "code", protocolErr.Code, "violations", *violations)
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too many invalid messages")
+	writeClose(conn, closeFrame, time.Now().Add(s.heartbeat.WriteWait))
+	return false
+}
@@ -0,0 +1,35 @@
+package game
+
+import "github.com/czh0526/game/server/internal/metrics"
+
+// 游戏服务器指标，通过 /metrics 暴露
+var (
+	connectedPlayersGauge     = metrics.NewGauge("game_connected_players", "Number of open WebSocket connections.")
+	roomsGauge                = metrics.NewGauge("game_rooms", "Number of active game rooms.")
+	messagesTotal             = metrics.NewCounterVec("game_messages_total", "WebSocket messages received by type.", "type")
+	broadcastDuration         = metrics.NewHistogramVec("game_broadcast_duration_seconds", "Time spent fanning out a message to local room players.", nil, "type")
+	broadcastsCoalesced       = metrics.NewCounterVec("game_broadcasts_coalesced_total", "Room broadcasts merged into a queued message of the same type.", "type")
+	websocketErrorsTotal      = metrics.NewCounterVec("game_websocket_errors_total", "Error messages sent to WebSocket clients by code.", "code")
+	sessionResumesTotal       = metrics.NewCounterVec("game_session_resumes_total", "Session resume attempts by result.", "result")
+	guestSessionsTotal        = metrics.NewCounterVec("game_guest_sessions_total", "Guest sessions by event (started, upgraded, expired).", "event")
+	stateResyncsTotal         = metrics.NewCounterVec("game_state_resyncs_total", "Full state snapshots sent to clients by reason.", "reason")
+	tradesTotal               = metrics.NewCounterVec("game_trades_total", "Player trades finished by result.", "result")
+	antiCheatViolationsTotal  = metrics.NewCounterVec("game_anticheat_violations_total", "Anti-cheat violations recorded by type.", "type")
+	antiCheatActionsTotal     = metrics.NewCounterVec("game_anticheat_actions_total", "Anti-cheat actions taken by action.", "action")
+	achievementsUnlockedTotal = metrics.NewCounterVec("game_achievements_unlocked_total", "Achievements unlocked by achievement ID.", "achievement")
+	roomOverflowTotal         = metrics.NewCounterVec("game_room_overflow_total", "Joins above a room's soft cap by result.", "result")
+	inboxMessagesTotal        = metrics.NewCounterVec("game_inbox_messages_total", "Messages put into player inboxes by kind.", "kind")
+	duplicateLoginsTotal      = metrics.NewCounterVec("game_duplicate_logins_total", "Connections affected by logins of an already connected DID by result.", "result")
+	persistenceConflictsTotal = metrics.NewCounterVec("game_persistence_conflicts_total", "Game state writes dropped because another instance changed the record, by record type.", "type")
+	persistenceLeaderGauge    = metrics.NewGauge("game_persistence_leader", "1 if this instance holds the lease for writing full game state snapshots.")
+	outboundQueueFullTotal    = metrics.NewCounterVec("game_websocket_outbound_queue_full_total", "WebSocket connections closed because their outbound queue was full.").With()
+)
+
+// WebSocket 帧压缩指标，压缩率为压缩后（含帧头）与压缩前字节数之比
+var (
+	compressionConnectionsTotal = metrics.NewCounterVec("game_websocket_compression_connections_total", "WebSocket connections that negotiated permessage-deflate.").With()
+	compressionMessagesTotal    = metrics.NewCounterVec("game_websocket_compression_messages_total", "Messages sent on permessage-deflate connections by whether they were compressed or skipped below the threshold.", "result")
+	compressionInputBytesTotal  = metrics.NewCounterVec("game_websocket_compression_input_bytes_total", "Encoded size of compressed messages before compression.").With()
+	compressionOutputBytesTotal = metrics.NewCounterVec("game_websocket_compression_output_bytes_total", "Bytes written for compressed messages, including frame headers.").With()
+	compressionRatio            = metrics.NewHistogramVec("game_websocket_compression_ratio", "Compressed to uncompressed size of each compressed message.", []float64{.05, .1, .2, .3, .5, .75, 1, 1.5}).With()
+)
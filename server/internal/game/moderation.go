@@ -0,0 +1,402 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+const (
+	// moderationStoreName 封禁、禁言和审计日志的存储名称
+	moderationStoreName = "game_moderation"
+
+	recordTypeSanction = "sanction"
+	recordTypeAudit    = "audit"
+)
+
+// SanctionKind 处罚类型
+type SanctionKind string
+
+const (
+	// SanctionBan 封禁：拒绝认证和加入房间
+	SanctionBan SanctionKind = "ban"
+	// SanctionMute 禁言：拒绝发送聊天消息
+	SanctionMute SanctionKind = "mute"
+)
+
+// 审计日志记录的管理操作
+const (
+	AuditBan    = "ban"
+	AuditUnban  = "unban"
+	AuditMute   = "mute"
+	AuditUnmute = "unmute"
+	AuditKick   = "kick"
+)
+
+// Sanction 对玩家的处罚，Until 为空表示永久有效
+type Sanction struct {
+	DID       string       `json:"did"`
+	Kind      SanctionKind `json:"kind"`
+	Reason    string       `json:"reason,omitempty"`
+	Until     *time.Time   `json:"until,omitempty"`
+	Actor     string       `json:"actor"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// Active 处罚在 now 时是否仍然有效
+func (s *Sanction) Active(now time.Time) bool {
+	return s.Until == nil || now.Before(*s.Until)
+}
+
+// message 返回发送给被处罚玩家的提示
+func (s *Sanction) message(catalog *i18n.Catalog, locale string) string {
+	key := "sanction.ban"
+	if s.Kind == SanctionMute {
+		key = "sanction.mute"
+	}
+	params := i18n.Params{}
+	if s.Until != nil {
+		key += "_until"
+		params["until"] = s.Until.Format(time.RFC3339)
+	}
+	message := catalog.Translate(locale, key, params)
+	if s.Reason != "" {
+		message = catalog.Translate(locale, "sanction.reason", i18n.Params{"message": message, "reason": s.Reason})
+	}
+	return message
+}
+
+// AuditEntry 一条管理操作审计记录
+type AuditEntry struct {
+	ID        string     `json:"id"`
+	Action    string     `json:"action"`
+	Target    string     `json:"target"`
+	Actor     string     `json:"actor"`
+	Reason    string     `json:"reason,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// Moderation 玩家处罚和审计日志，处罚在内存中缓存并同步写入存储
+type Moderation struct {
+	store     storage.Store
+	sanctions map[SanctionKind]map[string]Sanction
+	mutex     sync.RWMutex
+}
+
+// NewModeration 创建处罚模块并加载存储中仍然有效的处罚
+func NewModeration(provider storage.Provider) (*Moderation, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(moderationStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open moderation store: %w", err)
+	}
+
+	m := &Moderation{
+		store: store,
+		sanctions: map[SanctionKind]map[string]Sanction{
+			SanctionBan:  make(map[string]Sanction),
+			SanctionMute: make(map[string]Sanction),
+		},
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Moderation) load() error {
+	err := queryRecords(m.store, recordTypeTag, recordTypeSanction, func(data []byte) error {
+		var sanction Sanction
+		if err := json.Unmarshal(data, &sanction); err != nil {
+			return fmt.Errorf("unmarshal sanction: %w", err)
+		}
+
+		if sanctions, ok := m.sanctions[sanction.Kind]; ok {
+			sanctions[sanction.DID] = sanction
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.sweep()
+	return nil
+}
+
+// Apply 记录处罚，覆盖该玩家同类型的已有处罚
+func (m *Moderation) Apply(sanction Sanction) error {
+	data, err := json.Marshal(sanction)
+	if err != nil {
+		return fmt.Errorf("marshal sanction: %w", err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	sanctions, ok := m.sanctions[sanction.Kind]
+	if !ok {
+		return fmt.Errorf("unknown sanction kind: %s", sanction.Kind)
+	}
+	err = m.store.Put(sanctionKey(sanction.Kind, sanction.DID), data,
+		storage.Tag{Name: recordTypeTag, Value: recordTypeSanction})
+	if err != nil {
+		return fmt.Errorf("save sanction: %w", err)
+	}
+
+	sanctions[sanction.DID] = sanction
+	return nil
+}
+
+// Lift 解除处罚，玩家没有该类型的有效处罚时返回 false
+func (m *Moderation) Lift(kind SanctionKind, playerDID string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	sanction, exists := m.sanctions[kind][playerDID]
+	if !exists {
+		return false, nil
+	}
+	if err := m.store.Delete(sanctionKey(kind, playerDID)); err != nil {
+		return false, fmt.Errorf("delete sanction: %w", err)
+	}
+
+	delete(m.sanctions[kind], playerDID)
+	return sanction.Active(time.Now()), nil
+}
+
+// Active 返回玩家当前有效的处罚
+func (m *Moderation) Active(kind SanctionKind, playerDID string) (Sanction, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	sanction, exists := m.sanctions[kind][playerDID]
+	if !exists || !sanction.Active(time.Now()) {
+		return Sanction{}, false
+	}
+	return sanction, true
+}
+
+// List 返回某类型仍然有效的处罚，按创建时间排序
+func (m *Moderation) List(kind SanctionKind) []Sanction {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	now := time.Now()
+	sanctions := make([]Sanction, 0, len(m.sanctions[kind]))
+	for _, sanction := range m.sanctions[kind] {
+		if sanction.Active(now) {
+			sanctions = append(sanctions, sanction)
+		}
+	}
+	sort.Slice(sanctions, func(i, j int) bool { return sanctions[i].CreatedAt.Before(sanctions[j].CreatedAt) })
+	return sanctions
+}
+
+// Record 写入一条审计记录
+func (m *Moderation) Record(entry AuditEntry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+
+	err = m.store.Put(auditKey(entry), data, storage.Tag{Name: recordTypeTag, Value: recordTypeAudit})
+	if err != nil {
+		return fmt.Errorf("save audit entry: %w", err)
+	}
+	return nil
+}
+
+// AuditLog 返回最近的审计记录，从新到旧，limit 不大于 0 时返回全部
+func (m *Moderation) AuditLog(target string, limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	err := queryRecords(m.store, recordTypeTag, recordTypeAudit, func(data []byte) error {
+		var entry AuditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("unmarshal audit entry: %w", err)
+		}
+		if target == "" || entry.Target == target {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Timestamp.After(entries[j].Timestamp) })
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// sweep 清理已过期的处罚
+func (m *Moderation) sweep() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for kind, sanctions := range m.sanctions {
+		for playerDID, sanction := range sanctions {
+			if sanction.Active(now) {
+				continue
+			}
+			if err := m.store.Delete(sanctionKey(kind, playerDID)); err != nil {
+				slog.Error("Failed to delete expired sanction", "kind", kind, logging.KeyPlayerDID, playerDID, logging.Err(err))
+				continue
+			}
+			delete(sanctions, playerDID)
+		}
+	}
+}
+
+func sanctionKey(kind SanctionKind, playerDID string) string {
+	return "sanction:" + string(kind) + ":" + playerDID
+}
+
+// auditKey 以时间戳开头，便于在存储中按时间排查
+func auditKey(entry AuditEntry) string {
+	return fmt.Sprintf("audit:%020d:%s", entry.Timestamp.UnixNano(), entry.ID)
+}
+
+// queryRecords 遍历存储中带有标签 tagName:tagValue 的所有记录
+func queryRecords(store storage.Store, tagName, tagValue string, decode func(data []byte) error) error {
+	iter, err := store.Query(fmt.Sprintf("%s:%s", tagName, tagValue))
+	if err != nil {
+		return fmt.Errorf("query %s records: %w", tagValue, err)
+	}
+	defer iter.Close()
+
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate %s records: %w", tagValue, err)
+		}
+		if !more {
+			return nil
+		}
+
+		value, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read %s record: %w", tagValue, err)
+		}
+
+		if err := decode(value); err != nil {
+			return err
+		}
+	}
+}
+
+// SetModeration 使用持久化的处罚模块替换默认的内存实现，应在接受连接前调用
+func (s *SimpleServer) SetModeration(moderation *Moderation) {
+	s.moderation = moderation
+}
+
+// BanPlayer 封禁玩家并断开其连接，duration 为 0 表示永久封禁
+func (s *SimpleServer) BanPlayer(playerDID, reason string, duration time.Duration, actor string) (Sanction, error) {
+	sanction, err := s.sanction(SanctionBan, playerDID, reason, duration, actor)
+	if err != nil {
+		return Sanction{}, err
+	}
+
+	if err := s.disconnectPlayer(playerDID, ErrCodeBanned, sanction.message(s.catalog, s.findPlayerByDID(playerDID).locale())); err != nil && err != ErrPlayerNotConnected {
+		slog.Error("Failed to disconnect banned player", logging.KeyPlayerDID, playerDID, logging.Err(err))
+	}
+	return sanction, nil
+}
+
+// MutePlayer 禁止玩家发送聊天消息，duration 为 0 表示永久禁言
+func (s *SimpleServer) MutePlayer(playerDID, reason string, duration time.Duration, actor string) (Sanction, error) {
+	sanction, err := s.sanction(SanctionMute, playerDID, reason, duration, actor)
+	if err != nil {
+		return Sanction{}, err
+	}
+
+	if player := s.findPlayerByDID(playerDID); player != nil {
+		s.sendErrorToPlayer(player, ErrCodeMuted, sanction.message(s.catalog, player.Locale))
+	}
+	return sanction, nil
+}
+
+// UnbanPlayer 解除封禁，玩家未被封禁时返回 false
+func (s *SimpleServer) UnbanPlayer(playerDID, actor string) (bool, error) {
+	return s.liftSanction(SanctionBan, AuditUnban, playerDID, actor)
+}
+
+// UnmutePlayer 解除禁言，玩家未被禁言时返回 false
+func (s *SimpleServer) UnmutePlayer(playerDID, actor string) (bool, error) {
+	return s.liftSanction(SanctionMute, AuditUnmute, playerDID, actor)
+}
+
+// Sanctions 返回某类型仍然有效的处罚
+func (s *SimpleServer) Sanctions(kind SanctionKind) []Sanction {
+	return s.moderation.List(kind)
+}
+
+// AuditLog 返回最近的管理操作记录，target 为空时返回所有玩家的记录
+func (s *SimpleServer) AuditLog(target string, limit int) ([]AuditEntry, error) {
+	return s.moderation.AuditLog(target, limit)
+}
+
+func (s *SimpleServer) sanction(kind SanctionKind, playerDID, reason string, duration time.Duration, actor string) (Sanction, error) {
+	now := time.Now()
+	sanction := Sanction{
+		DID:       playerDID,
+		Kind:      kind,
+		Reason:    reason,
+		Actor:     actor,
+		CreatedAt: now,
+	}
+	if duration > 0 {
+		until := now.Add(duration)
+		sanction.Until = &until
+	}
+
+	if err := s.moderation.Apply(sanction); err != nil {
+		return Sanction{}, err
+	}
+
+	s.audit(AuditEntry{Action: string(kind), Target: playerDID, Actor: actor, Reason: reason, Until: sanction.Until})
+	slog.Info("Applied sanction", "kind", kind, logging.KeyPlayerDID, playerDID, "actor", actor)
+	return sanction, nil
+}
+
+func (s *SimpleServer) liftSanction(kind SanctionKind, action, playerDID, actor string) (bool, error) {
+	lifted, err := s.moderation.Lift(kind, playerDID)
+	if err != nil || !lifted {
+		return false, err
+	}
+
+	s.audit(AuditEntry{Action: action, Target: playerDID, Actor: actor})
+	slog.Info("Lifted sanction", "kind", kind, logging.KeyPlayerDID, playerDID, "actor", actor)
+	return true, nil
+}
+
+// audit 写入审计日志，失败时只记录日志，不影响已执行的操作
+func (s *SimpleServer) audit(entry AuditEntry) {
+	if err := s.moderation.Record(entry); err != nil {
+		slog.Error("Failed to record audit entry", "action", entry.Action, "target", entry.Target, logging.Err(err))
+	}
+}
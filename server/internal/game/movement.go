@@ -0,0 +1,224 @@
+package game
+
+import (
+	"math"
+	"time"
+)
+
+// 地图瓦片类型
+const (
+	TileEmpty = 0
+	TileWall  = 1
+)
+
+// MovementConfig 服务器端移动校验配置
+type MovementConfig struct {
+	MaxSpeed      float64       // 最大移动速度（像素/秒）
+	MinInterval   time.Duration // 计算位移上限时的最小时间间隔，即一个 tick
+	MaxInterval   time.Duration // 计算位移上限时的最大时间间隔，避免长时间静止后瞬移
+	Tolerance     float64       // 允许的网络抖动误差（像素）
+	PlayerRadius  float64       // 玩家碰撞半径（像素）
+	InteractRange float64       // 玩家碰撞边缘到可交互物体的最大距离（像素）
+}
+
+// DefaultMovementConfig 返回默认移动校验配置
+func DefaultMovementConfig() MovementConfig {
+	return MovementConfig{
+		MaxSpeed:      200,
+		MinInterval:   50 * time.Millisecond,
+		MaxInterval:   time.Second,
+		Tolerance:     5,
+		PlayerRadius:  8,
+		InteractRange: 24,
+	}
+}
+
+// moveResult 移动校验结果
+type moveResult struct {
+	Position  Position
+	Corrected bool
+	Reason    string
+	// Excess 超速时请求的移动距离与允许距离之比
+	Excess float64
+}
+
+// tileSize 返回瓦片边长，瓦片网格按地图高度均分
+func (m *GameMap) tileSize() float64 {
+	if len(m.Tiles) == 0 {
+		return 0
+	}
+	return float64(m.Height) / float64(len(m.Tiles))
+}
+
+// inBounds 判断位置是否在地图范围内
+func (m *GameMap) inBounds(pos Position, radius float64) bool {
+	return pos.X-radius >= 0 && pos.Y-radius >= 0 &&
+		pos.X+radius <= float64(m.Width) && pos.Y+radius <= float64(m.Height)
+}
+
+// clamp 将位置限制在地图范围内
+func (m *GameMap) clamp(pos Position, radius float64) Position {
+	pos.X = math.Max(radius, math.Min(pos.X, float64(m.Width)-radius))
+	pos.Y = math.Max(radius, math.Min(pos.Y, float64(m.Height)-radius))
+	return pos
+}
+
+// blocked 判断位置是否与墙体瓦片或阻挡物体重叠
+func (m *GameMap) blocked(pos Position, radius float64) bool {
+	if m.wallIn(pos.X-radius, pos.Y-radius, pos.X+radius, pos.Y+radius) {
+		return true
+	}
+
+	for _, object := range m.FindObjectsNear(pos, radius) {
+		if !object.isSolid() {
+			continue
+		}
+		if pos.X+radius > object.Position.X && pos.X-radius < object.Position.X+float64(object.Width) &&
+			pos.Y+radius > object.Position.Y && pos.Y-radius < object.Position.Y+float64(object.Height) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sweepBlocked 判断玩家从 from 直线移动到 to 的途中是否进入墙体瓦片或阻挡物体。
+// 障碍按玩家半径向外扩展后与移动线段求交，只检查终点时一次足够长的移动可以越过薄墙；起点已与之重叠的障碍不阻挡离开
+func (m *GameMap) sweepBlocked(from, to Position, radius float64) bool {
+	minX, maxX := math.Min(from.X, to.X)-radius, math.Max(from.X, to.X)+radius
+	minY, maxY := math.Min(from.Y, to.Y)-radius, math.Max(from.Y, to.Y)+radius
+
+	if size := m.tileSize(); size > 0 {
+		for row := int(minY / size); row <= int(maxY/size); row++ {
+			if row < 0 || row >= len(m.Tiles) {
+				continue
+			}
+			for col := int(minX / size); col <= int(maxX/size); col++ {
+				if col < 0 || col >= len(m.Tiles[row]) || m.Tiles[row][col] != TileWall {
+					continue
+				}
+				tileX, tileY := float64(col)*size, float64(row)*size
+				if segmentEntersRect(from, to, tileX-radius, tileY-radius, tileX+size+radius, tileY+size+radius) {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, object := range m.objects().query(minX, minY, maxX, maxY) {
+		if !object.isSolid() {
+			continue
+		}
+		if segmentEntersRect(from, to, object.Position.X-radius, object.Position.Y-radius,
+			object.Position.X+float64(object.Width)+radius, object.Position.Y+float64(object.Height)+radius) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentEntersRect 判断线段 from→to 是否进入矩形内部（只擦过边界不算），起点已在内部时返回 false
+func segmentEntersRect(from, to Position, minX, minY, maxX, maxY float64) bool {
+	enter, exit := math.Inf(-1), math.Inf(1)
+	for _, axis := range [2][4]float64{
+		{from.X, to.X - from.X, minX, maxX},
+		{from.Y, to.Y - from.Y, minY, maxY},
+	} {
+		p, d, lo, hi := axis[0], axis[1], axis[2], axis[3]
+		if d == 0 {
+			if p <= lo || p >= hi {
+				return false
+			}
+			continue
+		}
+		t1, t2 := (lo-p)/d, (hi-p)/d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		enter = math.Max(enter, t1)
+		exit = math.Min(exit, t2)
+	}
+	return enter < exit && enter >= 0 && enter <= 1
+}
+
+// wallIn 判断矩形区域是否与墙体瓦片重叠
+func (m *GameMap) wallIn(minX, minY, maxX, maxY float64) bool {
+	size := m.tileSize()
+	if size <= 0 {
+		return false
+	}
+
+	minRow := int(minY / size)
+	maxRow := int(maxY / size)
+	minCol := int(minX / size)
+	maxCol := int(maxX / size)
+
+	for row := minRow; row <= maxRow; row++ {
+		if row < 0 || row >= len(m.Tiles) {
+			continue
+		}
+		for col := minCol; col <= maxCol; col++ {
+			if col < 0 || col >= len(m.Tiles[row]) {
+				continue
+			}
+			if m.Tiles[row][col] == TileWall {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSolid 判断物体是否阻挡移动
+func (o *MapObject) isSolid() bool {
+	if o.Width <= 0 || o.Height <= 0 {
+		return false
+	}
+	if solid, ok := o.Properties["solid"].(bool); ok {
+		return solid
+	}
+	return o.Type == "wall" || o.Type == "obstacle" || o.Type == ObjectDoor
+}
+
+// validateMove 校验玩家从 from 移动到 to 是否合法，返回服务器认可的位置
+func validateMove(gameMap *GameMap, from, to Position, elapsed time.Duration, config MovementConfig) moveResult {
+	if math.IsNaN(to.X) || math.IsNaN(to.Y) || math.IsInf(to.X, 0) || math.IsInf(to.Y, 0) {
+		return moveResult{Position: from, Corrected: true, Reason: "invalid coordinates"}
+	}
+
+	result := moveResult{Position: to}
+
+	// 越界时限制在地图内
+	if !gameMap.inBounds(result.Position, config.PlayerRadius) {
+		result.Position = gameMap.clamp(result.Position, config.PlayerRadius)
+		result.Corrected = true
+		result.Reason = "out of bounds"
+	}
+
+	// 超过最大速度时沿移动方向截断
+	if elapsed < config.MinInterval {
+		elapsed = config.MinInterval
+	}
+	if elapsed > config.MaxInterval {
+		elapsed = config.MaxInterval
+	}
+	maxDistance := config.MaxSpeed*elapsed.Seconds() + config.Tolerance
+
+	dx := result.Position.X - from.X
+	dy := result.Position.Y - from.Y
+	distance := math.Hypot(dx, dy)
+	if distance > maxDistance {
+		scale := maxDistance / distance
+		result.Excess = distance / maxDistance
+		result.Position = Position{X: from.X + dx*scale, Y: from.Y + dy*scale}
+		result.Corrected = true
+		result.Reason = "speed limit exceeded"
+	}
+
+	// 沿移动路径检测碰撞，途中穿过或终点落在墙体、阻挡物体内都拒绝本次移动
+	if gameMap.sweepBlocked(from, result.Position, config.PlayerRadius) || gameMap.blocked(result.Position, config.PlayerRadius) {
+		return moveResult{Position: from, Corrected: true, Reason: "collision", Excess: result.Excess}
+	}
+
+	return result
+}
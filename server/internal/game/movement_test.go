@@ -0,0 +1,82 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestMap 返回 200x200、瓦片边长 20 的空地图，第 5 列（x 100-120）为一堵竖墙
+func newTestMap() *GameMap {
+	tiles := make([][]int, 10)
+	for row := range tiles {
+		tiles[row] = make([]int, 10)
+		tiles[row][5] = TileWall
+	}
+	return &GameMap{Width: 200, Height: 200, Tiles: tiles}
+}
+
+func TestValidateMoveRejectsTunnelling(t *testing.T) {
+	gameMap := newTestMap()
+	config := DefaultMovementConfig()
+	from := Position{X: 60, Y: 50}
+
+	// 终点在墙的另一侧且不与墙重叠，只检查终点时这次移动会穿墙
+	to := Position{X: 160, Y: 50}
+	if gameMap.blocked(to, config.PlayerRadius) {
+		t.Fatal("destination should be clear of the wall")
+	}
+	result := validateMove(gameMap, from, to, time.Second, config)
+	if !result.Corrected || result.Reason != "collision" || result.Position != from {
+		t.Fatalf("move through the wall = %+v", result)
+	}
+
+	// 同侧的移动不受影响
+	to = Position{X: 85, Y: 150}
+	if result := validateMove(gameMap, from, to, time.Second, config); result.Corrected || result.Position != to {
+		t.Errorf("clear move corrected: %+v", result)
+	}
+}
+
+func TestValidateMoveRejectsTunnellingThroughObjects(t *testing.T) {
+	gameMap := &GameMap{
+		Width:  200,
+		Height: 200,
+		Objects: []*MapObject{
+			{ID: "crate", Type: "obstacle", Position: Position{X: 100, Y: 40}, Width: 4, Height: 40},
+			{ID: "rug", Type: "decoration", Position: Position{X: 100, Y: 120}, Width: 4, Height: 40},
+		},
+	}
+	config := DefaultMovementConfig()
+
+	blocked := validateMove(gameMap, Position{X: 60, Y: 60}, Position{X: 150, Y: 60}, time.Second, config)
+	if blocked.Reason != "collision" {
+		t.Errorf("move through a solid object = %+v", blocked)
+	}
+	passable := validateMove(gameMap, Position{X: 60, Y: 140}, Position{X: 150, Y: 140}, time.Second, config)
+	if passable.Corrected {
+		t.Errorf("move over a non-solid object corrected: %+v", passable)
+	}
+}
+
+func TestSweepBlocked(t *testing.T) {
+	gameMap := newTestMap()
+	const radius = 8
+
+	tests := []struct {
+		name     string
+		from, to Position
+		want     bool
+	}{
+		{"crosses the wall", Position{X: 60, Y: 50}, Position{X: 160, Y: 50}, true},
+		{"diagonal across the wall", Position{X: 80, Y: 20}, Position{X: 140, Y: 180}, true},
+		{"stops short of the wall", Position{X: 60, Y: 50}, Position{X: 90, Y: 50}, false},
+		{"slides along the wall", Position{X: 92, Y: 20}, Position{X: 92, Y: 180}, false},
+		{"leaves a wall it overlaps", Position{X: 95, Y: 50}, Position{X: 60, Y: 50}, false},
+		{"stands still", Position{X: 60, Y: 50}, Position{X: 60, Y: 50}, false},
+	}
+	for _, tt := range tests {
+		if got := gameMap.sweepBlocked(tt.from, tt.to, radius); got != tt.want {
+			t.Errorf("%s: sweepBlocked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// DamageNPC NPC 攻击造成的伤害来源
+const DamageNPC = "npc"
+
+// NPC 行为，地图上的 npc 物体设置 behavior 属性时由服务器控制
+const (
+	BehaviorPatrol   = "patrol"   // 沿 waypoints 属性中的路径点巡逻，玩家靠近时停下对话
+	BehaviorGuard    = "guard"    // 追击 aggroRange 属性范围内的玩家，丢失目标后回到原位
+	BehaviorDialogue = "dialogue" // 原地不动，只用于对话
+)
+
+// DefaultNPCSpeed NPC 默认移动速度（像素/秒）
+const DefaultNPCSpeed = 80
+
+// npcTalkRange 玩家进入该距离时巡逻的 NPC 停下对话
+const npcTalkRange = 48
+
+// ErrNPCExists 房间中已有相同 ID 的 NPC
+var ErrNPCExists = errors.New("npc already exists")
+
+// BehaviorStatus 行为树节点的执行结果
+type BehaviorStatus int
+
+const (
+	BehaviorSuccess BehaviorStatus = iota
+	BehaviorFailure
+	BehaviorRunning
+)
+
+// Behavior 行为树节点，房间模拟循环每个 tick 对每个 NPC 执行一次根节点。在持有 room.mutex 时调用
+type Behavior interface {
+	Tick(ctx *BehaviorContext) BehaviorStatus
+}
+
+// BehaviorFunc 将函数用作行为树节点
+type BehaviorFunc func(ctx *BehaviorContext) BehaviorStatus
+
+// Tick 执行函数
+func (f BehaviorFunc) Tick(ctx *BehaviorContext) BehaviorStatus {
+	return f(ctx)
+}
+
+// Selector 依次执行子节点，返回第一个没有失败的子节点的结果，全部失败时失败
+type Selector []Behavior
+
+// Tick 执行子节点
+func (s Selector) Tick(ctx *BehaviorContext) BehaviorStatus {
+	for _, child := range s {
+		if status := child.Tick(ctx); status != BehaviorFailure {
+			return status
+		}
+	}
+	return BehaviorFailure
+}
+
+// Sequence 依次执行子节点，返回第一个没有成功的子节点的结果，全部成功时成功
+type Sequence []Behavior
+
+// Tick 执行子节点
+func (s Sequence) Tick(ctx *BehaviorContext) BehaviorStatus {
+	for _, child := range s {
+		if status := child.Tick(ctx); status != BehaviorSuccess {
+			return status
+		}
+	}
+	return BehaviorSuccess
+}
+
+// Patrol 沿路径点循环移动，没有路径点时失败
+type Patrol struct {
+	Waypoints []Position
+}
+
+// Tick 向当前路径点移动，到达后切换到下一个路径点
+func (p *Patrol) Tick(ctx *BehaviorContext) BehaviorStatus {
+	if len(p.Waypoints) == 0 {
+		return BehaviorFailure
+	}
+	npc := ctx.NPC
+	npc.waypoint %= len(p.Waypoints)
+	if ctx.MoveToward(p.Waypoints[npc.waypoint]) {
+		npc.waypoint = (npc.waypoint + 1) % len(p.Waypoints)
+	}
+	return BehaviorRunning
+}
+
+// Chase 追击 Range 内最近的玩家，贴近时按战斗配置的攻击距离和冷却造成 Damage 点伤害；
+// 目标死亡、离开房间或超出 GiveUpRange 后放弃，没有目标时失败
+type Chase struct {
+	Range       float64
+	GiveUpRange float64
+	Damage      int
+}
+
+// Tick 追击或攻击目标
+func (c *Chase) Tick(ctx *BehaviorContext) BehaviorStatus {
+	npc := ctx.NPC
+	target := ctx.Room.Players[npc.target]
+	if target == nil || target.dead() || ctx.Distance(target.Position) > math.Max(c.GiveUpRange, c.Range) {
+		target = ctx.NearestPlayer(c.Range)
+	}
+	if target == nil {
+		npc.target = ""
+		return BehaviorFailure
+	}
+	npc.target = target.ID
+
+	if ctx.Distance(target.Position) <= ctx.server.combat.AttackRange+ctx.server.movement.PlayerRadius {
+		ctx.Attack(target, c.Damage)
+	} else {
+		ctx.MoveToward(target.Position)
+	}
+	return BehaviorRunning
+}
+
+// Converse 有玩家在 Range 内时原地停下等待对话，否则失败
+type Converse struct {
+	Range float64
+}
+
+// Tick 检查附近是否有玩家
+func (c *Converse) Tick(ctx *BehaviorContext) BehaviorStatus {
+	if ctx.NearestPlayer(c.Range) == nil {
+		return BehaviorFailure
+	}
+	return BehaviorRunning
+}
+
+// NPC 服务器控制的实体。NPC 以 npc 类型的地图物体出现在房间中，与玩家一样可以交互，
+// 移动随 physics 消息广播
+type NPC struct {
+	Object   *MapObject
+	Behavior Behavior
+	Speed    float64 // 移动速度（像素/秒），0 时使用 DefaultNPCSpeed
+
+	waypoint     int
+	target       string
+	lastAttackAt time.Time
+}
+
+// BehaviorContext 行为树节点执行时的上下文
+type BehaviorContext struct {
+	Room  *GameRoom
+	NPC   *NPC
+	Now   time.Time
+	Delta time.Duration
+
+	server *SimpleServer
+	moved  bool
+	combat []*CombatEvent
+}
+
+// Distance 返回 NPC 到指定位置的距离
+func (ctx *BehaviorContext) Distance(pos Position) float64 {
+	self := ctx.NPC.Object.Position
+	return math.Hypot(pos.X-self.X, pos.Y-self.Y)
+}
+
+// NearestPlayer 返回 radius 内最近的存活玩家，距离相同时按 ID 选择，没有时返回 nil
+func (ctx *BehaviorContext) NearestPlayer(radius float64) *Player {
+	var nearest *Player
+	best := math.Inf(1)
+	for _, player := range ctx.Room.Players {
+		if player.dead() {
+			continue
+		}
+		distance := ctx.Distance(player.Position)
+		if distance > radius {
+			continue
+		}
+		if distance < best || (distance == best && player.ID < nearest.ID) {
+			nearest, best = player, distance
+		}
+	}
+	return nearest
+}
+
+// MoveToward 以 NPC 的速度向目标移动一个 tick，被阻挡时沿未阻挡的轴滑动。到达目标时返回 true
+func (ctx *BehaviorContext) MoveToward(target Position) bool {
+	object := ctx.NPC.Object
+	speed := ctx.NPC.Speed
+	if speed <= 0 {
+		speed = DefaultNPCSpeed
+	}
+
+	dx, dy := target.X-object.Position.X, target.Y-object.Position.Y
+	distance := math.Hypot(dx, dy)
+	step := speed * ctx.Delta.Seconds()
+	if distance <= step {
+		// 目标被阻挡时也视为到达，避免巡逻卡在无法到达的路径点
+		ctx.moveTo(target)
+		return true
+	}
+
+	next := Position{X: object.Position.X + dx/distance*step, Y: object.Position.Y + dy/distance*step}
+	if !ctx.moveTo(next) && !ctx.moveTo(Position{X: next.X, Y: object.Position.Y}) {
+		ctx.moveTo(Position{X: object.Position.X, Y: next.Y})
+	}
+	return false
+}
+
+// moveTo 在目标位置没有阻挡时移动 NPC
+func (ctx *BehaviorContext) moveTo(pos Position) bool {
+	object := ctx.NPC.Object
+	gameMap := ctx.Room.GameState.Map
+	if pos == object.Position {
+		return true
+	}
+
+	minX, minY := pos.X, pos.Y
+	maxX, maxY := pos.X+float64(object.Width), pos.Y+float64(object.Height)
+	if minX < 0 || minY < 0 || maxX > float64(gameMap.Width) || maxY > float64(gameMap.Height) ||
+		gameMap.solidIn(minX, minY, maxX, maxY, object) {
+		return false
+	}
+
+	object.Position = pos
+	ctx.moved = true
+	return true
+}
+
+// Attack 按战斗配置的冷却时间攻击玩家
+func (ctx *BehaviorContext) Attack(target *Player, damage int) {
+	npc := ctx.NPC
+	if ctx.Now.Sub(npc.lastAttackAt) < ctx.server.combat.AttackCooldown {
+		return
+	}
+	npc.lastAttackAt = ctx.Now
+
+	events := ctx.server.applyDamage(ctx.Room, nil, target, damage, DamageNPC, ctx.Now)
+	for _, event := range events {
+		event.AttackerID = npc.Object.ID
+	}
+	ctx.combat = append(ctx.combat, events...)
+}
+
+// SpawnNPC 在房间中加入 NPC，NPC 的物体不在地图上时加入地图，未设置 ID 时自动生成。
+// 调用方需持有 room.mutex，游戏模式可以在回调中直接调用
+func (r *GameRoom) SpawnNPC(npc *NPC) error {
+	if npc.Object == nil || npc.Behavior == nil {
+		return errors.New("npc object and behavior are required")
+	}
+	object := npc.Object
+	if object.ID == "" {
+		object.ID = uuid.New().String()
+	}
+	if object.Type == "" {
+		object.Type = ObjectNPC
+	}
+	for _, existing := range r.npcs {
+		if existing.Object.ID == object.ID {
+			return fmt.Errorf("%w: %s", ErrNPCExists, object.ID)
+		}
+	}
+
+	onMap := false
+	for _, candidate := range r.GameState.Map.Objects {
+		if candidate == object {
+			onMap = true
+			break
+		}
+	}
+	if !onMap {
+		r.GameState.Map.Objects = append(r.GameState.Map.Objects, object)
+	}
+	r.npcs = append(r.npcs, npc)
+	return nil
+}
+
+// RemoveNPC 从房间和地图中移除 NPC，调用方需持有 room.mutex
+func (r *GameRoom) RemoveNPC(objectID string) bool {
+	for i, npc := range r.npcs {
+		if npc.Object.ID != objectID {
+			continue
+		}
+		r.npcs = append(r.npcs[:i], r.npcs[i+1:]...)
+		objects := r.GameState.Map.Objects
+		for j, object := range objects {
+			if object == npc.Object {
+				r.GameState.Map.Objects = append(objects[:j], objects[j+1:]...)
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// spawnMapNPCs 为地图上设置了 behavior 属性的 npc 物体创建 NPC，调用方需持有 room.mutex
+func (r *GameRoom) spawnMapNPCs() {
+	for _, object := range r.GameState.Map.Objects {
+		if object.Type != ObjectNPC {
+			continue
+		}
+		npc := npcFromObject(object)
+		if npc == nil {
+			continue
+		}
+		if err := r.SpawnNPC(npc); err != nil {
+			r.log().Warn("Failed to spawn map NPC", "object_id", object.ID, logging.Err(err))
+		}
+	}
+}
+
+// npcFromObject 按物体属性创建 NPC：behavior 选择行为，speed 设置速度，patrol 的 waypoints 为路径点，
+// guard 的 aggroRange 和 damage 设置追击范围和伤害。没有 behavior 属性时返回 nil
+func npcFromObject(object *MapObject) *NPC {
+	behavior, _ := object.Properties["behavior"].(string)
+	npc := &NPC{Object: object, Speed: numberProperty(object.Properties, "speed", DefaultNPCSpeed)}
+	home := object.Position
+
+	switch behavior {
+	case BehaviorPatrol:
+		npc.Behavior = Selector{
+			&Converse{Range: npcTalkRange},
+			&Patrol{Waypoints: waypointsProperty(object.Properties["waypoints"])},
+		}
+	case BehaviorGuard:
+		aggroRange := numberProperty(object.Properties, "aggroRange", 150)
+		npc.Behavior = Selector{
+			&Chase{
+				Range:       aggroRange,
+				GiveUpRange: aggroRange * 2,
+				Damage:      int(numberProperty(object.Properties, "damage", 5)),
+			},
+			&Patrol{Waypoints: []Position{home}},
+		}
+	case BehaviorDialogue:
+		npc.Behavior = BehaviorFunc(func(*BehaviorContext) BehaviorStatus { return BehaviorRunning })
+	default:
+		return nil
+	}
+	return npc
+}
+
+// numberProperty 读取数值属性，属性可能来自 Go 代码或 JSON
+func numberProperty(properties map[string]interface{}, key string, fallback float64) float64 {
+	switch value := properties[key].(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	default:
+		return fallback
+	}
+}
+
+// waypointsProperty 读取路径点，支持 []Position 和 JSON 解码得到的 [{"x": 1, "y": 2}]
+func waypointsProperty(value interface{}) []Position {
+	switch points := value.(type) {
+	case []Position:
+		return points
+	case []interface{}:
+		waypoints := make([]Position, 0, len(points))
+		for _, point := range points {
+			fields, ok := point.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			waypoints = append(waypoints, Position{
+				X: numberProperty(fields, "x", 0),
+				Y: numberProperty(fields, "y", 0),
+			})
+		}
+		return waypoints
+	default:
+		return nil
+	}
+}
+
+// tickNPCs 执行房间中所有 NPC 的行为树，返回移动的 NPC 位置和 NPC 攻击产生的战斗事件。调用方需持有 room.mutex
+func (s *SimpleServer) tickNPCs(room *GameRoom, now time.Time) (map[string]Position, []*CombatEvent) {
+	if len(room.npcs) == 0 {
+		return nil, nil
+	}
+
+	moved := make(map[string]Position)
+	var combat []*CombatEvent
+	for _, npc := range room.npcs {
+		ctx := &BehaviorContext{Room: room, NPC: npc, Now: now, Delta: s.tickInterval(), server: s}
+		npc.Behavior.Tick(ctx)
+		if ctx.moved {
+			moved[npc.Object.ID] = npc.Object.Position
+		}
+		combat = append(combat, ctx.combat...)
+	}
+
+	if len(moved) > 0 {
+		room.GameState.Map.ReindexObjects()
+	}
+	return moved, combat
+}
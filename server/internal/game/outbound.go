@@ -0,0 +1,165 @@
+package game
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// outboundQueueSize 每个连接待发送帧的队列长度。队列满说明客户端读取过慢，断开连接而不是阻塞发送方
+const outboundQueueSize = 256
+
+// 发送失败的原因
+var (
+	errConnectionClosed = errors.New("connection closed")
+	errSlowConsumer     = errors.New("outbound queue is full")
+)
+
+// outboundFrame 待发送的一帧。CloseMessage 帧以 WriteControl 发送，written 非空时写入结果
+type outboundFrame struct {
+	messageType int
+	data        []byte
+	written     chan error
+}
+
+// connWriter 连接唯一的写入者。gorilla/websocket 同一时间只允许一个写操作，而房间广播的合并定时器、
+// 房间 tick 循环、匹配器和各消息处理都会向玩家发送消息，它们把帧放入队列，由写协程按顺序发送。
+// ping 以 WriteControl 发送，可与写协程并发，不经过队列
+type connWriter struct {
+	conn      *websocket.Conn
+	writeWait time.Duration
+	queue     chan outboundFrame
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// writers 已启动写协程的连接 -> connWriter
+var writers sync.Map
+
+// startWriter 为连接启动写协程，之后经 writeMessage 和 writeClose 发送的帧都由它写出。
+// 返回的函数在连接处理结束时调用，发送已入队的帧后停止写协程
+func startWriter(conn *websocket.Conn, writeWait time.Duration) func() {
+	w := &connWriter{
+		conn:      conn,
+		writeWait: writeWait,
+		queue:     make(chan outboundFrame, outboundQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	writers.Store(conn, w)
+	go w.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			writers.Delete(conn)
+			close(w.stop)
+			<-w.done
+		})
+	}
+}
+
+func (w *connWriter) run() {
+	defer func() {
+		close(w.done)
+		// 写协程退出后仍在队列中的帧不再发送，等待结果的发送方立即返回
+		for {
+			select {
+			case frame := <-w.queue:
+				frame.finish(errConnectionClosed)
+			default:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case frame := <-w.queue:
+			if err := w.write(frame); err != nil {
+				slog.Debug("WebSocket write failed", "error", err)
+				// 关闭连接使读循环退出，由连接处理完成断线清理
+				w.conn.Close()
+				return
+			}
+		case <-w.stop:
+			for {
+				select {
+				case frame := <-w.queue:
+					if err := w.write(frame); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *connWriter) write(frame outboundFrame) error {
+	deadline := time.Now().Add(w.writeWait)
+	var err error
+	if frame.messageType == websocket.CloseMessage {
+		err = w.conn.WriteControl(websocket.CloseMessage, frame.data, deadline)
+	} else {
+		w.conn.SetWriteDeadline(deadline)
+		err = writeCompressed(w.conn, frame.messageType, frame.data)
+	}
+	frame.finish(err)
+	return err
+}
+
+func (f outboundFrame) finish(err error) {
+	if f.written != nil {
+		f.written <- err
+	}
+}
+
+// enqueue 将帧放入连接的发送队列。连接处理结束后写协程已注销，不再接受新的帧
+func enqueue(conn *websocket.Conn, frame outboundFrame) error {
+	value, ok := writers.Load(conn)
+	if !ok {
+		return errConnectionClosed
+	}
+	w := value.(*connWriter)
+
+	select {
+	case <-w.done:
+		return errConnectionClosed
+	default:
+	}
+	select {
+	case w.queue <- frame:
+		return nil
+	default:
+		outboundQueueFullTotal.Inc()
+		slog.Warn("Closing WebSocket connection with a full outbound queue", "queued", outboundQueueSize, "remote_addr", conn.RemoteAddr().String())
+		conn.Close()
+		return errSlowConsumer
+	}
+}
+
+// writeClose 在已入队的消息之后发送 close 帧，等待写出或到达 deadline。之后可以安全地关闭连接
+func writeClose(conn *websocket.Conn, closeFrame []byte, deadline time.Time) error {
+	// WriteControl 可与其他写操作并发，没有写协程时直接发送
+	if _, ok := writers.Load(conn); !ok {
+		return conn.WriteControl(websocket.CloseMessage, closeFrame, deadline)
+	}
+
+	written := make(chan error, 1)
+	if err := enqueue(conn, outboundFrame{messageType: websocket.CloseMessage, data: closeFrame, written: written}); err != nil {
+		return err
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case err := <-written:
+		return err
+	case <-timer.C:
+		return errors.New("close frame not written before the deadline")
+	}
+}
@@ -0,0 +1,108 @@
+package game
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newWriterPair 返回已启动写协程的服务端连接、对应的客户端连接和写协程的停止函数
+func newWriterPair(t *testing.T) (*websocket.Conn, *websocket.Conn, func()) {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	conn := <-accepted
+	stop := startWriter(conn, time.Second)
+	t.Cleanup(func() {
+		stop()
+		conn.Close()
+	})
+	return conn, client, stop
+}
+
+func TestWriteMessageConcurrentWriters(t *testing.T) {
+	conn, client, _ := newWriterPair(t)
+
+	const writers, perWriter = 16, 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				if err := writeMessage(conn, Message{Type: MsgTypePong, Timestamp: time.Now()}); err != nil {
+					t.Errorf("writeMessage: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for received := 0; received < writers*perWriter; received++ {
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("read message %d: %v", received, err)
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type != MsgTypePong {
+			t.Fatalf("unexpected message %q: %v", data, err)
+		}
+	}
+}
+
+func TestWriteCloseAfterQueuedMessages(t *testing.T) {
+	conn, client, _ := newWriterPair(t)
+
+	for i := 0; i < 3; i++ {
+		if err := writeMessage(conn, Message{Type: MsgTypeError}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "bye")
+	if err := writeClose(conn, closeFrame, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("writeClose: %v", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < 3; i++ {
+		if _, _, err := client.ReadMessage(); err != nil {
+			t.Fatalf("message %d lost before the close frame: %v", i, err)
+		}
+	}
+	_, _, err := client.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("expected close 1008, got %v", err)
+	}
+}
+
+func TestWriteMessageAfterWriterStopped(t *testing.T) {
+	conn, _, stop := newWriterPair(t)
+	stop()
+
+	if err := writeMessage(conn, Message{Type: MsgTypePong}); err == nil {
+		t.Fatal("writeMessage succeeded after the writer stopped")
+	}
+}
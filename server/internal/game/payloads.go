@@ -0,0 +1,816 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+// ErrorCode 发送给客户端的错误码，客户端应根据错误码而非错误文本处理错误
+type ErrorCode string
+
+const (
+	// ErrCodeInvalidMessage 消息不是合法的 JSON 或缺少 type 字段
+	ErrCodeInvalidMessage ErrorCode = "invalid_message"
+	// ErrCodeUnknownType 不支持的消息类型
+	ErrCodeUnknownType ErrorCode = "unknown_type"
+	// ErrCodeMessageTooLarge data 字段超过该消息类型的大小上限，details 中给出实际大小和上限
+	ErrCodeMessageTooLarge ErrorCode = "message_too_large"
+	// ErrCodeInvalidPayload data 字段无法解码为该消息类型的载荷结构
+	ErrCodeInvalidPayload ErrorCode = "invalid_payload"
+	// ErrCodeValidationFailed 载荷字段校验失败，fields 中列出具体字段
+	ErrCodeValidationFailed ErrorCode = "validation_failed"
+	// ErrCodeUnauthenticated 需要先发送 auth 消息完成认证
+	ErrCodeUnauthenticated ErrorCode = "unauthenticated"
+	// ErrCodeInvalidDID DID 无法解析
+	ErrCodeInvalidDID ErrorCode = "invalid_did"
+	// ErrCodeJoinFailed 加入房间失败（如房间已满）
+	ErrCodeJoinFailed ErrorCode = "join_failed"
+	// ErrCodeEntryRejected 不满足房间的进入策略（密码、等级），reason 中给出具体原因
+	ErrCodeEntryRejected ErrorCode = "entry_rejected"
+	// ErrCodeRoomNotFound 房间不存在
+	ErrCodeRoomNotFound ErrorCode = "room_not_found"
+	// ErrCodePresentationRejected 凭证表述未通过验证
+	ErrCodePresentationRejected ErrorCode = "presentation_rejected"
+	// ErrCodeNoPendingRequest 没有待响应的表述请求，或请求已过期
+	ErrCodeNoPendingRequest ErrorCode = "no_pending_request"
+	// ErrCodeInventoryFailed 背包操作失败（道具不存在、不可装备等）
+	ErrCodeInventoryFailed ErrorCode = "inventory_failed"
+	// ErrCodeMessageUndeliverable DIDComm 消息无法加密或投递给接收方
+	ErrCodeMessageUndeliverable ErrorCode = "message_undeliverable"
+	// ErrCodeRateLimited 该类型消息发送过于频繁，消息已被丢弃
+	ErrCodeRateLimited ErrorCode = "rate_limited"
+	// ErrCodeKicked 玩家被管理员断开连接
+	ErrCodeKicked ErrorCode = "kicked"
+	// ErrCodeBanned 玩家已被封禁，认证和加入房间被拒绝
+	ErrCodeBanned ErrorCode = "banned"
+	// ErrCodeMuted 玩家已被禁言，聊天消息被丢弃
+	ErrCodeMuted ErrorCode = "muted"
+	// ErrCodeChatRejected 聊天消息被过滤器拒绝，或不在房间内无法聊天
+	ErrCodeChatRejected ErrorCode = "chat_rejected"
+	// ErrCodeWhisperFailed 私聊接收方不在线
+	ErrCodeWhisperFailed ErrorCode = "whisper_failed"
+	// ErrCodeInviteFailed 生成邀请码失败（不在房间内或不是房主）
+	ErrCodeInviteFailed ErrorCode = "invite_failed"
+	// ErrCodeTeamFailed 队伍操作失败（不在房间内、房间未分队、队伍不存在或已满）
+	ErrCodeTeamFailed ErrorCode = "team_failed"
+	// ErrCodeSkillFailed 技能操作失败（技能不存在、已解锁、前置技能未解锁或技能点不足）
+	ErrCodeSkillFailed ErrorCode = "skill_failed"
+	// ErrCodeInteractFailed 交互失败（附近没有该物体、门被锁住、宝箱已空等）
+	ErrCodeInteractFailed ErrorCode = "interact_failed"
+	// ErrCodeMaintenance 游戏处于维护模式，暂时不能加入房间或匹配
+	ErrCodeMaintenance ErrorCode = "maintenance"
+	// ErrCodeResumeFailed 会话令牌无效或已过恢复窗口，需重新发送 auth
+	ErrCodeResumeFailed ErrorCode = "resume_failed"
+	// ErrCodeTradeFailed 交易失败（对方不在线、已在交易中、道具无法托管或背包放不下）
+	ErrCodeTradeFailed ErrorCode = "trade_failed"
+	// ErrCodeGuildFailed 公会操作失败（公会不存在、名称已被占用、已在其他公会、公会已满或不是会长）
+	ErrCodeGuildFailed ErrorCode = "guild_failed"
+	// ErrCodeInboxFailed 收件箱操作失败（消息不存在或存储错误）
+	ErrCodeInboxFailed ErrorCode = "inbox_failed"
+	// ErrCodeAlreadyConnected 同一 DID 的连接数已达上限且策略为 reject_new，details 中没有附加信息
+	ErrCodeAlreadyConnected ErrorCode = "already_connected"
+	// ErrCodeSessionReplaced 同一 DID 在其他连接上登录，本连接随后被服务器关闭
+	ErrCodeSessionReplaced ErrorCode = "session_replaced"
+	// ErrCodeGuestFailed 访客认证或升级失败（未启用访客模式、不是访客、DID 未登记或已有进度）
+	ErrCodeGuestFailed ErrorCode = "guest_failed"
+	// ErrCodeAuthFailed auth 或 upgrade_guest 的 DID 证明无效（nonce 缺失、过期或已使用，或签名不是 DID 认证密钥的签名）
+	ErrCodeAuthFailed ErrorCode = "auth_failed"
+	// ErrCodeUpgradeRequired 客户端的协议版本低于服务器接受的最低版本，details 中给出接受的版本范围
+	ErrCodeUpgradeRequired ErrorCode = "upgrade_required"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError 载荷校验错误，包含所有不合法的字段
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		parts = append(parts, field.Field+": "+field.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// add 记录一个字段错误
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err 没有字段错误时返回 nil
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Payload 客户端消息载荷
+type Payload interface {
+	Validate() error
+}
+
+// inboundMessage 客户端发来的原始消息，data 延迟到确定类型后再解码
+type inboundMessage struct {
+	Type     string          `json:"type"`
+	PlayerID string          `json:"playerId,omitempty"`
+	RoomID   string          `json:"roomId,omitempty"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// AuthPayload auth 消息载荷，DIDProof 证明连接持有该 DID 的认证密钥
+type AuthPayload struct {
+	DID string `json:"did"`
+	DIDProof
+	// Credentials 其他游戏颁发的凭证，认证成功后按默认游戏的配置导入
+	Credentials []json.RawMessage `json:"credentials,omitempty"`
+	// Locale 服务端消息的语言偏好，格式同 Accept-Language，如 "zh-CN,en;q=0.8"
+	Locale string `json:"locale,omitempty"`
+	ProtocolOffer
+}
+
+// Validate 校验载荷
+func (p *AuthPayload) Validate() error {
+	v := &ValidationError{}
+	if p.DID == "" {
+		v.add("did", "is required")
+	} else if !strings.HasPrefix(p.DID, "did:") {
+		v.add("did", "must be a DID")
+	}
+	if len(p.Credentials) > maxImportedCredentials {
+		v.add("credentials", "must contain at most %d credentials", maxImportedCredentials)
+	}
+	p.DIDProof.validate(v)
+	p.ProtocolOffer.validate(v)
+	return v.err()
+}
+
+// GuestAuthPayload guest_auth 消息载荷，服务器为本次会话生成临时 DID
+type GuestAuthPayload struct {
+	// Locale 服务端消息的语言偏好，格式同 AuthPayload.Locale
+	Locale string `json:"locale,omitempty"`
+	ProtocolOffer
+}
+
+// Validate 校验载荷
+func (p *GuestAuthPayload) Validate() error {
+	v := &ValidationError{}
+	p.ProtocolOffer.validate(v)
+	return v.err()
+}
+
+// UpgradeGuestPayload upgrade_guest 消息载荷，访客将进度迁移到本服务登记的 DID，
+// DIDProof 证明连接持有该 DID 的认证密钥
+type UpgradeGuestPayload struct {
+	DID string `json:"did"`
+	DIDProof
+}
+
+// Validate 校验载荷
+func (p *UpgradeGuestPayload) Validate() error {
+	v := &ValidationError{}
+	if p.DID == "" {
+		v.add("did", "is required")
+	} else if !strings.HasPrefix(p.DID, "did:") {
+		v.add("did", "must be a DID")
+	}
+	p.DIDProof.validate(v)
+	return v.err()
+}
+
+// maxSessionTokenLength 会话令牌的最大长度
+const maxSessionTokenLength = 128
+
+// ResumePayload resume 消息载荷
+type ResumePayload struct {
+	SessionToken string `json:"sessionToken"`
+	// Locale 非空时替换认证时协商的语言
+	Locale string `json:"locale,omitempty"`
+	// ProtocolOffer 新连接重新协商协议，不沿用断线前的连接
+	ProtocolOffer
+}
+
+// Validate 校验载荷
+func (p *ResumePayload) Validate() error {
+	v := &ValidationError{}
+	if p.SessionToken == "" {
+		v.add("sessionToken", "is required")
+	} else if len(p.SessionToken) > maxSessionTokenLength {
+		v.add("sessionToken", "must be at most %d characters", maxSessionTokenLength)
+	}
+	p.ProtocolOffer.validate(v)
+	return v.err()
+}
+
+// join_room 字段长度限制
+const (
+	maxRoomPasswordLength = 64
+	maxGameModeLength     = 32
+	maxInviteCodeLength   = 64
+)
+
+// JoinRoomPayload join_room 消息载荷
+type JoinRoomPayload struct {
+	RoomID   string `json:"roomId"`
+	Password string `json:"password,omitempty"`
+	// InviteCode 房间邀请码，指定后加入邀请码对应的房间，忽略 roomId 且不需要密码
+	InviteCode string `json:"inviteCode,omitempty"`
+	// Credentials 其他游戏颁发的凭证，按房间所属游戏的配置导入
+	Credentials []json.RawMessage `json:"credentials,omitempty"`
+
+	// 以下房间配置仅在房间不存在、由本次加入创建时生效
+	RequiredCredentials []string                   `json:"requiredCredentials,omitempty"`
+	RequiredProofs      []vc.CredentialRequirement `json:"requiredProofs,omitempty"`
+	MinLevel            int                        `json:"minLevel,omitempty"`
+	Teams               int                        `json:"teams,omitempty"`
+	Mode                string                     `json:"mode,omitempty"`
+}
+
+// Validate 校验载荷，未指定房间时加入默认房间
+func (p *JoinRoomPayload) Validate() error {
+	v := &ValidationError{}
+	if p.RoomID == "" && p.InviteCode == "" {
+		p.RoomID = "default"
+	}
+	if len(p.InviteCode) > maxInviteCodeLength {
+		v.add("inviteCode", "must be at most %d characters", maxInviteCodeLength)
+	}
+	if len(p.RoomID) > 64 {
+		v.add("roomId", "must be at most 64 characters")
+	}
+	for i, credType := range p.RequiredCredentials {
+		if credType == "" {
+			v.add(fmt.Sprintf("requiredCredentials[%d]", i), "must not be empty")
+		}
+	}
+	if len(p.Password) > maxRoomPasswordLength {
+		v.add("password", "must be at most %d characters", maxRoomPasswordLength)
+	}
+	for i, requirement := range p.RequiredProofs {
+		if requirement.Type == "" {
+			v.add(fmt.Sprintf("requiredProofs[%d].type", i), "must not be empty")
+		}
+		for j, constraint := range requirement.Constraints {
+			if constraint.Attribute == "" {
+				v.add(fmt.Sprintf("requiredProofs[%d].constraints[%d].attribute", i, j), "must not be empty")
+			}
+		}
+	}
+	if p.MinLevel < 0 {
+		v.add("minLevel", "must not be negative")
+	}
+	if p.Teams != 0 && (p.Teams < 2 || p.Teams > maxTeams) {
+		v.add("teams", "must be between 2 and %d", maxTeams)
+	}
+	if len(p.Credentials) > maxImportedCredentials {
+		v.add("credentials", "must contain at most %d credentials", maxImportedCredentials)
+	}
+	if len(p.Mode) > maxGameModeLength {
+		v.add("mode", "must be at most %d characters", maxGameModeLength)
+	}
+	return v.err()
+}
+
+// EmptyPayload 不携带数据的消息载荷，如 leave_room、cancel_match
+type EmptyPayload struct{}
+
+// Validate 校验载荷
+func (p *EmptyPayload) Validate() error {
+	return nil
+}
+
+// MovePayload player_move 消息载荷
+type MovePayload struct {
+	X *float64 `json:"x"`
+	Y *float64 `json:"y"`
+}
+
+// Validate 校验载荷
+func (p *MovePayload) Validate() error {
+	v := &ValidationError{}
+	if p.X == nil {
+		v.add("x", "is required")
+	} else if math.IsNaN(*p.X) || math.IsInf(*p.X, 0) {
+		v.add("x", "must be a finite number")
+	}
+	if p.Y == nil {
+		v.add("y", "is required")
+	} else if math.IsNaN(*p.Y) || math.IsInf(*p.Y, 0) {
+		v.add("y", "must be a finite number")
+	}
+	return v.err()
+}
+
+// Position 返回载荷中的目标位置
+func (p *MovePayload) Position() Position {
+	return Position{X: *p.X, Y: *p.Y}
+}
+
+// 玩家动作类型
+const (
+	ActionAcceptTask = "accept_task"
+	ActionInteract   = "interact"
+	ActionAttack     = "attack"
+)
+
+// ActionPayload player_action 消息载荷，interact 未指定 objectId 时与最近的可交互物体交互，
+// attack 未指定 targetId 时攻击范围内最近的玩家
+type ActionPayload struct {
+	Action   string `json:"action"`
+	TaskID   string `json:"taskId,omitempty"`
+	ObjectID string `json:"objectId,omitempty"`
+	TargetID string `json:"targetId,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *ActionPayload) Validate() error {
+	v := &ValidationError{}
+	switch p.Action {
+	case "":
+		v.add("action", "is required")
+	case ActionAcceptTask:
+		if p.TaskID == "" {
+			v.add("taskId", "is required for %s", ActionAcceptTask)
+		}
+	case ActionInteract:
+		// 未指定 objectId 时与最近的可交互物体交互
+	case ActionAttack:
+		// 未指定 targetId 时攻击最近的玩家
+	default:
+		v.add("action", "unsupported action %q", p.Action)
+	}
+	return v.err()
+}
+
+// maxChatLength 聊天消息最大长度
+const maxChatLength = 500
+
+// chatChannelPattern 房间频道名称
+var chatChannelPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// maxChatHistoryPage chat_history 单页最多请求的消息数
+const maxChatHistoryPage = 100
+
+// ChatPayload chat 消息载荷，指定 to（玩家 ID）时为私聊，否则发送到房间频道
+type ChatPayload struct {
+	Message string `json:"message"`
+	Channel string `json:"channel,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+// Validate 校验载荷，未指定频道时使用默认频道
+func (p *ChatPayload) Validate() error {
+	v := &ValidationError{}
+	if strings.TrimSpace(p.Message) == "" {
+		v.add("message", "is required")
+	} else if len([]rune(p.Message)) > maxChatLength {
+		v.add("message", "must be at most %d characters", maxChatLength)
+	}
+	if p.To != "" && p.Channel != "" {
+		v.add("channel", "must be empty for whispers")
+	}
+	if p.To == "" {
+		validateChatChannel(v, &p.Channel)
+	}
+	return v.err()
+}
+
+// ChatHistoryPayload chat_history 消息载荷，before 为上一页第一条消息的 ID
+type ChatHistoryPayload struct {
+	Channel string `json:"channel,omitempty"`
+	Before  string `json:"before,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *ChatHistoryPayload) Validate() error {
+	v := &ValidationError{}
+	validateChatChannel(v, &p.Channel)
+	if p.Limit < 0 || p.Limit > maxChatHistoryPage {
+		v.add("limit", "must be between 0 and %d", maxChatHistoryPage)
+	}
+	return v.err()
+}
+
+// ChatMutePayload chat_mute 消息载荷，未指定操作时返回屏蔽列表
+type ChatMutePayload struct {
+	Action   string `json:"action"`
+	PlayerID string `json:"playerId,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *ChatMutePayload) Validate() error {
+	v := &ValidationError{}
+	switch p.Action {
+	case "":
+		p.Action = ChatMuteList
+	case ChatMuteList:
+	case ChatMuteAdd, ChatMuteRemove:
+		if p.PlayerID == "" {
+			v.add("playerId", "is required for %s", p.Action)
+		}
+	default:
+		v.add("action", "unsupported action %q", p.Action)
+	}
+	return v.err()
+}
+
+// validateChatChannel 校验频道名称，为空时使用默认频道
+func validateChatChannel(v *ValidationError, channel *string) {
+	if *channel == "" {
+		*channel = DefaultChatChannel
+	} else if !chatChannelPattern.MatchString(*channel) {
+		v.add("channel", "must be 1-32 lowercase letters, digits, '_' or '-'")
+	}
+}
+
+// RoomInvitePayload room_invite 消息载荷，未指定时生成 10 分钟内有效的单次邀请码
+type RoomInvitePayload struct {
+	MaxUses    int `json:"maxUses,omitempty"`
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
+	// To 受邀玩家的 DID，邀请码同时放入其收件箱
+	To string `json:"to,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *RoomInvitePayload) Validate() error {
+	v := &ValidationError{}
+	if p.MaxUses == 0 {
+		p.MaxUses = 1
+	}
+	if p.MaxUses < 1 || p.MaxUses > maxInviteUses {
+		v.add("maxUses", "must be between 1 and %d", maxInviteUses)
+	}
+	if p.TTLSeconds < 0 || p.TTLSeconds > int(MaxInviteTTL/time.Second) {
+		v.add("ttlSeconds", "must be between 0 and %d", int(MaxInviteTTL/time.Second))
+	}
+	return v.err()
+}
+
+// JoinTeamPayload join_team 消息载荷，未指定队伍时自动分配
+type JoinTeamPayload struct {
+	TeamID string `json:"teamId,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *JoinTeamPayload) Validate() error {
+	return nil
+}
+
+// PresentationPayload presentation 消息载荷
+type PresentationPayload struct {
+	Presentation json.RawMessage `json:"presentation"`
+}
+
+// Validate 校验载荷
+func (p *PresentationPayload) Validate() error {
+	v := &ValidationError{}
+	if len(p.Presentation) == 0 || string(p.Presentation) == "null" {
+		v.add("presentation", "is required")
+	}
+	return v.err()
+}
+
+// QueueMatchPayload queue_match 消息载荷，匹配使用的分数和延迟由服务器测得
+type QueueMatchPayload struct {
+	Mode string `json:"mode"`
+}
+
+// Validate 校验载荷，未指定模式时使用默认模式
+func (p *QueueMatchPayload) Validate() error {
+	if p.Mode == "" {
+		p.Mode = "default"
+	}
+	return nil
+}
+
+// InventoryPayload inventory 消息载荷
+type InventoryPayload struct {
+	Action   string `json:"action"`
+	ItemID   string `json:"itemId,omitempty"`
+	Quantity int    `json:"quantity,omitempty"`
+	Slot     string `json:"slot,omitempty"`
+}
+
+// Validate 校验载荷，未指定操作时返回背包内容
+func (p *InventoryPayload) Validate() error {
+	v := &ValidationError{}
+	switch p.Action {
+	case "":
+		p.Action = InventoryList
+	case InventoryList:
+	case InventoryRemove, InventoryEquip:
+		if p.ItemID == "" {
+			v.add("itemId", "is required for %s", p.Action)
+		}
+		if p.Quantity < 0 {
+			v.add("quantity", "must not be negative")
+		}
+	case InventoryUnequip:
+		if p.Slot == "" {
+			v.add("slot", "is required for %s", p.Action)
+		}
+	default:
+		v.add("action", "unsupported action %q", p.Action)
+	}
+	return v.err()
+}
+
+// SkillsPayload skills 消息载荷
+type SkillsPayload struct {
+	Action  string `json:"action"`
+	GameID  string `json:"gameId,omitempty"`
+	SkillID string `json:"skillId,omitempty"`
+}
+
+// Validate 校验载荷，未指定操作时返回技能树和已解锁技能
+func (p *SkillsPayload) Validate() error {
+	v := &ValidationError{}
+	switch p.Action {
+	case "":
+		p.Action = SkillsList
+	case SkillsList:
+	case SkillsUnlock:
+		if p.SkillID == "" {
+			v.add("skillId", "is required for %s", p.Action)
+		}
+	default:
+		v.add("action", "unsupported action %q", p.Action)
+	}
+	return v.err()
+}
+
+// TradePayload trade 消息载荷
+type TradePayload struct {
+	Action  string      `json:"action"`
+	TradeID string      `json:"tradeId,omitempty"`
+	To      string      `json:"to,omitempty"`      // propose 的交易对象玩家 ID
+	Items   []TradeItem `json:"items,omitempty"`   // propose、accept、offer 的报价
+	Receipt bool        `json:"receipt,omitempty"` // propose、accept 时要求完成后颁发交易回执凭证
+}
+
+// Validate 校验载荷，cancel 未指定交易时取消玩家当前的交易
+func (p *TradePayload) Validate() error {
+	v := &ValidationError{}
+	switch p.Action {
+	case TradePropose:
+		if p.To == "" {
+			v.add("to", "is required for %s", p.Action)
+		}
+	case TradeAccept, TradeOffer, TradeConfirm:
+		if p.TradeID == "" {
+			v.add("tradeId", "is required for %s", p.Action)
+		}
+	case TradeCancel:
+	default:
+		v.add("action", "unsupported action %q", p.Action)
+	}
+
+	seen := make(map[string]bool, len(p.Items))
+	for i, item := range p.Items {
+		if item.ItemID == "" {
+			v.add(fmt.Sprintf("items[%d].itemId", i), "is required")
+		} else if seen[item.ItemID] {
+			v.add(fmt.Sprintf("items[%d].itemId", i), "is duplicated")
+		}
+		seen[item.ItemID] = true
+		if item.Quantity < 0 {
+			v.add(fmt.Sprintf("items[%d].quantity", i), "must not be negative")
+		}
+	}
+	return v.err()
+}
+
+// 公会字段长度限制
+const (
+	minGuildNameLength = 3
+	maxGuildNameLength = 32
+	maxGuildTagLength  = 5
+)
+
+// guildTagPattern 公会标签只允许字母和数字
+var guildTagPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// GuildPayload guild 消息载荷
+type GuildPayload struct {
+	Action  string `json:"action"`
+	GuildID string `json:"guildId,omitempty"` // join 的公会 ID
+	Name    string `json:"name,omitempty"`    // create 的公会名
+	Tag     string `json:"tag,omitempty"`     // create 的公会标签，可选
+	DID     string `json:"did,omitempty"`     // promote 的成员 DID
+	// Credential create、join 成功后颁发 GuildMembershipCredential
+	Credential bool `json:"credential,omitempty"`
+}
+
+// Validate 校验载荷，未指定操作时返回玩家所在公会的名册
+func (p *GuildPayload) Validate() error {
+	v := &ValidationError{}
+	switch p.Action {
+	case "":
+		p.Action = GuildInfo
+	case GuildInfo, GuildLeave, GuildCredential:
+	case GuildCreate:
+		p.Name = strings.TrimSpace(p.Name)
+		if length := utf8.RuneCountInString(p.Name); length < minGuildNameLength || length > maxGuildNameLength {
+			v.add("name", "must be %d-%d characters", minGuildNameLength, maxGuildNameLength)
+		}
+		if p.Tag != "" && (len(p.Tag) > maxGuildTagLength || !guildTagPattern.MatchString(p.Tag)) {
+			v.add("tag", "must be at most %d letters or digits", maxGuildTagLength)
+		}
+	case GuildJoin:
+		if p.GuildID == "" {
+			v.add("guildId", "is required for %s", p.Action)
+		}
+	case GuildPromote:
+		if p.DID == "" {
+			v.add("did", "is required for %s", p.Action)
+		}
+	default:
+		v.add("action", "unsupported action %q", p.Action)
+	}
+	return v.err()
+}
+
+// AchievementsPayload achievements 消息载荷，未指定游戏时返回所在房间的游戏的成就进度
+type AchievementsPayload struct {
+	GameID string `json:"gameId,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *AchievementsPayload) Validate() error {
+	v := &ValidationError{}
+	if len(p.GameID) > 64 {
+		v.add("gameId", "must be at most 64 characters")
+	}
+	return v.err()
+}
+
+// maxInboxIDs 一次 read 或 delete 最多指定的消息数
+const maxInboxIDs = MaxInboxMessages
+
+// InboxPayload inbox 消息载荷，未指定操作时列出消息
+type InboxPayload struct {
+	Action     string   `json:"action"`
+	IDs        []string `json:"ids,omitempty"`        // read、delete 的消息 ID，省略时作用于所有消息
+	UnreadOnly bool     `json:"unreadOnly,omitempty"` // list 只返回未读消息
+}
+
+// Validate 校验载荷
+func (p *InboxPayload) Validate() error {
+	v := &ValidationError{}
+	switch p.Action {
+	case "":
+		p.Action = InboxList
+	case InboxList, InboxRead, InboxDelete:
+	default:
+		v.add("action", "unsupported action %q", p.Action)
+	}
+	if len(p.IDs) > maxInboxIDs {
+		v.add("ids", "must contain at most %d IDs", maxInboxIDs)
+	}
+	return v.err()
+}
+
+// PingPayload ping 消息载荷，原样回显给客户端
+type PingPayload struct {
+	ClientTime *time.Time `json:"clientTime,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *PingPayload) Validate() error {
+	return nil
+}
+
+// StateAckPayload state_ack 消息载荷
+type StateAckPayload struct {
+	// Version 客户端已应用的最新状态版本
+	Version uint64 `json:"version"`
+	// Resync 客户端状态与增量的基础版本不一致，请求完整快照
+	Resync bool `json:"resync,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *StateAckPayload) Validate() error {
+	return nil
+}
+
+// DIDCommPayload didcomm 消息载荷，服务器以发送方身份加密后投递给接收方 DID
+type DIDCommPayload struct {
+	To       string          `json:"to"`
+	Type     string          `json:"type"`
+	Body     json.RawMessage `json:"body"`
+	ThreadID string          `json:"thid,omitempty"`
+	// Attachments 随消息发送的附件，例如 Issue Credential 协议中的凭证请求
+	Attachments []aries.Attachment `json:"attachments,omitempty"`
+}
+
+// Validate 校验载荷
+func (p *DIDCommPayload) Validate() error {
+	v := &ValidationError{}
+	if !strings.HasPrefix(p.To, "did:") {
+		v.add("to", "must be a DID")
+	}
+	if p.Type == "" {
+		v.add("type", "is required")
+	}
+	if len(p.Body) == 0 || string(p.Body) == "null" {
+		v.add("body", "is required")
+	}
+	return v.err()
+}
+
+// payloadRegistry 消息类型到载荷结构的映射
+var payloadRegistry = map[string]func() Payload{
+	MsgTypeAuth:          func() Payload { return &AuthPayload{} },
+	MsgTypeAuthChallenge: func() Payload { return &EmptyPayload{} },
+	MsgTypeResume:        func() Payload { return &ResumePayload{} },
+	MsgTypeGuestAuth:     func() Payload { return &GuestAuthPayload{} },
+	MsgTypeUpgradeGuest:  func() Payload { return &UpgradeGuestPayload{} },
+	MsgTypeJoinRoom:      func() Payload { return &JoinRoomPayload{} },
+	MsgTypeLeaveRoom:     func() Payload { return &EmptyPayload{} },
+	MsgTypePlayerMove:    func() Payload { return &MovePayload{} },
+	MsgTypePlayerAction:  func() Payload { return &ActionPayload{} },
+	MsgTypeChat:          func() Payload { return &ChatPayload{} },
+	MsgTypeChatHistory:   func() Payload { return &ChatHistoryPayload{} },
+	MsgTypeChatMute:      func() Payload { return &ChatMutePayload{} },
+	MsgTypeJoinTeam:      func() Payload { return &JoinTeamPayload{} },
+	MsgTypeRoomInvite:    func() Payload { return &RoomInvitePayload{} },
+	MsgTypePresentation:  func() Payload { return &PresentationPayload{} },
+	MsgTypeQueueMatch:    func() Payload { return &QueueMatchPayload{} },
+	MsgTypeCancelMatch:   func() Payload { return &EmptyPayload{} },
+	MsgTypePing:          func() Payload { return &PingPayload{} },
+	MsgTypeStateAck:      func() Payload { return &StateAckPayload{} },
+	MsgTypeInventory:     func() Payload { return &InventoryPayload{} },
+	MsgTypeTrade:         func() Payload { return &TradePayload{} },
+	MsgTypeSkills:        func() Payload { return &SkillsPayload{} },
+	MsgTypeGuild:         func() Payload { return &GuildPayload{} },
+	MsgTypeAchievements:  func() Payload { return &AchievementsPayload{} },
+	MsgTypeInbox:         func() Payload { return &InboxPayload{} },
+	MsgTypeDIDComm:       func() Payload { return &DIDCommPayload{} },
+}
+
+// ProtocolError 返回给客户端的结构化错误
+type ProtocolError struct {
+	Code    ErrorCode              `json:"code"`
+	Message string                 `json:"message"`
+	Type    string                 `json:"type,omitempty"`
+	Fields  []FieldError           `json:"fields,omitempty"`
+	Reason  string                 `json:"reason,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	// Key 和 Params 为本地化消息的键和参数，Message 已按连接协商的语言生成
+	Key    string      `json:"key,omitempty"`
+	Params i18n.Params `json:"params,omitempty"`
+}
+
+// decodePayload 按消息类型解码并校验载荷
+func decodePayload(msgType string, data json.RawMessage) (Payload, *ProtocolError) {
+	factory, exists := payloadRegistry[msgType]
+	if !exists {
+		return nil, &ProtocolError{
+			Code:    ErrCodeUnknownType,
+			Message: fmt.Sprintf("unknown message type: %s", msgType),
+			Type:    msgType,
+		}
+	}
+
+	payload := factory()
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, payload); err != nil {
+			return nil, &ProtocolError{
+				Code:    ErrCodeInvalidPayload,
+				Message: fmt.Sprintf("decode %s payload: %v", msgType, err),
+				Type:    msgType,
+			}
+		}
+	}
+
+	if err := payload.Validate(); err != nil {
+		protocolErr := &ProtocolError{
+			Code:    ErrCodeValidationFailed,
+			Message: err.Error(),
+			Type:    msgType,
+		}
+		if validationErr, ok := err.(*ValidationError); ok {
+			protocolErr.Fields = validationErr.Fields
+		}
+		return nil, protocolErr
+	}
+
+	return payload, nil
+}
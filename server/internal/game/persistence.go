@@ -0,0 +1,813 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+const (
+	// gameStateStoreName 游戏状态存储名称
+	gameStateStoreName = "game_state"
+
+	// 存储标签，用于按类型查询记录
+	recordTypeTag       = "type"
+	recordTypePlayer    = "player"
+	recordTypeRoom      = "room"
+	recordTypeInventory = "inventory"
+
+	// gameStateLeaseName 全量快照写入者的租约名称
+	gameStateLeaseName = "game_state_snapshot"
+)
+
+// PersistenceConfig 持久化配置
+type PersistenceConfig struct {
+	SnapshotInterval time.Duration // 全量快照间隔
+	FlushInterval    time.Duration // 写回批次刷新间隔
+	BatchSize        int           // 待写入记录达到该数量时立即刷新
+	LeaseTTL         time.Duration // 多实例共享存储时全量快照写入者租约的有效期，每三分之一有效期续期一次
+}
+
+// DefaultPersistenceConfig 返回默认持久化配置
+func DefaultPersistenceConfig() PersistenceConfig {
+	return PersistenceConfig{
+		SnapshotInterval: 30 * time.Second,
+		FlushInterval:    2 * time.Second,
+		BatchSize:        100,
+		LeaseTTL:         15 * time.Second,
+	}
+}
+
+// PlayerRecord 玩家持久化记录
+type PlayerRecord struct {
+	ID         string    `json:"id"`
+	DID        string    `json:"did"`
+	Nickname   string    `json:"nickname"`
+	Position   Position  `json:"position"`
+	Level      int       `json:"level"`
+	Experience int       `json:"experience,omitempty"`
+	Health     int       `json:"health"`
+	MaxHealth  int       `json:"maxHealth"`
+	RoomID     string    `json:"roomId,omitempty"`
+	LastSeen   time.Time `json:"lastSeen"`
+
+	MutedPlayers []string `json:"mutedPlayers,omitempty"`
+
+	Skills           map[string][]string `json:"skills,omitempty"`
+	SkillPointsSpent int                 `json:"skillPointsSpent,omitempty"`
+
+	Titles              []string `json:"titles,omitempty"`
+	ImportedCredentials []string `json:"importedCredentials,omitempty"`
+
+	Avatar  string          `json:"avatar,omitempty"`
+	Title   string          `json:"title,omitempty"`
+	Privacy PrivacySettings `json:"privacy"`
+}
+
+// RoomRecord 房间持久化记录
+type RoomRecord struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	GameID     string     `json:"gameId"`
+	MaxPlayers int        `json:"maxPlayers"`
+	GameState  *GameState `json:"gameState"`
+	PlayerIDs  []string   `json:"playerIds"`
+	CreatedAt  time.Time  `json:"createdAt"`
+
+	RequiredCredentials []string                   `json:"requiredCredentials,omitempty"`
+	RequiredProofs      []vc.CredentialRequirement `json:"requiredProofs,omitempty"`
+	MinLevel            int                        `json:"minLevel,omitempty"`
+	PasswordHash        string                     `json:"passwordHash,omitempty"`
+	Teams               []*Team                    `json:"teams,omitempty"`
+	Mode                string                     `json:"mode,omitempty"`
+	OwnerID             string                     `json:"ownerId,omitempty"`
+	Group               string                     `json:"group,omitempty"`
+}
+
+// InventoryRecord 背包持久化记录
+type InventoryRecord struct {
+	PlayerID string  `json:"playerId,omitempty"`
+	Capacity int     `json:"capacity"`
+	Items    []*Item `json:"items"`
+}
+
+// batchProvider 支持在单个事务中写入和删除的存储（如 mysqlstore）
+type batchProvider interface {
+	Batch(storeName string, puts []storage.Operation, deletes []string) error
+}
+
+// versionedProvider 记录版本号并支持比较后写入的存储（如 mysqlstore）
+type versionedProvider interface {
+	Versions(storeName string) (map[string]uint64, error)
+	BatchIfVersion(storeName string, ops []storage.Operation, expected map[string]uint64) (map[string]uint64, []string, error)
+}
+
+// leaseProvider 支持租约的存储（如 mysqlstore），共享存储的实例中持有租约的一个为主实例
+type leaseProvider interface {
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// Persistence 游戏状态持久化层，采用写回（write-behind）批量写入。
+//
+// 多个实例共享存储时，本实例上连接的玩家和有本实例玩家的房间由本实例直接写入；其余玩家和房间的
+// 全量快照只由持有租约的主实例写入，并且只在记录自主实例读取或写入后没有被其他实例修改时写入，
+// 否则丢弃并计入 game_persistence_conflicts_total，过期的副本不会覆盖其他实例的写入
+type Persistence struct {
+	store  storage.Store
+	config PersistenceConfig
+
+	// 存储支持事务时，玩家、房间和背包记录在同一事务中写入
+	batcher batchProvider
+
+	// 存储记录版本号时，versions 为本实例读取或写入的各记录版本，写入期间持有 versionMutex
+	versioned    versionedProvider
+	versions     map[string]uint64
+	versionMutex sync.Mutex
+
+	// 存储支持租约时，holder 为本实例的租约持有者 ID
+	leases leaseProvider
+	holder string
+	leader atomic.Bool
+
+	// conditional 为待写记录中只在未被其他实例修改时写入的键
+	pending     map[string]storage.Operation
+	conditional map[string]bool
+	mutex       sync.Mutex
+
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	once    sync.Once
+}
+
+// NewPersistence 创建新的持久化层
+func NewPersistence(provider storage.Provider, config PersistenceConfig) (*Persistence, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(gameStateStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open game state store: %w", err)
+	}
+
+	defaults := DefaultPersistenceConfig()
+	if config.SnapshotInterval <= 0 {
+		config.SnapshotInterval = defaults.SnapshotInterval
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = defaults.LeaseTTL
+	}
+	batcher, _ := provider.(batchProvider)
+	versioned, _ := provider.(versionedProvider)
+	leases, _ := provider.(leaseProvider)
+
+	return &Persistence{
+		store:       store,
+		config:      config,
+		batcher:     batcher,
+		versioned:   versioned,
+		versions:    make(map[string]uint64),
+		leases:      leases,
+		holder:      uuid.New().String(),
+		pending:     make(map[string]storage.Operation),
+		conditional: make(map[string]bool),
+		flushCh:     make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}, nil
+}
+
+// Start 启动后台刷新和快照协程，snapshot 用于生成全量快照
+func (p *Persistence) Start(snapshot func()) {
+	if p.leases != nil {
+		p.renewLease()
+	}
+	go p.run(snapshot)
+}
+
+func (p *Persistence) run(snapshot func()) {
+	defer close(p.doneCh)
+
+	flushTicker := time.NewTicker(p.config.FlushInterval)
+	defer flushTicker.Stop()
+
+	snapshotTicker := time.NewTicker(p.config.SnapshotInterval)
+	defer snapshotTicker.Stop()
+
+	// 不支持租约的存储上 leaseC 为 nil，不会触发
+	var leaseC <-chan time.Time
+	if p.leases != nil {
+		leaseTicker := time.NewTicker(p.config.LeaseTTL / 3)
+		defer leaseTicker.Stop()
+		leaseC = leaseTicker.C
+	}
+
+	for {
+		select {
+		case <-flushTicker.C:
+			p.flushAndLog()
+		case <-p.flushCh:
+			p.flushAndLog()
+		case <-leaseC:
+			p.renewLease()
+		case <-snapshotTicker.C:
+			if snapshot != nil {
+				snapshot()
+			}
+			p.flushAndLog()
+		case <-p.stopCh:
+			if snapshot != nil {
+				snapshot()
+			}
+			p.flushAndLog()
+			p.releaseLease()
+			return
+		}
+	}
+}
+
+// IsLeader 本实例是否为写入全量快照的主实例，存储不支持租约时总是主实例
+func (p *Persistence) IsLeader() bool {
+	return p.leases == nil || p.leader.Load()
+}
+
+// renewLease 获取或续期主实例租约，出错时无法确认仍持有租约，按失去租约处理
+func (p *Persistence) renewLease() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.LeaseTTL/3)
+	defer cancel()
+
+	acquired, err := p.leases.AcquireLease(ctx, gameStateLeaseName, p.holder, p.config.LeaseTTL)
+	if err != nil {
+		slog.Error("Failed to renew game state lease", logging.Err(err))
+		acquired = false
+	}
+	if p.leader.Swap(acquired) == acquired {
+		return
+	}
+	if acquired {
+		persistenceLeaderGauge.Set(1)
+		slog.Info("Acquired game state lease, writing full snapshots", "holder", p.holder)
+	} else {
+		persistenceLeaderGauge.Set(0)
+		slog.Warn("Lost game state lease, no longer writing full snapshots", "holder", p.holder)
+	}
+}
+
+// releaseLease 关闭时释放租约，其他实例无需等待租约过期即可接替
+func (p *Persistence) releaseLease() {
+	if p.leases == nil || !p.leader.Swap(false) {
+		return
+	}
+	persistenceLeaderGauge.Set(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.LeaseTTL/3)
+	defer cancel()
+	if err := p.leases.ReleaseLease(ctx, gameStateLeaseName, p.holder); err != nil {
+		slog.Error("Failed to release game state lease", logging.Err(err))
+	}
+}
+
+// Close 停止后台协程并写入所有待写记录
+func (p *Persistence) Close() error {
+	p.once.Do(func() {
+		close(p.stopCh)
+	})
+	<-p.doneCh
+
+	return p.Flush()
+}
+
+// SavePlayer 将玩家状态加入待写队列
+func (p *Persistence) SavePlayer(record *PlayerRecord) error {
+	return p.savePlayer(record, false)
+}
+
+// SavePlayerIfUnchanged 将玩家状态加入待写队列，只在记录没有被其他实例修改时写入
+func (p *Persistence) SavePlayerIfUnchanged(record *PlayerRecord) error {
+	return p.savePlayer(record, true)
+}
+
+func (p *Persistence) savePlayer(record *PlayerRecord, conditional bool) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal player record: %w", err)
+	}
+
+	p.enqueue(storage.Operation{
+		Key:   playerKey(record.ID),
+		Value: data,
+		Tags:  []storage.Tag{{Name: recordTypeTag, Value: recordTypePlayer}},
+	}, conditional)
+	return nil
+}
+
+// SaveRoom 将房间状态加入待写队列
+func (p *Persistence) SaveRoom(record *RoomRecord) error {
+	return p.saveRoom(record, false)
+}
+
+// SaveRoomIfUnchanged 将房间状态加入待写队列，只在记录没有被其他实例修改时写入
+func (p *Persistence) SaveRoomIfUnchanged(record *RoomRecord) error {
+	return p.saveRoom(record, true)
+}
+
+func (p *Persistence) saveRoom(record *RoomRecord, conditional bool) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal room record: %w", err)
+	}
+
+	p.enqueue(storage.Operation{
+		Key:   roomKey(record.ID),
+		Value: data,
+		Tags:  []storage.Tag{{Name: recordTypeTag, Value: recordTypeRoom}},
+	}, conditional)
+	return nil
+}
+
+// SaveInventory 将玩家背包加入待写队列
+func (p *Persistence) SaveInventory(record *InventoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal inventory record: %w", err)
+	}
+
+	p.enqueue(storage.Operation{
+		Key:   inventoryKey(record.PlayerID),
+		Value: data,
+		Tags:  []storage.Tag{{Name: recordTypeTag, Value: recordTypeInventory}},
+	}, false)
+	return nil
+}
+
+// DeletePlayer 将玩家状态和背包的删除操作加入待写队列
+func (p *Persistence) DeletePlayer(playerID string) {
+	p.enqueue(storage.Operation{Key: playerKey(playerID)}, false)
+	p.enqueue(storage.Operation{Key: inventoryKey(playerID)}, false)
+}
+
+// DeleteRoom 将房间删除操作加入待写队列
+func (p *Persistence) DeleteRoom(roomID string) {
+	// Value 为 nil 的操作在 Batch 中表示删除
+	p.enqueue(storage.Operation{Key: roomKey(roomID)}, false)
+}
+
+// enqueue 加入待写队列，同一记录的待写操作合并为最新的一个；合并的操作中有一个无条件写入时无条件写入
+func (p *Persistence) enqueue(op storage.Operation, conditional bool) {
+	p.mutex.Lock()
+	_, queued := p.pending[op.Key]
+	switch {
+	case !conditional:
+		delete(p.conditional, op.Key)
+	case !queued:
+		p.conditional[op.Key] = true
+	}
+	p.pending[op.Key] = op
+	full := len(p.pending) >= p.config.BatchSize
+	p.mutex.Unlock()
+
+	if full {
+		select {
+		case p.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush 批量写入所有待写记录
+func (p *Persistence) Flush() error {
+	p.mutex.Lock()
+	if len(p.pending) == 0 {
+		p.mutex.Unlock()
+		return nil
+	}
+
+	ops := make([]storage.Operation, 0, len(p.pending))
+	for _, op := range p.pending {
+		ops = append(ops, op)
+	}
+	conditional := p.conditional
+	p.pending = make(map[string]storage.Operation)
+	p.conditional = make(map[string]bool)
+	p.mutex.Unlock()
+
+	if err := p.writeBatch(ops, conditional); err != nil {
+		// 写入失败时放回队列，避免覆盖期间产生的更新记录
+		p.mutex.Lock()
+		for _, op := range ops {
+			if _, exists := p.pending[op.Key]; !exists {
+				p.pending[op.Key] = op
+				if conditional[op.Key] {
+					p.conditional[op.Key] = true
+				}
+			}
+		}
+		p.mutex.Unlock()
+		return fmt.Errorf("batch write game state: %w", err)
+	}
+
+	return nil
+}
+
+// writeBatch 原子写入一批操作，Value 为 nil 的操作表示删除。
+// conditional 中的键只在未被其他实例修改时写入，存储不记录版本号时无条件写入
+func (p *Persistence) writeBatch(ops []storage.Operation, conditional map[string]bool) error {
+	if p.versioned != nil {
+		return p.writeVersioned(ops, conditional)
+	}
+	if p.batcher == nil {
+		return p.store.Batch(ops)
+	}
+
+	var (
+		puts    []storage.Operation
+		deletes []string
+	)
+	for _, op := range ops {
+		if op.Value == nil {
+			deletes = append(deletes, op.Key)
+		} else {
+			puts = append(puts, op)
+		}
+	}
+	return p.batcher.Batch(gameStateStoreName, puts, deletes)
+}
+
+// writeVersioned 写入并记录新的版本号，conditional 中的键按已知版本比较后写入。
+// 冲突的写入被丢弃，已知版本保持不变，本实例的副本已过期，之后的条件写入同样冲突
+func (p *Persistence) writeVersioned(ops []storage.Operation, conditional map[string]bool) error {
+	p.versionMutex.Lock()
+	defer p.versionMutex.Unlock()
+
+	expected := make(map[string]uint64, len(conditional))
+	for key := range conditional {
+		expected[key] = p.versions[key]
+	}
+
+	versions, conflicts, err := p.versioned.BatchIfVersion(gameStateStoreName, ops, expected)
+	if err != nil {
+		return err
+	}
+	for key, version := range versions {
+		if version == 0 {
+			delete(p.versions, key)
+		} else {
+			p.versions[key] = version
+		}
+	}
+	for _, key := range conflicts {
+		recordType, _, _ := strings.Cut(key, ":")
+		persistenceConflictsTotal.With(recordType).Inc()
+	}
+	if len(conflicts) > 0 {
+		slog.Warn("Dropped game state writes changed by another instance", "count", len(conflicts), "keys", conflicts)
+	}
+	return nil
+}
+
+func (p *Persistence) flushAndLog() {
+	if err := p.Flush(); err != nil {
+		slog.Error("Failed to flush game state", logging.Err(err))
+	}
+}
+
+// LoadPlayers 加载所有已持久化的玩家
+func (p *Persistence) LoadPlayers() ([]*PlayerRecord, error) {
+	var records []*PlayerRecord
+	err := p.loadRecords(recordTypePlayer, func(data []byte) error {
+		var record PlayerRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("unmarshal player record: %w", err)
+		}
+		records = append(records, &record)
+		return nil
+	})
+	return records, err
+}
+
+// LoadRooms 加载所有已持久化的房间
+func (p *Persistence) LoadRooms() ([]*RoomRecord, error) {
+	var records []*RoomRecord
+	err := p.loadRecords(recordTypeRoom, func(data []byte) error {
+		var record RoomRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("unmarshal room record: %w", err)
+		}
+		records = append(records, &record)
+		return nil
+	})
+	return records, err
+}
+
+// LoadInventories 加载所有已持久化的背包
+func (p *Persistence) LoadInventories() ([]*InventoryRecord, error) {
+	var records []*InventoryRecord
+	err := p.loadRecords(recordTypeInventory, func(data []byte) error {
+		var record InventoryRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("unmarshal inventory record: %w", err)
+		}
+		records = append(records, &record)
+		return nil
+	})
+	return records, err
+}
+
+// loadRecords 加载一种记录。存储记录版本号时先读取版本再读取记录，
+// 记录的已知版本不会比加载的内容新，过期的内容不会覆盖其他实例的写入
+func (p *Persistence) loadRecords(recordType string, decode func(data []byte) error) error {
+	if p.versioned != nil {
+		versions, err := p.versioned.Versions(gameStateStoreName)
+		if err != nil {
+			return fmt.Errorf("load %s record versions: %w", recordType, err)
+		}
+		p.versionMutex.Lock()
+		for key, version := range versions {
+			if _, known := p.versions[key]; !known {
+				p.versions[key] = version
+			}
+		}
+		p.versionMutex.Unlock()
+	}
+	return queryRecords(p.store, recordTypeTag, recordType, decode)
+}
+
+func playerKey(playerID string) string {
+	return "player:" + playerID
+}
+
+func roomKey(roomID string) string {
+	return "room:" + roomID
+}
+
+func inventoryKey(playerID string) string {
+	return "inventory:" + playerID
+}
+
+// restoreState 从持久化存储恢复玩家和房间
+func (s *SimpleServer) restoreState() error {
+	rooms, err := s.persistence.LoadRooms()
+	if err != nil {
+		return err
+	}
+
+	players, err := s.persistence.LoadPlayers()
+	if err != nil {
+		return err
+	}
+
+	inventories, err := s.persistence.LoadInventories()
+	if err != nil {
+		return err
+	}
+
+	s.roomMutex.Lock()
+	defer s.roomMutex.Unlock()
+
+	for _, record := range rooms {
+		gameState := record.GameState
+		if gameState == nil {
+			gameState = defaultGameState()
+		}
+
+		mode, found := s.gameMode(record.Mode)
+		if !found {
+			slog.Warn("Room uses unregistered game mode, falling back to default", logging.KeyRoomID, record.ID, "mode", record.Mode, "default", DefaultGameMode)
+			mode, _ = s.gameMode(DefaultGameMode)
+		}
+
+		// 重启后所有玩家都处于离线状态，房间成员在玩家重新加入时恢复
+		room := &GameRoom{
+			ID:         record.ID,
+			Name:       record.Name,
+			GameID:     record.GameID,
+			MaxPlayers: record.MaxPlayers,
+			Players:    make(map[string]*Player),
+			GameState:  gameState,
+			CreatedAt:  record.CreatedAt,
+
+			RequiredCredentials: record.RequiredCredentials,
+			RequiredProofs:      record.RequiredProofs,
+			MinLevel:            record.MinLevel,
+			PasswordProtected:   record.PasswordHash != "",
+			passwordHash:        record.PasswordHash,
+			Teams:               record.Teams,
+			Mode:                mode.Name(),
+			OwnerID:             record.OwnerID,
+			Group:               record.Group,
+			mode:                mode,
+		}
+		s.rooms[record.ID] = room
+		roomsGauge.Inc()
+		s.startRoomLoop(room)
+	}
+
+	for _, record := range players {
+		player := &Player{
+			ID:         record.ID,
+			DID:        record.DID,
+			Nickname:   record.Nickname,
+			Position:   record.Position,
+			Level:      record.Level,
+			Experience: record.Experience,
+			Health:     record.Health,
+			MaxHealth:  record.MaxHealth,
+			Status:     "offline",
+			LastSeen:   record.LastSeen,
+			Inventory:  NewInventory(DefaultInventoryCapacity),
+		}
+		for _, mutedID := range record.MutedPlayers {
+			player.setMuted(mutedID, true)
+		}
+		player.skills = record.Skills
+		player.skillPointsSpent = record.SkillPointsSpent
+		player.Titles = record.Titles
+		player.Avatar = record.Avatar
+		player.Title = record.Title
+		player.privacy = record.Privacy
+		for _, id := range record.ImportedCredentials {
+			if player.importedCredentials == nil {
+				player.importedCredentials = make(map[string]bool)
+			}
+			player.importedCredentials[id] = true
+		}
+		s.players.put(player)
+	}
+
+	for _, record := range inventories {
+		if player, exists := s.players.get(record.PlayerID); exists {
+			player.Inventory = inventoryFromRecord(record)
+		}
+	}
+
+	slog.Info("Restored game state from storage", "players", len(players), "rooms", len(rooms))
+	return nil
+}
+
+// snapshotState 将本实例上连接的玩家和有本实例玩家的房间的当前状态加入待写队列。
+// 主实例同时写入其余玩家和房间，这些记录只在没有被其他实例修改时写入
+func (s *SimpleServer) snapshotState() {
+	players := s.players.all()
+	leader := s.persistence.IsLeader()
+
+	s.roomMutex.RLock()
+	rooms := make([]*GameRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.roomMutex.RUnlock()
+
+	for _, player := range players {
+		switch {
+		case s.connections.Count(player.DID) > 0:
+			s.persistPlayer(player)
+		case leader:
+			s.persistPlayerIfUnchanged(player)
+		}
+	}
+	for _, room := range rooms {
+		room.mutex.RLock()
+		local := len(room.Players) > 0
+		room.mutex.RUnlock()
+
+		switch {
+		case local:
+			s.persistRoom(room)
+		case leader:
+			s.persistRoomIfUnchanged(room)
+		}
+	}
+}
+
+// persistPlayer 持久化玩家状态
+func (s *SimpleServer) persistPlayer(player *Player) {
+	if s.persistence == nil || !player.persistent() {
+		return
+	}
+
+	if err := s.persistence.SavePlayer(playerRecord(player)); err != nil {
+		player.log().Error("Failed to persist player", logging.Err(err))
+	}
+}
+
+// persistPlayerIfUnchanged 持久化玩家状态，玩家记录被其他实例修改过时不写入
+func (s *SimpleServer) persistPlayerIfUnchanged(player *Player) {
+	if s.persistence == nil || !player.persistent() {
+		return
+	}
+
+	if err := s.persistence.SavePlayerIfUnchanged(playerRecord(player)); err != nil {
+		player.log().Error("Failed to persist player", logging.Err(err))
+	}
+}
+
+// persistent 玩家状态是否写入存储，访客和数据已删除的玩家不持久化
+func (p *Player) persistent() bool {
+	p.profileMutex.Lock()
+	defer p.profileMutex.Unlock()
+
+	return !p.erased && !p.guest
+}
+
+// playerRecord 生成玩家的持久化记录，也用于导出玩家数据
+func playerRecord(player *Player) *PlayerRecord {
+	record := &PlayerRecord{
+		ID:         player.ID,
+		DID:        player.DID,
+		Nickname:   player.Nickname,
+		Position:   player.Position,
+		Level:      player.Level,
+		Experience: player.Experience,
+		Health:     player.Health,
+		MaxHealth:  player.MaxHealth,
+		LastSeen:   player.LastSeen,
+
+		MutedPlayers: player.mutedPlayerIDs(),
+	}
+	player.skillMutex.Lock()
+	record.SkillPointsSpent = player.skillPointsSpent
+	player.skillMutex.Unlock()
+	record.Skills = player.unlockedSkills()
+	record.ImportedCredentials = player.importedCredentialIDs()
+	player.importMutex.Lock()
+	record.Titles = append([]string(nil), player.Titles...)
+	player.importMutex.Unlock()
+	player.profileMutex.Lock()
+	record.Avatar = player.Avatar
+	record.Title = player.Title
+	record.Privacy = player.privacy
+	player.profileMutex.Unlock()
+	if room := player.Room; room != nil {
+		record.RoomID = room.ID
+	}
+	return record
+}
+
+// persistRoom 持久化房间状态，调用方不能持有 room.mutex
+func (s *SimpleServer) persistRoom(room *GameRoom) {
+	if s.persistence == nil {
+		return
+	}
+	saveRoom(room, s.persistence.SaveRoom)
+}
+
+// persistRoomIfUnchanged 持久化房间状态，房间记录被其他实例修改过时不写入，调用方不能持有 room.mutex
+func (s *SimpleServer) persistRoomIfUnchanged(room *GameRoom) {
+	if s.persistence == nil {
+		return
+	}
+	saveRoom(room, s.persistence.SaveRoomIfUnchanged)
+}
+
+// saveRoom 生成房间记录并交给 save 加入待写队列，序列化期间持有 room.mutex 读锁
+func saveRoom(room *GameRoom, save func(record *RoomRecord) error) {
+	room.mutex.RLock()
+	record := &RoomRecord{
+		ID:         room.ID,
+		Name:       room.Name,
+		GameID:     room.GameID,
+		MaxPlayers: room.MaxPlayers,
+		GameState:  room.GameState,
+		PlayerIDs:  make([]string, 0, len(room.Players)),
+		CreatedAt:  room.CreatedAt,
+
+		RequiredCredentials: room.RequiredCredentials,
+		RequiredProofs:      room.RequiredProofs,
+		MinLevel:            room.MinLevel,
+		PasswordHash:        room.passwordHash,
+		Teams:               room.Teams,
+		Mode:                room.Mode,
+		OwnerID:             room.OwnerID,
+		Group:               room.Group,
+	}
+	for playerID := range room.Players {
+		record.PlayerIDs = append(record.PlayerIDs, playerID)
+	}
+	err := save(record)
+	room.mutex.RUnlock()
+
+	if err != nil {
+		room.log().Error("Failed to persist room", logging.Err(err))
+	}
+}
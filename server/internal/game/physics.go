@@ -0,0 +1,347 @@
+package game
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MsgTypePhysics 移动物体和弹道的位置以及本 tick 发生的碰撞
+const MsgTypePhysics = "physics"
+
+// 碰撞类型
+const (
+	CollisionWall   = "wall"   // 撞到地图边界、墙体瓦片或阻挡物体
+	CollisionPlayer = "player" // 弹道命中玩家
+)
+
+// 发射弹道的错误
+var (
+	ErrTooManyProjectiles = errors.New("too many projectiles in room")
+	ErrInvalidProjectile  = errors.New("invalid projectile")
+)
+
+// PhysicsConfig 物理模拟配置
+type PhysicsConfig struct {
+	MaxProjectiles int           // 每个房间同时飞行的弹道上限
+	ProjectileTTL  time.Duration // 未设置 ExpiresAt 的弹道的存在时间
+	MaxSpeed       float64       // 弹道和移动物体的最大速度（像素/秒），超出时按比例缩小
+}
+
+// DefaultPhysicsConfig 返回默认物理模拟配置
+func DefaultPhysicsConfig() PhysicsConfig {
+	return PhysicsConfig{
+		MaxProjectiles: 256,
+		ProjectileTTL:  3 * time.Second,
+		MaxSpeed:       2000,
+	}
+}
+
+// SetPhysicsConfig 替换物理模拟配置，应在接受连接前调用
+func (s *SimpleServer) SetPhysicsConfig(config PhysicsConfig) {
+	s.physics = config
+}
+
+// Velocity 速度（像素/秒）
+type Velocity struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// limit 将速度限制在 maxSpeed 以内，maxSpeed 不大于 0 时不限制
+func (v Velocity) limit(maxSpeed float64) Velocity {
+	speed := math.Hypot(v.X, v.Y)
+	if maxSpeed <= 0 || speed <= maxSpeed {
+		return v
+	}
+	return Velocity{X: v.X * maxSpeed / speed, Y: v.Y * maxSpeed / speed}
+}
+
+// Projectile 弹道，按速度直线飞行，撞到阻挡、命中玩家或到期后消失
+type Projectile struct {
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"ownerId,omitempty"` // 发射的玩家，不会被自己的弹道命中
+	Position  Position  `json:"position"`
+	Velocity  Velocity  `json:"velocity"`
+	Radius    float64   `json:"radius"`
+	Damage    int       `json:"damage,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Collision 一次碰撞，Position 为碰撞发生的位置
+type Collision struct {
+	Type         string   `json:"type"`
+	ProjectileID string   `json:"projectileId,omitempty"`
+	ObjectID     string   `json:"objectId,omitempty"` // 撞到阻挡的移动物体
+	PlayerID     string   `json:"playerId,omitempty"` // 被命中的玩家
+	OwnerID      string   `json:"ownerId,omitempty"`
+	Damage       int      `json:"damage,omitempty"`
+	Position     Position `json:"position"`
+}
+
+// CollisionListener 游戏模式可以实现该接口处理碰撞，例如按弹道伤害扣除被命中玩家的生命值。
+// 与 GameMode 的其他回调一样在持有 room.mutex 时调用
+type CollisionListener interface {
+	OnCollision(room *GameRoom, collision *Collision)
+}
+
+// PhysicsUpdate physics 消息数据
+type PhysicsUpdate struct {
+	Tick        uint64              `json:"tick"`
+	Objects     map[string]Position `json:"objects,omitempty"`     // 本 tick 移动的地图物体
+	Projectiles []*Projectile       `json:"projectiles,omitempty"` // 飞行中的弹道
+	Removed     []string            `json:"removed,omitempty"`     // 本 tick 消失的弹道
+	Collisions  []*Collision        `json:"collisions,omitempty"`
+}
+
+// empty 判断本 tick 是否没有任何运动
+func (u *PhysicsUpdate) empty() bool {
+	return len(u.Objects) == 0 && len(u.Projectiles) == 0 && len(u.Removed) == 0 && len(u.Collisions) == 0
+}
+
+// roomPhysics 房间的物理状态，由 room.mutex 保护
+type roomPhysics struct {
+	config      PhysicsConfig
+	projectiles []*Projectile
+}
+
+// SpawnProjectile 在房间中发射弹道，未设置 ID 和 ExpiresAt 时自动生成，弹道从下一个 tick 开始飞行。
+// 调用方需持有 room.mutex，游戏模式可以在回调中直接调用
+func (r *GameRoom) SpawnProjectile(projectile *Projectile, now time.Time) error {
+	if !finite(projectile.Position.X, projectile.Position.Y, projectile.Velocity.X, projectile.Velocity.Y, projectile.Radius) ||
+		projectile.Radius < 0 {
+		return ErrInvalidProjectile
+	}
+	if len(r.physics.projectiles) >= r.physics.config.MaxProjectiles {
+		return ErrTooManyProjectiles
+	}
+
+	if projectile.ID == "" {
+		projectile.ID = uuid.New().String()
+	}
+	if projectile.ExpiresAt.IsZero() {
+		projectile.ExpiresAt = now.Add(r.physics.config.ProjectileTTL)
+	}
+	projectile.Velocity = projectile.Velocity.limit(r.physics.config.MaxSpeed)
+	r.physics.projectiles = append(r.physics.projectiles, projectile)
+	return nil
+}
+
+// Projectiles 返回飞行中的弹道，调用方需持有 room.mutex
+func (r *GameRoom) Projectiles() []*Projectile {
+	return r.physics.projectiles
+}
+
+// stepPhysics 按速度推进移动物体和弹道 dt 时间并检测碰撞，碰撞交给游戏模式处理。
+// 没有任何运动时返回 nil。调用方需持有 room.mutex
+func (r *GameRoom) stepPhysics(tick uint64, dt time.Duration, now time.Time, playerRadius float64) *PhysicsUpdate {
+	update := &PhysicsUpdate{Tick: tick, Objects: make(map[string]Position)}
+
+	if r.GameState.Map != nil {
+		r.moveObjects(update, dt.Seconds())
+	}
+	r.moveProjectiles(update, dt.Seconds(), now, playerRadius)
+
+	if listener, ok := r.mode.(CollisionListener); ok {
+		for _, collision := range update.Collisions {
+			listener.OnCollision(r, collision)
+		}
+	}
+
+	if update.empty() {
+		return nil
+	}
+	return update
+}
+
+// moveObjects 移动设置了速度的地图物体。物体会撞到地图边界、墙体瓦片和其他阻挡物体，
+// 撞到后停在原位，properties.bounce 为 true 的物体反向运动，其他物体停止
+func (r *GameRoom) moveObjects(update *PhysicsUpdate, seconds float64) {
+	gameMap := r.GameState.Map
+	maxSpeed := r.physics.config.MaxSpeed
+
+	moved := false
+	for _, object := range gameMap.Objects {
+		if object.Velocity == nil || *object.Velocity == (Velocity{}) {
+			continue
+		}
+		velocity := object.Velocity.limit(maxSpeed)
+		next := Position{X: object.Position.X + velocity.X*seconds, Y: object.Position.Y + velocity.Y*seconds}
+
+		minX, minY := next.X, next.Y
+		maxX, maxY := next.X+float64(object.Width), next.Y+float64(object.Height)
+		if minX < 0 || minY < 0 || maxX > float64(gameMap.Width) || maxY > float64(gameMap.Height) ||
+			gameMap.solidIn(minX, minY, maxX, maxY, object) {
+			update.Collisions = append(update.Collisions, &Collision{
+				Type:     CollisionWall,
+				ObjectID: object.ID,
+				Position: object.Position,
+			})
+			if bounce, _ := object.Properties["bounce"].(bool); bounce {
+				object.Velocity = &Velocity{X: -velocity.X, Y: -velocity.Y}
+			} else {
+				object.Velocity = nil
+			}
+			continue
+		}
+
+		object.Position = next
+		update.Objects[object.ID] = next
+		moved = true
+	}
+
+	if moved {
+		gameMap.ReindexObjects()
+	}
+}
+
+// moveProjectiles 推进弹道并检测命中，命中玩家或撞到阻挡的弹道在碰撞位置消失
+func (r *GameRoom) moveProjectiles(update *PhysicsUpdate, seconds float64, now time.Time, playerRadius float64) {
+	if len(r.physics.projectiles) == 0 {
+		return
+	}
+
+	// 按 ID 遍历玩家，同一时刻命中多名玩家时结果确定
+	playerIDs := make([]string, 0, len(r.Players))
+	for playerID := range r.Players {
+		playerIDs = append(playerIDs, playerID)
+	}
+	sort.Strings(playerIDs)
+
+	flying := r.physics.projectiles[:0]
+	for _, projectile := range r.physics.projectiles {
+		if !now.Before(projectile.ExpiresAt) {
+			update.Removed = append(update.Removed, projectile.ID)
+			continue
+		}
+
+		to := Position{
+			X: projectile.Position.X + projectile.Velocity.X*seconds,
+			Y: projectile.Position.Y + projectile.Velocity.Y*seconds,
+		}
+		if collision := r.traceProjectile(projectile, to, playerIDs, playerRadius); collision != nil {
+			update.Collisions = append(update.Collisions, collision)
+			update.Removed = append(update.Removed, projectile.ID)
+			continue
+		}
+
+		projectile.Position = to
+		flying = append(flying, projectile)
+	}
+	// 清除被移出的弹道的引用
+	for i := len(flying); i < len(r.physics.projectiles); i++ {
+		r.physics.projectiles[i] = nil
+	}
+	r.physics.projectiles = flying
+	update.Projectiles = flying
+}
+
+// traceProjectile 沿弹道从当前位置到 to 的线段检测碰撞，返回最早发生的碰撞，没有碰撞时返回 nil。
+// 检测的是整条线段，高速弹道不会穿过玩家或薄墙
+func (r *GameRoom) traceProjectile(projectile *Projectile, to Position, playerIDs []string, playerRadius float64) *Collision {
+	from := projectile.Position
+	var hit *Collision
+	earliest := math.Inf(1)
+
+	for _, playerID := range playerIDs {
+		if playerID == projectile.OwnerID {
+			continue
+		}
+		player := r.Players[playerID]
+		if t, ok := sweepCircle(from, to, player.Position, playerRadius+projectile.Radius); ok && t < earliest {
+			earliest = t
+			hit = &Collision{
+				Type:         CollisionPlayer,
+				ProjectileID: projectile.ID,
+				PlayerID:     playerID,
+				OwnerID:      projectile.OwnerID,
+				Damage:       projectile.Damage,
+				Position:     lerp(from, to, t),
+			}
+		}
+	}
+
+	if gameMap := r.GameState.Map; gameMap != nil {
+		if t, ok := gameMap.sweepSolid(from, to, projectile.Radius); ok && t < earliest {
+			hit = &Collision{
+				Type:         CollisionWall,
+				ProjectileID: projectile.ID,
+				OwnerID:      projectile.OwnerID,
+				Position:     lerp(from, to, t),
+			}
+		}
+	}
+	return hit
+}
+
+// sweepCircle 返回点从 from 移动到 to 的过程中首次进入以 center 为圆心、radius 为半径的圆时的比例 t ∈ [0, 1]
+func sweepCircle(from, to, center Position, radius float64) (float64, bool) {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	fx, fy := from.X-center.X, from.Y-center.Y
+
+	c := fx*fx + fy*fy - radius*radius
+	if c <= 0 {
+		return 0, true
+	}
+	a := dx*dx + dy*dy
+	if a == 0 {
+		return 0, false
+	}
+	b := 2 * (fx*dx + fy*dy)
+	discriminant := b*b - 4*a*c
+	if discriminant < 0 {
+		return 0, false
+	}
+	t := (-b - math.Sqrt(discriminant)) / (2 * a)
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// sweepSolid 沿线段按不超过弹道半径（至少 4 像素）的步长检测阻挡，返回首次阻挡处的比例 t
+func (m *GameMap) sweepSolid(from, to Position, radius float64) (float64, bool) {
+	step := math.Max(radius, 4)
+	steps := int(math.Ceil(math.Hypot(to.X-from.X, to.Y-from.Y) / step))
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		pos := lerp(from, to, t)
+		if !m.inBounds(pos, 0) || m.solidIn(pos.X-radius, pos.Y-radius, pos.X+radius, pos.Y+radius, nil) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// solidIn 判断矩形区域是否与墙体瓦片或 ignore 之外的阻挡物体重叠
+func (m *GameMap) solidIn(minX, minY, maxX, maxY float64, ignore *MapObject) bool {
+	if m.wallIn(minX, minY, maxX, maxY) {
+		return true
+	}
+	for _, object := range m.objects().query(minX, minY, maxX, maxY) {
+		if object == ignore || !object.isSolid() {
+			continue
+		}
+		if maxX > object.Position.X && minX < object.Position.X+float64(object.Width) &&
+			maxY > object.Position.Y && minY < object.Position.Y+float64(object.Height) {
+			return true
+		}
+	}
+	return false
+}
+
+func lerp(from, to Position, t float64) Position {
+	return Position{X: from.X + (to.X-from.X)*t, Y: from.Y + (to.Y-from.Y)*t}
+}
+
+func finite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,274 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/logging"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// ExportSuffix 玩家数据导出接口在玩家资料路径后的后缀：ProfilePath + "{did}" + ExportSuffix
+const ExportSuffix = "/export"
+
+// 删除玩家数据的方式，两种方式都保留对局分数等汇总统计
+const (
+	// ErasureAnonymize 聊天记录以假名署名保留，其余个人数据删除
+	ErasureAnonymize = "anonymize"
+	// ErasurePurge 聊天记录同样删除
+	ErasurePurge = "purge"
+)
+
+// ErrUnknownErasureMode 删除方式不是 anonymize 或 purge
+var ErrUnknownErasureMode = errors.New("unknown erasure mode")
+
+// erasedNickname 假名在对局结果和匿名聊天记录中显示的昵称
+const erasedNickname = "Deleted player"
+
+// PlayerArchive 玩家数据导出。Proof 是服务器颁发者 DID 对其余字段的签名，
+// 可以用颁发者 DID 文档中的密钥按凭证证明的方式验证
+type PlayerArchive struct {
+	DID          string                 `json:"did"`
+	ExportedAt   time.Time              `json:"exportedAt"`
+	DIDDocument  *pkgdid.DIDDocument    `json:"didDocument,omitempty"`
+	DIDHistory   []*did.DIDHistoryEntry `json:"didHistory,omitempty"`
+	Credentials  []*vc.SimpleCredential `json:"credentials"`
+	Profile      *Profile               `json:"profile"`
+	State        *PlayerRecord          `json:"state"`
+	Inventory    *InventoryRecord       `json:"inventory,omitempty"`
+	Achievements []AchievementProgress  `json:"achievements"`
+	Matches      []*MatchResult         `json:"matches"`
+	Guild        *Guild                 `json:"guild,omitempty"`
+	Chat         []ChatMessage          `json:"chat"`
+	Inbox        []*Mail                `json:"inbox"`
+	Proof        *vc.Proof              `json:"proof,omitempty"`
+}
+
+// ErasureReport 删除玩家数据的结果
+type ErasureReport struct {
+	DID            string `json:"did"`
+	Mode           string `json:"mode"`
+	Pseudonym      string `json:"pseudonym"` // 对局结果和匿名聊天记录中替代玩家的 ID
+	Credentials    int    `json:"credentials"`
+	DIDDeactivated bool   `json:"didDeactivated"`
+	Matches        int    `json:"matches"`
+	ChatMessages   int    `json:"chatMessages"`
+	Mails          int    `json:"mails"`
+	Achievements   int    `json:"achievements"`
+	LeftGuild      bool   `json:"leftGuild"`
+}
+
+// ExportPlayerData 汇总玩家在各模块中的数据并签名
+func (s *SimpleServer) ExportPlayerData(ctx context.Context, playerDID string) (*PlayerArchive, error) {
+	player := s.findPlayerByDID(playerDID)
+	if player == nil {
+		return nil, ErrPlayerNotFound
+	}
+
+	profile, err := s.Profile(playerDID, true)
+	if err != nil {
+		return nil, err
+	}
+	archive := &PlayerArchive{
+		DID:          playerDID,
+		ExportedAt:   time.Now().UTC(),
+		Credentials:  s.vcService.GetPlayerCredentials(playerDID),
+		Profile:      profile,
+		State:        playerRecord(player),
+		Achievements: s.achievements.PlayerProgress(playerDID),
+		Matches:      s.results.PlayerMatches(playerDID),
+	}
+	if player.Inventory != nil {
+		archive.Inventory = player.Inventory.Snapshot()
+	}
+
+	// did:key 等外部 DID 没有保存在本服务中，文档由解析得到，没有历史版本
+	if resolved, err := s.didService.ResolveDIDContext(ctx, playerDID); err == nil {
+		archive.DIDDocument = resolved.DIDDoc
+	}
+	if history, err := s.didService.GetDIDHistory(playerDID); err == nil {
+		archive.DIDHistory = history
+	}
+
+	guild, err := s.guilds.MemberGuild(playerDID)
+	switch {
+	case err == nil:
+		archive.Guild = guild
+	case !errors.Is(err, ErrNotInGuild):
+		return nil, fmt.Errorf("load guild: %w", err)
+	}
+	if archive.Chat, err = s.chatHistory.PlayerMessages(player.ID); err != nil {
+		return nil, fmt.Errorf("load chat messages: %w", err)
+	}
+	if archive.Inbox, _, err = s.inbox.Messages(playerDID, false); err != nil {
+		return nil, fmt.Errorf("load inbox: %w", err)
+	}
+
+	if archive.Proof, err = s.vcService.SignDocument(archive); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// ErasePlayerData 应玩家请求删除其个人数据：断开玩家的连接并使会话令牌失效，离开房间和公会，
+// 删除收件箱、成就进度、凭证和保存的玩家状态，停用本服务保存的 DID。对局结果中的玩家替换为假名，
+// 排行榜汇总不变；聊天记录按 mode 以假名署名保留或删除。审计日志和处罚记录不受影响
+func (s *SimpleServer) ErasePlayerData(ctx context.Context, playerDID, mode string) (*ErasureReport, error) {
+	switch mode {
+	case "":
+		mode = ErasureAnonymize
+	case ErasureAnonymize, ErasurePurge:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownErasureMode, mode)
+	}
+
+	player := s.findPlayerByDID(playerDID)
+	if player == nil {
+		return nil, ErrPlayerNotFound
+	}
+
+	// 先停止持久化，断开连接时的状态保存不会重新写入玩家记录
+	player.profileMutex.Lock()
+	player.erased = true
+	player.profileMutex.Unlock()
+
+	for _, entry := range s.connections.list(playerDID) {
+		closeFrame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "player data erased")
+		writeClose(entry.conn, closeFrame, time.Now().Add(s.heartbeat.WriteWait))
+		entry.conn.Close()
+	}
+	s.sessions.revoke(player.ID)
+	s.matchmaker.Dequeue(player.ID)
+	s.cancelQueuedJoin(player)
+	s.leaveRoom(player)
+
+	pseudonym := uuid.New().String()
+	report := &ErasureReport{DID: playerDID, Mode: mode, Pseudonym: pseudonym}
+
+	guild, _, err := s.guilds.Leave(playerDID)
+	switch {
+	case err == nil:
+		report.LeftGuild = true
+		s.sendToGuild(guild, Message{
+			Type: MsgTypeGuild,
+			Data: map[string]interface{}{
+				"action": GuildLeave,
+				"guild":  guild,
+			},
+			Timestamp: time.Now(),
+		}, "")
+	case !errors.Is(err, ErrNotInGuild):
+		return nil, fmt.Errorf("leave guild: %w", err)
+	}
+
+	if report.Mails, err = s.inbox.Delete(playerDID, nil); err != nil {
+		return nil, fmt.Errorf("delete inbox: %w", err)
+	}
+	if report.Achievements, err = s.achievements.ErasePlayer(playerDID); err != nil {
+		return nil, fmt.Errorf("erase achievements: %w", err)
+	}
+	report.Matches, err = s.results.ReplacePlayer(playerDID, PlayerResult{
+		DID:      "urn:uuid:" + pseudonym,
+		PlayerID: pseudonym,
+		Nickname: erasedNickname,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pseudonymize match results: %w", err)
+	}
+
+	chatPseudonym := pseudonym
+	if mode == ErasurePurge {
+		chatPseudonym = ""
+	}
+	if report.ChatMessages, err = s.chatHistory.ErasePlayer(player.ID, chatPseudonym, erasedNickname); err != nil {
+		return nil, fmt.Errorf("erase chat messages: %w", err)
+	}
+
+	if report.Credentials, err = s.vcService.EraseSubjectCredentials(ctx, playerDID); err != nil {
+		return nil, fmt.Errorf("erase credentials: %w", err)
+	}
+	if report.DIDDeactivated, err = s.didService.EraseDID(ctx, playerDID); err != nil {
+		return nil, fmt.Errorf("erase DID: %w", err)
+	}
+
+	if s.persistence != nil {
+		s.persistence.DeletePlayer(player.ID)
+	}
+	s.players.remove(player)
+
+	slog.Info("Player data erased", logging.KeyPlayerDID, playerDID, "mode", mode, "pseudonym", pseudonym)
+	return report, nil
+}
+
+// handleExport 处理 GET /api/player/{did}/export，只有玩家本人可以导出
+func (s *SimpleServer) handleExport(w http.ResponseWriter, r *http.Request, playerDID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requireProfileOwner(w, r, playerDID) {
+		return
+	}
+
+	archive, err := s.ExportPlayerData(r.Context(), playerDID)
+	switch {
+	case errors.Is(err, ErrPlayerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to export player data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="player-data.json"`)
+	json.NewEncoder(w).Encode(archive)
+}
+
+// handleErase 处理 DELETE /api/player/{did}?mode=anonymize|purge，只有玩家本人可以删除
+func (s *SimpleServer) handleErase(w http.ResponseWriter, r *http.Request, playerDID string) {
+	if !s.requireProfileOwner(w, r, playerDID) {
+		return
+	}
+
+	report, err := s.ErasePlayerData(r.Context(), playerDID, r.URL.Query().Get("mode"))
+	switch {
+	case errors.Is(err, ErrPlayerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrUnknownErasureMode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to erase player data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// requireProfileOwner 确认请求由该玩家发出，否则返回 401 或 403
+func (s *SimpleServer) requireProfileOwner(w http.ResponseWriter, r *http.Request, playerDID string) bool {
+	if s.profileOwner(r, playerDID) {
+		return true
+	}
+	if authenticated, ok := didauth.DIDFromContext(r.Context()); ok {
+		http.Error(w, "cannot access the data of "+playerDID+" as "+authenticated, http.StatusForbidden)
+		return false
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="player"`)
+	http.Error(w, "DID authentication or session token of the player is required", http.StatusUnauthorized)
+	return false
+}
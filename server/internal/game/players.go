@@ -0,0 +1,150 @@
+package game
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// playerShardCount 玩家登记表每个索引的分片数
+const playerShardCount = 32
+
+// playerShard 玩家登记表的一个分片
+type playerShard struct {
+	players map[string]*Player
+	mutex   sync.RWMutex
+}
+
+// load 返回分片中的玩家
+func (p *playerShard) load(key string) (*Player, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	player, exists := p.players[key]
+	return player, exists
+}
+
+// store 登记分片中的玩家
+func (p *playerShard) store(key string, player *Player) {
+	p.mutex.Lock()
+	p.players[key] = player
+	p.mutex.Unlock()
+}
+
+// playerRegistry 本实例上的玩家，按玩家 ID 和 DID 分别建立索引。两个索引各自分片，
+// 查找只读锁定键所在的分片，不经过 roomMutex，不同玩家的查找和创建互不阻塞。
+// 同时修改两个索引时先锁 DID 分片再锁 ID 分片；分片锁是最内层的锁，持有时不获取其他锁。
+// 离线玩家保留到重新连接，只有应玩家请求删除数据时才移除
+type playerRegistry struct {
+	byID  [playerShardCount]playerShard
+	byDID [playerShardCount]playerShard
+}
+
+// newPlayerRegistry 创建空的玩家登记表
+func newPlayerRegistry() *playerRegistry {
+	r := &playerRegistry{}
+	for i := range r.byID {
+		r.byID[i].players = make(map[string]*Player)
+		r.byDID[i].players = make(map[string]*Player)
+	}
+	return r
+}
+
+// shardFor 返回键所在的分片
+func shardFor(shards *[playerShardCount]playerShard, key string) *playerShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &shards[h.Sum32()%playerShardCount]
+}
+
+// get 按玩家 ID 查找玩家
+func (r *playerRegistry) get(playerID string) (*Player, bool) {
+	return shardFor(&r.byID, playerID).load(playerID)
+}
+
+// getByDID 按 DID 查找玩家，不存在时返回 nil
+func (r *playerRegistry) getByDID(playerDID string) *Player {
+	player, _ := shardFor(&r.byDID, playerDID).load(playerDID)
+	return player
+}
+
+// getOrCreate 返回 DID 对应的玩家，不存在时用 create 创建并登记。
+// 同一 DID 的并发调用只会创建一个玩家，create 在持有 DID 分片锁时调用，不能获取其他锁
+func (r *playerRegistry) getOrCreate(playerDID string, create func() *Player) *Player {
+	shard := shardFor(&r.byDID, playerDID)
+	if player, exists := shard.load(playerDID); exists {
+		return player
+	}
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if player, exists := shard.players[playerDID]; exists {
+		return player
+	}
+	player := create()
+	shard.players[playerDID] = player
+	shardFor(&r.byID, player.ID).store(player.ID, player)
+	return player
+}
+
+// put 登记玩家，替换同 ID 或同 DID 的已有玩家，用于从存储恢复状态
+func (r *playerRegistry) put(player *Player) {
+	shard := shardFor(&r.byDID, player.DID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	shard.players[player.DID] = player
+	shardFor(&r.byID, player.ID).store(player.ID, player)
+}
+
+// remove 注销玩家，之后同一 DID 认证时创建新的玩家
+func (r *playerRegistry) remove(player *Player) {
+	shard := shardFor(&r.byDID, player.DID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if shard.players[player.DID] == player {
+		delete(shard.players, player.DID)
+	}
+	byID := shardFor(&r.byID, player.ID)
+	byID.mutex.Lock()
+	delete(byID.players, player.ID)
+	byID.mutex.Unlock()
+}
+
+// rekey 将玩家改登记到 newDID 下并修改玩家的 DID，newDID 已有玩家时返回 false。
+// 两个 DID 分片依次锁定，期间玩家短暂地同时登记在两个 DID 下
+func (r *playerRegistry) rekey(player *Player, newDID string) bool {
+	shard := shardFor(&r.byDID, newDID)
+	shard.mutex.Lock()
+	if _, exists := shard.players[newDID]; exists {
+		shard.mutex.Unlock()
+		return false
+	}
+	oldDID := player.DID
+	player.DID = newDID
+	shard.players[newDID] = player
+	shard.mutex.Unlock()
+
+	old := shardFor(&r.byDID, oldDID)
+	old.mutex.Lock()
+	if old.players[oldDID] == player {
+		delete(old.players, oldDID)
+	}
+	old.mutex.Unlock()
+	return true
+}
+
+// all 返回所有玩家的快照，遍历期间其他分片仍可并发读写
+func (r *playerRegistry) all() []*Player {
+	var players []*Player
+	for i := range r.byID {
+		shard := &r.byID[i]
+		shard.mutex.RLock()
+		for _, player := range shard.players {
+			players = append(players, player)
+		}
+		shard.mutex.RUnlock()
+	}
+	return players
+}
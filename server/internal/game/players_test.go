@@ -0,0 +1,112 @@
+package game
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPlayerRegistryCreatesOnePlayerPerDID(t *testing.T) {
+	registry := newPlayerRegistry()
+	const workers = 16
+	const dids = 64
+
+	// 同一 DID 的并发创建只登记一个玩家，不同 DID 的创建互不影响
+	created := make([][]*Player, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < dids; i++ {
+				playerDID := fmt.Sprintf("did:player:race%08d", i)
+				created[w] = append(created[w], registry.getOrCreate(playerDID, func() *Player {
+					return newPlayer(playerDID)
+				}))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i := 0; i < dids; i++ {
+		player := created[0][i]
+		for w := 1; w < workers; w++ {
+			if created[w][i] != player {
+				t.Fatalf("DID %s resolved to different players", player.DID)
+			}
+		}
+		if found, exists := registry.get(player.ID); !exists || found != player {
+			t.Errorf("player %s is not indexed by ID", player.ID)
+		}
+	}
+	if count := len(registry.all()); count != dids {
+		t.Errorf("registry holds %d players, want %d", count, dids)
+	}
+}
+
+func TestPlayerRegistryRekeyAndRemoveUnderLookups(t *testing.T) {
+	registry := newPlayerRegistry()
+	const players = 32
+
+	var all []*Player
+	for i := 0; i < players; i++ {
+		playerDID := fmt.Sprintf("did:player:guest%08d", i)
+		all = append(all, registry.getOrCreate(playerDID, func() *Player { return newPlayer(playerDID) }))
+	}
+
+	// 升级（改登记 DID）和删除与按 DID、ID 的查找及遍历并发进行
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for i, player := range all {
+					registry.getByDID(fmt.Sprintf("did:player:guest%08d", i))
+					registry.get(player.ID)
+				}
+				registry.all()
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for i, player := range all {
+		writers.Add(1)
+		go func(i int, player *Player) {
+			defer writers.Done()
+			if i%2 == 0 {
+				registry.remove(player)
+				return
+			}
+			if !registry.rekey(player, fmt.Sprintf("did:player:member%07d", i)) {
+				t.Errorf("rekey of player %d failed", i)
+			}
+		}(i, player)
+	}
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	for i, player := range all {
+		_, indexed := registry.get(player.ID)
+		if i%2 == 0 {
+			if indexed || registry.getByDID(fmt.Sprintf("did:player:guest%08d", i)) != nil {
+				t.Errorf("removed player %d is still registered", i)
+			}
+			continue
+		}
+		if !indexed || registry.getByDID(fmt.Sprintf("did:player:member%07d", i)) != player {
+			t.Errorf("upgraded player %d is not registered under its new DID", i)
+		}
+		if registry.getByDID(fmt.Sprintf("did:player:guest%08d", i)) != nil {
+			t.Errorf("upgraded player %d is still registered under its guest DID", i)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+	internalvc "github.com/czh0526/game/server/internal/vc"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// presentationTimeout 表述请求的有效期
+const presentationTimeout = 2 * time.Minute
+
+// presentationRequest 等待玩家响应的表述请求
+type presentationRequest struct {
+	Challenge       string
+	Domain          string
+	RoomID          string
+	CredentialTypes []string
+	ExpiresAt       time.Time
+}
+
+// requestPresentation 向玩家发送 presentation_request，要求出示进入房间所需的凭证
+func (s *SimpleServer) requestPresentation(player *Player, room *GameRoom) {
+	request := &presentationRequest{
+		Challenge:       uuid.New().String(),
+		Domain:          "room:" + room.ID,
+		RoomID:          room.ID,
+		CredentialTypes: room.RequiredCredentials,
+		ExpiresAt:       time.Now().Add(presentationTimeout),
+	}
+	player.pendingPresentation = request
+
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypePresentationRequest,
+		PlayerID: player.ID,
+		RoomID:   room.ID,
+		Data: map[string]interface{}{
+			"challenge":       request.Challenge,
+			"domain":          request.Domain,
+			"credentialTypes": request.CredentialTypes,
+			"expiresAt":       request.ExpiresAt,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// handlePresentation 处理玩家对表述请求的响应，验证通过后加入房间
+func (s *SimpleServer) handlePresentation(player *Player, payload *PresentationPayload) {
+	request := player.pendingPresentation
+	if request == nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeNoPendingRequest, "error.no_pending_request", nil)
+		return
+	}
+
+	if time.Now().After(request.ExpiresAt) {
+		player.pendingPresentation = nil
+		s.sendLocalizedErrorToPlayer(player, ErrCodeNoPendingRequest, "error.request_expired", nil)
+		return
+	}
+
+	presentation, err := vc.PresentationFromJSON(payload.Presentation)
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeInvalidPayload, "error.invalid_presentation", i18n.Params{"error": err})
+		return
+	}
+
+	if presentation.Holder != player.DID {
+		s.sendEntryRejection(player, ErrCodePresentationRejected, request.RoomID, &EntryRejection{
+			Reason:  RejectPresentationInvalid,
+			Message: "Presentation holder does not match player DID",
+		})
+		return
+	}
+
+	valid, message := s.vcService.VerifyPresentationContext(player.traceContext(), presentation, request.Challenge, request.Domain)
+	if !valid {
+		s.sendEntryRejection(player, ErrCodePresentationRejected, request.RoomID, &EntryRejection{
+			Reason:  RejectPresentationInvalid,
+			Message: fmt.Sprintf("Presentation rejected: %s", message),
+		})
+		return
+	}
+
+	var missing []string
+	for _, credType := range request.CredentialTypes {
+		if !presentation.HasCredentialType(credType) {
+			missing = append(missing, credType)
+		}
+	}
+	if len(missing) > 0 {
+		s.sendEntryRejection(player, ErrCodePresentationRejected, request.RoomID, &EntryRejection{
+			Reason:  RejectCredentialMissing,
+			Message: fmt.Sprintf("Missing required credential: %s", strings.Join(missing, ", ")),
+			Details: map[string]interface{}{"missingCredentials": missing},
+		})
+		return
+	}
+
+	// 挑战值只能使用一次
+	player.pendingPresentation = nil
+
+	s.roomMutex.RLock()
+	room, exists := s.rooms[request.RoomID]
+	s.roomMutex.RUnlock()
+	if !exists {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeRoomNotFound, "error.room_not_found", nil)
+		return
+	}
+
+	room.log().Info("Player presented credentials", logging.KeyPlayerDID, player.DID, logging.KeyPlayerID, player.ID)
+	s.admitPlayer(player, room)
+}
+
+// admitPlayer 房间要求 Present Proof 证明时先通过 DIDComm 向玩家钱包请求表述，否则直接加入房间
+func (s *SimpleServer) admitPlayer(player *Player, room *GameRoom) {
+	if len(room.RequiredProofs) == 0 {
+		s.completeJoinRoom(player, room)
+		return
+	}
+
+	_, err := s.vcService.RequestPresentation(player.traceContext(), player.DID, "room:"+room.ID, room.RequiredProofs, func(result *internalvc.ProofResult) {
+		s.handleProofResult(player, room.ID, result)
+	})
+	if err != nil {
+		s.sendEntryRejection(player, ErrCodePresentationRejected, room.ID, &EntryRejection{
+			Reason:  RejectPresentationInvalid,
+			Message: fmt.Sprintf("Failed to request proof: %v", err),
+		})
+	}
+}
+
+// handleProofResult 处理 Present Proof 交换的结果，证明通过后加入房间
+func (s *SimpleServer) handleProofResult(player *Player, roomID string, result *internalvc.ProofResult) {
+	if !result.Verified {
+		rejection := &EntryRejection{
+			Reason:  RejectPresentationInvalid,
+			Message: fmt.Sprintf("Proof rejected: %s", result.Message),
+		}
+		if len(result.Unsatisfied) > 0 {
+			rejection.Reason = RejectRequirementNotMet
+			rejection.Details = map[string]interface{}{"unmetRequirements": result.Unsatisfied}
+		}
+		s.sendEntryRejection(player, ErrCodePresentationRejected, roomID, rejection)
+		return
+	}
+
+	s.roomMutex.RLock()
+	room, exists := s.rooms[roomID]
+	s.roomMutex.RUnlock()
+	if !exists {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeRoomNotFound, "error.room_not_found", nil)
+		return
+	}
+
+	room.log().Info("Player proved credential requirements", logging.KeyPlayerDID, player.DID, "thread_id", result.ThreadID)
+	s.completeJoinRoom(player, room)
+}
@@ -0,0 +1,278 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/czh0526/game/server/internal/didauth"
+)
+
+// ProfilePath 玩家资料接口的路由前缀，后接玩家 DID
+const ProfilePath = "/api/player/"
+
+// 资料字段限制
+const (
+	minNicknameLength = 2
+	maxNicknameLength = 20
+	maxAvatarLength   = 256
+)
+
+// 资料错误
+var (
+	ErrPlayerNotFound = errors.New("player not found")
+	ErrTitleNotEarned = errors.New("title is not earned by the player")
+)
+
+// PrivacySettings 玩家资料的隐私设置，只影响其他人通过资料接口看到的内容
+type PrivacySettings struct {
+	HideLevel  bool `json:"hideLevel,omitempty"`  // 隐藏等级和经验
+	HideTitles bool `json:"hideTitles,omitempty"` // 隐藏已获得的称号列表，当前佩戴的称号仍然可见
+	HideStatus bool `json:"hideStatus,omitempty"` // 隐藏在线状态、所在房间和最后在线时间
+}
+
+// Profile 玩家资料
+type Profile struct {
+	DID        string           `json:"did"`
+	PlayerID   string           `json:"playerId"`
+	Nickname   string           `json:"nickname"`
+	Avatar     string           `json:"avatar,omitempty"`
+	Title      string           `json:"title,omitempty"`
+	Titles     []string         `json:"titles,omitempty"` // 可以佩戴的称号
+	Level      int              `json:"level,omitempty"`
+	Experience int              `json:"experience,omitempty"`
+	Status     string           `json:"status,omitempty"`
+	RoomID     string           `json:"roomId,omitempty"`
+	LastSeen   *time.Time       `json:"lastSeen,omitempty"`
+	Privacy    *PrivacySettings `json:"privacy,omitempty"` // 只返回给玩家本人
+}
+
+// ProfileUpdate PATCH 请求体，省略的字段保持不变
+type ProfileUpdate struct {
+	Nickname *string          `json:"nickname,omitempty"`
+	Avatar   *string          `json:"avatar,omitempty"` // 头像预设 ID 或 http(s) 图片地址，空字符串清除
+	Title    *string          `json:"title,omitempty"`  // 已获得的称号之一，空字符串清除
+	Privacy  *PrivacySettings `json:"privacy,omitempty"`
+}
+
+// Validate 校验并规范化资料更新
+func (u *ProfileUpdate) Validate() error {
+	v := &ValidationError{}
+	if u.Nickname != nil {
+		nickname := strings.TrimSpace(*u.Nickname)
+		length := utf8.RuneCountInString(nickname)
+		switch {
+		case length < minNicknameLength || length > maxNicknameLength:
+			v.add("nickname", "must be %d-%d characters", minNicknameLength, maxNicknameLength)
+		case strings.IndexFunc(nickname, unicode.IsControl) >= 0:
+			v.add("nickname", "must not contain control characters")
+		}
+		u.Nickname = &nickname
+	}
+	if u.Avatar != nil && *u.Avatar != "" {
+		avatar := *u.Avatar
+		if len(avatar) > maxAvatarLength {
+			v.add("avatar", "must be at most %d bytes", maxAvatarLength)
+		} else if strings.Contains(avatar, "://") {
+			if parsed, err := url.Parse(avatar); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				v.add("avatar", "must be an http(s) URL or a preset ID")
+			}
+		} else if strings.IndexFunc(avatar, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+			v.add("avatar", "preset ID must not contain spaces")
+		}
+	}
+	return v.err()
+}
+
+// earnedTitles 返回玩家可以佩戴的称号：导入凭证获得的称号，以及颁发给玩家且仍然有效的成就凭证中的成就
+func (s *SimpleServer) earnedTitles(player *Player) []string {
+	player.importMutex.Lock()
+	titles := append([]string(nil), player.Titles...)
+	player.importMutex.Unlock()
+
+	for _, credential := range s.vcService.GetPlayerCredentials(player.DID) {
+		achievement := credential.CredentialSubject.Achievement
+		if achievement == "" || containsString(titles, achievement) {
+			continue
+		}
+		if valid, _ := s.vcService.VerifyCredential(credential); valid {
+			titles = append(titles, achievement)
+		}
+	}
+	return titles
+}
+
+// Profile 返回玩家资料，owner 为 false 时按玩家的隐私设置隐藏字段
+func (s *SimpleServer) Profile(playerDID string, owner bool) (*Profile, error) {
+	player := s.findPlayerByDID(playerDID)
+	if player == nil {
+		return nil, ErrPlayerNotFound
+	}
+
+	player.profileMutex.Lock()
+	profile := &Profile{
+		DID:      player.DID,
+		PlayerID: player.ID,
+		Nickname: player.Nickname,
+		Avatar:   player.Avatar,
+		Title:    player.Title,
+	}
+	privacy := player.privacy
+	player.profileMutex.Unlock()
+
+	if owner {
+		profile.Privacy = &privacy
+	}
+	if owner || !privacy.HideLevel {
+		profile.Level = player.Level
+		profile.Experience = player.Experience
+	}
+	if owner || !privacy.HideTitles {
+		profile.Titles = s.earnedTitles(player)
+	}
+	if owner || !privacy.HideStatus {
+		lastSeen := player.LastSeen
+		profile.Status = player.Status
+		profile.LastSeen = &lastSeen
+		if room := player.Room; room != nil {
+			profile.RoomID = room.ID
+		}
+	}
+	return profile, nil
+}
+
+// UpdateProfile 修改玩家资料，持久化后向玩家所在房间广播 player_update
+func (s *SimpleServer) UpdateProfile(playerDID string, update *ProfileUpdate) (*Profile, error) {
+	player := s.findPlayerByDID(playerDID)
+	if player == nil {
+		return nil, ErrPlayerNotFound
+	}
+	if update.Title != nil && *update.Title != "" && !containsString(s.earnedTitles(player), *update.Title) {
+		return nil, ErrTitleNotEarned
+	}
+
+	// 昵称随房间状态同步，需要在房间锁内修改
+	room := player.Room
+	if room != nil {
+		room.mutex.Lock()
+	}
+	player.profileMutex.Lock()
+	if update.Nickname != nil {
+		player.Nickname = *update.Nickname
+	}
+	if update.Avatar != nil {
+		player.Avatar = *update.Avatar
+	}
+	if update.Title != nil {
+		player.Title = *update.Title
+	}
+	if update.Privacy != nil {
+		player.privacy = *update.Privacy
+	}
+	player.profileMutex.Unlock()
+	if room != nil {
+		room.mutex.Unlock()
+	}
+
+	s.persistPlayer(player)
+	if room != nil {
+		s.broadcastToRoom(room, Message{
+			Type:     MsgTypePlayerUpdate,
+			PlayerID: player.ID,
+			RoomID:   room.ID,
+			Data: map[string]interface{}{
+				"action": "profile",
+				"player": player,
+			},
+			Timestamp: time.Now(),
+		}, "")
+	}
+	player.log().Info("Player profile updated", "nickname", player.Nickname)
+
+	return s.Profile(playerDID, true)
+}
+
+// profileOwner 判断请求是否由该玩家发出：经过 DID 认证中间件认证为该 DID，
+// 或带有该玩家的会话令牌（Authorization: Bearer <sessionToken>）
+func (s *SimpleServer) profileOwner(r *http.Request, playerDID string) bool {
+	if authenticated, ok := didauth.DIDFromContext(r.Context()); ok {
+		return authenticated == playerDID
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return false
+	}
+	playerID, exists := s.sessions.lookup(token)
+	if !exists {
+		return false
+	}
+
+	player, exists := s.players.get(playerID)
+	return exists && player.DID == playerDID
+}
+
+// HandleProfile 处理 /api/player/{did}：GET 返回玩家资料，PATCH 凭 DID 认证或会话令牌修改自己的资料，
+// DELETE 删除自己的个人数据；/api/player/{did}/export 导出自己的全部数据
+func (s *SimpleServer) HandleProfile(w http.ResponseWriter, r *http.Request) {
+	playerDID := strings.TrimPrefix(r.URL.Path, ProfilePath)
+	exportPath := strings.HasSuffix(playerDID, ExportSuffix)
+	playerDID = strings.TrimSuffix(playerDID, ExportSuffix)
+	if playerDID == "" {
+		http.Error(w, "did is required", http.StatusBadRequest)
+		return
+	}
+	if exportPath {
+		s.handleExport(w, r, playerDID)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		s.handleErase(w, r, playerDID)
+		return
+	}
+	owner := s.profileOwner(r, playerDID)
+
+	var (
+		profile *Profile
+		err     error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		profile, err = s.Profile(playerDID, owner)
+	case http.MethodPatch:
+		if !s.requireProfileOwner(w, r, playerDID) {
+			return
+		}
+		var update ProfileUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := update.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		profile, err = s.UpdateProfile(playerDID, &update)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrPlayerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
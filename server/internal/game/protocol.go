@@ -0,0 +1,146 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/i18n"
+)
+
+// ProtocolVersion 服务器实现的协议版本。客户端在 auth、guest_auth 和 resume 中声明自己的版本，
+// 服务器以两者中较低的版本通信；未声明版本的客户端按版本 1 处理
+const ProtocolVersion = 1
+
+// 可选的协议能力，客户端认证时声明支持的能力，服务器在回复中返回本连接启用的能力
+const (
+	// CapabilityBinary 以 msgpack 二进制帧收发消息，由握手时选择的子协议决定
+	CapabilityBinary = "binary"
+	// CapabilityDeltaSync 接收基于版本的 state_sync 增量并以 state_ack 确认，
+	// 不支持的客户端每次都收到完整快照，不需要确认
+	CapabilityDeltaSync = "delta_sync"
+	// CapabilityCompression 超过阈值的消息以 permessage-deflate 压缩，由握手时的扩展协商决定
+	CapabilityCompression = "compression"
+)
+
+// legacyCapabilities 未声明能力的旧客户端按此处理，与引入能力协商之前的行为一致
+var legacyCapabilities = []string{CapabilityDeltaSync}
+
+// maxCapabilities 客户端一次最多声明的能力数
+const maxCapabilities = 16
+
+// ProtocolConfig 协议版本策略
+type ProtocolConfig struct {
+	// MinVersion 接受的最低客户端协议版本，更旧的客户端认证时收到 upgrade_required 错误
+	MinVersion int
+}
+
+// DefaultProtocolConfig 返回默认协议版本策略：接受所有版本
+func DefaultProtocolConfig() ProtocolConfig {
+	return ProtocolConfig{MinVersion: 1}
+}
+
+// SetProtocolConfig 替换协议版本策略，应在接受连接前调用
+func (s *SimpleServer) SetProtocolConfig(config ProtocolConfig) error {
+	if config.MinVersion < 1 || config.MinVersion > ProtocolVersion {
+		return fmt.Errorf("minimum protocol version must be between 1 and %d, got %d", ProtocolVersion, config.MinVersion)
+	}
+	s.protocol = config
+	return nil
+}
+
+// ProtocolOffer 客户端在 auth、guest_auth 和 resume 中声明的协议版本和能力。
+// Capabilities 为 nil（未发送）时按旧客户端处理，空列表表示不支持任何可选能力
+type ProtocolOffer struct {
+	ProtocolVersion int      `json:"protocolVersion,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+}
+
+// validate 校验声明的版本和能力，未知的能力不视为错误
+func (o *ProtocolOffer) validate(v *ValidationError) {
+	if o.ProtocolVersion < 0 {
+		v.add("protocolVersion", "must not be negative")
+	}
+	if len(o.Capabilities) > maxCapabilities {
+		v.add("capabilities", "must contain at most %d capabilities", maxCapabilities)
+	}
+}
+
+// version 返回声明的版本，未声明时为 1
+func (o *ProtocolOffer) version() int {
+	if o.ProtocolVersion == 0 {
+		return 1
+	}
+	return o.ProtocolVersion
+}
+
+// Protocol 连接协商的协议版本和启用的能力
+type Protocol struct {
+	Version      int      `json:"protocolVersion"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Has 判断连接是否启用了能力
+func (p Protocol) Has(capability string) bool {
+	for _, c := range p.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateProtocol 按客户端的声明和连接的子协议确定本连接的协议。客户端版本低于 MinVersion 时
+// 发送 upgrade_required 错误并返回 false，details 中给出服务器接受的版本范围
+func (s *SimpleServer) negotiateProtocol(conn *websocket.Conn, offer ProtocolOffer, locale string) (Protocol, bool) {
+	version := offer.version()
+	if version < s.protocol.MinVersion {
+		protocolErr := s.localizedError(locale, ErrCodeUpgradeRequired, "error.upgrade_required", i18n.Params{
+			"version":    version,
+			"minVersion": s.protocol.MinVersion,
+		})
+		protocolErr.Details = map[string]interface{}{
+			"protocolVersion":    version,
+			"minProtocolVersion": s.protocol.MinVersion,
+			"maxProtocolVersion": ProtocolVersion,
+		}
+		s.sendProtocolError(conn, protocolErr)
+		return Protocol{}, false
+	}
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+
+	requested := offer.Capabilities
+	if requested == nil {
+		requested = legacyCapabilities
+	}
+	protocol := Protocol{Version: version, Capabilities: []string{}}
+	// 二进制编码和压缩在握手时已确定，不论客户端是否声明都如实返回
+	if conn.Subprotocol() == SubprotocolMsgPack {
+		protocol.Capabilities = append(protocol.Capabilities, CapabilityBinary)
+	}
+	if compressionNegotiated(conn) {
+		protocol.Capabilities = append(protocol.Capabilities, CapabilityCompression)
+	}
+	for _, capability := range requested {
+		if capability == CapabilityDeltaSync && !protocol.Has(capability) {
+			protocol.Capabilities = append(protocol.Capabilities, capability)
+		}
+	}
+	return protocol, true
+}
+
+// setProtocol 记录玩家当前连接协商的协议
+func (p *Player) setProtocol(protocol Protocol) {
+	p.protocolMutex.Lock()
+	p.protocol = protocol
+	p.protocolMutex.Unlock()
+}
+
+// supports 判断玩家当前连接是否启用了能力
+func (p *Player) supports(capability string) bool {
+	p.protocolMutex.RLock()
+	defer p.protocolMutex.RUnlock()
+	return p.protocol.Has(capability)
+}
@@ -0,0 +1,88 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/ratelimit"
+)
+
+// RateLimitPolicy 玩家消息超限时的处理策略
+type RateLimitPolicy string
+
+const (
+	// RateLimitThrottle 丢弃超限消息并回复 rate_limited 错误
+	RateLimitThrottle RateLimitPolicy = "throttle"
+	// RateLimitKick 与 throttle 相同，但连续超限达到 KickThreshold 次后断开连接
+	RateLimitKick RateLimitPolicy = "kick"
+)
+
+// RateLimitConfig 按消息类型的玩家限流配置
+type RateLimitConfig struct {
+	Rates         map[string]ratelimit.Rate // 消息类型 -> 每个玩家的令牌桶，未列出的类型不限流
+	Policy        RateLimitPolicy
+	KickThreshold int // kick 策略下连续超限多少次后断开连接
+}
+
+// DefaultRateLimitConfig 返回默认限流配置，移动消息的上限高于客户端正常的发送频率
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Rates: map[string]ratelimit.Rate{
+			MsgTypeChat:         {Limit: 2, Burst: 5},
+			MsgTypeChatHistory:  {Limit: 1, Burst: 5},
+			MsgTypePlayerMove:   {Limit: 30, Burst: 60},
+			MsgTypePlayerAction: {Limit: 5, Burst: 10},
+		},
+		Policy:        RateLimitThrottle,
+		KickThreshold: 20,
+	}
+}
+
+// ParseRateLimitPolicy 解析限流策略名称
+func ParseRateLimitPolicy(name string) (RateLimitPolicy, error) {
+	switch policy := RateLimitPolicy(name); policy {
+	case RateLimitThrottle, RateLimitKick:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown rate limit policy: %s", name)
+	}
+}
+
+// SetRateLimitConfig 替换玩家消息限流配置，应在接受连接前调用
+func (s *SimpleServer) SetRateLimitConfig(config RateLimitConfig) {
+	limiters := make(map[string]*ratelimit.Limiter, len(config.Rates))
+	for msgType, rate := range config.Rates {
+		if !rate.Disabled() {
+			limiters[msgType] = ratelimit.NewLimiter(rate)
+		}
+	}
+
+	s.rateLimit = config
+	s.rateLimiters = limiters
+}
+
+// allowMessage 检查玩家是否还能发送该类型的消息
+func (s *SimpleServer) allowMessage(player *Player, msgType string) (bool, time.Duration) {
+	limiter, ok := s.rateLimiters[msgType]
+	if !ok {
+		return true, 0
+	}
+	return limiter.Reserve(player.DID)
+}
+
+// throttle 拒绝超限消息，violations 为该连接连续超限的次数，返回 false 表示应断开连接
+func (s *SimpleServer) throttle(conn *websocket.Conn, player *Player, msgType string, wait time.Duration, violations int) bool {
+	if s.rateLimit.Policy == RateLimitKick && violations >= s.rateLimit.KickThreshold {
+		player.log().Warn("Disconnecting player for exceeding rate limit", "type", msgType, "violations", violations)
+		s.sendLocalizedError(conn, player.Locale, ErrCodeRateLimited, "error.rate_limit_disconnect", nil)
+		closeFrame := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded")
+		writeClose(conn, closeFrame, time.Now().Add(s.heartbeat.WriteWait))
+		return false
+	}
+
+	s.sendLocalizedError(conn, player.Locale, ErrCodeRateLimited, "error.rate_limited", i18n.Params{"type": msgType, "wait": wait.Milliseconds()})
+	return true
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig Redis 房间后端配置
+type RedisConfig struct {
+	Addr         string        // host:port
+	Password     string        // 为空时不发送 AUTH
+	DB           int           // 逻辑数据库编号
+	KeyPrefix    string        // 所有键和流的前缀
+	PresenceTTL  time.Duration // 在线状态过期时间，实例崩溃后状态自动失效
+	RoomHostTTL  time.Duration // 房间分配的过期时间，托管实例定期续期，实例崩溃后房间可由其他实例接管
+	DialTimeout  time.Duration // 连接超时
+	ReadTimeout  time.Duration // 读取回复超时，超时的连接被关闭，下次命令重新建立连接
+	WriteTimeout time.Duration // 写出命令超时
+	StreamMaxLen int64         // 消息总线每个流近似保留的条目数，订阅方断线超过该窗口的消息会被裁剪
+}
+
+// DefaultRedisConfig 返回默认 Redis 配置
+func DefaultRedisConfig() RedisConfig {
+	return RedisConfig{
+		Addr:         "localhost:6379",
+		KeyPrefix:    "game",
+		PresenceTTL:  5 * time.Minute,
+		RoomHostTTL:  time.Minute,
+		DialTimeout:  5 * time.Second,
+		ReadTimeout:  3 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		StreamMaxLen: 10000,
+	}
+}
+
+// newRedisClient 按配置创建客户端并确认服务可用。
+// 客户端维护连接池：读写出错或超时的连接被丢弃，之后的命令使用新建的连接，不会读到上一条命令残留的回复
+func newRedisClient(config RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:         config.Addr,
+		Password:     config.Password,
+		DB:           config.DB,
+		DialTimeout:  config.DialTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.DialTimeout+config.ReadTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis %s: %w", config.Addr, err)
+	}
+	return client, nil
+}
+
+// RedisRoomBackend 基于 Redis 的房间后端：成员使用集合，在线状态使用带过期时间的键
+type RedisRoomBackend struct {
+	config RedisConfig
+	client *redis.Client
+}
+
+// NewRedisRoomBackend 连接 Redis 并创建房间后端
+func NewRedisRoomBackend(config RedisConfig) (*RedisRoomBackend, error) {
+	client, err := newRedisClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisRoomBackend{
+		config: config,
+		client: client,
+	}, nil
+}
+
+func (b *RedisRoomBackend) membersKey(roomID string) string {
+	return b.config.KeyPrefix + ":room:" + roomID + ":members"
+}
+
+func (b *RedisRoomBackend) presenceKey(playerID string) string {
+	return b.config.KeyPrefix + ":presence:" + playerID
+}
+
+// AddMember 记录玩家加入房间
+func (b *RedisRoomBackend) AddMember(roomID, playerID string) error {
+	return b.client.SAdd(context.Background(), b.membersKey(roomID), playerID).Err()
+}
+
+// RemoveMember 记录玩家离开房间
+func (b *RedisRoomBackend) RemoveMember(roomID, playerID string) error {
+	return b.client.SRem(context.Background(), b.membersKey(roomID), playerID).Err()
+}
+
+// Members 返回所有实例上该房间的玩家 ID
+func (b *RedisRoomBackend) Members(roomID string) ([]string, error) {
+	return b.client.SMembers(context.Background(), b.membersKey(roomID)).Result()
+}
+
+// SetPresence 更新玩家在线状态，状态在 PresenceTTL 后过期
+func (b *RedisRoomBackend) SetPresence(playerID, status string) error {
+	return b.client.Set(context.Background(), b.presenceKey(playerID), status, b.config.PresenceTTL).Err()
+}
+
+// Presence 返回玩家在线状态，未知或已过期返回空字符串
+func (b *RedisRoomBackend) Presence(playerID string) (string, error) {
+	status, err := b.client.Get(context.Background(), b.presenceKey(playerID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return status, err
+}
+
+// Close 关闭连接池
+func (b *RedisRoomBackend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,169 @@
+package game
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedis 启动内存 Redis 并返回指向它的配置
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, RedisConfig) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	config := DefaultRedisConfig()
+	config.Addr = server.Addr()
+	config.DialTimeout = time.Second
+	config.ReadTimeout = 200 * time.Millisecond
+	config.WriteTimeout = 200 * time.Millisecond
+	return server, config
+}
+
+func TestRedisRoomBackendMembersAndPresence(t *testing.T) {
+	server, config := newTestRedis(t)
+	backend, err := NewRedisRoomBackend(config)
+	if err != nil {
+		t.Fatalf("NewRedisRoomBackend: %v", err)
+	}
+	defer backend.Close()
+
+	for _, playerID := range []string{"alice", "bob"} {
+		if err := backend.AddMember("room-1", playerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := backend.RemoveMember("room-1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	members, err := backend.Members("room-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0] != "bob" {
+		t.Errorf("members %v, want [bob]", members)
+	}
+
+	if status, err := backend.Presence("bob"); err != nil || status != "" {
+		t.Errorf("unknown presence = %q, %v", status, err)
+	}
+	if err := backend.SetPresence("bob", "online"); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := backend.Presence("bob"); err != nil || status != "online" {
+		t.Errorf("presence = %q, %v", status, err)
+	}
+	server.FastForward(config.PresenceTTL + time.Second)
+	if status, _ := backend.Presence("bob"); status != "" {
+		t.Errorf("presence %q did not expire", status)
+	}
+}
+
+func TestRedisRoomBackendReconnects(t *testing.T) {
+	server, config := newTestRedis(t)
+	backend, err := NewRedisRoomBackend(config)
+	if err != nil {
+		t.Fatalf("NewRedisRoomBackend: %v", err)
+	}
+	defer backend.Close()
+
+	if err := backend.SetPresence("alice", "online"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后连接池中的连接已失效，命令失败一次后重新建立连接
+	server.Close()
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		if lastErr = backend.SetPresence("alice", "offline"); lastErr == nil {
+			break
+		}
+	}
+	if lastErr != nil {
+		t.Fatalf("backend did not reconnect: %v", lastErr)
+	}
+	if status, err := backend.Presence("alice"); err != nil || status != "offline" {
+		t.Errorf("presence after reconnect = %q, %v", status, err)
+	}
+}
+
+func TestRedisRoomBackendReadTimeout(t *testing.T) {
+	// 接受连接但从不回复的服务端：没有读取超时时命令会永久阻塞
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	config := DefaultRedisConfig()
+	config.Addr = listener.Addr().String()
+	config.DialTimeout = time.Second
+	config.ReadTimeout = 100 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewRedisRoomBackend(config)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("connected to a server that never replies")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("command blocked past the read timeout")
+	}
+}
+
+func TestRedisRoomDirectoryClaimAndRelease(t *testing.T) {
+	server, config := newTestRedis(t)
+	directory, err := NewRedisRoomDirectory(config)
+	if err != nil {
+		t.Fatalf("NewRedisRoomDirectory: %v", err)
+	}
+	defer directory.Close()
+
+	first := Instance{ID: "first", Host: "10.0.0.1", Port: 8080}
+	second := Instance{ID: "second", Host: "10.0.0.2", Port: 8080}
+
+	host, err := directory.Claim("room-1", first)
+	if err != nil || host.ID != first.ID {
+		t.Fatalf("first claim = %+v, %v", host, err)
+	}
+	if host, err = directory.Claim("room-1", second); err != nil || host.ID != first.ID {
+		t.Fatalf("second claim = %+v, %v, want host %s", host, err, first.ID)
+	}
+
+	// 非托管实例的释放不生效
+	if err := directory.Release("room-1", second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if host, _ = directory.Claim("room-1", second); host.ID != first.ID {
+		t.Fatalf("room released by a non-host instance")
+	}
+
+	if err := directory.Release("room-1", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if host, _ = directory.Claim("room-1", second); host.ID != second.ID {
+		t.Fatalf("claim after release = %+v, want %s", host, second.ID)
+	}
+
+	// 托管实例停止续期后分配过期，其他实例可以接管
+	server.FastForward(config.RoomHostTTL + time.Second)
+	if host, _ = directory.Claim("room-1", first); host.ID != first.ID {
+		t.Fatalf("claim after expiry = %+v, want %s", host, first.ID)
+	}
+}
@@ -0,0 +1,139 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// 流订阅参数
+const (
+	redisBusField      = "data"                 // 流条目中保存消息的字段
+	redisBusReadCount  = 100                    // 每次 XREAD 最多读取的条目数
+	redisBusBlock      = 5 * time.Second        // XREAD 的阻塞等待时间
+	redisBusRetryMin   = 100 * time.Millisecond // 读取失败后的首次重试间隔
+	redisBusRetryMax   = 5 * time.Second        // 重试间隔上限
+	redisBusDefaultLen = 10000                  // 未配置 StreamMaxLen 时每个流保留的条目数
+)
+
+// RedisMessageBus 基于 Redis Streams 的消息总线，每个主题对应一个流。
+// 订阅方记录已处理的最后一个条目 ID，连接中断后从该 ID 继续读取，断线期间发布的消息不会丢失
+type RedisMessageBus struct {
+	config RedisConfig
+	client *redis.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRedisMessageBus 连接 Redis 并创建消息总线
+func NewRedisMessageBus(config RedisConfig) (*RedisMessageBus, error) {
+	client, err := newRedisClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RedisMessageBus{
+		config: config,
+		client: client,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+func (b *RedisMessageBus) stream(topic string) string {
+	return b.config.KeyPrefix + ":bus:" + topic
+}
+
+// Publish 向主题流追加消息，流的长度近似地保持在 StreamMaxLen 以内
+func (b *RedisMessageBus) Publish(topic string, data []byte) error {
+	maxLen := b.config.StreamMaxLen
+	if maxLen <= 0 {
+		maxLen = redisBusDefaultLen
+	}
+
+	return b.client.XAdd(b.ctx, &redis.XAddArgs{
+		Stream: b.stream(topic),
+		MaxLen: maxLen,
+		Approx: true,
+		Values: []interface{}{redisBusField, data},
+	}).Err()
+}
+
+// Subscribe 从流的当前末尾开始读取主题消息，在后台按顺序调用 handler，总线关闭后停止
+func (b *RedisMessageBus) Subscribe(topic string, handler func(data []byte)) error {
+	stream := b.stream(topic)
+
+	// 起始 ID 在订阅时确定，之后重连不会跳过订阅后发布的消息
+	lastID := "0-0"
+	latest, err := b.client.XRevRangeN(b.ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return err
+	}
+	if len(latest) > 0 {
+		lastID = latest[0].ID
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.consume(topic, stream, lastID, handler)
+	}()
+
+	return nil
+}
+
+// consume 循环读取流中 lastID 之后的条目，读取失败时按指数退避重试
+func (b *RedisMessageBus) consume(topic, stream, lastID string, handler func(data []byte)) {
+	retry := redisBusRetryMin
+	for b.ctx.Err() == nil {
+		streams, err := b.client.XRead(b.ctx, &redis.XReadArgs{
+			Streams: []string{stream, lastID},
+			Count:   redisBusReadCount,
+			Block:   redisBusBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Redis stream read failed, retrying", "topic", topic, "retry_in", retry, logging.Err(err))
+			select {
+			case <-time.After(retry):
+			case <-b.ctx.Done():
+				return
+			}
+			retry = min(retry*2, redisBusRetryMax)
+			continue
+		}
+		retry = redisBusRetryMin
+
+		for _, s := range streams {
+			for _, message := range s.Messages {
+				lastID = message.ID
+				if payload, ok := message.Values[redisBusField].(string); ok {
+					handler([]byte(payload))
+				}
+			}
+		}
+	}
+}
+
+// Close 停止所有订阅并关闭连接池
+func (b *RedisMessageBus) Close() error {
+	b.cancel()
+	// 关闭连接池会中断阻塞中的 XREAD
+	err := b.client.Close()
+	b.wg.Wait()
+	return err
+}
@@ -0,0 +1,212 @@
+package game
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive 等待 handler 收到下一条消息
+func receive(t *testing.T, received <-chan string) string {
+	t.Helper()
+	select {
+	case data := <-received:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+		return ""
+	}
+}
+
+func newTestBus(t *testing.T, config RedisConfig) *RedisMessageBus {
+	t.Helper()
+	bus, err := NewRedisMessageBus(config)
+	if err != nil {
+		t.Fatalf("NewRedisMessageBus: %v", err)
+	}
+	t.Cleanup(func() { bus.Close() })
+	return bus
+}
+
+func TestRedisMessageBusDeliversInOrder(t *testing.T) {
+	_, config := newTestRedis(t)
+	publisher := newTestBus(t, config)
+	subscriber := newTestBus(t, config)
+
+	// 订阅前发布的消息不重放
+	if err := publisher.Publish(busTopicRoom, []byte("before")); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan string, 16)
+	if err := subscriber.Subscribe(busTopicRoom, func(data []byte) { received <- string(data) }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	for _, message := range []string{"one", "two", "three"} {
+		if err := publisher.Publish(busTopicRoom, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"one", "two", "three"} {
+		if got := receive(t, received); got != want {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	}
+}
+
+func TestRedisMessageBusResumesAfterReconnect(t *testing.T) {
+	_, config := newTestRedis(t)
+	publisher := newTestBus(t, config)
+
+	proxy := newTestProxy(t, config.Addr)
+	proxied := config
+	proxied.Addr = proxy.addr()
+	subscriber := newTestBus(t, proxied)
+
+	received := make(chan string, 16)
+	if err := subscriber.Subscribe(busTopicPresence, func(data []byte) { received <- string(data) }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := publisher.Publish(busTopicPresence, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, received); got != "first" {
+		t.Fatalf("received %q", got)
+	}
+
+	// 订阅方断线期间发布的消息在重连后按顺序送达
+	proxy.disconnect()
+	for _, message := range []string{"second", "third"} {
+		if err := publisher.Publish(busTopicPresence, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proxy.reconnect()
+	for _, want := range []string{"second", "third"} {
+		if got := receive(t, received); got != want {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	}
+}
+
+// testProxy 转发到 Redis 的 TCP 代理，用于模拟订阅方的网络中断
+type testProxy struct {
+	listener net.Listener
+	target   string
+
+	mutex   sync.Mutex
+	down    bool
+	clients []net.Conn
+}
+
+func newTestProxy(t *testing.T, target string) *testProxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProxy{listener: listener, target: target}
+	t.Cleanup(func() {
+		listener.Close()
+		p.disconnect()
+	})
+
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			p.mutex.Lock()
+			down := p.down
+			if !down {
+				p.clients = append(p.clients, client)
+			}
+			p.mutex.Unlock()
+			if down {
+				client.Close()
+				continue
+			}
+
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				client.Close()
+				continue
+			}
+			go func() {
+				io.Copy(upstream, client)
+				upstream.Close()
+			}()
+			go func() {
+				io.Copy(client, upstream)
+				client.Close()
+			}()
+		}
+	}()
+	return p
+}
+
+func (p *testProxy) addr() string {
+	return p.listener.Addr().String()
+}
+
+// disconnect 断开所有已建立的连接并拒绝新连接
+func (p *testProxy) disconnect() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.down = true
+	for _, client := range p.clients {
+		client.Close()
+	}
+	p.clients = nil
+}
+
+// reconnect 重新接受连接
+func (p *testProxy) reconnect() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.down = false
+}
+
+func TestRedisMessageBusTrimsStreams(t *testing.T) {
+	server, config := newTestRedis(t)
+	config.StreamMaxLen = 5
+	bus := newTestBus(t, config)
+
+	for i := 0; i < 20; i++ {
+		if err := bus.Publish(busTopicInbox, []byte("message")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := server.Stream(bus.stream(busTopicInbox))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 20 || len(entries) < 5 {
+		t.Errorf("stream holds %d entries", len(entries))
+	}
+}
+
+func TestRedisMessageBusCloseStopsSubscribers(t *testing.T) {
+	_, config := newTestRedis(t)
+	bus, err := NewRedisMessageBus(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Subscribe(busTopicGuild, func([]byte) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a pending stream read")
+	}
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// claimScript 原子地分配房间：未分配时写入调用方并设置过期时间，已由调用方托管时续期，返回托管实例
+var claimScript = redis.NewScript(`local host = redis.call('GET', KEYS[1])
+if not host then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return ARGV[1]
+end
+if host == ARGV[1] then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return host`)
+
+// releaseScript 只在房间仍由调用方托管时删除分配
+var releaseScript = redis.NewScript(`if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+// RedisRoomDirectory 基于 Redis 的房间目录，每个房间的托管实例保存在带过期时间的键中
+type RedisRoomDirectory struct {
+	config RedisConfig
+	client *redis.Client
+}
+
+// NewRedisRoomDirectory 连接 Redis 并创建房间目录
+func NewRedisRoomDirectory(config RedisConfig) (*RedisRoomDirectory, error) {
+	client, err := newRedisClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisRoomDirectory{
+		config: config,
+		client: client,
+	}, nil
+}
+
+func (d *RedisRoomDirectory) hostKey(roomID string) string {
+	return d.config.KeyPrefix + ":room:" + roomID + ":host"
+}
+
+// Claim 将未分配的房间分配给 instance，分配在 RoomHostTTL 后过期
+func (d *RedisRoomDirectory) Claim(roomID string, instance Instance) (Instance, error) {
+	value, err := json.Marshal(instance)
+	if err != nil {
+		return Instance{}, fmt.Errorf("marshal instance: %w", err)
+	}
+
+	data, err := claimScript.Run(context.Background(), d.client, []string{d.hostKey(roomID)}, string(value), d.config.RoomHostTTL.Milliseconds()).Text()
+	if err != nil {
+		return Instance{}, err
+	}
+
+	var host Instance
+	if err := json.Unmarshal([]byte(data), &host); err != nil {
+		return Instance{}, fmt.Errorf("unmarshal room host: %w", err)
+	}
+	return host, nil
+}
+
+// Release 释放 instanceID 托管的房间
+func (d *RedisRoomDirectory) Release(roomID, instanceID string) error {
+	data, err := d.client.Get(context.Background(), d.hostKey(roomID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var host Instance
+	if err := json.Unmarshal([]byte(data), &host); err != nil {
+		return fmt.Errorf("unmarshal room host: %w", err)
+	}
+	if host.ID != instanceID {
+		return nil
+	}
+
+	return releaseScript.Run(context.Background(), d.client, []string{d.hostKey(roomID)}, data).Err()
+}
+
+// Close 关闭连接池
+func (d *RedisRoomDirectory) Close() error {
+	return d.client.Close()
+}
@@ -0,0 +1,28 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultRequestTimeout 处理一条 WebSocket 消息的默认时限
+const DefaultRequestTimeout = 10 * time.Second
+
+// SetRequestTimeout 设置处理一条消息的时限，超时后消息中尚未完成的 DID 解析、凭证验证等操作被取消，
+// 0 表示不限时。应在接受连接前调用
+func (s *SimpleServer) SetRequestTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("request timeout must not be negative, got %s", timeout)
+	}
+	s.requestTimeout = timeout
+	return nil
+}
+
+// messageContext 返回处理一条消息的上下文，超过时限或服务器关闭时取消
+func (s *SimpleServer) messageContext() (context.Context, context.CancelFunc) {
+	if s.requestTimeout == 0 {
+		return context.WithCancel(s.baseCtx)
+	}
+	return context.WithTimeout(s.baseCtx, s.requestTimeout)
+}
@@ -0,0 +1,423 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+const (
+	// resultsStoreName 对局结果的存储名称
+	resultsStoreName = "game_results"
+
+	recordTypeResult = "result"
+)
+
+// LeaderboardPath 排行榜接口路径，名次查询为 LeaderboardPath + "/rank"
+const LeaderboardPath = "/api/leaderboard"
+
+// 排行榜统计周期
+const (
+	PeriodAll    = "all"
+	PeriodWeekly = "weekly" // 本周（UTC 周一 00:00 起）
+)
+
+// 排行榜分页大小
+const (
+	defaultLeaderboardLimit = 20
+	maxLeaderboardLimit     = 100
+)
+
+// ErrPlayerNotRanked 玩家在该排行榜中没有对局记录
+var ErrPlayerNotRanked = errors.New("player has no results on this leaderboard")
+
+// PlayerResult 玩家在一局中的成绩
+type PlayerResult struct {
+	PlayerID string `json:"playerId"`
+	DID      string `json:"did"`
+	Nickname string `json:"nickname"`
+	TeamID   string `json:"teamId,omitempty"`
+	Score    int    `json:"score"`
+	Won      bool   `json:"won"`
+}
+
+// MatchResult 一局对局的结果
+type MatchResult struct {
+	ID         string          `json:"id"`
+	RoomID     string          `json:"roomId"`
+	GameID     string          `json:"gameId"`
+	Mode       string          `json:"mode"`
+	Winner     string          `json:"winner,omitempty"`
+	Reason     string          `json:"reason"`
+	Players    []*PlayerResult `json:"players"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt time.Time       `json:"finishedAt"`
+}
+
+// LeaderboardQuery 排行榜查询条件
+type LeaderboardQuery struct {
+	GameID string // 为空表示所有游戏
+	Period string // all 或 weekly
+	Offset int
+	Limit  int
+}
+
+// LeaderboardEntry 排行榜中的一名玩家，按总分、胜场、对局数排序
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	DID      string `json:"did"`
+	PlayerID string `json:"playerId"`
+	Nickname string `json:"nickname"`
+	Score    int    `json:"score"`
+	Wins     int    `json:"wins"`
+	Matches  int    `json:"matches"`
+}
+
+// Results 对局结果记录和排行榜，结果在内存中缓存并同步写入存储
+type Results struct {
+	store   storage.Store
+	results []*MatchResult
+	mutex   sync.RWMutex
+	now     func() time.Time
+}
+
+// NewResults 创建结果模块并加载存储中的历史结果
+func NewResults(provider storage.Provider) (*Results, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(resultsStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open results store: %w", err)
+	}
+
+	r := &Results{store: store, now: time.Now}
+	err = queryRecords(store, recordTypeTag, recordTypeResult, func(data []byte) error {
+		var result MatchResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return fmt.Errorf("unmarshal match result: %w", err)
+		}
+		r.results = append(r.results, &result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record 保存一局对局结果
+func (r *Results) Record(result *MatchResult) error {
+	if result.ID == "" {
+		result.ID = uuid.New().String()
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshal match result: %w", err)
+	}
+	err = r.store.Put("result:"+result.ID, data,
+		storage.Tag{Name: recordTypeTag, Value: recordTypeResult},
+		storage.Tag{Name: "game", Value: result.GameID})
+	if err != nil {
+		return fmt.Errorf("save match result: %w", err)
+	}
+
+	r.mutex.Lock()
+	r.results = append(r.results, result)
+	r.mutex.Unlock()
+	return nil
+}
+
+// Leaderboard 返回排行榜的一页和上榜玩家总数
+func (r *Results) Leaderboard(query LeaderboardQuery) ([]*LeaderboardEntry, int, error) {
+	entries, err := r.rank(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultLeaderboardLimit
+	}
+	if limit > maxLeaderboardLimit {
+		limit = maxLeaderboardLimit
+	}
+
+	start := query.Offset
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := start + limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+	return entries[start:end], len(entries), nil
+}
+
+// Rank 返回玩家在排行榜中的名次
+func (r *Results) Rank(playerDID string, query LeaderboardQuery) (*LeaderboardEntry, error) {
+	entries, err := r.rank(query)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.DID == playerDID {
+			return entry, nil
+		}
+	}
+	return nil, ErrPlayerNotRanked
+}
+
+// PlayerMatches 返回玩家参加过的对局，按完成顺序排列
+func (r *Results) PlayerMatches(playerDID string) []*MatchResult {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var matches []*MatchResult
+	for _, result := range r.results {
+		for _, player := range result.Players {
+			if player.DID == playerDID {
+				matches = append(matches, result)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// ReplacePlayer 将玩家在对局结果中的 DID、玩家 ID 和昵称替换为 replacement 中的值，分数和胜负保留，
+// 排行榜的汇总不受影响；用于删除数据时替换为假名和访客升级时迁移到登记的 DID。返回修改的对局数
+func (r *Results) ReplacePlayer(playerDID string, replacement PlayerResult) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	changed := 0
+	for i, result := range r.results {
+		var updated *MatchResult
+		for j, player := range result.Players {
+			if player.DID != playerDID {
+				continue
+			}
+			if updated == nil {
+				copied := *result
+				copied.Players = append([]*PlayerResult(nil), result.Players...)
+				updated = &copied
+			}
+			renamed := *player
+			renamed.DID = replacement.DID
+			renamed.PlayerID = replacement.PlayerID
+			renamed.Nickname = replacement.Nickname
+			updated.Players[j] = &renamed
+			if updated.Winner == player.PlayerID {
+				updated.Winner = replacement.PlayerID
+			}
+		}
+		if updated == nil {
+			continue
+		}
+
+		data, err := json.Marshal(updated)
+		if err != nil {
+			return changed, fmt.Errorf("marshal match result: %w", err)
+		}
+		err = r.store.Put("result:"+updated.ID, data,
+			storage.Tag{Name: recordTypeTag, Value: recordTypeResult},
+			storage.Tag{Name: "game", Value: updated.GameID})
+		if err != nil {
+			return changed, fmt.Errorf("save match result: %w", err)
+		}
+		r.results[i] = updated
+		changed++
+	}
+	return changed, nil
+}
+
+// rank 汇总符合条件的对局，返回完整的排名
+func (r *Results) rank(query LeaderboardQuery) ([]*LeaderboardEntry, error) {
+	var since time.Time
+	switch query.Period {
+	case "", PeriodAll:
+	case PeriodWeekly:
+		since = startOfWeek(r.now())
+	default:
+		return nil, fmt.Errorf("unsupported period: %s", query.Period)
+	}
+
+	r.mutex.RLock()
+	byDID := make(map[string]*LeaderboardEntry)
+	for _, result := range r.results {
+		if query.GameID != "" && result.GameID != query.GameID {
+			continue
+		}
+		if result.FinishedAt.Before(since) {
+			continue
+		}
+
+		for _, player := range result.Players {
+			entry, exists := byDID[player.DID]
+			if !exists {
+				entry = &LeaderboardEntry{DID: player.DID}
+				byDID[player.DID] = entry
+			}
+			// 结果按完成顺序追加，昵称以最近一局为准
+			entry.PlayerID = player.PlayerID
+			entry.Nickname = player.Nickname
+			entry.Score += player.Score
+			entry.Matches++
+			if player.Won {
+				entry.Wins++
+			}
+		}
+	}
+	r.mutex.RUnlock()
+
+	entries := make([]*LeaderboardEntry, 0, len(byDID))
+	for _, entry := range byDID {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.Matches != b.Matches {
+			return a.Matches < b.Matches
+		}
+		return a.DID < b.DID
+	})
+	for i, entry := range entries {
+		entry.Rank = i + 1
+	}
+	return entries, nil
+}
+
+// startOfWeek 返回 t 所在周的周一 00:00（UTC）
+func startOfWeek(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// SetResults 使用持久化的结果模块替换默认的内存实现，应在接受连接前调用
+func (s *SimpleServer) SetResults(results *Results) {
+	s.results = results
+}
+
+// matchResult 根据游戏模式的判定结果生成每名玩家的成绩，调用方需持有 room.mutex
+func (s *SimpleServer) matchResult(room *GameRoom, result *GameResult) *MatchResult {
+	match := &MatchResult{
+		RoomID:     room.ID,
+		GameID:     room.GameID,
+		Mode:       room.Mode,
+		Winner:     result.Winner,
+		Reason:     result.Reason,
+		Players:    make([]*PlayerResult, 0, len(room.Players)),
+		StartedAt:  room.GameState.StartTime,
+		FinishedAt: time.Now(),
+	}
+	if room.GameState.EndTime != nil {
+		match.FinishedAt = *room.GameState.EndTime
+	}
+
+	for _, player := range room.Players {
+		match.Players = append(match.Players, &PlayerResult{
+			PlayerID: player.ID,
+			DID:      player.DID,
+			Nickname: player.Nickname,
+			TeamID:   player.TeamID,
+			Score:    result.Scores[player.ID],
+			Won:      result.Winner != "" && (result.Winner == player.ID || result.Winner == player.TeamID),
+		})
+	}
+	sort.Slice(match.Players, func(i, j int) bool { return match.Players[i].Score > match.Players[j].Score })
+	return match
+}
+
+// HandleLeaderboard 处理 GET /api/leaderboard?game=...&period=all|weekly&offset=...&limit=...
+// 以及 GET /api/leaderboard/rank?did=...&game=...&period=...
+func (s *SimpleServer) HandleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	query := LeaderboardQuery{
+		GameID: params.Get("game"),
+		Period: params.Get("period"),
+	}
+	if query.Period == "" {
+		query.Period = PeriodAll
+	}
+
+	if r.URL.Path == LeaderboardPath+"/rank" {
+		playerDID := params.Get("did")
+		if playerDID == "" {
+			http.Error(w, "did is required", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := s.results.Rank(playerDID, query)
+		switch {
+		case errors.Is(err, ErrPlayerNotRanked):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+		return
+	}
+
+	for name, target := range map[string]*int{"offset": &query.Offset, "limit": &query.Limit} {
+		if value := params.Get(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				http.Error(w, name+" must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			*target = n
+		}
+	}
+
+	entries, total, err := s.results.Leaderboard(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"game":    query.GameID,
+		"period":  query.Period,
+		"total":   total,
+		"entries": entries,
+	})
+}
+
+// saveResult 保存对局结果，失败时只记录日志
+func (s *SimpleServer) saveResult(match *MatchResult) {
+	if err := s.results.Record(match); err != nil {
+		slog.Error("Failed to record match result", logging.KeyRoomID, match.RoomID, logging.Err(err))
+	}
+}
@@ -0,0 +1,175 @@
+package game
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// RoomBackend 房间成员和玩家在线状态的共享后端，
+// 多个服务器实例通过同一后端共享状态，默认使用单实例的内存实现
+type RoomBackend interface {
+	// AddMember 记录玩家加入房间
+	AddMember(roomID, playerID string) error
+	// RemoveMember 记录玩家离开房间
+	RemoveMember(roomID, playerID string) error
+	// Members 返回所有实例上该房间的玩家 ID
+	Members(roomID string) ([]string, error)
+	// SetPresence 更新玩家在线状态（online、offline）
+	SetPresence(playerID, status string) error
+	// Presence 返回玩家在线状态，未知玩家返回空字符串
+	Presence(playerID string) (string, error)
+	// Close 释放后端资源
+	Close() error
+}
+
+// MemoryRoomBackend 单实例内存后端
+type MemoryRoomBackend struct {
+	members  map[string]map[string]struct{}
+	presence map[string]string
+	mutex    sync.RWMutex
+}
+
+// NewMemoryRoomBackend 创建内存房间后端
+func NewMemoryRoomBackend() *MemoryRoomBackend {
+	return &MemoryRoomBackend{
+		members:  make(map[string]map[string]struct{}),
+		presence: make(map[string]string),
+	}
+}
+
+// AddMember 记录玩家加入房间
+func (b *MemoryRoomBackend) AddMember(roomID, playerID string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	members, exists := b.members[roomID]
+	if !exists {
+		members = make(map[string]struct{})
+		b.members[roomID] = members
+	}
+	members[playerID] = struct{}{}
+	return nil
+}
+
+// RemoveMember 记录玩家离开房间
+func (b *MemoryRoomBackend) RemoveMember(roomID, playerID string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	members := b.members[roomID]
+	delete(members, playerID)
+	if len(members) == 0 {
+		delete(b.members, roomID)
+	}
+	return nil
+}
+
+// Members 返回房间的玩家 ID
+func (b *MemoryRoomBackend) Members(roomID string) ([]string, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	playerIDs := make([]string, 0, len(b.members[roomID]))
+	for playerID := range b.members[roomID] {
+		playerIDs = append(playerIDs, playerID)
+	}
+	return playerIDs, nil
+}
+
+// SetPresence 更新玩家在线状态
+func (b *MemoryRoomBackend) SetPresence(playerID, status string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.presence[playerID] = status
+	return nil
+}
+
+// Presence 返回玩家在线状态
+func (b *MemoryRoomBackend) Presence(playerID string) (string, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.presence[playerID], nil
+}
+
+// Close 内存后端无需释放资源
+func (b *MemoryRoomBackend) Close() error {
+	return nil
+}
+
+// SetRoomBackend 替换房间后端，已有的房间成员会同步到新后端，需在处理连接前调用
+func (s *SimpleServer) SetRoomBackend(backend RoomBackend) {
+	s.roomMutex.Lock()
+	s.roomBackend = backend
+	rooms := make([]*GameRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.roomMutex.Unlock()
+
+	for _, room := range rooms {
+		room.mutex.RLock()
+		for playerID := range room.Players {
+			s.addRoomMember(room.ID, playerID)
+		}
+		room.mutex.RUnlock()
+	}
+}
+
+// addRoomMember 在共享后端记录房间成员，失败时仅记录日志
+func (s *SimpleServer) addRoomMember(roomID, playerID string) {
+	if err := s.roomBackend.AddMember(roomID, playerID); err != nil {
+		slog.Error("Failed to add member in room backend", logging.KeyPlayerID, playerID, logging.KeyRoomID, roomID, logging.Err(err))
+	}
+}
+
+// removeRoomMember 在共享后端移除房间成员，失败时仅记录日志
+func (s *SimpleServer) removeRoomMember(roomID, playerID string) {
+	if err := s.roomBackend.RemoveMember(roomID, playerID); err != nil {
+		slog.Error("Failed to remove member in room backend", logging.KeyPlayerID, playerID, logging.KeyRoomID, roomID, logging.Err(err))
+	}
+}
+
+// setPresence 在共享后端更新玩家在线状态并通知其他实例，失败时仅记录日志
+func (s *SimpleServer) setPresence(player *Player) {
+	if err := s.roomBackend.SetPresence(player.ID, player.Status); err != nil {
+		player.log().Error("Failed to set presence", logging.Err(err))
+	}
+	s.publishPresence(player)
+}
+
+// roomMemberCount 返回所有实例上的房间人数，后端不可用时退回本地人数，调用方需持有 room.mutex
+func (s *SimpleServer) roomMemberCount(room *GameRoom) int {
+	count := len(room.Players)
+	members, err := s.roomBackend.Members(room.ID)
+	if err != nil {
+		room.log().Error("Failed to count room members", logging.Err(err))
+		return count
+	}
+	if len(members) > count {
+		count = len(members)
+	}
+	return count
+}
+
+// releaseRoomMembers 关闭时移除本实例在共享后端登记的房间成员和在线状态
+func (s *SimpleServer) releaseRoomMembers() {
+	s.roomMutex.RLock()
+	defer s.roomMutex.RUnlock()
+
+	for _, room := range s.rooms {
+		room.mutex.RLock()
+		for playerID, player := range room.Players {
+			s.removeRoomMember(room.ID, playerID)
+			if player.Connection != nil {
+				if err := s.roomBackend.SetPresence(playerID, "offline"); err != nil {
+					slog.Error("Failed to set presence", logging.KeyPlayerID, playerID, logging.Err(err))
+				}
+			}
+		}
+		room.mutex.RUnlock()
+	}
+}
@@ -0,0 +1,228 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// MsgTypeRoomRedirect 房间由其他实例托管，客户端应连接到该实例后重新认证并加入房间
+const MsgTypeRoomRedirect = "room_redirect"
+
+// roomHostRenewInterval 托管实例续期房间分配的间隔，应小于目录中分配的过期时间
+const roomHostRenewInterval = 20 * time.Second
+
+// Instance 托管房间的服务器实例及客户端连接它的地址
+type Instance struct {
+	ID   string `json:"instanceId"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// InstanceFromURL 以公开地址的主机和端口作为实例地址，未指定端口时按协议取 80 或 443
+func InstanceFromURL(publicURL string) (Instance, error) {
+	parsed, err := url.Parse(publicURL)
+	if err != nil {
+		return Instance{}, fmt.Errorf("parse public URL: %w", err)
+	}
+	if parsed.Hostname() == "" {
+		return Instance{}, fmt.Errorf("public URL has no host: %s", publicURL)
+	}
+
+	port := 80
+	if parsed.Scheme == "https" {
+		port = 443
+	}
+	if parsed.Port() != "" {
+		if port, err = strconv.Atoi(parsed.Port()); err != nil {
+			return Instance{}, fmt.Errorf("invalid port in public URL: %w", err)
+		}
+	}
+	return Instance{Host: parsed.Hostname(), Port: port}, nil
+}
+
+// Addr 返回 host:port
+func (i Instance) Addr() string {
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+// RoomDirectory 记录每个房间由哪个实例托管。房间状态只在托管实例上模拟，
+// 其他实例收到加入请求时将玩家重定向到托管实例
+type RoomDirectory interface {
+	// Claim 将未分配的房间分配给 instance 并返回 instance；已分配时返回托管实例，
+	// 托管实例是 instance 自己时同时续期分配
+	Claim(roomID string, instance Instance) (Instance, error)
+	// Release 释放 instanceID 托管的房间，房间由其他实例托管时不做任何操作
+	Release(roomID, instanceID string) error
+	// Close 释放目录资源
+	Close() error
+}
+
+// MemoryRoomDirectory 单进程内存目录，同一进程中的多个服务器共享房间分配
+type MemoryRoomDirectory struct {
+	hosts map[string]Instance
+	mutex sync.Mutex
+}
+
+// NewMemoryRoomDirectory 创建内存房间目录
+func NewMemoryRoomDirectory() *MemoryRoomDirectory {
+	return &MemoryRoomDirectory{hosts: make(map[string]Instance)}
+}
+
+// Claim 将未分配的房间分配给 instance，已分配时返回托管实例
+func (d *MemoryRoomDirectory) Claim(roomID string, instance Instance) (Instance, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if host, exists := d.hosts[roomID]; exists {
+		return host, nil
+	}
+	d.hosts[roomID] = instance
+	return instance, nil
+}
+
+// Release 释放 instanceID 托管的房间
+func (d *MemoryRoomDirectory) Release(roomID, instanceID string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.hosts[roomID].ID == instanceID {
+		delete(d.hosts, roomID)
+	}
+	return nil
+}
+
+// Close 内存目录无需释放资源
+func (d *MemoryRoomDirectory) Close() error {
+	return nil
+}
+
+// RoomRedirectError 房间由其他实例托管
+type RoomRedirectError struct {
+	RoomID string
+	Host   Instance
+}
+
+func (e *RoomRedirectError) Error() string {
+	return fmt.Sprintf("room %s is hosted by instance %s at %s", e.RoomID, e.Host.ID, e.Host.Addr())
+}
+
+// SetRoomDirectory 启用房间分片：本实例只托管在目录中分配给自己的房间，instance 为客户端连接本实例的地址。
+// 已有的房间（如从持久化存储恢复的房间）会登记到目录，需在处理连接前调用
+func (s *SimpleServer) SetRoomDirectory(directory RoomDirectory, instance Instance) {
+	if s.instanceID == "" {
+		s.instanceID = uuid.New().String()
+	}
+	instance.ID = s.instanceID
+
+	s.roomMutex.Lock()
+	s.directory = directory
+	s.instance = instance
+	roomIDs := make([]string, 0, len(s.rooms))
+	for roomID := range s.rooms {
+		roomIDs = append(roomIDs, roomID)
+	}
+	s.roomMutex.Unlock()
+
+	for _, roomID := range roomIDs {
+		if err := s.claimRoom(roomID); err != nil {
+			slog.Warn("Local room is hosted by another instance", logging.KeyRoomID, roomID, logging.Err(err))
+		}
+	}
+
+	go s.renewRoomHosts()
+}
+
+// claimRoom 在目录中将房间分配给本实例，房间由其他实例托管时返回 *RoomRedirectError。未启用分片时总是成功
+func (s *SimpleServer) claimRoom(roomID string) error {
+	if s.directory == nil {
+		return nil
+	}
+
+	host, err := s.directory.Claim(roomID, s.instance)
+	if err != nil {
+		return fmt.Errorf("claim room in directory: %w", err)
+	}
+	if host.ID != s.instance.ID {
+		return &RoomRedirectError{RoomID: roomID, Host: host}
+	}
+	return nil
+}
+
+// releaseRoom 在目录中释放本实例托管的房间，失败时仅记录日志，分配过期后其他实例可以接管
+func (s *SimpleServer) releaseRoom(roomID string) {
+	if s.directory == nil {
+		return
+	}
+	if err := s.directory.Release(roomID, s.instance.ID); err != nil {
+		slog.Error("Failed to release room in directory", logging.KeyRoomID, roomID, logging.Err(err))
+	}
+}
+
+// renewRoomHosts 定期续期本实例托管的房间，实例崩溃后分配过期，房间可由其他实例重新创建
+func (s *SimpleServer) renewRoomHosts() {
+	ticker := time.NewTicker(roomHostRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.roomMutex.RLock()
+			roomIDs := make([]string, 0, len(s.rooms))
+			for roomID := range s.rooms {
+				roomIDs = append(roomIDs, roomID)
+			}
+			s.roomMutex.RUnlock()
+
+			for _, roomID := range roomIDs {
+				if err := s.claimRoom(roomID); err != nil {
+					slog.Warn("Failed to renew room host", logging.KeyRoomID, roomID, logging.Err(err))
+				}
+			}
+		}
+	}
+}
+
+// releaseRooms 关闭时释放本实例托管的所有房间
+func (s *SimpleServer) releaseRooms() {
+	if s.directory == nil {
+		return
+	}
+
+	s.roomMutex.RLock()
+	defer s.roomMutex.RUnlock()
+
+	for roomID := range s.rooms {
+		s.releaseRoom(roomID)
+	}
+	if err := s.directory.Close(); err != nil {
+		slog.Error("Failed to close room directory", logging.Err(err))
+	}
+}
+
+// sendRoomRedirect 通知玩家房间由其他实例托管
+func (s *SimpleServer) sendRoomRedirect(player *Player, redirect *RoomRedirectError) {
+	player.log().Info("Redirecting join to hosting instance", logging.KeyRoomID, redirect.RoomID, "instance", redirect.Host.ID, "addr", redirect.Host.Addr())
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypeRoomRedirect,
+		PlayerID: player.ID,
+		RoomID:   redirect.RoomID,
+		Data: map[string]interface{}{
+			"roomId":     redirect.RoomID,
+			"instanceId": redirect.Host.ID,
+			"host":       redirect.Host.Host,
+			"port":       redirect.Host.Port,
+		},
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,274 @@
+package game
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// MsgTypeResume 断线重连后出示会话令牌，恢复玩家身份、房间和位置
+const MsgTypeResume = "resume"
+
+// sessionTokenSize 会话令牌的随机字节数
+const sessionTokenSize = 32
+
+// SessionConfig 断线重连配置
+type SessionConfig struct {
+	ResumeWindow time.Duration // 断线后可凭令牌恢复会话的时间，超过 HeartbeatConfig.OfflineGrace 时房间位置已被回收
+	BufferSize   int           // 断线期间为玩家缓存的房间消息数，超出时丢弃最早的消息，0 表示不缓存
+}
+
+// DefaultSessionConfig 返回默认断线重连配置
+func DefaultSessionConfig() SessionConfig {
+	return SessionConfig{
+		ResumeWindow: 2 * time.Minute,
+		BufferSize:   256,
+	}
+}
+
+// SetSessionConfig 替换断线重连配置，应在接受连接前调用
+func (s *SimpleServer) SetSessionConfig(config SessionConfig) {
+	s.session = config
+}
+
+// Sessions 玩家会话令牌，每个玩家只有最新签发的令牌有效。令牌只保存在本实例内存中
+type Sessions struct {
+	tokens   map[string]string // 令牌 -> 玩家 ID
+	byPlayer map[string]string // 玩家 ID -> 令牌
+	mutex    sync.Mutex
+}
+
+// NewSessions 创建会话令牌管理器
+func NewSessions() *Sessions {
+	return &Sessions{
+		tokens:   make(map[string]string),
+		byPlayer: make(map[string]string),
+	}
+}
+
+// issue 为玩家签发新令牌，之前的令牌失效
+func (s *Sessions) issue(playerID string) (string, error) {
+	raw := make([]byte, sessionTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generate session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if old, exists := s.byPlayer[playerID]; exists {
+		delete(s.tokens, old)
+	}
+	s.tokens[token] = playerID
+	s.byPlayer[playerID] = token
+	return token, nil
+}
+
+// lookup 返回令牌对应的玩家 ID
+func (s *Sessions) lookup(token string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	playerID, exists := s.tokens[token]
+	return playerID, exists
+}
+
+// revoke 使玩家的令牌失效
+func (s *Sessions) revoke(playerID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if token, exists := s.byPlayer[playerID]; exists {
+		delete(s.tokens, token)
+		delete(s.byPlayer, playerID)
+	}
+}
+
+// playerIDs 返回持有令牌的玩家
+func (s *Sessions) playerIDs() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ids := make([]string, 0, len(s.byPlayer))
+	for playerID := range s.byPlayer {
+		ids = append(ids, playerID)
+	}
+	return ids
+}
+
+// issueSessionToken 为认证或恢复会话的玩家签发令牌，失败时玩家只能重新认证
+func (s *SimpleServer) issueSessionToken(player *Player) string {
+	token, err := s.sessions.issue(player.ID)
+	if err != nil {
+		player.log().Error("Failed to issue session token", logging.Err(err))
+		return ""
+	}
+	return token
+}
+
+// sessionExpired 离线超过恢复窗口的玩家不能再恢复会话。旧连接尚未检测到断开时仍可恢复
+func (s *SimpleServer) sessionExpired(player *Player, now time.Time) bool {
+	return player.Status == "offline" && now.Sub(player.LastSeen) > s.session.ResumeWindow
+}
+
+// sweepSessions 清理已过恢复窗口的令牌
+func (s *SimpleServer) sweepSessions(now time.Time) {
+	for _, playerID := range s.sessions.playerIDs() {
+		player, _ := s.players.get(playerID)
+
+		if player == nil || s.sessionExpired(player, now) {
+			s.sessions.revoke(playerID)
+			if player != nil && player.isGuest() {
+				s.forgetGuest(player)
+			}
+		}
+	}
+}
+
+// handleResume 校验会话令牌，将玩家接入新连接，补发断线期间错过的房间消息
+func (s *SimpleServer) handleResume(ctx context.Context, conn *websocket.Conn, payload *ResumePayload, logger *slog.Logger) *Player {
+	now := time.Now()
+
+	var player *Player
+	if playerID, exists := s.sessions.lookup(payload.SessionToken); exists {
+		player, _ = s.players.get(playerID)
+	}
+	if player == nil || s.sessionExpired(player, now) {
+		if player != nil {
+			s.sessions.revoke(player.ID)
+		}
+		sessionResumesTotal.With("rejected").Inc()
+		s.sendLocalizedError(conn, s.catalog.Negotiate(payload.Locale), ErrCodeResumeFailed, "error.resume_failed", nil)
+		return nil
+	}
+
+	if ban, banned := s.moderation.Active(SanctionBan, player.DID); banned {
+		s.sessions.revoke(player.ID)
+		s.sendError(conn, ErrCodeBanned, ban.message(s.catalog, player.Locale))
+		return nil
+	}
+
+	// 会话期间 DID 可能已被停用，停用后不能再恢复会话
+	if _, err := s.didService.ResolveDIDContext(ctx, player.DID); errors.Is(err, did.ErrDeactivated) {
+		s.sessions.revoke(player.ID)
+		sessionResumesTotal.With("rejected").Inc()
+		s.sendLocalizedError(conn, player.Locale, ErrCodeInvalidDID, "error.invalid_did", i18n.Params{"error": err})
+		return nil
+	}
+
+	locale := player.Locale
+	if payload.Locale != "" {
+		locale = s.catalog.Negotiate(payload.Locale)
+	}
+	protocol, ok := s.negotiateProtocol(conn, payload.ProtocolOffer, locale)
+	if !ok {
+		sessionResumesTotal.With("rejected").Inc()
+		return nil
+	}
+
+	// 恢复会话同样受并发连接策略约束，旧连接尚未超时断开时按策略踢出或拒绝
+	if !s.admitConnection(player.DID, conn, player.Locale) {
+		sessionResumesTotal.With("rejected").Inc()
+		return nil
+	}
+
+	player.logger = logger.With(logging.KeyPlayerDID, player.DID, logging.KeyPlayerID, player.ID)
+	player.setTraceContext(ctx)
+	player.Locale = locale
+	player.setProtocol(protocol)
+	player.Status = "online"
+	player.LastSeen = now
+
+	data := map[string]interface{}{
+		"success":         true,
+		"playerId":        player.ID,
+		"did":             player.DID,
+		"nickname":        player.Nickname,
+		"sessionToken":    s.issueSessionToken(player),
+		"position":        player.Position,
+		"protocolVersion": protocol.Version,
+		"capabilities":    protocol.Capabilities,
+	}
+	room := player.Room
+	var roomID string
+	if room != nil {
+		roomID = room.ID
+		data["room"] = room
+		data["gameState"] = room.GameState
+	}
+
+	// 持有缓冲区锁时先发送恢复结果和错过的消息再接入新连接，保证补发的消息排在新消息之前
+	player.missedMutex.Lock()
+	missed := player.missed
+	player.missed = nil
+	data["missed"] = len(missed)
+	writeMessage(conn, Message{
+		Type:      MsgTypeResume,
+		PlayerID:  player.ID,
+		RoomID:    roomID,
+		Data:      data,
+		Timestamp: now,
+	})
+	for _, msg := range missed {
+		writeMessage(conn, msg)
+	}
+	player.Connection = conn
+	player.missedMutex.Unlock()
+
+	s.persistPlayer(player)
+	s.setPresence(player)
+
+	if room != nil {
+		s.resetStateSync(room, player)
+		s.broadcastToRoom(room, Message{
+			Type:     MsgTypePlayerUpdate,
+			PlayerID: player.ID,
+			RoomID:   room.ID,
+			Data: map[string]interface{}{
+				"action": "reconnected",
+				"player": player,
+			},
+			Timestamp: now,
+		}, player.ID)
+	}
+
+	sessionResumesTotal.With("resumed").Inc()
+	player.log().Info("Player resumed session", "missed", len(missed))
+	s.deliverInbox(player)
+	return player
+}
+
+// deliverOrBuffer 向玩家的连接发送消息，玩家断线时缓存消息等待恢复会话后补发
+func (s *SimpleServer) deliverOrBuffer(player *Player, msg Message) {
+	player.missedMutex.Lock()
+	defer player.missedMutex.Unlock()
+
+	if player.Connection != nil {
+		writeMessage(player.Connection, msg)
+		return
+	}
+	// 状态增量和物理更新会被恢复后的完整状态取代，不需要缓存
+	if s.session.BufferSize <= 0 || msg.Type == MsgTypeStateDelta || msg.Type == MsgTypePhysics {
+		return
+	}
+	if len(player.missed) >= s.session.BufferSize {
+		copy(player.missed, player.missed[1:])
+		player.missed = player.missed[:len(player.missed)-1]
+	}
+	player.missed = append(player.missed, msg)
+}
+
+// clearMissed 丢弃缓存的消息，玩家重新认证或离开房间后不再补发
+func (p *Player) clearMissed() {
+	p.missedMutex.Lock()
+	p.missed = nil
+	p.missedMutex.Unlock()
+}
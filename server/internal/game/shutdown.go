@@ -0,0 +1,140 @@
+package game
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// MsgTypeServerShutdown 服务器即将关闭，客户端应稍后重连
+const MsgTypeServerShutdown = "server_shutdown"
+
+// trackConnection 登记活动连接，服务器正在关闭时返回 false
+func (s *SimpleServer) trackConnection(conn *websocket.Conn) bool {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	if s.draining {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.connWG.Add(1)
+	return true
+}
+
+// untrackConnection 移除已结束的连接
+func (s *SimpleServer) untrackConnection(conn *websocket.Conn) {
+	s.connMutex.Lock()
+	delete(s.conns, conn)
+	s.connMutex.Unlock()
+
+	s.connWG.Done()
+}
+
+// beginDrain 停止接受新连接并返回当前活动连接
+func (s *SimpleServer) beginDrain() []*websocket.Conn {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	s.draining = true
+	conns := make([]*websocket.Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// isDraining 服务器是否正在关闭
+func (s *SimpleServer) isDraining() bool {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	return s.draining
+}
+
+// rejectDuringDrain 关闭期间拒绝新的 WebSocket 连接
+func rejectDuringDrain(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "5")
+	http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+}
+
+// Shutdown 优雅关闭游戏服务器：通知所有连接、停止房间模拟并写入状态，
+// 然后以 close 帧关闭连接并等待连接处理结束。ctx 到期时强制断开剩余连接。
+func (s *SimpleServer) Shutdown(ctx context.Context) error {
+	conns := s.beginDrain()
+	slog.Info("Draining WebSocket connections", "count", len(conns))
+
+	notice := Message{
+		Type: MsgTypeServerShutdown,
+		Data: map[string]interface{}{
+			"reason": "server is shutting down",
+		},
+		Timestamp: time.Now(),
+	}
+	for _, conn := range conns {
+		writeMessage(conn, notice)
+	}
+
+	// 停止房间模拟，之后的房间状态不再变化
+	s.roomMutex.RLock()
+	for _, room := range s.rooms {
+		room.stopLoop()
+	}
+	s.roomMutex.RUnlock()
+
+	if s.persistence != nil {
+		s.snapshotState()
+		if err := waitContext(ctx, s.persistence.Flush); err != nil {
+			slog.Error("Failed to persist game state before closing connections", logging.Err(err))
+		}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(s.heartbeat.WriteWait)
+	}
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, conn := range conns {
+		writeClose(conn, closeFrame, deadline)
+	}
+
+	// 客户端回应 close 帧后读循环结束，连接处理随之退出
+	done := make(chan struct{})
+	go func() {
+		s.connWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Shutdown deadline reached, closing remaining connections")
+		s.cancelBase()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		<-done
+	}
+
+	return waitContext(ctx, s.Close)
+}
+
+// waitContext 执行 fn，ctx 先到期时返回 ctx 的错误，fn 在后台继续完成
+func waitContext(ctx context.Context, fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
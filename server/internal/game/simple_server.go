@@ -1,8 +1,10 @@
 package game
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
@@ -10,8 +12,15 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/google/uuid"
 
+	"github.com/czh0526/game/server/internal/aries"
 	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/ratelimit"
+	gamestorage "github.com/czh0526/game/server/internal/storage"
+	"github.com/czh0526/game/server/internal/tracing"
 	"github.com/czh0526/game/server/internal/vc"
+	"github.com/czh0526/game/server/internal/webhook"
 )
 
 // Player 玩家信息
@@ -21,12 +30,76 @@ type Player struct {
 	Nickname   string          `json:"nickname"`
 	Position   Position        `json:"position"`
 	Level      int             `json:"level"`
+	Experience int             `json:"experience"`
 	Health     int             `json:"health"`
 	MaxHealth  int             `json:"maxHealth"`
 	Status     string          `json:"status"` // online, offline, playing
 	Connection *websocket.Conn `json:"-"`
 	Room       *GameRoom       `json:"-"`
 	LastSeen   time.Time       `json:"lastSeen"`
+	Inventory  *Inventory      `json:"-"`
+	TeamID     string          `json:"teamId,omitempty"`
+	Titles     []string        `json:"titles,omitempty"`
+	Avatar     string          `json:"avatar,omitempty"`
+	Title      string          `json:"title,omitempty"` // 当前佩戴的称号
+	Locale     string          `json:"locale,omitempty"` // 认证时协商的服务端消息语言
+
+	pendingPresentation *presentationRequest
+	lastMoveAt          time.Time
+
+	// 战斗状态，由房间锁保护：上次攻击时间、死亡后的重生时间和重生后的无敌截止时间
+	lastAttackAt      time.Time
+	respawnAt         time.Time
+	invulnerableUntil time.Time
+
+	// 带有连接 ID、玩家 DID 和 ID 字段的日志记录器，认证时设置
+	logger *slog.Logger
+
+	// 正在处理的消息的追踪上下文
+	traceCtx   context.Context
+	traceMutex sync.Mutex
+
+	// 玩家屏蔽的其他玩家 ID
+	mutedPlayers map[string]bool
+	muteMutex    sync.RWMutex
+
+	// 按游戏记录的已解锁技能和已使用的技能点
+	skills           map[string][]string
+	skillPointsSpent int
+	skillMutex       sync.Mutex
+
+	// 已导入的外部凭证 ID，importMutex 同时保护 Titles
+	importedCredentials map[string]bool
+	importMutex         sync.Mutex
+
+	// 资料的隐私设置，profileMutex 同时保护 Avatar 和 Title
+	privacy      PrivacySettings
+	profileMutex sync.Mutex
+
+	// 玩家数据已应玩家请求删除，之后不再持久化，由 profileMutex 保护
+	erased bool
+
+	// 访客玩家持有服务器生成的临时 did:key，不持久化、不获得凭证，升级为登记的 DID 后清除，由 profileMutex 保护
+	guest bool
+
+	// 断线期间错过的房间消息，恢复会话后按顺序补发；missedMutex 同时保护 Connection 的切换
+	missed      []Message
+	missedMutex sync.Mutex
+
+	// 合并窗口内待发送的房间广播
+	outbox      broadcastOutbox
+	outboxMutex sync.Mutex
+
+	// 房间状态同步的发送和确认进度
+	sync      playerSync
+	syncMutex sync.Mutex
+
+	// 当前连接协商的协议版本和能力，认证和恢复会话时更新
+	protocol      Protocol
+	protocolMutex sync.RWMutex
+
+	// 连接处理协程处理每条消息时持有，匹配器等其他协程改变玩家所在房间前也需获取，使二者串行
+	handlerMutex sync.Mutex
 }
 
 // Position 位置信息
@@ -44,18 +117,53 @@ type GameRoom struct {
 	Players     map[string]*Player `json:"players"`
 	GameState   *GameState         `json:"gameState"`
 	CreatedAt   time.Time          `json:"createdAt"`
+	RequiredCredentials []string   `json:"requiredCredentials,omitempty"`
+	RequiredProofs      []vc.CredentialRequirement `json:"requiredProofs,omitempty"`
+	MinLevel            int        `json:"minLevel,omitempty"`
+	PasswordProtected   bool       `json:"passwordProtected,omitempty"`
+	Teams               []*Team    `json:"teams,omitempty"`
+	Mode                string     `json:"mode"`
+	OwnerID             string     `json:"ownerId,omitempty"`
+	// Group 房间组，即分流前的原房间 ID，为空时房间自成一组
+	Group               string     `json:"group,omitempty"`
 	mutex       sync.RWMutex
+
+	// 房间已从 rooms 中删除，之后不能再加入，由 mutex 保护
+	deleted bool
+
+	// 房间的游戏模式
+	mode GameMode
+
+	// 房间密码的加盐摘要，为空表示不需要密码
+	passwordHash string
+
+	// 服务器控制的 NPC，以及任务 NPC 已向哪些玩家颁发过凭证（键为 NPC 物体 ID 和玩家 ID）
+	npcs      []*NPC
+	npcGrants map[string]bool
+
+	// 模拟循环状态
+	inputs       []playerInput
+	inputSeq     uint64
+	inputMutex   sync.Mutex
+	sync         roomSync
+	physics      roomPhysics
+	loopStop     chan struct{}
+	stopOnce     sync.Once
+
+	// 最近的消息速率和 tick 耗时，用于容量策略
+	load roomLoad
 }
 
 // GameState 游戏状态
 type GameState struct {
-	Status     string                 `json:"status"` // waiting, playing, finished
+	Status     string                 `json:"status"` // waiting, playing, finished，见 GameStatus 常量
 	StartTime  *time.Time             `json:"startTime,omitempty"`
 	EndTime    *time.Time             `json:"endTime,omitempty"`
 	Map        *GameMap               `json:"map"`
 	Tasks      []*Task                `json:"tasks"`
 	Events     []*GameEvent           `json:"events"`
 	Properties map[string]interface{} `json:"properties"`
+	Tick       uint64                 `json:"tick"`
 }
 
 // GameMap 游戏地图
@@ -65,6 +173,10 @@ type GameMap struct {
 	Tiles       [][]int      `json:"tiles"`
 	Objects     []*MapObject `json:"objects"`
 	SpawnPoints []Position   `json:"spawnPoints"`
+
+	// 物体的空间索引，按需建立
+	index      *objectIndex
+	indexMutex sync.Mutex
 }
 
 // MapObject 地图对象
@@ -75,6 +187,7 @@ type MapObject struct {
 	Width      int                    `json:"width"`
 	Height     int                    `json:"height"`
 	Properties map[string]interface{} `json:"properties"`
+	Velocity   *Velocity              `json:"velocity,omitempty"` // 设置后每个 tick 按速度移动
 }
 
 // Task 游戏任务
@@ -111,6 +224,7 @@ type Reward struct {
 // GameEvent 游戏事件
 type GameEvent struct {
 	ID        string                 `json:"id"`
+	Seq       uint64                 `json:"seq,omitempty"`
 	Type      string                 `json:"type"`
 	PlayerID  string                 `json:"playerId,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
@@ -139,6 +253,10 @@ const (
 	MsgTypeError        = "error"
 	MsgTypeAuth         = "auth"
 	MsgTypeCredential   = "credential"
+
+	MsgTypePresentationRequest = "presentation_request"
+	MsgTypePresentation        = "presentation"
+	MsgTypePositionCorrection  = "position_correction"
 )
 
 // SimpleServer 简化的游戏服务器
@@ -147,211 +265,648 @@ type SimpleServer struct {
 	vcService  *vc.SimpleService
 	upgrader   websocket.Upgrader
 	
-	// 游戏状态管理
+	// 游戏状态管理。锁顺序：roomMutex → 单个房间的 room.mutex → 玩家和各子系统的锁 → 玩家登记表分片。
+	// 持有 room.mutex 时不获取 roomMutex，也不同时持有两个房间的锁；查找玩家不需要 roomMutex
 	rooms     map[string]*GameRoom
-	players   map[string]*Player
-	roomMutex sync.RWMutex
+	players   *playerRegistry
+	roomMutex sync.RWMutex // 保护 rooms
+
+	// 状态持久化（可选）
+	persistence *Persistence
+
+	// 达到该稀有度的道具颁发所有权凭证，为空表示不颁发
+	itemCredentialRarity string
+
+	// 移动校验与房间模拟循环
+	movement MovementConfig
+	tickRate int
+
+	// 连接心跳与空闲回收
+	heartbeat HeartbeatConfig
+
+	// 按消息类型的玩家限流
+	rateLimit    RateLimitConfig
+	rateLimiters map[string]*ratelimit.Limiter
+
+	// 消息大小限制
+	messageLimits MessageLimitConfig
+
+	// 房间广播的合并发送
+	batching BatchConfig
+
+	// 匹配系统
+	matchmaker *Matchmaker
+	stop       chan struct{}
+
+	// DIDComm 中继（可选）
+	didcomm *aries.DIDCommService
+
+	// 房间成员和在线状态的共享后端
+	roomBackend RoomBackend
+
+	// 房间分片（可选）：directory 记录房间的托管实例，instance 为本实例
+	directory RoomDirectory
+	instance  Instance
+
+	// 跨实例消息总线（可选），instanceID 用于忽略本实例发布的消息
+	bus        MessageBus
+	instanceID string
+
+	// 活动连接，关闭时用于通知和等待连接结束
+	conns     map[*websocket.Conn]struct{}
+	connWG    sync.WaitGroup
+	connMutex sync.Mutex
+	draining  bool
+	closeOnce sync.Once
+
+	// 消息处理的上下文：baseCtx 在服务器关闭时取消，每条消息的处理不超过 requestTimeout
+	baseCtx        context.Context
+	cancelBase     context.CancelFunc
+	requestTimeout time.Duration
+
+	// 玩家封禁、禁言和审计日志
+	moderation *Moderation
+
+	// 房间频道聊天记录和聊天过滤钩子
+	chatHistory *ChatHistory
+	chatFilters []ChatFilter
+
+	// 处于维护模式的游戏
+	maintenance map[string]string
+	adminMutex  sync.RWMutex
+
+	// 已注册的游戏模式
+	gameModes map[string]GameMode
+
+	// 按物体类型的交互处理
+	interactions map[string]InteractionHandler
+
+	// 房间游戏事件的追加日志
+	eventLog *EventLog
+
+	// 对局结果和排行榜
+	results *Results
+
+	// 成就定义和玩家成就进度
+	achievements *Achievements
+
+	// 外部服务的事件订阅（可选）
+	webhooks *webhook.Dispatcher
+
+	// 反作弊检查和违规报告
+	antiCheat *AntiCheat
+
+	// 经验和等级曲线
+	experience ExperienceConfig
+
+	// 按游戏的技能树
+	skillTrees          map[string]*SkillTree
+	skillPointsPerLevel int
+
+	// 已注册的游戏信息，运行时修改时整体替换 GameInfo
+	games      map[string]*GameInfo
+	gamesMutex sync.RWMutex
+
+	// 房间邀请码
+	invites *Invites
+
+	// 玩家之间进行中的交易
+	trades *Trades
+
+	// 公会和公会成员
+	guilds *Guilds
+
+	// 房间组已满时排队的玩家
+	overflow *overflowQueues
+
+	// 玩家收件箱
+	inbox *Inbox
+
+	// 服务端消息的多语言目录
+	catalog *i18n.Catalog
+
+	// 同一 DID 的并发连接策略和已认证连接登记表
+	connectionPolicy ConnectionPolicy
+	connections      *Connections
+
+	// 按游戏 ID 的任务定义和定义文件目录
+	taskDefinitions map[string][]*TaskDefinition
+	taskDir         string
+	taskMutex       sync.RWMutex
+
+	// 断线重连的会话令牌
+	session  SessionConfig
+	sessions *Sessions
+
+	// 是否接受 guest_auth 访客认证
+	guestPlay bool
+
+	// 接受的客户端协议版本
+	protocol ProtocolConfig
+
+	// WebSocket 帧压缩
+	compression CompressionConfig
+
+	// 房间状态的增量同步
+	stateSync StateSyncConfig
+	interest  InterestConfig
+	physics   PhysicsConfig
+	combat    CombatConfig
 }
 
 // NewSimpleServer 创建新的简化游戏服务器
 func NewSimpleServer(didService *did.SimpleService, vcService *vc.SimpleService) (*SimpleServer, error) {
-	return &SimpleServer{
+	memory := gamestorage.NewMemoryProvider()
+	moderation, err := NewModeration(memory)
+	if err != nil {
+		return nil, fmt.Errorf("create moderation: %w", err)
+	}
+	chatHistory, err := NewChatHistory(memory, DefaultChatConfig())
+	if err != nil {
+		return nil, fmt.Errorf("create chat history: %w", err)
+	}
+	eventLog, err := NewEventLog(memory, DefaultEventLogConfig())
+	if err != nil {
+		return nil, fmt.Errorf("create event log: %w", err)
+	}
+	results, err := NewResults(memory)
+	if err != nil {
+		return nil, fmt.Errorf("create results: %w", err)
+	}
+	guilds, err := NewGuilds(memory)
+	if err != nil {
+		return nil, fmt.Errorf("create guilds: %w", err)
+	}
+	achievements, err := NewAchievements(memory)
+	if err != nil {
+		return nil, fmt.Errorf("create achievements: %w", err)
+	}
+	inbox, err := NewInbox(memory)
+	if err != nil {
+		return nil, fmt.Errorf("create inbox: %w", err)
+	}
+	invites, err := NewInvites()
+	if err != nil {
+		return nil, fmt.Errorf("create invites: %w", err)
+	}
+
+	server := &SimpleServer{
 		didService: didService,
 		vcService:  vcService,
 		upgrader: websocket.Upgrader{
+			Subprotocols: subprotocols,
 			CheckOrigin: func(r *http.Request) bool {
 				return true // 允许所有来源，生产环境需要更严格的检查
 			},
 		},
-		rooms:   make(map[string]*GameRoom),
-		players: make(map[string]*Player),
-	}, nil
+		rooms:      make(map[string]*GameRoom),
+		players:    newPlayerRegistry(),
+		movement:   DefaultMovementConfig(),
+		tickRate:   DefaultTickRate,
+
+		itemCredentialRarity: RarityRare,
+		heartbeat:  DefaultHeartbeatConfig(),
+		matchmaker: NewMatchmaker(DefaultMatchmakingConfig()),
+		stop:       make(chan struct{}),
+
+		roomBackend: NewMemoryRoomBackend(),
+		conns:       make(map[*websocket.Conn]struct{}),
+		moderation:  moderation,
+		chatHistory: chatHistory,
+		maintenance: make(map[string]string),
+		gameModes:   make(map[string]GameMode),
+
+		interactions: make(map[string]InteractionHandler),
+		eventLog:     eventLog,
+		results:      results,
+		achievements: achievements,
+		experience:   DefaultExperienceConfig(),
+
+		skillTrees:          make(map[string]*SkillTree),
+		skillPointsPerLevel: DefaultSkillPointsPerLevel,
+		games:               map[string]*GameInfo{defaultGameID: defaultGameInfo()},
+		invites:             invites,
+		trades:              NewTrades(),
+		guilds:              guilds,
+		overflow:            newOverflowQueues(),
+		inbox:               inbox,
+		catalog:             NewCatalog(DefaultLocale),
+		taskDefinitions:     make(map[string][]*TaskDefinition),
+		connectionPolicy:    DefaultConnectionPolicy(),
+		connections:         NewConnections(),
+		session:             DefaultSessionConfig(),
+		sessions:            NewSessions(),
+		protocol:            DefaultProtocolConfig(),
+		stateSync:           DefaultStateSyncConfig(),
+		interest:            DefaultInterestConfig(),
+		physics:             DefaultPhysicsConfig(),
+		combat:              DefaultCombatConfig(),
+		requestTimeout:      DefaultRequestTimeout,
+	}
+	server.registerDefaultInteractions()
+
+	server.SetRateLimitConfig(DefaultRateLimitConfig())
+	server.SetMessageLimitConfig(DefaultMessageLimitConfig())
+	server.SetBatchConfig(DefaultBatchConfig())
+	server.SetCompressionConfig(DefaultCompressionConfig())
+	server.SetAntiCheatConfig(DefaultAntiCheatConfig())
+	for _, mode := range []GameMode{FreeRoamMode{}, NewTaskRaceMode()} {
+		if err := server.RegisterGameMode(mode); err != nil {
+			return nil, err
+		}
+	}
+	if err := server.RegisterSkillTree(defaultSkillTree()); err != nil {
+		return nil, err
+	}
+	if err := server.registerDefaultAchievements(); err != nil {
+		return nil, err
+	}
+
+	server.baseCtx, server.cancelBase = context.WithCancel(context.Background())
+	go server.runMatchmaking(server.stop)
+	go server.runReaper(server.stop)
+	return server, nil
+}
+
+// NewSimpleServerWithPersistence 创建带状态持久化的游戏服务器，启动时恢复已保存的玩家和房间
+func NewSimpleServerWithPersistence(didService *did.SimpleService, vcService *vc.SimpleService, persistence *Persistence) (*SimpleServer, error) {
+	server, err := NewSimpleServer(didService, vcService)
+	if err != nil {
+		return nil, err
+	}
+
+	server.persistence = persistence
+	if err := server.restoreState(); err != nil {
+		return nil, fmt.Errorf("restore game state: %w", err)
+	}
+
+	persistence.Start(server.snapshotState)
+	return server, nil
+}
+
+// Close 关闭游戏服务器，写入所有待持久化的状态，可重复调用
+func (s *SimpleServer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.cancelBase()
+
+		s.releaseRoomMembers()
+		s.releaseRooms()
+		if err := s.roomBackend.Close(); err != nil {
+			slog.Error("Failed to close room backend", logging.Err(err))
+		}
+		if s.bus != nil {
+			if err := s.bus.Close(); err != nil {
+				slog.Error("Failed to close message bus", logging.Err(err))
+			}
+		}
+
+		if err := s.eventLog.Close(); err != nil {
+			slog.Error("Failed to close event log", logging.Err(err))
+		}
+		if s.persistence != nil {
+			err = s.persistence.Close()
+		}
+	})
+	return err
 }
 
 // HandleWebSocket 处理WebSocket连接
 func (s *SimpleServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if s.isDraining() {
+		rejectDuringDrain(w)
+		return
+	}
+
+	connID := logging.NewID()
+	logger := logging.FromContext(r.Context()).With(logging.KeyConnectionID, connID, "remote_addr", r.RemoteAddr)
+
+	conn, err := s.upgrader.Upgrade(s.compressionWriter(w, r), r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade failed: %v", err)
+		logger.Warn("WebSocket upgrade failed", logging.Err(err))
 		return
 	}
 	defer conn.Close()
+	s.configureCompression(conn)
+	// 所有消息经写协程发送，连接上同一时间只有一个写操作
+	defer startWriter(conn, s.heartbeat.WriteWait)()
 
-	log.Printf("New WebSocket connection from %s", r.RemoteAddr)
+	if !s.trackConnection(conn) {
+		closeFrame := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server shutting down")
+		writeClose(conn, closeFrame, time.Now().Add(s.heartbeat.WriteWait))
+		return
+	}
+	defer s.untrackConnection(conn)
+
+	connectedPlayersGauge.Inc()
+	defer connectedPlayersGauge.Dec()
+
+	logger.Info("New WebSocket connection")
 
 	// 处理连接
-	s.handleConnection(conn)
+	s.handleConnection(conn, connID, logger)
 }
 
 // handleConnection 处理WebSocket连接
-func (s *SimpleServer) handleConnection(conn *websocket.Conn) {
+func (s *SimpleServer) handleConnection(conn *websocket.Conn, connID string, logger *slog.Logger) {
 	var player *Player
-	
+	// 连续超限的消息数，kick 策略据此断开连接
+	violations := 0
+	// 连续格式错误或超限的消息数，达到 MaxViolations 时断开连接
+	invalidMessages := 0
+	// auth_challenge 颁发的 nonce，auth 和 upgrade_guest 使用后失效
+	var challenge authChallenge
+
+	// 超过读取上限的帧会使连接以 close 1009 断开
+	if s.messageLimits.ReadLimit > 0 {
+		conn.SetReadLimit(s.messageLimits.ReadLimit)
+	}
+
+	stopHeartbeat := s.startHeartbeat(conn)
+	defer stopHeartbeat()
+
 	for {
-		var msg Message
-		err := conn.ReadJSON(&msg)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
-			log.Printf("Read message error: %v", err)
+			if errors.Is(err, websocket.ErrReadLimit) {
+				logger.Warn("WebSocket frame exceeds read limit, connection closed", "limit", s.messageLimits.ReadLimit)
+			} else {
+				logger.Info("WebSocket connection closed", logging.Err(err))
+			}
 			break
 		}
 
-		msg.Timestamp = time.Now()
+		// 收到任何消息都说明连接存活
+		conn.SetReadDeadline(time.Now().Add(s.heartbeat.PongWait))
+		if player != nil {
+			player.LastSeen = time.Now()
+		}
+
+		msg, err := decodeInbound(conn, data)
+		if err != nil || msg.Type == "" {
+			if !s.rejectMessage(conn, logger, invalidMessageError(err), &invalidMessages) {
+				break
+			}
+			continue
+		}
+
+		if protocolErr := s.checkMessageSize(msg.Type, len(msg.Data)); protocolErr != nil {
+			if !s.rejectMessage(conn, logger, protocolErr, &invalidMessages) {
+				break
+			}
+			continue
+		}
+
+		payload, protocolErr := decodePayload(msg.Type, msg.Data)
+		if protocolErr != nil {
+			if !s.rejectMessage(conn, logger, protocolErr, &invalidMessages) {
+				break
+			}
+			continue
+		}
+		invalidMessages = 0
+		messagesTotal.With(msg.Type).Inc()
+		if player != nil && player.Room != nil {
+			player.Room.load.message()
+		}
+		if player != nil {
+			player.log().Debug("WebSocket message", "type", msg.Type)
+		} else {
+			logger.Debug("WebSocket message", "type", msg.Type)
+		}
 
-		// 处理消息
-		switch msg.Type {
-		case MsgTypeAuth:
-			player = s.handleAuth(conn, &msg)
-		case MsgTypeJoinRoom:
-			if player != nil {
-				s.handleJoinRoom(player, &msg)
+		// 除 auth、guest_auth、resume 和 ping 外，其余消息都需要先完成认证
+		if player == nil && msg.Type != MsgTypeAuth && msg.Type != MsgTypeAuthChallenge && msg.Type != MsgTypeGuestAuth && msg.Type != MsgTypeResume && msg.Type != MsgTypePing {
+			s.sendLocalizedError(conn, "", ErrCodeUnauthenticated, "error.unauthenticated", i18n.Params{"type": msg.Type})
+			continue
+		}
+
+		if player != nil {
+			s.antiCheat.shadowLog(player, msg.Type, msg.RoomID, payload)
+			s.checkMessageRate(player, msg.Type)
+			if ok, wait := s.allowMessage(player, msg.Type); !ok {
+				violations++
+				if !s.throttle(conn, player, msg.Type, wait, violations) {
+					break
+				}
+				continue
 			}
-		case MsgTypeLeaveRoom:
-			if player != nil {
-				s.handleLeaveRoom(player, &msg)
+			violations = 0
+		}
+
+		// 处理消息，每条消息是一个独立的追踪，处理超时或服务器关闭时取消
+		msgCtx, cancel := s.messageContext()
+		ctx, span := tracing.StartKind(msgCtx, "websocket "+msg.Type, tracing.SpanKindServer,
+			tracing.String("websocket.message_type", msg.Type),
+			tracing.String(logging.KeyConnectionID, connID),
+		)
+		if player != nil {
+			span.SetAttributes(tracing.String(logging.KeyPlayerDID, player.DID))
+			player.setTraceContext(ctx)
+		}
+
+		// 认证消息可能更换 player，解锁时使用加锁时的玩家
+		handled := player
+		if handled != nil {
+			handled.handlerMutex.Lock()
+		}
+		switch p := payload.(type) {
+		case *PingPayload:
+			s.handlePing(conn, p)
+		case *AuthPayload:
+			if authenticated := s.handleAuth(ctx, conn, p, &challenge, logger); authenticated != nil {
+				// 同一连接以其他 DID 重新认证时释放原来的玩家
+				if player != nil && player != authenticated {
+					s.releaseConnection(player, conn)
+				}
+				player = authenticated
 			}
-		case MsgTypePlayerMove:
-			if player != nil {
-				s.handlePlayerMove(player, &msg)
+		case *GuestAuthPayload:
+			if guest := s.handleGuestAuth(ctx, conn, p, logger); guest != nil {
+				if player != nil && player != guest {
+					s.releaseConnection(player, conn)
+				}
+				player = guest
 			}
-		case MsgTypePlayerAction:
-			if player != nil {
-				s.handlePlayerAction(player, &msg)
+		case *UpgradeGuestPayload:
+			s.handleUpgradeGuest(ctx, conn, player, p, &challenge, logger)
+		case *StateAckPayload:
+			s.handleStateAck(player, p)
+		case *ResumePayload:
+			if resumed := s.handleResume(ctx, conn, p, logger); resumed != nil {
+				if player != nil && player != resumed {
+					s.releaseConnection(player, conn)
+				}
+				player = resumed
 			}
-		case MsgTypeChat:
-			if player != nil {
-				s.handleChat(player, &msg)
+		case *JoinRoomPayload:
+			s.handleJoinRoom(player, p)
+		case *MovePayload:
+			s.handlePlayerMove(player, p)
+		case *ActionPayload:
+			s.handlePlayerAction(player, p)
+		case *ChatPayload:
+			s.handleChat(player, p)
+		case *ChatHistoryPayload:
+			s.handleChatHistory(player, p)
+		case *ChatMutePayload:
+			s.handleChatMute(player, p)
+		case *JoinTeamPayload:
+			s.handleJoinTeam(player, p)
+		case *RoomInvitePayload:
+			s.handleRoomInvite(player, p)
+		case *PresentationPayload:
+			s.handlePresentation(player, p)
+		case *QueueMatchPayload:
+			s.handleQueueMatch(player, p)
+		case *InventoryPayload:
+			s.handleInventory(player, p)
+		case *TradePayload:
+			s.handleTrade(player, p)
+		case *SkillsPayload:
+			s.handleSkills(player, p)
+		case *GuildPayload:
+			s.handleGuild(player, p)
+		case *AchievementsPayload:
+			s.handleAchievements(player, p)
+		case *InboxPayload:
+			s.handleInbox(player, p)
+		case *DIDCommPayload:
+			s.handleDIDComm(player, p)
+		case *EmptyPayload:
+			switch msg.Type {
+			case MsgTypeLeaveRoom:
+				s.handleLeaveRoom(player)
+			case MsgTypeCancelMatch:
+				s.handleCancelMatch(player)
+			case MsgTypeAuthChallenge:
+				s.issueAuthChallenge(conn, &challenge)
 			}
-		default:
-			log.Printf("Unknown message type: %s", msg.Type)
+		}
+		if handled != nil {
+			handled.handlerMutex.Unlock()
+		}
+
+		span.End()
+		cancel()
+		if player != nil {
+			player.setTraceContext(nil)
 		}
 	}
 
-	// 连接断开时清理
+	// 连接断开时注销连接，玩家没有其他连接时按断线处理
 	if player != nil {
-		s.handleDisconnect(player)
+		s.releaseConnection(player, conn)
 	}
 }
 
-// handleAuth 处理身份认证
-func (s *SimpleServer) handleAuth(conn *websocket.Conn, msg *Message) *Player {
-	authData, ok := msg.Data.(map[string]interface{})
-	if !ok {
-		s.sendError(conn, "Invalid auth data")
-		return nil
-	}
+// handleAuth 处理身份认证，连接必须用 DID 的认证密钥签名 auth_challenge 颁发的 nonce
+func (s *SimpleServer) handleAuth(ctx context.Context, conn *websocket.Conn, payload *AuthPayload, challenge *authChallenge, logger *slog.Logger) *Player {
+	playerDID := payload.DID
+	locale := s.catalog.Negotiate(payload.Locale)
 
-	playerDID, ok := authData["did"].(string)
-	if !ok {
-		s.sendError(conn, "Missing player DID")
+	if ban, banned := s.moderation.Active(SanctionBan, playerDID); banned {
+		s.sendError(conn, ErrCodeBanned, ban.message(s.catalog, locale))
 		return nil
 	}
 
 	// 验证DID
-	didResponse, err := s.didService.ResolveDID(playerDID)
+	didResponse, err := s.didService.ResolveDIDContext(ctx, playerDID)
 	if err != nil {
-		s.sendError(conn, fmt.Sprintf("Invalid DID: %v", err))
+		s.sendLocalizedError(conn, locale, ErrCodeInvalidDID, "error.invalid_did", i18n.Params{"error": err})
 		return nil
 	}
-
-	// 创建或获取玩家
-	player := s.getOrCreatePlayer(playerDID, didResponse.DIDDoc.ID)
-	player.Connection = conn
-	player.Status = "online"
-	player.LastSeen = time.Now()
-
-	// 发送认证成功消息
-	authResponse := Message{
-		Type: MsgTypeAuth,
-		Data: map[string]interface{}{
-			"success":  true,
-			"playerId": player.ID,
-			"did":      player.DID,
-			"nickname": player.Nickname,
-		},
-		Timestamp: time.Now(),
+	if err := verifyDIDProof(challenge, didResponse.DIDDoc, &payload.DIDProof); err != nil {
+		s.sendLocalizedError(conn, locale, ErrCodeAuthFailed, "error.auth_failed", i18n.Params{"error": err})
+		return nil
 	}
-	conn.WriteJSON(authResponse)
 
-	log.Printf("Player authenticated: %s (%s)", player.Nickname, player.DID)
-	return player
-}
-
-// handleCompleteTask 处理完成任务
-func (s *SimpleServer) handleCompleteTask(player *Player, actionData map[string]interface{}) {
-	taskID, ok := actionData["taskId"].(string)
-	if !ok {
-		return
+	// 访客的临时 DID 只能凭会话令牌恢复，不能直接认证
+	if existing := s.players.getByDID(playerDID); existing != nil && existing.isGuest() {
+		s.sendLocalizedError(conn, locale, ErrCodeInvalidDID, "error.invalid_did", i18n.Params{"error": "guest DIDs can only resume their session"})
+		return nil
 	}
 
-	// 查找任务
-	var task *Task
-	for _, t := range player.Room.GameState.Tasks {
-		if t.ID == taskID {
-			task = t
-			break
-		}
+	protocol, ok := s.negotiateProtocol(conn, payload.ProtocolOffer, locale)
+	if !ok {
+		return nil
 	}
 
-	if task == nil || task.Status != "active" {
-		return
+	// 按并发连接策略登记连接，被接管的旧连接在此断开
+	if !s.admitConnection(playerDID, conn, locale) {
+		return nil
 	}
 
-	// 标记任务完成
-	task.Status = "completed"
+	// 创建或获取玩家，玩家已在其他连接上时由新连接接管房间成员身份和位置
+	player := s.getOrCreatePlayer(playerDID, didResponse.DIDDoc.ID)
+	s.startSession(ctx, conn, player, locale, protocol, logger)
+	s.importCredentials(player, defaultGameID, payload.Credentials)
+	s.deliverInbox(player)
+	return player
+}
 
-	// 颁发成就凭证
-	credential, err := s.vcService.IssueAchievementCredential(
-		player.DID, 
-		player.Room.GameID, 
-		player.ID, 
-		task.Name, 
-		100, // 默认分数
-	)
-	if err != nil {
-		log.Printf("Failed to issue credential: %v", err)
-		return
+// startSession 将认证的玩家接入连接并发送认证结果，接管其他连接时带上玩家所在的房间
+func (s *SimpleServer) startSession(ctx context.Context, conn *websocket.Conn, player *Player, locale string, protocol Protocol, logger *slog.Logger) {
+	player.Locale = locale
+	player.setProtocol(protocol)
+	player.clearMissed()
+	player.logger = logger.With(logging.KeyPlayerDID, player.DID, logging.KeyPlayerID, player.ID)
+	player.setTraceContext(ctx)
+	player.Status = "online"
+	player.LastSeen = time.Now()
+	s.persistPlayer(player)
+	s.setPresence(player)
+
+	// 发送认证成功消息，接管其他连接时带上玩家所在的房间
+	data := map[string]interface{}{
+		"success":         true,
+		"playerId":        player.ID,
+		"did":             player.DID,
+		"nickname":        player.Nickname,
+		"sessionToken":    s.issueSessionToken(player),
+		"resumeWindow":    int(s.session.ResumeWindow.Seconds()),
+		"locale":          locale,
+		"protocolVersion": protocol.Version,
+		"capabilities":    protocol.Capabilities,
 	}
-
-	// 通知玩家获得凭证
-	player.Connection.WriteJSON(Message{
-		Type:     MsgTypeCredential,
-		PlayerID: player.ID,
-		Data: map[string]interface{}{
-			"credential": credential,
-			"message":    fmt.Sprintf("获得凭证: %s", task.Name),
-		},
-		Timestamp: time.Now(),
-	})
-
-	// 通知任务完成
-	s.broadcastToRoom(player.Room, Message{
-		Type:     MsgTypeTaskUpdate,
-		PlayerID: player.ID,
-		RoomID:   player.Room.ID,
-		Data: map[string]interface{}{
-			"task":   task,
-			"action": "completed",
-		},
+	if player.isGuest() {
+		data["guest"] = true
+	}
+	room := player.Room
+	var roomID string
+	if room != nil {
+		roomID = room.ID
+		data["room"] = room
+	}
+	authResponse := Message{
+		Type:      MsgTypeAuth,
+		RoomID:    roomID,
+		Data:      data,
 		Timestamp: time.Now(),
-	}, "")
+	}
+	// 持有缓冲区锁时先发送认证结果再接入新连接，保证认证结果排在房间消息之前
+	player.missedMutex.Lock()
+	writeMessage(conn, authResponse)
+	player.Connection = conn
+	player.missedMutex.Unlock()
+	if room != nil {
+		s.resetStateSync(room, player)
+	}
 
-	log.Printf("Player %s completed task %s", player.Nickname, task.Name)
+	player.log().Info("Player authenticated", "nickname", player.Nickname)
 }
 
 // 其他方法保持不变，只是简化了依赖
 func (s *SimpleServer) getOrCreatePlayer(playerDID, didID string) *Player {
-	s.roomMutex.Lock()
-	defer s.roomMutex.Unlock()
-
-	// 尝试从现有玩家中查找
-	for _, player := range s.players {
-		if player.DID == playerDID {
-			return player
-		}
-	}
+	return s.players.getOrCreate(playerDID, func() *Player {
+		return newPlayer(playerDID)
+	})
+}
 
-	// 创建新玩家
-	player := &Player{
+// newPlayer 创建持有 DID 的新玩家
+func newPlayer(playerDID string) *Player {
+	return &Player{
 		ID:        uuid.New().String(),
 		DID:       playerDID,
 		Nickname:  fmt.Sprintf("Player_%s", playerDID[len(playerDID)-8:]),
@@ -361,30 +916,99 @@ func (s *SimpleServer) getOrCreatePlayer(playerDID, didID string) *Player {
 		MaxHealth: 100,
 		Status:    "online",
 		LastSeen:  time.Now(),
+		Inventory: NewInventory(DefaultInventoryCapacity),
 	}
-
-	s.players[player.ID] = player
-	return player
 }
 
-func (s *SimpleServer) handleJoinRoom(player *Player, msg *Message) {
-	joinData, ok := msg.Data.(map[string]interface{})
-	if !ok {
-		s.sendErrorToPlayer(player, "Invalid join room data")
+func (s *SimpleServer) handleJoinRoom(player *Player, payload *JoinRoomPayload) {
+	s.cancelQueuedJoin(player)
+	if ban, banned := s.moderation.Active(SanctionBan, player.DID); banned {
+		s.sendErrorToPlayer(player, ErrCodeBanned, ban.message(s.catalog, player.Locale))
+		return
+	}
+	gameID := s.gameIDForRoom(payload.RoomID)
+	if message, inMaintenance := s.maintenanceFor(gameID); inMaintenance {
+		s.sendErrorToPlayer(player, ErrCodeMaintenance, message)
+		return
+	}
+	if rejection := s.guestRejection(player, gameID); rejection != nil {
+		s.sendEntryRejection(player, ErrCodeEntryRejected, payload.RoomID, rejection)
 		return
 	}
 
-	roomID, ok := joinData["roomId"].(string)
-	if !ok {
-		roomID = "default"
+	var room *GameRoom
+	if payload.InviteCode != "" {
+		// 邀请码只能加入已存在的房间
+		invite, err := s.invites.Check(payload.InviteCode)
+		if err != nil {
+			s.sendEntryRejection(player, ErrCodeEntryRejected, payload.RoomID, inviteRejection(err))
+			return
+		}
+		s.roomMutex.RLock()
+		room = s.rooms[invite.RoomID]
+		s.roomMutex.RUnlock()
+		if room == nil {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeRoomNotFound, "error.room_not_found", nil)
+			return
+		}
+	} else {
+		var err error
+		room, err = s.getOrCreateRoom(payload.RoomID, defaultGameID, &RoomOptions{
+			RequiredCredentials: payload.RequiredCredentials,
+			RequiredProofs:      payload.RequiredProofs,
+			Password:            payload.Password,
+			MinLevel:            payload.MinLevel,
+			Teams:               payload.Teams,
+			Mode:                payload.Mode,
+			OwnerID:             player.ID,
+		})
+		var redirect *RoomRedirectError
+		if errors.As(err, &redirect) {
+			s.sendRoomRedirect(player, redirect)
+			return
+		}
+		if err != nil {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeJoinFailed, "error.create_room", i18n.Params{"error": err})
+			return
+		}
+		// 房间达到软上限或负载过高时分流到同组房间
+		if room = s.placeInRoomGroup(player, room, payload); room == nil {
+			return
+		}
 	}
 
-	room := s.getOrCreateRoom(roomID, "default")
-	
+	// 先导入外部凭证，提升后的等级参与进入策略检查
+	s.importCredentials(player, room.GameID, payload.Credentials)
+
+	if rejection := room.checkEntry(player, payload.Password, payload.InviteCode != ""); rejection != nil {
+		s.sendEntryRejection(player, ErrCodeEntryRejected, room.ID, rejection)
+		return
+	}
+
+	// 通过其他检查后才消耗邀请码的使用次数
+	if payload.InviteCode != "" {
+		if _, err := s.invites.Use(payload.InviteCode); err != nil {
+			s.sendEntryRejection(player, ErrCodeEntryRejected, room.ID, inviteRejection(err))
+			return
+		}
+	}
+
+	// 需要出示凭证的房间，先向玩家发起表述请求
+	if len(room.RequiredCredentials) > 0 {
+		s.requestPresentation(player, room)
+		return
+	}
+
+	s.admitPlayer(player, room)
+}
+
+// completeJoinRoom 将玩家加入房间并通知房间内其他玩家
+func (s *SimpleServer) completeJoinRoom(player *Player, room *GameRoom) {
 	if err := s.joinRoom(player, room); err != nil {
-		s.sendErrorToPlayer(player, fmt.Sprintf("Failed to join room: %v", err))
+		s.sendLocalizedErrorToPlayer(player, ErrCodeJoinFailed, "error.join_room", i18n.Params{"error": err})
 		return
 	}
+	s.antiCheat.joined(player, room.ID, time.Now())
 
 	joinResponse := Message{
 		Type:     MsgTypeJoinRoom,
@@ -397,7 +1021,11 @@ func (s *SimpleServer) handleJoinRoom(player *Player, msg *Message) {
 		},
 		Timestamp: time.Now(),
 	}
-	player.Connection.WriteJSON(joinResponse)
+	writeMessage(player.Connection, joinResponse)
+	s.resetStateSync(room, player)
+
+	s.persistPlayer(player)
+	s.persistRoom(room)
 
 	s.broadcastToRoom(room, Message{
 		Type:     MsgTypePlayerUpdate,
@@ -410,36 +1038,87 @@ func (s *SimpleServer) handleJoinRoom(player *Player, msg *Message) {
 		Timestamp: time.Now(),
 	}, player.ID)
 
-	log.Printf("Player %s joined room %s", player.Nickname, room.ID)
+	s.publishPlayerJoined(room, player)
+	player.log().Info("Player joined room")
 }
 
-func (s *SimpleServer) getOrCreateRoom(roomID, gameID string) *GameRoom {
+// RoomOptions 创建房间时的进入策略，仅在房间不存在时生效
+type RoomOptions struct {
+	RequiredCredentials []string // 进入房间需出示的凭证类型
+	// RequiredProofs 进入房间需通过 DIDComm Present Proof 证明的凭证及属性条件
+	RequiredProofs []vc.CredentialRequirement
+	Password            string   // 进入房间需提供的密码
+	MinLevel            int      // 进入房间的最低等级
+	Teams               int      // 房间的队伍数，0 表示不分队
+	Mode                string   // 游戏模式名称，为空时使用默认模式
+	OwnerID             string   // 创建房间的玩家，可以生成邀请码
+	Group               string   // 房间组，分流创建的同组房间为原房间 ID
+}
+
+func (s *SimpleServer) getOrCreateRoom(roomID, gameID string, options *RoomOptions) (*GameRoom, error) {
 	s.roomMutex.Lock()
 	defer s.roomMutex.Unlock()
 
 	room, exists := s.rooms[roomID]
 	if exists {
-		return room
+		return room, nil
+	}
+	modeName := ""
+	if options != nil {
+		modeName = options.Mode
+	}
+	mode, found := s.gameMode(modeName)
+	if !found {
+		return nil, fmt.Errorf("unknown game mode: %s", modeName)
 	}
 
 	room = &GameRoom{
 		ID:         roomID,
 		Name:       fmt.Sprintf("Room %s", roomID),
 		GameID:     gameID,
-		MaxPlayers: 10,
+		MaxPlayers: s.roomMaxPlayers(gameID),
 		Players:    make(map[string]*Player),
-		GameState:  s.createDefaultGameState(),
 		CreatedAt:  time.Now(),
+		Mode:       mode.Name(),
+		mode:       mode,
+	}
+	room.GameState = mode.Init(room)
+	s.assignTasks(room)
+	if options != nil {
+		room.RequiredCredentials = options.RequiredCredentials
+		room.RequiredProofs = options.RequiredProofs
+		room.MinLevel = options.MinLevel
+		room.OwnerID = options.OwnerID
+		room.Group = options.Group
+		if options.Password != "" {
+			hash, err := hashRoomPassword(options.Password)
+			if err != nil {
+				return nil, err
+			}
+			room.passwordHash = hash
+			room.PasswordProtected = true
+		}
+		if options.Teams > 0 {
+			room.Teams = newTeams(options.Teams, room.GameState.Map.SpawnPoints)
+		}
+	}
+
+	// 启用分片时只创建分配给本实例的房间
+	if err := s.claimRoom(roomID); err != nil {
+		return nil, err
 	}
 
 	s.rooms[roomID] = room
-	log.Printf("Created new room: %s", roomID)
-	return room
+	roomsGauge.Inc()
+	s.startRoomLoop(room)
+	room.log().Info("Created new room", "game_id", gameID)
+	return room, nil
 }
 
-func (s *SimpleServer) createDefaultGameState() *GameState {
+// defaultGameState 默认地图和新手任务，游戏模式可在此基础上修改
+func defaultGameState() *GameState {
 	return &GameState{
-		Status: "waiting",
+		Status: GameStatusWaiting,
 		Map: &GameMap{
 			Width:  800,
 			Height: 600,
@@ -466,7 +1145,7 @@ func (s *SimpleServer) createDefaultGameState() *GameState {
 				Name:        "Welcome to the Game",
 				Description: "Complete your first steps in the game",
 				Type:        "tutorial",
-				Status:      "available",
+				Status:      TaskAvailable,
 				Objectives: []*Objective{
 					{
 						ID:          "move_around",
@@ -480,8 +1159,8 @@ func (s *SimpleServer) createDefaultGameState() *GameState {
 				},
 				Rewards: []*Reward{
 					{
-						Type:  "credential",
-						Value: "WelcomeCredential",
+						Type:  RewardExperience,
+						Value: 50,
 					},
 				},
 			},
@@ -491,36 +1170,72 @@ func (s *SimpleServer) createDefaultGameState() *GameState {
 	}
 }
 
+// errRoomFull 房间人数已达上限
+var errRoomFull = errors.New("room is full")
+
+// joinRoom 将玩家加入房间。玩家先离开原房间再获取新房间的锁，任何时候只持有一个房间锁
 func (s *SimpleServer) joinRoom(player *Player, room *GameRoom) error {
-	room.mutex.Lock()
-	defer room.mutex.Unlock()
+	if player.Room == room {
+		return nil
+	}
 
-	if len(room.Players) >= room.MaxPlayers {
-		return fmt.Errorf("room is full")
+	room.mutex.RLock()
+	full := s.roomMemberCount(room) >= room.MaxPlayers
+	room.mutex.RUnlock()
+	if full {
+		return errRoomFull
 	}
 
 	if player.Room != nil {
 		s.leaveRoom(player)
 	}
 
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+
+	// 获取锁之前房间可能已被删除或被其他玩家占满
+	if room.deleted {
+		return ErrRoomNotFound
+	}
+	if s.roomMemberCount(room) >= room.MaxPlayers {
+		return errRoomFull
+	}
+
 	room.Players[player.ID] = player
 	player.Room = room
+	s.addRoomMember(room.ID, player.ID)
+	// 死亡后离开房间的玩家重新加入时直接复活
+	player.revive()
 
 	if len(room.GameState.Map.SpawnPoints) > 0 {
 		spawnIndex := len(room.Players) % len(room.GameState.Map.SpawnPoints)
 		player.Position = room.GameState.Map.SpawnPoints[spawnIndex]
 	}
 
+	// 重新加入时回到原来的队伍
+	if team := room.teamOf(player.ID); team != nil {
+		if point, ok := team.spawnPoint(team.memberIndex(player.ID)); ok {
+			player.Position = point
+		}
+		player.TeamID = team.ID
+	}
+
+	if room.mode != nil {
+		room.mode.OnPlayerJoin(room, player)
+	}
+
 	return nil
 }
 
-func (s *SimpleServer) handleLeaveRoom(player *Player, msg *Message) {
+func (s *SimpleServer) handleLeaveRoom(player *Player) {
+	s.cancelQueuedJoin(player)
 	if player.Room == nil {
 		return
 	}
 
 	room := player.Room
 	s.leaveRoom(player)
+	s.persistPlayer(player)
 
 	leaveResponse := Message{
 		Type:     MsgTypeLeaveRoom,
@@ -530,7 +1245,7 @@ func (s *SimpleServer) handleLeaveRoom(player *Player, msg *Message) {
 		},
 		Timestamp: time.Now(),
 	}
-	player.Connection.WriteJSON(leaveResponse)
+	writeMessage(player.Connection, leaveResponse)
 
 	s.broadcastToRoom(room, Message{
 		Type:     MsgTypePlayerUpdate,
@@ -544,116 +1259,118 @@ func (s *SimpleServer) handleLeaveRoom(player *Player, msg *Message) {
 	}, player.ID)
 }
 
+// leaveRoom 将玩家移出房间，房间空了时删除房间
 func (s *SimpleServer) leaveRoom(player *Player) {
-	if player.Room == nil {
+	room := player.Room
+	if room == nil {
 		return
 	}
 
-	room := player.Room
 	room.mutex.Lock()
-	defer room.mutex.Unlock()
-
 	delete(room.Players, player.ID)
+	room.removeFromTeam(player)
 	player.Room = nil
+	s.removeRoomMember(room.ID, player.ID)
+	empty := len(room.Players) == 0
+	room.mutex.Unlock()
 
-	if len(room.Players) == 0 {
-		s.roomMutex.Lock()
-		delete(s.rooms, room.ID)
-		s.roomMutex.Unlock()
-		log.Printf("Deleted empty room: %s", room.ID)
+	// 空出的位置让给排队的玩家，加入时需要获取房间锁
+	go s.admitQueued(room.group())
+
+	if empty {
+		s.deleteEmptyRoom(room)
 	}
 }
 
-func (s *SimpleServer) handlePlayerMove(player *Player, msg *Message) {
-	if player.Room == nil {
-		return
+// deleteEmptyRoom 删除没有玩家的房间。按锁顺序先获取 roomMutex 再获取房间锁，
+// 释放房间锁期间可能已有玩家加入或房间已被删除，此时不做任何事
+func (s *SimpleServer) deleteEmptyRoom(room *GameRoom) {
+	s.roomMutex.Lock()
+	room.mutex.Lock()
+	deletable := !room.deleted && len(room.Players) == 0 && s.rooms[room.ID] == room
+	if deletable {
+		room.deleted = true
+		delete(s.rooms, room.ID)
 	}
-
-	moveData, ok := msg.Data.(map[string]interface{})
-	if !ok {
+	room.mutex.Unlock()
+	s.roomMutex.Unlock()
+	if !deletable {
 		return
 	}
 
-	x, xOk := moveData["x"].(float64)
-	y, yOk := moveData["y"].(float64)
-	if !xOk || !yOk {
-		return
+	roomsGauge.Dec()
+	room.stopLoop()
+	s.releaseRoom(room.ID)
+	s.invites.RevokeRoom(room.ID)
+	if s.persistence != nil {
+		s.persistence.DeleteRoom(room.ID)
 	}
-
-	player.Position.X = x
-	player.Position.Y = y
-
-	s.broadcastToRoom(player.Room, Message{
-		Type:     MsgTypePlayerMove,
-		PlayerID: player.ID,
-		RoomID:   player.Room.ID,
-		Data: map[string]interface{}{
-			"position": player.Position,
-		},
-		Timestamp: time.Now(),
-	}, player.ID)
+	room.log().Info("Deleted empty room")
 }
 
-func (s *SimpleServer) handlePlayerAction(player *Player, msg *Message) {
+func (s *SimpleServer) handlePlayerMove(player *Player, payload *MovePayload) {
 	if player.Room == nil {
 		return
 	}
 
-	actionData, ok := msg.Data.(map[string]interface{})
-	if !ok {
-		return
-	}
+	// 移动在房间的下一个 tick 中统一校验和广播
+	player.Room.enqueueInput(playerInput{
+		PlayerID:   player.ID,
+		Type:       inputMove,
+		Position:   payload.Position(),
+		ReceivedAt: time.Now(),
+	})
+}
 
-	action, ok := actionData["action"].(string)
-	if !ok {
+// sendPositionCorrection 通知客户端其移动被修正，客户端应以服务器位置为准
+func (s *SimpleServer) sendPositionCorrection(player *Player, roomID string, result moveResult) {
+	if player.Connection == nil {
 		return
 	}
 
-	switch action {
-	case "complete_task":
-		s.handleCompleteTask(player, actionData)
-	case "interact":
-		s.handleInteract(player, actionData)
-	default:
-		log.Printf("Unknown action: %s", action)
-	}
-}
-
-func (s *SimpleServer) handleInteract(player *Player, actionData map[string]interface{}) {
-	// 简化的交互处理
-	log.Printf("Player %s interacted", player.Nickname)
+	writeMessage(player.Connection, Message{
+		Type:     MsgTypePositionCorrection,
+		PlayerID: player.ID,
+		RoomID:   roomID,
+		Data: map[string]interface{}{
+			"position": result.Position,
+			"reason":   result.Reason,
+		},
+		Timestamp: time.Now(),
+	})
 }
 
-func (s *SimpleServer) handleChat(player *Player, msg *Message) {
+func (s *SimpleServer) handlePlayerAction(player *Player, payload *ActionPayload) {
 	if player.Room == nil {
 		return
 	}
 
-	chatData, ok := msg.Data.(map[string]interface{})
-	if !ok {
-		return
-	}
-
-	message, ok := chatData["message"].(string)
-	if !ok {
-		return
+	switch payload.Action {
+	case ActionAcceptTask:
+		s.acceptTask(player, payload.TaskID)
+	case ActionInteract:
+		s.handleInteract(player, payload)
+	case ActionAttack:
+		// 攻击与移动一样在房间的下一个 tick 中结算
+		player.Room.enqueueInput(playerInput{
+			PlayerID:   player.ID,
+			Type:       inputAttack,
+			TargetID:   payload.TargetID,
+			ReceivedAt: time.Now(),
+		})
 	}
-
-	s.broadcastToRoom(player.Room, Message{
-		Type:     MsgTypeChat,
-		PlayerID: player.ID,
-		RoomID:   player.Room.ID,
-		Data: map[string]interface{}{
-			"message":  message,
-			"nickname": player.Nickname,
-		},
-		Timestamp: time.Now(),
-	}, "")
 }
 
 func (s *SimpleServer) handleDisconnect(player *Player) {
+	s.matchmaker.Dequeue(player.ID)
+	s.cancelQueuedJoin(player)
+	s.cancelTrades(player)
 	player.Status = "offline"
 	player.Connection = nil
+	player.LastSeen = time.Now()
+	s.persistPlayer(player)
+	s.setPresence(player)
+	s.antiCheat.forget(player.DID, player.LastSeen)
 
 	if player.Room != nil {
 		s.broadcastToRoom(player.Room, Message{
@@ -668,33 +1385,60 @@ func (s *SimpleServer) handleDisconnect(player *Player) {
 		}, player.ID)
 	}
 
-	log.Printf("Player %s disconnected", player.Nickname)
+	player.log().Info("Player disconnected")
 }
 
+// broadcastToRoom 发送给本实例上的房间玩家，并通过消息总线转发给其他实例
 func (s *SimpleServer) broadcastToRoom(room *GameRoom, msg Message, excludePlayerID string) {
+	s.broadcastToLocalPlayers(room, msg, excludePlayerID)
+	s.publishRoom(room.ID, msg, excludePlayerID)
+}
+
+// broadcastToLocalPlayers 发送给本实例上连接的房间玩家
+func (s *SimpleServer) broadcastToLocalPlayers(room *GameRoom, msg Message, excludePlayerID string) {
+	s.deliverToLocalPlayers(room, msg, excludePlayerID, "")
+}
+
+// deliverToLocalPlayers 发送给本实例上的房间玩家，teamID 不为空时只发给该队伍的玩家
+func (s *SimpleServer) deliverToLocalPlayers(room *GameRoom, msg Message, excludePlayerID, teamID string) {
+	defer broadcastDuration.With(msg.Type).ObserveSince(time.Now())
+
 	room.mutex.RLock()
 	defer room.mutex.RUnlock()
 
 	for playerID, player := range room.Players {
-		if playerID != excludePlayerID && player.Connection != nil {
-			player.Connection.WriteJSON(msg)
+		if playerID == excludePlayerID {
+			continue
+		}
+		if teamID != "" && player.TeamID != teamID {
+			continue
 		}
+		// 聊天消息不推送给屏蔽了发送方的玩家
+		if msg.Type == MsgTypeChat && player.hasMuted(msg.PlayerID) {
+			continue
+		}
+		// 合并窗口内的广播合并发送，断线的玩家缓存消息，恢复会话后补发
+		s.queueBroadcast(player, msg)
 	}
 }
 
-func (s *SimpleServer) sendError(conn *websocket.Conn, message string) {
+func (s *SimpleServer) sendError(conn *websocket.Conn, code ErrorCode, message string) {
+	s.sendProtocolError(conn, &ProtocolError{Code: code, Message: message})
+}
+
+// sendProtocolError 发送结构化错误，data 中包含错误码和字段错误
+func (s *SimpleServer) sendProtocolError(conn *websocket.Conn, protocolErr *ProtocolError) {
 	errorMsg := Message{
-		Type: MsgTypeError,
-		Data: map[string]interface{}{
-			"message": message,
-		},
+		Type:      MsgTypeError,
+		Data:      protocolErr,
 		Timestamp: time.Now(),
 	}
-	conn.WriteJSON(errorMsg)
+	websocketErrorsTotal.With(string(protocolErr.Code)).Inc()
+	writeMessage(conn, errorMsg)
 }
 
-func (s *SimpleServer) sendErrorToPlayer(player *Player, message string) {
+func (s *SimpleServer) sendErrorToPlayer(player *Player, code ErrorCode, message string) {
 	if player.Connection != nil {
-		s.sendError(player.Connection, message)
+		s.sendError(player.Connection, code, message)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,105 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+func newTestServer(t *testing.T) *SimpleServer {
+	t.Helper()
+	didService := did.NewSimpleService()
+	vcService, err := vc.NewSimpleService(didService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewSimpleServer(didService, vcService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// 以 go test -race 运行：玩家并发地加入、在房间之间切换、移动和离开，最后离开的玩家删除房间，
+// 删除与其他玩家的加入交错进行
+func TestConcurrentJoinLeaveMoveAndRoomDeletion(t *testing.T) {
+	server := newTestServer(t)
+	const players = 24
+	const rounds = 40
+	roomIDs := []string{"race-a", "race-b", "race-c"}
+
+	var wg sync.WaitGroup
+	for p := 0; p < players; p++ {
+		playerDID := fmt.Sprintf("did:player:race%08d", p)
+		player := server.getOrCreatePlayer(playerDID, playerDID)
+
+		wg.Add(1)
+		go func(p int, player *Player) {
+			defer wg.Done()
+			for round := 0; round < rounds; round++ {
+				room, err := server.getOrCreateRoom(roomIDs[(p+round)%len(roomIDs)], defaultGameID, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// 房间在获取后可能已被最后一名玩家删除，也可能已满
+				if err := server.joinRoom(player, room); err != nil {
+					if !errors.Is(err, ErrRoomNotFound) && !errors.Is(err, errRoomFull) {
+						t.Error(err)
+						return
+					}
+					continue
+				}
+
+				x, y := float64(20+round), float64(20+p)
+				server.handlePlayerMove(player, &MovePayload{X: &x, Y: &y})
+				if round%3 == 2 {
+					server.leaveRoom(player)
+				}
+			}
+			server.leaveRoom(player)
+		}(p, player)
+	}
+	wg.Wait()
+
+	// 所有玩家离开后房间都被删除
+	server.roomMutex.RLock()
+	remaining := len(server.rooms)
+	server.roomMutex.RUnlock()
+	if remaining != 0 {
+		t.Errorf("%d rooms left after every player left", remaining)
+	}
+	for _, player := range server.players.all() {
+		if player.Room != nil {
+			t.Errorf("player %s still in room %s", player.ID, player.Room.ID)
+		}
+	}
+}
+
+func TestJoinRoomRejectsDeletedRoom(t *testing.T) {
+	server := newTestServer(t)
+	first := server.getOrCreatePlayer("did:player:first0000", "")
+	second := server.getOrCreatePlayer("did:player:second000", "")
+
+	room, err := server.getOrCreateRoom("deleted", defaultGameID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.joinRoom(first, room); err != nil {
+		t.Fatal(err)
+	}
+	server.leaveRoom(first)
+
+	// 持有已删除房间引用的玩家不能加入，否则会留在不再登记的房间中
+	if err := server.joinRoom(second, room); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("joinRoom on a deleted room = %v, want ErrRoomNotFound", err)
+	}
+	if second.Room != nil {
+		t.Error("player joined a deleted room")
+	}
+}
@@ -0,0 +1,299 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// MsgTypeSkills 技能树查询、解锁及技能状态推送
+const MsgTypeSkills = "skills"
+
+// 技能操作类型
+const (
+	SkillsList   = "list"
+	SkillsUnlock = "unlock"
+)
+
+// DefaultSkillPointsPerLevel 每升一级获得的技能点
+const DefaultSkillPointsPerLevel = 1
+
+// 技能错误
+var (
+	ErrNoSkillTree          = errors.New("game has no skill tree")
+	ErrSkillNotFound        = errors.New("skill not found")
+	ErrSkillUnlocked        = errors.New("skill already unlocked")
+	ErrSkillPrerequisites   = errors.New("prerequisite skills not unlocked")
+	ErrNotEnoughSkillPoints = errors.New("not enough skill points")
+)
+
+// Skill 技能树中的一个技能
+type Skill struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	Cost          int      `json:"cost"`
+	Prerequisites []string `json:"prerequisites,omitempty"`
+	// Credential 解锁时是否颁发技能凭证
+	Credential bool `json:"credential,omitempty"`
+}
+
+// SkillTree 游戏的技能树
+type SkillTree struct {
+	GameID string   `json:"gameId"`
+	Skills []*Skill `json:"skills"`
+}
+
+// skill 按 ID 查找技能
+func (t *SkillTree) skill(id string) *Skill {
+	for _, skill := range t.Skills {
+		if skill.ID == id {
+			return skill
+		}
+	}
+	return nil
+}
+
+// validate 检查技能 ID 唯一、消耗不为负、前置技能存在且没有循环依赖
+func (t *SkillTree) validate() error {
+	if t.GameID == "" {
+		return errors.New("skill tree game ID is required")
+	}
+
+	byID := make(map[string]*Skill, len(t.Skills))
+	for _, skill := range t.Skills {
+		if skill.ID == "" {
+			return errors.New("skill ID is required")
+		}
+		if _, exists := byID[skill.ID]; exists {
+			return fmt.Errorf("duplicate skill: %s", skill.ID)
+		}
+		if skill.Cost < 0 {
+			return fmt.Errorf("skill %s has negative cost", skill.ID)
+		}
+		byID[skill.ID] = skill
+	}
+
+	// 深度优先检查循环依赖：1 表示正在访问，2 表示已完成
+	state := make(map[string]int, len(t.Skills))
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case 1:
+			return fmt.Errorf("skill prerequisites form a cycle at %s", id)
+		case 2:
+			return nil
+		}
+		state[id] = 1
+		for _, prerequisite := range byID[id].Prerequisites {
+			if _, exists := byID[prerequisite]; !exists {
+				return fmt.Errorf("skill %s requires unknown skill %s", id, prerequisite)
+			}
+			if err := visit(prerequisite); err != nil {
+				return err
+			}
+		}
+		state[id] = 2
+		return nil
+	}
+	for _, skill := range t.Skills {
+		if err := visit(skill.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultSkillTree 默认游戏的技能树
+func defaultSkillTree() *SkillTree {
+	return &SkillTree{
+		GameID: defaultGameID,
+		Skills: []*Skill{
+			{ID: "sprint", Name: "Sprint", Description: "Move faster across the map", Cost: 1},
+			{ID: "lockpicking", Name: "Lockpicking", Description: "Open simple locks without a key", Cost: 1},
+			{ID: "pathfinder", Name: "Pathfinder", Description: "Master of exploration", Cost: 2, Prerequisites: []string{"sprint"}, Credential: true},
+			{ID: "treasure-hunter", Name: "Treasure Hunter", Description: "Master of finding treasure", Cost: 2, Prerequisites: []string{"lockpicking"}, Credential: true},
+		},
+	}
+}
+
+// RegisterSkillTree 注册或替换游戏的技能树，应在接受连接前调用
+func (s *SimpleServer) RegisterSkillTree(tree *SkillTree) error {
+	if err := tree.validate(); err != nil {
+		return fmt.Errorf("invalid skill tree: %w", err)
+	}
+
+	s.skillTrees[tree.GameID] = tree
+	return nil
+}
+
+// SetSkillPointsPerLevel 设置每升一级获得的技能点，应在接受连接前调用
+func (s *SimpleServer) SetSkillPointsPerLevel(points int) {
+	s.skillPointsPerLevel = points
+}
+
+// skillPoints 返回玩家尚未使用的技能点，技能点由等级决定，所有游戏共用
+func (s *SimpleServer) skillPoints(player *Player) int {
+	player.skillMutex.Lock()
+	defer player.skillMutex.Unlock()
+	return s.availableSkillPoints(player)
+}
+
+// availableSkillPoints 调用方需持有 player.skillMutex
+func (s *SimpleServer) availableSkillPoints(player *Player) int {
+	return (player.Level-1)*s.skillPointsPerLevel - player.skillPointsSpent
+}
+
+// UnlockSkill 校验前置技能和技能点后为玩家解锁技能，需要时颁发 SkillCredential
+func (s *SimpleServer) UnlockSkill(player *Player, gameID, skillID string) (*Skill, error) {
+	tree, ok := s.skillTrees[gameID]
+	if !ok {
+		return nil, ErrNoSkillTree
+	}
+	skill := tree.skill(skillID)
+	if skill == nil {
+		return nil, ErrSkillNotFound
+	}
+
+	player.skillMutex.Lock()
+	unlocked := player.skills[gameID]
+	if containsString(unlocked, skillID) {
+		player.skillMutex.Unlock()
+		return nil, ErrSkillUnlocked
+	}
+	for _, prerequisite := range skill.Prerequisites {
+		if !containsString(unlocked, prerequisite) {
+			player.skillMutex.Unlock()
+			return nil, ErrSkillPrerequisites
+		}
+	}
+	if s.availableSkillPoints(player) < skill.Cost {
+		player.skillMutex.Unlock()
+		return nil, ErrNotEnoughSkillPoints
+	}
+	if player.skills == nil {
+		player.skills = make(map[string][]string)
+	}
+	player.skills[gameID] = append(unlocked, skillID)
+	player.skillPointsSpent += skill.Cost
+	player.skillMutex.Unlock()
+
+	s.persistPlayer(player)
+	player.log().Info("Player unlocked skill", "skill_id", skillID, "game_id", gameID)
+
+	if skill.Credential {
+		s.issueSkillCredential(player, gameID, skill)
+	}
+	return skill, nil
+}
+
+// issueSkillCredential 为解锁技能的玩家颁发技能凭证
+func (s *SimpleServer) issueSkillCredential(player *Player, gameID string, skill *Skill) {
+	if player.isGuest() {
+		return
+	}
+	credential, err := s.vcService.IssueSkillCredential(player.traceContext(), player.DID, gameID, player.ID, skill.ID, skill.Name)
+	if err != nil {
+		player.log().Error("Failed to issue skill credential", logging.Err(err))
+		return
+	}
+
+	if player.Connection != nil {
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeCredential,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"credential": credential,
+				"message":    s.localize(player, "credential.skill", i18n.Params{"name": skill.Name}),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// handleSkills 处理 skills 消息，未指定游戏时使用所在房间的游戏
+func (s *SimpleServer) handleSkills(player *Player, payload *SkillsPayload) {
+	gameID := payload.GameID
+	if gameID == "" {
+		gameID = defaultGameID
+		if room := player.Room; room != nil {
+			gameID = room.GameID
+		}
+	}
+
+	var skill *Skill
+	switch payload.Action {
+	case SkillsList:
+		if _, ok := s.skillTrees[gameID]; !ok {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeSkillFailed, "error.skills_failed", i18n.Params{"action": payload.Action, "error": ErrNoSkillTree})
+			return
+		}
+	case SkillsUnlock:
+		var err error
+		if skill, err = s.UnlockSkill(player, gameID, payload.SkillID); err != nil {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeSkillFailed, "error.skills_failed", i18n.Params{"action": payload.Action, "error": err})
+			return
+		}
+	}
+
+	s.sendSkills(player, payload.Action, gameID, skill)
+}
+
+// sendSkills 向玩家推送技能树、已解锁技能和剩余技能点
+func (s *SimpleServer) sendSkills(player *Player, action, gameID string, skill *Skill) {
+	if player.Connection == nil {
+		return
+	}
+
+	player.skillMutex.Lock()
+	unlocked := append([]string{}, player.skills[gameID]...)
+	points := s.availableSkillPoints(player)
+	player.skillMutex.Unlock()
+	sort.Strings(unlocked)
+
+	data := map[string]interface{}{
+		"action":      action,
+		"gameId":      gameID,
+		"tree":        s.skillTrees[gameID],
+		"unlocked":    unlocked,
+		"skillPoints": points,
+	}
+	if skill != nil {
+		data["skill"] = skill
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:      MsgTypeSkills,
+		PlayerID:  player.ID,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// unlockedSkills 复制玩家已解锁的技能，用于持久化
+func (p *Player) unlockedSkills() map[string][]string {
+	p.skillMutex.Lock()
+	defer p.skillMutex.Unlock()
+
+	if len(p.skills) == 0 {
+		return nil
+	}
+	skills := make(map[string][]string, len(p.skills))
+	for gameID, ids := range p.skills {
+		skills[gameID] = append([]string(nil), ids...)
+	}
+	return skills
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package game
+
+import (
+	"math"
+	"sort"
+)
+
+// spatialCellSize 地图物体网格索引的单元边长（像素）
+const spatialCellSize = 64
+
+// cellKey 网格单元坐标
+type cellKey struct {
+	X, Y int
+}
+
+// objectIndex 地图物体的均匀网格索引，物体登记在其包围盒覆盖的所有单元中
+type objectIndex struct {
+	cells   map[cellKey][]*MapObject
+	objects int
+}
+
+// newObjectIndex 为物体建立网格索引
+func newObjectIndex(objects []*MapObject) *objectIndex {
+	index := &objectIndex{
+		cells:   make(map[cellKey][]*MapObject),
+		objects: len(objects),
+	}
+	for _, object := range objects {
+		minX, minY, maxX, maxY := object.bounds()
+		for _, key := range cellsCovering(minX, minY, maxX, maxY) {
+			index.cells[key] = append(index.cells[key], object)
+		}
+	}
+	return index
+}
+
+// query 返回包围盒与矩形区域所在单元相交的物体，可能包含区域外的物体
+func (i *objectIndex) query(minX, minY, maxX, maxY float64) []*MapObject {
+	seen := make(map[*MapObject]bool)
+	var candidates []*MapObject
+	for _, key := range cellsCovering(minX, minY, maxX, maxY) {
+		for _, object := range i.cells[key] {
+			if !seen[object] {
+				seen[object] = true
+				candidates = append(candidates, object)
+			}
+		}
+	}
+	return candidates
+}
+
+// cellsCovering 返回覆盖矩形区域的网格单元
+func cellsCovering(minX, minY, maxX, maxY float64) []cellKey {
+	x0, y0 := cellCoord(minX), cellCoord(minY)
+	x1, y1 := cellCoord(maxX), cellCoord(maxY)
+
+	keys := make([]cellKey, 0, (x1-x0+1)*(y1-y0+1))
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			keys = append(keys, cellKey{X: x, Y: y})
+		}
+	}
+	return keys
+}
+
+func cellCoord(v float64) int {
+	return int(math.Floor(v / spatialCellSize))
+}
+
+// bounds 返回物体的包围盒，宽高为 0 的物体视为一个点
+func (o *MapObject) bounds() (minX, minY, maxX, maxY float64) {
+	return o.Position.X, o.Position.Y, o.Position.X + float64(o.Width), o.Position.Y + float64(o.Height)
+}
+
+// distanceTo 返回位置到物体包围盒的距离，位置在包围盒内时为 0
+func (o *MapObject) distanceTo(pos Position) float64 {
+	minX, minY, maxX, maxY := o.bounds()
+	dx := math.Max(math.Max(minX-pos.X, 0), pos.X-maxX)
+	dy := math.Max(math.Max(minY-pos.Y, 0), pos.Y-maxY)
+	return math.Hypot(dx, dy)
+}
+
+// objects 返回物体索引，物体数量变化后重建，调用方需持有房间锁
+func (m *GameMap) objects() *objectIndex {
+	m.indexMutex.Lock()
+	defer m.indexMutex.Unlock()
+
+	if m.index == nil || m.index.objects != len(m.Objects) {
+		m.index = newObjectIndex(m.Objects)
+	}
+	return m.index
+}
+
+// ReindexObjects 在修改物体位置或尺寸后重建索引
+func (m *GameMap) ReindexObjects() {
+	m.indexMutex.Lock()
+	m.index = nil
+	m.indexMutex.Unlock()
+}
+
+// FindObjectsNear 返回包围盒与 position 距离不超过 radius 的物体，按距离从近到远排序
+func (m *GameMap) FindObjectsNear(position Position, radius float64) []*MapObject {
+	candidates := m.objects().query(position.X-radius, position.Y-radius, position.X+radius, position.Y+radius)
+
+	type nearObject struct {
+		object   *MapObject
+		distance float64
+	}
+	near := make([]nearObject, 0, len(candidates))
+	for _, object := range candidates {
+		if distance := object.distanceTo(position); distance <= radius {
+			near = append(near, nearObject{object: object, distance: distance})
+		}
+	}
+	sort.SliceStable(near, func(i, j int) bool {
+		if near[i].distance != near[j].distance {
+			return near[i].distance < near[j].distance
+		}
+		return near[i].object.ID < near[j].object.ID
+	})
+
+	objects := make([]*MapObject, len(near))
+	for i, n := range near {
+		objects[i] = n.object
+	}
+	return objects
+}
@@ -0,0 +1,330 @@
+package game
+
+import (
+	"time"
+)
+
+// 状态同步消息类型
+const (
+	MsgTypeStateSync = "state_sync" // 服务器按版本发送的房间状态增量或完整快照
+	MsgTypeStateAck  = "state_ack"  // 客户端确认已应用的状态版本，或请求完整快照
+)
+
+// 发送完整快照的原因，用于指标
+const (
+	resyncJoin      = "join"
+	resyncLagging   = "lagging"
+	resyncRequested = "requested"
+)
+
+// StateSyncConfig 状态同步配置
+type StateSyncConfig struct {
+	// MaxUnacked 已发送未确认的消息数达到该值时暂停发送增量，客户端确认后改发完整快照
+	MaxUnacked int
+}
+
+// DefaultStateSyncConfig 返回默认状态同步配置，默认 tick 频率下约 3 秒未确认即暂停
+func DefaultStateSyncConfig() StateSyncConfig {
+	return StateSyncConfig{
+		MaxUnacked: 60,
+	}
+}
+
+// SetStateSyncConfig 替换状态同步配置，应在接受连接前调用
+func (s *SimpleServer) SetStateSyncConfig(config StateSyncConfig) {
+	s.stateSync = config
+}
+
+// PlayerState 同步给客户端的玩家状态
+type PlayerState struct {
+	Nickname  string
+	Position  Position
+	Level     int
+	Health    int
+	MaxHealth int
+	Status    string
+	TeamID    string
+}
+
+// PlayerStateDelta 玩家状态的字段级增量，只包含变化的字段；新加入的玩家包含全部字段
+type PlayerStateDelta struct {
+	Nickname  *string   `json:"nickname,omitempty"`
+	Position  *Position `json:"position,omitempty"`
+	Level     *int      `json:"level,omitempty"`
+	Health    *int      `json:"health,omitempty"`
+	MaxHealth *int      `json:"maxHealth,omitempty"`
+	Status    *string   `json:"status,omitempty"`
+	TeamID    *string   `json:"teamId,omitempty"`
+}
+
+// StateSync state_sync 消息数据。Full 为 false 时是基于 Base 版本的增量，
+// 客户端当前版本不等于 Base 时应丢弃增量并以 state_ack 请求完整快照。
+// 启用兴趣管理时只包含可见范围内的玩家，Entered 和 Exited 列出进入和离开视野的玩家
+type StateSync struct {
+	Version uint64                       `json:"version"`
+	Base    uint64                       `json:"base"`
+	Full    bool                         `json:"full,omitempty"`
+	Tick    uint64                       `json:"tick"`
+	Status  string                       `json:"status,omitempty"`
+	Players map[string]*PlayerStateDelta `json:"players,omitempty"`
+	Removed []string                     `json:"removed,omitempty"`
+	Entered []string                     `json:"entered,omitempty"`
+	Exited  []string                     `json:"exited,omitempty"`
+}
+
+// empty 判断增量是否没有任何变化
+func (d *StateSync) empty() bool {
+	return d.Status == "" && len(d.Players) == 0 && len(d.Removed) == 0 && len(d.Entered) == 0 && len(d.Exited) == 0
+}
+
+// positions 提取位置变化，通过消息总线以 state_delta 发给其他实例上的玩家
+func (d *StateSync) positions() *StateDelta {
+	delta := &StateDelta{Tick: d.Tick, Players: make(map[string]Position), Removed: d.Removed}
+	for playerID, player := range d.Players {
+		if player.Position != nil {
+			delta.Players[playerID] = *player.Position
+		}
+	}
+	return delta
+}
+
+// roomSync 房间最近一次同步的状态，由 room.mutex 保护
+type roomSync struct {
+	version uint64
+	status  string
+	players map[string]PlayerState
+}
+
+// playerSync 玩家连接的同步进度，由 Player.syncMutex 保护
+type playerSync struct {
+	sent     uint64          // 最近发送的版本
+	synced   uint64          // 客户端视图对应的房间版本，可见范围内没有变化而跳过的增量也计入
+	inFlight []uint64        // 已发送未确认的版本，按发送顺序
+	resync   bool            // 下次发送完整快照
+	visible  map[string]bool // 客户端可见的玩家，nil 表示整个房间可见
+}
+
+func playerStateOf(player *Player) PlayerState {
+	return PlayerState{
+		Nickname:  player.Nickname,
+		Position:  player.Position,
+		Level:     player.Level,
+		Health:    player.Health,
+		MaxHealth: player.MaxHealth,
+		Status:    player.Status,
+		TeamID:    player.TeamID,
+	}
+}
+
+// diffPlayerState 比较玩家状态，old 为 nil 时返回全部字段，没有变化时返回 nil
+func diffPlayerState(old *PlayerState, state PlayerState) *PlayerStateDelta {
+	delta := &PlayerStateDelta{}
+	changed := false
+	if old == nil || old.Nickname != state.Nickname {
+		delta.Nickname, changed = &state.Nickname, true
+	}
+	if old == nil || old.Position != state.Position {
+		delta.Position, changed = &state.Position, true
+	}
+	if old == nil || old.Level != state.Level {
+		delta.Level, changed = &state.Level, true
+	}
+	if old == nil || old.Health != state.Health {
+		delta.Health, changed = &state.Health, true
+	}
+	if old == nil || old.MaxHealth != state.MaxHealth {
+		delta.MaxHealth, changed = &state.MaxHealth, true
+	}
+	if old == nil || old.Status != state.Status {
+		delta.Status, changed = &state.Status, true
+	}
+	if old == nil || old.TeamID != state.TeamID {
+		delta.TeamID, changed = &state.TeamID, true
+	}
+	if !changed {
+		return nil
+	}
+	return delta
+}
+
+// advanceSync 对比上次同步的状态生成增量，有变化时版本号加一，没有变化时返回 nil。调用方需持有 room.mutex
+func (r *GameRoom) advanceSync(tick uint64) *StateSync {
+	delta := &StateSync{Base: r.sync.version, Tick: tick, Players: make(map[string]*PlayerStateDelta)}
+
+	if r.GameState.Status != r.sync.status {
+		delta.Status = r.GameState.Status
+		r.sync.status = r.GameState.Status
+	}
+
+	for playerID, player := range r.Players {
+		state := playerStateOf(player)
+		var changes *PlayerStateDelta
+		if last, exists := r.sync.players[playerID]; exists {
+			changes = diffPlayerState(&last, state)
+		} else {
+			changes = diffPlayerState(nil, state)
+		}
+		if changes != nil {
+			delta.Players[playerID] = changes
+			r.sync.players[playerID] = state
+		}
+	}
+
+	for playerID := range r.sync.players {
+		if _, exists := r.Players[playerID]; !exists {
+			delta.Removed = append(delta.Removed, playerID)
+			delete(r.sync.players, playerID)
+		}
+	}
+
+	if delta.empty() {
+		return nil
+	}
+	r.sync.version++
+	delta.Version = r.sync.version
+	return delta
+}
+
+// snapshotSync 返回最近一次同步版本的完整快照
+func (r *GameRoom) snapshotSync() *StateSync {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	snapshot := &StateSync{
+		Version: r.sync.version,
+		Full:    true,
+		Tick:    r.GameState.Tick,
+		Status:  r.sync.status,
+		Players: make(map[string]*PlayerStateDelta, len(r.sync.players)),
+	}
+	for playerID, state := range r.sync.players {
+		state := state
+		snapshot.Players[playerID] = diffPlayerState(nil, state)
+	}
+	return snapshot
+}
+
+// syncLocalPlayers 向本实例上的房间玩家发送状态增量
+func (s *SimpleServer) syncLocalPlayers(room *GameRoom, delta *StateSync) {
+	room.mutex.RLock()
+	players := make([]*Player, 0, len(room.Players))
+	for _, player := range room.Players {
+		players = append(players, player)
+	}
+	var view *interestView
+	if s.interest.enabled() {
+		view = newInterestView(room.sync.players, s.interest)
+	}
+	room.mutex.RUnlock()
+
+	for _, player := range players {
+		s.sendStateSync(room, player, delta, view)
+	}
+}
+
+// sendStateSync 按客户端的确认进度发送增量：客户端未确认的消息过多时暂停发送，
+// 客户端的版本与增量的基础版本不一致、需要重新同步或不支持增量时改发完整快照。view 不为 nil 时按可见范围过滤
+func (s *SimpleServer) sendStateSync(room *GameRoom, player *Player, delta *StateSync, view *interestView) {
+	player.syncMutex.Lock()
+	defer player.syncMutex.Unlock()
+
+	if player.Connection == nil || delta.Version <= player.sync.synced {
+		return
+	}
+	if len(player.sync.inFlight) >= s.stateSync.MaxUnacked {
+		if !player.sync.resync {
+			player.sync.resync = true
+			stateResyncsTotal.With(resyncLagging).Inc()
+			player.log().Debug("Client fell behind state sync", "sent", player.sync.sent, "unacked", len(player.sync.inFlight))
+		}
+		return
+	}
+
+	if player.sync.resync || player.sync.synced != delta.Base || !player.supports(CapabilityDeltaSync) {
+		s.writeStateSync(room, player, s.snapshotFor(room, player))
+		return
+	}
+	if view != nil {
+		filtered := view.filter(player, delta)
+		if filtered == nil {
+			player.sync.synced = delta.Version
+			return
+		}
+		delta = filtered
+	}
+	s.writeStateSync(room, player, delta)
+}
+
+// snapshotFor 返回发给玩家的完整快照，启用兴趣管理时只包含可见范围内的玩家。调用方需持有 player.syncMutex
+func (s *SimpleServer) snapshotFor(room *GameRoom, player *Player) *StateSync {
+	snapshot := room.snapshotSync()
+	if !s.interest.enabled() {
+		player.sync.visible = nil
+		return snapshot
+	}
+	return s.interest.filterSnapshot(player, snapshot)
+}
+
+// resetStateSync 玩家加入房间或恢复会话后从完整快照开始同步
+func (s *SimpleServer) resetStateSync(room *GameRoom, player *Player) {
+	player.syncMutex.Lock()
+	defer player.syncMutex.Unlock()
+
+	player.sync = playerSync{}
+	if player.Connection == nil {
+		player.sync.resync = true
+		return
+	}
+	stateResyncsTotal.With(resyncJoin).Inc()
+	s.writeStateSync(room, player, s.snapshotFor(room, player))
+}
+
+// writeStateSync 发送同步消息并记录发送进度，调用方需持有 player.syncMutex。
+// 不支持增量的客户端不发送确认，不记录待确认的版本
+func (s *SimpleServer) writeStateSync(room *GameRoom, player *Player, sync *StateSync) {
+	player.sync.sent = sync.Version
+	player.sync.synced = sync.Version
+	if player.supports(CapabilityDeltaSync) {
+		player.sync.inFlight = append(player.sync.inFlight, sync.Version)
+	}
+	if sync.Full {
+		player.sync.resync = false
+	}
+
+	writeMessage(player.Connection, Message{
+		Type:      MsgTypeStateSync,
+		RoomID:    room.ID,
+		Data:      sync,
+		Timestamp: time.Now(),
+	})
+}
+
+// handleStateAck 记录客户端确认的版本。暂停发送的客户端追上后、或客户端请求重新同步时立即发送完整快照
+func (s *SimpleServer) handleStateAck(player *Player, payload *StateAckPayload) {
+	room := player.Room
+	if room == nil {
+		return
+	}
+
+	player.syncMutex.Lock()
+	defer player.syncMutex.Unlock()
+
+	// 确认尚未发送的版本说明客户端与服务器不一致，按重新同步处理
+	if payload.Version > player.sync.sent {
+		payload.Resync = true
+	}
+
+	acked := 0
+	for acked < len(player.sync.inFlight) && player.sync.inFlight[acked] <= payload.Version {
+		acked++
+	}
+	player.sync.inFlight = player.sync.inFlight[acked:]
+
+	if payload.Resync && !player.sync.resync {
+		player.sync.resync = true
+		stateResyncsTotal.With(resyncRequested).Inc()
+	}
+	if player.sync.resync && player.Connection != nil && len(player.sync.inFlight) < s.stateSync.MaxUnacked {
+		s.writeStateSync(room, player, s.snapshotFor(room, player))
+	}
+}
@@ -0,0 +1,292 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoTaskDirectory 服务器启动时没有指定任务定义目录，无法重新加载
+var ErrNoTaskDirectory = errors.New("no task definition directory configured")
+
+// TaskDefinition 任务定义，房间创建时按游戏模式实例化为房间任务
+type TaskDefinition struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Modes       []string               `json:"modes,omitempty"` // 适用的游戏模式，为空时适用于所有模式
+	Objectives  []ObjectiveDefinition  `json:"objectives"`
+	Rewards     []Reward               `json:"rewards,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+// ObjectiveDefinition 任务目标定义，Type 为游戏事件类型，Target 为 "any" 时匹配任意目标
+type ObjectiveDefinition struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	Target      string `json:"target"`
+	Required    int    `json:"required"`
+}
+
+// objectiveEventTypes 可作为任务目标的游戏事件类型
+var objectiveEventTypes = map[string]bool{
+	EventMovement:      true,
+	EventInteraction:   true,
+	EventChat:          true,
+	EventKill:          true,
+	EventDeath:         true,
+	EventTaskCompleted: true,
+}
+
+// validate 校验任务定义的字段，不检查游戏模式是否存在
+func (d *TaskDefinition) validate() error {
+	if d.ID == "" || d.Name == "" {
+		return errors.New("task id and name are required")
+	}
+	if len(d.Objectives) == 0 {
+		return fmt.Errorf("task %s: at least one objective is required", d.ID)
+	}
+
+	objectiveIDs := make(map[string]bool, len(d.Objectives))
+	for _, objective := range d.Objectives {
+		if objective.ID == "" {
+			return fmt.Errorf("task %s: objective id is required", d.ID)
+		}
+		if objectiveIDs[objective.ID] {
+			return fmt.Errorf("task %s: duplicate objective %s", d.ID, objective.ID)
+		}
+		objectiveIDs[objective.ID] = true
+		if !objectiveEventTypes[objective.Type] {
+			return fmt.Errorf("task %s: objective %s has unknown event type %q", d.ID, objective.ID, objective.Type)
+		}
+		if objective.Target == "" {
+			return fmt.Errorf("task %s: objective %s target is required", d.ID, objective.ID)
+		}
+		if objective.Required < 1 {
+			return fmt.Errorf("task %s: objective %s must require at least 1 event", d.ID, objective.ID)
+		}
+	}
+
+	for _, reward := range d.Rewards {
+		switch reward.Type {
+		case RewardCredential:
+		case RewardItem:
+			if name, _ := reward.Value.(string); name == "" {
+				return fmt.Errorf("task %s: item reward value must be the item name", d.ID)
+			}
+		case RewardExperience:
+			if experienceFromReward(&reward) <= 0 {
+				return fmt.Errorf("task %s: experience reward value must be a positive number", d.ID)
+			}
+		default:
+			return fmt.Errorf("task %s: unknown reward type %q", d.ID, reward.Type)
+		}
+	}
+	return nil
+}
+
+// appliesTo 任务是否适用于游戏模式
+func (d *TaskDefinition) appliesTo(mode string) bool {
+	if len(d.Modes) == 0 {
+		return true
+	}
+	for _, m := range d.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// instantiate 生成房间任务，每个房间的任务和目标进度相互独立
+func (d *TaskDefinition) instantiate(status string) *Task {
+	task := &Task{
+		ID:          d.ID,
+		Name:        d.Name,
+		Description: d.Description,
+		Type:        d.Type,
+		Status:      status,
+		Objectives:  make([]*Objective, 0, len(d.Objectives)),
+		Rewards:     make([]*Reward, 0, len(d.Rewards)),
+		Properties:  make(map[string]interface{}, len(d.Properties)),
+	}
+	for _, objective := range d.Objectives {
+		task.Objectives = append(task.Objectives, &Objective{
+			ID:          objective.ID,
+			Description: objective.Description,
+			Type:        objective.Type,
+			Target:      objective.Target,
+			Required:    objective.Required,
+		})
+	}
+	for _, reward := range d.Rewards {
+		reward := reward
+		task.Rewards = append(task.Rewards, &reward)
+	}
+	for key, value := range d.Properties {
+		task.Properties[key] = value
+	}
+	return task
+}
+
+// LoadTaskDefinitions 读取目录中每个游戏的任务定义文件 {游戏 ID}.json，文件内容为任务定义数组。
+// 任何一个文件无法解析或包含无效定义时返回错误
+func LoadTaskDefinitions(dir string) (map[string][]*TaskDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read task directory: %w", err)
+	}
+
+	definitions := make(map[string][]*TaskDefinition)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read task file %s: %w", entry.Name(), err)
+		}
+		var tasks []*TaskDefinition
+		if err := json.Unmarshal(data, &tasks); err != nil {
+			return nil, fmt.Errorf("decode task file %s: %w", entry.Name(), err)
+		}
+		gameID := strings.TrimSuffix(entry.Name(), ".json")
+		if err := validateTaskDefinitions(tasks); err != nil {
+			return nil, fmt.Errorf("task file %s: %w", entry.Name(), err)
+		}
+		definitions[gameID] = tasks
+	}
+	return definitions, nil
+}
+
+// validateTaskDefinitions 校验一个游戏的任务定义，任务 ID 在游戏内不能重复
+func validateTaskDefinitions(tasks []*TaskDefinition) error {
+	ids := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		if task == nil {
+			return errors.New("task definition must be an object")
+		}
+		if err := task.validate(); err != nil {
+			return err
+		}
+		if ids[task.ID] {
+			return fmt.Errorf("duplicate task %s", task.ID)
+		}
+		ids[task.ID] = true
+	}
+	return nil
+}
+
+// SetTaskDefinitions 替换游戏的任务定义，tasks 为空时恢复游戏模式自带的任务。
+// 只影响之后创建的房间，已有房间保留原来的任务和进度
+func (s *SimpleServer) SetTaskDefinitions(gameID string, tasks []*TaskDefinition) error {
+	if err := validateTaskDefinitions(tasks); err != nil {
+		return err
+	}
+	if err := s.checkTaskModes(tasks); err != nil {
+		return err
+	}
+
+	s.taskMutex.Lock()
+	defer s.taskMutex.Unlock()
+
+	if len(tasks) == 0 {
+		delete(s.taskDefinitions, gameID)
+	} else {
+		s.taskDefinitions[gameID] = tasks
+	}
+	return nil
+}
+
+// LoadTasks 从目录加载所有游戏的任务定义并记住目录供 ReloadTasks 使用。
+// 所有文件都有效时才替换现有定义，返回每个游戏加载的任务数
+func (s *SimpleServer) LoadTasks(dir string) (map[string]int, error) {
+	definitions, err := LoadTaskDefinitions(dir)
+	if err != nil {
+		return nil, err
+	}
+	for gameID, tasks := range definitions {
+		if err := s.checkTaskModes(tasks); err != nil {
+			return nil, fmt.Errorf("game %s: %w", gameID, err)
+		}
+	}
+
+	s.taskMutex.Lock()
+	s.taskDir = dir
+	s.taskDefinitions = definitions
+	s.taskMutex.Unlock()
+
+	counts := make(map[string]int, len(definitions))
+	for gameID, tasks := range definitions {
+		counts[gameID] = len(tasks)
+	}
+	return counts, nil
+}
+
+// ReloadTasks 重新加载启动时指定的任务定义目录，新定义只影响之后创建的房间
+func (s *SimpleServer) ReloadTasks() (map[string]int, error) {
+	s.taskMutex.RLock()
+	dir := s.taskDir
+	s.taskMutex.RUnlock()
+
+	if dir == "" {
+		return nil, ErrNoTaskDirectory
+	}
+	return s.LoadTasks(dir)
+}
+
+// TaskDefinitions 返回各游戏当前的任务定义，调用方不应修改返回的定义
+func (s *SimpleServer) TaskDefinitions() map[string][]*TaskDefinition {
+	s.taskMutex.RLock()
+	defer s.taskMutex.RUnlock()
+
+	definitions := make(map[string][]*TaskDefinition, len(s.taskDefinitions))
+	for gameID, tasks := range s.taskDefinitions {
+		definitions[gameID] = tasks
+	}
+	return definitions
+}
+
+// checkTaskModes 检查任务定义引用的游戏模式都已注册
+func (s *SimpleServer) checkTaskModes(tasks []*TaskDefinition) error {
+	for _, task := range tasks {
+		for _, mode := range task.Modes {
+			if _, found := s.gameMode(mode); !found {
+				return fmt.Errorf("task %s: unknown game mode: %s", task.ID, mode)
+			}
+		}
+	}
+	return nil
+}
+
+// assignTasks 用游戏的任务定义中适用于房间模式的任务替换模式自带的任务，
+// 游戏没有定义或没有适用的任务时保留模式自带的任务。模式自带的任务初始为未开放时，
+// 定义的任务同样初始为未开放，由模式在开始对局时开放
+func (s *SimpleServer) assignTasks(room *GameRoom) {
+	s.taskMutex.RLock()
+	definitions := s.taskDefinitions[room.GameID]
+	s.taskMutex.RUnlock()
+
+	status := TaskAvailable
+	for _, task := range room.GameState.Tasks {
+		if task.Status == TaskLocked {
+			status = TaskLocked
+			break
+		}
+	}
+
+	var tasks []*Task
+	for _, definition := range definitions {
+		if definition.appliesTo(room.Mode) {
+			tasks = append(tasks, definition.instantiate(status))
+		}
+	}
+	if len(tasks) > 0 {
+		room.GameState.Tasks = tasks
+	}
+}
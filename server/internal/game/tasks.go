@@ -0,0 +1,278 @@
+package game
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// 游戏事件类型，与 Objective.Type 对应
+const (
+	EventMovement    = "movement"
+	EventInteraction = "interaction"
+	EventChat        = "chat"
+	// EventKill 玩家击杀其他玩家，target 为被击杀的玩家 ID
+	EventKill = "kill"
+	// EventDeath 玩家死亡，target 为击杀者（玩家或 NPC）ID，其他原因死亡时为空
+	EventDeath = "death"
+	// EventTaskCompleted 玩家完成任务，target 为任务 ID
+	EventTaskCompleted = "task_completed"
+)
+
+// 任务状态
+const (
+	TaskAvailable = "available"
+	TaskActive    = "active"
+	TaskCompleted = "completed"
+	TaskFailed    = "failed"
+	// TaskLocked 尚未开放的任务，由游戏模式在合适的时机开放
+	TaskLocked = "locked"
+)
+
+// ObjectiveTargetAny 匹配任意目标的 Objective.Target
+const ObjectiveTargetAny = "any"
+
+// taskProgress 一次事件处理后发生变化的任务
+type taskProgress struct {
+	Task      *Task
+	Completed bool
+}
+
+// emitEvent 将事件写入事件日志和房间最近事件窗口，并推进相关任务目标
+func (s *SimpleServer) emitEvent(room *GameRoom, player *Player, eventType, target string) {
+	s.antiCheat.observeEvent(player, room.ID, eventType, target)
+
+	event := &GameEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		PlayerID:  player.ID,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"target": target,
+		},
+	}
+
+	room.mutex.Lock()
+	// 在房间锁内分配序号，使窗口中的事件与日志顺序一致
+	if err := s.eventLog.Append(room.ID, event); err != nil {
+		room.log().Error("Failed to append event to log", logging.Err(err))
+	}
+	room.GameState.Events = append(room.GameState.Events, event)
+	if window := s.eventLog.config.Window; len(room.GameState.Events) > window {
+		room.GameState.Events = room.GameState.Events[len(room.GameState.Events)-window:]
+	}
+	progress := advanceTasks(room.GameState.Tasks, eventType, target)
+	if room.mode != nil {
+		room.mode.OnEvent(room, event)
+	}
+	result := room.checkResult(event.Timestamp)
+	room.mutex.Unlock()
+
+	if result != nil {
+		s.announceResult(room, result)
+	}
+	s.GrantExperience(room, player, s.experience.EventXP[eventType], eventType)
+	s.recordEventStats(room, player, eventType, target)
+	if len(progress) == 0 {
+		return
+	}
+
+	s.persistRoom(room)
+
+	for _, p := range progress {
+		action := "progress"
+		if p.Completed {
+			action = "completed"
+			s.rewardTask(room, player, p.Task)
+		}
+		s.broadcastTaskUpdate(room, player, p.Task, action)
+		if p.Completed {
+			s.emitEvent(room, player, EventTaskCompleted, p.Task.ID)
+			s.publishTaskCompleted(room, player, p.Task)
+			s.checkTaskCompletion(room, player, p.Task)
+		}
+	}
+}
+
+// advanceTasks 根据事件推进任务目标，调用方需持有 room.mutex
+func advanceTasks(tasks []*Task, eventType, target string) []taskProgress {
+	var progress []taskProgress
+
+	for _, task := range tasks {
+		if task.Status != TaskAvailable && task.Status != TaskActive {
+			continue
+		}
+
+		changed := false
+		for _, objective := range task.Objectives {
+			if objective.Completed || objective.Type != eventType {
+				continue
+			}
+			if objective.Target != ObjectiveTargetAny && objective.Target != target {
+				continue
+			}
+
+			objective.Current++
+			if objective.Current >= objective.Required {
+				objective.Current = objective.Required
+				objective.Completed = true
+			}
+			changed = true
+		}
+
+		if !changed {
+			continue
+		}
+
+		// 任务一旦有进展即视为已接受
+		task.Status = TaskActive
+		if taskObjectivesCompleted(task) {
+			task.Status = TaskCompleted
+		}
+		progress = append(progress, taskProgress{Task: task, Completed: task.Status == TaskCompleted})
+	}
+
+	return progress
+}
+
+// taskObjectivesCompleted 判断任务的所有目标是否都已完成
+func taskObjectivesCompleted(task *Task) bool {
+	for _, objective := range task.Objectives {
+		if !objective.Completed {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptTask 玩家主动接受任务
+func (s *SimpleServer) acceptTask(player *Player, taskID string) {
+	room := player.Room
+
+	room.mutex.Lock()
+	var accepted *Task
+	for _, task := range room.GameState.Tasks {
+		if task.ID == taskID && task.Status == TaskAvailable {
+			task.Status = TaskActive
+			accepted = task
+			break
+		}
+	}
+	room.mutex.Unlock()
+
+	if accepted == nil {
+		return
+	}
+
+	s.persistRoom(room)
+	s.broadcastTaskUpdate(room, player, accepted, "accepted")
+}
+
+// 任务奖励类型
+const (
+	RewardCredential = "credential"
+	RewardItem       = "item"
+	RewardExperience = "experience"
+)
+
+// rewardTask 发放任务奖励：凭证奖励颁发成就凭证，道具奖励放入玩家背包，经验奖励增加玩家经验
+func (s *SimpleServer) rewardTask(room *GameRoom, player *Player, task *Task) {
+	for _, reward := range task.Rewards {
+		switch reward.Type {
+		case RewardCredential:
+			s.issueAchievement(room, player, task.Name)
+		case RewardItem:
+			if _, err := s.GrantItem(player, itemFromReward(reward)); err != nil {
+				player.log().Error("Failed to grant item", logging.Err(err))
+			}
+		case RewardExperience:
+			s.GrantExperience(room, player, experienceFromReward(reward), "task:"+task.ID)
+		}
+	}
+
+	player.log().Info("Player completed task", "task", task.Name)
+}
+
+// itemFromReward 根据道具奖励生成道具，Value 为道具名称，Properties 描述类型、稀有度和槽位
+func itemFromReward(reward *Reward) *Item {
+	item := &Item{Properties: make(map[string]interface{})}
+	if name, ok := reward.Value.(string); ok {
+		item.Name = name
+		item.Type = name
+	}
+	for key, value := range reward.Properties {
+		switch key {
+		case "type":
+			item.Type, _ = value.(string)
+		case "rarity":
+			item.Rarity, _ = value.(string)
+		case "slot":
+			item.Slot, _ = value.(string)
+		case "stackable":
+			item.Stackable, _ = value.(bool)
+		case "quantity":
+			if quantity, ok := value.(float64); ok {
+				item.Quantity = int(quantity)
+			} else if quantity, ok := value.(int); ok {
+				item.Quantity = quantity
+			}
+		default:
+			item.Properties[key] = value
+		}
+	}
+	return item
+}
+
+// issueAchievement 为完成任务的玩家颁发成就凭证。任务可以在新房间中再次完成，
+// 玩家已持有这项成就的有效凭证时不再颁发
+func (s *SimpleServer) issueAchievement(room *GameRoom, player *Player, achievement string) {
+	if player.isGuest() {
+		return
+	}
+	if _, exists := s.vcService.AchievementCredential(player.DID, room.GameID, achievement); exists {
+		player.log().Debug("Achievement credential already issued", "achievement", achievement)
+		return
+	}
+
+	credential, err := s.vcService.IssueAchievementCredential(
+		player.traceContext(),
+		player.DID,
+		room.GameID,
+		player.ID,
+		achievement,
+		100, // 默认分数
+	)
+	if err != nil {
+		player.log().Error("Failed to issue achievement credential", "achievement", achievement, logging.Err(err))
+		return
+	}
+
+	if player.Connection != nil {
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeCredential,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"credential": credential,
+				"message":    s.localize(player, "credential.achievement", i18n.Params{"name": achievement}),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// broadcastTaskUpdate 向房间广播任务状态变化
+func (s *SimpleServer) broadcastTaskUpdate(room *GameRoom, player *Player, task *Task, action string) {
+	s.broadcastToRoom(room, Message{
+		Type:     MsgTypeTaskUpdate,
+		PlayerID: player.ID,
+		RoomID:   room.ID,
+		Data: map[string]interface{}{
+			"task":   task,
+			"action": action,
+		},
+		Timestamp: time.Now(),
+	}, "")
+}
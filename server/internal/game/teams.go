@@ -0,0 +1,223 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 队伍消息类型
+const (
+	MsgTypeJoinTeam   = "join_team"   // 玩家选择所在房间的队伍，未指定时分配到人数最少的队伍
+	MsgTypeAssignTeam = "assign_team" // 服务器通知房间内玩家的队伍分配结果
+)
+
+// TeamChatChannel 队伍频道，只有同队玩家能收到，记录按队伍分别保存
+const TeamChatChannel = "team"
+
+// maxTeams 房间最多队伍数
+const maxTeams = 8
+
+var (
+	// ErrNoTeams 房间没有启用队伍
+	ErrNoTeams = errors.New("room has no teams")
+	// ErrTeamNotFound 队伍不存在
+	ErrTeamNotFound = errors.New("team not found")
+	// ErrTeamFull 队伍人数已满
+	ErrTeamFull = errors.New("team is full")
+)
+
+// Team 房间内的队伍
+type Team struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Members     []string   `json:"members"`
+	MaxMembers  int        `json:"maxMembers,omitempty"`
+	SpawnPoints []Position `json:"spawnPoints,omitempty"`
+}
+
+// newTeams 创建 count 支队伍，按顺序将地图出生点轮流分给各队；出生点少于队伍数时所有队伍共用
+func newTeams(count int, spawnPoints []Position) []*Team {
+	teams := make([]*Team, count)
+	for i := range teams {
+		teams[i] = &Team{
+			ID:      fmt.Sprintf("team-%d", i+1),
+			Name:    fmt.Sprintf("Team %d", i+1),
+			Members: []string{},
+		}
+	}
+
+	for i, point := range spawnPoints {
+		if len(spawnPoints) < count {
+			for _, team := range teams {
+				team.SpawnPoints = append(team.SpawnPoints, point)
+			}
+			continue
+		}
+		team := teams[i%count]
+		team.SpawnPoints = append(team.SpawnPoints, point)
+	}
+	return teams
+}
+
+// hasMember 判断玩家是否在队伍中
+func (t *Team) hasMember(playerID string) bool {
+	return t.memberIndex(playerID) >= 0
+}
+
+// memberIndex 返回玩家在队伍中的序号，不在队伍中时返回 -1
+func (t *Team) memberIndex(playerID string) int {
+	for i, memberID := range t.Members {
+		if memberID == playerID {
+			return i
+		}
+	}
+	return -1
+}
+
+// spawnPoint 返回第 index 名队员的出生点
+func (t *Team) spawnPoint(index int) (Position, bool) {
+	if len(t.SpawnPoints) == 0 {
+		return Position{}, false
+	}
+	return t.SpawnPoints[index%len(t.SpawnPoints)], true
+}
+
+// team 按 ID 查找队伍，调用方需持有 room.mutex
+func (r *GameRoom) team(teamID string) *Team {
+	for _, team := range r.Teams {
+		if team.ID == teamID {
+			return team
+		}
+	}
+	return nil
+}
+
+// teamOf 返回玩家所在的队伍，调用方需持有 room.mutex
+func (r *GameRoom) teamOf(playerID string) *Team {
+	for _, team := range r.Teams {
+		if team.hasMember(playerID) {
+			return team
+		}
+	}
+	return nil
+}
+
+// smallestTeam 返回人数最少且未满的队伍，调用方需持有 room.mutex
+func (r *GameRoom) smallestTeam() *Team {
+	var smallest *Team
+	for _, team := range r.Teams {
+		if team.MaxMembers > 0 && len(team.Members) >= team.MaxMembers {
+			continue
+		}
+		if smallest == nil || len(team.Members) < len(smallest.Members) {
+			smallest = team
+		}
+	}
+	return smallest
+}
+
+// addToTeam 将玩家加入队伍并移动到队伍出生点，调用方需持有 room.mutex
+func (r *GameRoom) addToTeam(player *Player, team *Team) {
+	r.removeFromTeam(player)
+
+	if point, ok := team.spawnPoint(len(team.Members)); ok {
+		player.Position = point
+	}
+	team.Members = append(team.Members, player.ID)
+	player.TeamID = team.ID
+}
+
+// removeFromTeam 将玩家移出所在队伍，调用方需持有 room.mutex
+func (r *GameRoom) removeFromTeam(player *Player) {
+	for _, team := range r.Teams {
+		for i, memberID := range team.Members {
+			if memberID == player.ID {
+				team.Members = append(team.Members[:i], team.Members[i+1:]...)
+				break
+			}
+		}
+	}
+	player.TeamID = ""
+}
+
+// AssignTeam 将房间内的玩家分配到队伍并通知房间，teamID 为空时分配到人数最少的队伍
+func (s *SimpleServer) AssignTeam(room *GameRoom, player *Player, teamID string) (*Team, error) {
+	room.mutex.Lock()
+	if _, inRoom := room.Players[player.ID]; !inRoom {
+		room.mutex.Unlock()
+		return nil, fmt.Errorf("player %s is not in room %s", player.ID, room.ID)
+	}
+	if len(room.Teams) == 0 {
+		room.mutex.Unlock()
+		return nil, ErrNoTeams
+	}
+
+	var team *Team
+	if teamID == "" {
+		if team = room.smallestTeam(); team == nil {
+			room.mutex.Unlock()
+			return nil, ErrTeamFull
+		}
+	} else {
+		if team = room.team(teamID); team == nil {
+			room.mutex.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrTeamNotFound, teamID)
+		}
+		if team.MaxMembers > 0 && len(team.Members) >= team.MaxMembers && !team.hasMember(player.ID) {
+			room.mutex.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrTeamFull, teamID)
+		}
+	}
+
+	if !team.hasMember(player.ID) {
+		room.addToTeam(player, team)
+	}
+	position := player.Position
+	room.mutex.Unlock()
+
+	s.persistRoom(room)
+	s.broadcastToRoom(room, Message{
+		Type:     MsgTypeAssignTeam,
+		PlayerID: player.ID,
+		RoomID:   room.ID,
+		Data: map[string]interface{}{
+			"teamId":   team.ID,
+			"position": position,
+		},
+		Timestamp: time.Now(),
+	}, "")
+
+	player.log().Info("Player joined team", "team_id", team.ID)
+	return team, nil
+}
+
+// handleJoinTeam 处理 join_team 消息
+func (s *SimpleServer) handleJoinTeam(player *Player, payload *JoinTeamPayload) {
+	room := player.Room
+	if room == nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeTeamFailed, "error.team_join_room", nil)
+		return
+	}
+
+	if _, err := s.AssignTeam(room, player, payload.TeamID); err != nil {
+		s.sendErrorToPlayer(player, ErrCodeTeamFailed, err.Error())
+	}
+}
+
+// broadcastToTeam 发送给房间内某个队伍的玩家，并通过消息总线转发给其他实例
+func (s *SimpleServer) broadcastToTeam(room *GameRoom, teamID string, msg Message, excludePlayerID string) {
+	s.deliverToLocalPlayers(room, msg, excludePlayerID, teamID)
+	s.publish(busTopicRoom, roomBroadcast{
+		Origin:          s.instanceID,
+		RoomID:          room.ID,
+		TeamID:          teamID,
+		ExcludePlayerID: excludePlayerID,
+		Message:         msg,
+	})
+}
+
+// teamChatChannel 队伍频道在聊天记录中的名称
+func teamChatChannel(teamID string) string {
+	return TeamChatChannel + ":" + teamID
+}
@@ -0,0 +1,22 @@
+package game
+
+import "context"
+
+// traceContext 返回玩家正在处理的消息的追踪上下文，用于将颁发凭证、解析 DID 等操作记录为该消息的子跨度；
+// 不在处理消息时返回 context.Background()
+func (p *Player) traceContext() context.Context {
+	p.traceMutex.Lock()
+	defer p.traceMutex.Unlock()
+
+	if p.traceCtx == nil {
+		return context.Background()
+	}
+	return p.traceCtx
+}
+
+// setTraceContext 设置或清除（ctx 为 nil）玩家正在处理的消息的追踪上下文
+func (p *Player) setTraceContext(ctx context.Context) {
+	p.traceMutex.Lock()
+	p.traceCtx = ctx
+	p.traceMutex.Unlock()
+}
@@ -0,0 +1,452 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/i18n"
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// MsgTypeTrade 玩家之间交易道具
+const MsgTypeTrade = "trade"
+
+// 交易操作类型
+const (
+	TradePropose = "propose" // 向另一名玩家发起交易，可同时给出报价
+	TradeAccept  = "accept"  // 接受交易邀请，可同时给出报价
+	TradeOffer   = "offer"   // 替换自己的报价
+	TradeConfirm = "confirm" // 确认当前报价，双方都确认后交换道具
+	TradeCancel  = "cancel"  // 取消交易或拒绝邀请，托管的道具归还双方
+)
+
+// 交易状态
+const (
+	TradeStatusProposed  = "proposed"
+	TradeStatusOpen      = "open"
+	TradeStatusCompleted = "completed"
+	TradeStatusCancelled = "cancelled"
+)
+
+// TradeProposalTTL 未被接受的交易邀请的有效期，过期后对方可以接受其他交易
+const TradeProposalTTL = time.Minute
+
+// 交易错误
+var (
+	ErrTradeNotFound  = errors.New("trade not found")
+	ErrAlreadyTrading = errors.New("player is already in a trade")
+	ErrNotTradeParty  = errors.New("player is not a party to the trade")
+	ErrTradeState     = errors.New("action is not allowed in the current trade state")
+)
+
+// TradeItem 报价中的一项道具，数量为 0 表示全部
+type TradeItem struct {
+	ItemID   string `json:"itemId"`
+	Quantity int    `json:"quantity,omitempty"`
+}
+
+// Trade 两名玩家之间的一次交易。报价中的道具托管在各自背包里，直到交换或取消
+type Trade struct {
+	ID        string             `json:"id"`
+	From      string             `json:"from"`
+	To        string             `json:"to"`
+	Status    string             `json:"status"`
+	Offers    map[string][]*Item `json:"offers"`    // 玩家 ID -> 托管的道具
+	Confirmed map[string]bool    `json:"confirmed"` // 玩家 ID -> 是否确认了当前报价
+	Receipt   bool               `json:"receipt"`   // 完成后向双方颁发 TradeReceiptCredential
+	CreatedAt time.Time          `json:"createdAt"`
+
+	from, to *Player
+}
+
+// party 返回玩家在交易中的一方，不是交易方时返回 nil
+func (t *Trade) party(playerID string) *Player {
+	switch playerID {
+	case t.From:
+		return t.from
+	case t.To:
+		return t.to
+	}
+	return nil
+}
+
+// counterparty 返回交易的另一方
+func (t *Trade) counterparty(playerID string) *Player {
+	if playerID == t.From {
+		return t.to
+	}
+	return t.from
+}
+
+// snapshot 复制交易，用于在锁外发送
+func (t *Trade) snapshot() *Trade {
+	copied := *t
+	copied.Offers = make(map[string][]*Item, len(t.Offers))
+	for playerID, items := range t.Offers {
+		copied.Offers[playerID] = append([]*Item(nil), items...)
+	}
+	copied.Confirmed = make(map[string]bool, len(t.Confirmed))
+	for playerID, confirmed := range t.Confirmed {
+		copied.Confirmed[playerID] = confirmed
+	}
+	return &copied
+}
+
+// tradeSettlement 完成的交易中双方收到的道具及需要撤销的原所有权凭证
+type tradeSettlement struct {
+	received    map[string][]*Item // 玩家 ID -> 收到的道具
+	credentials []string
+}
+
+// Trades 进行中的交易，每名玩家同时只能参与一个交易。交易只保存在本实例内存中，双方需连接在同一实例
+type Trades struct {
+	trades   map[string]*Trade
+	byPlayer map[string]string // 玩家 ID -> 交易 ID
+	mutex    sync.Mutex
+}
+
+// NewTrades 创建交易管理器
+func NewTrades() *Trades {
+	return &Trades{
+		trades:   make(map[string]*Trade),
+		byPlayer: make(map[string]string),
+	}
+}
+
+// Propose 发起交易并托管发起方的报价。对方有过期的交易邀请时先取消，返回被取消的交易
+func (t *Trades) Propose(from, to *Player, offer []TradeItem, receipt bool, now time.Time) (*Trade, []*Trade, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	expired := t.expire(now)
+	if _, busy := t.byPlayer[from.ID]; busy {
+		return nil, expired, ErrAlreadyTrading
+	}
+	if _, busy := t.byPlayer[to.ID]; busy {
+		return nil, expired, ErrAlreadyTrading
+	}
+
+	trade := &Trade{
+		ID:        uuid.New().String(),
+		From:      from.ID,
+		To:        to.ID,
+		Status:    TradeStatusProposed,
+		Offers:    make(map[string][]*Item),
+		Confirmed: make(map[string]bool),
+		Receipt:   receipt,
+		CreatedAt: now,
+		from:      from,
+		to:        to,
+	}
+	if err := trade.escrow(from, offer); err != nil {
+		return nil, expired, err
+	}
+
+	t.trades[trade.ID] = trade
+	t.byPlayer[from.ID] = trade.ID
+	t.byPlayer[to.ID] = trade.ID
+	return trade.snapshot(), expired, nil
+}
+
+// Accept 接受交易邀请并托管接受方的报价
+func (t *Trades) Accept(player *Player, tradeID string, offer []TradeItem, receipt bool) (*Trade, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	trade, err := t.get(player, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade.Status != TradeStatusProposed || player.ID != trade.To {
+		return nil, ErrTradeState
+	}
+	if err := trade.escrow(player, offer); err != nil {
+		return nil, err
+	}
+
+	trade.Status = TradeStatusOpen
+	trade.Receipt = trade.Receipt || receipt
+	return trade.snapshot(), nil
+}
+
+// Offer 替换玩家的报价，双方的确认随之失效。新报价托管失败时该玩家的报价为空
+func (t *Trades) Offer(player *Player, tradeID string, offer []TradeItem) (*Trade, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	trade, err := t.get(player, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade.Status != TradeStatusOpen && !(trade.Status == TradeStatusProposed && player.ID == trade.From) {
+		return nil, ErrTradeState
+	}
+
+	player.Inventory.Release(trade.ID)
+	delete(trade.Offers, player.ID)
+	trade.Confirmed = make(map[string]bool)
+	if err := trade.escrow(player, offer); err != nil {
+		return trade.snapshot(), err
+	}
+	return trade.snapshot(), nil
+}
+
+// Confirm 确认当前报价。双方都确认后原子地交换托管的道具，返回结算结果；
+// 任一方背包放不下时交易保持打开，确认失效
+func (t *Trades) Confirm(player *Player, tradeID string) (*Trade, *tradeSettlement, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	trade, err := t.get(player, tradeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if trade.Status != TradeStatusOpen {
+		return nil, nil, ErrTradeState
+	}
+
+	trade.Confirmed[player.ID] = true
+	if !trade.Confirmed[trade.From] || !trade.Confirmed[trade.To] {
+		return trade.snapshot(), nil, nil
+	}
+
+	toFrom, toTo, credentials, err := exchangeEscrow(trade.ID, trade.from.Inventory, trade.to.Inventory)
+	if err != nil {
+		trade.Confirmed = make(map[string]bool)
+		return trade.snapshot(), nil, err
+	}
+
+	trade.Status = TradeStatusCompleted
+	t.remove(trade)
+	return trade.snapshot(), &tradeSettlement{
+		received:    map[string][]*Item{trade.From: toFrom, trade.To: toTo},
+		credentials: credentials,
+	}, nil
+}
+
+// Cancel 取消玩家参与的交易并归还托管的道具，tradeID 为空时取消玩家当前的交易
+func (t *Trades) Cancel(player *Player, tradeID string) (*Trade, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if tradeID == "" {
+		tradeID = t.byPlayer[player.ID]
+	}
+	trade, err := t.get(player, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	t.cancel(trade)
+	return trade.snapshot(), nil
+}
+
+// get 返回玩家参与的交易，调用方需持有 t.mutex
+func (t *Trades) get(player *Player, tradeID string) (*Trade, error) {
+	trade, exists := t.trades[tradeID]
+	if !exists {
+		return nil, ErrTradeNotFound
+	}
+	if trade.party(player.ID) == nil {
+		return nil, ErrNotTradeParty
+	}
+	return trade, nil
+}
+
+// cancel 归还双方托管的道具并结束交易，调用方需持有 t.mutex
+func (t *Trades) cancel(trade *Trade) {
+	trade.from.Inventory.Release(trade.ID)
+	trade.to.Inventory.Release(trade.ID)
+	trade.Status = TradeStatusCancelled
+	t.remove(trade)
+}
+
+// remove 结束交易，调用方需持有 t.mutex
+func (t *Trades) remove(trade *Trade) {
+	delete(t.trades, trade.ID)
+	delete(t.byPlayer, trade.From)
+	delete(t.byPlayer, trade.To)
+}
+
+// expire 取消过期未被接受的交易邀请，调用方需持有 t.mutex
+func (t *Trades) expire(now time.Time) []*Trade {
+	var expired []*Trade
+	for _, trade := range t.trades {
+		if trade.Status == TradeStatusProposed && now.Sub(trade.CreatedAt) > TradeProposalTTL {
+			t.cancel(trade)
+			expired = append(expired, trade.snapshot())
+		}
+	}
+	return expired
+}
+
+// escrow 托管玩家的报价，任一项失败时归还已托管的道具。调用方需持有 Trades.mutex
+func (t *Trade) escrow(player *Player, offer []TradeItem) error {
+	items := make([]*Item, 0, len(offer))
+	for _, entry := range offer {
+		item, err := player.Inventory.Escrow(entry.ItemID, entry.Quantity, t.ID)
+		if err != nil {
+			player.Inventory.Release(t.ID)
+			return fmt.Errorf("item %s: %w", entry.ItemID, err)
+		}
+		items = append(items, item)
+	}
+	t.Offers[player.ID] = items
+	return nil
+}
+
+// handleTrade 处理 trade 消息
+func (s *SimpleServer) handleTrade(player *Player, payload *TradePayload) {
+	var (
+		trade      *Trade
+		settlement *tradeSettlement
+		expired    []*Trade
+		err        error
+	)
+
+	switch payload.Action {
+	case TradePropose:
+		target, online := s.players.get(payload.To)
+		if !online || target.Connection == nil || target == player {
+			s.sendLocalizedErrorToPlayer(player, ErrCodeTradeFailed, "error.player_offline", i18n.Params{"player": payload.To})
+			return
+		}
+		trade, expired, err = s.trades.Propose(player, target, payload.Items, payload.Receipt, time.Now())
+		for _, cancelled := range expired {
+			tradesTotal.With("expired").Inc()
+			s.notifyTrade(cancelled, TradeCancel)
+		}
+	case TradeAccept:
+		trade, err = s.trades.Accept(player, payload.TradeID, payload.Items, payload.Receipt)
+	case TradeOffer:
+		trade, err = s.trades.Offer(player, payload.TradeID, payload.Items)
+	case TradeConfirm:
+		trade, settlement, err = s.trades.Confirm(player, payload.TradeID)
+	case TradeCancel:
+		trade, err = s.trades.Cancel(player, payload.TradeID)
+	}
+
+	// 报价被清空或确认失效时交易状态也发生了变化，需要通知双方
+	if trade != nil {
+		s.notifyTrade(trade, payload.Action)
+	}
+	if err != nil {
+		s.sendLocalizedErrorToPlayer(player, ErrCodeTradeFailed, "error.trade_failed", i18n.Params{"action": payload.Action, "error": err})
+		return
+	}
+
+	switch trade.Status {
+	case TradeStatusCompleted:
+		s.settleTrade(trade, settlement)
+	case TradeStatusCancelled:
+		tradesTotal.With(TradeStatusCancelled).Inc()
+		s.persistInventory(trade.from)
+		s.persistInventory(trade.to)
+		s.sendInventory(trade.from, TradeCancel, nil)
+		s.sendInventory(trade.to, TradeCancel, nil)
+	}
+}
+
+// settleTrade 交换完成后转移稀有道具的所有权凭证、持久化双方背包，并按需颁发交易回执凭证
+func (s *SimpleServer) settleTrade(trade *Trade, settlement *tradeSettlement) {
+	tradesTotal.With(TradeStatusCompleted).Inc()
+
+	for _, credentialID := range settlement.credentials {
+		if err := s.vcService.RevokeCredential(credentialID); err != nil {
+			slog.Error("Failed to revoke traded item credential", "trade_id", trade.ID, "credential_id", credentialID, logging.Err(err))
+		}
+	}
+
+	for _, player := range []*Player{trade.from, trade.to} {
+		for _, item := range settlement.received[player.ID] {
+			s.issueItemCredential(player, item)
+		}
+		s.persistInventory(player)
+		s.sendInventory(player, TradeConfirm, nil)
+
+		if trade.Receipt {
+			s.issueTradeReceipt(trade, player)
+		}
+	}
+
+	slog.Info("Trade completed", "trade_id", trade.ID, "from", trade.From, "to", trade.To)
+}
+
+// issueTradeReceipt 向交易一方颁发 TradeReceiptCredential，记录双方交换的道具
+func (s *SimpleServer) issueTradeReceipt(trade *Trade, player *Player) {
+	if player.DID == "" || player.isGuest() {
+		return
+	}
+
+	counterparty := trade.counterparty(player.ID)
+	gameID := "default"
+	if room := player.Room; room != nil {
+		gameID = room.GameID
+	}
+
+	credential, err := s.vcService.IssueTradeReceiptCredential(player.traceContext(), player.DID, gameID, player.ID, trade.ID,
+		counterparty.ID, tradeItemNames(trade.Offers[player.ID]), tradeItemNames(trade.Offers[counterparty.ID]))
+	if err != nil {
+		player.log().Error("Failed to issue trade receipt credential", "trade_id", trade.ID, logging.Err(err))
+		return
+	}
+	if player.Connection != nil {
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeCredential,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"credential": credential,
+				"message":    s.localize(player, "credential.trade", i18n.Params{"counterparty": counterparty.ID}),
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// tradeItemNames 返回回执中记录的道具描述
+func tradeItemNames(items []*Item) []string {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Quantity > 1 {
+			names = append(names, fmt.Sprintf("%s x%d", item.Name, item.Quantity))
+		} else {
+			names = append(names, item.Name)
+		}
+	}
+	return names
+}
+
+// notifyTrade 向交易双方推送交易状态
+func (s *SimpleServer) notifyTrade(trade *Trade, action string) {
+	for _, player := range []*Player{trade.from, trade.to} {
+		if player.Connection == nil {
+			continue
+		}
+		writeMessage(player.Connection, Message{
+			Type:     MsgTypeTrade,
+			PlayerID: player.ID,
+			Data: map[string]interface{}{
+				"action": action,
+				"trade":  trade,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// cancelTrades 玩家断开连接时取消其参与的交易
+func (s *SimpleServer) cancelTrades(player *Player) {
+	trade, err := s.trades.Cancel(player, "")
+	if err != nil {
+		return
+	}
+	tradesTotal.With(TradeStatusCancelled).Inc()
+	s.notifyTrade(trade, TradeCancel)
+	s.persistInventory(trade.from)
+	s.persistInventory(trade.to)
+	s.sendInventory(trade.counterparty(player.ID), TradeCancel, nil)
+}
@@ -0,0 +1,42 @@
+package game
+
+import (
+	"time"
+
+	"github.com/czh0526/game/server/internal/webhook"
+)
+
+// SetWebhooks 启用 webhook，向订阅方推送玩家加入、任务完成和对局结束事件，应在接受连接前调用
+func (s *SimpleServer) SetWebhooks(dispatcher *webhook.Dispatcher) {
+	s.webhooks = dispatcher
+}
+
+// publishPlayerJoined 推送 player_joined 事件
+func (s *SimpleServer) publishPlayerJoined(room *GameRoom, player *Player) {
+	s.webhooks.Publish(webhook.EventPlayerJoined, map[string]interface{}{
+		"roomId":   room.ID,
+		"gameId":   room.GameID,
+		"playerId": player.ID,
+		"did":      player.DID,
+		"nickname": player.Nickname,
+		"joinedAt": time.Now(),
+	})
+}
+
+// publishTaskCompleted 推送 task_completed 事件
+func (s *SimpleServer) publishTaskCompleted(room *GameRoom, player *Player, task *Task) {
+	s.webhooks.Publish(webhook.EventTaskCompleted, map[string]interface{}{
+		"roomId":   room.ID,
+		"gameId":   room.GameID,
+		"playerId": player.ID,
+		"did":      player.DID,
+		"taskId":   task.ID,
+		"taskName": task.Name,
+		"taskType": task.Type,
+	})
+}
+
+// publishMatchFinished 推送 match_finished 事件，载荷为对局结果
+func (s *SimpleServer) publishMatchFinished(match *MatchResult) {
+	s.webhooks.Publish(webhook.EventMatchFinished, match)
+}
@@ -0,0 +1,67 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/internal/msgpack"
+)
+
+// WebSocket 子协议，客户端在握手时通过 Sec-WebSocket-Protocol 选择消息编码，
+// 未请求子协议的连接使用 JSON 文本帧
+const (
+	SubprotocolJSON    = "game.v1.json"
+	SubprotocolMsgPack = "game.v1.msgpack"
+)
+
+// subprotocols 服务器支持的子协议，客户端同时请求多个时按此顺序选择
+var subprotocols = []string{SubprotocolMsgPack, SubprotocolJSON}
+
+// writeMessage 按连接协商的子协议编码消息，放入连接的发送队列，msgpack 连接使用二进制帧。
+// 协商了 permessage-deflate 的连接由写协程按阈值压缩
+func writeMessage(conn *websocket.Conn, v interface{}) error {
+	if conn.Subprotocol() != SubprotocolMsgPack {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode message: %w", err)
+		}
+		return enqueue(conn, outboundFrame{messageType: websocket.TextMessage, data: data})
+	}
+
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+	return enqueue(conn, outboundFrame{messageType: websocket.BinaryMessage, data: data})
+}
+
+// decodeInbound 按连接协商的子协议解码客户端消息。msgpack 消息的 data 转换为 JSON，
+// 之后与 JSON 连接一样按消息类型解码和校验载荷
+func decodeInbound(conn *websocket.Conn, data []byte) (inboundMessage, error) {
+	var msg inboundMessage
+	if conn.Subprotocol() != SubprotocolMsgPack {
+		err := json.Unmarshal(data, &msg)
+		return msg, err
+	}
+
+	decoded, err := msgpack.Decode(data)
+	if err != nil {
+		return msg, err
+	}
+	fields, ok := decoded.(map[string]interface{})
+	if !ok {
+		return msg, fmt.Errorf("message must be a map, got %T", decoded)
+	}
+
+	msg.Type, _ = fields["type"].(string)
+	msg.PlayerID, _ = fields["playerId"].(string)
+	msg.RoomID, _ = fields["roomId"].(string)
+	if payload, exists := fields["data"]; exists {
+		if msg.Data, err = json.Marshal(payload); err != nil {
+			return msg, fmt.Errorf("convert data: %w", err)
+		}
+	}
+	return msg, nil
+}
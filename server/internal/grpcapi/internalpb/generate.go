@@ -0,0 +1,4 @@
+// Package internalpb 内部 gRPC API 的 protobuf 定义和生成代码
+package internalpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative internal.proto
@@ -0,0 +1,115 @@
+// Package grpcapi 以 gRPC 向其他后端服务（匹配、数据分析、计费等）提供 DID 解析、凭证颁发和验证以及房间管理，
+// 接口定义见 internalpb/internal.proto。内部 API 只应在内网监听，调用方以共享令牌认证
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/grpcapi/internalpb"
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/tracing"
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+// Config 内部 API 配置
+type Config struct {
+	// Token 调用方在 authorization 元数据中以 Bearer <token> 出示的共享令牌
+	Token string
+}
+
+// Server 内部 gRPC API
+type Server struct {
+	config     Config
+	didService *did.SimpleService
+	vcService  *vc.SimpleService
+	gameServer *game.SimpleServer
+	grpc       *grpc.Server
+}
+
+// NewServer 创建内部 API 并注册身份、凭证和房间服务
+func NewServer(config Config, didService *did.SimpleService, vcService *vc.SimpleService, gameServer *game.SimpleServer) (*Server, error) {
+	if config.Token == "" {
+		return nil, errors.New("internal API requires a token")
+	}
+
+	s := &Server{
+		config:     config,
+		didService: didService,
+		vcService:  vcService,
+		gameServer: gameServer,
+	}
+	s.grpc = grpc.NewServer(grpc.ChainUnaryInterceptor(s.observe, s.authenticate))
+	internalpb.RegisterIdentityServiceServer(s.grpc, &identityService{didService: didService})
+	internalpb.RegisterCredentialServiceServer(s.grpc, &credentialService{vcService: vcService})
+	internalpb.RegisterRoomServiceServer(s.grpc, &roomService{gameServer: gameServer})
+	return s, nil
+}
+
+// Serve 在 lis 上处理请求，直到 Stop 或 GracefulStop
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// GracefulStop 停止接受新请求并等待进行中的请求完成
+func (s *Server) GracefulStop() {
+	s.grpc.GracefulStop()
+}
+
+// Stop 立即关闭所有连接
+func (s *Server) Stop() {
+	s.grpc.Stop()
+}
+
+// observe 为每个调用记录追踪跨度和请求日志，审计日志的调用方为 grpc:{客户端地址}
+func (s *Server) observe(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := tracing.StartKind(ctx, "grpc "+info.FullMethod, tracing.SpanKindServer,
+		tracing.String("rpc.system", "grpc"),
+		tracing.String("rpc.method", info.FullMethod),
+	)
+	defer span.End()
+
+	caller := "grpc:unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		caller = "grpc:" + host
+	}
+	logger := slog.Default().With(logging.KeyRequestID, logging.NewID(), "method", info.FullMethod, "caller", caller)
+	ctx = audit.WithCaller(logging.WithLogger(ctx, logger), caller)
+
+	started := time.Now()
+	resp, err := handler(ctx, req)
+	span.RecordError(err)
+	logger.Debug("gRPC request", "code", status.Code(err).String(), "duration", time.Since(started))
+	return resp, err
+}
+
+// authenticate 校验 authorization 元数据中的共享令牌
+func (s *Server) authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token = strings.TrimPrefix(values[0], "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing token")
+	}
+	return handler(ctx, req)
+}
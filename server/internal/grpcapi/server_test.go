@@ -0,0 +1,225 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/grpcapi/internalpb"
+	"github.com/czh0526/game/server/internal/vc"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+)
+
+const testToken = "internal-secret"
+
+// newTestConn 在本地端口启动内部 API，返回到它的客户端连接
+func newTestConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	didService := did.NewSimpleService()
+	vcService, err := vc.NewSimpleService(didService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gameServer, err := game.NewSimpleServer(didService, vcService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { gameServer.Close() })
+
+	server, err := NewServer(Config{Token: testToken}, didService, vcService, gameServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// authorized 返回带有共享令牌的调用上下文
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+testToken)
+}
+
+func newKeyDID(t *testing.T) string {
+	t.Helper()
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgdid.NewKeyDID(publicKey)
+}
+
+func TestNewServerRequiresToken(t *testing.T) {
+	if _, err := NewServer(Config{}, nil, nil, nil); err == nil {
+		t.Error("created an internal API without a token")
+	}
+}
+
+func TestAuthenticateRejectsMissingOrWrongToken(t *testing.T) {
+	client := internalpb.NewRoomServiceClient(newTestConn(t))
+
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer other")
+	for name, ctx := range map[string]context.Context{"missing": context.Background(), "wrong": wrong} {
+		_, err := client.ListRooms(ctx, &internalpb.ListRoomsRequest{})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s token: code %v, want %v", name, status.Code(err), codes.Unauthenticated)
+		}
+	}
+	if _, err := client.ListRooms(authorized(), &internalpb.ListRoomsRequest{}); err != nil {
+		t.Errorf("valid token: %v", err)
+	}
+}
+
+func TestResolveDID(t *testing.T) {
+	client := internalpb.NewIdentityServiceClient(newTestConn(t))
+
+	didKey := newKeyDID(t)
+	resp, err := client.ResolveDID(authorized(), &internalpb.ResolveDIDRequest{Did: didKey})
+	if err != nil {
+		t.Fatalf("ResolveDID: %v", err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(resp.GetDocumentJson(), &document); err != nil {
+		t.Fatalf("document_json: %v", err)
+	}
+	if resp.GetDid() != didKey || document["id"] != didKey {
+		t.Errorf("resolved %q with document id %v, want %q", resp.GetDid(), document["id"], didKey)
+	}
+
+	if _, err := client.ResolveDID(authorized(), &internalpb.ResolveDIDRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("empty did: code %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+	if _, err := client.ResolveDID(authorized(), &internalpb.ResolveDIDRequest{Did: "did:player:chess:missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown did: code %v, want %v", status.Code(err), codes.NotFound)
+	}
+}
+
+func TestCredentialLifecycle(t *testing.T) {
+	client := internalpb.NewCredentialServiceClient(newTestConn(t))
+
+	issued, err := client.IssueCredential(authorized(), &internalpb.IssueCredentialRequest{
+		HolderDid:   newKeyDID(t),
+		Type:        "LevelCredential",
+		SubjectJson: []byte(`{"level":7}`),
+	})
+	if err != nil {
+		t.Fatalf("IssueCredential: %v", err)
+	}
+	if issued.GetCredentialId() == "" {
+		t.Fatal("issued credential has no id")
+	}
+
+	verify := func() *internalpb.VerifyCredentialResponse {
+		t.Helper()
+		resp, err := client.VerifyCredential(authorized(), &internalpb.VerifyCredentialRequest{CredentialJson: issued.GetCredentialJson()})
+		if err != nil {
+			t.Fatalf("VerifyCredential: %v", err)
+		}
+		return resp
+	}
+	if resp := verify(); !resp.GetValid() {
+		t.Fatalf("issued credential invalid: %s", resp.GetMessage())
+	}
+
+	if _, err := client.RevokeCredential(authorized(), &internalpb.RevokeCredentialRequest{CredentialId: issued.GetCredentialId()}); err != nil {
+		t.Fatalf("RevokeCredential: %v", err)
+	}
+	if resp := verify(); resp.GetValid() {
+		t.Error("revoked credential verified")
+	}
+}
+
+func TestCredentialServiceRejectsInvalidRequests(t *testing.T) {
+	client := internalpb.NewCredentialServiceClient(newTestConn(t))
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"issue without holder", func() error {
+			_, err := client.IssueCredential(authorized(), &internalpb.IssueCredentialRequest{Type: "LevelCredential"})
+			return err
+		}},
+		{"issue without type", func() error {
+			_, err := client.IssueCredential(authorized(), &internalpb.IssueCredentialRequest{HolderDid: "did:example:a"})
+			return err
+		}},
+		{"issue with malformed subject", func() error {
+			_, err := client.IssueCredential(authorized(), &internalpb.IssueCredentialRequest{HolderDid: "did:example:a", Type: "LevelCredential", SubjectJson: []byte("{")})
+			return err
+		}},
+		{"verify malformed credential", func() error {
+			_, err := client.VerifyCredential(authorized(), &internalpb.VerifyCredentialRequest{CredentialJson: []byte("{")})
+			return err
+		}},
+		{"revoke without id", func() error {
+			_, err := client.RevokeCredential(authorized(), &internalpb.RevokeCredentialRequest{})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if code := status.Code(tt.call()); code != codes.InvalidArgument {
+			t.Errorf("%s: code %v, want %v", tt.name, code, codes.InvalidArgument)
+		}
+	}
+}
+
+func TestRoomService(t *testing.T) {
+	client := internalpb.NewRoomServiceClient(newTestConn(t))
+
+	created, err := client.CreateRoom(authorized(), &internalpb.CreateRoomRequest{RoomId: "lobby", GameId: "chess", MinLevel: 3})
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	if created.GetId() != "lobby" || created.GetGameId() != "chess" || created.GetMinLevel() != 3 {
+		t.Errorf("unexpected room: %+v", created)
+	}
+
+	room, err := client.GetRoom(authorized(), &internalpb.GetRoomRequest{RoomId: "lobby"})
+	if err != nil {
+		t.Fatalf("GetRoom: %v", err)
+	}
+	if room.GetId() != "lobby" {
+		t.Errorf("got room %q, want lobby", room.GetId())
+	}
+
+	list, err := client.ListRooms(authorized(), &internalpb.ListRoomsRequest{})
+	if err != nil {
+		t.Fatalf("ListRooms: %v", err)
+	}
+	found := false
+	for _, r := range list.GetRooms() {
+		found = found || r.GetId() == "lobby"
+	}
+	if !found {
+		t.Error("created room missing from ListRooms")
+	}
+
+	if _, err := client.GetRoom(authorized(), &internalpb.GetRoomRequest{RoomId: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown room: code %v, want %v", status.Code(err), codes.NotFound)
+	}
+	if _, err := client.CreateRoom(authorized(), &internalpb.CreateRoomRequest{RoomId: "arena", GameId: "chess", Mode: "no-such-mode"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown mode: code %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+}
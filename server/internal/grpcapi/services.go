@@ -0,0 +1,182 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/grpcapi/internalpb"
+	"github.com/czh0526/game/server/internal/vc"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	pkgvc "github.com/czh0526/game/server/pkg/vc"
+)
+
+// identityService 实现 IdentityService
+type identityService struct {
+	internalpb.UnimplementedIdentityServiceServer
+	didService *did.SimpleService
+}
+
+// ResolveDID 解析 DID 并返回 JSON 编码的 DID 文档
+func (s *identityService) ResolveDID(ctx context.Context, req *internalpb.ResolveDIDRequest) (*internalpb.ResolveDIDResponse, error) {
+	if req.GetDid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "did is required")
+	}
+
+	resolved, err := s.didService.ResolveDIDContext(ctx, req.GetDid())
+	switch {
+	case errors.Is(err, pkgdid.ErrDeactivated):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil && did.ResolutionErrorCode(err) == did.ResolutionNotFound:
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "resolve DID: %v", err)
+	}
+
+	document, err := json.Marshal(resolved.DIDDoc)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshal DID document: %v", err)
+	}
+	return &internalpb.ResolveDIDResponse{Did: resolved.DID, DocumentJson: document}, nil
+}
+
+// credentialService 实现 CredentialService
+type credentialService struct {
+	internalpb.UnimplementedCredentialServiceServer
+	vcService *vc.SimpleService
+}
+
+// IssueCredential 以请求的游戏或服务器的颁发者签发凭证
+func (s *credentialService) IssueCredential(ctx context.Context, req *internalpb.IssueCredentialRequest) (*internalpb.IssueCredentialResponse, error) {
+	if req.GetHolderDid() == "" || req.GetType() == "" {
+		return nil, status.Error(codes.InvalidArgument, "holder_did and type are required")
+	}
+
+	var subject pkgvc.CredentialSubject
+	if len(req.GetSubjectJson()) > 0 {
+		if err := json.Unmarshal(req.GetSubjectJson(), &subject); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid subject_json: %v", err)
+		}
+	}
+	subject.ID = req.GetHolderDid()
+	if req.GetGameId() != "" {
+		subject.GameID = req.GetGameId()
+	}
+
+	var expiresAt *time.Time
+	if req.GetExpiresAt() > 0 {
+		expires := time.Unix(req.GetExpiresAt(), 0)
+		expiresAt = &expires
+	}
+
+	credential, err := s.vcService.IssueGameCredentialContext(ctx, req.GetGameId(), req.GetHolderDid(), req.GetType(), subject, expiresAt)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "issue credential: %v", err)
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshal credential: %v", err)
+	}
+	return &internalpb.IssueCredentialResponse{CredentialId: credential.ID, CredentialJson: data}, nil
+}
+
+// VerifyCredential 验证 JSON 编码的凭证，验证失败时 valid 为 false
+func (s *credentialService) VerifyCredential(ctx context.Context, req *internalpb.VerifyCredentialRequest) (*internalpb.VerifyCredentialResponse, error) {
+	var credential pkgvc.SimpleCredential
+	if err := json.Unmarshal(req.GetCredentialJson(), &credential); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid credential_json: %v", err)
+	}
+
+	valid, message := s.vcService.VerifyCredentialContext(ctx, &credential)
+	return &internalpb.VerifyCredentialResponse{Valid: valid, Message: message}, nil
+}
+
+// RevokeCredential 撤销本服务器颁发的凭证
+func (s *credentialService) RevokeCredential(ctx context.Context, req *internalpb.RevokeCredentialRequest) (*internalpb.RevokeCredentialResponse, error) {
+	if req.GetCredentialId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "credential_id is required")
+	}
+	if err := s.vcService.RevokeCredentialContext(ctx, req.GetCredentialId()); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "revoke credential: %v", err)
+	}
+	return &internalpb.RevokeCredentialResponse{}, nil
+}
+
+// roomService 实现 RoomService
+type roomService struct {
+	internalpb.UnimplementedRoomServiceServer
+	gameServer *game.SimpleServer
+}
+
+// ListRooms 返回本实例上的所有房间
+func (s *roomService) ListRooms(ctx context.Context, req *internalpb.ListRoomsRequest) (*internalpb.ListRoomsResponse, error) {
+	infos := s.gameServer.Rooms()
+	rooms := make([]*internalpb.Room, 0, len(infos))
+	for i := range infos {
+		rooms = append(rooms, roomMessage(&infos[i]))
+	}
+	return &internalpb.ListRoomsResponse{Rooms: rooms}, nil
+}
+
+// GetRoom 返回房间详情
+func (s *roomService) GetRoom(ctx context.Context, req *internalpb.GetRoomRequest) (*internalpb.Room, error) {
+	info, err := s.gameServer.Room(req.GetRoomId())
+	if errors.Is(err, game.ErrRoomNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "get room: %v", err)
+	}
+	return roomMessage(info), nil
+}
+
+// CreateRoom 在本实例上创建房间，房间由其他实例托管时返回 FAILED_PRECONDITION
+func (s *roomService) CreateRoom(ctx context.Context, req *internalpb.CreateRoomRequest) (*internalpb.Room, error) {
+	info, err := s.gameServer.CreateRoom(req.GetRoomId(), req.GetGameId(), &game.RoomOptions{
+		RequiredCredentials: req.GetRequiredCredentials(),
+		MinLevel:            int(req.GetMinLevel()),
+		Teams:               int(req.GetTeams()),
+		Mode:                req.GetMode(),
+	})
+	var redirect *game.RoomRedirectError
+	if errors.As(err, &redirect) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "create room: %v", err)
+	}
+	return roomMessage(info), nil
+}
+
+// roomMessage 将房间详情转换为 protobuf 消息
+func roomMessage(info *game.RoomInfo) *internalpb.Room {
+	room := &internalpb.Room{
+		Id:                  info.ID,
+		Name:                info.Name,
+		GameId:              info.GameID,
+		MaxPlayers:          int32(info.MaxPlayers),
+		Status:              info.Status,
+		Tick:                info.Tick,
+		Mode:                info.Mode,
+		RequiredCredentials: info.RequiredCredentials,
+		MinLevel:            int32(info.MinLevel),
+		PasswordProtected:   info.PasswordProtected,
+		CreatedAt:           info.CreatedAt.Unix(),
+	}
+	for _, player := range info.Players {
+		room.Players = append(room.Players, &internalpb.Player{
+			Id:       player.ID,
+			Did:      player.DID,
+			Nickname: player.Nickname,
+			Status:   player.Status,
+		})
+	}
+	return room
+}
@@ -0,0 +1,246 @@
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Params 消息参数，替换模板中同名的 {name} 占位符
+type Params map[string]interface{}
+
+// Bundle 一种语言的消息模板，键为消息键
+type Bundle map[string]string
+
+// bundle 已注册的语言包，tag 保留注册时的写法
+type bundle struct {
+	tag      string
+	messages Bundle
+}
+
+// Catalog 多语言消息目录。消息先按请求的语言查找，缺失时使用默认语言，仍缺失时返回消息键本身
+type Catalog struct {
+	fallback string
+	bundles  map[string]*bundle
+	mutex    sync.RWMutex
+}
+
+// NewCatalog 创建以 fallback 为默认语言的空目录
+func NewCatalog(fallback string) *Catalog {
+	return &Catalog{
+		fallback: fallback,
+		bundles:  make(map[string]*bundle),
+	}
+}
+
+// Fallback 返回默认语言
+func (c *Catalog) Fallback() string {
+	return c.fallback
+}
+
+// Add 注册语言包，已存在的语言会合并消息，新消息覆盖同名的旧消息
+func (c *Catalog) Add(locale string, messages Bundle) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := normalize(locale)
+	existing, exists := c.bundles[key]
+	if !exists {
+		existing = &bundle{tag: locale, messages: make(Bundle, len(messages))}
+		c.bundles[key] = existing
+	}
+	for k, v := range messages {
+		existing.messages[k] = v
+	}
+}
+
+// LoadDir 加载目录中的 {语言}.json 语言包，如 zh-CN.json，目录不存在时不做任何事，返回加载的语言包数
+func (c *Catalog) LoadDir(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read locale directory: %w", err)
+	}
+
+	loaded := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return loaded, fmt.Errorf("read locale bundle %s: %w", entry.Name(), err)
+		}
+		var messages Bundle
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return loaded, fmt.Errorf("decode locale bundle %s: %w", entry.Name(), err)
+		}
+		c.Add(strings.TrimSuffix(entry.Name(), ".json"), messages)
+		loaded++
+	}
+	return loaded, nil
+}
+
+// Locales 返回已注册的语言，按字母排序
+func (c *Catalog) Locales() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	locales := make([]string, 0, len(c.bundles))
+	for _, b := range c.bundles {
+		locales = append(locales, b.tag)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Negotiate 按 Accept-Language 格式的语言偏好（如 "zh-CN,zh;q=0.9,en;q=0.8"）选择已注册的语言。
+// 每个偏好先精确匹配，再匹配主语言相同的语言包，都不匹配时返回默认语言
+func (c *Catalog) Negotiate(requested string) string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, tag := range parsePreferences(requested) {
+		if b, exists := c.bundles[normalize(tag)]; exists {
+			return b.tag
+		}
+		if tag := c.matchBase(base(normalize(tag))); tag != "" {
+			return tag
+		}
+	}
+	return c.fallback
+}
+
+// matchBase 查找主语言为 lang 的语言包，优先选择只有主语言的语言包，调用方需持有锁
+func (c *Catalog) matchBase(lang string) string {
+	if b, exists := c.bundles[lang]; exists {
+		return b.tag
+	}
+	var candidates []string
+	for key, b := range c.bundles {
+		if base(key) == lang {
+			candidates = append(candidates, b.tag)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Strings(candidates)
+	return candidates[0]
+}
+
+// Translate 返回消息键在该语言下替换参数后的文本
+func (c *Catalog) Translate(locale, key string, params Params) string {
+	c.mutex.RLock()
+	template, found := c.lookup(locale, key)
+	if !found {
+		template, found = c.lookup(c.fallback, key)
+	}
+	c.mutex.RUnlock()
+
+	if !found {
+		template = key
+	}
+	return Format(template, params)
+}
+
+// lookup 在单个语言包中查找消息，调用方需持有锁
+func (c *Catalog) lookup(locale, key string) (string, bool) {
+	b, exists := c.bundles[normalize(locale)]
+	if !exists {
+		return "", false
+	}
+	template, found := b.messages[key]
+	return template, found
+}
+
+// Format 把模板中的 {name} 替换为参数值，没有对应参数的占位符原样保留
+func Format(template string, params Params) string {
+	if len(params) == 0 || !strings.Contains(template, "{") {
+		return template
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(template[:start])
+		if value, exists := params[template[start+1:end]]; exists {
+			b.WriteString(fmt.Sprint(value))
+		} else {
+			b.WriteString(template[start : end+1])
+		}
+		template = template[end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// parsePreferences 解析语言偏好列表，按权重从高到低返回语言标签，忽略 "*" 和权重为 0 的语言
+func parsePreferences(requested string) []string {
+	type preference struct {
+		tag    string
+		weight float64
+	}
+
+	var preferences []preference
+	for _, part := range strings.Split(requested, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		if weight <= 0 {
+			continue
+		}
+		preferences = append(preferences, preference{tag: tag, weight: weight})
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].weight > preferences[j].weight
+	})
+	tags := make([]string, len(preferences))
+	for i, p := range preferences {
+		tags[i] = p.tag
+	}
+	return tags
+}
+
+// normalize 统一语言标签的大小写和分隔符，zh_CN 和 zh-cn 视为同一语言
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// base 返回规范化语言标签的主语言部分
+func base(tag string) string {
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,134 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestCatalog() *Catalog {
+	catalog := NewCatalog("en")
+	catalog.Add("en", Bundle{
+		"room.joined": "{player} joined {room}",
+		"room.full":   "Room is full",
+	})
+	catalog.Add("zh-CN", Bundle{"room.joined": "{player} 加入了 {room}"})
+	catalog.Add("pt-BR", Bundle{"room.joined": "{player} entrou em {room}"})
+	catalog.Add("pt", Bundle{"room.joined": "{player} entrou na {room}"})
+	return catalog
+}
+
+func TestNegotiate(t *testing.T) {
+	catalog := newTestCatalog()
+
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh-CN"},
+		// 大小写和分隔符不同的标签视为同一语言
+		{"zh_cn", "zh-CN"},
+		// 没有地区完全匹配的语言包时退回主语言，优先选择只有主语言的语言包
+		{"zh-TW", "zh-CN"},
+		{"pt-PT", "pt"},
+		// 按权重而不是出现顺序选择
+		{"en;q=0.5,pt-BR;q=0.9", "pt-BR"},
+		// 权重为 0 的语言和 * 被忽略
+		{"zh-CN;q=0,*", "en"},
+		{"", "en"},
+		{"fr,de", "en"},
+	}
+	for _, tt := range tests {
+		if got := catalog.Negotiate(tt.requested); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateFallsBackToDefaultLocaleAndKey(t *testing.T) {
+	catalog := newTestCatalog()
+	params := Params{"player": "alice", "room": "lobby"}
+
+	if got := catalog.Translate("zh-CN", "room.joined", params); got != "alice 加入了 lobby" {
+		t.Errorf("zh-CN room.joined = %q", got)
+	}
+	// 缺失的消息使用默认语言
+	if got := catalog.Translate("zh-CN", "room.full", nil); got != "Room is full" {
+		t.Errorf("zh-CN room.full = %q, want the English message", got)
+	}
+	// 默认语言也缺失时返回消息键本身
+	if got := catalog.Translate("zh-CN", "room.closed", nil); got != "room.closed" {
+		t.Errorf("missing message = %q, want the key", got)
+	}
+}
+
+func TestAddMergesBundles(t *testing.T) {
+	catalog := newTestCatalog()
+	catalog.Add("ZH_cn", Bundle{"room.full": "房间已满"})
+
+	if got := catalog.Translate("zh-CN", "room.full", nil); got != "房间已满" {
+		t.Errorf("merged message = %q", got)
+	}
+	if got := catalog.Translate("zh-CN", "room.joined", Params{"player": "a", "room": "b"}); got != "a 加入了 b" {
+		t.Errorf("existing message lost after merge: %q", got)
+	}
+	// 合并不改变注册时的标签写法
+	if got := catalog.Locales(); !reflect.DeepEqual(got, []string{"en", "pt", "pt-BR", "zh-CN"}) {
+		t.Errorf("Locales = %v", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		template string
+		params   Params
+		want     string
+	}{
+		{"{player} scored {score}", Params{"player": "alice", "score": 42}, "alice scored 42"},
+		// 没有对应参数的占位符原样保留
+		{"{player} in {room}", Params{"player": "alice"}, "alice in {room}"},
+		{"unclosed {player", Params{"player": "alice"}, "unclosed {player"},
+		{"{player}", nil, "{player}"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.template, tt.params); got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	catalog := NewCatalog("en")
+
+	// 目录不存在时不做任何事
+	if n, err := catalog.LoadDir(filepath.Join(t.TempDir(), "missing")); n != 0 || err != nil {
+		t.Fatalf("LoadDir(missing) = %d, %v", n, err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"ja.json":    `{"room.full": "満室です"}`,
+		"notes.txt":  "not a bundle",
+		"en-GB.json": `{"room.full": "Room is full"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := catalog.LoadDir(dir)
+	if err != nil || n != 2 {
+		t.Fatalf("LoadDir = %d, %v, want 2 bundles", n, err)
+	}
+	if got := catalog.Translate("ja", "room.full", nil); got != "満室です" {
+		t.Errorf("ja room.full = %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.LoadDir(dir); err == nil {
+		t.Error("malformed bundle loaded without error")
+	}
+}
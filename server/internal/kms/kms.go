@@ -0,0 +1,144 @@
+package kms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeyType 密钥类型
+type KeyType string
+
+// 支持的密钥类型
+const (
+	KeyTypeEd25519   KeyType = "Ed25519"
+	KeyTypeECDSAP256 KeyType = "ECDSA_P256"
+	// KeyTypeAES256 对称数据密钥，只能通过 DataKeyProvider 获取
+	KeyTypeAES256 KeyType = "AES256"
+)
+
+// KMS 错误
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrKeyExists   = errors.New("key already exists")
+)
+
+// KeyManager 管理签名密钥。私钥只在 KMS 内部使用，调用方通过密钥 ID 获取公钥和签名
+type KeyManager interface {
+	// CreateKey 创建指定 ID 和类型的密钥，返回公钥；ID 已存在时返回 ErrKeyExists
+	CreateKey(keyID string, keyType KeyType) (crypto.PublicKey, error)
+	// PublicKey 返回密钥的公钥，密钥不存在时返回 ErrKeyNotFound
+	PublicKey(keyID string) (crypto.PublicKey, error)
+	// Sign 与 crypto.Signer 约定相同：Ed25519 对原始消息签名，ECDSA 对摘要签名并返回 ASN.1 DER 编码
+	Sign(keyID string, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// DataKeyProvider 提供 256 位对称数据密钥，用于在进程内加密存储中的数据。
+// 数据密钥需要交给调用方使用，只有本地 KMS 实现此接口
+type DataKeyProvider interface {
+	// DataKey 返回指定 ID 的数据密钥，不存在时创建
+	DataKey(keyID string) ([]byte, error)
+}
+
+// EnsureKey 返回已有密钥的公钥，不存在时创建；已有密钥类型不符时返回错误
+func EnsureKey(keys KeyManager, keyID string, keyType KeyType) (crypto.PublicKey, error) {
+	publicKey, err := keys.PublicKey(keyID)
+	if errors.Is(err, ErrKeyNotFound) {
+		return keys.CreateKey(keyID, keyType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := keyTypeOf(publicKey); actual != keyType {
+		return nil, fmt.Errorf("key %s has type %s, want %s", keyID, actual, keyType)
+	}
+	return publicKey, nil
+}
+
+// Signer 返回由 KMS 签名的 crypto.Signer，可直接用于凭证和 JWT 签名
+func Signer(keys KeyManager, keyID string) (crypto.Signer, error) {
+	publicKey, err := keys.PublicKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return &signer{keys: keys, keyID: keyID, publicKey: publicKey}, nil
+}
+
+type signer struct {
+	keys      KeyManager
+	keyID     string
+	publicKey crypto.PublicKey
+}
+
+func (s *signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+func (s *signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.keys.Sign(s.keyID, digest, opts)
+}
+
+// keyTypeOf 返回公钥对应的密钥类型，不支持的类型返回空字符串
+func keyTypeOf(publicKey crypto.PublicKey) KeyType {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return KeyTypeEd25519
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return KeyTypeECDSAP256
+		}
+	}
+	return ""
+}
+
+// 后端
+const (
+	BackendLocal = "local"
+	BackendVault = "vault"
+)
+
+// Config KMS 配置
+type Config struct {
+	// Backend local 或 vault
+	Backend string
+	// KeystorePath 本地后端的加密密钥库文件，不设置 Ephemeral 时必填
+	KeystorePath string
+	// Ephemeral 本地后端不使用密钥库，密钥只保存在内存中，进程退出后丢失，仅用于开发和测试
+	Ephemeral bool
+	// Passphrase 加密密钥库的口令，使用 KeystorePath 时必填
+	Passphrase string
+	// Vault vault 后端配置
+	Vault VaultConfig
+}
+
+// DefaultConfig 默认配置：本地后端，需要再设置密钥库或 Ephemeral
+func DefaultConfig() Config {
+	return Config{
+		Backend: BackendLocal,
+		Vault:   DefaultVaultConfig(),
+	}
+}
+
+// Open 按配置创建 KMS。本地后端没有密钥库时拒绝启动，除非明确设置 Ephemeral，
+// 以免颁发者密钥在重启后丢失或以明文保存在其他地方。不支持 AWS KMS
+func Open(config Config) (KeyManager, error) {
+	switch config.Backend {
+	case "", BackendLocal:
+		switch {
+		case config.KeystorePath == "" && !config.Ephemeral:
+			return nil, errors.New("local KMS requires a keystore file and passphrase; use the vault backend, or allow in-memory keys for development only")
+		case config.KeystorePath != "" && config.Ephemeral:
+			return nil, errors.New("local KMS cannot use a keystore file and in-memory keys at the same time")
+		}
+		return NewLocalKMS(config.KeystorePath, config.Passphrase)
+	case BackendVault:
+		return NewVaultKMS(config.Vault)
+	default:
+		return nil, fmt.Errorf("unknown KMS backend %q: use local or vault", config.Backend)
+	}
+}
@@ -0,0 +1,335 @@
+package kms
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 密钥库加密参数
+const (
+	keystoreVersion    = 1
+	keystoreKDF        = "pbkdf2-sha256"
+	keystoreIterations = 600000
+	keystoreSaltSize   = 16
+	keystoreKeySize    = 32
+)
+
+// keystoreFile 密钥库文件格式，每个签名私钥以 PKCS#8 编码、数据密钥以原始字节
+// 用 AES-256-GCM 单独加密，密钥 ID 作为附加数据
+type keystoreFile struct {
+	Version    int                       `json:"version"`
+	KDF        string                    `json:"kdf"`
+	Iterations int                       `json:"iterations"`
+	Salt       []byte                    `json:"salt"`
+	Keys       map[string]*keystoreEntry `json:"keys"`
+}
+
+type keystoreEntry struct {
+	Type       KeyType   `json:"type"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// LocalKMS 本地 KMS，私钥加密保存在密钥库文件中，解密后只在进程内存中使用
+type LocalKMS struct {
+	path string
+	aead cipher.AEAD
+	file *keystoreFile
+
+	keys     map[string]crypto.Signer
+	dataKeys map[string][]byte
+	mutex    sync.RWMutex
+}
+
+// NewLocalKMS 打开或创建加密密钥库。path 为空时不写文件，密钥在进程退出后丢失
+func NewLocalKMS(path, passphrase string) (*LocalKMS, error) {
+	k := &LocalKMS{
+		path:     path,
+		keys:     make(map[string]crypto.Signer),
+		dataKeys: make(map[string][]byte),
+	}
+	if path == "" {
+		return k, nil
+	}
+	if passphrase == "" {
+		return nil, errors.New("keystore passphrase is required")
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		salt := make([]byte, keystoreSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("generate keystore salt: %w", err)
+		}
+		k.file = &keystoreFile{
+			Version:    keystoreVersion,
+			KDF:        keystoreKDF,
+			Iterations: keystoreIterations,
+			Salt:       salt,
+			Keys:       make(map[string]*keystoreEntry),
+		}
+	case err != nil:
+		return nil, fmt.Errorf("read keystore: %w", err)
+	default:
+		k.file = &keystoreFile{}
+		if err := json.Unmarshal(data, k.file); err != nil {
+			return nil, fmt.Errorf("parse keystore: %w", err)
+		}
+		if k.file.Version != keystoreVersion || k.file.KDF != keystoreKDF {
+			return nil, fmt.Errorf("unsupported keystore version %d (%s)", k.file.Version, k.file.KDF)
+		}
+		if k.file.Keys == nil {
+			k.file.Keys = make(map[string]*keystoreEntry)
+		}
+	}
+
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(passphrase), k.file.Salt, k.file.Iterations, keystoreKeySize))
+	if err != nil {
+		return nil, fmt.Errorf("create keystore cipher: %w", err)
+	}
+	if k.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, fmt.Errorf("create keystore cipher: %w", err)
+	}
+
+	// 启动时解密全部私钥，口令错误时在此失败
+	for keyID, entry := range k.file.Keys {
+		plaintext, err := k.decrypt(keyID, entry)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Type == KeyTypeAES256 {
+			k.dataKeys[keyID] = plaintext
+			continue
+		}
+		key, err := parseSigningKey(keyID, entry.Type, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[keyID] = key
+	}
+	return k, nil
+}
+
+// CreateKey 生成密钥，使用密钥库时先写入文件再生效
+func (k *LocalKMS) CreateKey(keyID string, keyType KeyType) (crypto.PublicKey, error) {
+	if keyID == "" {
+		return nil, errors.New("key ID is required")
+	}
+
+	var key crypto.Signer
+	var err error
+	switch keyType {
+	case KeyTypeEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case KeyTypeECDSAP256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.exists(keyID) {
+		return nil, ErrKeyExists
+	}
+	plaintext, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encode key: %w", err)
+	}
+	if err := k.persist(keyID, keyType, plaintext); err != nil {
+		return nil, err
+	}
+	k.keys[keyID] = key
+
+	return key.Public(), nil
+}
+
+// DataKey 返回 256 位数据密钥，不存在时生成并写入密钥库
+func (k *LocalKMS) DataKey(keyID string) ([]byte, error) {
+	if keyID == "" {
+		return nil, errors.New("key ID is required")
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if key, exists := k.dataKeys[keyID]; exists {
+		return append([]byte(nil), key...), nil
+	}
+	if k.exists(keyID) {
+		return nil, fmt.Errorf("key %s is not a data key", keyID)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	if err := k.persist(keyID, KeyTypeAES256, key); err != nil {
+		return nil, err
+	}
+	k.dataKeys[keyID] = key
+
+	return append([]byte(nil), key...), nil
+}
+
+// PublicKey 返回密钥的公钥
+func (k *LocalKMS) PublicKey(keyID string) (crypto.PublicKey, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	key, exists := k.keys[keyID]
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+	return key.Public(), nil
+}
+
+// Sign 使用密钥签名
+func (k *LocalKMS) Sign(keyID string, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	k.mutex.RLock()
+	key, exists := k.keys[keyID]
+	k.mutex.RUnlock()
+
+	if !exists {
+		return nil, ErrKeyNotFound
+	}
+	return key.Sign(rand.Reader, digest, opts)
+}
+
+// exists 检查签名密钥和数据密钥是否已使用该 ID，调用方需持有锁
+func (k *LocalKMS) exists(keyID string) bool {
+	_, signing := k.keys[keyID]
+	_, data := k.dataKeys[keyID]
+	return signing || data
+}
+
+// persist 加密密钥并写入密钥库文件，未使用密钥库时不做处理。调用方需持有写锁
+func (k *LocalKMS) persist(keyID string, keyType KeyType, plaintext []byte) error {
+	if k.file == nil {
+		return nil
+	}
+
+	entry, err := k.encrypt(keyID, keyType, plaintext)
+	if err != nil {
+		return err
+	}
+	k.file.Keys[keyID] = entry
+	if err := k.save(); err != nil {
+		delete(k.file.Keys, keyID)
+		return err
+	}
+	return nil
+}
+
+func (k *LocalKMS) encrypt(keyID string, keyType KeyType, plaintext []byte) (*keystoreEntry, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	return &keystoreEntry{
+		Type:       keyType,
+		Nonce:      nonce,
+		Ciphertext: k.aead.Seal(nil, nonce, plaintext, []byte(keyID)),
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+func (k *LocalKMS) decrypt(keyID string, entry *keystoreEntry) ([]byte, error) {
+	plaintext, err := k.aead.Open(nil, entry.Nonce, entry.Ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt key %s: wrong passphrase or corrupted keystore", keyID)
+	}
+	return plaintext, nil
+}
+
+// parseSigningKey 解析 PKCS#8 编码的签名私钥并检查类型
+func parseSigningKey(keyID string, keyType KeyType, plaintext []byte) (crypto.Signer, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("parse key %s: %w", keyID, err)
+	}
+	key, ok := parsed.(crypto.Signer)
+	if !ok || keyTypeOf(key.Public()) != keyType {
+		return nil, fmt.Errorf("key %s does not match type %s", keyID, keyType)
+	}
+	return key, nil
+}
+
+// save 先写临时文件再重命名，避免写入中断损坏密钥库。调用方需持有写锁
+func (k *LocalKMS) save() error {
+	data, err := json.MarshalIndent(k.file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode keystore: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(k.path), filepath.Base(k.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("write keystore: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write keystore: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write keystore: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), k.path); err != nil {
+		return fmt.Errorf("write keystore: %w", err)
+	}
+	return nil
+}
+
+// pbkdf2SHA256 按 RFC 8018 由口令派生密钥
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	derived := make([]byte, 0, blocks*hashLen)
+	var index [4]byte
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(index[:], uint32(block))
+		prf.Write(index[:])
+		derived = prf.Sum(derived)
+
+		t := derived[len(derived)-hashLen:]
+		copy(u, t)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range u {
+				t[j] ^= u[j]
+			}
+		}
+	}
+	return derived[:keyLen]
+}
@@ -0,0 +1,127 @@
+package kms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalKMSKeystoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keys, err := NewLocalKMS(path, "secret")
+	if err != nil {
+		t.Fatalf("NewLocalKMS: %v", err)
+	}
+	publicKey, err := keys.CreateKey("issuer", KeyTypeEd25519)
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	dataKey, err := keys.DataKey("storage")
+	if err != nil {
+		t.Fatalf("DataKey: %v", err)
+	}
+	if _, err := keys.CreateKey("issuer", KeyTypeEd25519); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("duplicate key ID: %v", err)
+	}
+
+	reopened, err := NewLocalKMS(path, "secret")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	signer, err := Signer(reopened, "issuer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign(nil, []byte("message"), crypto.Hash(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(publicKey.(ed25519.PublicKey), []byte("message"), signature) {
+		t.Error("key changed across restart")
+	}
+	if again, _ := reopened.DataKey("storage"); hex.EncodeToString(again) != hex.EncodeToString(dataKey) {
+		t.Error("data key changed across restart")
+	}
+
+	if _, err := NewLocalKMS(path, "wrong"); err == nil {
+		t.Error("keystore opened with the wrong passphrase")
+	}
+}
+
+func TestEnsureKey(t *testing.T) {
+	keys, err := NewLocalKMS("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := EnsureKey(keys, "es256", KeyTypeECDSAP256)
+	if err != nil {
+		t.Fatalf("EnsureKey: %v", err)
+	}
+	existing, err := EnsureKey(keys, "es256", KeyTypeECDSAP256)
+	if err != nil || !existing.(*ecdsa.PublicKey).Equal(created) {
+		t.Fatalf("EnsureKey did not return the existing key: %v", err)
+	}
+	if _, err := EnsureKey(keys, "es256", KeyTypeEd25519); err == nil {
+		t.Error("EnsureKey accepted a key of another type")
+	}
+
+	digest := sha256.Sum256([]byte("message"))
+	signature, err := keys.Sign("es256", digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.VerifyASN1(created.(*ecdsa.PublicKey), digest[:], signature) {
+		t.Error("ES256 signature does not verify")
+	}
+	if _, err := keys.Sign("missing", digest[:], crypto.SHA256); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("signing with a missing key: %v", err)
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	tests := []struct {
+		iterations int
+		want       string
+	}{
+		{1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(pbkdf2SHA256([]byte("password"), []byte("salt"), tt.iterations, 32)); got != tt.want {
+			t.Errorf("%d iterations: %s, want %s", tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestOpenRequiresKeystoreForLocalBackend(t *testing.T) {
+	config := DefaultConfig()
+	if _, err := Open(config); err == nil {
+		t.Error("local KMS opened without a keystore")
+	}
+
+	config.Ephemeral = true
+	if _, err := Open(config); err != nil {
+		t.Errorf("ephemeral local KMS: %v", err)
+	}
+
+	config.KeystorePath = filepath.Join(t.TempDir(), "keys.json")
+	config.Passphrase = "passphrase"
+	if _, err := Open(config); err == nil {
+		t.Error("local KMS opened with both a keystore and in-memory keys")
+	}
+
+	config.Ephemeral = false
+	if _, err := Open(config); err != nil {
+		t.Errorf("local KMS with a keystore: %v", err)
+	}
+
+	config.Backend = "awskms"
+	if _, err := Open(config); err == nil {
+		t.Error("opened an unsupported backend")
+	}
+}
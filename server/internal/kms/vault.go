@@ -0,0 +1,211 @@
+package kms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VaultConfig HashiCorp Vault transit 引擎配置
+type VaultConfig struct {
+	Addr    string        // Vault 地址，如 https://vault.example.com:8200
+	Token   string        // 具有 transit 密钥创建、读取和签名权限的令牌
+	Mount   string        // transit 引擎的挂载路径
+	Timeout time.Duration // 单次请求超时
+}
+
+// DefaultVaultConfig 返回默认 Vault 配置
+func DefaultVaultConfig() VaultConfig {
+	return VaultConfig{
+		Addr:    "http://127.0.0.1:8200",
+		Mount:   "transit",
+		Timeout: 10 * time.Second,
+	}
+}
+
+// VaultKMS 使用 Vault transit 引擎的 KMS，密钥以不可导出方式在 Vault 中创建，签名由 Vault 完成
+type VaultKMS struct {
+	config VaultConfig
+	base   string
+	client *http.Client
+}
+
+// NewVaultKMS 创建 Vault KMS
+func NewVaultKMS(config VaultConfig) (*VaultKMS, error) {
+	parsed, err := url.Parse(config.Addr)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid Vault address: %s", config.Addr)
+	}
+	if config.Token == "" {
+		return nil, errors.New("Vault token is required")
+	}
+	if config.Mount == "" {
+		config.Mount = "transit"
+	}
+
+	return &VaultKMS{
+		config: config,
+		base:   strings.TrimSuffix(config.Addr, "/") + "/v1/" + strings.Trim(config.Mount, "/"),
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// vaultKeyTypes 密钥类型与 transit 密钥类型的对应关系
+var vaultKeyTypes = map[KeyType]string{
+	KeyTypeEd25519:   "ed25519",
+	KeyTypeECDSAP256: "ecdsa-p256",
+}
+
+// CreateKey 在 Vault 中创建不可导出的密钥
+func (v *VaultKMS) CreateKey(keyID string, keyType KeyType) (crypto.PublicKey, error) {
+	vaultType, ok := vaultKeyTypes[keyType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type: %s", keyType)
+	}
+
+	// transit 对已存在的密钥名重复创建不会报错，先检查以免混用类型
+	if _, err := v.PublicKey(keyID); err == nil {
+		return nil, ErrKeyExists
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+
+	body := map[string]interface{}{"type": vaultType, "exportable": false}
+	if err := v.do(http.MethodPost, "/keys/"+url.PathEscape(keyID), body, nil); err != nil {
+		return nil, fmt.Errorf("create Vault key: %w", err)
+	}
+	return v.PublicKey(keyID)
+}
+
+// PublicKey 读取密钥最新版本的公钥
+func (v *VaultKMS) PublicKey(keyID string) (crypto.PublicKey, error) {
+	var resp struct {
+		Data struct {
+			Type          string `json:"type"`
+			LatestVersion int    `json:"latest_version"`
+			Keys          map[string]struct {
+				PublicKey string `json:"public_key"`
+			} `json:"keys"`
+		} `json:"data"`
+	}
+	if err := v.do(http.MethodGet, "/keys/"+url.PathEscape(keyID), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	latest, ok := resp.Data.Keys[strconv.Itoa(resp.Data.LatestVersion)]
+	if !ok {
+		return nil, fmt.Errorf("Vault key %s has no version %d", keyID, resp.Data.LatestVersion)
+	}
+
+	switch resp.Data.Type {
+	case vaultKeyTypes[KeyTypeEd25519]:
+		publicKey, err := base64.StdEncoding.DecodeString(latest.PublicKey)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key for Vault key %s", keyID)
+		}
+		return ed25519.PublicKey(publicKey), nil
+	case vaultKeyTypes[KeyTypeECDSAP256]:
+		block, _ := pem.Decode([]byte(latest.PublicKey))
+		if block == nil {
+			return nil, fmt.Errorf("invalid P-256 public key for Vault key %s", keyID)
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key for Vault key %s: %w", keyID, err)
+		}
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("Vault key %s has unsupported type %s", keyID, resp.Data.Type)
+	}
+}
+
+// Sign 由 Vault 签名。ECDSA 摘要以 prehashed 方式提交，签名为 ASN.1 DER 编码
+func (v *VaultKMS) Sign(keyID string, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	body := map[string]interface{}{"input": base64.StdEncoding.EncodeToString(digest)}
+	switch opts.HashFunc() {
+	case crypto.Hash(0):
+	case crypto.SHA256:
+		body["prehashed"] = true
+		body["hash_algorithm"] = "sha2-256"
+		body["marshaling_algorithm"] = "asn1"
+	default:
+		return nil, fmt.Errorf("unsupported hash: %v", opts.HashFunc())
+	}
+
+	var resp struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}
+	if err := v.do(http.MethodPost, "/sign/"+url.PathEscape(keyID), body, &resp); err != nil {
+		return nil, fmt.Errorf("sign with Vault key: %w", err)
+	}
+
+	// 签名格式为 vault:v<版本>:<base64>
+	parts := strings.SplitN(resp.Data.Signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected Vault signature format")
+	}
+	signature, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode Vault signature: %w", err)
+	}
+	return signature, nil
+}
+
+// do 发送请求并解码 data 响应，404 返回 ErrKeyNotFound
+func (v *VaultKMS) do(method, path string, body, out interface{}) error {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, v.base+path, reader)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.config.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrKeyNotFound
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&vaultErr)
+		return fmt.Errorf("Vault returned %d: %s", resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode Vault response: %w", err)
+	}
+	return nil
+}
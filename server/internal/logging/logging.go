@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// 日志输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// 日志中通用的字段名，便于在日志聚合系统中按字段检索
+const (
+	KeyRequestID    = "request_id"
+	KeyConnectionID = "conn_id"
+	KeyPlayerDID    = "player_did"
+	KeyPlayerID     = "player_id"
+	KeyRoomID       = "room_id"
+	KeyError        = "error"
+)
+
+// Config 日志配置
+type Config struct {
+	// Level 最低输出级别：debug、info、warn 或 error
+	Level string
+	// Format 输出格式：text 或 json
+	Format string
+}
+
+// DefaultConfig 默认配置：info 级别，文本格式
+func DefaultConfig() Config {
+	return Config{
+		Level:  "info",
+		Format: FormatText,
+	}
+}
+
+// New 按配置创建写入 w 的日志记录器
+func New(config Config, w io.Writer) (*slog.Logger, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(config.Format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format: %s", config.Format)
+	}
+}
+
+// Setup 创建日志记录器并设为默认，标准库 log 包的输出也会经由它以 info 级别写出
+func Setup(config Config, w io.Writer) error {
+	logger, err := New(config, w)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// ParseLevel 解析日志级别名称
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level: %s", name)
+	}
+	return level, nil
+}
+
+// Err 返回错误字段
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// NewID 生成用于关联日志的随机 ID
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+type loggerKey struct{}
+
+// WithLogger 返回携带日志记录器的上下文
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext 返回上下文中的日志记录器，没有时返回默认记录器
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
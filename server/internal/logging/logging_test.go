@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewHonorsLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Config{Level: "warn", Format: FormatJSON}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("dropped")
+	logger.Warn("kept", KeyRoomID, "lobby", Err(errors.New("boom")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want only the warning: %q", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if record["msg"] != "kept" || record[KeyRoomID] != "lobby" || record[KeyError] != "boom" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	if _, err := New(Config{Level: "verbose"}, &bytes.Buffer{}); err == nil {
+		t.Error("invalid level accepted")
+	}
+	if _, err := New(Config{Format: "xml"}, &bytes.Buffer{}); err == nil {
+		t.Error("invalid format accepted")
+	}
+	if level, err := ParseLevel(""); err != nil || level != slog.LevelInfo {
+		t.Errorf("ParseLevel(\"\") = %v, %v, want info", level, err)
+	}
+}
+
+// captureDefault 将默认日志记录器替换为写入缓冲区的 debug 级别 JSON 记录器，测试结束后恢复
+func captureDefault(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var buf bytes.Buffer
+	if err := Setup(Config{Level: "debug", Format: FormatJSON}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestMiddlewareAssignsRequestID(t *testing.T) {
+	buf := captureDefault(t)
+
+	var contextLogger *slog.Logger
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contextLogger = FromContext(r.Context())
+		contextLogger.Info("handled")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/rooms", nil))
+
+	requestID := recorder.Header().Get(RequestIDHeader)
+	if len(requestID) != 16 {
+		t.Fatalf("generated request ID %q", requestID)
+	}
+	if contextLogger == nil || contextLogger == slog.Default() {
+		t.Fatal("handler did not get a request-scoped logger")
+	}
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want the handler log and the access log", len(records))
+	}
+	for _, record := range records {
+		if record[KeyRequestID] != requestID {
+			t.Errorf("record %v lacks request ID %s", record, requestID)
+		}
+	}
+	if access := records[1]; access["status"] != float64(http.StatusTeapot) || access["path"] != "/api/rooms" {
+		t.Errorf("unexpected access log: %v", access)
+	}
+}
+
+func TestMiddlewareRequestIDFromClient(t *testing.T) {
+	captureDefault(t)
+	handler := Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	tests := []struct {
+		name  string
+		id    string
+		reuse bool
+	}{
+		{"printable", "client-req-42", true},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"control characters", "id\x00with-nul", false},
+		{"spaces", "two words", false},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set(RequestIDHeader, tt.id)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		got := recorder.Header().Get(RequestIDHeader)
+		if reused := got == tt.id; reused != tt.reuse {
+			t.Errorf("%s: response request ID %q, reuse = %v, want %v", tt.name, got, reused, tt.reuse)
+		}
+		if got == "" {
+			t.Errorf("%s: no request ID assigned", tt.name)
+		}
+	}
+}
+
+func TestFromContextDefaultsToDefaultLogger(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if FromContext(request.Context()) != slog.Default() {
+		t.Error("FromContext without a logger did not return the default logger")
+	}
+}
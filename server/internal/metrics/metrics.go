@@ -0,0 +1,319 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets 延迟直方图的默认桶上界（秒）
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// 指标类型，对应 Prometheus 文本格式中的 TYPE
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// collector 一个指标族，按标签值组合保存各个序列
+type collector interface {
+	name() string
+	write(b *strings.Builder)
+}
+
+// family 指标族的公共部分：名称、说明、标签名和按标签值索引的序列
+type family struct {
+	metricName string
+	help       string
+	metricType string
+	labelNames []string
+
+	series map[string]*series
+	mutex  sync.RWMutex
+}
+
+// series 一组标签值对应的序列
+type series struct {
+	labelValues []string
+
+	mutex   sync.Mutex
+	value   float64
+	buckets []uint64 // 直方图各桶的计数（非累计）
+	sum     float64
+	count   uint64
+}
+
+func newFamily(name, help, metricType string, labelNames []string) *family {
+	return &family{
+		metricName: name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		series:     make(map[string]*series),
+	}
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// with 返回标签值对应的序列，不存在时创建；标签值个数必须与标签名一致
+func (f *family) with(labelValues []string, buckets int) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.metricName, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mutex.RLock()
+	s, ok := f.series[key]
+	f.mutex.RUnlock()
+	if ok {
+		return s
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if s, ok = f.series[key]; !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if buckets > 0 {
+			s.buckets = make([]uint64, buckets)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sortedSeries 按标签值排序的序列，使输出稳定
+func (f *family) sortedSeries() []*series {
+	f.mutex.RLock()
+	list := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		list = append(list, s)
+	}
+	f.mutex.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(list[i].labelValues, "\xff") < strings.Join(list[j].labelValues, "\xff")
+	})
+	return list
+}
+
+func (f *family) writeHeader(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.metricName, f.metricType)
+}
+
+// labels 格式化标签，extra 为额外的标签（如直方图的 le）
+func (f *family) labels(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(values)+len(extra)/2)
+	for i, value := range values {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, f.labelNames[i], escapeLabel(value)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extra[i], extra[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// CounterVec 按标签区分的计数器
+type CounterVec struct {
+	*family
+}
+
+// Counter 单个计数器序列
+type Counter struct {
+	s *series
+}
+
+// NewCounterVec 创建并在默认注册表中注册计数器
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{newFamily(name, help, typeCounter, labelNames)}
+	Default.MustRegister(c)
+	return c
+}
+
+// With 返回标签值对应的计数器
+func (c *CounterVec) With(labelValues ...string) Counter {
+	return Counter{c.with(labelValues, 0)}
+}
+
+// Inc 计数加一
+func (c Counter) Inc() {
+	c.Add(1)
+}
+
+// Add 增加计数，计数器只能增加
+func (c Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.s.mutex.Lock()
+	c.s.value += delta
+	c.s.mutex.Unlock()
+}
+
+func (c *CounterVec) write(b *strings.Builder) {
+	c.writeHeader(b)
+	for _, s := range c.sortedSeries() {
+		s.mutex.Lock()
+		value := s.value
+		s.mutex.Unlock()
+		fmt.Fprintf(b, "%s%s %s\n", c.metricName, c.labels(s.labelValues), formatValue(value))
+	}
+}
+
+// GaugeVec 按标签区分的仪表
+type GaugeVec struct {
+	*family
+}
+
+// Gauge 单个仪表序列
+type Gauge struct {
+	s *series
+}
+
+// NewGaugeVec 创建并在默认注册表中注册仪表
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{newFamily(name, help, typeGauge, labelNames)}
+	Default.MustRegister(g)
+	return g
+}
+
+// NewGauge 创建并注册没有标签的仪表
+func NewGauge(name, help string) Gauge {
+	return NewGaugeVec(name, help).With()
+}
+
+// With 返回标签值对应的仪表
+func (g *GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{g.with(labelValues, 0)}
+}
+
+// Set 设置当前值
+func (g Gauge) Set(value float64) {
+	g.s.mutex.Lock()
+	g.s.value = value
+	g.s.mutex.Unlock()
+}
+
+// Add 增加当前值，delta 可以为负
+func (g Gauge) Add(delta float64) {
+	g.s.mutex.Lock()
+	g.s.value += delta
+	g.s.mutex.Unlock()
+}
+
+// Inc 当前值加一
+func (g Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec 当前值减一
+func (g Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *GaugeVec) write(b *strings.Builder) {
+	g.writeHeader(b)
+	for _, s := range g.sortedSeries() {
+		s.mutex.Lock()
+		value := s.value
+		s.mutex.Unlock()
+		fmt.Fprintf(b, "%s%s %s\n", g.metricName, g.labels(s.labelValues), formatValue(value))
+	}
+}
+
+// HistogramVec 按标签区分的直方图
+type HistogramVec struct {
+	*family
+	upperBounds []float64
+}
+
+// Histogram 单个直方图序列
+type Histogram struct {
+	s           *series
+	upperBounds []float64
+}
+
+// NewHistogramVec 创建并在默认注册表中注册直方图，buckets 为空时使用 DefaultBuckets
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	upperBounds := append([]float64(nil), buckets...)
+	sort.Float64s(upperBounds)
+
+	h := &HistogramVec{family: newFamily(name, help, typeHistogram, labelNames), upperBounds: upperBounds}
+	Default.MustRegister(h)
+	return h
+}
+
+// With 返回标签值对应的直方图
+func (h *HistogramVec) With(labelValues ...string) Histogram {
+	return Histogram{s: h.with(labelValues, len(h.upperBounds)), upperBounds: h.upperBounds}
+}
+
+// Observe 记录一个观测值
+func (h Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.upperBounds, value)
+
+	h.s.mutex.Lock()
+	if i < len(h.s.buckets) {
+		h.s.buckets[i]++
+	}
+	h.s.sum += value
+	h.s.count++
+	h.s.mutex.Unlock()
+}
+
+// ObserveSince 记录从 start 到现在经过的秒数
+func (h Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+func (h *HistogramVec) write(b *strings.Builder) {
+	h.writeHeader(b)
+	for _, s := range h.sortedSeries() {
+		s.mutex.Lock()
+		buckets := append([]uint64(nil), s.buckets...)
+		sum, count := s.sum, s.count
+		s.mutex.Unlock()
+
+		var cumulative uint64
+		for i, upperBound := range h.upperBounds {
+			cumulative += buckets[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.metricName, h.labels(s.labelValues, "le", formatValue(upperBound)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.metricName, h.labels(s.labelValues, "le", "+Inf"), count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.metricName, h.labels(s.labelValues), formatValue(sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.metricName, h.labels(s.labelValues), count)
+	}
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// escapeLabel 按文本格式转义标签值中的反斜杠、双引号和换行
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
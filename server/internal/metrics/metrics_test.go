@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// output 返回单个指标族的文本格式输出
+func output(c collector) string {
+	var b strings.Builder
+	c.write(&b)
+	return b.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	counter := NewCounterVec("test_messages_total", "Messages handled.", "type", "result")
+	counter.With("move", "ok").Inc()
+	counter.With("move", "ok").Add(2)
+	counter.With("chat", "error").Inc()
+	// 计数器不能减少
+	counter.With("chat", "error").Add(-5)
+
+	want := `# HELP test_messages_total Messages handled.
+# TYPE test_messages_total counter
+test_messages_total{type="chat",result="error"} 1
+test_messages_total{type="move",result="ok"} 3
+`
+	if got := output(counter); got != want {
+		t.Errorf("counter output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	gauge := NewGauge("test_connections", "Open connections.")
+	gauge.Set(5)
+	gauge.Inc()
+	gauge.Dec()
+	gauge.Add(-2.5)
+
+	want := "# HELP test_connections Open connections.\n# TYPE test_connections gauge\ntest_connections 2.5\n"
+	if got := output(Default.collectors["test_connections"]); got != want {
+		t.Errorf("gauge output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramBucketsAreCumulative(t *testing.T) {
+	histogram := NewHistogramVec("test_latency_seconds", "Latency.", []float64{1, 0.1}, "op")
+	for _, value := range []float64{0.05, 0.1, 0.5, 3} {
+		histogram.With("get").Observe(value)
+	}
+
+	// 桶上界排序，观测值等于上界时计入该桶
+	want := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{op="get",le="0.1"} 2
+test_latency_seconds_bucket{op="get",le="1"} 3
+test_latency_seconds_bucket{op="get",le="+Inf"} 4
+test_latency_seconds_sum{op="get"} 3.65
+test_latency_seconds_count{op="get"} 4
+`
+	if got := output(histogram); got != want {
+		t.Errorf("histogram output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLabelAndHelpEscaping(t *testing.T) {
+	counter := NewCounterVec("test_escaped_total", "Line one\nback\\slash", "path")
+	counter.With("a\"b\\c\nd").Inc()
+
+	got := output(counter)
+	if !strings.Contains(got, `# HELP test_escaped_total Line one\nback\\slash`) {
+		t.Errorf("help not escaped:\n%s", got)
+	}
+	if !strings.Contains(got, `test_escaped_total{path="a\"b\\c\nd"} 1`) {
+		t.Errorf("label value not escaped:\n%s", got)
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	counter := NewCounterVec("test_labels_total", "Labels.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("With accepted the wrong number of label values")
+		}
+	}()
+	counter.With("only-one")
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	first := &CounterVec{newFamily("b_total", "B.", typeCounter, nil)}
+	second := &GaugeVec{newFamily("a_value", "A.", typeGauge, nil)}
+	registry.MustRegister(first)
+	registry.MustRegister(second)
+	first.With().Inc()
+	second.With().Set(7)
+
+	if err := registry.Register(&CounterVec{newFamily("b_total", "Again.", typeCounter, nil)}); err == nil {
+		t.Error("duplicate metric registered")
+	}
+
+	// 按名称排序输出
+	gathered := registry.Gather()
+	if strings.Index(gathered, "a_value 7") > strings.Index(gathered, "b_total 1") || !strings.Contains(gathered, "b_total 1") {
+		t.Errorf("unexpected output:\n%s", gathered)
+	}
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != gathered {
+		t.Errorf("GET /metrics = %d %q", recorder.Code, recorder.Body.String())
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %s", recorder.Header().Get("Content-Type"))
+	}
+
+	recorder = httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /metrics = %d", recorder.Code)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	counter := NewCounterVec("test_concurrent_total", "Concurrent.", "worker")
+	histogram := NewHistogramVec("test_concurrent_seconds", "Concurrent.", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter.With("shared").Inc()
+				histogram.With().Observe(0.002)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !strings.Contains(output(counter), `test_concurrent_total{worker="shared"} 8000`) {
+		t.Errorf("lost counter updates:\n%s", output(counter))
+	}
+	if !strings.Contains(output(histogram), "test_concurrent_seconds_count 8000") {
+		t.Errorf("lost histogram updates:\n%s", output(histogram))
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Default 默认注册表，各包的指标在初始化时注册到这里
+var Default = NewRegistry()
+
+// Registry 指标注册表
+type Registry struct {
+	collectors map[string]collector
+	mutex      sync.RWMutex
+}
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// Register 注册指标，名称重复时返回错误
+func (r *Registry) Register(c collector) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.collectors[c.name()]; exists {
+		return fmt.Errorf("duplicate metric: %s", c.name())
+	}
+	r.collectors[c.name()] = c
+	return nil
+}
+
+// MustRegister 注册指标，名称重复时 panic
+func (r *Registry) MustRegister(c collector) {
+	if err := r.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// Gather 按名称顺序以 Prometheus 文本格式输出所有指标
+func (r *Registry) Gather() string {
+	r.mutex.RLock()
+	list := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		list = append(list, c)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name() < list[j].name()
+	})
+
+	var b strings.Builder
+	for _, c := range list {
+		c.write(&b)
+	}
+	return b.String()
+}
+
+// ServeHTTP 实现 http.Handler，供 Prometheus 抓取
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(r.Gather()))
+}
+
+// Handler 返回默认注册表的 /metrics 处理器
+func Handler() http.Handler {
+	return Default
+}
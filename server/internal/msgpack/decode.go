@@ -0,0 +1,251 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// maxDepth 数组和映射的最大嵌套层数
+const maxDepth = 64
+
+// timestampExt MessagePack 规范定义的时间戳扩展类型
+const timestampExt = -1
+
+var errTruncated = errors.New("msgpack: unexpected end of data")
+
+// Decode 将 MessagePack 解码为通用值：映射为 map[string]interface{}，数组为 []interface{}，
+// 整数为 int64 或 uint64，浮点数为 float64，二进制为 []byte，时间戳为 time.Time。
+// 映射的键必须是字符串，data 必须恰好包含一个值
+func Decode(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// length 读取 1、2 或 4 字节的长度
+func (d *decoder) length(size int) (int, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	default:
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(d.data)) {
+			return 0, errTruncated
+		}
+		return int(n), nil
+	}
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: nesting too deep")
+	}
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := b[0]; {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return d.mapping(int(c&0x0f), depth)
+	}
+
+	switch c := b[0]; c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		raw, err := d.next(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		n := readUint(raw)
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		raw, err := d.next(size)
+		if err != nil {
+			return nil, err
+		}
+		// 按位宽做符号扩展
+		shift := 64 - 8*size
+		return int64(readUint(raw)<<shift) >> shift, nil
+	case 0xca:
+		raw, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+	case 0xcb:
+		raw, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(n, depth)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+	default:
+		return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", c)
+	}
+}
+
+func (d *decoder) str(n int) (interface{}, error) {
+	raw, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (d *decoder) array(n, depth int) (interface{}, error) {
+	// 每个元素至少一个字节，先检查长度避免按伪造的长度分配内存
+	if n > len(d.data)-d.pos {
+		return nil, errTruncated
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (d *decoder) mapping(n, depth int) (interface{}, error) {
+	if 2*n > len(d.data)-d.pos {
+		return nil, errTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: map key must be a string, got %T", k)
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// ext 解码扩展类型，只支持时间戳
+func (d *decoder) ext(n int) (interface{}, error) {
+	header, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(header[0]) != timestampExt {
+		return nil, fmt.Errorf("msgpack: unsupported extension type %d", int8(header[0]))
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(raw)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(raw)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(raw[:4])
+		sec := int64(binary.BigEndian.Uint64(raw[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	default:
+		return nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+	}
+}
+
+func readUint(b []byte) uint64 {
+	var n uint64
+	for _, x := range b {
+		n = n<<8 | uint64(x)
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package msgpack
+
+import (
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalEncodings(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{5, "05"},
+		{-1, "ff"},
+		{-33, "d0df"},
+		{200, "ccc8"},
+		{300, "cd012c"},
+		{-40000, "d2ffff63c0"},
+		{uint64(math.MaxUint64), "cfffffffffffffffff"},
+		{1.5, "cb3ff8000000000000"},
+		{"hi", "a26869"},
+		{[]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{map[string]int{"a": 1}, "81a16101"},
+	}
+	for _, tt := range tests {
+		data, err := Marshal(tt.value)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", tt.value, err)
+		}
+		if got := hex.EncodeToString(data); got != tt.want {
+			t.Errorf("Marshal(%#v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestStructUsesJSONTags(t *testing.T) {
+	type message struct {
+		Type    string    `json:"type"`
+		Skipped string    `json:"skipped,omitempty"`
+		Hidden  string    `json:"-"`
+		At      time.Time `json:"at"`
+		Score   int       `json:"score"`
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	data, err := Marshal(message{Type: "move", Hidden: "secret", At: at, Score: -7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := map[string]interface{}{"type": "move", "at": "2024-05-01T12:00:00Z", "score": int64(-7)}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("decoded %#v, want %#v", decoded, want)
+	}
+}
+
+func TestDecodeRejectsMalformedInput(t *testing.T) {
+	tests := map[string]string{
+		"truncated string":   "a3616263"[:6],
+		"truncated map":      "82a161",
+		"trailing bytes":     "0101",
+		"invalid type":       "c1",
+		"non-string map key": "810101",
+		"huge length":        "dbffffffff",
+		"empty":              "",
+	}
+	for name, input := range tests {
+		data, _ := hex.DecodeString(input)
+		if _, err := Decode(data); err == nil {
+			t.Errorf("%s: %s decoded", name, input)
+		}
+	}
+
+	deep := strings.Repeat("91", maxDepth+2) + "c0"
+	data, _ := hex.DecodeString(deep)
+	if _, err := Decode(data); err == nil {
+		t.Error("deeply nested input decoded")
+	}
+}
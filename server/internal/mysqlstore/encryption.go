@@ -0,0 +1,249 @@
+package mysqlstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// envelopeMagic 加密值的前缀。存储的值是 JSON，不会以 NUL 字节开头，启用加密之前写入的值原样读出
+var envelopeMagic = []byte{0x00, 'E', 'N', 'C'}
+
+// envelopeVersion 加密值的格式：magic | 版本 | 密钥 ID 长度 | 密钥 ID | nonce | 密文
+const envelopeVersion = 1
+
+// reencryptPageSize Reencrypt 每次查询读取的条目数
+const reencryptPageSize = 500
+
+// ErrUnknownKey 加密值所用的密钥不在密钥环中
+var ErrUnknownKey = errors.New("value is encrypted with a key that is not in the keyring")
+
+// Keyring 静态加密值所用的 AES-256-GCM 密钥环
+type Keyring struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+// NewKeyring 以密钥 ID 索引的 32 字节密钥创建密钥环。新值以活动密钥加密，密钥环中任一密钥加密的值都能读取，
+// 轮换密钥时加入新的活动密钥，并保留旧密钥直到 Reencrypt 执行完毕
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	if _, exists := keys[active]; !exists {
+		return nil, fmt.Errorf("active key %q is not in the keyring", active)
+	}
+
+	k := &Keyring{active: active, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("invalid key ID %q: must be 1 to 255 bytes", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key %s: %w", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// ActiveKeyID 返回加密新值所用密钥的 ID
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// seal 以活动密钥加密 value，aad 将密文绑定到其存储和键，使值不能在行之间调换
+func (k *Keyring) seal(aad, value []byte) ([]byte, error) {
+	aead := k.aeads[k.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	envelope := make([]byte, 0, len(envelopeMagic)+2+len(k.active)+len(nonce)+len(value)+aead.Overhead())
+	envelope = append(envelope, envelopeMagic...)
+	envelope = append(envelope, envelopeVersion, byte(len(k.active)))
+	envelope = append(envelope, k.active...)
+	envelope = append(envelope, nonce...)
+	return aead.Seal(envelope, nonce, value, aad), nil
+}
+
+// open 解密加密值，没有前缀的值原样返回
+func (k *Keyring) open(aad, value []byte) ([]byte, error) {
+	keyID, rest, ok, err := parseEnvelope(value)
+	if err != nil || !ok {
+		return value, err
+	}
+
+	aead, exists := k.aeads[keyID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("encrypted value is truncated")
+	}
+
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value with key %s: %w", keyID, err)
+	}
+	return plaintext, nil
+}
+
+// parseEnvelope 返回加密值的密钥 ID 以及 nonce 和密文，明文值的 ok 为 false
+func parseEnvelope(value []byte) (string, []byte, bool, error) {
+	if !bytes.HasPrefix(value, envelopeMagic) {
+		return "", nil, false, nil
+	}
+
+	header := value[len(envelopeMagic):]
+	if len(header) < 2 {
+		return "", nil, false, errors.New("encrypted value is truncated")
+	}
+	if header[0] != envelopeVersion {
+		return "", nil, false, fmt.Errorf("unsupported encryption envelope version %d", header[0])
+	}
+	idLength := int(header[1])
+	if len(header) < 2+idLength {
+		return "", nil, false, errors.New("encrypted value is truncated")
+	}
+	return string(header[2 : 2+idLength]), header[2+idLength:], true, nil
+}
+
+// WithEncryption 以 keyring 静态加密值，键和标签用于查询匹配，仍以明文存储
+func WithEncryption(keyring *Keyring) Option {
+	return func(p *Provider) {
+		p.keyring = keyring
+	}
+}
+
+// entryAAD 条目值的附加认证数据
+func entryAAD(storeName, key string) []byte {
+	return []byte(storeName + "\x00" + key)
+}
+
+// seal 在写入前加密值，没有密钥环时原样存储
+func (s *store) seal(key string, value []byte) ([]byte, error) {
+	if s.keyring == nil {
+		return value, nil
+	}
+	return s.keyring.seal(entryAAD(s.name, key), value)
+}
+
+// open 在读取后解密值
+func (s *store) open(key string, value []byte) ([]byte, error) {
+	if s.keyring == nil || value == nil {
+		return value, nil
+	}
+	return s.keyring.open(entryAAD(s.name, key), value)
+}
+
+// Reencrypt 重写所有以明文存储或以非活动密钥加密的值，返回重写的数量，完成后即可从密钥环中移除旧密钥。
+// 期间被并发修改的值保持修改后的内容
+func (p *Provider) Reencrypt() (int, error) {
+	return p.ReencryptContext(context.Background())
+}
+
+// ReencryptContext 与 Reencrypt 相同，但在 ctx 被取消或超过截止时间时停止，已重写的值不会回退，查询超时作用于每条语句
+func (p *Provider) ReencryptContext(ctx context.Context) (int, error) {
+	defer observeQuery(ctx, "reencrypt")()
+
+	if p.keyring == nil {
+		return 0, errors.New("encryption is not enabled")
+	}
+
+	var rewritten int
+	var lastStore, lastKey string
+	for {
+		page, err := p.readEntries(ctx, lastStore, lastKey)
+		if err != nil {
+			return rewritten, err
+		}
+
+		for _, r := range page {
+			keyID, _, encrypted, err := parseEnvelope(r.value)
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to read %s/%s: %w", r.storeName, r.key, err)
+			}
+			if encrypted && keyID == p.keyring.active {
+				continue
+			}
+
+			aad := entryAAD(r.storeName, r.key)
+			plaintext, err := p.keyring.open(aad, r.value)
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to decrypt %s/%s: %w", r.storeName, r.key, err)
+			}
+			sealed, err := p.keyring.seal(aad, plaintext)
+			if err != nil {
+				return rewritten, err
+			}
+
+			updated, err := p.rewriteEntry(ctx, r, sealed)
+			if err != nil {
+				return rewritten, fmt.Errorf("failed to rewrite %s/%s: %w", r.storeName, r.key, err)
+			}
+			if updated {
+				rewritten++
+			}
+		}
+
+		if len(page) < reencryptPageSize {
+			return rewritten, nil
+		}
+		lastStore, lastKey = page[len(page)-1].storeName, page[len(page)-1].key
+	}
+}
+
+// rawEntry Reencrypt 读取的未解密的存储值
+type rawEntry struct {
+	storeName, key string
+	value          []byte
+}
+
+// readEntries 读取 (lastStore, lastKey) 之后的下一页条目
+func (p *Provider) readEntries(ctx context.Context, lastStore, lastKey string) ([]rawEntry, error) {
+	ctx, cancel := p.bound(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, "SELECT `store_name`, `entry_key`, `value` FROM "+p.tables.entries+
+		" WHERE (`store_name`, `entry_key`) > (?, ?) ORDER BY `store_name`, `entry_key` LIMIT ?",
+		lastStore, lastKey, reencryptPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries: %w", err)
+	}
+	defer rows.Close()
+
+	var page []rawEntry
+	for rows.Next() {
+		var r rawEntry
+		if err := rows.Scan(&r.storeName, &r.key, &r.value); err != nil {
+			return nil, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		page = append(page, r)
+	}
+	return page, rows.Err()
+}
+
+// rewriteEntry 以 sealed 替换 r 的值，读取后已被修改的值不替换
+func (p *Provider) rewriteEntry(ctx context.Context, r rawEntry, sealed []byte) (bool, error) {
+	ctx, cancel := p.bound(ctx)
+	defer cancel()
+
+	result, err := p.db.ExecContext(ctx, "UPDATE "+p.tables.entries+" SET `value` = ? WHERE `store_name` = ? AND `entry_key` = ? AND `value` = ?",
+		sealed, r.storeName, r.key, r.value)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
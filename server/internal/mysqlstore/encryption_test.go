@@ -0,0 +1,117 @@
+package mysqlstore
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestNewKeyringValidatesKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		active string
+		keys   map[string][]byte
+	}{
+		{"active key missing", "k2", map[string][]byte{"k1": testKey(1)}},
+		{"short key", "k1", map[string][]byte{"k1": testKey(1)[:16]}},
+		{"empty key ID", "", map[string][]byte{"": testKey(1)}},
+	}
+	for _, tt := range tests {
+		if _, err := NewKeyring(tt.active, tt.keys); err == nil {
+			t.Errorf("%s: NewKeyring succeeded", tt.name)
+		}
+	}
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := entryAAD("players", "alice")
+	plaintext := []byte(`{"level":7}`)
+
+	sealed, err := keyring.seal(aad, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("sealed value contains the plaintext")
+	}
+	keyID, _, ok, err := parseEnvelope(sealed)
+	if err != nil || !ok || keyID != "k1" {
+		t.Errorf("parseEnvelope = %q, %v, %v; want k1", keyID, ok, err)
+	}
+
+	opened, err := keyring.open(aad, sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("open = %s, %v", opened, err)
+	}
+
+	// 密文绑定到存储和键，换到另一行后无法解密
+	if _, err := keyring.open(entryAAD("players", "bob"), sealed); err == nil {
+		t.Error("value opened under another key")
+	}
+	if _, err := keyring.open(entryAAD("scores", "alice"), sealed); err == nil {
+		t.Error("value opened in another store")
+	}
+
+	// 启用加密之前写入的明文值原样读出
+	if opened, err := keyring.open(aad, plaintext); err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("plaintext value read as %s, %v", opened, err)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := entryAAD("players", "alice")
+	sealed, err := old.seal(aad, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后旧密钥加密的值仍可读取，新值以活动密钥加密
+	rotated, err := NewKeyring("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotated.open(aad, sealed); err != nil {
+		t.Errorf("value sealed with the previous key: %v", err)
+	}
+	resealed, err := rotated.seal(aad, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID, _, _, _ := parseEnvelope(resealed); keyID != rotated.ActiveKeyID() {
+		t.Errorf("sealed with %q, want %q", keyID, rotated.ActiveKeyID())
+	}
+
+	// 移除旧密钥后，仍以旧密钥加密的值报告 ErrUnknownKey
+	current, err := NewKeyring("k2", map[string][]byte{"k2": testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := current.open(aad, sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("open with removed key: %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestParseEnvelopeRejectsMalformedValues(t *testing.T) {
+	tests := map[string][]byte{
+		"missing header":      append([]byte(nil), envelopeMagic...),
+		"unsupported version": append(append([]byte(nil), envelopeMagic...), 9, 0),
+		"truncated key ID":    append(append([]byte(nil), envelopeMagic...), envelopeVersion, 4, 'k'),
+	}
+	for name, value := range tests {
+		if _, _, _, err := parseEnvelope(value); err == nil {
+			t.Errorf("%s: parseEnvelope succeeded", name)
+		}
+	}
+}
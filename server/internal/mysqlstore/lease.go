@@ -0,0 +1,59 @@
+package mysqlstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AcquireLease 租约空闲、已过期或已由 holder 持有时，使 holder 持有指定租约 ttl 时长，并返回 holder 是否持有租约。
+// 租约用于在共享数据库的多个进程中选出一个，例如唯一写入共享状态的进程，持有者须在 ttl 内及时续约。
+// 过期时间以数据库时钟判断，进程之间的时钟偏差不影响结果
+func (p *Provider) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	if name == "" || holder == "" {
+		return false, errors.New("lease name and holder are required")
+	}
+	if len(name) > maxNameLength || len(holder) > maxNameLength {
+		return false, fmt.Errorf("lease name and holder cannot exceed %d characters", maxNameLength)
+	}
+	if ttl <= 0 {
+		return false, errors.New("lease TTL must be positive")
+	}
+
+	ctx, cancel := p.bound(ctx)
+	defer cancel()
+	defer observeQuery(ctx, "acquire_lease")()
+
+	var acquired bool
+	err := p.retry(ctx, "acquire_lease", func() error {
+		// 赋值从左到右求值，expires_at 看到的是更新后的 holder，只有 holder 刚取得或已持有租约时才延长
+		if _, err := p.db.ExecContext(ctx, "INSERT INTO "+p.tables.leases+" (`name`, `holder`, `expires_at`) "+
+			"VALUES (?, ?, NOW(6) + INTERVAL ? MICROSECOND) ON DUPLICATE KEY UPDATE "+
+			"`holder` = IF(`holder` = VALUES(`holder`) OR `expires_at` < NOW(6), VALUES(`holder`), `holder`), "+
+			"`expires_at` = IF(`holder` = VALUES(`holder`), VALUES(`expires_at`), `expires_at`)",
+			name, holder, ttl.Microseconds()); err != nil {
+			return fmt.Errorf("failed to acquire lease %s: %w", name, err)
+		}
+
+		var current string
+		if err := p.db.QueryRowContext(ctx, "SELECT `holder` FROM "+p.tables.leases+" WHERE `name` = ?", name).Scan(&current); err != nil {
+			return fmt.Errorf("failed to read lease %s: %w", name, err)
+		}
+		acquired = current == holder
+		return nil
+	})
+	return acquired, err
+}
+
+// ReleaseLease holder 持有指定租约时放弃租约，其他进程无需等待过期即可取得
+func (p *Provider) ReleaseLease(ctx context.Context, name, holder string) error {
+	ctx, cancel := p.bound(ctx)
+	defer cancel()
+	defer observeQuery(ctx, "release_lease")()
+
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM "+p.tables.leases+" WHERE `name` = ? AND `holder` = ?", name, holder); err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", name, err)
+	}
+	return nil
+}
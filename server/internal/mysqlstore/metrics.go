@@ -0,0 +1,26 @@
+package mysqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/czh0526/game/server/internal/metrics"
+	"github.com/czh0526/game/server/internal/tracing"
+)
+
+// queryDuration 按操作记录存储操作的耗时
+var queryDuration = metrics.NewHistogramVec("mysqlstore_query_duration_seconds", "Latency of MySQL store operations.", nil, "operation")
+
+// observeQuery 以 ctx 为父级为 operation 开始一个 span，返回结束 span 并记录耗时的函数，
+// 用法为 defer observeQuery(ctx, "get")()。存储接口不带 context，其操作单独追踪
+func observeQuery(ctx context.Context, operation string) func() {
+	started := time.Now()
+	_, span := tracing.StartKind(ctx, "mysqlstore."+operation, tracing.SpanKindClient,
+		tracing.String("db.system", "mysql"),
+		tracing.String("db.operation", operation),
+	)
+	return func() {
+		queryDuration.With(operation).ObserveSince(started)
+		span.End()
+	}
+}
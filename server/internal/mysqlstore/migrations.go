@@ -0,0 +1,171 @@
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// migrationLockTimeout NewProvider 等待其他进程完成迁移的最长时间
+const migrationLockTimeout = 30 * time.Second
+
+// errDuplicateColumn 之前中断的迁移已经添加了该列时 ADD COLUMN 返回的错误码，MySQL 不支持 ADD COLUMN IF NOT EXISTS
+const errDuplicateColumn = 1060
+
+// identifierPattern 命名空间只能包含在 MySQL 标识符中安全的字符
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,48}$`)
+
+// migration 一次版本化的表结构变更
+type migration struct {
+	version    int
+	name       string
+	statements func(t tables) []string
+}
+
+// migrations 按顺序列出所有表结构变更，已发布的版本不能再修改
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create entries, tags and store configs",
+		statements: func(t tables) []string {
+			return []string{
+				"CREATE TABLE IF NOT EXISTS " + t.entries + " (" +
+					"`store_name` VARCHAR(255) NOT NULL, " +
+					"`entry_key` VARCHAR(255) NOT NULL, " +
+					"`value` MEDIUMBLOB NOT NULL, " +
+					"PRIMARY KEY (`store_name`, `entry_key`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin",
+				"CREATE TABLE IF NOT EXISTS " + t.tags + " (" +
+					"`store_name` VARCHAR(255) NOT NULL, " +
+					"`entry_key` VARCHAR(255) NOT NULL, " +
+					"`tag_name` VARCHAR(255) NOT NULL, " +
+					"`tag_value` VARCHAR(255) NOT NULL DEFAULT '', " +
+					"PRIMARY KEY (`store_name`, `entry_key`, `tag_name`), " +
+					"KEY `idx_tag_lookup` (`store_name`, `tag_name`, `tag_value`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin",
+				"CREATE TABLE IF NOT EXISTS " + t.configs + " (" +
+					"`store_name` VARCHAR(255) NOT NULL, " +
+					"`config` JSON NOT NULL, " +
+					"PRIMARY KEY (`store_name`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin",
+			}
+		},
+	},
+	{
+		version: 2,
+		name:    "add entry versions and leases",
+		statements: func(t tables) []string {
+			return []string{
+				// 引入版本之前写入的行从 1 开始，版本 0 始终表示不存在
+				"ALTER TABLE " + t.entries + " ADD COLUMN `version` BIGINT UNSIGNED NOT NULL DEFAULT 1",
+				"CREATE TABLE IF NOT EXISTS " + t.leases + " (" +
+					"`name` VARCHAR(255) NOT NULL, " +
+					"`holder` VARCHAR(255) NOT NULL, " +
+					"`expires_at` DATETIME(6) NOT NULL, " +
+					"PRIMARY KEY (`name`)" +
+					") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin",
+			}
+		},
+	},
+}
+
+// tables 命名空间中所有表加引号后的表名
+type tables struct {
+	migrations string
+	entries    string
+	tags       string
+	configs    string
+	leases     string
+}
+
+func newTables(namespace string) tables {
+	return tables{
+		migrations: quoteIdentifier(namespace + "_schema_migrations"),
+		entries:    quoteIdentifier(namespace + "_entries"),
+		tags:       quoteIdentifier(namespace + "_tags"),
+		configs:    quoteIdentifier(namespace + "_store_configs"),
+		leases:     quoteIdentifier(namespace + "_leases"),
+	}
+}
+
+// quoteIdentifier 为 MySQL 标识符加反引号，并转义其中的反引号
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// validateNamespace 检查命名空间能否用作表名前缀
+func validateNamespace(namespace string) error {
+	if !identifierPattern.MatchString(namespace) {
+		return fmt.Errorf("invalid namespace %q: must be 1-48 letters, digits or underscores", namespace)
+	}
+	return nil
+}
+
+// migrate 执行所有未执行的迁移，以命名锁在进程间串行化
+func migrate(db *sql.DB, namespace string, t tables) error {
+	ctx := context.Background()
+
+	// 命名锁属于连接，整个迁移过程使用同一个连接
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	lockName := namespace + "_schema_migrations"
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(migrationLockTimeout.Seconds())).Scan(&acquired); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for migration lock %s", lockName)
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.migrations+" ("+
+		"`version` INT NOT NULL, "+
+		"`name` VARCHAR(255) NOT NULL, "+
+		"`applied_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, "+
+		"PRIMARY KEY (`version`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(`version`), 0) FROM "+t.migrations).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		// MySQL 隐式提交 DDL，语句必须是幂等的，版本号最后记录；ADD COLUMN 通过忽略列重复错误实现幂等
+		for _, statement := range m.statements(t) {
+			if _, err := conn.ExecContext(ctx, statement); err != nil && !isDuplicateColumn(err) {
+				return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+			}
+		}
+
+		if _, err := conn.ExecContext(ctx, "INSERT INTO "+t.migrations+" (`version`, `name`) VALUES (?, ?)", m.version, m.name); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+		}
+		slog.Info("Applied storage migration", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+// isDuplicateColumn err 是否由添加已存在的列引起
+func isDuplicateColumn(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateColumn
+}
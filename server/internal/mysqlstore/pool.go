@@ -0,0 +1,140 @@
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/czh0526/game/server/internal/metrics"
+)
+
+// 可以安全重试的 MySQL 错误码：语句已回滚，重新执行可能成功
+const (
+	errLockWaitTimeout = 1205
+	errLockDeadlock    = 1213
+)
+
+// retriesTotal 按操作统计因暂时性错误而重试的存储操作
+var retriesTotal = metrics.NewCounterVec("mysqlstore_retries_total", "Store operations retried after a transient MySQL error.", "operation")
+
+// PoolConfig 提供者所有存储共享的连接池大小
+type PoolConfig struct {
+	// MaxOpenConns 最大打开连接数，0 表示不限制
+	MaxOpenConns int
+	// MaxIdleConns 保留复用的最大空闲连接数，0 表示不保留
+	MaxIdleConns int
+	// ConnMaxLifetime 连接的最长存活时间，0 表示永久保留
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime 连接的最长空闲时间，0 表示永久保留
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultPoolConfig 返回适合单个游戏服务器实例的连接池配置，连接在 MySQL 默认 8 小时的 wait_timeout 之前回收
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns:    32,
+		MaxIdleConns:    16,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+	}
+}
+
+func (c PoolConfig) validate() error {
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 || c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 {
+		return errors.New("pool limits cannot be negative")
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		return fmt.Errorf("max idle connections (%d) exceed max open connections (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	return nil
+}
+
+func (c PoolConfig) apply(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+}
+
+// WithPool 设置连接池大小
+func WithPool(config PoolConfig) Option {
+	return func(p *Provider) {
+		p.pool = config
+	}
+}
+
+// RetryConfig 存储操作遇到死锁、锁等待超时、连接断开等暂时性 MySQL 错误时的重试策略，
+// 所有尝试共用同一个查询超时
+type RetryConfig struct {
+	// MaxAttempts 包括首次尝试在内的最大尝试次数，1 表示不重试
+	MaxAttempts int
+	// InitialBackoff 首次重试前的等待时间，每次尝试后加倍
+	InitialBackoff time.Duration
+	// MaxBackoff 两次尝试之间的最长等待时间
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryConfig 返回未配置时使用的重试策略
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+	}
+}
+
+func (c RetryConfig) validate() error {
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("retry attempts must be at least 1, got %d", c.MaxAttempts)
+	}
+	if c.InitialBackoff < 0 || c.MaxBackoff < 0 {
+		return errors.New("retry backoff cannot be negative")
+	}
+	return nil
+}
+
+// WithRetry 设置暂时性错误的重试策略
+func WithRetry(config RetryConfig) Option {
+	return func(p *Provider) {
+		p.retryConfig = config
+	}
+}
+
+// retry 执行 fn，直到成功、返回非暂时性错误、用完尝试次数或 ctx 结束，fn 必须可以安全地重新执行，例如整个事务
+func (p *Provider) retry(ctx context.Context, operation string, fn func() error) error {
+	backoff := p.retryConfig.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.retryConfig.MaxAttempts || !isTransient(err) {
+			return err
+		}
+		retriesTotal.With(operation).Inc()
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > p.retryConfig.MaxBackoff {
+			backoff = p.retryConfig.MaxBackoff
+		}
+	}
+}
+
+// isTransient err 是否由死锁、锁等待超时或语句执行中断开的连接引起
+func isTransient(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == errLockDeadlock || mysqlErr.Number == errLockWaitTimeout
+	}
+	return errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, driver.ErrBadConn)
+}
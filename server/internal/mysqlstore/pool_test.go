@@ -0,0 +1,107 @@
+package mysqlstore
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestPoolAndRetryConfigValidation(t *testing.T) {
+	if err := DefaultPoolConfig().validate(); err != nil {
+		t.Errorf("default pool config: %v", err)
+	}
+	if err := DefaultRetryConfig().validate(); err != nil {
+		t.Errorf("default retry config: %v", err)
+	}
+
+	const dsn = "user:pass@tcp(127.0.0.1:1)/aries"
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"negative pool limit", WithPool(PoolConfig{MaxOpenConns: -1})},
+		{"more idle than open connections", WithPool(PoolConfig{MaxOpenConns: 2, MaxIdleConns: 4})},
+		{"no attempts", WithRetry(RetryConfig{})},
+		{"negative backoff", WithRetry(RetryConfig{MaxAttempts: 2, InitialBackoff: -time.Millisecond})},
+	}
+	for _, tt := range tests {
+		if p, err := NewProvider(dsn, tt.opt); err == nil {
+			p.Close()
+			t.Errorf("%s: NewProvider succeeded", tt.name)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err       error
+		transient bool
+	}{
+		{&mysql.MySQLError{Number: errLockDeadlock}, true},
+		{fmt.Errorf("put: %w", &mysql.MySQLError{Number: errLockWaitTimeout}), true},
+		{mysql.ErrInvalidConn, true},
+		{driver.ErrBadConn, true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.transient {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.transient)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	p := &Provider{retryConfig: RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}}
+	deadlock := &mysql.MySQLError{Number: errLockDeadlock}
+
+	// 暂时性错误重试到成功为止
+	attempts := 0
+	err := p.retry(context.Background(), "test", func() error {
+		attempts++
+		if attempts < 3 {
+			return deadlock
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("transient errors: %v after %d attempts, want success after 3", err, attempts)
+	}
+
+	// 用完尝试次数后返回最后一次的错误
+	attempts = 0
+	err = p.retry(context.Background(), "test", func() error {
+		attempts++
+		return deadlock
+	})
+	if !errors.Is(err, deadlock) || attempts != 3 {
+		t.Errorf("persistent deadlock: %v after %d attempts, want the deadlock after 3", err, attempts)
+	}
+
+	// 非暂时性错误不重试
+	attempts = 0
+	permanent := errors.New("duplicate entry")
+	if err := p.retry(context.Background(), "test", func() error {
+		attempts++
+		return permanent
+	}); err != permanent || attempts != 1 {
+		t.Errorf("permanent error: %v after %d attempts, want it after 1", err, attempts)
+	}
+
+	// ctx 结束后不再等待重试
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.retryConfig.InitialBackoff = time.Hour
+	attempts = 0
+	if err := p.retry(ctx, "test", func() error {
+		attempts++
+		return deadlock
+	}); err == nil || attempts != 1 {
+		t.Errorf("canceled context: %v after %d attempts, want the deadlock after 1", err, attempts)
+	}
+}
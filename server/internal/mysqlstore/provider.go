@@ -0,0 +1,281 @@
+// Package mysqlstore 基于 MySQL 实现 Aries 的 storage.Provider 接口。
+//
+// 所有存储共用一组带命名空间前缀的表，表结构由版本化的迁移管理。存储名、键和标签只作为查询参数绑定，
+// 打开存储不会创建表或数据库
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// DefaultNamespace 未配置时使用的表名前缀
+const DefaultNamespace = "aries"
+
+// maxNameLength 与 store_name、entry_key 和标签列的 VARCHAR 长度一致
+const maxNameLength = 255
+
+// DefaultQueryTimeout 未配置时每条语句的超时时间
+const DefaultQueryTimeout = 10 * time.Second
+
+// Provider storage.Provider 的 MySQL 实现
+//
+// 存储接口不带 context，其操作受查询超时限制，并在提供者关闭时取消。UpdateContext、BatchContext 和
+// ReencryptContext 还会遵循调用方的 context
+type Provider struct {
+	db           *sql.DB
+	namespace    string
+	tables       tables
+	keyring      *Keyring
+	queryTimeout time.Duration
+	pool         PoolConfig
+	retryConfig  RetryConfig
+	stmts        *statements
+	stores       map[string]*store
+	mutex        sync.RWMutex
+
+	// ctx 由 Close 取消，以中止仍在执行的语句
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Option 提供者配置项
+type Option func(p *Provider)
+
+// WithNamespace 设置表名前缀，使多个部署可以共用一个数据库
+func WithNamespace(namespace string) Option {
+	return func(p *Provider) {
+		p.namespace = namespace
+	}
+}
+
+// WithQueryTimeout 设置每条语句的超时时间，0 表示不限制
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(p *Provider) {
+		p.queryTimeout = timeout
+	}
+}
+
+// NewProvider 连接 MySQL 并将表结构迁移到最新版本，DSN 必须指定数据库，
+// 例如 user:pass@tcp(host:3306)/aries?parseTime=true
+func NewProvider(dsn string, opts ...Option) (*Provider, error) {
+	if dsn == "" {
+		return nil, errors.New("DSN is required")
+	}
+
+	p := &Provider{
+		namespace:    DefaultNamespace,
+		queryTimeout: DefaultQueryTimeout,
+		pool:         DefaultPoolConfig(),
+		retryConfig:  DefaultRetryConfig(),
+		stores:       make(map[string]*store),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.queryTimeout < 0 {
+		return nil, errors.New("query timeout cannot be negative")
+	}
+	if err := p.pool.validate(); err != nil {
+		return nil, err
+	}
+	if err := p.retryConfig.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := validateNamespace(p.namespace); err != nil {
+		return nil, err
+	}
+	p.tables = newTables(p.namespace)
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MySQL connection: %w", err)
+	}
+	p.pool.apply(db)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping MySQL: %w", err)
+	}
+
+	if err := migrate(db, p.namespace, p.tables); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	p.db = db
+	p.stmts = newStatements(db)
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p, nil
+}
+
+// OpenStore 返回指定存储的句柄，存储名不区分大小写
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	name, err := normalizeStoreName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if s, exists := p.stores[name]; exists {
+		return s, nil
+	}
+
+	s := p.newStore(name)
+	s.close = p.removeStore
+	p.stores[name] = s
+	return s, nil
+}
+
+// newStore 返回与提供者共享连接池和预编译语句的存储句柄
+func (p *Provider) newStore(name string) *store {
+	return &store{
+		db:      p.db,
+		name:    name,
+		tables:  p.tables,
+		keyring: p.keyring,
+		bound:   p.bound,
+		retry:   p.retry,
+		stmts:   p.stmts,
+	}
+}
+
+// SetStoreConfig 保存存储配置，存储必须已经打开
+func (p *Provider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	name, err := normalizeStoreName(name)
+	if err != nil {
+		return err
+	}
+
+	for _, tagName := range config.TagNames {
+		if strings.Contains(tagName, ":") {
+			return fmt.Errorf("invalid tag name %q: must not contain ':'", tagName)
+		}
+	}
+
+	if !p.isOpen(name) {
+		return storage.ErrStoreNotFound
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal store configuration: %w", err)
+	}
+
+	ctx, cancel := p.bound(context.Background())
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, "INSERT INTO "+p.tables.configs+" (`store_name`, `config`) VALUES (?, ?) "+
+		"ON DUPLICATE KEY UPDATE `config` = VALUES(`config`)", name, data)
+	if err != nil {
+		return fmt.Errorf("failed to save store configuration: %w", err)
+	}
+	return nil
+}
+
+// GetStoreConfig 返回保存的存储配置
+func (p *Provider) GetStoreConfig(name string) (storage.StoreConfiguration, error) {
+	name, err := normalizeStoreName(name)
+	if err != nil {
+		return storage.StoreConfiguration{}, err
+	}
+
+	ctx, cancel := p.bound(context.Background())
+	defer cancel()
+
+	var data []byte
+	err = p.db.QueryRowContext(ctx, "SELECT `config` FROM "+p.tables.configs+" WHERE `store_name` = ?", name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.StoreConfiguration{}, storage.ErrStoreNotFound
+	}
+	if err != nil {
+		return storage.StoreConfiguration{}, fmt.Errorf("failed to load store configuration: %w", err)
+	}
+
+	var config storage.StoreConfiguration
+	if err := json.Unmarshal(data, &config); err != nil {
+		return storage.StoreConfiguration{}, fmt.Errorf("failed to unmarshal store configuration: %w", err)
+	}
+	return config, nil
+}
+
+// GetOpenStores 返回当前打开的所有存储
+func (p *Provider) GetOpenStores() []storage.Store {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	stores := make([]storage.Store, 0, len(p.stores))
+	for _, s := range p.stores {
+		stores = append(stores, s)
+	}
+	return stores
+}
+
+// Close 取消仍在执行的语句，关闭所有存储和数据库连接
+func (p *Provider) Close() error {
+	p.cancel()
+
+	p.mutex.Lock()
+	p.stores = make(map[string]*store)
+	p.mutex.Unlock()
+
+	p.stmts.close()
+	return p.db.Close()
+}
+
+// bound 从 ctx 派生一条语句或一个事务使用的 context，提供者关闭时取消，配置了查询超时时到期取消
+func (p *Provider) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(p.ctx, cancel)
+	if p.queryTimeout == 0 {
+		return ctx, func() {
+			stop()
+			cancel()
+		}
+	}
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, p.queryTimeout)
+	return ctx, func() {
+		cancelTimeout()
+		stop()
+		cancel()
+	}
+}
+
+func (p *Provider) isOpen(name string) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	_, exists := p.stores[name]
+	return exists
+}
+
+func (p *Provider) removeStore(name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.stores, name)
+}
+
+// normalizeStoreName 将存储名转为小写并校验
+func normalizeStoreName(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("store name is required")
+	}
+	if len(name) > maxNameLength {
+		return "", fmt.Errorf("store name exceeds %d characters", maxNameLength)
+	}
+	return strings.ToLower(name), nil
+}
@@ -0,0 +1,58 @@
+package mysqlstore
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewProviderValidatesOptionsBeforeConnecting(t *testing.T) {
+	// 这些配置在连接数据库之前就被拒绝，DSN 指向的地址不会被访问
+	const dsn = "user:pass@tcp(127.0.0.1:1)/aries"
+	tests := []struct {
+		name string
+		dsn  string
+		opts []Option
+	}{
+		{"empty DSN", "", nil},
+		{"empty namespace", dsn, []Option{WithNamespace("")}},
+		{"namespace with backtick", dsn, []Option{WithNamespace("game`; DROP TABLE x")}},
+		{"namespace too long", dsn, []Option{WithNamespace(strings.Repeat("a", 49))}},
+		{"negative query timeout", dsn, []Option{WithQueryTimeout(-time.Second)}},
+	}
+	for _, tt := range tests {
+		if p, err := NewProvider(tt.dsn, tt.opts...); err == nil {
+			p.Close()
+			t.Errorf("%s: NewProvider succeeded", tt.name)
+		}
+	}
+}
+
+func TestNamespaceTables(t *testing.T) {
+	for _, namespace := range []string{DefaultNamespace, "game_2", strings.Repeat("a", 48)} {
+		if err := validateNamespace(namespace); err != nil {
+			t.Errorf("validateNamespace(%q): %v", namespace, err)
+		}
+	}
+
+	tables := newTables("game")
+	if tables.entries != "`game_entries`" || tables.tags != "`game_tags`" || tables.leases != "`game_leases`" {
+		t.Errorf("unexpected table names: %+v", tables)
+	}
+	if quoted := quoteIdentifier("a`b"); quoted != "`a``b`" {
+		t.Errorf("quoteIdentifier escaped to %s", quoted)
+	}
+}
+
+func TestNormalizeStoreName(t *testing.T) {
+	name, err := normalizeStoreName("DIDStore")
+	if err != nil || name != "didstore" {
+		t.Errorf("normalizeStoreName = %q, %v; want didstore", name, err)
+	}
+	if _, err := normalizeStoreName(""); err == nil {
+		t.Error("empty store name accepted")
+	}
+	if _, err := normalizeStoreName(strings.Repeat("s", maxNameLength+1)); err == nil {
+		t.Error("overlong store name accepted")
+	}
+}
@@ -0,0 +1,234 @@
+package mysqlstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// defaultPageSize 未指定分页大小时每次往返读取的条目数
+const defaultPageSize = 25
+
+// Query 返回标签匹配 "TagName" 或 "TagName:TagValue" 的条目。
+//
+// 匹配使用带索引的标签表。结果按 storage.WithPageSize 分页延迟读取，跳过 storage.WithInitialPageNum 页，
+// 按 storage.WithSortOrder 指定标签的值排序（其次按键）
+func (s *store) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	defer observeQuery(context.Background(), "query")()
+
+	tagName, tagValue, hasValue, err := parseExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := storage.QueryOptions{PageSize: defaultPageSize}
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+	if opts.InitialPageNum < 0 {
+		return nil, errors.New("initial page number cannot be negative")
+	}
+
+	from := " FROM " + s.tables.entries + " e JOIN " + s.tables.tags + " t" +
+		" ON t.`store_name` = e.`store_name` AND t.`entry_key` = e.`entry_key`"
+	where := " WHERE e.`store_name` = ? AND t.`tag_name` = ?"
+	whereArgs := []interface{}{s.name, tagName}
+	if hasValue {
+		where += " AND t.`tag_value` = ?"
+		whereArgs = append(whereArgs, tagValue)
+	}
+
+	var (
+		join     string
+		joinArgs []interface{}
+		order    = " ORDER BY e.`entry_key`"
+	)
+	if opts.SortOptions != nil {
+		join = " LEFT JOIN " + s.tables.tags + " o" +
+			" ON o.`store_name` = e.`store_name` AND o.`entry_key` = e.`entry_key` AND o.`tag_name` = ?"
+		joinArgs = []interface{}{opts.SortOptions.TagName}
+
+		direction := "ASC"
+		if opts.SortOptions.Order == storage.SortDescending {
+			direction = "DESC"
+		}
+		order = " ORDER BY o.`tag_value` " + direction + ", e.`entry_key` " + direction
+	}
+
+	return &iterator{
+		store:     s,
+		query:     "SELECT e.`entry_key`, e.`value`" + from + join + where + order + " LIMIT ? OFFSET ?",
+		args:      append(joinArgs, whereArgs...),
+		countSQL:  "SELECT COUNT(*)" + from + where,
+		countArgs: whereArgs,
+		pageSize:  opts.PageSize,
+		offset:    opts.PageSize * opts.InitialPageNum,
+		index:     -1,
+	}, nil
+}
+
+// entry 一条查询结果
+type entry struct {
+	key   string
+	value []byte
+	tags  []storage.Tag
+}
+
+// iterator 分页遍历查询结果
+type iterator struct {
+	store     *store
+	query     string
+	args      []interface{}
+	countSQL  string
+	countArgs []interface{}
+	pageSize  int
+	offset    int
+
+	page  []entry
+	index int
+	done  bool
+}
+
+// Next 移动到下一个条目，当前页读完时读取下一页
+func (it *iterator) Next() (bool, error) {
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true, nil
+	}
+	if it.done {
+		return false, nil
+	}
+
+	if err := it.fetchPage(); err != nil {
+		return false, err
+	}
+	if len(it.page) == 0 {
+		return false, nil
+	}
+	it.index = 0
+	return true, nil
+}
+
+// fetchPage 读取下一页条目及其标签
+func (it *iterator) fetchPage() error {
+	ctx, cancel := it.store.bound(context.Background())
+	defer cancel()
+
+	args := append(append([]interface{}(nil), it.args...), it.pageSize, it.offset)
+	rows, err := it.store.db.QueryContext(ctx, it.query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query page: %w", err)
+	}
+	defer rows.Close()
+
+	page := make([]entry, 0, it.pageSize)
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.key, &e.value); err != nil {
+			return fmt.Errorf("failed to scan entry: %w", err)
+		}
+		if e.value, err = it.store.open(e.key, e.value); err != nil {
+			return err
+		}
+		page = append(page, e)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	it.page = page
+	it.index = -1
+	it.offset += len(page)
+	if len(page) < it.pageSize {
+		it.done = true
+	}
+
+	return it.loadTags(ctx)
+}
+
+// loadTags 一次查询读取当前页所有条目的标签
+func (it *iterator) loadTags(ctx context.Context) error {
+	if len(it.page) == 0 {
+		return nil
+	}
+
+	args := []interface{}{it.store.name}
+	positions := make(map[string]int, len(it.page))
+	for i, e := range it.page {
+		args = append(args, e.key)
+		positions[e.key] = i
+	}
+
+	rows, err := it.store.db.QueryContext(ctx, "SELECT `entry_key`, `tag_name`, `tag_value` FROM "+it.store.tables.tags+
+		" WHERE `store_name` = ? AND `entry_key` IN ("+placeholders(len(it.page))+")", args...)
+	if err != nil {
+		return fmt.Errorf("failed to load tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var tag storage.Tag
+		if err := rows.Scan(&key, &tag.Name, &tag.Value); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if i, exists := positions[key]; exists {
+			it.page[i].tags = append(it.page[i].tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+func (it *iterator) current() (*entry, error) {
+	if it.index < 0 || it.index >= len(it.page) {
+		return nil, errors.New("iterator is not positioned on an entry")
+	}
+	return &it.page[it.index], nil
+}
+
+func (it *iterator) Key() (string, error) {
+	e, err := it.current()
+	if err != nil {
+		return "", err
+	}
+	return e.key, nil
+}
+
+func (it *iterator) Value() ([]byte, error) {
+	e, err := it.current()
+	if err != nil {
+		return nil, err
+	}
+	return e.value, nil
+}
+
+func (it *iterator) Tags() ([]storage.Tag, error) {
+	e, err := it.current()
+	if err != nil {
+		return nil, err
+	}
+	return e.tags, nil
+}
+
+// TotalItems 返回匹配查询的条目总数，不考虑分页
+func (it *iterator) TotalItems() (int, error) {
+	ctx, cancel := it.store.bound(context.Background())
+	defer cancel()
+
+	var total int
+	if err := it.store.db.QueryRowContext(ctx, it.countSQL, it.countArgs...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count entries: %w", err)
+	}
+	return total, nil
+}
+
+func (it *iterator) Close() error {
+	it.page = nil
+	it.done = true
+	return nil
+}
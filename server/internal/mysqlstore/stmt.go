@@ -0,0 +1,81 @@
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// statements 缓存常用存储操作的预编译语句，Put、Get 和 Delete 不必每次都让服务器重新解析语句。
+// 语句在首次使用时预编译，database/sql 会在连接池的每个连接上和事务中透明地重新预编译
+type statements struct {
+	db    *sql.DB
+	stmts map[string]*sql.Stmt
+	mutex sync.Mutex
+}
+
+func newStatements(db *sql.DB) *statements {
+	return &statements{
+		db:    db,
+		stmts: make(map[string]*sql.Stmt),
+	}
+}
+
+// get 返回 query 的预编译语句，首次使用时预编译
+func (c *statements) get(ctx context.Context, query string) (*sql.Stmt, error) {
+	c.mutex.Lock()
+	stmt, exists := c.stmts[query]
+	c.mutex.Unlock()
+	if exists {
+		return stmt, nil
+	}
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// 其他调用方可能已同时预编译了相同的语句
+	if existing, exists := c.stmts[query]; exists {
+		stmt.Close()
+		return existing, nil
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// exec 在事务 tx 中执行缓存的语句
+func (c *statements) exec(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := c.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+}
+
+// queryRow 执行缓存的单行查询，tx 不为 nil 时在事务中执行
+func (c *statements) queryRow(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*sql.Row, error) {
+	stmt, err := c.get(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	return stmt.QueryRowContext(ctx, args...), nil
+}
+
+// close 关闭所有缓存的语句
+func (c *statements) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for query, stmt := range c.stmts {
+		stmt.Close()
+		delete(c.stmts, query)
+	}
+}
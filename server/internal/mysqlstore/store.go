@@ -0,0 +1,332 @@
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// store 由共享命名空间表中的行组成的逻辑存储
+type store struct {
+	db      *sql.DB
+	name    string
+	tables  tables
+	keyring *Keyring
+	close   func(name string)
+	// bound 将操作限制在提供者的生命周期和查询超时之内
+	bound func(ctx context.Context) (context.Context, context.CancelFunc)
+	// retry 重新执行因暂时性错误失败的操作
+	retry func(ctx context.Context, operation string, fn func() error) error
+	// stmts 提供者的预编译语句
+	stmts *statements
+}
+
+// Put 写入值并替换其标签
+func (s *store) Put(key string, value []byte, tags ...storage.Tag) error {
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer observeQuery(ctx, "put")()
+
+	if err := validatePut(key, value, tags); err != nil {
+		return err
+	}
+
+	return s.retry(ctx, "put", func() error {
+		return s.inTx(ctx, func(tx *sql.Tx) error {
+			return s.put(ctx, tx, key, value, tags)
+		})
+	})
+}
+
+// Get 返回 key 下存储的值
+func (s *store) Get(key string) ([]byte, error) {
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer observeQuery(ctx, "get")()
+
+	if key == "" {
+		return nil, errors.New("key is required")
+	}
+
+	var value []byte
+	err := s.retry(ctx, "get", func() error {
+		var err error
+		value, err = s.get(ctx, nil, key)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrDataNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	return s.open(key, value)
+}
+
+// GetTags 返回与 key 一起存储的标签
+func (s *store) GetTags(key string) ([]storage.Tag, error) {
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer observeQuery(ctx, "get_tags")()
+
+	if _, err := s.Get(key); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT `tag_name`, `tag_value` FROM "+s.tables.tags+" WHERE `store_name` = ? AND `entry_key` = ?", s.name, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags for %s: %w", key, err)
+	}
+	defer rows.Close()
+
+	var tags []storage.Tag
+	for rows.Next() {
+		var tag storage.Tag
+		if err := rows.Scan(&tag.Name, &tag.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetBulk 按 keys 的顺序返回值，不存在的键返回 nil
+func (s *store) GetBulk(keys ...string) ([][]byte, error) {
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer observeQuery(ctx, "get_bulk")()
+
+	if len(keys) == 0 {
+		return nil, errors.New("keys are required")
+	}
+
+	args := []interface{}{s.name}
+	for _, key := range keys {
+		if key == "" {
+			return nil, errors.New("key is required")
+		}
+		args = append(args, key)
+	}
+
+	var found map[string][]byte
+	err := s.retry(ctx, "get_bulk", func() error {
+		var err error
+		found, err = s.getBulk(ctx, len(keys), args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = found[key]
+	}
+	return values, nil
+}
+
+// Delete 删除 key 及其标签，删除不存在的键不是错误
+func (s *store) Delete(key string) error {
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer observeQuery(ctx, "delete")()
+
+	if key == "" {
+		return errors.New("key is required")
+	}
+
+	return s.retry(ctx, "delete", func() error {
+		return s.inTx(ctx, func(tx *sql.Tx) error {
+			return s.delete(ctx, tx, key)
+		})
+	})
+}
+
+// Batch 原子地执行所有操作，值为 nil 的操作删除对应的键
+func (s *store) Batch(operations []storage.Operation) error {
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer observeQuery(ctx, "batch")()
+
+	if len(operations) == 0 {
+		return errors.New("batch requires at least one operation")
+	}
+
+	for _, op := range operations {
+		if op.Value == nil {
+			if op.Key == "" {
+				return errors.New("key is required")
+			}
+			continue
+		}
+		if err := validatePut(op.Key, op.Value, op.Tags); err != nil {
+			return err
+		}
+	}
+
+	return s.retry(ctx, "batch", func() error {
+		return s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, op := range operations {
+				var err error
+				if op.Value == nil {
+					err = s.delete(ctx, tx, op.Key)
+				} else {
+					err = s.put(ctx, tx, op.Key, op.Value, op.Tags)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Flush 每次写入都立即提交，无需操作
+func (s *store) Flush() error {
+	return nil
+}
+
+// Close 释放存储句柄
+func (s *store) Close() error {
+	s.close(s.name)
+	return nil
+}
+
+// get 读取 key 的原始存储值，tx 不为 nil 时在事务中读取
+func (s *store) get(ctx context.Context, tx *sql.Tx, key string) ([]byte, error) {
+	row, err := s.stmts.queryRow(ctx, tx, "SELECT `value` FROM "+s.tables.entries+" WHERE `store_name` = ? AND `entry_key` = ?", s.name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	if err := row.Scan(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// getBulk 读取并解密 count 个键的值，args 为存储名和这些键
+func (s *store) getBulk(ctx context.Context, count int, args []interface{}) (map[string][]byte, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT `entry_key`, `value` FROM "+s.tables.entries+
+		" WHERE `store_name` = ? AND `entry_key` IN ("+placeholders(count)+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get values: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[string][]byte, count)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan value: %w", err)
+		}
+		if value, err = s.open(key, value); err != nil {
+			return nil, err
+		}
+		found[key] = value
+	}
+	return found, rows.Err()
+}
+
+func (s *store) put(ctx context.Context, tx *sql.Tx, key string, value []byte, tags []storage.Tag) error {
+	value, err := s.seal(key, value)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.stmts.exec(ctx, tx, "INSERT INTO "+s.tables.entries+" (`store_name`, `entry_key`, `value`) VALUES (?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `value` = VALUES(`value`), `version` = `version` + 1", s.name, key, value); err != nil {
+		return fmt.Errorf("failed to put %s: %w", key, err)
+	}
+
+	if _, err := s.stmts.exec(ctx, tx, "DELETE FROM "+s.tables.tags+" WHERE `store_name` = ? AND `entry_key` = ?", s.name, key); err != nil {
+		return fmt.Errorf("failed to clear tags for %s: %w", key, err)
+	}
+
+	for _, tag := range tags {
+		if _, err := s.stmts.exec(ctx, tx, "INSERT INTO "+s.tables.tags+" (`store_name`, `entry_key`, `tag_name`, `tag_value`) VALUES (?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE `tag_value` = VALUES(`tag_value`)", s.name, key, tag.Name, tag.Value); err != nil {
+			return fmt.Errorf("failed to put tag %s for %s: %w", tag.Name, key, err)
+		}
+	}
+	return nil
+}
+
+func (s *store) delete(ctx context.Context, tx *sql.Tx, key string) error {
+	if _, err := s.stmts.exec(ctx, tx, "DELETE FROM "+s.tables.tags+" WHERE `store_name` = ? AND `entry_key` = ?", s.name, key); err != nil {
+		return fmt.Errorf("failed to delete tags for %s: %w", key, err)
+	}
+	if _, err := s.stmts.exec(ctx, tx, "DELETE FROM "+s.tables.entries+" WHERE `store_name` = ? AND `entry_key` = ?", s.name, key); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// validatePut 写入前检查键、值和标签
+func validatePut(key string, value []byte, tags []storage.Tag) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+	if len(key) > maxNameLength {
+		return fmt.Errorf("key exceeds %d characters", maxNameLength)
+	}
+	if value == nil {
+		return errors.New("value cannot be nil")
+	}
+
+	for _, tag := range tags {
+		if tag.Name == "" || strings.Contains(tag.Name, ":") {
+			return fmt.Errorf("invalid tag name %q: must be non-empty and must not contain ':'", tag.Name)
+		}
+		if strings.Contains(tag.Value, ":") {
+			return fmt.Errorf("invalid tag value %q: must not contain ':'", tag.Value)
+		}
+		if len(tag.Name) > maxNameLength || len(tag.Value) > maxNameLength {
+			return fmt.Errorf("tag %s exceeds %d characters", tag.Name, maxNameLength)
+		}
+	}
+	return nil
+}
+
+// parseExpression 将查询表达式拆分为标签名和可选的标签值
+func parseExpression(expression string) (string, string, bool, error) {
+	parts := strings.Split(expression, ":")
+	switch {
+	case expression == "":
+		return "", "", false, errors.New("query expression is required")
+	case len(parts) == 1:
+		return parts[0], "", false, nil
+	case len(parts) == 2 && parts[0] != "":
+		return parts[0], parts[1], true, nil
+	default:
+		return "", "", false, fmt.Errorf("invalid query expression %q: must be TagName or TagName:TagValue", expression)
+	}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
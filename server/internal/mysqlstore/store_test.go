@@ -0,0 +1,73 @@
+package mysqlstore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+func TestValidatePut(t *testing.T) {
+	if err := validatePut("key", []byte("{}"), []storage.Tag{{Name: "player", Value: "alice"}}); err != nil {
+		t.Fatalf("valid put rejected: %v", err)
+	}
+	// 空值合法，nil 值不合法
+	if err := validatePut("key", []byte{}, nil); err != nil {
+		t.Errorf("empty value rejected: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		value []byte
+		tags  []storage.Tag
+	}{
+		{"empty key", "", []byte("{}"), nil},
+		{"key too long", strings.Repeat("k", maxNameLength+1), []byte("{}"), nil},
+		{"nil value", "key", nil, nil},
+		{"empty tag name", "key", []byte("{}"), []storage.Tag{{Value: "v"}}},
+		{"colon in tag name", "key", []byte("{}"), []storage.Tag{{Name: "a:b"}}},
+		{"colon in tag value", "key", []byte("{}"), []storage.Tag{{Name: "a", Value: "b:c"}}},
+		{"tag value too long", "key", []byte("{}"), []storage.Tag{{Name: "a", Value: strings.Repeat("v", maxNameLength+1)}}},
+	}
+	for _, tt := range tests {
+		if err := validatePut(tt.key, tt.value, tt.tags); err == nil {
+			t.Errorf("%s: validatePut succeeded", tt.name)
+		}
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		name       string
+		value      string
+		hasValue   bool
+		valid      bool
+	}{
+		{"player", "player", "", false, true},
+		{"player:alice", "player", "alice", true, true},
+		{"player:", "player", "", true, true},
+		{"", "", "", false, false},
+		{":alice", "", "", false, false},
+		{"a:b:c", "", "", false, false},
+	}
+	for _, tt := range tests {
+		name, value, hasValue, err := parseExpression(tt.expression)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseExpression(%q) error = %v, want valid %v", tt.expression, err, tt.valid)
+			continue
+		}
+		if name != tt.name || value != tt.value || hasValue != tt.hasValue {
+			t.Errorf("parseExpression(%q) = %q, %q, %v; want %q, %q, %v", tt.expression, name, value, hasValue, tt.name, tt.value, tt.hasValue)
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	for n, want := range map[int]string{1: "?", 3: "?, ?, ?"} {
+		if got := placeholders(n); got != want {
+			t.Errorf("placeholders(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
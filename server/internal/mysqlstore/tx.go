@@ -0,0 +1,121 @@
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// Tx 跨越同一提供者任意多个存储的事务
+type Tx struct {
+	tx       *sql.Tx
+	provider *Provider
+	ctx      context.Context
+}
+
+// Update 在事务中执行 fn，fn 返回 nil 时提交，否则回滚
+func (p *Provider) Update(fn func(tx *Tx) error) error {
+	return p.UpdateContext(context.Background(), fn)
+}
+
+// UpdateContext 与 Update 相同，但 ctx 在提交前被取消或超过截止时间时回滚事务，查询超时作用于整个事务
+func (p *Provider) UpdateContext(ctx context.Context, fn func(tx *Tx) error) error {
+	ctx, cancel := p.bound(ctx)
+	defer cancel()
+	defer observeQuery(ctx, "transaction")()
+
+	sqlTx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := fn(&Tx{tx: sqlTx, provider: p, ctx: ctx}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Batch 在一个存储中原子地写入 puts 并删除 deletes
+func (p *Provider) Batch(storeName string, puts []storage.Operation, deletes []string) error {
+	return p.BatchContext(context.Background(), storeName, puts, deletes)
+}
+
+// BatchContext 与 Batch 相同，但遵循 ctx 的取消和截止时间
+func (p *Provider) BatchContext(ctx context.Context, storeName string, puts []storage.Operation, deletes []string) error {
+	return p.UpdateContext(ctx, func(tx *Tx) error {
+		return tx.Batch(storeName, puts, deletes)
+	})
+}
+
+// Put 在事务中写入值并替换其标签
+func (t *Tx) Put(storeName, key string, value []byte, tags ...storage.Tag) error {
+	s, err := t.store(storeName)
+	if err != nil {
+		return err
+	}
+	if err := validatePut(key, value, tags); err != nil {
+		return err
+	}
+	return s.put(t.ctx, t.tx, key, value, tags)
+}
+
+// Delete 在事务中删除键
+func (t *Tx) Delete(storeName, key string) error {
+	s, err := t.store(storeName)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("key is required")
+	}
+	return s.delete(t.ctx, t.tx, key)
+}
+
+// Get 在事务中读取值，可以读到本事务自己的写入
+func (t *Tx) Get(storeName, key string) ([]byte, error) {
+	s, err := t.store(storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.get(t.ctx, t.tx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrDataNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	return s.open(key, value)
+}
+
+// Batch 在事务中向一个存储写入 puts 并删除 deletes
+func (t *Tx) Batch(storeName string, puts []storage.Operation, deletes []string) error {
+	for _, op := range puts {
+		if err := t.Put(storeName, op.Key, op.Value, op.Tags...); err != nil {
+			return err
+		}
+	}
+	for _, key := range deletes {
+		if err := t.Delete(storeName, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store 返回写入 storeName 的句柄，存储不必已经打开
+func (t *Tx) store(storeName string) (*store, error) {
+	name, err := normalizeStoreName(storeName)
+	if err != nil {
+		return nil, err
+	}
+	return t.provider.newStore(name), nil
+}
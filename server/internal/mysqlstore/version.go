@@ -0,0 +1,161 @@
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// ErrVersionConflict 条目的版本与预期不同，即读取后已被其他写入者修改
+var ErrVersionConflict = errors.New("version conflict")
+
+// 每个条目带有从 1 开始、每次写入递增的版本号，写入者据此发现读取后其他进程修改了条目，版本 0 表示条目不存在
+
+// Versions 返回 storeName 中所有条目的版本。先读版本再读值，保证版本不会比对应的值更新，
+// 过期的副本不会覆盖较新的值
+func (p *Provider) Versions(storeName string) (map[string]uint64, error) {
+	return p.VersionsContext(context.Background(), storeName)
+}
+
+// VersionsContext 与 Versions 相同，但遵循 ctx 的取消和截止时间
+func (p *Provider) VersionsContext(ctx context.Context, storeName string) (map[string]uint64, error) {
+	name, err := normalizeStoreName(storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := p.bound(ctx)
+	defer cancel()
+	defer observeQuery(ctx, "versions")()
+
+	var versions map[string]uint64
+	err = p.retry(ctx, "versions", func() error {
+		rows, err := p.db.QueryContext(ctx, "SELECT `entry_key`, `version` FROM "+p.tables.entries+" WHERE `store_name` = ?", name)
+		if err != nil {
+			return fmt.Errorf("failed to get versions: %w", err)
+		}
+		defer rows.Close()
+
+		versions = make(map[string]uint64)
+		for rows.Next() {
+			var key string
+			var version uint64
+			if err := rows.Scan(&key, &version); err != nil {
+				return fmt.Errorf("failed to scan version: %w", err)
+			}
+			versions[key] = version
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// BatchIfVersion 原子地对一个存储执行操作，值为 nil 的操作删除对应的键。键在 expected 中的操作只在条目仍是该版本时执行，
+// 0 表示条目不能存在，其他操作无条件执行。冲突的操作被跳过，不影响其他操作，其键作为冲突返回。
+// 同时返回已执行操作的新版本，已删除的键为 0
+func (p *Provider) BatchIfVersion(storeName string, ops []storage.Operation, expected map[string]uint64) (map[string]uint64, []string, error) {
+	return p.BatchIfVersionContext(context.Background(), storeName, ops, expected)
+}
+
+// BatchIfVersionContext 与 BatchIfVersion 相同，但遵循 ctx 的取消和截止时间
+func (p *Provider) BatchIfVersionContext(ctx context.Context, storeName string, ops []storage.Operation, expected map[string]uint64) (map[string]uint64, []string, error) {
+	if len(ops) == 0 {
+		return nil, nil, errors.New("batch requires at least one operation")
+	}
+
+	var (
+		versions  map[string]uint64
+		conflicts []string
+	)
+	err := p.retry(ctx, "batch_if_version", func() error {
+		versions, conflicts = make(map[string]uint64, len(ops)), nil
+		return p.UpdateContext(ctx, func(tx *Tx) error {
+			for _, op := range ops {
+				current, err := tx.Version(storeName, op.Key)
+				if err != nil {
+					return err
+				}
+				if want, conditional := expected[op.Key]; conditional && current != want {
+					conflicts = append(conflicts, op.Key)
+					continue
+				}
+
+				switch {
+				case op.Value != nil:
+					err = tx.Put(storeName, op.Key, op.Value, op.Tags...)
+					versions[op.Key] = current + 1
+				case current != 0:
+					err = tx.Delete(storeName, op.Key)
+					versions[op.Key] = 0
+				default:
+					versions[op.Key] = 0
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return versions, conflicts, nil
+}
+
+// Version 返回 key 的版本并锁定该行直到事务结束，使版本在事务写入该键之前不会改变，0 表示键不存在
+func (t *Tx) Version(storeName, key string) (uint64, error) {
+	s, err := t.store(storeName)
+	if err != nil {
+		return 0, err
+	}
+	if key == "" {
+		return 0, errors.New("key is required")
+	}
+
+	var version uint64
+	err = t.tx.QueryRowContext(t.ctx, "SELECT `version` FROM "+s.tables.entries+" WHERE `store_name` = ? AND `entry_key` = ? FOR UPDATE", s.name, key).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get version of %s: %w", key, err)
+	}
+	return version, nil
+}
+
+// PutIfVersion key 仍是预期版本时像 Put 一样写入值并返回新版本，0 表示键不能存在，否则不写入并返回 ErrVersionConflict
+func (t *Tx) PutIfVersion(storeName, key string, expected uint64, value []byte, tags ...storage.Tag) (uint64, error) {
+	current, err := t.Version(storeName, key)
+	if err != nil {
+		return 0, err
+	}
+	if current != expected {
+		return 0, fmt.Errorf("%w: %s is at version %d, expected %d", ErrVersionConflict, key, current, expected)
+	}
+	if err := t.Put(storeName, key, value, tags...); err != nil {
+		return 0, err
+	}
+	return current + 1, nil
+}
+
+// DeleteIfVersion key 仍是预期版本时像 Delete 一样删除，否则不删除并返回 ErrVersionConflict
+func (t *Tx) DeleteIfVersion(storeName, key string, expected uint64) error {
+	current, err := t.Version(storeName, key)
+	if err != nil {
+		return err
+	}
+	if current != expected {
+		return fmt.Errorf("%w: %s is at version %d, expected %d", ErrVersionConflict, key, current, expected)
+	}
+	if current == 0 {
+		return nil
+	}
+	return t.Delete(storeName, key)
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Middleware 按客户端 IP 限制路径前缀为 prefixes 之一的请求，超限时返回 429 和 Retry-After，
+// 其余请求直接交给 next
+func Middleware(limiter *Limiter, prefixes []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasPrefix(r.URL.Path, prefixes) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ok, wait := limiter.Reserve(ClientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientIP 返回请求的来源 IP。不信任 X-Forwarded-For 等可伪造的请求头，
+// 部署在反向代理之后时应由代理负责限流
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate 令牌桶速率：每秒补充 Limit 个令牌，最多积累 Burst 个
+type Rate struct {
+	Limit float64
+	Burst int
+}
+
+// Disabled Limit 不大于 0 表示不限流
+func (r Rate) Disabled() bool {
+	return r.Limit <= 0
+}
+
+// bucket 单个键的令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter 按键（IP、玩家等）分别维护令牌桶的限流器，长时间空闲的桶会被回收
+type Limiter struct {
+	rate    Rate
+	buckets map[string]*bucket
+	mutex   sync.Mutex
+
+	// 桶补满所需时间，空闲超过该时间的桶与新建的桶等价，可以安全删除
+	idle      time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter 创建限流器
+func NewLimiter(rate Rate) *Limiter {
+	if rate.Burst < 1 {
+		rate.Burst = 1
+	}
+
+	idle := time.Minute
+	if !rate.Disabled() {
+		idle = time.Duration(float64(rate.Burst) / rate.Limit * float64(time.Second))
+	}
+
+	return &Limiter{
+		rate:    rate,
+		buckets: make(map[string]*bucket),
+		idle:    idle,
+		now:     time.Now,
+	}
+}
+
+// Allow 消耗 key 的一个令牌，令牌不足时返回 false
+func (l *Limiter) Allow(key string) bool {
+	ok, _ := l.Reserve(key)
+	return ok
+}
+
+// Reserve 消耗 key 的一个令牌；令牌不足时返回 false 和下一个令牌可用前的等待时间
+func (l *Limiter) Reserve(key string) (bool, time.Duration) {
+	if l.rate.Disabled() {
+		return true, 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.rate.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.rate.Burst), b.tokens+now.Sub(b.last).Seconds()*l.rate.Limit)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate.Limit * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep 定期回收空闲的令牌桶，调用方需持有锁
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.idle {
+			delete(l.buckets, key)
+		}
+	}
+}
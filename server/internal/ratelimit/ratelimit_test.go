@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestLimiter 返回时钟由 advance 推进的限流器
+func newTestLimiter(rate Rate) (*Limiter, func(time.Duration)) {
+	limiter := NewLimiter(rate)
+	now := time.Unix(0, 0)
+	limiter.now = func() time.Time { return now }
+	return limiter, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiterRefillsTokens(t *testing.T) {
+	limiter, advance := newTestLimiter(Rate{Limit: 2, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a") {
+			t.Fatalf("request %d within the burst rejected", i)
+		}
+	}
+	ok, wait := limiter.Reserve("a")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("Reserve after the burst = %v, %v; want false, 500ms", ok, wait)
+	}
+	// 其他键有自己的令牌桶
+	if !limiter.Allow("b") {
+		t.Error("another key was limited")
+	}
+
+	advance(500 * time.Millisecond)
+	if !limiter.Allow("a") {
+		t.Error("token not refilled after the wait")
+	}
+	if limiter.Allow("a") {
+		t.Error("more than one token refilled")
+	}
+}
+
+func TestLimiterSweepsIdleBuckets(t *testing.T) {
+	limiter, advance := newTestLimiter(Rate{Limit: 1, Burst: 2})
+	limiter.Allow("a")
+	advance(3 * time.Second)
+	limiter.Allow("b")
+
+	if _, exists := limiter.buckets["a"]; exists {
+		t.Error("idle bucket was not removed")
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	limiter := NewLimiter(Rate{})
+	for i := 0; i < 100; i++ {
+		if !limiter.Allow("a") {
+			t.Fatal("disabled limiter rejected a request")
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter, _ := newTestLimiter(Rate{Limit: 0.5, Burst: 1})
+	handler := Middleware(limiter, []string{"/api/"}, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("/api/did"); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rec.Code)
+	}
+	rec := serve("/api/did")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("limited request: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := serve("/health"); rec.Code != http.StatusOK {
+		t.Errorf("unlimited path: status %d", rec.Code)
+	}
+}
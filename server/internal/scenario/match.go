@@ -0,0 +1,215 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// wildcard 期望中匹配任意非空值的占位符
+const wildcard = "*"
+
+// variablePattern 字符串中的变量引用 ${name}
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// match 判断 actual 是否包含 expected：映射逐键匹配，期望列表中的每一项需匹配实际列表中的某一项，
+// 数字按数值比较。不匹配时返回第一处差异
+func match(expected, actual interface{}, path string) error {
+	if path == "" {
+		path = "data"
+	}
+
+	switch want := expected.(type) {
+	case nil:
+		if actual != nil {
+			return fmt.Errorf("%s: expected null, got %s", path, compact(actual))
+		}
+		return nil
+
+	case map[string]interface{}:
+		got, ok := actual.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %s", path, compact(actual))
+		}
+		for key, value := range want {
+			field, exists := got[key]
+			if !exists {
+				return fmt.Errorf("%s.%s: missing", path, key)
+			}
+			if err := match(value, field, path+"."+key); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case []interface{}:
+		got, ok := actual.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a list, got %s", path, compact(actual))
+		}
+		for i, value := range want {
+			found := false
+			for _, item := range got {
+				if match(value, item, path) == nil {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: no item matches %s (expected[%d]) in %s", path, compact(value), i, compact(actual))
+			}
+		}
+		return nil
+
+	case string:
+		if want == wildcard {
+			if actual == nil {
+				return fmt.Errorf("%s: expected a value, got null", path)
+			}
+			return nil
+		}
+		if got, ok := actual.(string); ok && got == want {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %q, got %s", path, want, compact(actual))
+	}
+
+	if want, ok := number(expected); ok {
+		if got, ok := number(actual); ok && got == want {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %v, got %s", path, expected, compact(actual))
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		return fmt.Errorf("%s: expected %v, got %s", path, expected, compact(actual))
+	}
+	return nil
+}
+
+// number 将 YAML 和 JSON 解码出的各种数字统一为 float64
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// lookup 按点分路径取值，列表用数字下标，如 data.credential.type.0
+func lookup(v interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			value, exists := node[part]
+			if !exists {
+				return nil, fmt.Errorf("path %s: %q not found", path, part)
+			}
+			v = value
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("path %s: invalid index %q for a list of %d items", path, part, len(node))
+			}
+			v = node[index]
+		default:
+			return nil, fmt.Errorf("path %s: %q is not inside an object or list", path, part)
+		}
+	}
+	return v, nil
+}
+
+// substitute 替换 v 中所有字符串里的 ${name}。整个字符串只有一个变量时替换为变量的原始值，保留其类型
+func substitute(v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch node := v.(type) {
+	case string:
+		return substituteString(node, vars)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			replaced, err := substitute(value, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = replaced
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(node))
+		for i, value := range node {
+			replaced, err := substitute(value, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = replaced
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// substituteString 替换字符串中的变量
+func substituteString(s string, vars map[string]interface{}) (interface{}, error) {
+	if m := variablePattern.FindStringSubmatch(s); m != nil && m[0] == s {
+		value, exists := vars[m[1]]
+		if !exists {
+			return nil, fmt.Errorf("undefined variable %q", m[1])
+		}
+		return value, nil
+	}
+
+	var missing string
+	replaced := variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variablePattern.FindStringSubmatch(ref)[1]
+		value, exists := vars[name]
+		if !exists {
+			missing = name
+			return ref
+		}
+		if str, ok := value.(string); ok {
+			return str
+		}
+		return compact(value)
+	})
+	if missing != "" {
+		return nil, fmt.Errorf("undefined variable %q", missing)
+	}
+	return replaced, nil
+}
+
+// generic 将值经 JSON 转换为由映射、列表和基本类型组成的通用结构
+func generic(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// compact 返回值的单行 JSON 表示，过长时截断
+func compact(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	const maxLength = 200
+	if len(data) > maxLength {
+		return string(data[:maxLength]) + "..."
+	}
+	return string(data)
+}
@@ -0,0 +1,400 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/pkg/client"
+)
+
+// DefaultTimeout 场景和 Runner 都未指定超时时等待期望消息的时间
+const DefaultTimeout = 5 * time.Second
+
+// recentMessages 期望失败时列出的最近收到的消息条数
+const recentMessages = 10
+
+// Runner 以 pkg/client 模拟玩家，对 BaseURL 指向的服务器执行场景
+type Runner struct {
+	BaseURL string
+	// Timeout 等待期望消息的默认超时，场景可以覆盖
+	Timeout time.Duration
+	// Logf 不为 nil 时输出每个步骤和保存的变量
+	Logf func(format string, args ...interface{})
+}
+
+// NewRunner 创建对 baseURL 执行场景的 Runner
+func NewRunner(baseURL string) *Runner {
+	return &Runner{BaseURL: baseURL, Timeout: DefaultTimeout}
+}
+
+// StepError 场景在某个步骤失败
+type StepError struct {
+	Index int
+	Step  string
+	Err   error
+	// Received 失败时玩家已收到但未被期望消费的消息，按到达顺序
+	Received []string
+}
+
+// Error 实现 error 接口
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("step %d (%s): %v", e.Index+1, e.Step, e.Err)
+	if len(e.Received) > 0 {
+		msg += "\n  unconsumed messages:\n    " + strings.Join(e.Received, "\n    ")
+	}
+	return msg
+}
+
+// Unwrap 返回步骤失败的原因
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// player 运行中的玩家：身份、连接和尚未被期望消费的消息
+type player struct {
+	spec   Player
+	client *client.Client
+	conn   *client.Conn
+
+	inbox  []client.Message
+	notify chan struct{}
+	mutex  sync.Mutex
+}
+
+// receive 将消息放入收件箱并唤醒等待的期望
+func (p *player) receive(msg client.Message) {
+	p.mutex.Lock()
+	p.inbox = append(p.inbox, msg)
+	p.mutex.Unlock()
+
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take 取出收件箱中第一条满足 accept 的消息
+func (p *player) take(accept func(client.Message) bool) (client.Message, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, msg := range p.inbox {
+		if accept(msg) {
+			p.inbox = append(p.inbox[:i], p.inbox[i+1:]...)
+			return msg, true
+		}
+	}
+	return client.Message{}, false
+}
+
+// pending 返回收件箱中最近的消息摘要
+func (p *player) pending() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	msgs := p.inbox
+	if len(msgs) > recentMessages {
+		msgs = msgs[len(msgs)-recentMessages:]
+	}
+	summaries := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		summaries = append(summaries, msg.Type+" "+compact(msg.Data))
+	}
+	return summaries
+}
+
+// run 一次场景执行的状态
+type run struct {
+	runner   *Runner
+	scenario *Scenario
+	players  map[string]*player
+	vars     map[string]interface{}
+	timeout  time.Duration
+}
+
+// Run 按顺序执行场景的步骤，第一个失败的步骤返回 *StepError。结束时关闭所有玩家的连接
+func (r *Runner) Run(ctx context.Context, s *Scenario) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = r.Timeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	state := &run{
+		runner:   r,
+		scenario: s,
+		players:  make(map[string]*player, len(s.Players)),
+		vars:     make(map[string]interface{}),
+		timeout:  timeout,
+	}
+	defer state.close()
+
+	// 玩家 ID 每次运行随机生成，DID 在连接前即可被其他玩家的步骤引用
+	runID := uuid.New().String()[:8]
+	for _, spec := range s.Players {
+		gameID := spec.GameID
+		if gameID == "" {
+			gameID = DefaultGameID
+		}
+		identity, err := client.NewIdentity(gameID, spec.Name+"-"+runID)
+		if err != nil {
+			return fmt.Errorf("player %s: %w", spec.Name, err)
+		}
+		config := client.DefaultConfig(r.BaseURL)
+		config.Locale = spec.Locale
+
+		p := &player{spec: spec, client: client.NewClient(config, identity), notify: make(chan struct{}, 1)}
+		p.client.On(client.AllMessages, p.receive)
+		state.players[spec.Name] = p
+		state.vars[spec.Name+".did"] = identity.ID()
+	}
+
+	for i, step := range s.Steps {
+		r.logf("step %d/%d: %s", i+1, len(s.Steps), step.describe())
+		if err := state.step(ctx, step); err != nil {
+			stepErr := &StepError{Index: i, Step: step.describe(), Err: err}
+			if p := state.player(step); p != nil {
+				stepErr.Received = p.pending()
+			}
+			return stepErr
+		}
+	}
+	return nil
+}
+
+// logf 在配置了 Logf 时输出日志
+func (r *Runner) logf(format string, args ...interface{}) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
+
+// player 返回步骤作用的玩家，未指定时为唯一的玩家
+func (st *run) player(step Step) *player {
+	name := step.Player
+	if name == "" {
+		name = st.scenario.Players[0].Name
+	}
+	return st.players[name]
+}
+
+// close 关闭所有玩家的连接
+func (st *run) close() {
+	for _, p := range st.players {
+		if p.conn != nil {
+			p.conn.Close()
+		}
+	}
+}
+
+// step 执行一个步骤的动作和期望
+func (st *run) step(ctx context.Context, step Step) error {
+	p := st.player(step)
+
+	switch {
+	case step.Connect:
+		if err := st.connect(ctx, p); err != nil {
+			return err
+		}
+	case step.Send != "":
+		if p.conn == nil {
+			return errors.New("player is not connected")
+		}
+		var data interface{}
+		if step.Data != nil {
+			var err error
+			if data, err = substitute(step.Data, st.vars); err != nil {
+				return err
+			}
+		}
+		if err := p.conn.Send(step.Send, data); err != nil {
+			return err
+		}
+	case step.Request != nil:
+		return st.request(ctx, p, step.Request, step.Save)
+	case step.Sleep > 0:
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(step.Sleep):
+		}
+	case step.Close:
+		if p.conn == nil {
+			return errors.New("player is not connected")
+		}
+		p.conn.Close()
+		p.conn = nil
+	}
+
+	if step.Expect != nil {
+		return st.expect(ctx, p, step.Expect, step.Save)
+	}
+	return nil
+}
+
+// connect 注册玩家的 DID 并连接认证，之后 ${player.playerId} 可用
+func (st *run) connect(ctx context.Context, p *player) error {
+	if p.conn != nil {
+		return errors.New("player is already connected")
+	}
+	nickname := p.spec.Nickname
+	if nickname == "" {
+		nickname = p.spec.Name
+	}
+	if err := p.client.Register(ctx, nickname); err != nil {
+		return fmt.Errorf("register DID: %w", err)
+	}
+	conn, err := p.client.Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	p.conn = conn
+	st.vars[p.spec.Name+".playerId"] = conn.Session().PlayerID
+	return nil
+}
+
+// request 以玩家的 DID 认证调用 HTTP 接口，检查状态码和响应并保存变量
+func (st *run) request(ctx context.Context, p *player, req *Request, save map[string]string) error {
+	path, err := substituteString(req.Path, st.vars)
+	if err != nil {
+		return err
+	}
+	body, err := substitute(req.Body, st.vars)
+	if err != nil {
+		return err
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var response interface{}
+	status := http.StatusOK
+	err = p.client.Do(ctx, strings.ToUpper(method), fmt.Sprint(path), body, &response)
+	var httpErr *client.HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		// 错误响应体是 JSON 时同样可以匹配
+		status = httpErr.StatusCode
+		if json.Unmarshal([]byte(httpErr.Message), &response) != nil {
+			response = httpErr.Message
+		}
+	case errors.Is(err, io.EOF):
+		// 响应没有内容
+	case err != nil:
+		return err
+	}
+
+	if req.Status != 0 && status != req.Status {
+		return fmt.Errorf("expected status %d, got %d: %s", req.Status, status, compact(response))
+	}
+	if req.Status == 0 && httpErr != nil {
+		return fmt.Errorf("request failed: %w", httpErr)
+	}
+	if req.Response != nil {
+		expected, err := substitute(req.Response, st.vars)
+		if err != nil {
+			return err
+		}
+		if err := match(expected, response, "response"); err != nil {
+			return err
+		}
+	}
+	return st.save(response, save)
+}
+
+// expect 等待玩家收到匹配的消息。期望的不是 error 时，先收到的 error 消息使步骤立即失败
+func (st *run) expect(ctx context.Context, p *player, expectation *Expectation, save map[string]string) error {
+	expected, err := substitute(expectation.Data, st.vars)
+	if err != nil {
+		return err
+	}
+	roomID, err := substituteString(expectation.RoomID, st.vars)
+	if err != nil {
+		return err
+	}
+
+	var mismatch error
+	accept := func(msg client.Message) bool {
+		if msg.Type != expectation.Type || (expectation.RoomID != "" && msg.RoomID != fmt.Sprint(roomID)) {
+			return false
+		}
+		if expected == nil {
+			return true
+		}
+		data, err := generic(msg.Data)
+		if err != nil {
+			return false
+		}
+		if err := match(expected, data, "data"); err != nil {
+			mismatch = err
+			return false
+		}
+		return true
+	}
+	unexpectedError := func(msg client.Message) bool {
+		return msg.Type == client.MsgTypeError && expectation.Type != client.MsgTypeError
+	}
+
+	timeout := expectation.Timeout
+	if timeout <= 0 {
+		timeout = st.timeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		if msg, ok := p.take(accept); ok {
+			if expectation.None {
+				return fmt.Errorf("unexpected %s message: %s", msg.Type, compact(msg.Data))
+			}
+			value, err := generic(msg)
+			if err != nil {
+				return err
+			}
+			return st.save(value, save)
+		}
+		if msg, ok := p.take(unexpectedError); ok {
+			return fmt.Errorf("server error while waiting for %s: %s", expectation.Type, compact(msg.Data))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.notify:
+		case <-timer.C:
+			if expectation.None {
+				return nil
+			}
+			if mismatch != nil {
+				return fmt.Errorf("no matching %s message within %s, last mismatch: %w", expectation.Type, timeout, mismatch)
+			}
+			return fmt.Errorf("no %s message within %s", expectation.Type, timeout)
+		}
+	}
+}
+
+// save 从消息或响应中按路径保存变量
+func (st *run) save(v interface{}, save map[string]string) error {
+	for name, path := range save {
+		value, err := lookup(v, path)
+		if err != nil {
+			return fmt.Errorf("save %s: %w", name, err)
+		}
+		st.vars[name] = value
+		st.runner.logf("  %s = %s", name, compact(value))
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Package scenario 执行 YAML 描述的集成测试场景：若干模拟玩家依次连接、发送消息、调用 HTTP 接口，
+// 并断言收到的服务器消息。场景可以在进程内的内存服务器上运行，也可以指向远程服务器
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultGameID 玩家未指定游戏时 DID 中的游戏 ID
+const DefaultGameID = "scenario"
+
+// Scenario 一个场景：参与的玩家和按顺序执行的步骤
+type Scenario struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description,omitempty"`
+	Players     []Player `yaml:"players"`
+	Steps       []Step   `yaml:"steps"`
+	// Timeout 等待期望消息的默认超时，为 0 时使用 Runner 的超时
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Player 场景中的一个玩家，步骤中以 Name 引用。玩家 ID 在每次运行时随机生成，
+// 同一场景可以对同一服务器重复运行
+type Player struct {
+	Name     string `yaml:"name"`
+	Nickname string `yaml:"nickname,omitempty"`
+	GameID   string `yaml:"gameId,omitempty"`
+	Locale   string `yaml:"locale,omitempty"`
+}
+
+// Step 一个步骤。Connect、Send、Request、Sleep 和 Close 中最多设置一个动作，
+// Expect 在动作之后等待玩家收到的消息，Save 从匹配的消息或响应中保存变量。
+// 字符串中的 ${name} 替换为变量，${player.did} 和 ${player.playerId} 为玩家的 DID 和玩家 ID
+type Step struct {
+	Player string `yaml:"player,omitempty"`
+
+	// Connect 为 true 时注册玩家的 DID 并连接、认证
+	Connect bool `yaml:"connect,omitempty"`
+	// Send 发送的消息类型，载荷为 Data
+	Send string                 `yaml:"send,omitempty"`
+	Data map[string]interface{} `yaml:"data,omitempty"`
+	// Request 以玩家的 DID 认证调用 HTTP 接口
+	Request *Request `yaml:"request,omitempty"`
+	// Sleep 等待一段时间
+	Sleep time.Duration `yaml:"sleep,omitempty"`
+	// Close 为 true 时关闭玩家的连接且不重连
+	Close bool `yaml:"close,omitempty"`
+
+	Expect *Expectation `yaml:"expect,omitempty"`
+	// Save 变量名 -> 匹配的消息（或响应）中的路径，如 data.credential.id
+	Save map[string]string `yaml:"save,omitempty"`
+}
+
+// Request HTTP 请求及对响应的期望
+type Request struct {
+	Method string      `yaml:"method"`
+	Path   string      `yaml:"path"`
+	Body   interface{} `yaml:"body,omitempty"`
+	// Status 期望的状态码，为 0 时期望 2xx
+	Status int `yaml:"status,omitempty"`
+	// Response 期望响应 JSON 包含的内容，匹配规则同 Expectation.Data
+	Response interface{} `yaml:"response,omitempty"`
+}
+
+// Expectation 期望玩家收到的消息。Data 只需是消息 data 的子集：映射逐键匹配，
+// 列表中的每一项需匹配实际列表中的某一项，"*" 匹配任意非空值
+type Expectation struct {
+	Type   string      `yaml:"type"`
+	RoomID string      `yaml:"roomId,omitempty"`
+	Data   interface{} `yaml:"data,omitempty"`
+	// Timeout 等待超时，为 0 时使用场景的超时
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// None 为 true 时期望超时前没有收到匹配的消息
+	None bool `yaml:"none,omitempty"`
+}
+
+// Load 读取并校验场景文件
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = path
+	}
+	return s, nil
+}
+
+// Parse 解析并校验 YAML 场景
+func Parse(data []byte) (*Scenario, error) {
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate 校验场景：玩家名唯一，每个步骤引用已声明的玩家并且最多只有一个动作
+func (s *Scenario) Validate() error {
+	if len(s.Players) == 0 {
+		return errors.New("scenario has no players")
+	}
+	if len(s.Steps) == 0 {
+		return errors.New("scenario has no steps")
+	}
+
+	names := make(map[string]bool, len(s.Players))
+	for i, player := range s.Players {
+		if player.Name == "" {
+			return fmt.Errorf("players[%d]: name is required", i)
+		}
+		if names[player.Name] {
+			return fmt.Errorf("players[%d]: duplicate name %q", i, player.Name)
+		}
+		names[player.Name] = true
+	}
+
+	for i, step := range s.Steps {
+		if step.Player != "" && !names[step.Player] {
+			return fmt.Errorf("steps[%d]: unknown player %q", i, step.Player)
+		}
+		if step.Player == "" && len(s.Players) > 1 && step.needsPlayer() {
+			return fmt.Errorf("steps[%d]: player is required when the scenario has several players", i)
+		}
+
+		actions := 0
+		for _, set := range []bool{step.Connect, step.Send != "", step.Request != nil, step.Sleep > 0, step.Close} {
+			if set {
+				actions++
+			}
+		}
+		switch {
+		case actions > 1:
+			return fmt.Errorf("steps[%d]: connect, send, request, sleep and close are mutually exclusive", i)
+		case actions == 0 && step.Expect == nil:
+			return fmt.Errorf("steps[%d]: step has no action or expectation", i)
+		case step.Expect != nil && step.Expect.Type == "":
+			return fmt.Errorf("steps[%d]: expect.type is required", i)
+		case step.Request != nil && step.Request.Path == "":
+			return fmt.Errorf("steps[%d]: request.path is required", i)
+		case step.Request != nil && step.Expect != nil:
+			return fmt.Errorf("steps[%d]: request steps check the response with request.response instead of expect", i)
+		case len(step.Save) > 0 && step.Expect == nil && step.Request == nil:
+			return fmt.Errorf("steps[%d]: save requires expect or request", i)
+		case step.Expect != nil && step.Expect.None && len(step.Save) > 0:
+			return fmt.Errorf("steps[%d]: save cannot be used with expect.none", i)
+		}
+	}
+	return nil
+}
+
+// needsPlayer 步骤是否作用于某个玩家
+func (s Step) needsPlayer() bool {
+	return s.Sleep == 0 || s.Expect != nil
+}
+
+// describe 返回步骤的简短描述，用于日志和错误
+func (s Step) describe() string {
+	var action string
+	switch {
+	case s.Connect:
+		action = "connect"
+	case s.Send != "":
+		action = "send " + s.Send
+	case s.Request != nil:
+		action = s.Request.Method + " " + s.Request.Path
+	case s.Sleep > 0:
+		action = "sleep " + s.Sleep.String()
+	case s.Close:
+		action = "close"
+	}
+	if s.Expect != nil {
+		expect := "expect " + s.Expect.Type
+		if s.Expect.None {
+			expect = "expect no " + s.Expect.Type
+		}
+		if action == "" {
+			action = expect
+		} else {
+			action += ", " + expect
+		}
+	}
+	if s.Player != "" {
+		return s.Player + ": " + action
+	}
+	return action
+}
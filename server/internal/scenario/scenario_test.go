@@ -0,0 +1,153 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScenarios 在进程内内存服务器上执行 scenarios 目录中的每个场景
+func TestScenarios(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "scenarios", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no scenario files found")
+	}
+
+	for _, file := range files {
+		s, err := Load(file)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			server, err := StartServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			runner := NewRunner(server.URL)
+			runner.Logf = t.Logf
+			if err := runner.Run(context.Background(), s); err != nil {
+				t.Fatalf("%s: %v", s.Name, err)
+			}
+		})
+	}
+}
+
+func TestRunReportsFailedExpectation(t *testing.T) {
+	server, err := StartServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	s, err := Parse([]byte(`
+name: join fails
+players:
+  - name: alice
+steps:
+  - connect: true
+  - send: join_room
+    data: {roomId: scenario-fail}
+    expect: {type: join_room, data: {success: false}, timeout: 500ms}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewRunner(server.URL).Run(context.Background(), s)
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) {
+		t.Fatalf("Run = %v, want a StepError", err)
+	}
+	if !strings.Contains(err.Error(), "join_room") {
+		t.Errorf("error %q does not name the failing step", err)
+	}
+}
+
+func TestParseRejectsInvalidScenarios(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"no players", "name: x\nsteps: [{sleep: 1s}]", "no players"},
+		{"duplicate player", "name: x\nplayers: [{name: a}, {name: a}]\nsteps: [{connect: true, player: a}]", "duplicate name"},
+		{"unknown player", "name: x\nplayers: [{name: a}]\nsteps: [{connect: true, player: b}]", "unknown player"},
+		{"player required", "name: x\nplayers: [{name: a}, {name: b}]\nsteps: [{connect: true}]", "player is required"},
+		{"two actions", "name: x\nplayers: [{name: a}]\nsteps: [{connect: true, close: true}]", "mutually exclusive"},
+		{"expect without type", "name: x\nplayers: [{name: a}]\nsteps: [{expect: {data: {}}}]", "expect.type is required"},
+		{"save without source", "name: x\nplayers: [{name: a}]\nsteps: [{connect: true, save: {id: data.id}}]", "save requires"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.yaml)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Parse error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	s, err := Parse([]byte("name: x\ntimeout: 2s\nplayers: [{name: a}]\nsteps: [{connect: true}]"))
+	if err != nil {
+		t.Fatalf("valid scenario rejected: %v", err)
+	}
+	if s.Timeout != 2*time.Second {
+		t.Errorf("timeout = %v, want 2s", s.Timeout)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	actual := map[string]interface{}{
+		"success": true,
+		"level":   float64(3),
+		"player":  map[string]interface{}{"id": "p1", "nickname": "alice"},
+		"items":   []interface{}{"sword", "shield"},
+		"missing": nil,
+	}
+
+	tests := []struct {
+		name     string
+		expected interface{}
+		ok       bool
+	}{
+		{"subset of keys", map[string]interface{}{"success": true}, true},
+		{"integer matches float", map[string]interface{}{"level": 3}, true},
+		{"nested object", map[string]interface{}{"player": map[string]interface{}{"nickname": "alice"}}, true},
+		{"list item", map[string]interface{}{"items": []interface{}{"shield"}}, true},
+		{"wildcard", map[string]interface{}{"player": "*"}, true},
+		{"wildcard rejects null", map[string]interface{}{"missing": "*"}, false},
+		{"wrong value", map[string]interface{}{"level": 4}, false},
+		{"missing key", map[string]interface{}{"score": 1}, false},
+		{"absent list item", map[string]interface{}{"items": []interface{}{"bow"}}, false},
+	}
+	for _, tt := range tests {
+		if err := match(tt.expected, actual, ""); (err == nil) != tt.ok {
+			t.Errorf("%s: match = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]interface{}{"alice.playerId": "p1", "count": float64(2)}
+
+	got, err := substitute(map[string]interface{}{
+		"id":      "${alice.playerId}",
+		"message": "hello ${alice.playerId}",
+		"count":   "${count}",
+	}, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"id": "p1", "message": "hello p1", "count": float64(2)}
+	if err := match(want, got, ""); err != nil {
+		t.Errorf("substitute: %v", err)
+	}
+
+	if _, err := substitute("${unknown}", vars); err == nil {
+		t.Error("undefined variable substituted")
+	}
+}
@@ -0,0 +1,70 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/czh0526/game/server/internal/api"
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/game"
+	"github.com/czh0526/game/server/internal/storage"
+	"github.com/czh0526/game/server/internal/vc"
+)
+
+// shutdownTimeout 关闭进程内服务器时等待玩家会话保存的时间
+const shutdownTimeout = 5 * time.Second
+
+// Server 进程内的游戏服务器，状态保存在内存存储中，每个实例互不影响
+type Server struct {
+	// URL 服务器的 HTTP 地址，作为 Runner 的 BaseURL
+	URL string
+
+	http *httptest.Server
+	game *game.SimpleServer
+}
+
+// StartServer 启动进程内的游戏服务器，注册与正式服务器相同的 DID、凭证和 WebSocket 路由
+func StartServer() (*Server, error) {
+	didService := did.NewSimpleService()
+	vcService, err := vc.NewSimpleService(didService)
+	if err != nil {
+		return nil, fmt.Errorf("create VC service: %w", err)
+	}
+	persistence, err := game.NewPersistence(storage.NewMemoryProvider(), game.DefaultPersistenceConfig())
+	if err != nil {
+		return nil, fmt.Errorf("create game persistence: %w", err)
+	}
+	gameServer, err := game.NewSimpleServerWithPersistence(didService, vcService, persistence)
+	if err != nil {
+		return nil, fmt.Errorf("create game server: %w", err)
+	}
+	vcService.SetClaimSource(gameServer)
+
+	// 先确定监听地址，DID 认证令牌的 aud 和 htu 需要与之一致
+	mux := http.NewServeMux()
+	httpServer := httptest.NewUnstartedServer(mux)
+	url := "http://" + httpServer.Listener.Addr().String()
+
+	authConfig := didauth.DefaultConfig()
+	authConfig.PublicURL = url
+	api.Register(mux, didauth.NewAuthenticator(didService, authConfig), didService, vcService, gameServer)
+	mux.HandleFunc(game.GamesPath, gameServer.HandleGames)
+	mux.HandleFunc(game.GamesPath+"/", gameServer.HandleGames)
+
+	httpServer.Start()
+	return &Server{URL: url, http: httpServer, game: gameServer}, nil
+}
+
+// Close 关闭游戏服务器和 HTTP 监听
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := s.game.Shutdown(ctx)
+	s.http.Close()
+	return err
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/kms"
+	"github.com/czh0526/game/server/internal/mysqlstore"
+)
+
+// EnvEncryptionKeys 未指定加密密钥参数时读取的环境变量
+const EnvEncryptionKeys = "GAME_STORAGE_ENCRYPTION_KEYS"
+
+// ParseEncryptionKeys 由逗号分隔的密钥列表创建密钥环，最新的密钥在前：
+//
+//	id=<base64>   直接给出的 32 字节密钥
+//	id            KMS 保管的数据密钥，首次使用时创建
+//
+// 第一个密钥加密新值，其余密钥用于读取轮换前写入的值。所有密钥都直接给出，或 KMS 无法跨重启保留数据密钥时，
+// dataKeys 可以为 nil
+func ParseEncryptionKeys(spec string, dataKeys kms.DataKeyProvider) (*mysqlstore.Keyring, error) {
+	var active string
+	keys := make(map[string][]byte)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		id, encoded, inline := strings.Cut(item, "=")
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("duplicate encryption key %q", id)
+		}
+
+		var key []byte
+		var err error
+		if inline {
+			if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+				return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+			}
+		} else {
+			if dataKeys == nil {
+				return nil, fmt.Errorf("encryption key %q must be given as id=<base64>: no persistent KMS data keys are available", id)
+			}
+			if key, err = dataKeys.DataKey(id); err != nil {
+				return nil, fmt.Errorf("failed to load encryption key %q from KMS: %w", id, err)
+			}
+		}
+
+		keys[id] = key
+		if active == "" {
+			active = id
+		}
+	}
+
+	if active == "" {
+		return nil, errors.New("no encryption keys given")
+	}
+	return mysqlstore.NewKeyring(active, keys)
+}
@@ -0,0 +1,109 @@
+// Package storage 选择所有服务共用的 storage.Provider 后端
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	spi "github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/mysqlstore"
+)
+
+// EnvStorage 未指定存储参数时读取的环境变量
+const EnvStorage = "GAME_STORAGE"
+
+// 存储后端
+const (
+	BackendMemory  = "memory"
+	BackendMySQL   = "mysql"
+	BackendSQLite  = "sqlite"
+	BackendLevelDB = "leveldb"
+)
+
+// Options 所选后端的配置
+type Options struct {
+	// Namespace SQL 后端的表名前缀
+	Namespace string
+	// Encryption 不为空时静态加密值，只有 MySQL 后端支持
+	Encryption *mysqlstore.Keyring
+	// QueryTimeout 每条 SQL 语句的超时时间，0 表示使用后端默认值（SQLite 不限制）
+	QueryTimeout time.Duration
+	// Pool 不为空时代替后端默认值设置 SQL 连接池大小
+	Pool *mysqlstore.PoolConfig
+}
+
+// Open 根据存储配置创建提供者：
+//
+//	:memory:             内存存储，退出后数据丢失
+//	mysql:<dsn>          MySQL，例如 mysql:root:pw@tcp(localhost:3308)/aries?parseTime=true
+//	sqlite:<file>        SQLite 数据库文件，不存在时创建
+//	leveldb:<dir>        LevelDB 目录，不存在时创建
+//
+// 为兼容 -mysql-dsn，也接受不带前缀的 MySQL DSN（包含 "@tcp("）
+func Open(spec string, opts Options) (spi.Provider, error) {
+	backend, target := ParseSpec(spec)
+
+	switch backend {
+	case BackendMemory:
+		return NewMemoryProvider(), nil
+	case BackendMySQL:
+		var mysqlOpts []mysqlstore.Option
+		if opts.Namespace != "" {
+			mysqlOpts = append(mysqlOpts, mysqlstore.WithNamespace(opts.Namespace))
+		}
+		if opts.Encryption != nil {
+			mysqlOpts = append(mysqlOpts, mysqlstore.WithEncryption(opts.Encryption))
+		}
+		if opts.Pool != nil {
+			mysqlOpts = append(mysqlOpts, mysqlstore.WithPool(*opts.Pool))
+		}
+		if opts.QueryTimeout != 0 {
+			mysqlOpts = append(mysqlOpts, mysqlstore.WithQueryTimeout(opts.QueryTimeout))
+		}
+		return mysqlstore.NewProvider(target, mysqlOpts...)
+	case BackendSQLite, BackendLevelDB:
+		if opts.Encryption != nil {
+			return nil, fmt.Errorf("storage encryption is not supported by the %s backend", backend)
+		}
+		if backend == BackendSQLite {
+			return NewSQLiteProvider(target, opts.QueryTimeout)
+		}
+		return NewLevelDBProvider(target)
+	default:
+		return nil, fmt.Errorf("unknown storage spec %q: use :memory:, mysql:<dsn>, sqlite:<file> or leveldb:<dir>", spec)
+	}
+}
+
+// ParseSpec 将存储配置拆分为后端和目标
+func ParseSpec(spec string) (string, string) {
+	spec = strings.TrimSpace(spec)
+
+	switch {
+	case spec == ":memory:" || spec == BackendMemory:
+		return BackendMemory, ""
+	case strings.HasPrefix(spec, BackendMySQL+":"):
+		return BackendMySQL, strings.TrimPrefix(spec, BackendMySQL+":")
+	case strings.HasPrefix(spec, BackendSQLite+":"):
+		return BackendSQLite, strings.TrimPrefix(spec, BackendSQLite+":")
+	case strings.HasPrefix(spec, BackendLevelDB+":"):
+		return BackendLevelDB, strings.TrimPrefix(spec, BackendLevelDB+":")
+	case strings.Contains(spec, "@tcp("):
+		return BackendMySQL, spec
+	default:
+		return "", spec
+	}
+}
+
+// SpecFromEnv 依次返回 spec、$GAME_STORAGE 和 fallback 中第一个不为空的值
+func SpecFromEnv(spec, fallback string) string {
+	if spec != "" {
+		return spec
+	}
+	if env := os.Getenv(EnvStorage); env != "" {
+		return env
+	}
+	return fallback
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	spi "github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// 键值引擎中的键前缀，存储名和键以零字节分隔，一个存储的条目是一段连续的键范围
+const (
+	kvEntryPrefix  = "e\x00"
+	kvConfigPrefix = "c\x00"
+)
+
+// kvEngine 有序的键值引擎，例如 LevelDB 或一张 SQLite 表
+type kvEngine interface {
+	// get 键不存在时返回 spi.ErrDataNotFound
+	get(key []byte) ([]byte, error)
+	// write 原子地先执行所有删除，再执行所有写入
+	write(puts map[string][]byte, deletes []string) error
+	// scan 按键的顺序对每个带有该前缀的键调用 fn
+	scan(prefix []byte, fn func(key, value []byte) error) error
+	close() error
+}
+
+// kvProvider 将所有存储持久化到一个键值引擎的 storage.Provider。查询扫描存储的键范围并按标签过滤，
+// 适合单节点部署的数据量，数据量更大时使用 MySQL
+type kvProvider struct {
+	engine kvEngine
+	stores map[string]*kvStore
+	mutex  sync.RWMutex
+}
+
+func newKVProvider(engine kvEngine) *kvProvider {
+	return &kvProvider{engine: engine, stores: make(map[string]*kvStore)}
+}
+
+// OpenStore 返回指定的存储，存储名不区分大小写
+func (p *kvProvider) OpenStore(name string) (spi.Store, error) {
+	if name == "" {
+		return nil, errors.New("store name is required")
+	}
+	name = strings.ToLower(name)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if s, exists := p.stores[name]; exists {
+		return s, nil
+	}
+	s := &kvStore{engine: p.engine, prefix: kvEntryPrefix + name + "\x00"}
+	p.stores[name] = s
+	return s, nil
+}
+
+// SetStoreConfig 保存存储配置，存储必须已经打开
+func (p *kvProvider) SetStoreConfig(name string, config spi.StoreConfiguration) error {
+	name = strings.ToLower(name)
+
+	p.mutex.RLock()
+	_, open := p.stores[name]
+	p.mutex.RUnlock()
+	if !open {
+		return spi.ErrStoreNotFound
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal store configuration: %w", err)
+	}
+	if err := p.engine.write(map[string][]byte{kvConfigPrefix + name: data}, nil); err != nil {
+		return fmt.Errorf("failed to save store configuration: %w", err)
+	}
+	return nil
+}
+
+// GetStoreConfig 返回保存的存储配置
+func (p *kvProvider) GetStoreConfig(name string) (spi.StoreConfiguration, error) {
+	data, err := p.engine.get([]byte(kvConfigPrefix + strings.ToLower(name)))
+	if errors.Is(err, spi.ErrDataNotFound) {
+		return spi.StoreConfiguration{}, spi.ErrStoreNotFound
+	}
+	if err != nil {
+		return spi.StoreConfiguration{}, fmt.Errorf("failed to load store configuration: %w", err)
+	}
+
+	var config spi.StoreConfiguration
+	if err := json.Unmarshal(data, &config); err != nil {
+		return spi.StoreConfiguration{}, fmt.Errorf("failed to unmarshal store configuration: %w", err)
+	}
+	return config, nil
+}
+
+// GetOpenStores 返回所有已打开的存储
+func (p *kvProvider) GetOpenStores() []spi.Store {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	stores := make([]spi.Store, 0, len(p.stores))
+	for _, s := range p.stores {
+		stores = append(stores, s)
+	}
+	return stores
+}
+
+// Close 关闭引擎，从该提供者打开的存储不能再使用
+func (p *kvProvider) Close() error {
+	p.mutex.Lock()
+	p.stores = make(map[string]*kvStore)
+	p.mutex.Unlock()
+
+	return p.engine.close()
+}
+
+// kvRecord 条目的存储形式
+type kvRecord struct {
+	Value []byte    `json:"value"`
+	Tags  []spi.Tag `json:"tags,omitempty"`
+}
+
+type kvStore struct {
+	engine kvEngine
+	prefix string
+}
+
+func (s *kvStore) Put(key string, value []byte, tags ...spi.Tag) error {
+	if err := validateOperation(key, value, tags); err != nil {
+		return err
+	}
+	return s.Batch([]spi.Operation{{Key: key, Value: value, Tags: tags}})
+}
+
+func (s *kvStore) record(key string) (*kvRecord, error) {
+	if key == "" {
+		return nil, errors.New("key is required")
+	}
+	data, err := s.engine.get([]byte(s.prefix + key))
+	if err != nil {
+		return nil, err
+	}
+
+	var r kvRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to decode entry %q: %w", key, err)
+	}
+	return &r, nil
+}
+
+func (s *kvStore) Get(key string) ([]byte, error) {
+	r, err := s.record(key)
+	if err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
+
+func (s *kvStore) GetTags(key string) ([]spi.Tag, error) {
+	r, err := s.record(key)
+	if err != nil {
+		return nil, err
+	}
+	return r.Tags, nil
+}
+
+func (s *kvStore) GetBulk(keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keys are required")
+	}
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := s.Get(key)
+		if err != nil && !errors.Is(err, spi.ErrDataNotFound) {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// Query 返回标签匹配 "TagName" 或 "TagName:TagValue" 的条目
+func (s *kvStore) Query(expression string, options ...spi.QueryOption) (spi.Iterator, error) {
+	tagName, tagValue, hasValue, err := parseExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := spi.QueryOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	var matches []memoryResult
+	err = s.engine.scan([]byte(s.prefix), func(key, value []byte) error {
+		var r kvRecord
+		if err := json.Unmarshal(value, &r); err != nil {
+			return fmt.Errorf("failed to decode entry %q: %w", key, err)
+		}
+		for _, tag := range r.Tags {
+			if tag.Name == tagName && (!hasValue || tag.Value == tagValue) {
+				matches = append(matches, memoryResult{
+					key:   string(bytes.TrimPrefix(key, []byte(s.prefix))),
+					value: r.Value,
+					tags:  r.Tags,
+				})
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query store: %w", err)
+	}
+
+	sortResults(matches, opts.SortOptions)
+
+	if opts.PageSize > 0 && opts.InitialPageNum > 0 {
+		skip := opts.PageSize * opts.InitialPageNum
+		if skip > len(matches) {
+			skip = len(matches)
+		}
+		matches = matches[skip:]
+	}
+
+	return &memoryIterator{results: matches, index: -1}, nil
+}
+
+func (s *kvStore) Delete(key string) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+	return s.engine.write(nil, []string{s.prefix + key})
+}
+
+// Batch 原子地执行所有操作，值为 nil 的操作删除对应的键
+func (s *kvStore) Batch(operations []spi.Operation) error {
+	if len(operations) == 0 {
+		return errors.New("batch requires at least one operation")
+	}
+
+	puts := make(map[string][]byte)
+	var deletes []string
+	for _, op := range operations {
+		key := s.prefix + op.Key
+		if op.Value == nil {
+			if op.Key == "" {
+				return errors.New("key is required")
+			}
+			delete(puts, key)
+			deletes = append(deletes, key)
+			continue
+		}
+		if err := validateOperation(op.Key, op.Value, op.Tags); err != nil {
+			return err
+		}
+		data, err := json.Marshal(kvRecord{Value: op.Value, Tags: op.Tags})
+		if err != nil {
+			return fmt.Errorf("failed to encode entry %q: %w", op.Key, err)
+		}
+		puts[key] = data
+	}
+
+	if err := s.engine.write(puts, deletes); err != nil {
+		return fmt.Errorf("failed to write batch: %w", err)
+	}
+	return nil
+}
+
+func (s *kvStore) Flush() error {
+	return nil
+}
+
+func (s *kvStore) Close() error {
+	return nil
+}
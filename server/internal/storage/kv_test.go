@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	spi "github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// persistentBackends 在固定位置打开每种持久化后端的提供者，再次调用时重新打开相同的数据
+var persistentBackends = map[string]func(t *testing.T, dir string) spi.Provider{
+	BackendSQLite: func(t *testing.T, dir string) spi.Provider {
+		provider, err := Open("sqlite:"+filepath.Join(dir, "game.db"), Options{})
+		if err != nil {
+			t.Fatalf("open SQLite: %v", err)
+		}
+		return provider
+	},
+	BackendLevelDB: func(t *testing.T, dir string) spi.Provider {
+		provider, err := Open("leveldb:"+filepath.Join(dir, "leveldb"), Options{})
+		if err != nil {
+			t.Fatalf("open LevelDB: %v", err)
+		}
+		return provider
+	},
+}
+
+func TestPersistentBackends(t *testing.T) {
+	for name, open := range persistentBackends {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			provider := open(t, dir)
+			store, err := provider.OpenStore("Players")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := store.Put("alice", []byte("1"), spi.Tag{Name: "player", Value: "b"}); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if err := store.Put("bob", []byte("2"), spi.Tag{Name: "player", Value: "a"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Put("guild", []byte("3"), spi.Tag{Name: "guild"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Put("nil", nil); err == nil {
+				t.Error("nil value accepted")
+			}
+			if _, err := store.Get("carol"); !errors.Is(err, spi.ErrDataNotFound) {
+				t.Errorf("Get missing key: %v, want ErrDataNotFound", err)
+			}
+
+			// 名称以该存储名开头的其他存储不能出现在查询结果中
+			other, err := provider.OpenStore("Players2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := other.Put("mallory", []byte("x"), spi.Tag{Name: "player"}); err != nil {
+				t.Fatal(err)
+			}
+
+			keys := queryKeys(t, store, "player", spi.WithSortOrder(&spi.SortOptions{Order: spi.SortAscending, TagName: "player"}))
+			if len(keys) != 2 || keys[0] != "bob" || keys[1] != "alice" {
+				t.Fatalf("query by tag = %v, want [bob alice]", keys)
+			}
+			if keys := queryKeys(t, store, "player:b"); len(keys) != 1 || keys[0] != "alice" {
+				t.Fatalf("query by tag value = %v, want [alice]", keys)
+			}
+
+			err = store.Batch([]spi.Operation{
+				{Key: "alice"},
+				{Key: "carol", Value: []byte("4"), Tags: []spi.Tag{{Name: "player", Value: "c"}}},
+			})
+			if err != nil {
+				t.Fatalf("Batch: %v", err)
+			}
+			if err := provider.SetStoreConfig("players", spi.StoreConfiguration{TagNames: []string{"player"}}); err != nil {
+				t.Fatalf("SetStoreConfig: %v", err)
+			}
+			if err := provider.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			reopened := open(t, dir)
+			defer reopened.Close()
+			store, err = reopened.OpenStore("players")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get("alice"); !errors.Is(err, spi.ErrDataNotFound) {
+				t.Errorf("deleted key survived: %v", err)
+			}
+			value, err := store.Get("carol")
+			if err != nil || string(value) != "4" {
+				t.Errorf("Get after reopen = %q, %v", value, err)
+			}
+			tags, err := store.GetTags("bob")
+			if err != nil || len(tags) != 1 || tags[0].Value != "a" {
+				t.Errorf("GetTags after reopen = %v, %v", tags, err)
+			}
+			config, err := reopened.GetStoreConfig("players")
+			if err != nil || len(config.TagNames) != 1 {
+				t.Errorf("GetStoreConfig after reopen = %v, %v", config, err)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsEncryptionForKeyValueBackends(t *testing.T) {
+	keyring, err := ParseEncryptionKeys("k1=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("leveldb:"+t.TempDir(), Options{Encryption: keyring}); err == nil {
+		t.Error("LevelDB backend accepted an encryption keyring it would ignore")
+	}
+}
+
+func queryKeys(t *testing.T, store spi.Store, expression string, options ...spi.QueryOption) []string {
+	t.Helper()
+	iter, err := store.Query(expression, options...)
+	if err != nil {
+		t.Fatalf("Query(%q): %v", expression, err)
+	}
+	defer iter.Close()
+
+	var keys []string
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !more {
+			return keys
+		}
+		key, err := iter.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+}
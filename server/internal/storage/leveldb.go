@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	spi "github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// NewLevelDBProvider 打开（或创建）dir 中的 LevelDB 数据库，同一时间只能有一个进程打开该目录
+func NewLevelDBProvider(dir string) (spi.Provider, error) {
+	if dir == "" {
+		return nil, errors.New("LevelDB directory is required")
+	}
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	return newKVProvider(&levelDBEngine{db: db}), nil
+}
+
+type levelDBEngine struct {
+	db *leveldb.DB
+}
+
+func (e *levelDBEngine) get(key []byte) ([]byte, error) {
+	value, err := e.db.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, spi.ErrDataNotFound
+	}
+	return value, err
+}
+
+func (e *levelDBEngine) write(puts map[string][]byte, deletes []string) error {
+	batch := new(leveldb.Batch)
+	for _, key := range deletes {
+		batch.Delete([]byte(key))
+	}
+	for key, value := range puts {
+		batch.Put([]byte(key), value)
+	}
+	return e.db.Write(batch, nil)
+}
+
+func (e *levelDBEngine) scan(prefix []byte, fn func(key, value []byte) error) error {
+	iter := e.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		if err := fn(iter.Key(), iter.Value()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+func (e *levelDBEngine) close() error {
+	return e.db.Close()
+}
@@ -0,0 +1,383 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	spi "github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// MemoryProvider 用于开发和测试的内存 storage.Provider，退出后数据丢失
+type MemoryProvider struct {
+	stores  map[string]*memoryStore
+	configs map[string]spi.StoreConfiguration
+	mutex   sync.RWMutex
+}
+
+// NewMemoryProvider 创建空的内存提供者
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{
+		stores:  make(map[string]*memoryStore),
+		configs: make(map[string]spi.StoreConfiguration),
+	}
+}
+
+// OpenStore 返回指定的存储，不存在时创建，存储名不区分大小写
+func (p *MemoryProvider) OpenStore(name string) (spi.Store, error) {
+	if name == "" {
+		return nil, errors.New("store name is required")
+	}
+	name = strings.ToLower(name)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if s, exists := p.stores[name]; exists {
+		return s, nil
+	}
+
+	s := &memoryStore{entries: make(map[string]*memoryEntry)}
+	p.stores[name] = s
+	return s, nil
+}
+
+// SetStoreConfig 保存存储配置，存储必须已经打开
+func (p *MemoryProvider) SetStoreConfig(name string, config spi.StoreConfiguration) error {
+	name = strings.ToLower(name)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, exists := p.stores[name]; !exists {
+		return spi.ErrStoreNotFound
+	}
+	p.configs[name] = config
+	return nil
+}
+
+// GetStoreConfig 返回保存的存储配置
+func (p *MemoryProvider) GetStoreConfig(name string) (spi.StoreConfiguration, error) {
+	name = strings.ToLower(name)
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	config, exists := p.configs[name]
+	if !exists {
+		return spi.StoreConfiguration{}, spi.ErrStoreNotFound
+	}
+	return config, nil
+}
+
+// GetOpenStores 返回所有已打开的存储
+func (p *MemoryProvider) GetOpenStores() []spi.Store {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	stores := make([]spi.Store, 0, len(p.stores))
+	for _, s := range p.stores {
+		stores = append(stores, s)
+	}
+	return stores
+}
+
+// Close 无需操作，存储的数据保留到进程退出
+func (p *MemoryProvider) Close() error {
+	return nil
+}
+
+// Batch 在一个存储中原子地写入 puts 并删除 deletes
+func (p *MemoryProvider) Batch(storeName string, puts []spi.Operation, deletes []string) error {
+	s, err := p.OpenStore(storeName)
+	if err != nil {
+		return err
+	}
+
+	ops := append([]spi.Operation(nil), puts...)
+	for _, key := range deletes {
+		ops = append(ops, spi.Operation{Key: key})
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	return s.Batch(ops)
+}
+
+type memoryEntry struct {
+	value []byte
+	tags  []spi.Tag
+}
+
+type memoryStore struct {
+	entries map[string]*memoryEntry
+	mutex   sync.RWMutex
+}
+
+func (s *memoryStore) Put(key string, value []byte, tags ...spi.Tag) error {
+	if err := validateOperation(key, value, tags); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.put(key, value, tags)
+	return nil
+}
+
+func (s *memoryStore) put(key string, value []byte, tags []spi.Tag) {
+	s.entries[key] = &memoryEntry{
+		value: append([]byte(nil), value...),
+		tags:  append([]spi.Tag(nil), tags...),
+	}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	e, exists := s.entries[key]
+	if !exists {
+		return nil, spi.ErrDataNotFound
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+func (s *memoryStore) GetTags(key string) ([]spi.Tag, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	e, exists := s.entries[key]
+	if !exists {
+		return nil, spi.ErrDataNotFound
+	}
+	return append([]spi.Tag(nil), e.tags...), nil
+}
+
+func (s *memoryStore) GetBulk(keys ...string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keys are required")
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		if e, exists := s.entries[key]; exists {
+			values[i] = append([]byte(nil), e.value...)
+		}
+	}
+	return values, nil
+}
+
+// Query 返回标签匹配 "TagName" 或 "TagName:TagValue" 的条目
+func (s *memoryStore) Query(expression string, options ...spi.QueryOption) (spi.Iterator, error) {
+	tagName, tagValue, hasValue, err := parseExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := spi.QueryOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	s.mutex.RLock()
+	var matches []memoryResult
+	for key, e := range s.entries {
+		for _, tag := range e.tags {
+			if tag.Name == tagName && (!hasValue || tag.Value == tagValue) {
+				matches = append(matches, memoryResult{
+					key:   key,
+					value: append([]byte(nil), e.value...),
+					tags:  append([]spi.Tag(nil), e.tags...),
+				})
+				break
+			}
+		}
+	}
+	s.mutex.RUnlock()
+
+	sortResults(matches, opts.SortOptions)
+
+	if opts.PageSize > 0 && opts.InitialPageNum > 0 {
+		skip := opts.PageSize * opts.InitialPageNum
+		if skip > len(matches) {
+			skip = len(matches)
+		}
+		matches = matches[skip:]
+	}
+
+	return &memoryIterator{results: matches, index: -1}, nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Batch 原子地执行所有操作，值为 nil 的操作删除对应的键
+func (s *memoryStore) Batch(operations []spi.Operation) error {
+	if len(operations) == 0 {
+		return errors.New("batch requires at least one operation")
+	}
+	for _, op := range operations {
+		if op.Value == nil {
+			if op.Key == "" {
+				return errors.New("key is required")
+			}
+			continue
+		}
+		if err := validateOperation(op.Key, op.Value, op.Tags); err != nil {
+			return err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, op := range operations {
+		if op.Value == nil {
+			delete(s.entries, op.Key)
+		} else {
+			s.put(op.Key, op.Value, op.Tags)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Flush() error {
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+type memoryResult struct {
+	key   string
+	value []byte
+	tags  []spi.Tag
+}
+
+// sortResults 按排序标签的值排序结果，其次按键
+func sortResults(results []memoryResult, options *spi.SortOptions) {
+	tagValue := func(r memoryResult) string {
+		for _, tag := range r.tags {
+			if tag.Name == options.TagName {
+				return tag.Value
+			}
+		}
+		return ""
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if options != nil {
+			vi, vj := tagValue(results[i]), tagValue(results[j])
+			if vi != vj {
+				if options.Order == spi.SortDescending {
+					return vi > vj
+				}
+				return vi < vj
+			}
+		}
+		return results[i].key < results[j].key
+	})
+}
+
+type memoryIterator struct {
+	results []memoryResult
+	index   int
+}
+
+func (it *memoryIterator) Next() (bool, error) {
+	if it.index+1 >= len(it.results) {
+		return false, nil
+	}
+	it.index++
+	return true, nil
+}
+
+func (it *memoryIterator) current() (*memoryResult, error) {
+	if it.index < 0 || it.index >= len(it.results) {
+		return nil, errors.New("iterator is not positioned on an entry")
+	}
+	return &it.results[it.index], nil
+}
+
+func (it *memoryIterator) Key() (string, error) {
+	r, err := it.current()
+	if err != nil {
+		return "", err
+	}
+	return r.key, nil
+}
+
+func (it *memoryIterator) Value() ([]byte, error) {
+	r, err := it.current()
+	if err != nil {
+		return nil, err
+	}
+	return r.value, nil
+}
+
+func (it *memoryIterator) Tags() ([]spi.Tag, error) {
+	r, err := it.current()
+	if err != nil {
+		return nil, err
+	}
+	return r.tags, nil
+}
+
+func (it *memoryIterator) TotalItems() (int, error) {
+	return len(it.results), nil
+}
+
+func (it *memoryIterator) Close() error {
+	return nil
+}
+
+// validateOperation 写入前检查键、值和标签
+func validateOperation(key string, value []byte, tags []spi.Tag) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+	if value == nil {
+		return errors.New("value cannot be nil")
+	}
+	for _, tag := range tags {
+		if tag.Name == "" || strings.Contains(tag.Name, ":") {
+			return fmt.Errorf("invalid tag name %q: must be non-empty and must not contain ':'", tag.Name)
+		}
+		if strings.Contains(tag.Value, ":") {
+			return fmt.Errorf("invalid tag value %q: must not contain ':'", tag.Value)
+		}
+	}
+	return nil
+}
+
+// parseExpression 将查询表达式拆分为标签名和可选的标签值
+func parseExpression(expression string) (string, string, bool, error) {
+	parts := strings.Split(expression, ":")
+	switch {
+	case expression == "":
+		return "", "", false, errors.New("query expression is required")
+	case len(parts) == 1:
+		return parts[0], "", false, nil
+	case len(parts) == 2 && parts[0] != "":
+		return parts[0], parts[1], true, nil
+	default:
+		return "", "", false, fmt.Errorf("invalid query expression %q: must be TagName or TagName:TagValue", expression)
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	spi "github.com/hyperledger/aries-framework-go/spi/storage"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteBusyTimeout 语句等待其他连接持有的锁的最长时间
+const sqliteBusyTimeout = 5 * time.Second
+
+// NewSQLiteProvider 打开（或创建）SQLite 数据库文件，所有存储共用一张键值表。
+// queryTimeout 为每条语句的超时时间，0 表示不限制
+func NewSQLiteProvider(file string, queryTimeout time.Duration) (spi.Provider, error) {
+	if file == "" {
+		return nil, errors.New("SQLite file is required")
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)", file, sqliteBusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// SQLite 同一时间只允许一个写入者，使用单个连接串行执行语句，避免 SQLITE_BUSY 错误
+	db.SetMaxOpenConns(1)
+
+	engine := &sqliteEngine{db: db, queryTimeout: queryTimeout}
+	ctx, cancel := engine.bound()
+	defer cancel()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS entries (key BLOB PRIMARY KEY, value BLOB NOT NULL) WITHOUT ROWID"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create SQLite schema: %w", err)
+	}
+	return newKVProvider(engine), nil
+}
+
+type sqliteEngine struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (e *sqliteEngine) bound() (context.Context, context.CancelFunc) {
+	if e.queryTimeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), e.queryTimeout)
+}
+
+func (e *sqliteEngine) get(key []byte) ([]byte, error) {
+	ctx, cancel := e.bound()
+	defer cancel()
+
+	var value []byte
+	err := e.db.QueryRowContext(ctx, "SELECT value FROM entries WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, spi.ErrDataNotFound
+	}
+	return value, err
+}
+
+func (e *sqliteEngine) write(puts map[string][]byte, deletes []string) error {
+	ctx, cancel := e.bound()
+	defer cancel()
+
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, key := range deletes {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM entries WHERE key = ?", []byte(key)); err != nil {
+			return err
+		}
+	}
+	for key, value := range puts {
+		if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO entries (key, value) VALUES (?, ?)", []byte(key), value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (e *sqliteEngine) scan(prefix []byte, fn func(key, value []byte) error) error {
+	ctx, cancel := e.bound()
+	defer cancel()
+
+	// BLOB 以 memcmp 比较，前缀范围为 [prefix, 最后一个字节加一后的 prefix)
+	end := append([]byte(nil), prefix...)
+	end[len(end)-1]++
+
+	rows, err := e.db.QueryContext(ctx, "SELECT key, value FROM entries WHERE key >= ? AND key < ? ORDER BY key", prefix, end)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (e *sqliteEngine) close() error {
+	return e.db.Close()
+}
@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 导出批次和队列限制
+const (
+	exportBatchSize = 512
+	exportQueueSize = 4096
+	exportInterval  = 5 * time.Second
+	exportTimeout   = 10 * time.Second
+)
+
+// Exporter 以 OTLP/HTTP JSON 格式批量上报跨度，队列满时丢弃新的跨度
+type Exporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+
+	queue chan *Span
+	flush chan chan struct{}
+	once  sync.Once
+}
+
+// NewExporter 创建导出器，endpoint 为接收端的基础地址，跨度发往 endpoint/v1/traces
+func NewExporter(endpoint, serviceName string) (*Exporter, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %s", endpoint)
+	}
+
+	e := &Exporter{
+		url:         strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: exportTimeout},
+		queue:       make(chan *Span, exportQueueSize),
+		flush:       make(chan chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// export 将结束的跨度放入队列
+func (e *Exporter) export(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+	}
+}
+
+// Shutdown 导出队列中剩余的跨度并停止后台协程
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	var flushed chan struct{}
+	e.once.Do(func() {
+		flushed = make(chan struct{})
+		select {
+		case e.flush <- flushed:
+		case <-ctx.Done():
+		}
+	})
+	if flushed == nil {
+		return nil
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, exportBatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			slog.Warn("Failed to export spans", "count", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-e.flush:
+			for drained := false; !drained; {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= exportBatchSize {
+						send()
+					}
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(flushed)
+			return
+		}
+	}
+}
+
+// send 上报一批跨度
+func (e *Exporter) send(spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP JSON 编码，字段名遵循 opentelemetry-proto 的 JSON 映射
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// OTLP 状态码
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+func (e *Exporter) encode(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mutex.Lock()
+		s := otlpSpan{
+			TraceID:           span.context.TraceID.String(),
+			SpanID:            span.context.SpanID.String(),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.attributes),
+			Status:            otlpStatus{Code: otlpStatusOK},
+		}
+		if span.parent.IsValid() {
+			s.ParentSpanID = span.parent.String()
+		}
+		if span.errMessage != "" {
+			s.Status = otlpStatus{Code: otlpStatusError, Message: span.errMessage}
+		}
+		span.mutex.Unlock()
+		encoded = append(encoded, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttributes([]Attr{String("service.name", e.serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/czh0526/game/server"},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+func encodeAttributes(attrs []Attr) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]interface{}
+		switch v := attr.Value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int64:
+			// OTLP JSON 中 64 位整数编码为字符串
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
@@ -0,0 +1,296 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpanKind 跨度类型，取值与 OTLP 一致
+type SpanKind int
+
+// 跨度类型
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// TraceID 16 字节的追踪 ID
+type TraceID [16]byte
+
+// SpanID 8 字节的跨度 ID
+type SpanID [8]byte
+
+// String 返回十六进制表示
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid 全零 ID 无效
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// String 返回十六进制表示
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid 全零 ID 无效
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext 跨度的标识，在进程内通过 context 传递，跨进程通过 traceparent 头传递
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid 追踪 ID 和跨度 ID 都有效
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Attr 跨度属性
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// String 创建字符串属性
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int 创建整数属性
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: int64(value)}
+}
+
+// Bool 创建布尔属性
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Span 一次操作的计时和属性。未采样或未启用追踪时为不记录的跨度，所有方法都可安全调用
+type Span struct {
+	context   SpanContext
+	parent    SpanID
+	name      string
+	kind      SpanKind
+	start     time.Time
+	recording bool
+	tracer    *Tracer
+
+	mutex      sync.Mutex
+	end        time.Time
+	attributes []Attr
+	errMessage string
+	ended      bool
+}
+
+// SpanContext 返回跨度的标识
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetAttributes 添加属性
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mutex.Lock()
+	s.attributes = append(s.attributes, attrs...)
+	s.mutex.Unlock()
+}
+
+// RecordError 将跨度标记为失败，err 为 nil 时不做处理
+func (s *Span) RecordError(err error) {
+	if s == nil || !s.recording || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.errMessage = err.Error()
+	s.mutex.Unlock()
+}
+
+// End 结束跨度并交给导出器，重复调用无效
+func (s *Span) End() {
+	if s == nil || !s.recording {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+
+	s.tracer.exporter.export(s)
+}
+
+// Tracer 按采样率创建跨度并交给导出器
+type Tracer struct {
+	serviceName string
+	sampleRatio float64
+	exporter    *Exporter
+}
+
+var (
+	defaultTracer *Tracer
+	tracerMutex   sync.RWMutex
+)
+
+// SetDefault 设置全局追踪器，为 nil 时关闭追踪
+func SetDefault(tracer *Tracer) {
+	tracerMutex.Lock()
+	defaultTracer = tracer
+	tracerMutex.Unlock()
+}
+
+func currentTracer() *Tracer {
+	tracerMutex.RLock()
+	defer tracerMutex.RUnlock()
+	return defaultTracer
+}
+
+type spanKey struct{}
+
+// ContextWithSpanContext 返回携带远程跨度标识的上下文，用于接续其他进程的追踪
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanContextFromContext 返回上下文中当前跨度的标识
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(spanKey{}).(SpanContext)
+	return sc
+}
+
+// Start 在 ctx 中的跨度之下创建内部跨度，未启用追踪时返回原上下文和不记录的跨度
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return StartKind(ctx, name, SpanKindInternal, attrs...)
+}
+
+// StartKind 创建指定类型的跨度。父跨度未采样时子跨度也不采样，没有父跨度时按采样率决定
+func StartKind(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tracer := currentTracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = tracer.sample(sc.TraceID)
+	}
+
+	span := &Span{
+		context:   sc,
+		name:      name,
+		kind:      kind,
+		start:     time.Now(),
+		recording: sc.Sampled,
+		tracer:    tracer,
+	}
+	if parent.IsValid() {
+		span.parent = parent.SpanID
+	}
+	span.SetAttributes(attrs...)
+
+	return context.WithValue(ctx, spanKey{}, sc), span
+}
+
+// sample 按追踪 ID 的低 8 字节决定是否采样，同一追踪在各服务中的采样结果一致
+func (t *Tracer) sample(traceID TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	if t.sampleRatio <= 0 {
+		return false
+	}
+	value := binary.BigEndian.Uint64(traceID[8:]) >> 11
+	return float64(value) < t.sampleRatio*float64(uint64(1)<<53)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// Config 追踪配置
+type Config struct {
+	// Endpoint OTLP/HTTP 接收端地址，如 http://localhost:4318，为空时不启用追踪
+	Endpoint string
+	// ServiceName 上报的 service.name 资源属性
+	ServiceName string
+	// SampleRatio 根跨度的采样率，0 到 1
+	SampleRatio float64
+}
+
+// DefaultConfig 默认配置：不启用，全部采样
+func DefaultConfig() Config {
+	return Config{
+		ServiceName: "game-server",
+		SampleRatio: 1,
+	}
+}
+
+// Setup 按配置创建追踪器和 OTLP 导出器并设为全局追踪器，返回的函数在退出前导出剩余的跨度。
+// 未配置 Endpoint 时不启用追踪
+func Setup(config Config) (func(context.Context) error, error) {
+	if config.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio must be between 0 and 1: %v", config.SampleRatio)
+	}
+
+	exporter, err := NewExporter(config.Endpoint, config.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	SetDefault(&Tracer{
+		serviceName: config.ServiceName,
+		sampleRatio: config.SampleRatio,
+		exporter:    exporter,
+	})
+	return func(ctx context.Context) error {
+		SetDefault(nil)
+		return exporter.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector 记录收到的 OTLP 跨度的测试接收端
+type collector struct {
+	server *httptest.Server
+	mutex  sync.Mutex
+	spans  []otlpSpan
+	// service 最近一次上报的 service.name
+	service string
+}
+
+func newCollector(t *testing.T) *collector {
+	t.Helper()
+	c := &collector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var request otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, attr := range resourceSpans.Resource.Attributes {
+				if attr.Key == "service.name" {
+					c.service, _ = attr.Value["stringValue"].(string)
+				}
+			}
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				c.spans = append(c.spans, scopeSpans.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+// setup 以 sampleRatio 启用追踪，返回的函数导出剩余的跨度并关闭追踪
+func (c *collector) setup(t *testing.T, sampleRatio float64) func() {
+	t.Helper()
+	shutdown, err := Setup(Config{Endpoint: c.server.URL, ServiceName: "test-service", SampleRatio: sampleRatio})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetDefault(nil) })
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+	}
+}
+
+func (c *collector) exported() []otlpSpan {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]otlpSpan(nil), c.spans...)
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatalf("parsed %+v", sc)
+	}
+	if got := FormatTraceparent(sc); got != value {
+		t.Errorf("FormatTraceparent = %s, want %s", got, value)
+	}
+
+	// 更高版本可以在末尾附加字段
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Errorf("future version rejected: %v", err)
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		"00-xyz92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	for _, value := range invalid {
+		if _, err := ParseTraceparent(value); err == nil {
+			t.Errorf("ParseTraceparent(%q) accepted", value)
+		}
+	}
+}
+
+func TestDisabledTracingIsNoop(t *testing.T) {
+	SetDefault(nil)
+	ctx, span := Start(context.Background(), "noop", String("k", "v"))
+	if span != nil {
+		t.Fatal("span created while tracing is disabled")
+	}
+	// 不记录的跨度上的所有方法都可安全调用
+	span.SetAttributes(Int("n", 1))
+	span.RecordError(errors.New("ignored"))
+	span.End()
+	if span.SpanContext().IsValid() || SpanContextFromContext(ctx).IsValid() {
+		t.Error("disabled tracing produced a span context")
+	}
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if header.Get(TraceparentHeader) != "" {
+		t.Error("traceparent injected without a span")
+	}
+}
+
+func TestSpansAreExportedWithParentsAndAttributes(t *testing.T) {
+	c := newCollector(t)
+	shutdown := c.setup(t, 1)
+
+	ctx, root := Start(context.Background(), "root", String("room", "lobby"), Bool("ok", true))
+	_, child := StartKind(ctx, "child", SpanKindClient, Int("attempt", 2))
+	child.RecordError(errors.New("timeout"))
+	child.End()
+	root.End()
+	root.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if header.Get(TraceparentHeader) != FormatTraceparent(root.SpanContext()) {
+		t.Errorf("injected %q", header.Get(TraceparentHeader))
+	}
+
+	shutdown()
+
+	spans := c.exported()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2 (End is idempotent)", len(spans))
+	}
+	if c.service != "test-service" {
+		t.Errorf("service.name = %q", c.service)
+	}
+	exportedChild, exportedRoot := spans[0], spans[1]
+	if exportedChild.TraceID != exportedRoot.TraceID || exportedChild.ParentSpanID != exportedRoot.SpanID || exportedRoot.ParentSpanID != "" {
+		t.Errorf("child %+v is not linked to root %+v", exportedChild, exportedRoot)
+	}
+	if exportedChild.Kind != SpanKindClient || exportedRoot.Kind != SpanKindInternal {
+		t.Errorf("kinds %d and %d", exportedChild.Kind, exportedRoot.Kind)
+	}
+	if exportedChild.Status.Code != otlpStatusError || exportedChild.Status.Message != "timeout" || exportedRoot.Status.Code != otlpStatusOK {
+		t.Errorf("statuses %+v and %+v", exportedChild.Status, exportedRoot.Status)
+	}
+	if len(exportedChild.Attributes) != 1 || exportedChild.Attributes[0].Value["intValue"] != "2" {
+		t.Errorf("child attributes %+v", exportedChild.Attributes)
+	}
+	if len(exportedRoot.Attributes) != 2 || exportedRoot.Attributes[1].Value["boolValue"] != true {
+		t.Errorf("root attributes %+v", exportedRoot.Attributes)
+	}
+}
+
+func TestUnsampledTraceIsNotRecorded(t *testing.T) {
+	c := newCollector(t)
+	shutdown := c.setup(t, 0)
+
+	ctx, root := Start(context.Background(), "root")
+	if root.SpanContext().Sampled {
+		t.Fatal("root span sampled with a sample ratio of 0")
+	}
+	// 子跨度沿用父跨度的采样结果，仍然传播追踪 ID
+	_, child := Start(ctx, "child")
+	if child.SpanContext().TraceID != root.SpanContext().TraceID || child.SpanContext().Sampled {
+		t.Errorf("child %+v does not follow root %+v", child.SpanContext(), root.SpanContext())
+	}
+	child.End()
+	root.End()
+
+	// 远程父跨度已采样时，即使采样率为 0 也记录
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, sampled := Start(ContextWithSpanContext(context.Background(), remote), "remote child")
+	sampled.End()
+
+	shutdown()
+	spans := c.exported()
+	if len(spans) != 1 || spans[0].Name != "remote child" || spans[0].ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("exported %+v, want only the remotely sampled span", spans)
+	}
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	c := newCollector(t)
+	shutdown := c.setup(t, 1)
+
+	var handlerContext SpanContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerContext = SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	request := httptest.NewRequest(http.MethodPost, "/api/rooms", nil)
+	request.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	shutdown()
+
+	if handlerContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("handler context %+v does not continue the incoming trace", handlerContext)
+	}
+	spans := c.exported()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "POST /api/rooms" || span.Kind != SpanKindServer || span.ParentSpanID != "00f067aa0ba902b7" || span.SpanID != handlerContext.SpanID.String() {
+		t.Errorf("server span %+v", span)
+	}
+	if span.Status.Code != otlpStatusError {
+		t.Errorf("5xx response not recorded as an error: %+v", span.Status)
+	}
+}
+
+func TestSetupValidatesConfig(t *testing.T) {
+	if shutdown, err := Setup(DefaultConfig()); err != nil || currentTracer() != nil {
+		t.Errorf("Setup without an endpoint = %v, tracer %v", err, currentTracer())
+	} else if err := shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if _, err := Setup(Config{Endpoint: "http://localhost:4318", SampleRatio: 1.5}); err == nil {
+		t.Error("sample ratio above 1 accepted")
+	}
+	if _, err := Setup(Config{Endpoint: "localhost:4318", SampleRatio: 1}); err == nil {
+		t.Error("endpoint without a scheme accepted")
+	}
+}
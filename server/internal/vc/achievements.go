@@ -0,0 +1,312 @@
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// achievementStoreName 成就凭证索引的存储名称
+const achievementStoreName = "achievement_credentials"
+
+// achievementTag 成就凭证记录的标签，用于加载全部记录
+const achievementTag = "achievement"
+
+// achievementKey 成就凭证的幂等键
+type achievementKey struct {
+	playerDID   string
+	gameID      string
+	achievement string
+}
+
+func (k achievementKey) storageKey() string {
+	return k.playerDID + "#" + k.gameID + "#" + k.achievement
+}
+
+// achievementRecord 持久化的成就凭证记录，保存凭证本身，重启后仍能返回已颁发的凭证
+type achievementRecord struct {
+	PlayerDID   string               `json:"playerDid"`
+	GameID      string               `json:"gameId"`
+	Achievement string               `json:"achievement"`
+	Credential  *vc.SimpleCredential `json:"credential"`
+}
+
+// achievementStore 已颁发的成就凭证，每个玩家在每个游戏中的每项成就只有一张有效凭证。
+// 颁发期间持有该成就键的锁，同一成就的并发颁发只会签发一次，不同成就的颁发互不阻塞
+type achievementStore struct {
+	store  storage.Store
+	issued map[achievementKey]*vc.SimpleCredential
+	locks  map[achievementKey]*sync.Mutex
+	mutex  sync.Mutex // 保护 issued 和 locks
+}
+
+// openAchievementStore 打开成就凭证存储并加载全部记录
+func openAchievementStore(provider storage.Provider) (*achievementStore, error) {
+	store, err := provider.OpenStore(achievementStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open achievement store: %w", err)
+	}
+
+	iter, err := store.Query(achievementTag)
+	if err != nil {
+		return nil, fmt.Errorf("query achievement credentials: %w", err)
+	}
+	defer iter.Close()
+
+	a := &achievementStore{
+		store:  store,
+		issued: make(map[achievementKey]*vc.SimpleCredential),
+		locks:  make(map[achievementKey]*sync.Mutex),
+	}
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate achievement credentials: %w", err)
+		}
+		if !more {
+			break
+		}
+
+		value, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read achievement credential: %w", err)
+		}
+		var record achievementRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil, fmt.Errorf("unmarshal achievement credential: %w", err)
+		}
+		if record.Credential == nil {
+			continue
+		}
+		key := achievementKey{playerDID: record.PlayerDID, gameID: record.GameID, achievement: record.Achievement}
+		a.issued[key] = record.Credential
+	}
+	return a, nil
+}
+
+// lock 锁定单个成就键，返回解锁函数
+func (a *achievementStore) lock(key achievementKey) func() {
+	a.mutex.Lock()
+	keyMutex, exists := a.locks[key]
+	if !exists {
+		keyMutex = &sync.Mutex{}
+		a.locks[key] = keyMutex
+	}
+	a.mutex.Unlock()
+
+	keyMutex.Lock()
+	return keyMutex.Unlock
+}
+
+// get 返回键对应的凭证，不检查是否仍然有效
+func (a *achievementStore) get(key achievementKey) *vc.SimpleCredential {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.issued[key]
+}
+
+// put 写入键对应的凭证，调用方需持有 a.mutex
+func (a *achievementStore) put(key achievementKey, credential *vc.SimpleCredential) error {
+	data, err := json.Marshal(achievementRecord{
+		PlayerDID:   key.playerDID,
+		GameID:      key.gameID,
+		Achievement: key.achievement,
+		Credential:  credential,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal achievement credential: %w", err)
+	}
+	if err := a.store.Put(key.storageKey(), data, storage.Tag{Name: achievementTag}); err != nil {
+		return fmt.Errorf("save achievement credential: %w", err)
+	}
+	a.issued[key] = credential
+	return nil
+}
+
+// set 写入键对应的凭证
+func (a *achievementStore) set(key achievementKey, credential *vc.SimpleCredential) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.put(key, credential)
+}
+
+// replace 凭证续期后改为跟踪新凭证
+func (a *achievementStore) replace(oldID string, credential *vc.SimpleCredential) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for key, issued := range a.issued {
+		if issued.ID == oldID {
+			return a.put(key, credential)
+		}
+	}
+	return nil
+}
+
+// rebind 凭证被认领到新 DID 后改为按新 DID 跟踪新凭证
+func (a *achievementStore) rebind(oldID string, credential *vc.SimpleCredential, newDID string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for key, issued := range a.issued {
+		if issued.ID != oldID {
+			continue
+		}
+		if err := a.store.Delete(key.storageKey()); err != nil {
+			return fmt.Errorf("delete achievement credential: %w", err)
+		}
+		delete(a.issued, key)
+		key.playerDID = newDID
+		return a.put(key, credential)
+	}
+	return nil
+}
+
+// erase 删除颁发给 DID 的全部成就凭证记录
+func (a *achievementStore) erase(playerDID string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for key := range a.issued {
+		if key.playerDID != playerDID {
+			continue
+		}
+		if err := a.store.Delete(key.storageKey()); err != nil {
+			return fmt.Errorf("delete achievement credential: %w", err)
+		}
+		delete(a.issued, key)
+	}
+	return nil
+}
+
+// credentials 返回全部记录中的凭证
+func (a *achievementStore) credentials() []*vc.SimpleCredential {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	credentials := make([]*vc.SimpleCredential, 0, len(a.issued))
+	for _, credential := range a.issued {
+		credentials = append(credentials, credential)
+	}
+	return credentials
+}
+
+// AchievementCredential 返回玩家在游戏中已获得且仍然有效（未过期、未撤销）的成就凭证
+func (s *SimpleService) AchievementCredential(playerDID, gameID, achievement string) (*vc.SimpleCredential, bool) {
+	credential := s.currentAchievement(achievementKey{playerDID: playerDID, gameID: gameID, achievement: achievement})
+	return credential, credential != nil
+}
+
+// IssueAchievementCredentialWithAttributes 颁发成就凭证并将 attributes 合并到凭证主体的属性中，幂等性与 IssueAchievementCredential 相同
+func (s *SimpleService) IssueAchievementCredentialWithAttributes(ctx context.Context, playerDID, gameID, playerID, achievement string, score int, attributes map[string]interface{}) (*vc.SimpleCredential, error) {
+	subject := achievementSubject(gameID, playerID, achievement, score)
+	for key, value := range attributes {
+		subject.Attributes[key] = value
+	}
+	return s.issueAchievement(ctx, playerDID, subject, false)
+}
+
+// IssueOpenBadgeCredential 颁发 Open Badges 3.0 格式的成就凭证（OpenBadgeCredential），成就名为 badge.Name，
+// attributes 合并到凭证主体的属性中，幂等性与 IssueAchievementCredential 相同
+func (s *SimpleService) IssueOpenBadgeCredential(ctx context.Context, playerDID, gameID, playerID string, badge vc.BadgeAchievement, score int, attributes map[string]interface{}) (*vc.SimpleCredential, error) {
+	subject := achievementSubject(gameID, playerID, badge.Name, score)
+	for key, value := range attributes {
+		subject.Attributes[key] = value
+	}
+	subject.Badge = &badge
+	return s.issueAchievement(ctx, playerDID, subject, false)
+}
+
+// ReissueAchievementCredential 强制重新颁发成就凭证并撤销原有的有效凭证，供管理员修正凭证
+func (s *SimpleService) ReissueAchievementCredential(ctx context.Context, playerDID, gameID, playerID, achievement string, score int) (*vc.SimpleCredential, error) {
+	return s.issueAchievement(ctx, playerDID, achievementSubject(gameID, playerID, achievement, score), true)
+}
+
+// achievementSubject 成就凭证的主体
+func achievementSubject(gameID, playerID, achievement string, score int) vc.CredentialSubject {
+	now := time.Now()
+	return vc.CredentialSubject{
+		PlayerID:    playerID,
+		GameID:      gameID,
+		Achievement: achievement,
+		Score:       score,
+		CompletedAt: &now,
+		Attributes: map[string]interface{}{
+			"difficulty": "normal",
+			"category":   "achievement",
+		},
+	}
+}
+
+// achievementCredentialType 成就凭证的类型，只由 issueAchievement 颁发
+const achievementCredentialType = "AchievementCredential"
+
+// ErrUseAchievementIssuance 成就凭证必须经过防重复颁发的成就路径
+var ErrUseAchievementIssuance = errors.New("achievement credentials must be issued through the achievement path")
+
+// routeAchievement 通用颁发入口收到成就凭证时改走 issueAchievement，玩家已有该成就的有效凭证时返回原凭证。
+// handled 为 false 表示不是成就凭证，由调用方照常颁发。Open Badges 成就需要徽章定义，不能经通用入口颁发
+func (s *SimpleService) routeAchievement(ctx context.Context, playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time) (credential *vc.SimpleCredential, handled bool, err error) {
+	switch credType {
+	case achievementCredentialType:
+	case vc.OpenBadgeCredentialType:
+		return nil, true, ErrUseAchievementIssuance
+	default:
+		return nil, false, nil
+	}
+	if subject.GameID == "" || subject.Achievement == "" {
+		return nil, true, errors.New("achievement credentials require gameId and achievement")
+	}
+	if expiresAt != nil {
+		return nil, true, errors.New("achievement credentials do not expire")
+	}
+	credential, err = s.issueAchievement(ctx, playerDID, subject, false)
+	return credential, true, err
+}
+
+// issueAchievement 颁发成就凭证。已有有效凭证时直接返回它，reissue 为 true 时重新颁发并撤销原凭证
+func (s *SimpleService) issueAchievement(ctx context.Context, playerDID string, subject vc.CredentialSubject, reissue bool) (*vc.SimpleCredential, error) {
+	key := achievementKey{playerDID: playerDID, gameID: subject.GameID, achievement: subject.Achievement}
+
+	unlock := s.achievements.lock(key)
+	defer unlock()
+
+	previous := s.currentAchievement(key)
+	if previous != nil && !reissue {
+		return previous, nil
+	}
+
+	credential, err := s.issueForGame(ctx, subject.GameID, playerDID, achievementCredentialType, subject)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		if err := s.RevokeCredentialContext(ctx, previous.ID); err != nil {
+			return nil, fmt.Errorf("revoke reissued credential: %w", err)
+		}
+	}
+
+	if err := s.achievements.set(key, credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// currentAchievement 返回键对应的有效成就凭证
+func (s *SimpleService) currentAchievement(key achievementKey) *vc.SimpleCredential {
+	credential := s.achievements.get(key)
+	if credential == nil || s.revokedLocally(credential) {
+		return nil
+	}
+	if credential.ExpirationDate != nil && credential.ExpirationDate.Before(time.Now()) {
+		return nil
+	}
+	return credential
+}
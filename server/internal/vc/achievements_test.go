@@ -0,0 +1,180 @@
+package vc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/kms"
+	gamestorage "github.com/czh0526/game/server/internal/storage"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+func TestAchievementCredentialsSurviveRestart(t *testing.T) {
+	ctx := context.Background()
+	provider := gamestorage.NewMemoryProvider()
+	keys, err := kms.NewLocalKMS("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder := newTestPlayerDID(t)
+
+	before, err := NewSimpleServiceWithKeyRing(did.NewSimpleService(), keys, provider)
+	if err != nil {
+		t.Fatalf("NewSimpleServiceWithKeyRing: %v", err)
+	}
+	issued, err := before.IssueAchievementCredential(ctx, holder, "test", "alice", "first-win", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := NewSimpleServiceWithKeyRing(did.NewSimpleService(), keys, provider)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	again, err := after.IssueAchievementCredential(ctx, holder, "test", "alice", "first-win", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != issued.ID {
+		t.Fatalf("achievement issued again after restart: %s, want %s", again.ID, issued.ID)
+	}
+
+	// 恢复的凭证可以按 ID 撤销，撤销后重新颁发
+	if err := after.RevokeCredential(issued.ID); err != nil {
+		t.Fatalf("RevokeCredential: %v", err)
+	}
+	if _, ok := after.AchievementCredential(holder, "test", "first-win"); ok {
+		t.Error("revoked achievement is still current")
+	}
+	reissued, err := after.IssueAchievementCredential(ctx, holder, "test", "alice", "first-win", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reissued.ID == issued.ID {
+		t.Error("revoked achievement was returned instead of reissued")
+	}
+}
+
+func TestIssueAchievementConcurrentlyIssuesOnce(t *testing.T) {
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+
+	ids := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			credential, err := service.IssueAchievementCredential(context.Background(), holder, "test", "alice", "first-win", 100)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids[i] = credential.ID
+		}(i)
+	}
+	wg.Wait()
+
+	for _, id := range ids[1:] {
+		if id != ids[0] {
+			t.Fatalf("concurrent issuance produced %s and %s", ids[0], id)
+		}
+	}
+}
+
+func TestIssueAchievementLocksPerKey(t *testing.T) {
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+
+	// 一项成就颁发中不阻塞其他成就的颁发
+	unlock := service.achievements.lock(achievementKey{playerDID: holder, gameID: "test", achievement: "first-win"})
+	defer unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := service.IssueAchievementCredential(context.Background(), holder, "test", "alice", "level-10", 100)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("issuing another achievement blocked on a held key")
+	}
+}
+
+func TestGenericIssuanceDeduplicatesAchievements(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+
+	issued, err := service.IssueAchievementCredential(ctx, holder, "test", "alice", "first-win", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := vc.CredentialSubject{PlayerID: "alice", GameID: "test", Achievement: "first-win", Score: 100}
+
+	// 通用颁发入口不能绕过去重再颁发一份成就凭证
+	viaServer, err := service.IssueCredentialContext(ctx, holder, "AchievementCredential", subject, nil, vc.ProofTypeDataIntegrity)
+	if err != nil {
+		t.Fatalf("IssueCredentialContext: %v", err)
+	}
+	viaGame, err := service.IssueGameCredentialContext(ctx, "test", holder, "AchievementCredential", subject, nil)
+	if err != nil {
+		t.Fatalf("IssueGameCredentialContext: %v", err)
+	}
+	if viaServer.ID != issued.ID || viaGame.ID != issued.ID {
+		t.Errorf("generic issuance minted %s and %s, want %s", viaServer.ID, viaGame.ID, issued.ID)
+	}
+	if count := len(service.GetPlayerCredentials(holder)); count != 1 {
+		t.Errorf("holder has %d credentials, want 1", count)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	if _, err := service.IssueCredentialContext(ctx, holder, "AchievementCredential", subject, &expires, vc.ProofTypeDataIntegrity); err == nil {
+		t.Error("expiring achievement credential issued")
+	}
+	if _, err := service.IssueCredentialContext(ctx, holder, "AchievementCredential", vc.CredentialSubject{GameID: "test"}, nil, vc.ProofTypeDataIntegrity); err == nil {
+		t.Error("achievement credential issued without an achievement")
+	}
+}
+
+func TestIssuedCredentialProofTypes(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+
+	achievement, err := service.IssueAchievementCredential(ctx, holder, "test", "alice", "first-win", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if achievement.Proof == nil || achievement.Proof.Type != vc.ProofTypeEd25519Signature2020 {
+		t.Fatalf("achievement proof = %+v, want %s", achievement.Proof, vc.ProofTypeEd25519Signature2020)
+	}
+	if valid, message := service.VerifyCredential(achievement); !valid {
+		t.Errorf("achievement credential rejected: %s", message)
+	}
+
+	// Open Badges 上下文未内置，改用 DataIntegrityProof 签名
+	badge := vc.BadgeAchievement{
+		ID:          "https://game.example.com/achievements/dragon-slayer",
+		Name:        "dragon-slayer",
+		Description: "Defeat the dragon",
+		Criteria:    vc.BadgeCriteria{Narrative: "Defeat the dragon boss"},
+	}
+	openBadge, err := service.IssueOpenBadgeCredential(ctx, holder, "test", "alice", badge, 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if openBadge.Proof == nil || openBadge.Proof.Type != vc.ProofTypeDataIntegrity {
+		t.Fatalf("open badge proof = %+v, want %s", openBadge.Proof, vc.ProofTypeDataIntegrity)
+	}
+	if valid, message := service.VerifyCredential(openBadge); !valid {
+		t.Errorf("open badge credential rejected: %s", message)
+	}
+}
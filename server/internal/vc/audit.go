@@ -0,0 +1,38 @@
+package vc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// SetAuditLog 启用审计日志，记录凭证的颁发、验证和撤销。应在处理请求前调用
+func (s *SimpleService) SetAuditLog(auditLog *audit.Log) {
+	s.auditLog = auditLog
+}
+
+// auditIssue 记录一次颁发，成功时对象为凭证 ID，失败时为申请凭证的玩家 DID
+func (s *SimpleService) auditIssue(ctx context.Context, playerDID string, credential *vc.SimpleCredential, err error) {
+	subject := playerDID
+	if err == nil {
+		subject = credential.ID
+	}
+	s.auditLog.Record(ctx, audit.CategoryVC, audit.OpIssue, subject, err)
+}
+
+// verificationError 将验证结果转换为审计记录的失败原因，验证通过时为 nil
+func verificationError(valid bool, message string) error {
+	if valid {
+		return nil
+	}
+	return errors.New(message)
+}
+
+// VerifyCredentialContext 同 VerifyCredential，验证受 ctx 的截止时间和取消约束，并以 ctx 中的调用方记录审计日志
+func (s *SimpleService) VerifyCredentialContext(ctx context.Context, credential *vc.SimpleCredential) (valid bool, message string) {
+	valid, message = s.verifyCredential(ctx, credential)
+	s.auditLog.Record(ctx, audit.CategoryVC, audit.OpVerify, credential.ID, verificationError(valid, message))
+	return valid, message
+}
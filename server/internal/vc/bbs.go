@@ -0,0 +1,150 @@
+package vc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/kms"
+	"github.com/czh0526/game/server/pkg/bbs"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// issuerBBSKeyID BBS+ 签名密钥种子在 KMS 中的数据密钥 ID
+const issuerBBSKeyID = "issuer-bbs"
+
+// bbsKeyFragment 颁发者 BBS+ 密钥的验证方法片段
+const bbsKeyFragment = "#bbs-key-1"
+
+// levelGates BBS+ 凭证签发的等级门槛。BBS+ 不提供范围证明，持有者披露不高于自身等级的某个门槛来证明等级不低于它
+var levelGates = []int{5, 10, 20, 30, 50, 100}
+
+// levelThresholds 返回 level 达到的等级门槛
+func levelThresholds(level int) []int {
+	var thresholds []int
+	for _, gate := range levelGates {
+		if level >= gate {
+			thresholds = append(thresholds, gate)
+		}
+	}
+	return thresholds
+}
+
+// loadBBSKey 由 KMS 的数据密钥派生颁发者的 BBS+ 签名密钥。KMS 只保管 Ed25519 和 P-256 签名密钥，
+// BBS+ 签名需要在进程内使用私钥，因此只有提供数据密钥的本地 KMS 可以签发 BBS+ 凭证，其他后端返回 nil
+func loadBBSKey(keys kms.KeyManager) (*bbs.PrivateKey, error) {
+	dataKeys, ok := keys.(kms.DataKeyProvider)
+	if !ok {
+		slog.Info("BBS+ credentials are disabled: the KMS backend does not provide data keys")
+		return nil, nil
+	}
+	seed, err := dataKeys.DataKey(issuerBBSKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("load BBS+ key: %w", err)
+	}
+	return bbs.NewKeyFromSeed(seed)
+}
+
+// bbsPublicKey 返回颁发者的 BBS+ 公钥，未启用时为 nil
+func (s *SimpleService) bbsPublicKey() *bbs.PublicKey {
+	if s.bbsKey == nil {
+		return nil
+	}
+	return s.bbsKey.Public()
+}
+
+// signBBS 以服务器颁发者的 BBS+ 密钥为凭证签名，凭证主体附带其等级达到的门槛。游戏颁发者没有 BBS+ 密钥
+func (s *SimpleService) signBBS(credential *vc.SimpleCredential, issuerDID string) error {
+	if s.bbsKey == nil {
+		return errors.New("BBS+ signatures require a KMS backend that provides data keys")
+	}
+	if issuerDID != s.IssuerDID() {
+		return fmt.Errorf("issuer %s has no BBS+ key", issuerDID)
+	}
+	credential.CredentialSubject.LevelAtLeast = levelThresholds(credential.CredentialSubject.Level)
+	if err := vc.SignBBSCredential(credential, s.bbsKey, issuerDID+bbsKeyFragment); err != nil {
+		return fmt.Errorf("sign credential: %w", err)
+	}
+	return nil
+}
+
+// verifyBBSProof 验证 BbsBlsSignature2020 签名或持有者派生的 BbsBlsSignatureProof2020 证明，
+// 本服务器颁发者使用自己的公钥，其他颁发者解析 DID 文档中的 Bls12381G2Key2020 验证方法
+func (s *SimpleService) verifyBBSProof(ctx context.Context, credential *vc.SimpleCredential) error {
+	verificationMethod := credential.Proof.VerificationMethod
+	if !strings.HasPrefix(verificationMethod, credential.Issuer+"#") {
+		return errors.New("verification method does not belong to issuer")
+	}
+
+	publicKey := s.bbsPublicKey()
+	if credential.Issuer != s.IssuerDID() || verificationMethod != credential.Issuer+bbsKeyFragment || publicKey == nil {
+		resolved, err := s.resolveBBSKey(ctx, credential.Issuer, verificationMethod)
+		if err != nil {
+			return fmt.Errorf("resolve verification method: %w", err)
+		}
+		publicKey = resolved
+	}
+
+	var err error
+	if credential.Proof.Type == vc.ProofTypeBbsBlsSignatureProof2020 {
+		if err = vc.VerifyBBSDerivedProof(credential, publicKey, ""); err == nil && credential.Issuer == s.IssuerDID() {
+			err = s.checkDisclosedFrame(credential)
+		}
+	} else {
+		err = vc.VerifyBBSSignature(credential, publicKey)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid proof: %w", err)
+	}
+	return nil
+}
+
+// checkDisclosedFrame 派生证明可以隐藏任意语句，本服务器颁发的凭证须披露与原凭证相同的有效期和撤销状态，
+// 以免持有者隐藏它们绕过过期和撤销检查
+func (s *SimpleService) checkDisclosedFrame(credential *vc.SimpleCredential) error {
+	s.mutex.RLock()
+	original, exists := s.credentials[credential.ID]
+	s.mutex.RUnlock()
+	if !exists {
+		return errors.New("credential not found in registry")
+	}
+
+	sameExpiration := original.ExpirationDate == nil && credential.ExpirationDate == nil ||
+		original.ExpirationDate != nil && credential.ExpirationDate != nil && original.ExpirationDate.Equal(*credential.ExpirationDate)
+	if !sameExpiration || !reflect.DeepEqual(original.CredentialStatus, credential.CredentialStatus) {
+		return errors.New("derived credential must disclose the expiration date and status of the original")
+	}
+	return nil
+}
+
+// resolveBBSKey 解析颁发者 DID 文档中的 BBS+ 验证方法公钥
+func (s *SimpleService) resolveBBSKey(ctx context.Context, issuerDID, verificationMethod string) (*bbs.PublicKey, error) {
+	resolved, err := s.didService.ResolveDIDContext(ctx, issuerDID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, method := range resolved.DIDDoc.VerificationMethod {
+		if method.ID != verificationMethod {
+			continue
+		}
+		publicKey, err := method.CryptoPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		bbsKey, ok := publicKey.(*bbs.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("verification method %s is not a BBS+ key", verificationMethod)
+		}
+		return bbsKey, nil
+	}
+	return nil, fmt.Errorf("verification method not found: %s", verificationMethod)
+}
+
+// isBBSProof 判断证明是否为 BBS+ 签名或派生证明
+func isBBSProof(proof *vc.Proof) bool {
+	return proof != nil && (proof.Type == vc.ProofTypeBbsBlsSignature2020 || proof.Type == vc.ProofTypeBbsBlsSignatureProof2020)
+}
@@ -0,0 +1,67 @@
+package vc
+
+import (
+	"testing"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+func TestIssueAndVerifyBBSCredential(t *testing.T) {
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+	credential, err := service.IssueCredentialWithProof(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 12, Score: 900}, nil, vc.ProofTypeBbsBlsSignature2020)
+	if err != nil {
+		t.Fatalf("IssueCredentialWithProof: %v", err)
+	}
+	if valid, message := service.VerifyCredential(credential); !valid {
+		t.Fatalf("BBS+ credential does not verify: %s", message)
+	}
+
+	derived, err := vc.DeriveBBSCredential(credential, service.bbsPublicKey(), []string{"level"}, "bm9uY2U=")
+	if err != nil {
+		t.Fatalf("DeriveBBSCredential: %v", err)
+	}
+	if valid, message := service.VerifyCredential(derived); !valid {
+		t.Fatalf("derived credential does not verify: %s", message)
+	}
+
+	derived.CredentialSubject.Level = 20
+	if valid, _ := service.VerifyCredential(derived); valid {
+		t.Error("derived credential with a forged level verifies")
+	}
+
+	if err := service.RevokeCredential(credential.ID); err != nil {
+		t.Fatal(err)
+	}
+	derived.CredentialSubject.Level = 12
+	if valid, _ := service.VerifyCredential(derived); valid {
+		t.Error("derived credential verifies after the original was revoked")
+	}
+}
+
+func TestBBSCredentialProvesLevelThreshold(t *testing.T) {
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+	credential, err := service.IssueCredentialWithProof(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 12, Score: 900}, nil, vc.ProofTypeBbsBlsSignature2020)
+	if err != nil {
+		t.Fatalf("IssueCredentialWithProof: %v", err)
+	}
+	if thresholds := credential.CredentialSubject.LevelAtLeast; len(thresholds) != 2 || thresholds[0] != 5 || thresholds[1] != 10 {
+		t.Fatalf("level thresholds = %v, want [5 10]", thresholds)
+	}
+
+	// 只披露等级门槛 10，隐藏等级和分数
+	derived, err := vc.DeriveBBSCredential(credential, service.bbsPublicKey(), []string{"levelAtLeast=10"}, "bm9uY2U=")
+	if err != nil {
+		t.Fatalf("DeriveBBSCredential: %v", err)
+	}
+	if derived.CredentialSubject.Level != 0 || derived.CredentialSubject.Score != 0 {
+		t.Fatalf("derived credential discloses %+v", derived.CredentialSubject)
+	}
+	if valid, message := service.VerifyCredential(derived); !valid {
+		t.Fatalf("derived credential does not verify: %s", message)
+	}
+	if _, err := vc.DeriveBBSCredential(credential, service.bbsPublicKey(), []string{"levelAtLeast=20"}, "bm9uY2U="); err == nil {
+		t.Error("derived a threshold the holder has not reached")
+	}
+}
@@ -0,0 +1,296 @@
+package vc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// claimChallengeTTL 认领挑战值的有效期
+const claimChallengeTTL = 5 * time.Minute
+
+// ClaimChallengeRequest 申请认领挑战值，请求方以新的永久 DID 认证
+type ClaimChallengeRequest struct {
+	FromDID string `json:"fromDid"` // 原来持有凭证的 DID，如访客使用的 did:key
+}
+
+// ClaimChallengeResponse 认领挑战值。原 DID 以 challenge 和 domain 签名出示要认领的凭证
+type ClaimChallengeResponse struct {
+	Challenge string    `json:"challenge"`
+	Domain    string    `json:"domain"` // 新的永久 DID
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ClaimCredentialsRequest 认领凭证请求
+type ClaimCredentialsRequest struct {
+	Challenge    string                 `json:"challenge"`
+	Presentation *vc.SimplePresentation `json:"presentation"`
+}
+
+// ClaimCredentialsResponse 认领结果
+type ClaimCredentialsResponse struct {
+	Credentials []*vc.SimpleCredential `json:"credentials"`
+	Claimed     map[string]string      `json:"claimed"` // 原凭证 ID -> 重新颁发的凭证 ID
+}
+
+// claimChallenge 待使用的认领挑战值
+type claimChallenge struct {
+	fromDID   string
+	toDID     string
+	expiresAt time.Time
+}
+
+// claimStore 认领挑战值和已认领的凭证，同一凭证只能认领一次
+type claimStore struct {
+	challenges map[string]*claimChallenge
+	claimed    map[string]string // 原凭证 ID -> 重新颁发的凭证 ID
+	mutex      sync.Mutex
+}
+
+func newClaimStore() *claimStore {
+	return &claimStore{
+		challenges: make(map[string]*claimChallenge),
+		claimed:    make(map[string]string),
+	}
+}
+
+// issue 为 fromDID 到 toDID 的认领生成一次性挑战值，并清理过期的挑战值
+func (c *claimStore) issue(fromDID, toDID string, now time.Time) (string, time.Time, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, fmt.Errorf("generate claim challenge: %w", err)
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := now.Add(claimChallengeTTL)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, pending := range c.challenges {
+		if now.After(pending.expiresAt) {
+			delete(c.challenges, key)
+		}
+	}
+	c.challenges[challenge] = &claimChallenge{fromDID: fromDID, toDID: toDID, expiresAt: expiresAt}
+	return challenge, expiresAt, nil
+}
+
+// take 取出并作废挑战值，挑战值不存在、已过期或不属于 toDID 时返回 nil
+func (c *claimStore) take(challenge, toDID string, now time.Time) *claimChallenge {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pending, exists := c.challenges[challenge]
+	if !exists || pending.toDID != toDID {
+		return nil
+	}
+	delete(c.challenges, challenge)
+	if now.After(pending.expiresAt) {
+		return nil
+	}
+	return pending
+}
+
+// ClaimCredentials 将颁发给 fromDID 的凭证以 toDID 为主体重新颁发并撤销原凭证。调用方负责确认请求方
+// 同时控制两个 DID；toDID 必须在本服务登记。新凭证沿用原凭证的颁发者、类型、主体属性和过期时间，
+// 只处理本服务颁发且尚未撤销的凭证，返回原凭证 ID 到新凭证的映射。
+// 认领是原子的：先签名全部新凭证，再撤销原凭证并迁移成就记录，任一步失败时恢复已做的修改，
+// 新凭证在全部成功后才保存并通知持有者
+func (s *SimpleService) ClaimCredentials(ctx context.Context, fromDID, toDID string, credentialIDs []string) (claimed map[string]*vc.SimpleCredential, err error) {
+	defer func() { s.auditLog.Record(ctx, audit.CategoryVC, audit.OpClaim, fromDID+" -> "+toDID, err) }()
+
+	if fromDID == toDID {
+		return nil, errors.New("credentials are already held by this DID")
+	}
+	if _, err := s.didService.GetDID(toDID); err != nil {
+		return nil, fmt.Errorf("%s is not registered on this server", toDID)
+	}
+
+	s.claims.mutex.Lock()
+	defer s.claims.mutex.Unlock()
+
+	// 先检查全部凭证，任一凭证不能认领时不做任何修改
+	olds := make([]*vc.SimpleCredential, 0, len(credentialIDs))
+	seen := make(map[string]bool, len(credentialIDs))
+	for _, credentialID := range credentialIDs {
+		if seen[credentialID] {
+			return nil, fmt.Errorf("credential %s is listed more than once", credentialID)
+		}
+		seen[credentialID] = true
+
+		s.mutex.RLock()
+		old, exists := s.credentials[credentialID]
+		s.mutex.RUnlock()
+		switch {
+		case !exists:
+			return nil, fmt.Errorf("credential not found: %s", credentialID)
+		case old.CredentialSubject.ID != fromDID:
+			return nil, fmt.Errorf("credential %s was not issued to %s", credentialID, fromDID)
+		case s.claims.claimed[credentialID] != "":
+			return nil, fmt.Errorf("credential %s was already claimed as %s", credentialID, s.claims.claimed[credentialID])
+		case old.CredentialStatus == nil || s.revokedLocally(old):
+			return nil, fmt.Errorf("credential %s has been revoked or cannot be revoked", credentialID)
+		}
+		if _, _, err := s.localStatusEntry(old.CredentialStatus); err != nil {
+			return nil, err
+		}
+		olds = append(olds, old)
+	}
+
+	// 签名全部新凭证，此时还没有保存，失败时只浪费分配的状态条目
+	reissued := make([]*vc.SimpleCredential, 0, len(olds))
+	for _, old := range olds {
+		subject := old.CredentialSubject
+		subject.ID = toDID
+		proofType := s.proofType
+		if old.Proof != nil {
+			proofType = old.Proof.Type
+		}
+		credential, err := s.signAs(ctx, s.acceptedIssuer(old), toDID, credentialType(old), subject, old.ExpirationDate, proofType)
+		if err != nil {
+			return nil, fmt.Errorf("reissue credential %s: %w", old.ID, err)
+		}
+		reissued = append(reissued, credential)
+	}
+
+	// 撤销原凭证并迁移成就记录，失败时按相反顺序恢复
+	var undo []func()
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+	for i, old := range olds {
+		listID, index, err := s.localStatusEntry(old.CredentialStatus)
+		if err == nil {
+			err = s.status.set(listID, index, true)
+		}
+		if err != nil {
+			rollback()
+			return nil, fmt.Errorf("revoke claimed credential %s: %w", old.ID, err)
+		}
+		undo = append(undo, func() { s.status.set(listID, index, false) })
+
+		if err := s.achievements.rebind(old.ID, reissued[i], toDID); err != nil {
+			rollback()
+			return nil, err
+		}
+		undo = append(undo, func() { s.achievements.rebind(reissued[i].ID, old, fromDID) })
+	}
+
+	claimed = make(map[string]*vc.SimpleCredential, len(olds))
+	for i, old := range olds {
+		credential := reissued[i]
+		s.storeIssued(credential)
+		s.claims.claimed[old.ID] = credential.ID
+		s.auditLog.Record(ctx, audit.CategoryVC, audit.OpRevoke, old.ID, nil)
+		s.auditIssue(ctx, toDID, credential, nil)
+		s.publishIssued(credential, vc.FormatLDPVC, nil)
+		claimed[old.ID] = credential
+	}
+	return claimed, nil
+}
+
+// HandleClaimChallenge 处理 POST /api/vc/claim/challenge，需要以新的永久 DID 认证
+func (s *SimpleService) HandleClaimChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	toDID, ok := didauth.DIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "DID authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	var req ClaimChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !strings.HasPrefix(req.FromDID, "did:") || req.FromDID == toDID {
+		http.Error(w, "fromDid must be another DID", http.StatusBadRequest)
+		return
+	}
+
+	challenge, expiresAt, err := s.claims.issue(req.FromDID, toDID, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ClaimChallengeResponse{Challenge: challenge, Domain: toDID, ExpiresAt: expiresAt})
+}
+
+// HandleClaimCredentials 处理 POST /api/vc/claim：请求以新的永久 DID 认证，表述由原 DID 以挑战值签名，
+// 两者共同证明请求方控制两个 DID。表述中的凭证以新 DID 为主体重新颁发，原凭证撤销
+func (s *SimpleService) HandleClaimCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	toDID, ok := didauth.DIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "DID authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	var req ClaimCredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Challenge == "" || req.Presentation == nil {
+		http.Error(w, "challenge and presentation are required", http.StatusBadRequest)
+		return
+	}
+
+	pending := s.claims.take(req.Challenge, toDID, time.Now())
+	if pending == nil {
+		http.Error(w, "challenge is unknown, expired or issued to another DID", http.StatusBadRequest)
+		return
+	}
+	if req.Presentation.Holder != pending.fromDID {
+		http.Error(w, "presentation must be held by "+pending.fromDID, http.StatusBadRequest)
+		return
+	}
+	if valid, message := s.VerifyPresentationContext(r.Context(), req.Presentation, req.Challenge, toDID); !valid {
+		http.Error(w, "Invalid presentation: "+message, http.StatusBadRequest)
+		return
+	}
+
+	credentialIDs := make([]string, 0, len(req.Presentation.VerifiableCredential))
+	for _, credential := range req.Presentation.VerifiableCredential {
+		credentialIDs = append(credentialIDs, credential.ID)
+	}
+	claimed, err := s.ClaimCredentials(r.Context(), pending.fromDID, toDID, credentialIDs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to claim credentials: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := ClaimCredentialsResponse{
+		Credentials: make([]*vc.SimpleCredential, 0, len(claimed)),
+		Claimed:     make(map[string]string, len(claimed)),
+	}
+	for _, credentialID := range credentialIDs {
+		credential := claimed[credentialID]
+		response.Credentials = append(response.Credentials, credential)
+		response.Claimed[credentialID] = credential.ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
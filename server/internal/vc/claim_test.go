@@ -0,0 +1,76 @@
+package vc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+func TestClaimCredentialsIsAtomic(t *testing.T) {
+	service := newTestService(t)
+	guest := newTestPlayerDID(t)
+	player, _, _ := newTestHolder(t, service)
+
+	var ids []string
+	for level := 1; level <= 2; level++ {
+		credential, err := service.IssueCredential(guest, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: level}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, credential.ID)
+	}
+
+	// 第二个凭证无法重新签名，整个认领失败，第一个凭证不能被撤销或转移
+	broken := *service.credentials[ids[1]]
+	proof := *broken.Proof
+	proof.Type = "UnsupportedSignature"
+	broken.Proof = &proof
+	service.credentials[ids[1]] = &broken
+
+	ctx := context.Background()
+	if _, err := service.ClaimCredentials(ctx, guest, player, ids); err == nil {
+		t.Fatal("claim with an unsignable credential succeeded")
+	}
+	if revoked, err := service.checkStatus(ctx, service.credentials[ids[0]]); err != nil || revoked {
+		t.Errorf("failed claim revoked the first credential: revoked=%v err=%v", revoked, err)
+	}
+	if issued := service.GetPlayerCredentials(player); len(issued) != 0 {
+		t.Errorf("failed claim issued %d credentials", len(issued))
+	}
+
+	// 修复后可以完整认领，原凭证全部撤销
+	broken.Proof = service.credentials[ids[0]].Proof
+	claimed, err := service.ClaimCredentials(ctx, guest, player, ids)
+	if err != nil {
+		t.Fatalf("ClaimCredentials: %v", err)
+	}
+	if len(claimed) != 2 || len(service.GetPlayerCredentials(player)) != 2 {
+		t.Fatalf("claimed %d credentials, want 2", len(claimed))
+	}
+	for _, id := range ids {
+		if revoked, err := service.checkStatus(ctx, service.credentials[id]); err != nil || !revoked {
+			t.Errorf("claimed credential %s not revoked: %v", id, err)
+		}
+	}
+	if _, err := service.ClaimCredentials(ctx, guest, player, ids[:1]); err == nil {
+		t.Error("credential claimed twice")
+	}
+}
+
+func TestClaimCredentialsRejectsDuplicates(t *testing.T) {
+	service := newTestService(t)
+	guest := newTestPlayerDID(t)
+	player, _, _ := newTestHolder(t, service)
+	credential, err := service.IssueCredential(guest, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.ClaimCredentials(context.Background(), guest, player, []string{credential.ID, credential.ID}); err == nil {
+		t.Fatal("duplicate credential IDs accepted")
+	}
+	if issued := service.GetPlayerCredentials(player); len(issued) != 0 {
+		t.Errorf("rejected claim issued %d credentials", len(issued))
+	}
+}
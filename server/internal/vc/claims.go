@@ -0,0 +1,73 @@
+package vc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// ClaimSource 按服务器保存的游戏状态生成玩家自助申请的凭证声明，由游戏服务器实现
+type ClaimSource interface {
+	// CredentialClaims 返回 playerDID 当前可以获得的 credType 凭证主体，玩家不满足条件时返回错误
+	CredentialClaims(ctx context.Context, playerDID, credType string) (vc.CredentialSubject, error)
+}
+
+// selfServiceCredentialTypes 玩家可以通过 /api/vc/issue 和 OIDC4VCI 报价自助申请的凭证类型，
+// 声明全部由 ClaimSource 生成。其他类型只由游戏逻辑或管理接口颁发
+var selfServiceCredentialTypes = []string{"LevelCredential"}
+
+// ErrNotSelfService 凭证类型不能由玩家自助申请
+var ErrNotSelfService = errors.New("credential type can only be issued by the server")
+
+// ErrNoClaimSource 没有设置 ClaimSource，无法为自助申请生成声明
+var ErrNoClaimSource = errors.New("self-service issuance is not available")
+
+// SetClaimSource 设置自助申请凭证的声明来源。应在处理请求前调用
+func (s *SimpleService) SetClaimSource(source ClaimSource) {
+	s.claimSource = source
+}
+
+// isSelfServiceType 判断凭证类型是否可以自助申请
+func isSelfServiceType(credType string) bool {
+	for _, supported := range selfServiceCredentialTypes {
+		if credType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// selfServiceClaims 校验凭证类型可以自助申请，并由 ClaimSource 生成 playerDID 的凭证主体
+func (s *SimpleService) selfServiceClaims(ctx context.Context, playerDID, credType string) (vc.CredentialSubject, error) {
+	if !isSelfServiceType(credType) {
+		return vc.CredentialSubject{}, fmt.Errorf("%w: %s", ErrNotSelfService, credType)
+	}
+	if s.claimSource == nil {
+		return vc.CredentialSubject{}, ErrNoClaimSource
+	}
+	return s.claimSource.CredentialClaims(ctx, playerDID, credType)
+}
+
+// selfServiceStatus 返回自助申请失败时的 HTTP 状态码
+func selfServiceStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotSelfService):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNoClaimSource):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// IssueAdminCredential 颁发管理员指定类型和主体的凭证，使用服务默认的证明类型。
+// 成就凭证按玩家和成就去重，只能通过 IssueAchievementCredential 颁发
+func (s *SimpleService) IssueAdminCredential(ctx context.Context, playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time) (*vc.SimpleCredential, error) {
+	if credType == achievementCredentialType || credType == vc.OpenBadgeCredentialType {
+		return nil, ErrUseAchievementIssuance
+	}
+	return s.IssueCredentialContext(ctx, playerDID, credType, subject, expiresAt, s.proofType)
+}
@@ -0,0 +1,112 @@
+package vc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// levelSource 为所有玩家给出固定等级的 ClaimSource
+type levelSource struct {
+	level int
+}
+
+func (s levelSource) CredentialClaims(_ context.Context, playerDID, credType string) (vc.CredentialSubject, error) {
+	if credType != "LevelCredential" {
+		return vc.CredentialSubject{}, errors.New("unexpected type")
+	}
+	return vc.CredentialSubject{PlayerID: "alice", GameID: "test", Level: s.level}, nil
+}
+
+// issueRequest 以 caller 的身份请求 /api/vc/issue，body 原样发送以便附加客户端声明
+func issueRequest(service *SimpleService, caller string, body map[string]interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/vc/issue", bytes.NewReader(data))
+	if caller != "" {
+		req = req.WithContext(didauth.WithDID(req.Context(), caller))
+	}
+	rec := httptest.NewRecorder()
+	service.HandleIssueCredential(rec, req)
+	return rec
+}
+
+func TestHandleIssueCredentialUsesServerClaims(t *testing.T) {
+	service := newTestService(t)
+	service.SetClaimSource(levelSource{level: 3})
+	holder := newTestPlayerDID(t)
+
+	// 客户端提交的声明被忽略，等级取自游戏状态
+	rec := issueRequest(service, holder, map[string]interface{}{
+		"playerDid":         holder,
+		"type":              "LevelCredential",
+		"credentialSubject": map[string]interface{}{"gameId": "test", "level": 99},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var response IssueCredentialResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if level := response.Credential.CredentialSubject.Level; level != 3 {
+		t.Errorf("issued level %d, want the server's level 3", level)
+	}
+}
+
+func TestHandleIssueCredentialRejectsUnsafeRequests(t *testing.T) {
+	service := newTestService(t)
+	service.SetClaimSource(levelSource{level: 3})
+	holder, other := newTestPlayerDID(t), newTestPlayerDID(t)
+
+	tests := []struct {
+		name   string
+		caller string
+		body   map[string]interface{}
+		want   int
+	}{
+		{"unauthenticated", "", map[string]interface{}{"playerDid": holder, "type": "LevelCredential"}, http.StatusForbidden},
+		{"other player", other, map[string]interface{}{"playerDid": holder, "type": "LevelCredential"}, http.StatusForbidden},
+		{"achievement", holder, map[string]interface{}{"playerDid": holder, "type": "AchievementCredential"}, http.StatusForbidden},
+		{"admin-only type", holder, map[string]interface{}{"playerDid": holder, "type": "GuildMembershipCredential"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := issueRequest(service, tt.caller, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+	if issued := service.GetPlayerCredentials(holder); len(issued) != 0 {
+		t.Errorf("rejected requests issued %d credentials", len(issued))
+	}
+}
+
+func TestHandleIssueCredentialRequiresClaimSource(t *testing.T) {
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+
+	rec := issueRequest(service, holder, map[string]interface{}{"playerDid": holder, "type": "LevelCredential"})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestIssueAdminCredentialRejectsAchievements(t *testing.T) {
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+
+	for _, credType := range []string{"AchievementCredential", vc.OpenBadgeCredentialType} {
+		_, err := service.IssueAdminCredential(context.Background(), holder, credType, vc.CredentialSubject{GameID: "test", Achievement: "first-win"}, nil)
+		if !errors.Is(err, ErrUseAchievementIssuance) {
+			t.Errorf("%s: err = %v, want ErrUseAchievementIssuance", credType, err)
+		}
+	}
+	if _, err := service.IssueAdminCredential(context.Background(), holder, "GuildMembershipCredential", vc.CredentialSubject{GameID: "test"}, nil); err != nil {
+		t.Errorf("admin-only type: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package vc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// SignDocument 用颁发者当前的签名密钥为凭证之外的文档生成证明，document 序列化后不能包含证明本身
+func (s *SimpleService) SignDocument(document interface{}) (*vc.Proof, error) {
+	signer, kid := s.signingMethod()
+	proof, err := vc.SignDocument(document, signer, kid)
+	if err != nil {
+		return nil, fmt.Errorf("sign document: %w", err)
+	}
+	return proof, nil
+}
+
+// EraseSubjectCredentials 撤销并删除颁发给 DID 的所有凭证，返回删除的凭证数。
+// 本服务的状态列表中保留撤销位，玩家此前出示给第三方的凭证验证时显示为已撤销
+func (s *SimpleService) EraseSubjectCredentials(ctx context.Context, subjectDID string) (erased int, err error) {
+	defer func() { s.auditLog.Record(ctx, audit.CategoryVC, audit.OpErase, subjectDID, err) }()
+
+	credentials := s.GetPlayerCredentials(subjectDID)
+	for _, credential := range credentials {
+		if credential.CredentialStatus == nil || s.revokedLocally(credential) {
+			continue
+		}
+		if _, _, err := s.localStatusEntry(credential.CredentialStatus); err != nil {
+			continue
+		}
+		if err := s.RevokeCredentialContext(ctx, credential.ID); err != nil {
+			return 0, fmt.Errorf("revoke credential %s: %w", credential.ID, err)
+		}
+	}
+
+	s.mutex.Lock()
+	for _, credential := range credentials {
+		delete(s.credentials, credential.ID)
+	}
+	s.mutex.Unlock()
+	if err := s.achievements.erase(subjectDID); err != nil {
+		return 0, err
+	}
+	return len(credentials), nil
+}
@@ -0,0 +1,357 @@
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// IssueCredentialProtocol Aries Issue Credential 2.0 协议 URI
+const IssueCredentialProtocol = "https://didcomm.org/issue-credential/2.0"
+
+// Issue Credential 2.0 消息类型
+const (
+	MsgProposeCredential = IssueCredentialProtocol + "/propose-credential"
+	MsgOfferCredential   = IssueCredentialProtocol + "/offer-credential"
+	MsgRequestCredential = IssueCredentialProtocol + "/request-credential"
+	MsgIssueCredential   = IssueCredentialProtocol + "/issue-credential"
+	MsgCredentialAck     = IssueCredentialProtocol + "/ack"
+	MsgProblemReport     = IssueCredentialProtocol + "/problem-report"
+)
+
+// 附件格式：凭证模板与签名后的凭证
+const (
+	attachFormatLDProofDetail = "aries/ld-proof-vc-detail@v1.0"
+	attachFormatLDProofVC     = "aries/ld-proof-vc@v1.0"
+)
+
+// 颁发者一方的交换状态
+const (
+	exchangeOfferSent        = "offer-sent"
+	exchangeCredentialIssued = "credential-issued"
+	exchangeDone             = "done"
+	exchangeAbandoned        = "abandoned"
+)
+
+// credentialExchangeTTL 未完成的凭证交换保留的时间
+const credentialExchangeTTL = time.Hour
+
+// credentialExchange 一次 Issue Credential 交换，以线程 ID 标识
+type credentialExchange struct {
+	threadID     string
+	holderDID    string
+	detail       *credentialDetail
+	state        string
+	credentialID string
+	updatedAt    time.Time
+}
+
+// credentialDetail aries/ld-proof-vc-detail 附件：待签发凭证的模板和证明选项
+type credentialDetail struct {
+	Credential struct {
+		Type              []string             `json:"type"`
+		CredentialSubject vc.CredentialSubject `json:"credentialSubject"`
+		ExpirationDate    *time.Time           `json:"expirationDate,omitempty"`
+	} `json:"credential"`
+	Options struct {
+		ProofType string `json:"proofType,omitempty"`
+	} `json:"options"`
+}
+
+// credentialType 返回模板中 VerifiableCredential 之外的凭证类型
+func (d *credentialDetail) credentialType() string {
+	for _, t := range d.Credential.Type {
+		if t != "VerifiableCredential" {
+			return t
+		}
+	}
+	return ""
+}
+
+// attachmentFormat Issue Credential 2.0 消息体中附件与格式的对应
+type attachmentFormat struct {
+	AttachID string `json:"attach_id"`
+	Format   string `json:"format"`
+}
+
+// protocolBody Issue Credential 和 Present Proof 2.0 的消息体
+type protocolBody struct {
+	GoalCode string             `json:"goal_code,omitempty"`
+	Comment  string             `json:"comment,omitempty"`
+	Formats  []attachmentFormat `json:"formats,omitempty"`
+}
+
+// exchangeStore 凭证交换的内存登记表
+type exchangeStore struct {
+	exchanges map[string]*credentialExchange
+	mutex     sync.Mutex
+}
+
+// EnableDIDComm 在 DIDComm 中继上以颁发者 DID 处理 Issue Credential 2.0 和 Present Proof 2.0 消息，
+// 已连接的钱包可以通过 propose/offer/request/issue 消息领取凭证。凭证只按服务器发出的报价签发：
+// 持有者的提议只能申请自助类型，声明由 ClaimSource 生成。消息发送方由中继认证（/didcomm 要求
+// authcrypt 且 skid 属于 from DID，WebSocket 使用连接认证的 DID），凭证只颁发给该 DID
+func (s *SimpleService) EnableDIDComm(relay *aries.DIDCommService, sender aries.MessageSender) {
+	s.mutex.Lock()
+	s.didcommSender = sender
+	s.exchanges = &exchangeStore{exchanges: make(map[string]*credentialExchange)}
+	s.proofRequests = &proofRequestStore{requests: make(map[string]*proofRequest)}
+	s.mutex.Unlock()
+
+	relay.HandleProtocol(IssueCredentialProtocol, s.IssuerDID(), s.handleIssueCredentialMessage)
+	relay.HandleProtocol(PresentProofProtocol, s.IssuerDID(), s.handlePresentProofMessage)
+}
+
+// OfferCredential 由颁发者发起凭证交换，向持有者发送 offer-credential
+func (s *SimpleService) OfferCredential(ctx context.Context, holderDID, credType string, subject vc.CredentialSubject) (string, error) {
+	if s.didcommSender == nil {
+		return "", errors.New("DIDComm issuance is not enabled")
+	}
+
+	detail := &credentialDetail{}
+	detail.Credential.Type = []string{"VerifiableCredential", credType}
+	detail.Credential.CredentialSubject = subject
+
+	threadID := uuid.New().String()
+	if err := s.sendOffer(ctx, threadID, holderDID, detail); err != nil {
+		return "", err
+	}
+	return threadID, nil
+}
+
+// handleIssueCredentialMessage 按消息类型推进凭证交换
+func (s *SimpleService) handleIssueCredentialMessage(ctx context.Context, msg *aries.Message) error {
+	if msg.From == "" {
+		return errors.New("issue-credential messages must name the sender")
+	}
+	threadID := msg.ThreadID
+	if threadID == "" {
+		threadID = msg.ID
+	}
+
+	switch msg.Type {
+	case MsgProposeCredential:
+		detail, err := s.proposedDetail(ctx, msg)
+		if err != nil {
+			return s.sendProblemReport(ctx, threadID, msg.From, err)
+		}
+		return s.sendOffer(ctx, threadID, msg.From, detail)
+	case MsgRequestCredential:
+		return s.issueRequested(ctx, threadID, msg)
+	case MsgCredentialAck:
+		s.finishExchange(threadID, msg.From, exchangeDone)
+		return nil
+	case MsgProblemReport:
+		s.finishExchange(threadID, msg.From, exchangeAbandoned)
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", aries.ErrUnsupportedMessage, msg.Type)
+	}
+}
+
+// proposedDetail 由持有者的提议生成报价模板：只采用提议的凭证类型和证明类型，
+// 类型必须可以自助申请，凭证主体由 ClaimSource 按服务器状态生成
+func (s *SimpleService) proposedDetail(ctx context.Context, msg *aries.Message) (*credentialDetail, error) {
+	proposal, err := parseCredentialDetail(msg)
+	if err != nil {
+		return nil, err
+	}
+	credType := proposal.credentialType()
+	if credType == "" {
+		return nil, errors.New("credential type is required")
+	}
+
+	subject, err := s.selfServiceClaims(ctx, msg.From, credType)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &credentialDetail{}
+	detail.Credential.Type = []string{"VerifiableCredential", credType}
+	detail.Credential.CredentialSubject = subject
+	detail.Options.ProofType = proposal.Options.ProofType
+	return detail, nil
+}
+
+// sendOffer 记录交换并向持有者发送 offer-credential
+func (s *SimpleService) sendOffer(ctx context.Context, threadID, holderDID string, detail *credentialDetail) error {
+	if detail.credentialType() == "" {
+		return s.sendProblemReport(ctx, threadID, holderDID, errors.New("credential type is required"))
+	}
+	detail.Credential.CredentialSubject.ID = holderDID
+	if detail.Options.ProofType == "" {
+		detail.Options.ProofType = s.proofType
+	}
+
+	s.exchanges.mutex.Lock()
+	s.exchanges.prune(time.Now())
+	s.exchanges.exchanges[threadID] = &credentialExchange{
+		threadID:  threadID,
+		holderDID: holderDID,
+		detail:    detail,
+		state:     exchangeOfferSent,
+		updatedAt: time.Now(),
+	}
+	s.exchanges.mutex.Unlock()
+
+	return s.sendIssueCredentialMessage(ctx, MsgOfferCredential, threadID, holderDID, attachFormatLDProofDetail, detail)
+}
+
+// issueRequested 处理 request-credential：只签发本线程上服务器报价的模板，没有待处理报价时回复 problem-report，
+// 请求中附带的模板被忽略
+func (s *SimpleService) issueRequested(ctx context.Context, threadID string, msg *aries.Message) error {
+	s.exchanges.mutex.Lock()
+	exchange, exists := s.exchanges.exchanges[threadID]
+	if !exists || exchange.holderDID != msg.From || exchange.state != exchangeOfferSent {
+		s.exchanges.mutex.Unlock()
+		return s.sendProblemReport(ctx, threadID, msg.From, errors.New("no pending offer on this thread"))
+	}
+	// 标记为已颁发，重复的请求不会再次签发
+	exchange.state = exchangeCredentialIssued
+	detail := exchange.detail
+	s.exchanges.mutex.Unlock()
+
+	proofType := detail.Options.ProofType
+	if proofType == "" {
+		proofType = s.proofType
+	}
+	credential, err := s.IssueCredentialContext(ctx, msg.From, detail.credentialType(), detail.Credential.CredentialSubject, detail.Credential.ExpirationDate, proofType)
+	if err != nil {
+		s.finishExchange(threadID, msg.From, exchangeAbandoned)
+		return s.sendProblemReport(ctx, threadID, msg.From, err)
+	}
+
+	s.exchanges.mutex.Lock()
+	s.exchanges.exchanges[threadID] = &credentialExchange{
+		threadID:     threadID,
+		holderDID:    msg.From,
+		detail:       detail,
+		state:        exchangeCredentialIssued,
+		credentialID: credential.ID,
+		updatedAt:    time.Now(),
+	}
+	s.exchanges.mutex.Unlock()
+
+	slog.Info("Issued credential over DIDComm", "credential_id", credential.ID, "holder", msg.From, "thread_id", threadID)
+	return s.sendIssueCredentialMessage(ctx, MsgIssueCredential, threadID, msg.From, attachFormatLDProofVC, credential)
+}
+
+// finishExchange 结束持有者的交换，ack 和 problem-report 都会触发
+func (s *SimpleService) finishExchange(threadID, holderDID, state string) {
+	s.exchanges.mutex.Lock()
+	defer s.exchanges.mutex.Unlock()
+
+	if exchange, exists := s.exchanges.exchanges[threadID]; exists && exchange.holderDID == holderDID {
+		exchange.state = state
+		exchange.updatedAt = time.Now()
+	}
+}
+
+// sendIssueCredentialMessage 发送带单个附件的 Issue Credential 消息
+func (s *SimpleService) sendIssueCredentialMessage(ctx context.Context, msgType, threadID, holderDID, format string, attachment interface{}) error {
+	data, err := json.Marshal(attachment)
+	if err != nil {
+		return fmt.Errorf("marshal attachment: %w", err)
+	}
+	body, err := json.Marshal(protocolBody{
+		Formats: []attachmentFormat{{AttachID: "0", Format: format}},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal body: %w", err)
+	}
+
+	return s.didcommSender.SendDIDComm(ctx, &aries.Message{
+		Type:     msgType,
+		From:     s.IssuerDID(),
+		To:       []string{holderDID},
+		ThreadID: threadID,
+		Body:     body,
+		Attachments: []aries.Attachment{{
+			ID:        "0",
+			MediaType: "application/json",
+			Data:      aries.AttachmentData{JSON: data},
+		}},
+	})
+}
+
+// sendProblemReport 告知持有者交换失败
+func (s *SimpleService) sendProblemReport(ctx context.Context, threadID, holderDID string, cause error) error {
+	body, err := json.Marshal(map[string]string{
+		"code":    "e.p.issuance-abandoned",
+		"comment": cause.Error(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal problem report: %w", err)
+	}
+
+	if err := s.didcommSender.SendDIDComm(ctx, &aries.Message{
+		Type:     MsgProblemReport,
+		From:     s.IssuerDID(),
+		To:       []string{holderDID},
+		ThreadID: threadID,
+		Body:     body,
+	}); err != nil {
+		return err
+	}
+	return cause
+}
+
+// parseCredentialDetail 读取 propose/request 消息中 ld-proof-vc-detail 格式的附件
+func parseCredentialDetail(msg *aries.Message) (*credentialDetail, error) {
+	var body protocolBody
+	if len(msg.Body) > 0 {
+		if err := json.Unmarshal(msg.Body, &body); err != nil {
+			return nil, fmt.Errorf("parse message body: %w", err)
+		}
+	}
+
+	attachID := ""
+	for _, format := range body.Formats {
+		if format.Format == attachFormatLDProofDetail {
+			attachID = format.AttachID
+			break
+		}
+	}
+	if len(body.Formats) > 0 && attachID == "" {
+		return nil, fmt.Errorf("unsupported attachment format, expected %s", attachFormatLDProofDetail)
+	}
+
+	for _, attachment := range msg.Attachments {
+		if attachID != "" && attachment.ID != attachID {
+			continue
+		}
+		if len(attachment.Data.JSON) == 0 {
+			continue
+		}
+
+		var detail credentialDetail
+		if err := json.Unmarshal(attachment.Data.JSON, &detail); err != nil {
+			return nil, fmt.Errorf("parse credential detail: %w", err)
+		}
+		switch detail.Options.ProofType {
+		case "", vc.ProofTypeEd25519Signature2020, vc.ProofTypeEd25519Signature2018, vc.ProofTypeDataIntegrity:
+		default:
+			return nil, fmt.Errorf("unsupported proof type: %s", detail.Options.ProofType)
+		}
+		return &detail, nil
+	}
+	return nil, errors.New("message has no credential detail attachment")
+}
+
+// prune 清理长时间未推进的交换，调用方需持有 mutex
+func (e *exchangeStore) prune(now time.Time) {
+	for threadID, exchange := range e.exchanges {
+		if now.Sub(exchange.updatedAt) > credentialExchangeTTL {
+			delete(e.exchanges, threadID)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// recordingSender 记录颁发者发出的 DIDComm 消息
+type recordingSender struct {
+	messages []*aries.Message
+}
+
+func (r *recordingSender) SendDIDComm(_ context.Context, msg *aries.Message) error {
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *recordingSender) last() *aries.Message {
+	return r.messages[len(r.messages)-1]
+}
+
+func newDIDCommTestService(t *testing.T) (*SimpleService, *recordingSender) {
+	t.Helper()
+	relay, err := aries.NewDIDCommService("http://localhost/didcomm", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := newTestService(t)
+	service.SetClaimSource(levelSource{level: 3})
+	sender := &recordingSender{}
+	service.EnableDIDComm(relay, sender)
+	return service, sender
+}
+
+// detailMessage 构造带 ld-proof-vc-detail 附件的协议消息
+func detailMessage(t *testing.T, msgType, from, threadID string, detail map[string]interface{}) *aries.Message {
+	t.Helper()
+	body, _ := json.Marshal(protocolBody{Formats: []attachmentFormat{{AttachID: "0", Format: attachFormatLDProofDetail}}})
+	data, _ := json.Marshal(detail)
+	return &aries.Message{
+		ID:          threadID,
+		Type:        msgType,
+		From:        from,
+		ThreadID:    threadID,
+		Body:        body,
+		Attachments: []aries.Attachment{{ID: "0", Data: aries.AttachmentData{JSON: data}}},
+	}
+}
+
+func TestDIDCommIssuanceUsesServerOffer(t *testing.T) {
+	service, sender := newDIDCommTestService(t)
+	holder := newTestPlayerDID(t)
+	proposal := map[string]interface{}{
+		"credential": map[string]interface{}{
+			"type":              []string{"VerifiableCredential", "LevelCredential"},
+			"credentialSubject": map[string]interface{}{"gameId": "test", "level": 99},
+		},
+	}
+
+	ctx := context.Background()
+	if err := service.handleIssueCredentialMessage(ctx, detailMessage(t, MsgProposeCredential, holder, "thread-1", proposal)); err != nil {
+		t.Fatalf("propose: %v", err)
+	}
+	if offer := sender.last(); offer.Type != MsgOfferCredential {
+		t.Fatalf("replied %s, want an offer", offer.Type)
+	}
+
+	// 请求中的模板被忽略，签发报价中由服务器生成的声明
+	if err := service.handleIssueCredentialMessage(ctx, detailMessage(t, MsgRequestCredential, holder, "thread-1", proposal)); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	issued := sender.last()
+	if issued.Type != MsgIssueCredential {
+		t.Fatalf("replied %s, want issue-credential", issued.Type)
+	}
+	credentials := service.GetPlayerCredentials(holder)
+	if len(credentials) != 1 || credentials[0].CredentialSubject.Level != 3 {
+		t.Fatalf("issued %+v, want one credential with the server's level 3", credentials)
+	}
+
+	// 报价只能兑现一次
+	if err := service.handleIssueCredentialMessage(ctx, detailMessage(t, MsgRequestCredential, holder, "thread-1", proposal)); err == nil {
+		t.Error("second request on the same offer was accepted")
+	}
+	if len(service.GetPlayerCredentials(holder)) != 1 {
+		t.Error("second request issued another credential")
+	}
+}
+
+func TestDIDCommIssuanceRejectsUnofferedRequests(t *testing.T) {
+	service, sender := newDIDCommTestService(t)
+	holder, other := newTestPlayerDID(t), newTestPlayerDID(t)
+	ctx := context.Background()
+	template := map[string]interface{}{
+		"credential": map[string]interface{}{
+			"type":              []string{"VerifiableCredential", "GuildMembershipCredential"},
+			"credentialSubject": map[string]interface{}{"gameId": "test"},
+		},
+	}
+
+	// 没有报价的请求
+	if err := service.handleIssueCredentialMessage(ctx, detailMessage(t, MsgRequestCredential, holder, "no-offer", template)); err == nil {
+		t.Error("request without an offer was accepted")
+	}
+	if report := sender.last(); report.Type != MsgProblemReport || report.To[0] != holder {
+		t.Errorf("replied %s to %v, want a problem-report to the holder", report.Type, report.To)
+	}
+
+	// 不能自助申请的类型不会得到报价
+	if err := service.handleIssueCredentialMessage(ctx, detailMessage(t, MsgProposeCredential, holder, "admin-type", template)); err == nil {
+		t.Error("proposal for an admin-only type was offered")
+	}
+
+	// 其他 DID 不能兑现发给持有者的报价
+	if _, err := service.OfferCredential(ctx, holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 3}); err != nil {
+		t.Fatal(err)
+	}
+	threadID := sender.last().ThreadID
+	if err := service.handleIssueCredentialMessage(ctx, detailMessage(t, MsgRequestCredential, other, threadID, template)); err == nil {
+		t.Error("another DID redeemed the holder's offer")
+	}
+
+	for _, did := range []string{holder, other} {
+		if issued := service.GetPlayerCredentials(did); len(issued) != 0 {
+			t.Errorf("%s received %d credentials", did, len(issued))
+		}
+	}
+}
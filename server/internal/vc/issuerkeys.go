@@ -0,0 +1,412 @@
+package vc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/kms"
+	"github.com/czh0526/game/server/pkg/bbs"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+)
+
+// issuerKeyStoreName 颁发者签名密钥记录的存储名称
+const issuerKeyStoreName = "issuer_keys"
+
+// issuerKeyTag 颁发者密钥记录的标签，用于加载全部记录
+const issuerKeyTag = "issuer_key"
+
+// DefaultKeyOverlap 轮换后旧密钥继续发布在颁发者 DID 文档中的默认时长
+const DefaultKeyOverlap = 7 * 24 * time.Hour
+
+// issuerKeyClockSkew 按颁发时间选择历史密钥时允许的时钟偏差
+const issuerKeyClockSkew = time.Minute
+
+// IssuerKey 颁发者的一个 Ed25519 签名密钥。轮换后旧密钥停止签名，重叠期内仍发布在 DID 文档中，
+// 之后从文档移除，但仍用于验证它在签名期间颁发的凭证
+type IssuerKey struct {
+	Fragment     string     `json:"fragment"` // 验证方法片段，如 #key-3
+	KMSKeyID     string     `json:"kmsKeyId"`
+	PublicKeyHex string     `json:"publicKeyHex"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	RotatedAt    *time.Time `json:"rotatedAt,omitempty"` // 停止签名的时间，为空表示当前签名密钥
+	RetiresAt    *time.Time `json:"retiresAt,omitempty"` // 重叠期结束、从 DID 文档移除的时间
+	Retired      bool       `json:"retired,omitempty"`
+}
+
+// published 密钥是否仍发布在 DID 文档中
+func (k *IssuerKey) published() bool {
+	return !k.Retired
+}
+
+// signedAt 凭证颁发时该密钥是否为签名密钥
+func (k *IssuerKey) signedAt(issuedAt time.Time) bool {
+	if issuedAt.Before(k.CreatedAt.Add(-issuerKeyClockSkew)) {
+		return false
+	}
+	return k.RotatedAt == nil || !issuedAt.After(k.RotatedAt.Add(issuerKeyClockSkew))
+}
+
+// issuerKeyRing 颁发者签名密钥的轮换记录，按创建时间排序，最后一个为当前签名密钥
+type issuerKeyRing struct {
+	store storage.Store
+	keys  []*IssuerKey
+	mutex sync.Mutex // 串行化轮换和移除操作
+}
+
+// openIssuerKeyRing 加载密钥记录，没有记录时把 KMS 中的 issuerKeyID 作为 #key-1
+func openIssuerKeyRing(provider storage.Provider, keys kms.KeyManager) (*issuerKeyRing, error) {
+	store, err := provider.OpenStore(issuerKeyStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open issuer key store: %w", err)
+	}
+	ring := &issuerKeyRing{store: store}
+
+	iter, err := store.Query(issuerKeyTag)
+	if err != nil {
+		return nil, fmt.Errorf("query issuer keys: %w", err)
+	}
+	defer iter.Close()
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate issuer keys: %w", err)
+		}
+		if !more {
+			break
+		}
+		value, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read issuer key: %w", err)
+		}
+		var key IssuerKey
+		if err := json.Unmarshal(value, &key); err != nil {
+			return nil, fmt.Errorf("decode issuer key: %w", err)
+		}
+		ring.keys = append(ring.keys, &key)
+	}
+	sort.Slice(ring.keys, func(i, j int) bool {
+		return ring.keys[i].CreatedAt.Before(ring.keys[j].CreatedAt)
+	})
+
+	if len(ring.keys) == 0 {
+		publicKey, err := kms.EnsureKey(keys, issuerKeyID, kms.KeyTypeEd25519)
+		if err != nil {
+			return nil, fmt.Errorf("load issuer key: %w", err)
+		}
+		key := &IssuerKey{
+			Fragment:     "#key-1",
+			KMSKeyID:     issuerKeyID,
+			PublicKeyHex: hex.EncodeToString(publicKey.(ed25519.PublicKey)),
+			CreatedAt:    time.Now(),
+		}
+		if err := ring.save(key); err != nil {
+			return nil, err
+		}
+		ring.keys = append(ring.keys, key)
+	}
+	return ring, nil
+}
+
+// active 返回当前签名密钥
+func (r *issuerKeyRing) active() *IssuerKey {
+	return r.keys[len(r.keys)-1]
+}
+
+// find 按验证方法片段查找密钥
+func (r *issuerKeyRing) find(fragment string) *IssuerKey {
+	for _, key := range r.keys {
+		if key.Fragment == fragment {
+			return key
+		}
+	}
+	return nil
+}
+
+// snapshot 复制密钥记录
+func (r *issuerKeyRing) snapshot() []IssuerKey {
+	keys := make([]IssuerKey, len(r.keys))
+	for i, key := range r.keys {
+		keys[i] = *key
+	}
+	return keys
+}
+
+func (r *issuerKeyRing) save(key *IssuerKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal issuer key: %w", err)
+	}
+	if err := r.store.Put(key.Fragment, data, storage.Tag{Name: issuerKeyTag}); err != nil {
+		return fmt.Errorf("store issuer key: %w", err)
+	}
+	return nil
+}
+
+// buildIssuerDID 按密钥环生成颁发者 DID：发布中的 Ed25519 密钥都是认证和断言方法，ES256 #key-2 和 BBS+ #bbs-key-1 只用于断言
+func buildIssuerDID(id string, keys []IssuerKey, es256Key *ecdsa.PublicKey, bbsKey *bbs.PublicKey, createdAt time.Time) *pkgdid.SimpleDID {
+	issuer := &pkgdid.SimpleDID{
+		ID:        id,
+		PublicKey: keys[len(keys)-1].PublicKeyHex,
+		GameID:    "system",
+		PlayerID:  "game-server",
+		CreatedAt: createdAt,
+	}
+
+	// 已移除的历史密钥也计入序号，轮换时不会重用它们的 ID
+	issuer.LastKeyIndex = pkgdid.KeyIndex(es256KeyFragment)
+	for _, key := range keys {
+		if n := pkgdid.KeyIndex(key.Fragment); n > issuer.LastKeyIndex {
+			issuer.LastKeyIndex = n
+		}
+		if !key.published() {
+			continue
+		}
+		keyID := id + key.Fragment
+		issuer.VerificationMethods = append(issuer.VerificationMethods, pkgdid.VerificationMethod{
+			ID:         keyID,
+			Type:       pkgdid.Ed25519VerificationKeyType,
+			Controller: id,
+			PublicKey:  key.PublicKeyHex,
+		})
+		issuer.Authentication = append(issuer.Authentication, keyID)
+		issuer.AssertionMethod = append(issuer.AssertionMethod, keyID)
+	}
+
+	keyID := id + es256KeyFragment
+	issuer.VerificationMethods = append(issuer.VerificationMethods, pkgdid.VerificationMethod{
+		ID:         keyID,
+		Type:       ecdsaP256KeyType,
+		Controller: id,
+		PublicKey:  hex.EncodeToString(elliptic.Marshal(elliptic.P256(), es256Key.X, es256Key.Y)),
+	})
+	issuer.AssertionMethod = append(issuer.AssertionMethod, keyID)
+
+	if bbsKey != nil {
+		keyID = id + bbsKeyFragment
+		issuer.VerificationMethods = append(issuer.VerificationMethods, pkgdid.VerificationMethod{
+			ID:         keyID,
+			Type:       pkgdid.Bls12381G2KeyType,
+			Controller: id,
+			PublicKey:  hex.EncodeToString(bbsKey.Bytes()),
+		})
+		issuer.AssertionMethod = append(issuer.AssertionMethod, keyID)
+	}
+	return issuer
+}
+
+// signingMethod 返回当前的颁发者签名密钥及其验证方法 ID
+func (s *SimpleService) signingMethod() (crypto.Signer, string) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.signingKey, s.issuerDID + s.signingFragment
+}
+
+// IssuerKeys 返回颁发者 Ed25519 签名密钥的轮换记录，最后一个为当前签名密钥
+func (s *SimpleService) IssuerKeys() []IssuerKey {
+	s.keyRing.mutex.Lock()
+	defer s.keyRing.mutex.Unlock()
+
+	return s.keyRing.snapshot()
+}
+
+// RotateIssuerKey 在 KMS 中创建新的 Ed25519 签名密钥并发布到颁发者 DID 文档，之后颁发的凭证使用新密钥签名。
+// 旧密钥在 overlap 内仍保留在 DID 文档中，之后移除；本服务验证凭证时按颁发时间选择对应的历史密钥
+func (s *SimpleService) RotateIssuerKey(overlap time.Duration) (*IssuerKey, error) {
+	if overlap < 0 {
+		return nil, errors.New("overlap must not be negative")
+	}
+
+	s.keyRing.mutex.Lock()
+	defer s.keyRing.mutex.Unlock()
+
+	now := time.Now()
+	kmsKeyID := fmt.Sprintf("%s-%d", issuerKeyID, len(s.keyRing.keys)+1)
+	publicKey, err := kms.EnsureKey(s.keys, kmsKeyID, kms.KeyTypeEd25519)
+	if err != nil {
+		return nil, fmt.Errorf("create issuer key: %w", err)
+	}
+	signer, err := kms.Signer(s.keys, kmsKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("create issuer key: %w", err)
+	}
+	publicKeyHex := hex.EncodeToString(publicKey.(ed25519.PublicKey))
+
+	update := &pkgdid.DIDUpdate{
+		AddKeys: []pkgdid.NewVerificationKey{{
+			PublicKey:     publicKeyHex,
+			Relationships: []string{pkgdid.RelationshipAuthentication, pkgdid.RelationshipAssertionMethod},
+		}},
+		RetireKeys: s.expiredIssuerKeys(now),
+	}
+	updated, err := s.updateIssuerDID(update)
+	if err != nil {
+		return nil, err
+	}
+
+	fragment := ""
+	for _, method := range updated.VerificationMethods {
+		if method.PublicKey == publicKeyHex {
+			fragment = strings.TrimPrefix(method.ID, updated.ID)
+		}
+	}
+	key := &IssuerKey{
+		Fragment:     fragment,
+		KMSKeyID:     kmsKeyID,
+		PublicKeyHex: publicKeyHex,
+		CreatedAt:    now,
+	}
+
+	previous := s.keyRing.active()
+	retiresAt := now.Add(overlap)
+	previous.RotatedAt = &now
+	previous.RetiresAt = &retiresAt
+	if err := s.keyRing.save(previous); err != nil {
+		return nil, err
+	}
+	if err := s.markRetired(update.RetireKeys); err != nil {
+		return nil, err
+	}
+	if err := s.keyRing.save(key); err != nil {
+		return nil, err
+	}
+	s.keyRing.keys = append(s.keyRing.keys, key)
+
+	s.mutex.Lock()
+	s.signingKey = signer
+	s.signingFragment = key.Fragment
+	s.issuer = updated
+	s.mutex.Unlock()
+
+	slog.Info("Rotated issuer key", "issuer", updated.ID, "key", key.Fragment, "previous", previous.Fragment, "retires_at", retiresAt)
+	copied := *key
+	return &copied, nil
+}
+
+// RetireIssuerKeys 将重叠期已结束的旧密钥从颁发者 DID 文档中移除
+func (s *SimpleService) RetireIssuerKeys(now time.Time) error {
+	s.keyRing.mutex.Lock()
+	defer s.keyRing.mutex.Unlock()
+
+	fragments := s.expiredIssuerKeys(now)
+	if len(fragments) == 0 {
+		return nil
+	}
+
+	updated, err := s.updateIssuerDID(&pkgdid.DIDUpdate{RetireKeys: fragments})
+	if err != nil {
+		return err
+	}
+	if err := s.markRetired(fragments); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.issuer = updated
+	s.mutex.Unlock()
+
+	slog.Info("Retired issuer keys", "issuer", updated.ID, "keys", fragments)
+	return nil
+}
+
+// expiredIssuerKeys 返回重叠期已结束但仍发布的密钥片段，调用方需持有密钥环的锁
+func (s *SimpleService) expiredIssuerKeys(now time.Time) []string {
+	var fragments []string
+	for _, key := range s.keyRing.keys {
+		if key.published() && key.RetiresAt != nil && !key.RetiresAt.After(now) {
+			fragments = append(fragments, key.Fragment)
+		}
+	}
+	return fragments
+}
+
+// markRetired 记录密钥已从 DID 文档移除，调用方需持有密钥环的锁
+func (s *SimpleService) markRetired(fragments []string) error {
+	for _, fragment := range fragments {
+		key := s.keyRing.find(fragment)
+		key.Retired = true
+		if err := s.keyRing.save(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateIssuerDID 用当前签名密钥签名并应用颁发者 DID 更新，update 中的 RetireKeys 为片段
+func (s *SimpleService) updateIssuerDID(update *pkgdid.DIDUpdate) (*pkgdid.SimpleDID, error) {
+	signer, signingKeyID := s.signingMethod()
+	issuerDID := s.IssuerDID()
+
+	current, err := s.didService.GetDID(issuerDID)
+	if err != nil {
+		return nil, err
+	}
+	update.DID = issuerDID
+	update.PreviousVersion = current.Version
+	update.SigningKeyID = signingKeyID
+
+	retire := update.RetireKeys
+	update.RetireKeys = make([]string, len(retire))
+	for i, fragment := range retire {
+		update.RetireKeys[i] = issuerDID + fragment
+	}
+	defer func() { update.RetireKeys = retire }()
+
+	payload, err := update.SigningPayload()
+	if err != nil {
+		return nil, fmt.Errorf("marshal issuer DID update: %w", err)
+	}
+	signature, err := signer.Sign(nil, payload, crypto.Hash(0))
+	if err != nil {
+		return nil, fmt.Errorf("sign issuer DID update: %w", err)
+	}
+	update.Signature = hex.EncodeToString(signature)
+
+	updated, err := s.didService.UpdateDID(update)
+	if err != nil {
+		return nil, fmt.Errorf("update issuer DID: %w", err)
+	}
+	return updated, nil
+}
+
+// issuerVerificationKey 按验证方法选择本服务器颁发者的历史签名密钥，issuedAt 必须在该密钥的签名期间内。
+// 不是本服务器颁发者的 Ed25519 密钥时返回 false，由调用方解析 DID 文档
+func (s *SimpleService) issuerVerificationKey(verificationMethod string, issuedAt time.Time) (ed25519.PublicKey, bool, error) {
+	issuerDID := s.IssuerDID()
+	if !strings.HasPrefix(verificationMethod, issuerDID+"#") {
+		return nil, false, nil
+	}
+
+	s.keyRing.mutex.Lock()
+	key := s.keyRing.find(strings.TrimPrefix(verificationMethod, issuerDID))
+	var copied IssuerKey
+	if key != nil {
+		copied = *key
+	}
+	s.keyRing.mutex.Unlock()
+	if key == nil {
+		return nil, false, nil
+	}
+
+	if !copied.signedAt(issuedAt) {
+		return nil, true, fmt.Errorf("credential was not issued while %s was the signing key", verificationMethod)
+	}
+	publicKey, err := hex.DecodeString(copied.PublicKeyHex)
+	if err != nil {
+		return nil, true, fmt.Errorf("decode public key: %w", err)
+	}
+	return ed25519.PublicKey(publicKey), true, nil
+}
@@ -0,0 +1,157 @@
+package vc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/internal/kms"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// gameIssuerPlayerID 游戏颁发者 DID 的最后一段，DID 为 did:player:{gameId}:game-issuer
+const gameIssuerPlayerID = "game-issuer"
+
+// gameIssuerKeyPrefix 游戏颁发者签名密钥在 KMS 中的 ID 前缀，后接游戏 ID
+const gameIssuerKeyPrefix = "issuer-game-"
+
+// gameIssuerFragment 游戏颁发者签名密钥的验证方法片段
+const gameIssuerFragment = "#key-1"
+
+// gameIssuer 游戏自己的颁发者身份，签名密钥由 KMS 保管
+type gameIssuer struct {
+	gameID string
+	did    string
+	signer crypto.Signer
+}
+
+// RegisterGameIssuer 为游戏创建颁发者 DID，该游戏的便捷颁发方法改用它签发凭证。
+// 签名密钥保存在 KMS 中，重启后复用；游戏已有颁发者时直接返回其 DID
+func (s *SimpleService) RegisterGameIssuer(gameID string) (string, error) {
+	if gameID == "" || strings.Contains(gameID, ":") {
+		return "", fmt.Errorf("invalid game ID: %q", gameID)
+	}
+
+	s.mutex.RLock()
+	existing, exists := s.gameIssuers[gameID]
+	s.mutex.RUnlock()
+	if exists {
+		return existing.did, nil
+	}
+
+	keyID := gameIssuerKeyPrefix + gameID
+	if _, err := kms.EnsureKey(s.keys, keyID, kms.KeyTypeEd25519); err != nil {
+		return "", fmt.Errorf("load game issuer key: %w", err)
+	}
+	signer, err := kms.Signer(s.keys, keyID)
+	if err != nil {
+		return "", fmt.Errorf("load game issuer key: %w", err)
+	}
+	publicKey, ok := signer.Public().(ed25519.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("game issuer key is not Ed25519: %s", keyID)
+	}
+
+	issuer := &pkgdid.SimpleDID{
+		ID:        fmt.Sprintf("did:player:%s:%s", gameID, gameIssuerPlayerID),
+		PublicKey: hex.EncodeToString(publicKey),
+		GameID:    gameID,
+		PlayerID:  gameIssuerPlayerID,
+		CreatedAt: time.Now(),
+	}
+	if err := s.didService.RegisterDID(issuer); err != nil {
+		return "", fmt.Errorf("register game issuer DID: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.gameIssuers[gameID] = &gameIssuer{gameID: gameID, did: issuer.ID, signer: signer}
+	s.mutex.Unlock()
+
+	slog.Info("Registered game issuer", "game_id", gameID, "issuer", issuer.ID)
+	return issuer.ID, nil
+}
+
+// IssuerForGame 返回签发该游戏凭证的颁发者 DID，游戏没有自己的颁发者时为服务器颁发者
+func (s *SimpleService) IssuerForGame(gameID string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if issuer, exists := s.gameIssuers[gameID]; exists {
+		return issuer.did
+	}
+	return s.issuerDID
+}
+
+// GameIssuers 返回游戏 ID 到其颁发者 DID 的映射
+func (s *SimpleService) GameIssuers() map[string]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	issuers := make(map[string]string, len(s.gameIssuers))
+	for gameID, issuer := range s.gameIssuers {
+		issuers[gameID] = issuer.did
+	}
+	return issuers
+}
+
+// localIssuer 颁发者 DID 是否为服务器颁发者或某个游戏的颁发者
+func (s *SimpleService) localIssuer(issuerDID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if issuerDID == s.issuerDID {
+		return true
+	}
+	for _, issuer := range s.gameIssuers {
+		if issuer.did == issuerDID {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedIssuer 返回续期和认领时重新签发凭证的颁发者：凭证由本地颁发者签发时为其颁发者，否则为服务器颁发者
+func (s *SimpleService) acceptedIssuer(credential *vc.SimpleCredential) string {
+	if credential != nil && s.localIssuer(credential.Issuer) {
+		return credential.Issuer
+	}
+	return s.IssuerDID()
+}
+
+// issuerSigner 返回本地颁发者的当前签名密钥及其验证方法 ID
+func (s *SimpleService) issuerSigner(issuerDID string) (crypto.Signer, string, error) {
+	if issuerDID == s.IssuerDID() {
+		signer, verificationMethod := s.signingMethod()
+		return signer, verificationMethod, nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, issuer := range s.gameIssuers {
+		if issuer.did == issuerDID {
+			return issuer.signer, issuer.did + gameIssuerFragment, nil
+		}
+	}
+	return nil, "", fmt.Errorf("issuer key unavailable: %s", issuerDID)
+}
+
+// IssueGameCredentialContext 以游戏的颁发者签发凭证，游戏没有自己的颁发者时由服务器颁发者签发。
+// 成就凭证与 IssueAchievementCredential 一样按玩家和成就去重
+func (s *SimpleService) IssueGameCredentialContext(ctx context.Context, gameID, playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time) (*vc.SimpleCredential, error) {
+	if credential, handled, err := s.routeAchievement(ctx, playerDID, credType, subject, expiresAt); handled {
+		return credential, err
+	}
+	return s.issueAs(ctx, s.IssuerForGame(gameID), playerDID, credType, subject, expiresAt, s.proofType)
+}
+
+// issueForGame 以游戏的颁发者签发凭证，游戏没有自己的颁发者时由服务器颁发者签发
+func (s *SimpleService) issueForGame(ctx context.Context, gameID, playerDID, credType string, subject vc.CredentialSubject) (*vc.SimpleCredential, error) {
+	return s.issueAs(ctx, s.IssuerForGame(gameID), playerDID, credType, subject, nil, s.proofType)
+}
@@ -0,0 +1,188 @@
+package vc
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/internal/tracing"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// P-256 验证方法类型，公钥为十六进制编码的未压缩点
+const ecdsaP256KeyType = pkgdid.P256VerificationKeyType
+
+// es256KeyFragment 颁发者 ES256 密钥的验证方法片段
+const es256KeyFragment = "#key-2"
+
+// IssueJWTCredential 颁发 jwt_vc_json 格式的凭证，alg 为 EdDSA（默认）或 ES256
+func (s *SimpleService) IssueJWTCredential(playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time, alg string) (string, *vc.SimpleCredential, error) {
+	return s.issueEncoded(context.Background(), playerDID, credType, subject, expiresAt, alg, vc.FormatJWTVCJSON, vc.EncodeJWTCredential)
+}
+
+// IssueSDJWTCredential 颁发 vc+sd-jwt 格式的凭证，凭证主体的每个字段都可由持有者选择是否披露
+func (s *SimpleService) IssueSDJWTCredential(playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time, alg string) (string, *vc.SimpleCredential, error) {
+	return s.issueEncoded(context.Background(), playerDID, credType, subject, expiresAt, alg, vc.FormatSDJWT, vc.EncodeSDJWTCredential)
+}
+
+// issueEncoded 创建凭证、分配撤销状态并用 encode 生成签名后的 JWT 形式
+func (s *SimpleService) issueEncoded(ctx context.Context, playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time, alg, format string,
+	encode func(*vc.SimpleCredential, crypto.Signer, string) (string, error)) (token string, credential *vc.SimpleCredential, err error) {
+	ctx, span := tracing.Start(ctx, "vc.issue", tracing.String("credential.type", credType), tracing.String("credential.format", format))
+	defer func() {
+		s.auditIssue(ctx, playerDID, credential, err)
+		s.publishIssued(credential, format, err)
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// 成就凭证去重只记录 ldp_vc 凭证，不以 JWT 格式颁发
+	if credType == achievementCredentialType || credType == vc.OpenBadgeCredentialType {
+		return "", nil, ErrUseAchievementIssuance
+	}
+
+	signer, kid, err := s.jwtSigner(alg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 验证玩家DID是否存在
+	if _, err := s.didService.ResolveDIDContext(ctx, playerDID); err != nil {
+		return "", nil, fmt.Errorf("invalid player DID: %w", err)
+	}
+
+	credential, err = vc.IssueCredential(s.issuerDID, playerDID, credType, subject)
+	if err != nil {
+		return "", nil, fmt.Errorf("issue credential: %w", err)
+	}
+	if expiresAt != nil {
+		credential.ExpirationDate = expiresAt
+	}
+	if err := s.assignStatus(credential); err != nil {
+		return "", nil, err
+	}
+
+	token, err = encode(credential, signer, kid)
+	if err != nil {
+		return "", nil, fmt.Errorf("sign credential: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.credentials[credential.ID] = credential
+	s.mutex.Unlock()
+	credentialsIssuedTotal.With(format).Inc()
+
+	return token, credential, nil
+}
+
+// jwtSigner 返回 alg 对应的颁发者私钥和验证方法
+func (s *SimpleService) jwtSigner(alg string) (crypto.Signer, string, error) {
+	switch alg {
+	case "", vc.JWTAlgEdDSA:
+		signer, kid := s.signingMethod()
+		return signer, kid, nil
+	case vc.JWTAlgES256:
+		return s.es256Key, s.issuerDID + es256KeyFragment, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported alg: %s", alg)
+	}
+}
+
+// VerifyJWTCredential 验证 jwt_vc_json 格式的凭证：签名、有效期和撤销状态
+func (s *SimpleService) VerifyJWTCredential(token string) (valid bool, message string) {
+	return s.VerifyJWTCredentialContext(context.Background(), token)
+}
+
+// VerifyJWTCredentialContext 同 VerifyJWTCredential，解析 DID 和获取状态列表在 ctx 取消或到期时中止
+func (s *SimpleService) VerifyJWTCredentialContext(ctx context.Context, token string) (valid bool, message string) {
+	defer func() { observeVerification(vc.FormatJWTVCJSON, valid) }()
+
+	parsed, err := vc.ParseJWTCredential(token)
+	if err != nil {
+		return false, fmt.Sprintf("invalid JWT credential: %v", err)
+	}
+
+	return s.verifySignedCredential(ctx, parsed.Credential, parsed.Header.KID, parsed.Verify)
+}
+
+// VerifySDJWTCredential 验证 vc+sd-jwt 格式的凭证，返回由已披露字段还原的凭证
+func (s *SimpleService) VerifySDJWTCredential(sdJWT string) (credential *vc.SimpleCredential, valid bool, message string) {
+	return s.VerifySDJWTCredentialContext(context.Background(), sdJWT)
+}
+
+// VerifySDJWTCredentialContext 同 VerifySDJWTCredential，解析 DID 和获取状态列表在 ctx 取消或到期时中止
+func (s *SimpleService) VerifySDJWTCredentialContext(ctx context.Context, sdJWT string) (credential *vc.SimpleCredential, valid bool, message string) {
+	defer func() { observeVerification(vc.FormatSDJWT, valid) }()
+
+	parsed, err := vc.ParseSDJWTCredential(sdJWT)
+	if err != nil {
+		return nil, false, fmt.Sprintf("invalid SD-JWT credential: %v", err)
+	}
+
+	valid, message = s.verifySignedCredential(ctx, parsed.Credential, parsed.Header.KID, parsed.Verify)
+	if !valid {
+		return nil, valid, message
+	}
+	return parsed.Credential, true, message
+}
+
+// verifySignedCredential 检查 JWT 形式凭证的颁发者、有效期、签名和撤销状态
+func (s *SimpleService) verifySignedCredential(ctx context.Context, credential *vc.SimpleCredential, kid string, verify func(crypto.PublicKey) error) (bool, string) {
+	if valid, message := s.evaluateTrust(credential, s.localIssuer(credential.Issuer)); !valid {
+		return valid, message
+	}
+	if valid, message := vc.VerifyCredential(credential, credential.Issuer); !valid {
+		return valid, message
+	}
+	if s.subjectDeactivated(ctx, credential) {
+		return false, "credential subject DID has been deactivated"
+	}
+
+	if !strings.HasPrefix(kid, credential.Issuer+"#") {
+		return false, "JWT kid does not belong to the issuer"
+	}
+	var publicKey crypto.PublicKey
+	key, ours, err := s.issuerVerificationKey(kid, credential.IssuanceDate)
+	if ours {
+		publicKey = key
+	} else {
+		publicKey, err = s.resolvePublicKey(ctx, credential.Issuer, kid)
+	}
+	if err != nil {
+		return false, fmt.Sprintf("resolve verification method: %v", err)
+	}
+	if err := verify(publicKey); err != nil {
+		return false, fmt.Sprintf("invalid proof: %v", err)
+	}
+
+	if credential.CredentialStatus != nil {
+		revoked, err := s.checkStatus(ctx, credential)
+		if err != nil {
+			return false, fmt.Sprintf("check credential status: %v", err)
+		}
+		if revoked {
+			return false, "credential has been revoked"
+		}
+	}
+
+	return true, "credential is valid"
+}
+
+// resolvePublicKey 解析颁发者 DID 文档中的验证方法，返回 Ed25519 或 P-256 公钥
+func (s *SimpleService) resolvePublicKey(ctx context.Context, issuerDID, verificationMethod string) (crypto.PublicKey, error) {
+	resolved, err := s.didService.ResolveDIDContext(ctx, issuerDID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, method := range resolved.DIDDoc.VerificationMethod {
+		if method.ID == verificationMethod {
+			return method.CryptoPublicKey()
+		}
+	}
+
+	return nil, fmt.Errorf("verification method not found: %s", verificationMethod)
+}
@@ -0,0 +1,18 @@
+package vc
+
+import "github.com/czh0526/game/server/internal/metrics"
+
+// 凭证颁发和验证指标，format 为 ldp_vc、jwt_vc_json 或 vc+sd-jwt
+var (
+	credentialsIssuedTotal   = metrics.NewCounterVec("vc_issued_total", "Verifiable credentials issued by format.", "format")
+	credentialsVerifiedTotal = metrics.NewCounterVec("vc_verifications_total", "Verifiable credential verifications by format and result.", "format", "result")
+)
+
+// observeVerification 记录一次凭证验证的结果
+func observeVerification(format string, valid bool) {
+	result := "invalid"
+	if valid {
+		result = "valid"
+	}
+	credentialsVerifiedTotal.With(format, result).Inc()
+}
@@ -0,0 +1,547 @@
+package vc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// OIDC4VCI 端点路径
+const (
+	IssuerMetadataPath     = "/.well-known/openid-credential-issuer"
+	credentialOfferPath    = "/api/vc/oidc4vci/offer/"
+	oidcTokenPath          = "/api/vc/oidc4vci/token"
+	oidcCredentialPath     = "/api/vc/oidc4vci/credential"
+	credentialOfferScheme  = "openid-credential-offer://"
+	preAuthorizedGrantType = "urn:ietf:params:oauth:grant-type:pre-authorized_code"
+	proofTypeJWT           = "jwt"
+	proofJWTType           = "openid4vci-proof+jwt"
+	credentialFormatLDP    = "ldp_vc"
+)
+
+// 凭证报价、访问令牌和 c_nonce 的有效期
+const (
+	credentialOfferTTL = 10 * time.Minute
+	accessTokenTTL     = 5 * time.Minute
+	cNonceTTL          = 5 * time.Minute
+	proofMaxAge        = 5 * time.Minute
+)
+
+// OAuth 错误码（RFC 6749 与 OIDC4VCI）
+const (
+	oauthInvalidRequest    = "invalid_request"
+	oauthInvalidGrant      = "invalid_grant"
+	oauthUnsupportedGrant  = "unsupported_grant_type"
+	oauthInvalidToken      = "invalid_token"
+	oauthInvalidProof      = "invalid_proof"
+	oauthUnsupportedType   = "unsupported_credential_type"
+	oauthUnsupportedFormat = "unsupported_credential_format"
+	oauthServerError       = "server_error"
+)
+
+// supportedCredentialTypes 可通过 OIDC4VCI 领取的凭证类型，与自助申请的类型相同
+var supportedCredentialTypes = selfServiceCredentialTypes
+
+// credentialOffer 待领取的凭证，凭预授权码兑换
+type credentialOffer struct {
+	id        string
+	code      string
+	playerDID string
+	credType  string
+	subject   vc.CredentialSubject
+	expiresAt time.Time
+	redeemed  bool
+}
+
+// accessToken 预授权码兑换的访问令牌，只能领取一次凭证
+type accessToken struct {
+	offer     *credentialOffer
+	cNonce    string
+	expiresAt time.Time
+	used      bool
+}
+
+// offerStore 凭证报价和访问令牌的内存登记表
+type offerStore struct {
+	offers map[string]*credentialOffer // 报价ID -> 报价
+	codes  map[string]*credentialOffer // 预授权码 -> 报价
+	tokens map[string]*accessToken
+	mutex  sync.Mutex
+}
+
+func newOfferStore() *offerStore {
+	return &offerStore{
+		offers: make(map[string]*credentialOffer),
+		codes:  make(map[string]*credentialOffer),
+		tokens: make(map[string]*accessToken),
+	}
+}
+
+// prune 清理过期的报价和令牌，调用方需持有 mutex
+func (o *offerStore) prune(now time.Time) {
+	for id, offer := range o.offers {
+		if now.After(offer.expiresAt) {
+			delete(o.offers, id)
+			delete(o.codes, offer.code)
+		}
+	}
+	for value, token := range o.tokens {
+		if now.After(token.expiresAt) {
+			delete(o.tokens, value)
+		}
+	}
+}
+
+// CreateCredentialOfferRequest 创建凭证报价请求
+type CreateCredentialOfferRequest struct {
+	PlayerDID string `json:"playerDid"`
+	Type      string `json:"type"` // 只能是可自助申请的类型，凭证主体由服务器按游戏状态生成
+}
+
+// CredentialOffer OIDC4VCI 凭证报价
+type CredentialOffer struct {
+	CredentialIssuer           string                            `json:"credential_issuer"`
+	CredentialConfigurationIDs []string                          `json:"credential_configuration_ids"`
+	Grants                     map[string]map[string]interface{} `json:"grants"`
+}
+
+// CreateCredentialOfferResponse 创建凭证报价响应
+type CreateCredentialOfferResponse struct {
+	Offer    *CredentialOffer `json:"credential_offer"`
+	OfferURI string           `json:"credential_offer_uri"`
+	// 钱包扫码使用的 openid-credential-offer:// 链接
+	OfferURL  string    `json:"offer_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TokenResponse 令牌端点响应
+type TokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+	CNonce          string `json:"c_nonce"`
+	CNonceExpiresIn int    `json:"c_nonce_expires_in"`
+}
+
+// CredentialRequest 凭证端点请求
+type CredentialRequest struct {
+	Format               string                `json:"format"`
+	CredentialDefinition *CredentialDefinition `json:"credential_definition,omitempty"`
+	Proof                *CredentialProof      `json:"proof"`
+}
+
+// CredentialDefinition 凭证类型定义
+type CredentialDefinition struct {
+	Context []string `json:"@context,omitempty"`
+	Type    []string `json:"type"`
+}
+
+// CredentialProof 持有者密钥证明
+type CredentialProof struct {
+	ProofType string `json:"proof_type"`
+	JWT       string `json:"jwt"`
+}
+
+// CredentialResponse 凭证端点响应
+type CredentialResponse struct {
+	Format          string               `json:"format"`
+	Credential      *vc.SimpleCredential `json:"credential"`
+	CNonce          string               `json:"c_nonce,omitempty"`
+	CNonceExpiresIn int                  `json:"c_nonce_expires_in,omitempty"`
+}
+
+// oauthError OAuth 风格的错误响应
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+	CNonce           string `json:"c_nonce,omitempty"`
+	CNonceExpiresIn  int    `json:"c_nonce_expires_in,omitempty"`
+}
+
+// IssuerMetadata 返回颁发者元数据
+func (s *SimpleService) IssuerMetadata() map[string]interface{} {
+	configurations := make(map[string]interface{}, len(supportedCredentialTypes))
+	for _, credType := range supportedCredentialTypes {
+		configurations[credType] = map[string]interface{}{
+			"format": credentialFormatLDP,
+			"scope":  credType,
+			"cryptographic_binding_methods_supported": []string{"did"},
+			"credential_signing_alg_values_supported": []string{vc.ProofTypeEd25519Signature2020, vc.ProofTypeEd25519Signature2018, vc.CryptosuiteEddsaJcs2022},
+			"proof_types_supported": map[string]interface{}{
+				proofTypeJWT: map[string]interface{}{
+					"proof_signing_alg_values_supported": []string{"EdDSA"},
+				},
+			},
+			"credential_definition": map[string]interface{}{
+				"@context": []string{"https://www.w3.org/2018/credentials/v1"},
+				"type":     []string{"VerifiableCredential", credType},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"credential_issuer":                   s.publicURL,
+		"credential_endpoint":                 s.publicURL + oidcCredentialPath,
+		"token_endpoint":                      s.publicURL + oidcTokenPath,
+		"credential_configurations_supported": configurations,
+	}
+}
+
+// HandleIssuerMetadata 处理 GET /.well-known/openid-credential-issuer
+func (s *SimpleService) HandleIssuerMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.IssuerMetadata())
+}
+
+// CreateCredentialOffer 为玩家创建凭证报价，钱包凭预授权码领取凭证
+func (s *SimpleService) CreateCredentialOffer(ctx context.Context, playerDID, credType string, subject vc.CredentialSubject) (*CreateCredentialOfferResponse, error) {
+	if !isSupportedCredentialType(credType) {
+		return nil, fmt.Errorf("unsupported credential type: %s", credType)
+	}
+	if _, err := s.didService.ResolveDIDContext(ctx, playerDID); err != nil {
+		return nil, fmt.Errorf("invalid player DID: %w", err)
+	}
+
+	offer := &credentialOffer{
+		id:        uuid.New().String(),
+		code:      uuid.New().String(),
+		playerDID: playerDID,
+		credType:  credType,
+		subject:   subject,
+		expiresAt: time.Now().Add(credentialOfferTTL),
+	}
+
+	s.offers.mutex.Lock()
+	s.offers.prune(time.Now())
+	s.offers.offers[offer.id] = offer
+	s.offers.codes[offer.code] = offer
+	s.offers.mutex.Unlock()
+
+	offerURI := s.publicURL + credentialOfferPath + offer.id
+	return &CreateCredentialOfferResponse{
+		Offer:     s.credentialOfferObject(offer),
+		OfferURI:  offerURI,
+		OfferURL:  credentialOfferScheme + "?credential_offer_uri=" + url.QueryEscape(offerURI),
+		ExpiresAt: offer.expiresAt,
+	}, nil
+}
+
+// credentialOfferObject 生成报价的 OIDC4VCI 表示
+func (s *SimpleService) credentialOfferObject(offer *credentialOffer) *CredentialOffer {
+	return &CredentialOffer{
+		CredentialIssuer:           s.publicURL,
+		CredentialConfigurationIDs: []string{offer.credType},
+		Grants: map[string]map[string]interface{}{
+			preAuthorizedGrantType: {
+				"pre-authorized_code": offer.code,
+			},
+		},
+	}
+}
+
+// HandleCreateCredentialOffer 处理 POST /api/vc/oidc4vci/offer
+func (s *SimpleService) HandleCreateCredentialOffer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CreateCredentialOfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.PlayerDID == "" {
+		http.Error(w, "playerDid is required", http.StatusBadRequest)
+		return
+	}
+	if req.Type == "" {
+		http.Error(w, "type is required", http.StatusBadRequest)
+		return
+	}
+	if !authorizedFor(r, req.PlayerDID) {
+		http.Error(w, "credential offers can only be created for the authenticated DID", http.StatusForbidden)
+		return
+	}
+
+	subject, err := s.selfServiceClaims(r.Context(), req.PlayerDID, req.Type)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot offer credential: %v", err), selfServiceStatus(err))
+		return
+	}
+	response, err := s.CreateCredentialOffer(r.Context(), req.PlayerDID, req.Type, subject)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create credential offer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleCredentialOffer 处理 GET /api/vc/oidc4vci/offer/{id}，供钱包通过 credential_offer_uri 获取报价
+func (s *SimpleService) HandleCredentialOffer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	offerID := strings.TrimPrefix(r.URL.Path, credentialOfferPath)
+
+	s.offers.mutex.Lock()
+	offer, exists := s.offers.offers[offerID]
+	s.offers.mutex.Unlock()
+
+	if !exists || offer.redeemed || time.Now().After(offer.expiresAt) {
+		http.Error(w, "Credential offer not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.credentialOfferObject(offer))
+}
+
+// HandleToken 处理 POST /api/vc/oidc4vci/token，用预授权码兑换访问令牌
+func (s *SimpleService) HandleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, fmt.Sprintf("invalid form: %v", err), "")
+		return
+	}
+	if grantType := r.PostForm.Get("grant_type"); grantType != preAuthorizedGrantType {
+		writeOAuthError(w, http.StatusBadRequest, oauthUnsupportedGrant, fmt.Sprintf("unsupported grant type: %s", grantType), "")
+		return
+	}
+
+	code := r.PostForm.Get("pre-authorized_code")
+	now := time.Now()
+
+	s.offers.mutex.Lock()
+	offer, exists := s.offers.codes[code]
+	if !exists || offer.redeemed || now.After(offer.expiresAt) {
+		s.offers.mutex.Unlock()
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidGrant, "pre-authorized code is invalid or expired", "")
+		return
+	}
+
+	// 预授权码只能兑换一次
+	offer.redeemed = true
+	delete(s.offers.codes, code)
+
+	token := &accessToken{
+		offer:     offer,
+		cNonce:    uuid.New().String(),
+		expiresAt: now.Add(accessTokenTTL),
+	}
+	tokenValue := uuid.New().String()
+	s.offers.tokens[tokenValue] = token
+	s.offers.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken:     tokenValue,
+		TokenType:       "Bearer",
+		ExpiresIn:       int(accessTokenTTL.Seconds()),
+		CNonce:          token.cNonce,
+		CNonceExpiresIn: int(cNonceTTL.Seconds()),
+	})
+}
+
+// HandleOIDCCredential 处理 POST /api/vc/oidc4vci/credential，校验持有者证明后颁发凭证
+func (s *SimpleService) HandleOIDCCredential(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokenValue := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.offers.mutex.Lock()
+	token, exists := s.offers.tokens[tokenValue]
+	s.offers.mutex.Unlock()
+
+	if tokenValue == "" || !exists || token.used || time.Now().After(token.expiresAt) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidToken, "access token is invalid or expired", "")
+		return
+	}
+
+	var req CredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidRequest, fmt.Sprintf("invalid request: %v", err), "")
+		return
+	}
+	if req.Format != credentialFormatLDP {
+		writeOAuthError(w, http.StatusBadRequest, oauthUnsupportedFormat, fmt.Sprintf("unsupported format: %s", req.Format), "")
+		return
+	}
+
+	offer := token.offer
+	if req.CredentialDefinition != nil && !containsString(req.CredentialDefinition.Type, offer.credType) {
+		writeOAuthError(w, http.StatusBadRequest, oauthUnsupportedType, fmt.Sprintf("offer is for %s", offer.credType), "")
+		return
+	}
+
+	// 持有者证明失败时返回新的 c_nonce 供钱包重试
+	if err := s.verifyProofJWT(r.Context(), req.Proof, offer.playerDID, token.cNonce); err != nil {
+		cNonce := s.rotateCNonce(token)
+		writeOAuthError(w, http.StatusBadRequest, oauthInvalidProof, err.Error(), cNonce)
+		return
+	}
+
+	s.offers.mutex.Lock()
+	if token.used {
+		s.offers.mutex.Unlock()
+		writeOAuthError(w, http.StatusUnauthorized, oauthInvalidToken, "access token has already been used", "")
+		return
+	}
+	token.used = true
+	delete(s.offers.tokens, tokenValue)
+	delete(s.offers.offers, offer.id)
+	s.offers.mutex.Unlock()
+
+	credential, err := s.IssueCredential(offer.playerDID, offer.credType, offer.subject, nil)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, oauthServerError, fmt.Sprintf("failed to issue credential: %v", err), "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(CredentialResponse{
+		Format:     credentialFormatLDP,
+		Credential: credential,
+	})
+}
+
+// rotateCNonce 生成新的 c_nonce
+func (s *SimpleService) rotateCNonce(token *accessToken) string {
+	s.offers.mutex.Lock()
+	defer s.offers.mutex.Unlock()
+
+	token.cNonce = uuid.New().String()
+	return token.cNonce
+}
+
+// proofJWTHeader 持有者证明 JWT 头部
+type proofJWTHeader struct {
+	Typ string `json:"typ"`
+	Alg string `json:"alg"`
+	KID string `json:"kid"`
+}
+
+// proofJWTClaims 持有者证明 JWT 声明
+type proofJWTClaims struct {
+	Aud   string `json:"aud"`
+	Iat   int64  `json:"iat"`
+	Nonce string `json:"nonce"`
+}
+
+// verifyProofJWT 校验持有者证明：kid 必须属于报价中的玩家 DID，aud 为颁发者，nonce 为当前 c_nonce
+func (s *SimpleService) verifyProofJWT(ctx context.Context, proof *CredentialProof, holderDID, cNonce string) error {
+	if proof == nil || proof.ProofType != proofTypeJWT {
+		return fmt.Errorf("a %s proof is required", proofTypeJWT)
+	}
+
+	parts := strings.Split(proof.JWT, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed proof JWT")
+	}
+
+	var header proofJWTHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return fmt.Errorf("decode proof header: %w", err)
+	}
+	if header.Typ != proofJWTType {
+		return fmt.Errorf("proof typ must be %s", proofJWTType)
+	}
+	if header.Alg != "EdDSA" {
+		return fmt.Errorf("unsupported proof alg: %s", header.Alg)
+	}
+	if !strings.HasPrefix(header.KID, holderDID+"#") {
+		return fmt.Errorf("proof key does not belong to %s", holderDID)
+	}
+
+	var claims proofJWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return fmt.Errorf("decode proof claims: %w", err)
+	}
+	if claims.Aud != s.publicURL {
+		return fmt.Errorf("proof audience must be %s", s.publicURL)
+	}
+	if claims.Nonce != cNonce {
+		return fmt.Errorf("proof nonce does not match c_nonce")
+	}
+	if age := time.Since(time.Unix(claims.Iat, 0)); age > proofMaxAge || age < -time.Minute {
+		return fmt.Errorf("proof iat is out of range")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("decode proof signature: %w", err)
+	}
+
+	publicKey, err := s.resolveVerificationKey(ctx, holderDID, header.KID)
+	if err != nil {
+		return fmt.Errorf("resolve proof key: %w", err)
+	}
+	if !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		return fmt.Errorf("invalid proof signature")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description, cNonce string) {
+	body := oauthError{Error: code, ErrorDescription: description}
+	if cNonce != "" {
+		body.CNonce = cNonce
+		body.CNonceExpiresIn = int(cNonceTTL.Seconds())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func isSupportedCredentialType(credType string) bool {
+	return containsString(supportedCredentialTypes, credType)
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package vc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// VerifyPresentationRequest 验证表述请求，challenge 和 domain 为验证方发给持有者的值，必填
+type VerifyPresentationRequest struct {
+	Presentation *vc.SimplePresentation `json:"presentation"`
+	Challenge    string                 `json:"challenge"`
+	Domain       string                 `json:"domain"`
+}
+
+// VerifyPresentationResponse 验证表述响应
+type VerifyPresentationResponse struct {
+	Valid   bool   `json:"valid"`
+	Message string `json:"message,omitempty"`
+	Holder  string `json:"holder,omitempty"`
+}
+
+// HandleVerifyPresentation 处理验证表述请求
+func (s *SimpleService) HandleVerifyPresentation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req VerifyPresentationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Presentation == nil {
+		http.Error(w, "presentation is required", http.StatusBadRequest)
+		return
+	}
+	if req.Challenge == "" || req.Domain == "" {
+		http.Error(w, "challenge and domain are required", http.StatusBadRequest)
+		return
+	}
+
+	valid, message := s.VerifyPresentationContext(r.Context(), req.Presentation, req.Challenge, req.Domain)
+	for _, credential := range req.Presentation.VerifiableCredential {
+		if credential != nil {
+			s.auditLog.Record(r.Context(), audit.CategoryVC, audit.OpVerify, credential.ID, verificationError(valid, message))
+		}
+	}
+
+	response := VerifyPresentationResponse{
+		Valid:   valid,
+		Message: message,
+		Holder:  req.Presentation.Holder,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// VerifyPresentation 验证表述的持有者证明及其包含的每一个凭证
+func (s *SimpleService) VerifyPresentation(presentation *vc.SimplePresentation, challenge, domain string) (bool, string) {
+	return s.VerifyPresentationContext(context.Background(), presentation, challenge, domain)
+}
+
+// VerifyPresentationContext 同 VerifyPresentation，解析 DID 和获取状态列表在 ctx 取消或到期时中止
+func (s *SimpleService) VerifyPresentationContext(ctx context.Context, presentation *vc.SimplePresentation, challenge, domain string) (bool, string) {
+	if presentation == nil {
+		return false, "presentation is nil"
+	}
+	if presentation.Proof == nil {
+		return false, "presentation has no proof"
+	}
+
+	// 验证方法必须属于持有者
+	if !strings.HasPrefix(presentation.Proof.VerificationMethod, presentation.Holder+"#") {
+		return false, "verification method does not belong to holder"
+	}
+
+	publicKey, err := s.resolveAuthenticationKey(ctx, presentation.Holder, presentation.Proof.VerificationMethod)
+	if err != nil {
+		return false, fmt.Sprintf("resolve holder key: %v", err)
+	}
+
+	if err := vc.VerifyPresentationProof(presentation, publicKey, challenge, domain); err != nil {
+		return false, fmt.Sprintf("invalid presentation proof: %v", err)
+	}
+
+	if len(presentation.VerifiableCredential) == 0 {
+		return false, "presentation contains no credentials"
+	}
+
+	for _, credential := range presentation.VerifiableCredential {
+		if credential == nil {
+			return false, "presentation contains an empty credential"
+		}
+
+		// 持有者只能出示颁发给自己的凭证
+		if credential.CredentialSubject.ID != presentation.Holder {
+			return false, fmt.Sprintf("credential %s is not issued to holder", credential.ID)
+		}
+
+		if valid, message := s.verifyCredential(ctx, credential); !valid {
+			return false, fmt.Sprintf("credential %s: %s", credential.ID, message)
+		}
+	}
+
+	return true, "presentation is valid"
+}
+
+// resolveAuthenticationKey 解析持有者 DID，返回列在 authentication 关系中的验证方法的公钥；
+// 只用于断言的密钥不能代表持有者出示表述
+func (s *SimpleService) resolveAuthenticationKey(ctx context.Context, holderDID, verificationMethod string) (ed25519.PublicKey, error) {
+	resolved, err := s.didService.ResolveDIDContext(ctx, holderDID)
+	if err != nil {
+		return nil, err
+	}
+
+	method, ok := resolved.DIDDoc.AuthenticationKey(verificationMethod)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an authentication key of %s", verificationMethod, holderDID)
+	}
+	publicKey, err := hex.DecodeString(method.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key for %s", verificationMethod)
+	}
+	return ed25519.PublicKey(publicKey), nil
+}
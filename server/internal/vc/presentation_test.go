@@ -0,0 +1,93 @@
+package vc
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// newTestHolder 在服务中注册玩家 DID，#key-1 用于认证，#key-2 只用于断言；返回 DID 和两个私钥
+func newTestHolder(t *testing.T, service *SimpleService) (string, ed25519.PrivateKey, ed25519.PrivateKey) {
+	t.Helper()
+	holder, err := pkgdid.CreatePlayerDID("test", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authKey, _ := hex.DecodeString(holder.PrivateKey)
+
+	assertPublic, assertKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := &pkgdid.DIDUpdate{
+		DID:          holder.ID,
+		AddKeys:      []pkgdid.NewVerificationKey{{PublicKey: hex.EncodeToString(assertPublic), Relationships: []string{pkgdid.RelationshipAssertionMethod}}},
+		SigningKeyID: holder.ID + "#key-1",
+	}
+	if err := update.Sign(holder.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.ApplyUpdate(update); err != nil {
+		t.Fatalf("ApplyUpdate: %v", err)
+	}
+	if err := service.didService.RegisterDID(holder); err != nil {
+		t.Fatalf("RegisterDID: %v", err)
+	}
+	return holder.ID, ed25519.PrivateKey(authKey), assertKey
+}
+
+func newTestPresentation(t *testing.T, service *SimpleService, holder string, key ed25519.PrivateKey, keyID, challenge, domain string) *vc.SimplePresentation {
+	t.Helper()
+	credential, err := service.IssueCredential(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 3}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	presentation, err := vc.CreatePresentation(holder, credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vc.SignPresentation(presentation, key, keyID, vc.ProofTypeDataIntegrity, challenge, domain); err != nil {
+		t.Fatal(err)
+	}
+	return presentation
+}
+
+func TestVerifyPresentationRequiresAuthenticationKey(t *testing.T) {
+	service := newTestService(t)
+	holder, authKey, assertKey := newTestHolder(t, service)
+
+	presentation := newTestPresentation(t, service, holder, authKey, holder+"#key-1", "nonce-1", "room:1")
+	if valid, message := service.VerifyPresentation(presentation, "nonce-1", "room:1"); !valid {
+		t.Fatalf("presentation signed with the authentication key: %s", message)
+	}
+
+	// #key-2 在持有者的 DID 文档中，但只能用于断言
+	presentation = newTestPresentation(t, service, holder, assertKey, holder+"#key-2", "nonce-1", "room:1")
+	if valid, _ := service.VerifyPresentation(presentation, "nonce-1", "room:1"); valid {
+		t.Error("presentation signed with an assertion-only key verified")
+	}
+}
+
+func TestVerifyPresentationRequiresChallengeAndDomain(t *testing.T) {
+	service := newTestService(t)
+	holder, authKey, _ := newTestHolder(t, service)
+	presentation := newTestPresentation(t, service, holder, authKey, holder+"#key-1", "", "")
+
+	if valid, _ := service.VerifyPresentation(presentation, "", ""); valid {
+		t.Error("presentation verified without challenge and domain")
+	}
+
+	body, _ := json.Marshal(VerifyPresentationRequest{Presentation: presentation})
+	rec := httptest.NewRecorder()
+	service.HandleVerifyPresentation(rec, httptest.NewRequest(http.MethodPost, "/api/vp/verify", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
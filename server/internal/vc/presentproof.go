@@ -0,0 +1,339 @@
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/internal/aries"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// PresentProofProtocol Aries Present Proof 2.0 协议 URI
+const PresentProofProtocol = "https://didcomm.org/present-proof/2.0"
+
+// Present Proof 2.0 消息类型
+const (
+	MsgRequestPresentation = PresentProofProtocol + "/request-presentation"
+	MsgPresentation        = PresentProofProtocol + "/presentation"
+	MsgPresentationAck     = PresentProofProtocol + "/ack"
+	MsgPresentProblem      = PresentProofProtocol + "/problem-report"
+)
+
+// attachFormatPEDefinition DIF Presentation Exchange 定义的附件格式
+const attachFormatPEDefinition = "dif/presentation-exchange/definitions@v1.0"
+
+// proofRequestTTL 等待钱包出示表述的时间
+const proofRequestTTL = 2 * time.Minute
+
+// CredentialRequirement 要求持有者出示的一类凭证，Constraints 全部满足的凭证才算出示
+type CredentialRequirement struct {
+	Type        string                `json:"type"`
+	Constraints []AttributeConstraint `json:"constraints,omitempty"`
+}
+
+// AttributeConstraint 凭证主体属性的条件，例如 {"attribute": "level", "minimum": 10}。
+// 属性先查 credentialSubject 的字段，再查 credentialSubject.attributes
+type AttributeConstraint struct {
+	Attribute string      `json:"attribute"`
+	Minimum   *float64    `json:"minimum,omitempty"` // 数值属性的下限（含）
+	Equals    interface{} `json:"equals,omitempty"`  // 属性必须等于该值
+}
+
+// ProofResult Present Proof 交换的结果
+type ProofResult struct {
+	ThreadID  string
+	HolderDID string
+	Verified  bool
+	Message   string
+	// Unsatisfied 未满足的凭证要求，表述本身无效时为空
+	Unsatisfied []CredentialRequirement
+}
+
+// proofRequest 等待钱包响应的表述请求
+type proofRequest struct {
+	holderDID    string
+	challenge    string
+	domain       string
+	requirements []CredentialRequirement
+	expiresAt    time.Time
+	done         func(*ProofResult)
+}
+
+// proofRequestStore 表述请求的内存登记表，以线程 ID 标识
+type proofRequestStore struct {
+	requests map[string]*proofRequest
+	mutex    sync.Mutex
+}
+
+// RequestPresentation 通过 DIDComm 向持有者发送 request-presentation，要求出示满足 requirements 的凭证。
+// 钱包响应并验证后调用 done，超时未响应的请求不会调用 done。返回交换的线程 ID
+func (s *SimpleService) RequestPresentation(ctx context.Context, holderDID, domain string, requirements []CredentialRequirement, done func(*ProofResult)) (string, error) {
+	if s.didcommSender == nil {
+		return "", errors.New("DIDComm is not enabled")
+	}
+	if len(requirements) == 0 {
+		return "", errors.New("at least one credential requirement is required")
+	}
+
+	request := &proofRequest{
+		holderDID:    holderDID,
+		challenge:    uuid.New().String(),
+		domain:       domain,
+		requirements: requirements,
+		expiresAt:    time.Now().Add(proofRequestTTL),
+		done:         done,
+	}
+	threadID := uuid.New().String()
+
+	s.proofRequests.mutex.Lock()
+	now := time.Now()
+	for id, pending := range s.proofRequests.requests {
+		if now.After(pending.expiresAt) {
+			delete(s.proofRequests.requests, id)
+		}
+	}
+	s.proofRequests.requests[threadID] = request
+	s.proofRequests.mutex.Unlock()
+
+	definition, err := json.Marshal(map[string]interface{}{
+		"options":                 map[string]string{"challenge": request.challenge, "domain": domain},
+		"presentation_definition": presentationDefinition(threadID, requirements),
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal presentation definition: %w", err)
+	}
+	body, err := json.Marshal(protocolBody{
+		Formats: []attachmentFormat{{AttachID: "0", Format: attachFormatPEDefinition}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal body: %w", err)
+	}
+
+	err = s.didcommSender.SendDIDComm(ctx, &aries.Message{
+		Type:     MsgRequestPresentation,
+		From:     s.IssuerDID(),
+		To:       []string{holderDID},
+		ThreadID: threadID,
+		Body:     body,
+		Attachments: []aries.Attachment{{
+			ID:        "0",
+			MediaType: "application/json",
+			Data:      aries.AttachmentData{JSON: definition},
+		}},
+	})
+	if err != nil {
+		s.proofRequests.mutex.Lock()
+		delete(s.proofRequests.requests, threadID)
+		s.proofRequests.mutex.Unlock()
+		return "", err
+	}
+	return threadID, nil
+}
+
+// handlePresentProofMessage 处理钱包发来的 presentation 和 problem-report
+func (s *SimpleService) handlePresentProofMessage(ctx context.Context, msg *aries.Message) error {
+	switch msg.Type {
+	case MsgPresentation, MsgPresentProblem:
+	default:
+		return fmt.Errorf("%w: %s", aries.ErrUnsupportedMessage, msg.Type)
+	}
+
+	// 挑战值只能使用一次，收到响应即结束请求
+	s.proofRequests.mutex.Lock()
+	request, exists := s.proofRequests.requests[msg.ThreadID]
+	if exists && request.holderDID == msg.From {
+		delete(s.proofRequests.requests, msg.ThreadID)
+	}
+	s.proofRequests.mutex.Unlock()
+
+	if !exists || request.holderDID != msg.From || time.Now().After(request.expiresAt) {
+		return errors.New("no pending presentation request on this thread")
+	}
+
+	result := &ProofResult{ThreadID: msg.ThreadID, HolderDID: msg.From}
+	if msg.Type == MsgPresentProblem {
+		result.Message = "holder declined the presentation request"
+	} else {
+		s.verifyProof(ctx, request, msg, result)
+	}
+
+	reply := &aries.Message{
+		Type:     MsgPresentationAck,
+		From:     s.IssuerDID(),
+		To:       []string{msg.From},
+		ThreadID: msg.ThreadID,
+		Body:     json.RawMessage(`{"status":"OK"}`),
+	}
+	if !result.Verified {
+		reply.Type = MsgPresentProblem
+		reply.Body, _ = json.Marshal(map[string]string{"code": "e.p.presentation-rejected", "comment": result.Message})
+	}
+	if msg.Type == MsgPresentation {
+		if err := s.didcommSender.SendDIDComm(ctx, reply); err != nil {
+			slog.Warn("Failed to send presentation result", "thread_id", msg.ThreadID, "error", err)
+		}
+	}
+
+	if request.done != nil {
+		request.done(result)
+	}
+	return nil
+}
+
+// verifyProof 验证表述并检查凭证要求，结果写入 result
+func (s *SimpleService) verifyProof(ctx context.Context, request *proofRequest, msg *aries.Message, result *ProofResult) {
+	presentation, err := parsePresentationAttachment(msg)
+	if err != nil {
+		result.Message = err.Error()
+		return
+	}
+	if presentation.Holder != request.holderDID {
+		result.Message = "presentation holder does not match sender DID"
+		return
+	}
+
+	valid, message := s.VerifyPresentationContext(ctx, presentation, request.challenge, request.domain)
+	if !valid {
+		result.Message = message
+		return
+	}
+
+	for _, requirement := range request.requirements {
+		if !satisfies(presentation, requirement) {
+			result.Unsatisfied = append(result.Unsatisfied, requirement)
+		}
+	}
+	if len(result.Unsatisfied) > 0 {
+		types := make([]string, len(result.Unsatisfied))
+		for i, requirement := range result.Unsatisfied {
+			types[i] = requirement.Type
+		}
+		result.Message = fmt.Sprintf("requirements not met: %s", strings.Join(types, ", "))
+		return
+	}
+
+	result.Verified = true
+	result.Message = "presentation is valid"
+}
+
+// parsePresentationAttachment 读取 presentation 消息中的可验证表述附件
+func parsePresentationAttachment(msg *aries.Message) (*vc.SimplePresentation, error) {
+	for _, attachment := range msg.Attachments {
+		if len(attachment.Data.JSON) == 0 {
+			continue
+		}
+		presentation, err := vc.PresentationFromJSON(attachment.Data.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("invalid presentation: %w", err)
+		}
+		return presentation, nil
+	}
+	return nil, errors.New("message has no presentation attachment")
+}
+
+// satisfies 表述中是否有一张该类型的凭证满足全部属性条件
+func satisfies(presentation *vc.SimplePresentation, requirement CredentialRequirement) bool {
+	for _, credential := range presentation.VerifiableCredential {
+		if !hasType(credential, requirement.Type) {
+			continue
+		}
+
+		matched := true
+		for _, constraint := range requirement.Constraints {
+			if !constraint.matches(credential.CredentialSubject) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 检查凭证主体的属性是否满足条件
+func (c AttributeConstraint) matches(subject vc.CredentialSubject) bool {
+	value, exists := subjectAttribute(subject, c.Attribute)
+	if !exists {
+		return false
+	}
+
+	if c.Minimum != nil {
+		number, ok := value.(float64)
+		if !ok || number < *c.Minimum {
+			return false
+		}
+	}
+	if c.Equals != nil && fmt.Sprint(value) != fmt.Sprint(c.Equals) {
+		return false
+	}
+	return true
+}
+
+// subjectAttribute 按 JSON 字段名读取凭证主体属性，数值统一为 float64
+func subjectAttribute(subject vc.CredentialSubject, name string) (interface{}, bool) {
+	data, err := json.Marshal(subject)
+	if err != nil {
+		return nil, false
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, false
+	}
+
+	if value, exists := fields[name]; exists && name != "attributes" {
+		return value, true
+	}
+	if attributes, ok := fields["attributes"].(map[string]interface{}); ok {
+		value, exists := attributes[name]
+		return value, exists
+	}
+	return nil, false
+}
+
+// presentationDefinition 将凭证要求转换为 DIF Presentation Exchange 定义，供钱包选择凭证
+func presentationDefinition(id string, requirements []CredentialRequirement) map[string]interface{} {
+	descriptors := make([]map[string]interface{}, 0, len(requirements))
+	for i, requirement := range requirements {
+		fields := []map[string]interface{}{{
+			"path":   []string{"$.type"},
+			"filter": map[string]interface{}{"type": "array", "contains": map[string]string{"const": requirement.Type}},
+		}}
+		for _, constraint := range requirement.Constraints {
+			filter := map[string]interface{}{}
+			if constraint.Minimum != nil {
+				filter["type"] = "number"
+				filter["minimum"] = *constraint.Minimum
+			}
+			if constraint.Equals != nil {
+				filter["const"] = constraint.Equals
+			}
+			fields = append(fields, map[string]interface{}{
+				"path": []string{
+					"$.credentialSubject." + constraint.Attribute,
+					"$.credentialSubject.attributes." + constraint.Attribute,
+				},
+				"filter": filter,
+			})
+		}
+		descriptors = append(descriptors, map[string]interface{}{
+			"id":          fmt.Sprintf("requirement-%d", i),
+			"name":        requirement.Type,
+			"constraints": map[string]interface{}{"fields": fields},
+		})
+	}
+
+	return map[string]interface{}{
+		"id":                id,
+		"input_descriptors": descriptors,
+	}
+}
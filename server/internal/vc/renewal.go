@@ -0,0 +1,332 @@
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// ExpiryConfig 凭证过期提醒配置
+type ExpiryConfig struct {
+	CheckInterval time.Duration // 扫描即将过期凭证的间隔
+	WarningWindow time.Duration // 凭证在过期前多久提醒持有者
+}
+
+// DefaultExpiryConfig 返回默认过期提醒配置
+func DefaultExpiryConfig() ExpiryConfig {
+	return ExpiryConfig{
+		CheckInterval: time.Minute,
+		WarningWindow: 24 * time.Hour,
+	}
+}
+
+// ExpiryWarning 凭证即将过期的提醒
+type ExpiryWarning struct {
+	CredentialID string    `json:"credentialId"`
+	HolderDID    string    `json:"holderDid"`
+	Type         []string  `json:"type"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// ExpiryNotifier 将过期提醒推送给持有者，持有者不在线时返回 false
+type ExpiryNotifier interface {
+	NotifyCredentialExpiring(warning *ExpiryWarning) bool
+}
+
+// RenewCredentialRequest 续期凭证请求
+type RenewCredentialRequest struct {
+	CredentialID string     `json:"credentialId"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"` // 为空时按原凭证的有效期从现在起顺延
+}
+
+// RenewCredentialResponse 续期凭证响应
+type RenewCredentialResponse struct {
+	Credential  *vc.SimpleCredential `json:"credential"`
+	RenewedFrom string               `json:"renewedFrom"`
+	Chain       []string             `json:"chain"`
+}
+
+// RenewalChainResponse 续期链查询响应
+type RenewalChainResponse struct {
+	CredentialID string   `json:"credentialId"`
+	Chain        []string `json:"chain"` // 从最初颁发的凭证到最新续期的凭证
+}
+
+// renewalStore 凭证续期关系，续期期间持有锁，同一凭证只能续期一次
+type renewalStore struct {
+	previous map[string]string // 新凭证 ID -> 被续期的凭证 ID
+	next     map[string]string // 被续期的凭证 ID -> 新凭证 ID
+	mutex    sync.Mutex
+}
+
+func newRenewalStore() *renewalStore {
+	return &renewalStore{
+		previous: make(map[string]string),
+		next:     make(map[string]string),
+	}
+}
+
+// chain 返回 credentialID 所在的续期链，调用方需持有锁
+func (r *renewalStore) chain(credentialID string) []string {
+	first := credentialID
+	for {
+		previous, exists := r.previous[first]
+		if !exists {
+			break
+		}
+		first = previous
+	}
+
+	chain := []string{first}
+	for id := first; ; {
+		next, exists := r.next[id]
+		if !exists {
+			return chain
+		}
+		chain = append(chain, next)
+		id = next
+	}
+}
+
+// StartExpiryScheduler 定期扫描 WarningWindow 内将要过期的凭证并通过 notifier 提醒持有者，
+// 同时从颁发者 DID 文档移除重叠期已结束的旧签名密钥。
+// 每张凭证只提醒一次，持有者不在线时下次扫描重试；返回的函数停止扫描
+func (s *SimpleService) StartExpiryScheduler(config ExpiryConfig, notifier ExpiryNotifier) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(config.CheckInterval)
+		defer ticker.Stop()
+
+		warned := make(map[string]bool)
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				s.warnExpiring(now, config.WarningWindow, notifier, warned)
+				if err := s.RetireIssuerKeys(now); err != nil {
+					slog.Warn("Failed to retire issuer keys", "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// warnExpiring 提醒一轮即将过期的凭证，已过期的凭证从 warned 中移除
+func (s *SimpleService) warnExpiring(now time.Time, window time.Duration, notifier ExpiryNotifier, warned map[string]bool) {
+	for id := range warned {
+		s.mutex.RLock()
+		credential, exists := s.credentials[id]
+		s.mutex.RUnlock()
+		if !exists || credential.ExpirationDate.Before(now) {
+			delete(warned, id)
+		}
+	}
+
+	for _, credential := range s.expiringCredentials(now, now.Add(window)) {
+		if warned[credential.ID] || s.revokedLocally(credential) {
+			continue
+		}
+
+		warning := &ExpiryWarning{
+			CredentialID: credential.ID,
+			HolderDID:    credential.CredentialSubject.ID,
+			Type:         credential.Type,
+			ExpiresAt:    *credential.ExpirationDate,
+		}
+		if notifier.NotifyCredentialExpiring(warning) {
+			warned[credential.ID] = true
+			slog.Info("Warned holder of expiring credential", "credential_id", credential.ID, "holder", warning.HolderDID, "expires_at", warning.ExpiresAt)
+		}
+	}
+}
+
+// expiringCredentials 返回在 (now, deadline] 内过期的凭证
+func (s *SimpleService) expiringCredentials(now, deadline time.Time) []*vc.SimpleCredential {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var expiring []*vc.SimpleCredential
+	for _, credential := range s.credentials {
+		expiresAt := credential.ExpirationDate
+		if expiresAt != nil && expiresAt.After(now) && !expiresAt.After(deadline) {
+			expiring = append(expiring, credential)
+		}
+	}
+	return expiring
+}
+
+// revokedLocally 凭证在本服务器的状态列表中是否已撤销
+func (s *SimpleService) revokedLocally(credential *vc.SimpleCredential) bool {
+	if credential.CredentialStatus == nil {
+		return false
+	}
+	listID, index, err := s.localStatusEntry(credential.CredentialStatus)
+	if err != nil {
+		return false
+	}
+	revoked, err := s.status.get(listID, index)
+	return err == nil && revoked
+}
+
+// RenewCredential 以相同的类型和主体重新颁发凭证并撤销原凭证，新凭证的过期时间为 expiresAt，
+// 为空时按原凭证的有效期从现在起顺延。已撤销或已续期的凭证不能续期
+func (s *SimpleService) RenewCredential(ctx context.Context, credentialID string, expiresAt *time.Time) (*vc.SimpleCredential, error) {
+	s.mutex.RLock()
+	old, exists := s.credentials[credentialID]
+	s.mutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("credential not found: %s", credentialID)
+	}
+	if old.CredentialStatus == nil {
+		return nil, fmt.Errorf("credential has no status entry: %s", credentialID)
+	}
+	if _, _, err := s.localStatusEntry(old.CredentialStatus); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if expiresAt == nil {
+		if old.ExpirationDate == nil {
+			return nil, errors.New("expiresAt is required for credentials without an expiration date")
+		}
+		renewed := now.Add(old.ExpirationDate.Sub(old.IssuanceDate))
+		expiresAt = &renewed
+	}
+	if !expiresAt.After(now) || (old.ExpirationDate != nil && !expiresAt.After(*old.ExpirationDate)) {
+		return nil, errors.New("expiresAt must extend the current expiration date")
+	}
+
+	s.renewals.mutex.Lock()
+	defer s.renewals.mutex.Unlock()
+
+	if next, renewed := s.renewals.next[credentialID]; renewed {
+		return nil, fmt.Errorf("credential was already renewed as %s", next)
+	}
+	if s.revokedLocally(old) {
+		return nil, fmt.Errorf("credential has been revoked: %s", credentialID)
+	}
+
+	proofType := s.proofType
+	if old.Proof != nil {
+		proofType = old.Proof.Type
+	}
+	// 续期凭证沿用原凭证的颁发者，游戏颁发的凭证仍由该游戏签发
+	credential, err := s.issueAs(ctx, s.acceptedIssuer(old), old.CredentialSubject.ID, credentialType(old), old.CredentialSubject, expiresAt, proofType)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.RevokeCredentialContext(ctx, credentialID); err != nil {
+		return nil, fmt.Errorf("revoke renewed credential: %w", err)
+	}
+
+	s.renewals.previous[credential.ID] = credentialID
+	s.renewals.next[credentialID] = credential.ID
+
+	if err := s.achievements.replace(credentialID, credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// RenewalChain 返回凭证所在的续期链，从最初颁发的凭证到最新续期的凭证
+func (s *SimpleService) RenewalChain(credentialID string) []string {
+	s.renewals.mutex.Lock()
+	defer s.renewals.mutex.Unlock()
+
+	return s.renewals.chain(credentialID)
+}
+
+// credentialType 返回 VerifiableCredential 之外的凭证类型
+func credentialType(credential *vc.SimpleCredential) string {
+	for _, t := range credential.Type {
+		if t != "VerifiableCredential" {
+			return t
+		}
+	}
+	return ""
+}
+
+// HandleRenewCredential 处理续期凭证请求，经过 DID 认证的请求只能续期颁发给自己的凭证
+func (s *SimpleService) HandleRenewCredential(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RenewCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.CredentialID == "" {
+		http.Error(w, "credentialId is required", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.RLock()
+	old, exists := s.credentials[req.CredentialID]
+	s.mutex.RUnlock()
+	if !exists {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+	if !authorizedFor(r, old.CredentialSubject.ID) {
+		http.Error(w, "credentials can only be renewed by their holder", http.StatusForbidden)
+		return
+	}
+
+	credential, err := s.RenewCredential(r.Context(), req.CredentialID, req.ExpiresAt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to renew credential: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := RenewCredentialResponse{
+		Credential:  credential,
+		RenewedFrom: req.CredentialID,
+		Chain:       s.RenewalChain(credential.ID),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleRenewalChain 处理 GET /api/vc/renewals?credentialId=...，返回凭证所在的续期链
+func (s *SimpleService) HandleRenewalChain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	credentialID := r.URL.Query().Get("credentialId")
+	if credentialID == "" {
+		http.Error(w, "credentialId is required", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.RLock()
+	_, exists := s.credentials[credentialID]
+	s.mutex.RUnlock()
+	if !exists {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+
+	response := RenewalChainResponse{
+		CredentialID: credentialID,
+		Chain:        s.RenewalChain(credentialID),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,13 +1,31 @@
 package vc
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/aries"
 	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/kms"
+	gamestorage "github.com/czh0526/game/server/internal/storage"
+	"github.com/czh0526/game/server/internal/tracing"
+	"github.com/czh0526/game/server/internal/webhook"
+	"github.com/czh0526/game/server/pkg/bbs"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
 	"github.com/czh0526/game/server/pkg/vc"
 )
 
@@ -16,47 +34,212 @@ type SimpleService struct {
 	didService  *did.SimpleService
 	credentials map[string]*vc.SimpleCredential
 	issuerDID   string
+	issuer      *pkgdid.SimpleDID
+	keys        kms.KeyManager
+	keyRing     *issuerKeyRing
+	signingKey  crypto.Signer // 当前 Ed25519 签名密钥，由 KMS 签名
+	signingFragment string    // 当前签名密钥的验证方法片段
+	es256Key    crypto.Signer // P-256 #key-2，由 KMS 签名
+	bbsKey      *bbs.PrivateKey // BBS+ #bbs-key-1，由 KMS 数据密钥派生，KMS 不提供数据密钥时为空
+	proofType   string
+	status      *statusRegistry
+	offers      *offerStore
+	renewals    *renewalStore
+	achievements *achievementStore
+	claims      *claimStore
+	gameIssuers map[string]*gameIssuer // 游戏 ID -> 游戏自己的颁发者
+	trust       *trustRegistry // 验证时接受的颁发者及其策略
+	publicURL   string
+	auditLog    *audit.Log // 审计日志，为空时不记录
+	webhooks    *webhook.Dispatcher // 事件订阅，为空时不推送
+	issuedNotifier IssuedNotifier // 通知持有者收到了新凭证，为空时不通知
+	claimSource ClaimSource // 生成自助申请凭证的声明，为空时不能自助申请
 	mutex       sync.RWMutex
+
+	// Issue Credential 和 Present Proof 2.0 协议，EnableDIDComm 后可用
+	didcommSender aries.MessageSender
+	exchanges     *exchangeStore
+	proofRequests *proofRequestStore
 }
 
 // IssueCredentialRequest 颁发凭证请求
 type IssueCredentialRequest struct {
 	PlayerDID   string                `json:"playerDid"`
-	Type        string                `json:"type"`
-	Subject     vc.CredentialSubject  `json:"credentialSubject"`
+	Type        string                `json:"type"`                // 只能是可自助申请的类型，凭证主体由服务器按游戏状态生成
 	ExpiresAt   *time.Time            `json:"expiresAt,omitempty"`
+	ProofType   string                `json:"proofType,omitempty"` // Ed25519Signature2020、Ed25519Signature2018、DataIntegrityProof（eddsa-jcs-2022）或 BbsBlsSignature2020
+	Format      string                `json:"format,omitempty"`    // ldp_vc（默认）、jwt_vc_json 或 vc+sd-jwt
+	Alg         string                `json:"alg,omitempty"`       // JWT 格式的签名算法：EdDSA（默认）或 ES256
 }
 
 // IssueCredentialResponse 颁发凭证响应
 type IssueCredentialResponse struct {
-	Credential *vc.SimpleCredential `json:"credential"`
+	Credential *vc.SimpleCredential `json:"credential,omitempty"`
+	JWT        string               `json:"jwt,omitempty"`   // jwt_vc_json 格式的凭证
+	SDJWT      string               `json:"sdJwt,omitempty"` // vc+sd-jwt 格式的凭证，含全部披露
+	Format     string               `json:"format"`
 	ID         string               `json:"id"`
 }
 
 // VerifyCredentialRequest 验证凭证请求
 type VerifyCredentialRequest struct {
-	Credential *vc.SimpleCredential `json:"credential"`
+	Credential *vc.SimpleCredential `json:"credential,omitempty"`
+	JWT        string               `json:"jwt,omitempty"`   // jwt_vc_json 格式的凭证
+	SDJWT      string               `json:"sdJwt,omitempty"` // vc+sd-jwt 格式的凭证，只含持有者选择的披露
+	// TrustedIssuers 验证方信任的颁发者 DID，设置后只接受其中的颁发者，可包含其他服务器的颁发者；
+	// 为空时接受服务器颁发者和各游戏颁发者
+	TrustedIssuers []string `json:"trustedIssuers,omitempty"`
+	// Nonce 验证方发给持有者的随机数，设置后 BbsBlsSignatureProof2020 派生证明须绑定该随机数
+	Nonce string `json:"nonce,omitempty"`
 }
 
 // VerifyCredentialResponse 验证凭证响应
 type VerifyCredentialResponse struct {
 	Valid   bool   `json:"valid"`
 	Message string `json:"message,omitempty"`
+	// DisclosedCredential SD-JWT 凭证由已披露字段还原的内容
+	DisclosedCredential *vc.SimpleCredential `json:"disclosedCredential,omitempty"`
 }
 
-// NewSimpleService 创建新的简化VC服务
+// 颁发者签名密钥在 KMS 中的 ID
+const (
+	issuerKeyID      = "issuer-ed25519"
+	issuerES256KeyID = "issuer-es256"
+)
+
+// NewSimpleService 创建新的简化VC服务，颁发者密钥保存在进程内存中的本地 KMS
 func NewSimpleService(didService *did.SimpleService) (*SimpleService, error) {
-	// 使用固定的系统颁发者 DID
-	issuerDID := "did:player:system:game-server"
+	keys, err := kms.NewLocalKMS("", "")
+	if err != nil {
+		return nil, err
+	}
+	return NewSimpleServiceWithKMS(didService, keys)
+}
+
+// NewSimpleServiceWithKMS 创建VC服务，颁发者密钥由 keys 创建和保管，私钥不离开 KMS；
+// KMS 中已有的颁发者密钥会被复用，密钥轮换记录、撤销状态列表和成就凭证只保存在内存中
+func NewSimpleServiceWithKMS(didService *did.SimpleService, keys kms.KeyManager) (*SimpleService, error) {
+	return NewSimpleServiceWithKeyRing(didService, keys, gamestorage.NewMemoryProvider())
+}
+
+// NewSimpleServiceWithKeyRing 同 NewSimpleServiceWithKMS，颁发者密钥的轮换记录、撤销状态列表和成就凭证保存在 provider 中，
+// 重启后沿用当前签名密钥，能验证轮换前颁发的凭证及其撤销状态，已获得的成就不会重复颁发
+func NewSimpleServiceWithKeyRing(didService *did.SimpleService, keys kms.KeyManager, provider storage.Provider) (*SimpleService, error) {
+	keyRing, err := openIssuerKeyRing(provider, keys)
+	if err != nil {
+		return nil, err
+	}
+	trust, err := openTrustRegistry(provider)
+	if err != nil {
+		return nil, err
+	}
+	status, err := openStatusRegistry(provider, vc.DefaultStatusListSize)
+	if err != nil {
+		return nil, err
+	}
+	achievements, err := openAchievementStore(provider)
+	if err != nil {
+		return nil, err
+	}
+	active := keyRing.active()
+	signingKey, err := kms.Signer(keys, active.KMSKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("load issuer key: %w", err)
+	}
+
+	// 重启期间重叠期已结束的旧密钥不再发布
+	now := time.Now()
+	for _, key := range keyRing.keys {
+		if key.published() && key.RetiresAt != nil && !key.RetiresAt.After(now) {
+			key.Retired = true
+			if err := keyRing.save(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// ES256 签名密钥，作为 #key-2 发布在颁发者 DID 文档中
+	if _, err := kms.EnsureKey(keys, issuerES256KeyID, kms.KeyTypeECDSAP256); err != nil {
+		return nil, fmt.Errorf("load ES256 key: %w", err)
+	}
+	es256Key, err := kms.Signer(keys, issuerES256KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("load ES256 key: %w", err)
+	}
+	bbsKey, err := loadBBSKey(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用固定的系统颁发者 DID，文档包含发布中的全部签名密钥
+	var bbsPublicKey *bbs.PublicKey
+	if bbsKey != nil {
+		bbsPublicKey = bbsKey.Public()
+	}
+	issuer := buildIssuerDID("did:player:system:game-server", keyRing.snapshot(), es256Key.Public().(*ecdsa.PublicKey), bbsPublicKey, keyRing.keys[0].CreatedAt)
+
+	// 注册颁发者 DID，使第三方可以解析其公钥验证凭证
+	if err := didService.RegisterDID(issuer); err != nil {
+		return nil, fmt.Errorf("register issuer DID: %w", err)
+	}
+
+	// 已颁发的成就凭证重启后仍可按 ID 撤销、续期和认领
+	credentials := make(map[string]*vc.SimpleCredential)
+	for _, credential := range achievements.credentials() {
+		credentials[credential.ID] = credential
+	}
 
 	return &SimpleService{
 		didService:  didService,
-		credentials: make(map[string]*vc.SimpleCredential),
-		issuerDID:   issuerDID,
+		credentials: credentials,
+		issuerDID:   issuer.ID,
+		issuer:      issuer,
+		keys:        keys,
+		keyRing:     keyRing,
+		signingKey:  signingKey,
+		signingFragment: active.Fragment,
+		es256Key:    es256Key,
+		bbsKey:      bbsKey,
+		proofType:   vc.ProofTypeEd25519Signature2020,
+		status:      status,
+		offers:      newOfferStore(),
+		renewals:    newRenewalStore(),
+		achievements: achievements,
+		claims:      newClaimStore(),
+		gameIssuers: make(map[string]*gameIssuer),
+		trust:       trust,
+		publicURL:   "http://localhost:8080",
 	}, nil
 }
 
-// HandleIssueCredential 处理颁发凭证请求
+// UseWebIssuer 以 did:web 身份签发凭证，复用颁发者密钥，DID 由服务地址的主机生成；需在服务启动前调用
+func (s *SimpleService) UseWebIssuer(publicURL string) error {
+	webID, err := pkgdid.WebDIDFromURL(publicURL)
+	if err != nil {
+		return fmt.Errorf("build did:web: %w", err)
+	}
+
+	s.keyRing.mutex.Lock()
+	issuer := buildIssuerDID(webID, s.keyRing.snapshot(), s.es256Key.Public().(*ecdsa.PublicKey), s.bbsPublicKey(), s.issuer.CreatedAt)
+	s.keyRing.mutex.Unlock()
+	if err := s.didService.HostWebDID(issuer); err != nil {
+		return fmt.Errorf("host issuer did:web: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.issuer = issuer
+	s.issuerDID = webID
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// IssuerDID 返回颁发者 DID
+func (s *SimpleService) IssuerDID() string {
+	return s.issuerDID
+}
+
+// HandleIssueCredential 处理颁发凭证请求，只颁发可自助申请的类型，凭证主体由 ClaimSource 生成
 func (s *SimpleService) HandleIssueCredential(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -78,23 +261,73 @@ func (s *SimpleService) HandleIssueCredential(w http.ResponseWriter, r *http.Req
 		http.Error(w, "type is required", http.StatusBadRequest)
 		return
 	}
-
-	// 颁发凭证
-	credential, err := s.IssueCredential(req.PlayerDID, req.Type, req.Subject, req.ExpiresAt)
+	if !authorizedFor(r, req.PlayerDID) {
+		http.Error(w, "credentials can only be requested for the authenticated DID", http.StatusForbidden)
+		return
+	}
+	subject, err := s.selfServiceClaims(r.Context(), req.PlayerDID, req.Type)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to issue credential: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Cannot issue credential: %v", err), selfServiceStatus(err))
 		return
 	}
 
-	response := IssueCredentialResponse{
-		Credential: credential,
-		ID:         credential.ID,
+	var response IssueCredentialResponse
+	switch req.Format {
+	case "", vc.FormatLDPVC:
+		// 颁发凭证
+		proofType := req.ProofType
+		if proofType == "" {
+			proofType = s.proofType
+		}
+
+		credential, err := s.IssueCredentialContext(r.Context(), req.PlayerDID, req.Type, subject, req.ExpiresAt, proofType)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to issue credential: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		response = IssueCredentialResponse{
+			Credential: credential,
+			Format:     vc.FormatLDPVC,
+			ID:         credential.ID,
+		}
+	case vc.FormatJWTVCJSON, vc.FormatSDJWT:
+		if req.Alg != "" && req.Alg != vc.JWTAlgEdDSA && req.Alg != vc.JWTAlgES256 {
+			http.Error(w, "alg must be EdDSA or ES256", http.StatusBadRequest)
+			return
+		}
+
+		encode := vc.EncodeJWTCredential
+		if req.Format == vc.FormatSDJWT {
+			encode = vc.EncodeSDJWTCredential
+		}
+		token, credential, err := s.issueEncoded(r.Context(), req.PlayerDID, req.Type, subject, req.ExpiresAt, req.Alg, req.Format, encode)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to issue credential: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		response = IssueCredentialResponse{Format: req.Format, ID: credential.ID}
+		if req.Format == vc.FormatSDJWT {
+			response.SDJWT = token
+		} else {
+			response.JWT = token
+		}
+	default:
+		http.Error(w, "format must be ldp_vc, jwt_vc_json or vc+sd-jwt", http.StatusBadRequest)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// authorizedFor 请求必须经过 DID 认证，且只能为请求方自己的 DID 申请凭证
+func authorizedFor(r *http.Request, playerDID string) bool {
+	authenticated, ok := didauth.DIDFromContext(r.Context())
+	return ok && authenticated == playerDID
+}
+
 // HandleVerifyCredential 处理验证凭证请求
 func (s *SimpleService) HandleVerifyCredential(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -108,33 +341,102 @@ func (s *SimpleService) HandleVerifyCredential(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if req.Credential == nil {
-		http.Error(w, "credential is required", http.StatusBadRequest)
+	// 验证凭证，JWT、SD-JWT 与 JSON-LD 格式均可
+	var (
+		valid     bool
+		message   string
+		disclosed *vc.SimpleCredential
+		issuer    string
+		// credentialID 审计记录的对象，SD-JWT 验证失败时未知
+		credentialID string
+	)
+	switch {
+	case req.SDJWT != "":
+		disclosed, valid, message = s.VerifySDJWTCredentialContext(r.Context(), req.SDJWT)
+		if disclosed != nil {
+			issuer, credentialID = disclosed.Issuer, disclosed.ID
+		}
+	case req.JWT != "":
+		valid, message = s.VerifyJWTCredentialContext(r.Context(), req.JWT)
+		if parsed, err := vc.ParseJWTCredential(req.JWT); err == nil {
+			issuer, credentialID = parsed.Credential.Issuer, parsed.Credential.ID
+		}
+	case req.Credential != nil:
+		// 信任的外部颁发者通过其 DID 文档验证
+		if req.Nonce != "" && (req.Credential.Proof == nil || req.Credential.Proof.Nonce != req.Nonce) {
+			valid, message = false, "nonce mismatch"
+		} else if len(req.TrustedIssuers) > 0 && !s.localIssuer(req.Credential.Issuer) {
+			valid, message = s.VerifyTrustedCredential(r.Context(), req.Credential, req.TrustedIssuers)
+		} else {
+			valid, message = s.verifyCredential(r.Context(), req.Credential)
+		}
+		issuer, credentialID = req.Credential.Issuer, req.Credential.ID
+	default:
+		http.Error(w, "credential, jwt or sdJwt is required", http.StatusBadRequest)
 		return
 	}
-
-	// 验证凭证
-	valid, message := s.VerifyCredential(req.Credential)
+	if valid && len(req.TrustedIssuers) > 0 && !containsString(req.TrustedIssuers, issuer) {
+		valid, message, disclosed = false, "issuer is not trusted", nil
+	}
+	s.auditLog.Record(r.Context(), audit.CategoryVC, audit.OpVerify, credentialID, verificationError(valid, message))
 
 	response := VerifyCredentialResponse{
-		Valid:   valid,
-		Message: message,
+		Valid:               valid,
+		Message:             message,
+		DisclosedCredential: disclosed,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// IssueCredential 颁发凭证
+// IssueCredential 颁发凭证（使用默认证明类型）
 func (s *SimpleService) IssueCredential(playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time) (*vc.SimpleCredential, error) {
+	return s.IssueCredentialWithProof(playerDID, credType, subject, expiresAt, s.proofType)
+}
+
+// IssueCredentialWithProof 颁发凭证并使用指定类型的证明签名
+func (s *SimpleService) IssueCredentialWithProof(playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time, proofType string) (*vc.SimpleCredential, error) {
+	return s.IssueCredentialContext(context.Background(), playerDID, credType, subject, expiresAt, proofType)
+}
+
+// IssueCredentialContext 同 IssueCredentialWithProof，在 ctx 的追踪中记录颁发跨度。
+// 成就凭证改走 issueAchievement，按玩家和成就去重
+func (s *SimpleService) IssueCredentialContext(ctx context.Context, playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time, proofType string) (*vc.SimpleCredential, error) {
+	if credential, handled, err := s.routeAchievement(ctx, playerDID, credType, subject, expiresAt); handled {
+		return credential, err
+	}
+	return s.issueAs(ctx, s.IssuerDID(), playerDID, credType, subject, expiresAt, proofType)
+}
+
+// issueAs 以本地颁发者 issuerDID 的当前签名密钥颁发凭证
+func (s *SimpleService) issueAs(ctx context.Context, issuerDID, playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time, proofType string) (credential *vc.SimpleCredential, err error) {
+	ctx, span := tracing.Start(ctx, "vc.issue", tracing.String("credential.type", credType), tracing.String("credential.format", vc.FormatLDPVC))
+	defer func() {
+		s.auditIssue(ctx, playerDID, credential, err)
+		s.publishIssued(credential, vc.FormatLDPVC, err)
+		span.RecordError(err)
+		span.End()
+	}()
+
+	credential, err = s.signAs(ctx, issuerDID, playerDID, credType, subject, expiresAt, proofType)
+	if err != nil {
+		return nil, err
+	}
+	s.storeIssued(credential)
+	return credential, nil
+}
+
+// signAs 构造并签名凭证并分配撤销状态条目，但不保存也不通知持有者，调用方确认后以 storeIssued 保存
+func (s *SimpleService) signAs(ctx context.Context, issuerDID, playerDID, credType string, subject vc.CredentialSubject, expiresAt *time.Time, proofType string) (*vc.SimpleCredential, error) {
 	// 验证玩家DID是否存在
-	_, err := s.didService.ResolveDID(playerDID)
+	_, err := s.didService.ResolveDIDContext(ctx, playerDID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid player DID: %w", err)
 	}
 
 	// 颁发凭证
-	credential, err := vc.IssueCredential(s.issuerDID, playerDID, credType, subject)
+	credential, err := vc.IssueCredential(issuerDID, playerDID, credType, subject)
 	if err != nil {
 		return nil, fmt.Errorf("issue credential: %w", err)
 	}
@@ -144,62 +446,228 @@ func (s *SimpleService) IssueCredential(playerDID, credType string, subject vc.C
 		credential.ExpirationDate = expiresAt
 	}
 
-	// 存储凭证
+	// 分配撤销状态条目，需在签名前写入
+	if err := s.assignStatus(credential); err != nil {
+		return nil, err
+	}
+
+	if proofType == vc.ProofTypeBbsBlsSignature2020 {
+		// BBS+ 签名，持有者可由它派生选择性披露证明
+		if err := s.signBBS(credential, issuerDID); err != nil {
+			return nil, err
+		}
+	} else {
+		// Open Badges 等未内置的上下文无法离线做 URDNA2015 规范化，这类凭证改用 DataIntegrityProof
+		if vc.IsLinkedDataProofType(proofType) && !credential.HasOfflineContexts() {
+			proofType = vc.ProofTypeDataIntegrity
+		}
+
+		// 由 KMS 使用当前颁发者密钥签名，证明中的验证方法指明所用密钥
+		signer, verificationMethod, err := s.issuerSigner(issuerDID)
+		if err != nil {
+			return nil, err
+		}
+		if err := vc.SignCredential(credential, signer, verificationMethod, proofType); err != nil {
+			return nil, fmt.Errorf("sign credential: %w", err)
+		}
+	}
+
+	return credential, nil
+}
+
+// storeIssued 保存已签名的凭证
+func (s *SimpleService) storeIssued(credential *vc.SimpleCredential) {
 	s.mutex.Lock()
 	s.credentials[credential.ID] = credential
 	s.mutex.Unlock()
-
-	return credential, nil
+	credentialsIssuedTotal.With(vc.FormatLDPVC).Inc()
 }
 
 // VerifyCredential 验证凭证
-func (s *SimpleService) VerifyCredential(credential *vc.SimpleCredential) (bool, string) {
-	// 使用简化的验证逻辑
-	valid, message := vc.VerifyCredential(credential, s.issuerDID)
+func (s *SimpleService) VerifyCredential(credential *vc.SimpleCredential) (valid bool, message string) {
+	return s.verifyCredential(context.Background(), credential)
+}
+
+// verifyCredential 验证凭证，解析 DID 和获取外部状态列表在 ctx 取消或到期时中止
+func (s *SimpleService) verifyCredential(ctx context.Context, credential *vc.SimpleCredential) (valid bool, message string) {
+	defer func() { observeVerification(vc.FormatLDPVC, valid) }()
+
+	// 接受本地颁发者和信任登记表中的颁发者签发、满足其策略的凭证
+	valid, message = s.evaluateTrust(credential, credential != nil && s.localIssuer(credential.Issuer))
+	if !valid {
+		return valid, message
+	}
+	valid, message = vc.VerifyCredential(credential, credential.Issuer)
 	if !valid {
 		return valid, message
 	}
+	if s.subjectDeactivated(ctx, credential) {
+		return false, "credential subject DID has been deactivated"
+	}
+
+	// 没有证明的凭证只能通过本地登记表验证
+	if credential.Proof == nil {
+		s.mutex.RLock()
+		_, exists := s.credentials[credential.ID]
+		s.mutex.RUnlock()
+
+		if !exists {
+			return false, "credential not found in registry"
+		}
+		return true, "credential is valid"
+	}
 
-	// 检查凭证是否存在于存储中
-	s.mutex.RLock()
-	_, exists := s.credentials[credential.ID]
-	s.mutex.RUnlock()
+	if isBBSProof(credential.Proof) {
+		// BBS+ 签名或持有者派生的选择性披露证明
+		if err := s.verifyBBSProof(ctx, credential); err != nil {
+			return false, err.Error()
+		}
+	} else {
+		// 本服务器颁发的凭证按颁发时间选择历史签名密钥，其他颁发者解析 DID 文档
+		publicKey, ours, err := s.issuerVerificationKey(credential.Proof.VerificationMethod, credential.IssuanceDate)
+		if !ours && !strings.HasPrefix(credential.Proof.VerificationMethod, credential.Issuer+"#") {
+			return false, "verification method does not belong to issuer"
+		}
+		if !ours {
+			publicKey, err = s.resolveVerificationKey(ctx, credential.Issuer, credential.Proof.VerificationMethod)
+		}
+		if err != nil {
+			return false, fmt.Sprintf("resolve verification method: %v", err)
+		}
+
+		if err := vc.VerifyProof(credential, publicKey); err != nil {
+			return false, fmt.Sprintf("invalid proof: %v", err)
+		}
+	}
 
-	if !exists {
-		return false, "credential not found in registry"
+	// 检查撤销状态
+	if credential.CredentialStatus != nil {
+		revoked, err := s.checkStatus(ctx, credential)
+		if err != nil {
+			return false, fmt.Sprintf("check credential status: %v", err)
+		}
+		if revoked {
+			return false, "credential has been revoked"
+		}
 	}
 
 	return true, "credential is valid"
 }
 
-// IssueAchievementCredential 颁发成就凭证的便捷方法
-func (s *SimpleService) IssueAchievementCredential(playerDID, gameID, playerID, achievement string, score int) (*vc.SimpleCredential, error) {
+// subjectDeactivated 检查凭证主体的 DID 是否已停用，停用 DID 持有的凭证不能通过验证
+func (s *SimpleService) subjectDeactivated(ctx context.Context, credential *vc.SimpleCredential) bool {
+	if credential.CredentialSubject.ID == "" {
+		return false
+	}
+	_, err := s.didService.ResolveDIDContext(ctx, credential.CredentialSubject.ID)
+	return errors.Is(err, pkgdid.ErrDeactivated)
+}
+
+// resolveVerificationKey 解析颁发者 DID 文档中的验证方法公钥
+func (s *SimpleService) resolveVerificationKey(ctx context.Context, issuerDID, verificationMethod string) (ed25519.PublicKey, error) {
+	resolved, err := s.didService.ResolveDIDContext(ctx, issuerDID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, method := range resolved.DIDDoc.VerificationMethod {
+		if method.ID != verificationMethod {
+			continue
+		}
+
+		publicKey, err := hex.DecodeString(method.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("decode public key: %w", err)
+		}
+		return ed25519.PublicKey(publicKey), nil
+	}
+
+	return nil, fmt.Errorf("verification method not found: %s", verificationMethod)
+}
+
+// IssueAchievementCredential 颁发成就凭证的便捷方法。颁发是幂等的：
+// 玩家在该游戏中已有这项成就的有效凭证时直接返回它，不会重复签发
+func (s *SimpleService) IssueAchievementCredential(ctx context.Context, playerDID, gameID, playerID, achievement string, score int) (*vc.SimpleCredential, error) {
+	return s.issueAchievement(ctx, playerDID, achievementSubject(gameID, playerID, achievement, score), false)
+}
+
+// IssueLevelCredential 颁发等级凭证的便捷方法
+func (s *SimpleService) IssueLevelCredential(ctx context.Context, playerDID, gameID, playerID string, level int) (*vc.SimpleCredential, error) {
+	subject := vc.CredentialSubject{
+		PlayerID: playerID,
+		GameID:   gameID,
+		Level:    level,
+		Attributes: map[string]interface{}{
+			"category": "level",
+		},
+	}
+
+	return s.issueForGame(ctx, gameID, playerDID, "LevelCredential", subject)
+}
+
+// IssueSkillCredential 颁发技能凭证的便捷方法
+func (s *SimpleService) IssueSkillCredential(ctx context.Context, playerDID, gameID, playerID, skillID, skillName string) (*vc.SimpleCredential, error) {
+	subject := vc.CredentialSubject{
+		PlayerID: playerID,
+		GameID:   gameID,
+		Skills:   []string{skillName},
+		Attributes: map[string]interface{}{
+			"category": "skill",
+			"skillId":  skillID,
+		},
+	}
+
+	return s.issueForGame(ctx, gameID, playerDID, "SkillCredential", subject)
+}
+
+// IssueItemCredential 颁发道具凭证的便捷方法，用于跨游戏证明稀有道具的所有权
+func (s *SimpleService) IssueItemCredential(ctx context.Context, playerDID, gameID, playerID, itemID, itemName, rarity string) (*vc.SimpleCredential, error) {
+	subject := vc.CredentialSubject{
+		PlayerID: playerID,
+		GameID:   gameID,
+		Items:    []string{itemName},
+		Attributes: map[string]interface{}{
+			"category": "item",
+			"itemId":   itemID,
+			"rarity":   rarity,
+		},
+	}
+
+	return s.issueForGame(ctx, gameID, playerDID, "ItemCredential", subject)
+}
+
+// IssueTradeReceiptCredential 颁发交易回执凭证的便捷方法，记录玩家在一次交易中给出和收到的道具
+func (s *SimpleService) IssueTradeReceiptCredential(ctx context.Context, playerDID, gameID, playerID, tradeID, counterparty string, given, received []string) (*vc.SimpleCredential, error) {
 	now := time.Now()
 	subject := vc.CredentialSubject{
 		PlayerID:    playerID,
 		GameID:      gameID,
-		Achievement: achievement,
-		Score:       score,
+		Items:       received,
 		CompletedAt: &now,
 		Attributes: map[string]interface{}{
-			"difficulty": "normal",
-			"category":   "achievement",
+			"category":     "trade",
+			"tradeId":      tradeID,
+			"counterparty": counterparty,
+			"given":        given,
+			"received":     received,
 		},
 	}
 
-	return s.IssueCredential(playerDID, "AchievementCredential", subject, nil)
+	return s.issueForGame(ctx, gameID, playerDID, "TradeReceiptCredential", subject)
 }
 
-// IssueLevelCredential 颁发等级凭证的便捷方法
-func (s *SimpleService) IssueLevelCredential(playerDID, gameID, playerID string, level int) (*vc.SimpleCredential, error) {
+// IssueGuildMembershipCredential 颁发公会成员凭证的便捷方法，用于向其他游戏或服务证明玩家的公会身份和职位
+func (s *SimpleService) IssueGuildMembershipCredential(ctx context.Context, playerDID, gameID, playerID, guildID, guildName, role string) (*vc.SimpleCredential, error) {
 	subject := vc.CredentialSubject{
 		PlayerID: playerID,
 		GameID:   gameID,
-		Level:    level,
 		Attributes: map[string]interface{}{
-			"category": "level",
+			"category":  "guild",
+			"guildId":   guildID,
+			"guildName": guildName,
+			"role":      role,
 		},
 	}
 
-	return s.IssueCredential(playerDID, "LevelCredential", subject, nil)
-}
\ No newline at end of file
+	return s.issueForGame(ctx, gameID, playerDID, "GuildMembershipCredential", subject)
+}
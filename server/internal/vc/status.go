@@ -0,0 +1,524 @@
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/audit"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// statusListPath 状态列表凭证的 HTTP 路径前缀
+const statusListPath = "/api/vc/status/"
+
+// statusListFetchTimeout 获取外部状态列表的超时时间
+const statusListFetchTimeout = 5 * time.Second
+
+// statusListMaxSize 外部状态列表凭证的最大字节数
+const statusListMaxSize = 1 << 20
+
+// statusListClient 获取外部状态列表的 HTTP 客户端。状态列表地址由凭证给出，
+// 连接时检查解析后的地址，不访问回环、私有和链路本地地址，重定向同样受限
+var statusListClient = &http.Client{
+	Timeout: statusListFetchTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: statusListFetchTimeout,
+			Control: dialPublicOnly,
+		}).DialContext,
+		TLSHandshakeTimeout: statusListFetchTimeout,
+	},
+}
+
+// sharedAddressSpace 运营商级 NAT 地址段（RFC 6598）
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// dialPublicOnly 拒绝连接非公网地址，在 DNS 解析之后检查，域名重新绑定到内网地址同样被拒绝
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// publicIP 检查地址是否可以从公网访问
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
+
+// statusListStoreName 状态列表的存储名称
+const statusListStoreName = "status_lists"
+
+// statusListTag 状态列表记录的标签，用于加载全部列表
+const statusListTag = "status_list"
+
+// statusListCounterKey 已分配的最大列表 ID 的记录键，重启后继续递增，列表 ID 不会复用
+const statusListCounterKey = "next_id"
+
+// statusList 单个颁发者的状态列表
+type statusList struct {
+	id     string
+	issuer string
+	bits   *vc.StatusList
+	next   int
+}
+
+// statusListRecord 状态列表的存储记录
+type statusListRecord struct {
+	ID          string `json:"id"`
+	Issuer      string `json:"issuer"`
+	Next        int    `json:"next"`
+	EncodedList string `json:"encodedList"`
+}
+
+// statusRegistry StatusList2021 撤销登记表，每个颁发者维护自己的位串列表。
+// 分配索引和修改状态位后立即写入存储，重启后已颁发凭证的状态条目仍然有效
+type statusRegistry struct {
+	store  storage.Store
+	lists  map[string]*statusList // listID -> 列表
+	active map[string]string      // 颁发者 -> 当前分配中的 listID
+	nextID int
+	size   int
+	mutex  sync.RWMutex
+}
+
+// openStatusRegistry 打开状态列表存储并加载全部列表，每个颁发者 ID 最大的列表继续分配
+func openStatusRegistry(provider storage.Provider, size int) (*statusRegistry, error) {
+	store, err := provider.OpenStore(statusListStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open status list store: %w", err)
+	}
+	r := &statusRegistry{
+		store:  store,
+		lists:  make(map[string]*statusList),
+		active: make(map[string]string),
+		size:   size,
+	}
+
+	counter, err := store.Get(statusListCounterKey)
+	switch {
+	case errors.Is(err, storage.ErrDataNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("read status list counter: %w", err)
+	default:
+		if r.nextID, err = strconv.Atoi(string(counter)); err != nil {
+			return nil, fmt.Errorf("invalid status list counter: %q", counter)
+		}
+	}
+
+	iter, err := store.Query(statusListTag)
+	if err != nil {
+		return nil, fmt.Errorf("query status lists: %w", err)
+	}
+	defer iter.Close()
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate status lists: %w", err)
+		}
+		if !more {
+			break
+		}
+		value, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read status list: %w", err)
+		}
+		var record statusListRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			return nil, fmt.Errorf("decode status list: %w", err)
+		}
+		bits, err := vc.DecodeStatusList(record.EncodedList)
+		if err != nil {
+			return nil, fmt.Errorf("decode status list %s: %w", record.ID, err)
+		}
+		r.lists[record.ID] = &statusList{id: record.ID, issuer: record.Issuer, bits: bits, next: record.Next}
+
+		id, err := strconv.Atoi(record.ID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status list ID: %q", record.ID)
+		}
+		if id > r.nextID {
+			r.nextID = id
+		}
+		if activeID, _ := strconv.Atoi(r.active[record.Issuer]); id > activeID {
+			r.active[record.Issuer] = record.ID
+		}
+	}
+	return r, nil
+}
+
+// save 写入列表，调用方持有写锁
+func (r *statusRegistry) save(list *statusList) error {
+	encoded, err := list.bits.Encode()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(statusListRecord{ID: list.id, Issuer: list.issuer, Next: list.next, EncodedList: encoded})
+	if err != nil {
+		return fmt.Errorf("marshal status list: %w", err)
+	}
+	if err := r.store.Put(list.id, data, storage.Tag{Name: statusListTag}); err != nil {
+		return fmt.Errorf("store status list: %w", err)
+	}
+	return nil
+}
+
+// allocate 为颁发者分配下一个状态索引，列表满时创建新列表。新列表先写入计数器，
+// 索引写入存储后才返回，重启后不会再分配给其他凭证
+func (r *statusRegistry) allocate(issuer string) (string, int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	list, exists := r.lists[r.active[issuer]]
+	if !exists || list.next >= list.bits.Size() {
+		if err := r.store.Put(statusListCounterKey, []byte(strconv.Itoa(r.nextID+1))); err != nil {
+			return "", 0, fmt.Errorf("store status list counter: %w", err)
+		}
+		r.nextID++
+		list = &statusList{
+			id:     strconv.Itoa(r.nextID),
+			issuer: issuer,
+			bits:   vc.NewStatusList(r.size),
+		}
+		r.lists[list.id] = list
+		r.active[issuer] = list.id
+	}
+
+	index := list.next
+	list.next++
+	if err := r.save(list); err != nil {
+		list.next--
+		return "", 0, err
+	}
+	return list.id, index, nil
+}
+
+// set 设置状态位并写入存储
+func (r *statusRegistry) set(listID string, index int, revoked bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	list, exists := r.lists[listID]
+	if !exists {
+		return fmt.Errorf("status list not found: %s", listID)
+	}
+	previous, err := list.bits.Get(index)
+	if err != nil {
+		return err
+	}
+	if err := list.bits.Set(index, revoked); err != nil {
+		return err
+	}
+	if err := r.save(list); err != nil {
+		list.bits.Set(index, previous)
+		return err
+	}
+	return nil
+}
+
+// get 读取状态位
+func (r *statusRegistry) get(listID string, index int) (bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	list, exists := r.lists[listID]
+	if !exists {
+		return false, fmt.Errorf("status list not found: %s", listID)
+	}
+	return list.bits.Get(index)
+}
+
+// snapshot 复制状态列表，避免签名期间持有锁
+func (r *statusRegistry) snapshot(listID string) (string, *vc.StatusList, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	list, exists := r.lists[listID]
+	if !exists {
+		return "", nil, fmt.Errorf("status list not found: %s", listID)
+	}
+
+	encoded, err := list.bits.Encode()
+	if err != nil {
+		return "", nil, err
+	}
+	bits, err := vc.DecodeStatusList(encoded)
+	if err != nil {
+		return "", nil, err
+	}
+	return list.issuer, bits, nil
+}
+
+// RevokeCredentialRequest 撤销凭证请求
+type RevokeCredentialRequest struct {
+	CredentialID string `json:"credentialId"`
+}
+
+// RevokeCredentialResponse 撤销凭证响应
+type RevokeCredentialResponse struct {
+	Success      bool   `json:"success"`
+	CredentialID string `json:"credentialId"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// SetPublicURL 设置服务器的外部访问地址，用于生成状态列表凭证的 URL
+func (s *SimpleService) SetPublicURL(publicURL string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.publicURL = strings.TrimSuffix(publicURL, "/")
+}
+
+// statusListURL 返回状态列表凭证的 URL
+func (s *SimpleService) statusListURL(listID string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.publicURL + statusListPath + listID
+}
+
+// assignStatus 为凭证分配撤销状态条目
+func (s *SimpleService) assignStatus(credential *vc.SimpleCredential) error {
+	listID, index, err := s.status.allocate(credential.Issuer)
+	if err != nil {
+		return fmt.Errorf("allocate status entry: %w", err)
+	}
+	listURL := s.statusListURL(listID)
+
+	credential.CredentialStatus = &vc.CredentialStatus{
+		ID:                   fmt.Sprintf("%s#%d", listURL, index),
+		Type:                 vc.StatusList2021EntryType,
+		StatusPurpose:        vc.StatusPurposeRevocation,
+		StatusListIndex:      strconv.Itoa(index),
+		StatusListCredential: listURL,
+	}
+	return nil
+}
+
+// RevokeCredential 撤销已颁发的凭证
+func (s *SimpleService) RevokeCredential(credentialID string) error {
+	return s.RevokeCredentialContext(context.Background(), credentialID)
+}
+
+// RevokeCredentialContext 同 RevokeCredential，审计日志中的调用方取自 ctx
+func (s *SimpleService) RevokeCredentialContext(ctx context.Context, credentialID string) (err error) {
+	defer func() { s.auditLog.Record(ctx, audit.CategoryVC, audit.OpRevoke, credentialID, err) }()
+
+	s.mutex.RLock()
+	credential, exists := s.credentials[credentialID]
+	s.mutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("credential not found: %s", credentialID)
+	}
+	if credential.CredentialStatus == nil {
+		return fmt.Errorf("credential has no status entry: %s", credentialID)
+	}
+
+	listID, index, err := s.localStatusEntry(credential.CredentialStatus)
+	if err != nil {
+		return err
+	}
+
+	return s.status.set(listID, index, true)
+}
+
+// checkStatus 检查凭证的撤销状态，返回 true 表示已撤销
+func (s *SimpleService) checkStatus(ctx context.Context, credential *vc.SimpleCredential) (bool, error) {
+	status := credential.CredentialStatus
+	if status.Type != vc.StatusList2021EntryType {
+		return false, fmt.Errorf("unsupported status type: %s", status.Type)
+	}
+	if status.StatusPurpose != vc.StatusPurposeRevocation {
+		return false, fmt.Errorf("unsupported status purpose: %s", status.StatusPurpose)
+	}
+
+	// 本服务器维护的列表直接查询
+	if listID, index, err := s.localStatusEntry(status); err == nil {
+		return s.status.get(listID, index)
+	}
+
+	return s.checkRemoteStatus(ctx, credential)
+}
+
+// localStatusEntry 解析属于本服务器的状态条目
+func (s *SimpleService) localStatusEntry(status *vc.CredentialStatus) (string, int, error) {
+	prefix := s.statusListURL("")
+	if !strings.HasPrefix(status.StatusListCredential, prefix) {
+		return "", 0, fmt.Errorf("status list is not hosted here: %s", status.StatusListCredential)
+	}
+
+	index, err := strconv.Atoi(status.StatusListIndex)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid status list index: %s", status.StatusListIndex)
+	}
+
+	return strings.TrimPrefix(status.StatusListCredential, prefix), index, nil
+}
+
+// checkRemoteStatus 获取外部颁发者的状态列表凭证并检查撤销位。只为信任登记表中的颁发者获取，
+// 且只访问公网的 HTTP(S) 地址，凭证中的地址不能让服务器请求内网服务
+func (s *SimpleService) checkRemoteStatus(ctx context.Context, credential *vc.SimpleCredential) (bool, error) {
+	status := credential.CredentialStatus
+
+	if _, trusted := s.trust.lookup(credential.Issuer); !trusted {
+		return false, fmt.Errorf("status list of %s is not fetched: issuer is not in the trust registry", credential.Issuer)
+	}
+	listURL, err := url.Parse(status.StatusListCredential)
+	if err != nil || (listURL.Scheme != "https" && listURL.Scheme != "http") || listURL.Host == "" {
+		return false, fmt.Errorf("invalid status list URL: %q", status.StatusListCredential)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL.String(), nil)
+	if err != nil {
+		return false, fmt.Errorf("fetch status list: %w", err)
+	}
+	resp, err := statusListClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetch status list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("fetch status list: unexpected status %d", resp.StatusCode)
+	}
+
+	var listCredential vc.StatusListCredential
+	if err := json.NewDecoder(io.LimitReader(resp.Body, statusListMaxSize)).Decode(&listCredential); err != nil {
+		return false, fmt.Errorf("decode status list credential: %w", err)
+	}
+
+	if listCredential.Issuer != credential.Issuer {
+		return false, fmt.Errorf("status list issuer mismatch")
+	}
+	if listCredential.Proof == nil {
+		return false, fmt.Errorf("status list credential has no proof")
+	}
+
+	publicKey, err := s.resolveIssuerKey(ctx, listCredential.Issuer, listCredential.Proof.VerificationMethod)
+	if err != nil {
+		return false, fmt.Errorf("resolve status list issuer key: %w", err)
+	}
+	if err := vc.VerifyStatusListCredential(&listCredential, publicKey); err != nil {
+		return false, fmt.Errorf("invalid status list credential: %w", err)
+	}
+
+	bits, err := vc.DecodeStatusList(listCredential.CredentialSubject.EncodedList)
+	if err != nil {
+		return false, err
+	}
+
+	index, err := strconv.Atoi(status.StatusListIndex)
+	if err != nil {
+		return false, fmt.Errorf("invalid status list index: %s", status.StatusListIndex)
+	}
+
+	return bits.Get(index)
+}
+
+// HandleRevokeCredential 处理撤销凭证请求，请求方必须以凭证颁发者的 DID 认证
+func (s *SimpleService) HandleRevokeCredential(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RevokeCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.CredentialID == "" {
+		http.Error(w, "credentialId is required", http.StatusBadRequest)
+		return
+	}
+
+	// 只有颁发者可以撤销凭证，运维人员通过管理接口强制撤销
+	caller, ok := didauth.DIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "DID authentication is required", http.StatusUnauthorized)
+		return
+	}
+	s.mutex.RLock()
+	credential, exists := s.credentials[req.CredentialID]
+	s.mutex.RUnlock()
+	if !exists {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+	if credential.Issuer != caller {
+		http.Error(w, "only the credential issuer can revoke it", http.StatusForbidden)
+		return
+	}
+
+	if err := s.RevokeCredentialContext(r.Context(), req.CredentialID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to revoke credential: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := RevokeCredentialResponse{
+		Success:      true,
+		CredentialID: req.CredentialID,
+		Timestamp:    time.Now().Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandleStatusList 提供签名的 StatusList2021 凭证
+func (s *SimpleService) HandleStatusList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	listID := strings.TrimPrefix(r.URL.Path, statusListPath)
+	if listID == "" {
+		http.Error(w, "status list id is required", http.StatusBadRequest)
+		return
+	}
+
+	issuer, bits, err := s.status.snapshot(listID)
+	if err != nil {
+		http.Error(w, "Status list not found", http.StatusNotFound)
+		return
+	}
+	// 状态列表由分配条目的颁发者签名，服务器颁发者和游戏颁发者各自维护列表
+	signer, verificationMethod, err := s.issuerSigner(issuer)
+	if err != nil {
+		http.Error(w, "Status list issuer key unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	credential, err := vc.IssueStatusListCredential(issuer, s.statusListURL(listID), bits, signer, verificationMethod)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to issue status list: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(credential)
+}
@@ -0,0 +1,232 @@
+package vc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/czh0526/game/server/internal/did"
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/internal/kms"
+	gamestorage "github.com/czh0526/game/server/internal/storage"
+	pkgdid "github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+func newTestService(t *testing.T) *SimpleService {
+	t.Helper()
+	service, err := NewSimpleService(did.NewSimpleService())
+	if err != nil {
+		t.Fatalf("NewSimpleService: %v", err)
+	}
+	return service
+}
+
+func newTestPlayerDID(t *testing.T) string {
+	t.Helper()
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkgdid.NewKeyDID(publicKey)
+}
+
+func revokeRequest(t *testing.T, service *SimpleService, caller, credentialID string) int {
+	t.Helper()
+	body, _ := json.Marshal(RevokeCredentialRequest{CredentialID: credentialID})
+	req := httptest.NewRequest(http.MethodPost, "/api/vc/revoke", bytes.NewReader(body))
+	if caller != "" {
+		req = req.WithContext(didauth.WithDID(req.Context(), caller))
+	}
+	rec := httptest.NewRecorder()
+	service.HandleRevokeCredential(rec, req)
+	return rec.Code
+}
+
+func TestHandleRevokeCredentialRequiresIssuer(t *testing.T) {
+	service := newTestService(t)
+	holder := newTestPlayerDID(t)
+	credential, err := service.IssueCredential(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 3}, nil)
+	if err != nil {
+		t.Fatalf("IssueCredential: %v", err)
+	}
+
+	if code := revokeRequest(t, service, "", credential.ID); code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated revoke: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := revokeRequest(t, service, holder, credential.ID); code != http.StatusForbidden {
+		t.Errorf("revoke by holder: status %d, want %d", code, http.StatusForbidden)
+	}
+	if valid, message := service.VerifyCredential(credential); !valid {
+		t.Fatalf("credential revoked by a non-issuer: %s", message)
+	}
+
+	if code := revokeRequest(t, service, service.IssuerDID(), credential.ID); code != http.StatusOK {
+		t.Fatalf("revoke by issuer: status %d, want %d", code, http.StatusOK)
+	}
+	if valid, _ := service.VerifyCredential(credential); valid {
+		t.Error("revoked credential still verifies")
+	}
+}
+
+func TestHandleRevokeCredentialUnknown(t *testing.T) {
+	service := newTestService(t)
+	if code := revokeRequest(t, service, service.IssuerDID(), "urn:uuid:unknown"); code != http.StatusNotFound {
+		t.Errorf("status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestStatusListsSurviveRestart(t *testing.T) {
+	provider := gamestorage.NewMemoryProvider()
+	keys, err := kms.NewLocalKMS("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder := newTestPlayerDID(t)
+
+	before, err := NewSimpleServiceWithKeyRing(did.NewSimpleService(), keys, provider)
+	if err != nil {
+		t.Fatalf("NewSimpleServiceWithKeyRing: %v", err)
+	}
+	revoked, err := before.IssueCredential(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := before.IssueCredential(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := before.RevokeCredential(revoked.ID); err != nil {
+		t.Fatalf("RevokeCredential: %v", err)
+	}
+
+	after, err := NewSimpleServiceWithKeyRing(did.NewSimpleService(), keys, provider)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if valid, _ := after.VerifyCredential(revoked); valid {
+		t.Error("revocation was lost across restart")
+	}
+	if valid, message := after.VerifyCredential(kept); !valid {
+		t.Errorf("credential issued before restart no longer verifies: %s", message)
+	}
+
+	issued, err := after.IssueCredential(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 3}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, old := range []*vc.SimpleCredential{revoked, kept} {
+		if issued.CredentialStatus.ID == old.CredentialStatus.ID {
+			t.Fatalf("status entry %s reused after restart", issued.CredentialStatus.ID)
+		}
+	}
+	if err := after.RevokeCredential(issued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if valid, message := after.VerifyCredential(kept); !valid {
+		t.Errorf("revoking a new credential revoked an old one: %s", message)
+	}
+}
+
+func TestStatusRegistryNeverReusesListIDs(t *testing.T) {
+	provider := gamestorage.NewMemoryProvider()
+	registry, err := openStatusRegistry(provider, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		listID, index, err := registry.allocate("did:example:issuer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := listID + "#" + strconv.Itoa(index)
+		if seen[entry] {
+			t.Fatalf("entry %s allocated twice", entry)
+		}
+		seen[entry] = true
+	}
+
+	reopened, err := openStatusRegistry(provider, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listID, index, err := reopened.allocate("did:example:issuer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := listID + "#" + strconv.Itoa(index); seen[entry] {
+		t.Fatalf("entry %s reused after reopening", entry)
+	}
+	if listID, _, _ := reopened.allocate("did:example:other"); listID != "3" {
+		t.Errorf("new issuer got list %s, want 3", listID)
+	}
+}
+
+func TestCheckRemoteStatusRefusesUntrustedAndInternalURLs(t *testing.T) {
+	service := newTestService(t)
+	fetched := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+	}))
+	defer server.Close()
+
+	credential := &vc.SimpleCredential{
+		Issuer: "did:web:issuer.example.com",
+		CredentialStatus: &vc.CredentialStatus{
+			Type:                 vc.StatusList2021EntryType,
+			StatusPurpose:        vc.StatusPurposeRevocation,
+			StatusListIndex:      "1",
+			StatusListCredential: server.URL + "/status/1",
+		},
+	}
+	if _, err := service.checkStatus(context.Background(), credential); err == nil {
+		t.Fatal("fetched the status list of an untrusted issuer")
+	}
+
+	// 登记的颁发者也不能让服务器访问回环地址
+	if _, err := service.trust.put(TrustedIssuer{DID: credential.Issuer}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.checkStatus(context.Background(), credential); err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Fatalf("status list fetch from a loopback address: %v", err)
+	}
+	credential.CredentialStatus.StatusListCredential = "file:///etc/passwd"
+	if _, err := service.checkStatus(context.Background(), credential); err == nil {
+		t.Fatal("fetched a status list from a file URL")
+	}
+	if fetched != 0 {
+		t.Errorf("status list server was contacted %d times", fetched)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+	for address, want := range tests {
+		if got := publicIP(net.ParseIP(address)); got != want {
+			t.Errorf("publicIP(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
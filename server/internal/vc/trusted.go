@@ -0,0 +1,108 @@
+package vc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/czh0526/game/server/internal/tracing"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// VerifyTrustedCredential 验证其他游戏颁发的凭证：颁发者必须在信任列表中，
+// 凭证必须带有颁发者签名的证明，并检查有效期和撤销状态
+func (s *SimpleService) VerifyTrustedCredential(ctx context.Context, credential *vc.SimpleCredential, trustedIssuers []string) (valid bool, message string) {
+	ctx, span := tracing.Start(ctx, "vc.verify_trusted")
+	defer func() {
+		observeVerification(vc.FormatLDPVC, valid)
+		span.SetAttributes(tracing.Bool("credential.valid", valid))
+		span.End()
+	}()
+
+	if credential == nil {
+		return false, "credential is nil"
+	}
+
+	trusted := false
+	for _, issuer := range trustedIssuers {
+		if issuer == credential.Issuer {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return false, "issuer is not trusted"
+	}
+	// 信任登记表中对该颁发者的类型和年龄限制同样适用
+	if valid, message := s.evaluateTrust(credential, true); !valid {
+		return valid, message
+	}
+
+	if valid, message := vc.VerifyCredential(credential, credential.Issuer); !valid {
+		return valid, message
+	}
+	if s.subjectDeactivated(ctx, credential) {
+		return false, "credential subject DID has been deactivated"
+	}
+
+	// 外部凭证不在本地登记表中，只能通过证明验证
+	if credential.Proof == nil {
+		return false, "credential has no proof"
+	}
+	if !strings.HasPrefix(credential.Proof.VerificationMethod, credential.Issuer+"#") {
+		return false, "verification method does not belong to issuer"
+	}
+
+	if isBBSProof(credential.Proof) {
+		if err := s.verifyBBSProof(ctx, credential); err != nil {
+			return false, err.Error()
+		}
+	} else {
+		publicKey, err := s.resolveIssuerKey(ctx, credential.Issuer, credential.Proof.VerificationMethod)
+		if err != nil {
+			return false, fmt.Sprintf("resolve verification method: %v", err)
+		}
+		if err := vc.VerifyProof(credential, publicKey); err != nil {
+			return false, fmt.Sprintf("invalid proof: %v", err)
+		}
+	}
+
+	if credential.CredentialStatus != nil {
+		revoked, err := s.checkStatus(ctx, credential)
+		if err != nil {
+			return false, fmt.Sprintf("check credential status: %v", err)
+		}
+		if revoked {
+			return false, "credential has been revoked"
+		}
+	}
+
+	return true, "credential is valid"
+}
+
+// resolveIssuerKey 按 DID 方法解析颁发者的 Ed25519 公钥，外部颁发者的 did:web 文档通过 HTTPS 获取
+func (s *SimpleService) resolveIssuerKey(ctx context.Context, issuerDID, verificationMethod string) (ed25519.PublicKey, error) {
+	resolved, err := s.didService.ResolveDIDContext(ctx, issuerDID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, method := range resolved.DIDDoc.VerificationMethod {
+		if method.ID != verificationMethod {
+			continue
+		}
+
+		publicKey, err := hex.DecodeString(method.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("decode public key: %w", err)
+		}
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key: %s", verificationMethod)
+		}
+		return ed25519.PublicKey(publicKey), nil
+	}
+
+	return nil, fmt.Errorf("verification method not found: %s", verificationMethod)
+}
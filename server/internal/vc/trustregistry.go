@@ -0,0 +1,250 @@
+package vc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// trustRegistryStoreName 信任登记表的存储名称
+const trustRegistryStoreName = "trust_registry"
+
+// trustedIssuerTag 信任策略记录的标签，用于加载全部记录
+const trustedIssuerTag = "trusted_issuer"
+
+// AnyCredentialType MaxAge 中适用于未单独列出的凭证类型的键
+const AnyCredentialType = "*"
+
+// ErrTrustedIssuerNotFound 颁发者不在信任登记表中
+var ErrTrustedIssuerNotFound = errors.New("trusted issuer not found")
+
+// TrustedIssuer 信任登记表中一个颁发者的策略。本地颁发者（服务器和各游戏的颁发者）不登记也被信任，
+// 登记后同样受类型和年龄限制；其他颁发者必须登记，其凭证通过解析颁发者 DID 文档验证
+type TrustedIssuer struct {
+	DID  string `json:"did"`
+	Name string `json:"name,omitempty"`
+	// Types 接受的凭证类型，为空时接受所有类型
+	Types []string `json:"types,omitempty"`
+	// MaxAge 按凭证类型限制自颁发起的最长时间，值为时长如 "720h"，键 "*" 适用于其他类型
+	MaxAge    map[string]string `json:"maxAge,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// validate 校验颁发者 DID 和最长时间
+func (t *TrustedIssuer) validate() error {
+	if !strings.HasPrefix(t.DID, "did:") {
+		return fmt.Errorf("invalid issuer DID: %q", t.DID)
+	}
+	for credType, value := range t.MaxAge {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			return fmt.Errorf("maxAge for %s must be a positive duration, got %q", credType, value)
+		}
+	}
+	return nil
+}
+
+// check 检查凭证的类型和年龄是否满足策略，满足时返回空字符串，否则返回原因
+func (t *TrustedIssuer) check(credential *vc.SimpleCredential, now time.Time) string {
+	var types []string
+	for _, credType := range credential.Type {
+		if credType != "VerifiableCredential" {
+			types = append(types, credType)
+		}
+	}
+
+	if len(t.Types) > 0 && !containsAny(t.Types, types) {
+		return fmt.Sprintf("issuer is not trusted for credential type %s", strings.Join(types, ", "))
+	}
+
+	limit, credType := t.maxAge(types)
+	if limit > 0 && now.Sub(credential.IssuanceDate) > limit {
+		return fmt.Sprintf("credential is older than the maximum age of %s for %s", limit, credType)
+	}
+	return ""
+}
+
+// maxAge 返回凭证类型适用的最长时间及其键，单独列出的类型优先于 "*"，没有限制时为 0
+func (t *TrustedIssuer) maxAge(types []string) (time.Duration, string) {
+	for _, credType := range types {
+		if value, ok := t.MaxAge[credType]; ok {
+			maxAge, _ := time.ParseDuration(value)
+			return maxAge, credType
+		}
+	}
+	if value, ok := t.MaxAge[AnyCredentialType]; ok {
+		maxAge, _ := time.ParseDuration(value)
+		return maxAge, AnyCredentialType
+	}
+	return 0, ""
+}
+
+// trustRegistry 验证凭证时接受的颁发者及其策略，保存在存储中，启动时加载
+type trustRegistry struct {
+	store   storage.Store
+	issuers map[string]*TrustedIssuer // 颁发者 DID -> 策略
+	mutex   sync.RWMutex
+}
+
+// openTrustRegistry 打开信任登记表并加载全部策略
+func openTrustRegistry(provider storage.Provider) (*trustRegistry, error) {
+	store, err := provider.OpenStore(trustRegistryStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open trust registry store: %w", err)
+	}
+
+	iter, err := store.Query(trustedIssuerTag)
+	if err != nil {
+		return nil, fmt.Errorf("query trusted issuers: %w", err)
+	}
+	defer iter.Close()
+
+	r := &trustRegistry{store: store, issuers: make(map[string]*TrustedIssuer)}
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate trusted issuers: %w", err)
+		}
+		if !more {
+			break
+		}
+
+		value, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read trusted issuer: %w", err)
+		}
+		var issuer TrustedIssuer
+		if err := json.Unmarshal(value, &issuer); err != nil {
+			return nil, fmt.Errorf("unmarshal trusted issuer: %w", err)
+		}
+		r.issuers[issuer.DID] = &issuer
+	}
+	return r, nil
+}
+
+// lookup 返回颁发者的策略
+func (r *trustRegistry) lookup(issuerDID string) (*TrustedIssuer, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	issuer, exists := r.issuers[issuerDID]
+	return issuer, exists
+}
+
+// put 登记或替换颁发者的策略
+func (r *trustRegistry) put(issuer TrustedIssuer) (*TrustedIssuer, error) {
+	if err := issuer.validate(); err != nil {
+		return nil, err
+	}
+	issuer.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(&issuer)
+	if err != nil {
+		return nil, fmt.Errorf("marshal trusted issuer: %w", err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.store.Put(trustedIssuerKey(issuer.DID), data, storage.Tag{Name: trustedIssuerTag}); err != nil {
+		return nil, fmt.Errorf("save trusted issuer: %w", err)
+	}
+	r.issuers[issuer.DID] = &issuer
+	copied := issuer
+	return &copied, nil
+}
+
+// remove 删除颁发者的策略
+func (r *trustRegistry) remove(issuerDID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.issuers[issuerDID]; !exists {
+		return ErrTrustedIssuerNotFound
+	}
+	if err := r.store.Delete(trustedIssuerKey(issuerDID)); err != nil {
+		return fmt.Errorf("delete trusted issuer: %w", err)
+	}
+	delete(r.issuers, issuerDID)
+	return nil
+}
+
+// list 按颁发者 DID 返回全部策略
+func (r *trustRegistry) list() []TrustedIssuer {
+	r.mutex.RLock()
+	issuers := make([]TrustedIssuer, 0, len(r.issuers))
+	for _, issuer := range r.issuers {
+		issuers = append(issuers, *issuer)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(issuers, func(i, j int) bool { return issuers[i].DID < issuers[j].DID })
+	return issuers
+}
+
+func trustedIssuerKey(issuerDID string) string {
+	return "issuer:" + issuerDID
+}
+
+// TrustedIssuers 返回信任登记表中的全部颁发者策略
+func (s *SimpleService) TrustedIssuers() []TrustedIssuer {
+	return s.trust.list()
+}
+
+// SetTrustedIssuer 登记颁发者或替换其策略，之后验证该颁发者的凭证时生效
+func (s *SimpleService) SetTrustedIssuer(issuer TrustedIssuer) (*TrustedIssuer, error) {
+	saved, err := s.trust.put(issuer)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Trusted issuer updated", "issuer", saved.DID, "types", saved.Types)
+	return saved, nil
+}
+
+// RemoveTrustedIssuer 从信任登记表删除颁发者。本地颁发者删除后恢复为不受限制地信任，其他颁发者的凭证不再被接受
+func (s *SimpleService) RemoveTrustedIssuer(issuerDID string) error {
+	if err := s.trust.remove(issuerDID); err != nil {
+		return err
+	}
+	slog.Info("Trusted issuer removed", "issuer", issuerDID)
+	return nil
+}
+
+// evaluateTrust 按信任策略检查凭证的颁发者、类型和年龄。implicit 为 true 时不在登记表中的颁发者也被接受，
+// 用于本地颁发者和调用方已确认信任的颁发者
+func (s *SimpleService) evaluateTrust(credential *vc.SimpleCredential, implicit bool) (bool, string) {
+	if credential == nil {
+		return false, "credential is nil"
+	}
+
+	issuer, registered := s.trust.lookup(credential.Issuer)
+	if !registered {
+		if implicit {
+			return true, ""
+		}
+		return false, "issuer is not trusted"
+	}
+	if reason := issuer.check(credential, time.Now()); reason != "" {
+		return false, reason
+	}
+	return true, ""
+}
+
+// containsAny 判断 values 中是否有元素在 allowed 中
+func containsAny(allowed, values []string) bool {
+	for _, value := range values {
+		if containsString(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
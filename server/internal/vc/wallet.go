@@ -0,0 +1,227 @@
+package vc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// 钱包列表分页大小
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// CredentialQuery 玩家凭证查询条件
+type CredentialQuery struct {
+	PlayerDID    string
+	Type         string     // 为空表示所有类型
+	IssuedAfter  *time.Time // 只返回此时间之后颁发的凭证
+	IssuedBefore *time.Time // 只返回此时间之前颁发的凭证
+	After        string     // 上一页返回的 nextCursor
+	Limit        int
+}
+
+// CredentialListItem 钱包中的一条凭证及其当前状态
+type CredentialListItem struct {
+	Credential *vc.SimpleCredential `json:"credential"`
+	Revoked    bool                 `json:"revoked"`
+	Expired    bool                 `json:"expired"`
+}
+
+// ListCredentialsResponse 凭证列表响应
+type ListCredentialsResponse struct {
+	Credentials []CredentialListItem `json:"credentials"`
+	NextCursor  string               `json:"nextCursor,omitempty"`
+}
+
+// GetPlayerCredentials 返回颁发给玩家的所有凭证，按颁发时间从新到旧排序
+func (s *SimpleService) GetPlayerCredentials(playerDID string) []*vc.SimpleCredential {
+	s.mutex.RLock()
+	var credentials []*vc.SimpleCredential
+	for _, credential := range s.credentials {
+		if credential.CredentialSubject.ID == playerDID {
+			credentials = append(credentials, credential)
+		}
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentialBefore(credentials[j], credentials[i])
+	})
+	return credentials
+}
+
+// ListPlayerCredentials 按条件分页查询玩家凭证，并附带撤销和过期状态
+func (s *SimpleService) ListPlayerCredentials(ctx context.Context, query CredentialQuery) (*ListCredentialsResponse, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	var cursor *vc.SimpleCredential
+	if query.After != "" {
+		var err error
+		if cursor, err = decodeCursor(query.After); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	response := &ListCredentialsResponse{Credentials: []CredentialListItem{}}
+	for _, credential := range s.GetPlayerCredentials(query.PlayerDID) {
+		// 列表从新到旧，游标之前（更新）的凭证已在前几页返回
+		if cursor != nil && !credentialBefore(credential, cursor) {
+			continue
+		}
+		if query.Type != "" && !hasType(credential, query.Type) {
+			continue
+		}
+		if query.IssuedAfter != nil && !credential.IssuanceDate.After(*query.IssuedAfter) {
+			continue
+		}
+		if query.IssuedBefore != nil && !credential.IssuanceDate.Before(*query.IssuedBefore) {
+			continue
+		}
+
+		if len(response.Credentials) == limit {
+			last := response.Credentials[limit-1].Credential
+			response.NextCursor = encodeCursor(last)
+			break
+		}
+
+		item := CredentialListItem{
+			Credential: credential,
+			Expired:    credential.ExpirationDate != nil && credential.ExpirationDate.Before(now),
+		}
+		if credential.CredentialStatus != nil {
+			revoked, err := s.checkStatus(ctx, credential)
+			if err != nil {
+				return nil, fmt.Errorf("check status of %s: %w", credential.ID, err)
+			}
+			item.Revoked = revoked
+		}
+		response.Credentials = append(response.Credentials, item)
+	}
+
+	return response, nil
+}
+
+// HandleListCredentials 处理 GET /api/vc/list?did=...&type=...&issuedAfter=...&issuedBefore=...&after=...&limit=...，
+// 只列出已认证请求方自己的凭证，did 可省略，指定时必须是请求方 DID
+func (s *SimpleService) HandleListCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := didauth.DIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "DID authentication is required", http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+	query := CredentialQuery{
+		PlayerDID: caller,
+		Type:      params.Get("type"),
+		After:     params.Get("after"),
+	}
+	if requested := params.Get("did"); requested != "" && requested != caller {
+		http.Error(w, "credentials can only be listed for the authenticated DID", http.StatusForbidden)
+		return
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+
+	var err error
+	if query.IssuedAfter, err = parseTimeParam(params.Get("issuedAfter")); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid issuedAfter: %v", err), http.StatusBadRequest)
+		return
+	}
+	if query.IssuedBefore, err = parseTimeParam(params.Get("issuedBefore")); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid issuedBefore: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.ListPlayerCredentials(r.Context(), query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list credentials: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// credentialBefore 列表排序：a 颁发早于 b，颁发时间相同时按 ID 排序
+func credentialBefore(a, b *vc.SimpleCredential) bool {
+	if !a.IssuanceDate.Equal(b.IssuanceDate) {
+		return a.IssuanceDate.Before(b.IssuanceDate)
+	}
+	return a.ID < b.ID
+}
+
+func hasType(credential *vc.SimpleCredential, credType string) bool {
+	for _, t := range credential.Type {
+		if t == credType {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeCursor 游标记录上一页最后一条凭证的颁发时间和 ID
+func encodeCursor(credential *vc.SimpleCredential) string {
+	raw := credential.IssuanceDate.UTC().Format(time.RFC3339Nano) + "|" + credential.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*vc.SimpleCredential, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	issued, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, errors.New("invalid cursor")
+	}
+	issuanceDate, err := time.Parse(time.RFC3339Nano, issued)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &vc.SimpleCredential{ID: id, IssuanceDate: issuanceDate}, nil
+}
+
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,50 @@
+package vc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/czh0526/game/server/internal/didauth"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+func listRequest(service *SimpleService, caller, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if caller != "" {
+		req = req.WithContext(didauth.WithDID(req.Context(), caller))
+	}
+	rec := httptest.NewRecorder()
+	service.HandleListCredentials(rec, req)
+	return rec
+}
+
+func TestHandleListCredentialsReturnsOnlyCallerCredentials(t *testing.T) {
+	service := newTestService(t)
+	alice, bob := newTestPlayerDID(t), newTestPlayerDID(t)
+	for _, holder := range []string{alice, bob} {
+		if _, err := service.IssueCredential(holder, "LevelCredential", vc.CredentialSubject{GameID: "test", Level: 3}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := listRequest(service, alice, "/api/vc/list")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var response ListCredentialsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Credentials) != 1 || response.Credentials[0].Credential.CredentialSubject.ID != alice {
+		t.Fatalf("listed %+v, want only alice's credential", response.Credentials)
+	}
+
+	if rec := listRequest(service, "", "/api/vc/list?did="+alice); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated list: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := listRequest(service, bob, "/api/vc/list?did="+alice); rec.Code != http.StatusForbidden {
+		t.Errorf("listing another player's credentials: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,42 @@
+package vc
+
+import (
+	"github.com/czh0526/game/server/internal/webhook"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// SetWebhooks 启用 webhook，每次成功颁发凭证后推送 credential_issued 事件。应在处理请求前调用
+func (s *SimpleService) SetWebhooks(dispatcher *webhook.Dispatcher) {
+	s.webhooks = dispatcher
+}
+
+// IssuedNotifier 将新颁发的凭证通知给持有者，不能阻塞颁发
+type IssuedNotifier interface {
+	NotifyCredentialIssued(credential *vc.SimpleCredential)
+}
+
+// SetIssuedNotifier 设置凭证颁发通知，每次成功颁发凭证后调用。应在处理请求前调用
+func (s *SimpleService) SetIssuedNotifier(notifier IssuedNotifier) {
+	s.issuedNotifier = notifier
+}
+
+// publishIssued 推送 credential_issued 事件并通知持有者，颁发失败时不推送。
+// 载荷只包含凭证的元数据，不包含凭证主体，避免泄露选择性披露凭证中未披露的字段
+func (s *SimpleService) publishIssued(credential *vc.SimpleCredential, format string, err error) {
+	if err != nil || credential == nil {
+		return
+	}
+	if s.issuedNotifier != nil {
+		s.issuedNotifier.NotifyCredentialIssued(credential)
+	}
+	s.webhooks.Publish(webhook.EventCredentialIssued, map[string]interface{}{
+		"credentialId":   credential.ID,
+		"type":           credential.Type,
+		"format":         format,
+		"issuer":         credential.Issuer,
+		"holder":         credential.CredentialSubject.ID,
+		"gameId":         credential.CredentialSubject.GameID,
+		"issuanceDate":   credential.IssuanceDate,
+		"expirationDate": credential.ExpirationDate,
+	})
+}
@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/czh0526/game/server/internal/logging"
+	"github.com/czh0526/game/server/internal/metrics"
+)
+
+// 投递请求头
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature sha256=<hex>，见 Sign
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// 投递结果，作为指标标签
+const (
+	resultDelivered = "delivered"
+	resultRetried   = "retried"
+	resultFailed    = "failed"
+	resultDropped   = "dropped"
+)
+
+var deliveriesTotal = metrics.NewCounterVec("webhook_deliveries_total", "Webhook delivery attempts by event type and result.", "event", "result")
+
+// delivery 一次事件投递，重试时保持投递 ID 和请求体不变
+type delivery struct {
+	id           string
+	event        Event
+	body         []byte
+	subscription *Subscription
+	attempt      int
+}
+
+// Sign 返回请求的签名：以订阅的签名密钥对 "{timestamp}.{body}" 计算 HMAC-SHA256。
+// 订阅方应以同样方式计算并比较签名，并拒绝时间戳过旧的请求以防止重放
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueue 将投递加入队列，队列已满或已关闭时丢弃
+func (d *Dispatcher) enqueue(job *delivery) {
+	select {
+	case <-d.stopCh:
+		return
+	default:
+	}
+
+	select {
+	case d.queue <- job:
+	default:
+		deliveriesTotal.With(job.event.Type, resultDropped).Inc()
+		slog.Warn("Webhook queue full, dropping delivery", "event", job.event.Type, "delivery_id", job.id, "subscription_id", job.subscription.ID)
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case job := <-d.queue:
+			d.attempt(job)
+		}
+	}
+}
+
+// attempt 投递一次，失败且可重试时在退避时间后重新入队
+func (d *Dispatcher) attempt(job *delivery) {
+	job.attempt++
+	logger := slog.Default().With("event", job.event.Type, "delivery_id", job.id, "subscription_id", job.subscription.ID, "attempt", job.attempt)
+
+	retryable, err := d.send(job)
+	if err == nil {
+		deliveriesTotal.With(job.event.Type, resultDelivered).Inc()
+		logger.Debug("Webhook delivered")
+		return
+	}
+
+	if !retryable || job.attempt >= d.config.MaxAttempts {
+		deliveriesTotal.With(job.event.Type, resultFailed).Inc()
+		logger.Error("Webhook delivery failed", "url", job.subscription.URL, logging.Err(err))
+		return
+	}
+
+	backoff := d.backoff(job.attempt)
+	deliveriesTotal.With(job.event.Type, resultRetried).Inc()
+	logger.Warn("Webhook delivery failed, retrying", "url", job.subscription.URL, "backoff", backoff, logging.Err(err))
+	time.AfterFunc(backoff, func() { d.enqueue(job) })
+}
+
+// backoff 第 attempt 次失败后的等待时间，从 InitialBackoff 开始每次翻倍，不超过 MaxBackoff
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := d.config.InitialBackoff
+	for i := 1; i < attempt && backoff < d.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.config.MaxBackoff {
+		backoff = d.config.MaxBackoff
+	}
+	return backoff
+}
+
+// send 发送签名后的请求，2xx 视为成功。网络错误、超时、408、429 和 5xx 可以重试
+func (d *Dispatcher) send(job *delivery) (retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, job.subscription.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, job.event.Type)
+	req.Header.Set(HeaderDelivery, job.id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(job.subscription.Secret, timestamp, job.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retryable, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
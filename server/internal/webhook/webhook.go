@@ -0,0 +1,386 @@
+// Package webhook 向外部服务推送游戏事件：外部服务为感兴趣的事件类型登记回调地址，
+// 服务器以带签名的 JSON 请求投递事件，失败时按指数退避重试。订阅保存在存储提供者中
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/czh0526/game/server/internal/logging"
+)
+
+// storeName 订阅的存储名称
+const storeName = "webhooks"
+
+// 记录的标签，按类型查询
+const (
+	recordTypeTag          = "type"
+	recordTypeSubscription = "subscription"
+)
+
+// secretSize 自动生成的签名密钥字节数
+const secretSize = 32
+
+// 事件类型
+const (
+	// EventPlayerJoined 玩家加入房间
+	EventPlayerJoined = "player_joined"
+	// EventTaskCompleted 玩家完成任务
+	EventTaskCompleted = "task_completed"
+	// EventCredentialIssued 服务器颁发了凭证
+	EventCredentialIssued = "credential_issued"
+	// EventMatchFinished 对局结束
+	EventMatchFinished = "match_finished"
+)
+
+// EventTypes 可订阅的事件类型
+var EventTypes = []string{EventPlayerJoined, EventTaskCompleted, EventCredentialIssued, EventMatchFinished}
+
+// ErrSubscriptionNotFound 订阅不存在
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// Config 投递配置
+type Config struct {
+	Workers         int           // 并发投递的协程数
+	QueueSize       int           // 待投递队列长度，队列满时丢弃新的投递
+	Timeout         time.Duration // 单次请求超时
+	MaxAttempts     int           // 每次投递的最多尝试次数，包括第一次
+	InitialBackoff  time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxBackoff      time.Duration // 重试等待时间上限
+	RefreshInterval time.Duration // 从存储重新加载订阅的间隔，多个实例共享存储时使其他实例登记的订阅生效
+}
+
+// DefaultConfig 返回默认投递配置
+func DefaultConfig() Config {
+	return Config{
+		Workers:         4,
+		QueueSize:       1024,
+		Timeout:         10 * time.Second,
+		MaxAttempts:     5,
+		InitialBackoff:  time.Second,
+		MaxBackoff:      5 * time.Minute,
+		RefreshInterval: 30 * time.Second,
+	}
+}
+
+// Subscription 外部服务的事件订阅
+type Subscription struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret 签名密钥，只在登记时返回
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// subscribed 订阅是否包含事件类型
+func (s *Subscription) subscribed(eventType string) bool {
+	for _, event := range s.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event 投递给订阅方的事件，作为请求体
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher 管理订阅并投递事件。nil 的 Dispatcher 不投递任何事件，未启用 webhook 的服务可以直接调用 Publish
+type Dispatcher struct {
+	store  storage.Store
+	config Config
+	client *http.Client
+
+	subscriptions map[string]*Subscription
+	mutex         sync.RWMutex
+
+	queue  chan *delivery
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// NewDispatcher 加载已登记的订阅并启动投递协程
+func NewDispatcher(provider storage.Provider, config Config) (*Dispatcher, error) {
+	if provider == nil {
+		return nil, errors.New("storage provider is required")
+	}
+
+	store, err := provider.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open webhook store: %w", err)
+	}
+
+	defaults := DefaultConfig()
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+
+	d := &Dispatcher{
+		store:  store,
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// 不跟随重定向，订阅方应登记最终地址
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		queue:  make(chan *delivery, config.QueueSize),
+		stopCh: make(chan struct{}),
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	if config.RefreshInterval > 0 {
+		d.wg.Add(1)
+		go d.refresh()
+	}
+	return d, nil
+}
+
+// Close 停止投递协程，尚未完成的投递和重试被丢弃
+func (d *Dispatcher) Close() error {
+	d.once.Do(func() {
+		close(d.stopCh)
+	})
+	d.wg.Wait()
+	return nil
+}
+
+// Subscribe 为 url 登记事件订阅，secret 为空时自动生成。返回的订阅包含签名密钥
+func (d *Dispatcher) Subscribe(callbackURL string, events []string, secret string) (*Subscription, error) {
+	if err := validateURL(callbackURL); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.New("at least one event type is required")
+	}
+	for _, event := range events {
+		if !knownEvent(event) {
+			return nil, fmt.Errorf("unknown event type: %s", event)
+		}
+	}
+	if secret == "" {
+		raw := make([]byte, secretSize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("generate webhook secret: %w", err)
+		}
+		secret = hex.EncodeToString(raw)
+	}
+
+	subscription := &Subscription{
+		ID:        uuid.New().String(),
+		URL:       callbackURL,
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook subscription: %w", err)
+	}
+	if err := d.store.Put(subscriptionKey(subscription.ID), data, storage.Tag{Name: recordTypeTag, Value: recordTypeSubscription}); err != nil {
+		return nil, fmt.Errorf("save webhook subscription: %w", err)
+	}
+
+	d.mutex.Lock()
+	d.subscriptions[subscription.ID] = subscription
+	d.mutex.Unlock()
+
+	slog.Info("Webhook subscription registered", "subscription_id", subscription.ID, "url", callbackURL, "events", events)
+	copied := *subscription
+	return &copied, nil
+}
+
+// Unsubscribe 删除订阅，已在队列中的投递仍会完成。订阅可以由共享存储的其他实例登记
+func (d *Dispatcher) Unsubscribe(id string) error {
+	if _, err := d.store.Get(subscriptionKey(id)); errors.Is(err, storage.ErrDataNotFound) {
+		return ErrSubscriptionNotFound
+	} else if err != nil {
+		return fmt.Errorf("get webhook subscription: %w", err)
+	}
+
+	d.mutex.Lock()
+	delete(d.subscriptions, id)
+	d.mutex.Unlock()
+
+	if err := d.store.Delete(subscriptionKey(id)); err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+
+	slog.Info("Webhook subscription removed", "subscription_id", id)
+	return nil
+}
+
+// Subscriptions 按登记时间返回所有订阅，不包含签名密钥
+func (d *Dispatcher) Subscriptions() []Subscription {
+	d.mutex.RLock()
+	subscriptions := make([]Subscription, 0, len(d.subscriptions))
+	for _, subscription := range d.subscriptions {
+		copied := *subscription
+		copied.Secret = ""
+		subscriptions = append(subscriptions, copied)
+	}
+	d.mutex.RUnlock()
+
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt) })
+	return subscriptions
+}
+
+// Publish 将事件投递给订阅了该类型的所有订阅方，不等待投递完成
+func (d *Dispatcher) Publish(eventType string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	d.mutex.RLock()
+	var targets []*Subscription
+	for _, subscription := range d.subscriptions {
+		if subscription.subscribed(eventType) {
+			targets = append(targets, subscription)
+		}
+	}
+	d.mutex.RUnlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	event := Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to marshal webhook event", "event", eventType, logging.Err(err))
+		return
+	}
+
+	for _, subscription := range targets {
+		d.enqueue(&delivery{
+			id:           uuid.New().String(),
+			event:        event,
+			body:         body,
+			subscription: subscription,
+		})
+	}
+}
+
+// load 从存储加载所有订阅，替换内存中的订阅
+func (d *Dispatcher) load() error {
+	iter, err := d.store.Query(fmt.Sprintf("%s:%s", recordTypeTag, recordTypeSubscription))
+	if err != nil {
+		return fmt.Errorf("query webhook subscriptions: %w", err)
+	}
+	defer iter.Close()
+
+	subscriptions := make(map[string]*Subscription)
+	for {
+		more, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate webhook subscriptions: %w", err)
+		}
+		if !more {
+			break
+		}
+
+		value, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read webhook subscription: %w", err)
+		}
+		var subscription Subscription
+		if err := json.Unmarshal(value, &subscription); err != nil {
+			return fmt.Errorf("unmarshal webhook subscription: %w", err)
+		}
+		subscriptions[subscription.ID] = &subscription
+	}
+
+	d.mutex.Lock()
+	d.subscriptions = subscriptions
+	d.mutex.Unlock()
+	return nil
+}
+
+// refresh 定期从存储重新加载订阅
+func (d *Dispatcher) refresh() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-ticker.C:
+			if err := d.load(); err != nil {
+				slog.Error("Failed to reload webhook subscriptions", logging.Err(err))
+			}
+		}
+	}
+}
+
+// validateURL 回调地址必须是 http 或 https 的绝对地址
+func validateURL(callbackURL string) error {
+	parsed, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback URL: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("callback URL must be an absolute http or https URL: %s", callbackURL)
+	}
+	return nil
+}
+
+func knownEvent(eventType string) bool {
+	for _, known := range EventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func subscriptionKey(id string) string {
+	return "subscription:" + id
+}
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gamestorage "github.com/czh0526/game/server/internal/storage"
+)
+
+// receivedRequest 订阅方收到的一次投递
+type receivedRequest struct {
+	delivery  string
+	signature string
+	timestamp int64
+	body      []byte
+}
+
+func TestPublishRetriesWithSignedRequests(t *testing.T) {
+	var mutex sync.Mutex
+	var received []receivedRequest
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		mutex.Lock()
+		defer mutex.Unlock()
+		received = append(received, receivedRequest{r.Header.Get(HeaderDelivery), r.Header.Get(HeaderSignature), timestamp, body})
+		// 第一次返回 503，重试后成功
+		if len(received) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		close(done)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.InitialBackoff = 10 * time.Millisecond
+	dispatcher, err := NewDispatcher(gamestorage.NewMemoryProvider(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dispatcher.Close()
+
+	subscription, err := dispatcher.Subscribe(server.URL, []string{EventTaskCompleted}, "secret")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	dispatcher.Publish(EventPlayerJoined, map[string]string{"playerId": "alice"})
+	dispatcher.Publish(EventTaskCompleted, map[string]string{"taskId": "welcome"})
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(received) != 2 || received[0].delivery != received[1].delivery {
+		t.Fatalf("expected one delivery retried once, got %d requests", len(received))
+	}
+	for _, request := range received {
+		if request.signature != Sign(subscription.Secret, request.timestamp, request.body) {
+			t.Error("request signature does not match the body")
+		}
+	}
+	var event Event
+	if err := json.Unmarshal(received[1].body, &event); err != nil || event.Type != EventTaskCompleted {
+		t.Errorf("delivered event %s, %v", received[1].body, err)
+	}
+}
+
+func TestSubscribeValidates(t *testing.T) {
+	dispatcher, err := NewDispatcher(gamestorage.NewMemoryProvider(), DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dispatcher.Close()
+
+	tests := map[string]struct {
+		url    string
+		events []string
+	}{
+		"relative URL":  {"/hooks", []string{EventTaskCompleted}},
+		"other scheme":  {"ftp://example.com/hooks", []string{EventTaskCompleted}},
+		"no events":     {"https://example.com/hooks", nil},
+		"unknown event": {"https://example.com/hooks", []string{"player_left"}},
+	}
+	for name, tt := range tests {
+		if _, err := dispatcher.Subscribe(tt.url, tt.events, ""); err == nil {
+			t.Errorf("%s: subscription accepted", name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	dispatcher := &Dispatcher{config: Config{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, expected := range want {
+		if got := dispatcher.backoff(i + 1); got != expected {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, expected)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package bbs 封装 aries-framework-go 的 BLS12-381 BBS+ 签名原语（bbs12381g2pub）。
+// 一个签名同时覆盖一组消息，持有者可以由签名派生零知识证明，只披露其中一部分消息，
+// 验证方确认被隐藏的消息也由同一签名覆盖，但得不到它们的值；每次派生的证明互不关联。
+//
+// 公钥为 96 字节的压缩 G2 点，签名和派生证明的编码与 aries-framework-go 相同，
+// 派生证明以消息总数和披露下标的位图开头
+package bbs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hyperledger/aries-framework-go/component/kmscrypto/crypto/primitive/bbs12381g2pub"
+)
+
+// 编码长度
+const (
+	PublicKeySize = 96
+	SignatureSize = 112
+)
+
+// 验证错误
+var (
+	ErrInvalidSignature = errors.New("invalid BBS+ signature")
+	ErrInvalidProof     = errors.New("invalid BBS+ proof")
+)
+
+var scheme = bbs12381g2pub.New()
+
+// PrivateKey BBS+ 私钥
+type PrivateKey struct {
+	raw    []byte
+	public *PublicKey
+}
+
+// PublicKey BBS+ 公钥
+type PublicKey struct {
+	raw []byte
+}
+
+// GenerateKey 由 random 生成私钥
+func GenerateKey(random io.Reader) (*PrivateKey, error) {
+	seed := make([]byte, 32)
+	if _, err := io.ReadFull(random, seed); err != nil {
+		return nil, fmt.Errorf("generate key seed: %w", err)
+	}
+	return NewKeyFromSeed(seed)
+}
+
+// NewKeyFromSeed 由至少 32 字节的种子确定地派生私钥（HKDF-SHA256），同一种子总是得到同一密钥
+func NewKeyFromSeed(seed []byte) (*PrivateKey, error) {
+	if len(seed) < 32 {
+		return nil, errors.New("key seed must be at least 32 bytes")
+	}
+	publicKey, privateKey, err := bbs12381g2pub.GenerateKeyPair(sha256.New, seed)
+	if err != nil {
+		return nil, fmt.Errorf("generate key pair: %w", err)
+	}
+	raw, err := privateKey.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("encode private key: %w", err)
+	}
+	publicRaw, err := publicKey.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("encode public key: %w", err)
+	}
+	return &PrivateKey{raw: raw, public: &PublicKey{raw: publicRaw}}, nil
+}
+
+// Public 返回公钥
+func (k *PrivateKey) Public() *PublicKey {
+	return k.public
+}
+
+// PublicKeyFromBytes 解码压缩编码的公钥
+func PublicKeyFromBytes(data []byte) (*PublicKey, error) {
+	if len(data) != PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes", PublicKeySize)
+	}
+	if _, err := bbs12381g2pub.UnmarshalPublicKey(data); err != nil {
+		return nil, fmt.Errorf("decode public key: %w", err)
+	}
+	return &PublicKey{raw: append([]byte(nil), data...)}, nil
+}
+
+// Bytes 返回 96 字节的压缩编码
+func (k *PublicKey) Bytes() []byte {
+	return append([]byte(nil), k.raw...)
+}
+
+// Sign 对一组消息签名，返回 SignatureSize 字节的签名。消息的顺序是签名的一部分
+func (k *PrivateKey) Sign(messages [][]byte) ([]byte, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("no messages to sign")
+	}
+	signature, err := scheme.Sign(messages, k.raw)
+	if err != nil {
+		return nil, fmt.Errorf("sign messages: %w", err)
+	}
+	return signature, nil
+}
+
+// Verify 验证签名覆盖了全部 messages
+func (k *PublicKey) Verify(messages [][]byte, signature []byte) error {
+	if len(signature) != SignatureSize {
+		return ErrInvalidSignature
+	}
+	if err := scheme.Verify(messages, signature, k.raw); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// DeriveProof 由签名派生选择性披露证明，只披露 revealed 中的消息（按下标，从 0 开始），
+// 其余消息只证明其存在。nonce 由验证方提供，防止证明被重放。派生前先验证签名
+func (k *PublicKey) DeriveProof(messages [][]byte, signature []byte, revealed []int, nonce []byte) ([]byte, error) {
+	if err := k.Verify(messages, signature); err != nil {
+		return nil, err
+	}
+	indexes := append([]int(nil), revealed...)
+	sort.Ints(indexes)
+	for i, index := range indexes {
+		if index < 0 || index >= len(messages) || (i > 0 && indexes[i-1] == index) {
+			return nil, fmt.Errorf("invalid revealed message index: %d", index)
+		}
+	}
+
+	proof, err := scheme.DeriveProof(messages, signature, nonce, k.raw, indexes)
+	if err != nil {
+		return nil, fmt.Errorf("derive proof: %w", err)
+	}
+	return proof, nil
+}
+
+// ProofMessages 返回派生证明覆盖的消息总数和按升序排列的披露下标
+func ProofMessages(proof []byte) (int, []int, error) {
+	if len(proof) < 2 {
+		return 0, nil, ErrInvalidProof
+	}
+	messageCount := int(binary.BigEndian.Uint16(proof))
+	bitmap := proof[2:]
+	bitmapSize := messageCount/8 + 1
+	if len(bitmap) < bitmapSize {
+		return 0, nil, ErrInvalidProof
+	}
+
+	// 位图按小端存储，下标 i 对应倒数第 i/8 个字节的第 i%8 位
+	var revealed []int
+	for index := 0; index < bitmapSize*8; index++ {
+		if bitmap[bitmapSize-1-index/8]&(1<<(index%8)) == 0 {
+			continue
+		}
+		if index >= messageCount {
+			return 0, nil, ErrInvalidProof
+		}
+		revealed = append(revealed, index)
+	}
+	return messageCount, revealed, nil
+}
+
+// VerifyProof 验证 DeriveProof 生成的证明，revealed 为按下标升序排列的披露消息，
+// 条数须与证明中的披露下标一致；nonce 须与派生时相同
+func (k *PublicKey) VerifyProof(revealed [][]byte, proof, nonce []byte) error {
+	_, indexes, err := ProofMessages(proof)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 0 || len(indexes) != len(revealed) {
+		return ErrInvalidProof
+	}
+	if err := scheme.VerifyProof(revealed, proof, nonce, k.raw); err != nil {
+		return ErrInvalidProof
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package bbs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func testMessages() [][]byte {
+	return [][]byte{[]byte("options"), []byte("frame"), []byte(`["level",12]`), []byte(`["score",900]`)}
+}
+
+func newTestKey(t *testing.T) *PrivateKey {
+	t.Helper()
+	key, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return key
+}
+
+func TestSignVerify(t *testing.T) {
+	key := newTestKey(t)
+	messages := testMessages()
+
+	signature, err := key.Sign(messages)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if len(signature) != SignatureSize {
+		t.Fatalf("signature is %d bytes, want %d", len(signature), SignatureSize)
+	}
+	if err := key.Public().Verify(messages, signature); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	tampered := testMessages()
+	tampered[2] = []byte(`["level",99]`)
+	if err := key.Public().Verify(tampered, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered message: got %v, want ErrInvalidSignature", err)
+	}
+	if err := key.Public().Verify(messages[:3], signature); err == nil {
+		t.Error("signature verified over fewer messages")
+	}
+	if err := newTestKey(t).Public().Verify(messages, signature); err == nil {
+		t.Error("signature verified with another key")
+	}
+}
+
+func TestNewKeyFromSeedIsDeterministic(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, 32)
+	first, err := NewKeyFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewKeyFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Public().Bytes(), second.Public().Bytes()) {
+		t.Error("same seed produced different keys")
+	}
+	if _, err := NewKeyFromSeed(seed[:16]); err == nil {
+		t.Error("short seed accepted")
+	}
+
+	decoded, err := PublicKeyFromBytes(first.Public().Bytes())
+	if err != nil {
+		t.Fatalf("PublicKeyFromBytes: %v", err)
+	}
+	if !bytes.Equal(decoded.Bytes(), first.Public().Bytes()) {
+		t.Error("public key does not round-trip")
+	}
+	if _, err := PublicKeyFromBytes(bytes.Repeat([]byte{0xff}, PublicKeySize)); err == nil {
+		t.Error("invalid public key accepted")
+	}
+}
+
+func TestDeriveVerifyProof(t *testing.T) {
+	key := newTestKey(t)
+	messages := testMessages()
+	signature, err := key.Sign(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("verifier-nonce")
+
+	proof, err := key.Public().DeriveProof(messages, signature, []int{2, 0, 1}, nonce)
+	if err != nil {
+		t.Fatalf("DeriveProof: %v", err)
+	}
+	count, revealed, err := ProofMessages(proof)
+	if err != nil {
+		t.Fatalf("ProofMessages: %v", err)
+	}
+	if count != 4 || len(revealed) != 3 || revealed[0] != 0 || revealed[1] != 1 || revealed[2] != 2 {
+		t.Fatalf("ProofMessages = %d %v, want 4 [0 1 2]", count, revealed)
+	}
+
+	disclosed := messages[:3]
+	if err := key.Public().VerifyProof(disclosed, proof, nonce); err != nil {
+		t.Fatalf("VerifyProof: %v", err)
+	}
+
+	if err := key.Public().VerifyProof(disclosed, proof, []byte("other-nonce")); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("wrong nonce: got %v, want ErrInvalidProof", err)
+	}
+	forged := [][]byte{messages[0], messages[1], []byte(`["level",99]`)}
+	if err := key.Public().VerifyProof(forged, proof, nonce); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("forged disclosed message: got %v, want ErrInvalidProof", err)
+	}
+	if err := key.Public().VerifyProof(disclosed[:2], proof, nonce); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("missing disclosed message: got %v, want ErrInvalidProof", err)
+	}
+	if err := newTestKey(t).Public().VerifyProof(disclosed, proof, nonce); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("another key: got %v, want ErrInvalidProof", err)
+	}
+}
+
+func TestDeriveProofRejectsInvalidInput(t *testing.T) {
+	key := newTestKey(t)
+	messages := testMessages()
+	signature, err := key.Sign(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := key.Public().DeriveProof(messages, signature, []int{4}, nil); err == nil {
+		t.Error("out-of-range index accepted")
+	}
+	if _, err := key.Public().DeriveProof(messages, signature, []int{1, 1}, nil); err == nil {
+		t.Error("duplicate index accepted")
+	}
+	messages[3] = []byte("changed")
+	if _, err := key.Public().DeriveProof(messages, signature, []int{0}, nil); err == nil {
+		t.Error("proof derived from an invalid signature")
+	}
+}
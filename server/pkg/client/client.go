@@ -0,0 +1,238 @@
+// Package client 是游戏服务器的 Go 客户端，供机器人和集成测试使用：创建并注册玩家 DID、
+// 签名 DID 认证令牌和挑战、管理自动重连的 WebSocket 连接、收发类型化的消息，
+// 并在钱包中保存服务器颁发的凭证
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/did"
+)
+
+// Config 客户端配置
+type Config struct {
+	// BaseURL 服务器地址，如 http://localhost:8080，WebSocket 地址由其推导
+	BaseURL string
+	// Locale 服务端消息的语言偏好，格式同 Accept-Language
+	Locale string
+	// HTTPClient 发送 HTTP 请求使用的客户端，为空时使用 http.DefaultClient
+	HTTPClient *http.Client
+	// TokenLifetime DID 认证令牌的有效期，不能超过服务器允许的最长有效期
+	TokenLifetime time.Duration
+	// DialTimeout 建立 WebSocket 连接并完成认证的超时
+	DialTimeout time.Duration
+	// ReconnectDelay 首次重连前的等待时间，之后每次加倍，最长 MaxReconnectDelay
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	// MaxReconnectAttempts 连续重连失败多少次后放弃并关闭连接，0 表示不限；小于 0 时不重连
+	MaxReconnectAttempts int
+	// ReadTimeout 读超时，期间未收到任何消息或服务器 ping 即视为断线并重连
+	ReadTimeout time.Duration
+	// AutoPresent 收到出示请求时自动用钱包中的凭证回应
+	AutoPresent bool
+	// Capabilities 认证时声明的可选协议能力，如 "delta_sync"（需自行以 state_ack 确认状态版本）；
+	// 为空时不声明任何能力，服务器每次发送完整的 state_sync 快照
+	Capabilities []string
+	// EnableCompression 握手时请求 permessage-deflate，服务器同意时超过阈值的消息压缩传输
+	EnableCompression bool
+}
+
+// DefaultConfig 返回连接 baseURL 的默认配置
+func DefaultConfig(baseURL string) Config {
+	return Config{
+		BaseURL:              baseURL,
+		TokenLifetime:        5 * time.Minute,
+		DialTimeout:          10 * time.Second,
+		ReconnectDelay:       time.Second,
+		MaxReconnectDelay:    30 * time.Second,
+		MaxReconnectAttempts: 10,
+		ReadTimeout:          60 * time.Second,
+	}
+}
+
+// HTTPError 服务器返回的非 2xx 响应
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error 实现 error 接口
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Client 以一个玩家身份访问游戏服务器
+type Client struct {
+	config   Config
+	identity *Identity
+	wallet   *Wallet
+
+	handlers     map[string][]func(Message)
+	handlerMutex sync.Mutex
+}
+
+// NewClient 创建以 identity 身份访问服务器的客户端，未设置的配置项使用默认值。
+// 凭证默认保存在内存钱包中，可以用 SetWallet 替换为文件钱包
+func NewClient(config Config, identity *Identity) *Client {
+	defaults := DefaultConfig(config.BaseURL)
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.TokenLifetime <= 0 {
+		config.TokenLifetime = defaults.TokenLifetime
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaults.DialTimeout
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = defaults.ReconnectDelay
+	}
+	if config.ReadTimeout <= 0 {
+		config.ReadTimeout = defaults.ReadTimeout
+	}
+	if config.MaxReconnectDelay < config.ReconnectDelay {
+		config.MaxReconnectDelay = defaults.MaxReconnectDelay
+	}
+	return &Client{
+		config:   config,
+		identity: identity,
+		wallet:   NewWallet(),
+		handlers: make(map[string][]func(Message)),
+	}
+}
+
+// Identity 返回客户端的身份
+func (c *Client) Identity() *Identity {
+	return c.identity
+}
+
+// Wallet 返回保存凭证的钱包
+func (c *Client) Wallet() *Wallet {
+	return c.wallet
+}
+
+// SetWallet 替换保存凭证的钱包，需在 Connect 之前调用
+func (c *Client) SetWallet(wallet *Wallet) {
+	c.wallet = wallet
+}
+
+// On 为之后建立的连接注册 msgType 消息的处理函数，与 Conn.On 不同，处理函数也会收到认证的回复
+func (c *Client) On(msgType string, handler func(Message)) {
+	c.handlerMutex.Lock()
+	c.handlers[msgType] = append(c.handlers[msgType], handler)
+	c.handlerMutex.Unlock()
+}
+
+// registrationNonceRequest 申请注册 nonce 的请求
+type registrationNonceRequest struct {
+	DID string `json:"did"`
+}
+
+// registrationNonceResponse 注册 nonce 响应
+type registrationNonceResponse struct {
+	Nonce string `json:"nonce"`
+}
+
+// registerRequest 注册 DID 的请求
+type registerRequest struct {
+	DID         string           `json:"did"`
+	DIDDocument *did.DIDDocument `json:"didDocument"`
+	PublicKey   string           `json:"publicKey"`
+	GameID      string           `json:"gameId"`
+	PlayerID    string           `json:"playerId"`
+	Nickname    string           `json:"nickname,omitempty"`
+	Nonce       string           `json:"nonce"`
+	Signature   string           `json:"signature"`
+}
+
+// Register 在服务器注册客户端的 DID：申请注册 nonce，用私钥签名后随 DID 文档提交
+func (c *Client) Register(ctx context.Context, nickname string) error {
+	d := c.identity.DID
+
+	var nonce registrationNonceResponse
+	if err := c.do(ctx, http.MethodPost, "/api/did/register/nonce", registrationNonceRequest{DID: d.ID}, &nonce, false); err != nil {
+		return fmt.Errorf("request registration nonce: %w", err)
+	}
+	signature, err := c.identity.SignChallenge(nonce.Nonce)
+	if err != nil {
+		return err
+	}
+
+	req := registerRequest{
+		DID:         d.ID,
+		DIDDocument: d.ToDIDDocument(),
+		PublicKey:   d.PublicKey,
+		GameID:      d.GameID,
+		PlayerID:    d.PlayerID,
+		Nickname:    nickname,
+		Nonce:       nonce.Nonce,
+		Signature:   signature,
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/did/register", req, nil, false); err != nil {
+		return fmt.Errorf("register DID: %w", err)
+	}
+	return nil
+}
+
+// Do 发送带 DID 认证令牌的 JSON 请求，body 为空时不发送请求体，result 非空时解码响应。
+// path 是相对 BaseURL 的路径，可以带查询参数；非 2xx 响应返回 *HTTPError
+func (c *Client) Do(ctx context.Context, method, path string, body, result interface{}) error {
+	return c.do(ctx, method, path, body, result, true)
+}
+
+// do 发送 JSON 请求，authenticate 为 true 时附加只能用于本次请求的 DID 认证令牌
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}, authenticate bool) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.config.BaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.Locale != "" {
+		req.Header.Set("Accept-Language", c.config.Locale)
+	}
+	if authenticate {
+		token, err := c.identity.AuthToken(method, req.URL.Scheme+"://"+req.URL.Host+req.URL.Path, c.config.TokenLifetime)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &HTTPError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
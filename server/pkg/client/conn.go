@@ -0,0 +1,560 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// WebSocketPath 游戏 WebSocket 端点相对 BaseURL 的路径
+const WebSocketPath = "/ws/game"
+
+// subprotocolJSON 客户端使用的子协议，消息以 JSON 文本帧收发
+const subprotocolJSON = "game.v1.json"
+
+// ProtocolVersion 客户端实现的协议版本，认证时发给服务器
+const ProtocolVersion = 1
+
+// errCodeResumeFailed 会话令牌无效或已过恢复窗口，需要重新认证
+const errCodeResumeFailed = "resume_failed"
+
+// ErrCodeUpgradeRequired 服务器不再接受客户端的协议版本，Connect 返回带此错误码的 *ServerError
+const ErrCodeUpgradeRequired = "upgrade_required"
+
+// AllMessages 传给 On 时处理所有类型的消息
+const AllMessages = "*"
+
+// ErrClosed 连接已关闭，Close 后或重连次数用尽时返回
+var ErrClosed = errors.New("connection closed")
+
+// Message 与服务器交换的消息，Data 保留原始 JSON，用 Decode 解码为具体类型
+type Message struct {
+	Type      string          `json:"type"`
+	PlayerID  string          `json:"playerId,omitempty"`
+	RoomID    string          `json:"roomId,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// Decode 将 Data 解码到 v
+func (m Message) Decode(v interface{}) error {
+	if len(m.Data) == 0 {
+		return fmt.Errorf("%s message has no data", m.Type)
+	}
+	if err := json.Unmarshal(m.Data, v); err != nil {
+		return fmt.Errorf("decode %s message: %w", m.Type, err)
+	}
+	return nil
+}
+
+// ServerError 服务器的 error 消息
+type ServerError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Type    string                 `json:"type,omitempty"`
+	Reason  string                 `json:"reason,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error 实现 error 接口
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Session 认证后服务器分配的会话
+type Session struct {
+	PlayerID     string `json:"playerId"`
+	DID          string `json:"did"`
+	Nickname     string `json:"nickname"`
+	SessionToken string `json:"sessionToken"`
+	// ProtocolVersion 和 Capabilities 为服务器为本连接协商的协议版本和启用的能力
+	ProtocolVersion int      `json:"protocolVersion"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// outboundMessage 发给服务器的消息
+type outboundMessage struct {
+	Type     string      `json:"type"`
+	PlayerID string      `json:"playerId,omitempty"`
+	RoomID   string      `json:"roomId,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// waiter Wait 等待的消息类型
+type waiter struct {
+	msgType string
+	ch      chan Message
+}
+
+// Conn 已认证的游戏连接。连接意外断开时按指数退避重连，用会话令牌恢复会话，
+// 令牌失效时重新认证；处理函数在同一个读协程中按到达顺序调用，batch 消息拆开后逐条分发
+type Conn struct {
+	client *Client
+
+	ws         *websocket.Conn
+	writeMutex sync.Mutex
+
+	session      Session
+	roomID       string
+	sessionMutex sync.RWMutex
+
+	handlers     map[string][]func(Message)
+	waiters      []*waiter
+	handlerMutex sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Connect 建立 WebSocket 连接并以客户端的 DID 认证，返回后开始接收消息
+func (c *Client) Connect(ctx context.Context) (*Conn, error) {
+	conn := &Conn{
+		client:   c,
+		handlers: make(map[string][]func(Message)),
+		done:     make(chan struct{}),
+	}
+	conn.On(MsgTypeCredential, conn.storeCredential)
+	if c.config.AutoPresent {
+		conn.On(MsgTypePresentationRequest, conn.answerPresentationRequest)
+	}
+	c.handlerMutex.Lock()
+	for msgType, handlers := range c.handlers {
+		conn.handlers[msgType] = append(conn.handlers[msgType], handlers...)
+	}
+	c.handlerMutex.Unlock()
+
+	ws, pending, err := conn.handshake(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	conn.ws = ws
+	go conn.readLoop(ws, pending)
+	return conn, nil
+}
+
+// On 注册 msgType 消息的处理函数，msgType 为 AllMessages 时处理所有消息。
+// 处理函数在读协程中调用，阻塞会延迟后续消息
+func (c *Conn) On(msgType string, handler func(Message)) {
+	c.handlerMutex.Lock()
+	c.handlers[msgType] = append(c.handlers[msgType], handler)
+	c.handlerMutex.Unlock()
+}
+
+// Wait 等待下一条 msgType 消息。先收到 error 消息时返回 *ServerError，
+// ctx 结束或连接关闭时返回相应的错误
+func (c *Conn) Wait(ctx context.Context, msgType string) (Message, error) {
+	w := &waiter{msgType: msgType, ch: make(chan Message, 1)}
+	c.handlerMutex.Lock()
+	c.waiters = append(c.waiters, w)
+	c.handlerMutex.Unlock()
+
+	defer func() {
+		c.handlerMutex.Lock()
+		for i, other := range c.waiters {
+			if other == w {
+				c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+				break
+			}
+		}
+		c.handlerMutex.Unlock()
+	}()
+
+	select {
+	case msg := <-w.ch:
+		if msg.Type == MsgTypeError && msgType != MsgTypeError {
+			serverErr := &ServerError{}
+			if err := msg.Decode(serverErr); err != nil {
+				return Message{}, err
+			}
+			return Message{}, serverErr
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case <-c.done:
+		return Message{}, c.Err()
+	}
+}
+
+// Send 发送 msgType 消息，data 序列化为消息载荷
+func (c *Conn) Send(msgType string, data interface{}) error {
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+
+	c.sessionMutex.RLock()
+	msg := outboundMessage{Type: msgType, PlayerID: c.session.PlayerID, RoomID: c.roomID, Data: data}
+	c.sessionMutex.RUnlock()
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if err := c.ws.WriteJSON(msg); err != nil {
+		return fmt.Errorf("send %s message: %w", msgType, err)
+	}
+	return nil
+}
+
+// Session 返回当前会话，重连后会话令牌会更新
+func (c *Conn) Session() Session {
+	c.sessionMutex.RLock()
+	defer c.sessionMutex.RUnlock()
+	return c.session
+}
+
+// RoomID 返回玩家所在的房间，不在房间中时返回空字符串
+func (c *Conn) RoomID() string {
+	c.sessionMutex.RLock()
+	defer c.sessionMutex.RUnlock()
+	return c.roomID
+}
+
+// Done 返回连接关闭时关闭的通道
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err 返回连接关闭的原因，连接未关闭时返回 nil
+func (c *Conn) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close 关闭连接且不再重连，服务器在恢复窗口内保留玩家的会话
+func (c *Conn) Close() error {
+	c.shutdown(ErrClosed)
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	c.ws.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(time.Second))
+	return c.ws.Close()
+}
+
+// shutdown 记录关闭原因并关闭 done，只有第一次调用生效
+func (c *Conn) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+	})
+}
+
+// handshake 建立 WebSocket 连接并认证，resume 为 true 时先用会话令牌恢复会话。
+// 返回认证结果之前收到的消息，由读协程在认证结果之后分发
+func (c *Conn) handshake(ctx context.Context, resume bool) (*websocket.Conn, []Message, error) {
+	config := c.client.config
+	ctx, cancel := context.WithTimeout(ctx, config.DialTimeout)
+	defer cancel()
+
+	target, err := webSocketURL(config.BaseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	dialer := websocket.Dialer{
+		Proxy:             websocket.DefaultDialer.Proxy,
+		HandshakeTimeout:  config.DialTimeout,
+		Subprotocols:      []string{subprotocolJSON},
+		EnableCompression: config.EnableCompression,
+	}
+	ws, _, err := dialer.DialContext(ctx, target, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dial %s: %w", target, err)
+	}
+
+	// 握手期间读写都受 ctx 的截止时间限制
+	if deadline, ok := ctx.Deadline(); ok {
+		ws.SetReadDeadline(deadline)
+		ws.SetWriteDeadline(deadline)
+	}
+
+	var pending []Message
+	if resume {
+		token := c.Session().SessionToken
+		pending, err = c.authenticate(ws, MsgTypeResume, c.hello(map[string]interface{}{"sessionToken": token}))
+		var serverErr *ServerError
+		if errors.As(err, &serverErr) && serverErr.Code == errCodeResumeFailed {
+			resume = false
+		} else if err != nil {
+			ws.Close()
+			return nil, nil, err
+		}
+	}
+	if !resume {
+		authPending, err := c.authenticateDID(ws)
+		if err != nil {
+			ws.Close()
+			return nil, nil, err
+		}
+		pending = append(pending, authPending...)
+	}
+
+	ws.SetWriteDeadline(time.Time{})
+	ws.SetReadDeadline(time.Now().Add(config.ReadTimeout))
+	ws.SetPingHandler(func(data string) error {
+		ws.SetReadDeadline(time.Now().Add(config.ReadTimeout))
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	return ws, pending, nil
+}
+
+// hello 在 auth 或 resume 载荷中加入语言偏好、协议版本和声明的能力
+func (c *Conn) hello(data map[string]interface{}) map[string]interface{} {
+	config := c.client.config
+	capabilities := config.Capabilities
+	if capabilities == nil {
+		capabilities = []string{}
+	}
+	data["locale"] = config.Locale
+	data["protocolVersion"] = ProtocolVersion
+	data["capabilities"] = capabilities
+	return data
+}
+
+// authChallenge auth_challenge 回复，nonce 用 DID 认证密钥签名后随 auth 提交
+type authChallenge struct {
+	Nonce string `json:"nonce"`
+}
+
+// authenticateDID 申请认证 nonce，用身份私钥签名后发送 auth
+func (c *Conn) authenticateDID(ws *websocket.Conn) ([]Message, error) {
+	reply, before, after, err := request(ws, MsgTypeAuthChallenge, nil)
+	if err != nil {
+		return nil, err
+	}
+	var challenge authChallenge
+	if err := reply.Decode(&challenge); err != nil {
+		return nil, err
+	}
+
+	identity := c.client.identity
+	signature, err := identity.SignChallenge(challenge.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	authPending, err := c.authenticate(ws, MsgTypeAuth, c.hello(map[string]interface{}{
+		"did":       identity.ID(),
+		"keyId":     identity.KeyID(),
+		"nonce":     challenge.Nonce,
+		"signature": signature,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return append(append(before, after...), authPending...), nil
+}
+
+// authenticate 发送 auth 或 resume 消息并读取到同类型的回复为止，回复之前收到的其他消息连同回复一起返回。
+// 收到 error 消息时返回 *ServerError
+func (c *Conn) authenticate(ws *websocket.Conn, msgType string, data interface{}) ([]Message, error) {
+	reply, before, after, err := request(ws, msgType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := reply.Decode(&session); err != nil {
+		return nil, err
+	}
+	c.sessionMutex.Lock()
+	c.session = session
+	c.roomID = reply.RoomID
+	c.sessionMutex.Unlock()
+	return append(append(before, reply), after...), nil
+}
+
+// request 发送消息并读取到同类型的回复为止，返回回复以及同一批次中回复之前和之后收到的其他消息。
+// 收到 error 消息时返回 *ServerError
+func request(ws *websocket.Conn, msgType string, data interface{}) (reply Message, before, after []Message, err error) {
+	if err := ws.WriteJSON(outboundMessage{Type: msgType, Data: data}); err != nil {
+		return Message{}, nil, nil, fmt.Errorf("send %s message: %w", msgType, err)
+	}
+
+	for {
+		msgs, err := readMessages(ws)
+		if err != nil {
+			return Message{}, nil, nil, fmt.Errorf("read %s response: %w", msgType, err)
+		}
+		for i, msg := range msgs {
+			switch msg.Type {
+			case MsgTypeError:
+				serverErr := &ServerError{}
+				if err := msg.Decode(serverErr); err != nil {
+					return Message{}, nil, nil, err
+				}
+				return Message{}, nil, nil, serverErr
+			case msgType:
+				return msg, before, msgs[i+1:], nil
+			default:
+				before = append(before, msg)
+			}
+		}
+	}
+}
+
+// readLoop 读取并分发消息，连接断开时重连，重连失败或连接关闭后退出
+func (c *Conn) readLoop(ws *websocket.Conn, pending []Message) {
+	for {
+		for _, msg := range pending {
+			c.dispatch(msg)
+		}
+
+		var err error
+		for err == nil {
+			pending, err = readMessages(ws)
+			for _, msg := range pending {
+				c.dispatch(msg)
+			}
+		}
+		ws.Close()
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		if ws, pending, err = c.reconnect(err); err != nil {
+			c.shutdown(err)
+			return
+		}
+	}
+}
+
+// reconnect 按指数退避重连，返回新连接和恢复后的消息
+func (c *Conn) reconnect(cause error) (*websocket.Conn, []Message, error) {
+	config := c.client.config
+	if config.MaxReconnectAttempts < 0 {
+		return nil, nil, fmt.Errorf("%w: %v", ErrClosed, cause)
+	}
+
+	delay := config.ReconnectDelay
+	for attempt := 1; config.MaxReconnectAttempts == 0 || attempt <= config.MaxReconnectAttempts; attempt++ {
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.done:
+			timer.Stop()
+			return nil, nil, c.err
+		case <-timer.C:
+		}
+
+		ws, pending, err := c.handshake(context.Background(), true)
+		if err == nil {
+			c.writeMutex.Lock()
+			c.ws = ws
+			c.writeMutex.Unlock()
+			return ws, pending, nil
+		}
+		cause = err
+		if delay *= 2; delay > config.MaxReconnectDelay {
+			delay = config.MaxReconnectDelay
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: reconnect failed after %d attempts: %v", ErrClosed, config.MaxReconnectAttempts, cause)
+}
+
+// dispatch 更新连接状态，并将消息交给等待者和处理函数
+func (c *Conn) dispatch(msg Message) {
+	switch msg.Type {
+	case MsgTypeJoinRoom:
+		if msg.PlayerID == c.Session().PlayerID && msg.RoomID != "" {
+			c.sessionMutex.Lock()
+			c.roomID = msg.RoomID
+			c.sessionMutex.Unlock()
+		}
+	case MsgTypeLeaveRoom:
+		if msg.PlayerID == c.Session().PlayerID {
+			c.sessionMutex.Lock()
+			c.roomID = ""
+			c.sessionMutex.Unlock()
+		}
+	}
+
+	c.handlerMutex.Lock()
+	var handlers []func(Message)
+	handlers = append(handlers, c.handlers[msg.Type]...)
+	handlers = append(handlers, c.handlers[AllMessages]...)
+	for _, w := range c.waiters {
+		if w.msgType == msg.Type || w.msgType == AllMessages || msg.Type == MsgTypeError {
+			select {
+			case w.ch <- msg:
+			default:
+			}
+		}
+	}
+	c.handlerMutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+}
+
+// storeCredential 将 credential 消息中的凭证存入钱包
+func (c *Conn) storeCredential(msg Message) {
+	var data struct {
+		Credential *vc.SimpleCredential `json:"credential"`
+	}
+	if err := msg.Decode(&data); err != nil || data.Credential == nil {
+		return
+	}
+	c.client.wallet.Add(data.Credential)
+}
+
+// answerPresentationRequest 用钱包中的凭证回应出示请求，钱包缺少请求的凭证时不回应
+func (c *Conn) answerPresentationRequest(msg Message) {
+	var request PresentationRequest
+	if err := msg.Decode(&request); err != nil {
+		return
+	}
+	c.Present(request)
+}
+
+// readMessages 读取一条消息，batch 消息拆开为其中的各条消息
+func readMessages(ws *websocket.Conn) ([]Message, error) {
+	var msg Message
+	if err := ws.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Type != MsgTypeBatch {
+		return []Message{msg}, nil
+	}
+
+	var batch struct {
+		Messages []Message `json:"messages"`
+	}
+	if err := msg.Decode(&batch); err != nil {
+		return nil, err
+	}
+	return batch.Messages, nil
+}
+
+// webSocketURL 由 HTTP 地址推导 WebSocket 端点地址
+func webSocketURL(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("parse base URL: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported base URL scheme %q", u.Scheme)
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + WebSocketPath
+	return u.String(), nil
+}
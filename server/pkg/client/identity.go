@@ -0,0 +1,148 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/pkg/did"
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// authTokenType DID 认证令牌的 JWT typ
+const authTokenType = "JWT"
+
+// authClaims DID 认证令牌的声明，与服务器 didauth 校验的声明一致
+type authClaims struct {
+	Issuer    string `json:"iss"`
+	Audience  string `json:"aud"`
+	JWTID     string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Method    string `json:"htm"`
+	URI       string `json:"htu"`
+}
+
+// Identity 玩家 DID 及其私钥，用于注册 DID、签名认证令牌和可验证表述
+type Identity struct {
+	DID *did.SimpleDID
+}
+
+// NewIdentity 生成新的密钥对并创建 did:player:{gameID}:{playerID}，尚未在服务器注册
+func NewIdentity(gameID, playerID string) (*Identity, error) {
+	d, err := did.CreatePlayerDID(gameID, playerID)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{DID: d}, nil
+}
+
+// LoadIdentity 读取 Save 保存的身份
+func LoadIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read identity: %w", err)
+	}
+	d, err := did.FromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse identity: %w", err)
+	}
+	if d.ID == "" || d.PrivateKey == "" {
+		return nil, fmt.Errorf("identity in %s has no DID or private key", path)
+	}
+	return &Identity{DID: d}, nil
+}
+
+// Save 将 DID 和私钥写入文件，文件只有所有者可读写
+func (i *Identity) Save(path string) error {
+	data, err := i.DID.ToJSON()
+	if err != nil {
+		return fmt.Errorf("marshal identity: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("write identity: %w", err)
+	}
+	return nil
+}
+
+// ID 返回 DID
+func (i *Identity) ID() string {
+	return i.DID.ID
+}
+
+// KeyID 返回认证和签名使用的验证方法 ID
+func (i *Identity) KeyID() string {
+	return i.DID.ID + "#key-1"
+}
+
+// privateKey 解码 Ed25519 私钥
+func (i *Identity) privateKey() (ed25519.PrivateKey, error) {
+	key, err := hex.DecodeString(i.DID.PrivateKey)
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("identity %s has no valid Ed25519 private key", i.DID.ID)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// SignChallenge 用私钥签名服务器下发的 nonce 或挑战，返回 hex 编码的签名
+func (i *Identity) SignChallenge(challenge string) (string, error) {
+	signature, err := i.DID.Sign([]byte(challenge))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(signature), nil
+}
+
+// AuthToken 生成 Authorization: Bearer 使用的 DID 认证令牌，令牌只能用于 method 和 uri 指定的一次请求，
+// uri 是不含查询参数的完整地址，其 scheme 和 host 作为 aud；lifetime 不能超过服务器允许的最长有效期
+func (i *Identity) AuthToken(method, uri string, lifetime time.Duration) (string, error) {
+	if method == "" || uri == "" {
+		return "", errors.New("auth token requires the request method and URI")
+	}
+	target, err := url.Parse(uri)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return "", fmt.Errorf("auth token URI must be absolute: %s", uri)
+	}
+	key, err := i.privateKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := authClaims{
+		Issuer:    i.DID.ID,
+		Audience:  target.Scheme + "://" + target.Host,
+		JWTID:     uuid.New().String(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(lifetime).Unix(),
+		Method:    method,
+		URI:       uri,
+	}
+	token, err := vc.SignCompactJWT(authTokenType, i.KeyID(), claims, key)
+	if err != nil {
+		return "", fmt.Errorf("sign auth token: %w", err)
+	}
+	return token, nil
+}
+
+// Present 将凭证封装为可验证表述并签名，challenge 和 domain 取自服务器的出示请求
+func (i *Identity) Present(challenge, domain string, credentials ...*vc.SimpleCredential) (*vc.SimplePresentation, error) {
+	key, err := i.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	presentation, err := vc.CreatePresentation(i.DID.ID, credentials...)
+	if err != nil {
+		return nil, err
+	}
+	if err := vc.SignPresentation(presentation, key, i.KeyID(), vc.ProofTypeDataIntegrity, challenge, domain); err != nil {
+		return nil, fmt.Errorf("sign presentation: %w", err)
+	}
+	return presentation, nil
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+func newTestIdentity(t *testing.T) *Identity {
+	t.Helper()
+	identity, err := NewIdentity("test", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func TestIdentitySaveAndLoad(t *testing.T) {
+	identity := newTestIdentity(t)
+	path := filepath.Join(t.TempDir(), "identity.json")
+
+	if err := identity.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("identity file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := LoadIdentity(path)
+	if err != nil {
+		t.Fatalf("LoadIdentity: %v", err)
+	}
+	if loaded.ID() != identity.ID() || loaded.DID.PrivateKey != identity.DID.PrivateKey {
+		t.Error("loaded identity differs from the saved one")
+	}
+}
+
+func TestAuthTokenBindsRequest(t *testing.T) {
+	identity := newTestIdentity(t)
+
+	token, err := identity.AuthToken("POST", "https://game.example.com/api/vc/issue", time.Minute)
+	if err != nil {
+		t.Fatalf("AuthToken: %v", err)
+	}
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	var claims authClaims
+	signingInput, signature, err := vc.ParseCompactJWT(token, &header, &claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Kid != identity.KeyID() || claims.Issuer != identity.ID() {
+		t.Errorf("token kid %q, iss %q", header.Kid, claims.Issuer)
+	}
+	if claims.Method != "POST" || claims.URI != "https://game.example.com/api/vc/issue" {
+		t.Errorf("token bound to %s %s", claims.Method, claims.URI)
+	}
+	if claims.Audience != "https://game.example.com" || claims.JWTID == "" {
+		t.Errorf("token aud %q, jti %q", claims.Audience, claims.JWTID)
+	}
+	publicKey, _ := hex.DecodeString(identity.DID.PublicKey)
+	if err := vc.VerifyJWTSignature("EdDSA", signingInput, signature, ed25519.PublicKey(publicKey)); err != nil {
+		t.Errorf("token signature: %v", err)
+	}
+
+	// 未绑定请求的令牌会被服务器拒绝，客户端不生成
+	if _, err := identity.AuthToken("", "https://game.example.com/api/vc/issue", time.Minute); err == nil {
+		t.Error("token without a method generated")
+	}
+	if _, err := identity.AuthToken("POST", "", time.Minute); err == nil {
+		t.Error("token without a URI generated")
+	}
+	if _, err := identity.AuthToken("POST", "/api/vc/issue", time.Minute); err == nil {
+		t.Error("token for a relative URI generated")
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// 常用消息类型，与服务器的消息类型一致
+const (
+	MsgTypeAuth                = "auth"
+	MsgTypeAuthChallenge       = "auth_challenge"
+	MsgTypeResume              = "resume"
+	MsgTypeError               = "error"
+	MsgTypeBatch               = "batch"
+	MsgTypePing                = "ping"
+	MsgTypePong                = "pong"
+	MsgTypeJoinRoom            = "join_room"
+	MsgTypeLeaveRoom           = "leave_room"
+	MsgTypePlayerMove          = "player_move"
+	MsgTypePlayerAction        = "player_action"
+	MsgTypePlayerUpdate        = "player_update"
+	MsgTypeGameState           = "game_state"
+	MsgTypeStateDelta          = "state_delta"
+	MsgTypeChat                = "chat"
+	MsgTypeWhisper             = "whisper"
+	MsgTypeCredential          = "credential"
+	MsgTypePresentationRequest = "presentation_request"
+	MsgTypePresentation        = "presentation"
+	MsgTypeQueueMatch          = "queue_match"
+	MsgTypeCancelMatch         = "cancel_match"
+	MsgTypeMatchFound          = "match_found"
+)
+
+// JoinRoomPayload join_room 消息载荷，未指定房间时加入默认房间；房间配置仅在房间由本次加入创建时生效
+type JoinRoomPayload struct {
+	RoomID     string `json:"roomId,omitempty"`
+	Password   string `json:"password,omitempty"`
+	InviteCode string `json:"inviteCode,omitempty"`
+
+	RequiredCredentials []string `json:"requiredCredentials,omitempty"`
+	MinLevel            int      `json:"minLevel,omitempty"`
+	Teams               int      `json:"teams,omitempty"`
+	Mode                string   `json:"mode,omitempty"`
+}
+
+// ActionPayload player_action 消息载荷
+type ActionPayload struct {
+	Action   string `json:"action"`
+	TaskID   string `json:"taskId,omitempty"`
+	ObjectID string `json:"objectId,omitempty"`
+	TargetID string `json:"targetId,omitempty"`
+}
+
+// ChatPayload chat 消息载荷，To 非空时为私聊
+type ChatPayload struct {
+	Message string `json:"message"`
+	Channel string `json:"channel,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+// PresentationRequest 服务器的出示请求，出示的表述需用 Challenge 和 Domain 签名
+type PresentationRequest struct {
+	Challenge       string    `json:"challenge"`
+	Domain          string    `json:"domain"`
+	CredentialTypes []string  `json:"credentialTypes"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+// JoinRoom 加入房间，房间不存在时创建
+func (c *Conn) JoinRoom(payload JoinRoomPayload) error {
+	return c.Send(MsgTypeJoinRoom, payload)
+}
+
+// LeaveRoom 离开当前房间
+func (c *Conn) LeaveRoom() error {
+	return c.Send(MsgTypeLeaveRoom, struct{}{})
+}
+
+// Move 移动到 (x, y)
+func (c *Conn) Move(x, y float64) error {
+	return c.Send(MsgTypePlayerMove, map[string]float64{"x": x, "y": y})
+}
+
+// Action 执行游戏动作
+func (c *Conn) Action(payload ActionPayload) error {
+	return c.Send(MsgTypePlayerAction, payload)
+}
+
+// Chat 在房间频道发送聊天消息
+func (c *Conn) Chat(message string) error {
+	return c.Send(MsgTypeChat, ChatPayload{Message: message})
+}
+
+// Whisper 向房间中的玩家发送私聊
+func (c *Conn) Whisper(to, message string) error {
+	return c.Send(MsgTypeChat, ChatPayload{Message: message, To: to})
+}
+
+// QueueMatch 按模式加入匹配队列
+func (c *Conn) QueueMatch(mode string) error {
+	return c.Send(MsgTypeQueueMatch, map[string]string{"mode": mode})
+}
+
+// Present 从钱包中为请求的每种凭证类型选出凭证，签名表述后发送给服务器
+func (c *Conn) Present(request PresentationRequest) error {
+	if !request.ExpiresAt.IsZero() && time.Now().After(request.ExpiresAt) {
+		return fmt.Errorf("presentation request expired at %s", request.ExpiresAt)
+	}
+	credentials, err := c.client.wallet.Select(request.CredentialTypes...)
+	if err != nil {
+		return err
+	}
+	presentation, err := c.client.identity.Present(request.Challenge, request.Domain, credentials...)
+	if err != nil {
+		return err
+	}
+	return c.Send(MsgTypePresentation, map[string]interface{}{"presentation": presentation})
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/vc"
+)
+
+// Wallet 保存玩家持有的凭证，可以同时写入 JSON 文件，重启后由 OpenWallet 恢复。
+// 连接收到的 credential 消息自动存入钱包，并发使用安全
+type Wallet struct {
+	path        string
+	credentials map[string]*vc.SimpleCredential
+	mutex       sync.RWMutex
+}
+
+// NewWallet 创建只保存在内存中的钱包
+func NewWallet() *Wallet {
+	return &Wallet{credentials: make(map[string]*vc.SimpleCredential)}
+}
+
+// OpenWallet 打开保存在 path 的钱包，文件不存在时创建空钱包，之后的修改都写回文件
+func OpenWallet(path string) (*Wallet, error) {
+	w := NewWallet()
+	w.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read wallet: %w", err)
+	}
+
+	var credentials []*vc.SimpleCredential
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("parse wallet: %w", err)
+	}
+	for _, credential := range credentials {
+		if credential != nil && credential.ID != "" {
+			w.credentials[credential.ID] = credential
+		}
+	}
+	return w, nil
+}
+
+// Add 保存凭证，替换同 ID 的已有凭证
+func (w *Wallet) Add(credential *vc.SimpleCredential) error {
+	if credential == nil || credential.ID == "" {
+		return errors.New("credential ID is required")
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.credentials[credential.ID] = credential
+	return w.save()
+}
+
+// Remove 删除凭证，凭证不存在时不做任何事
+func (w *Wallet) Remove(credentialID string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, exists := w.credentials[credentialID]; !exists {
+		return nil
+	}
+	delete(w.credentials, credentialID)
+	return w.save()
+}
+
+// Get 按 ID 返回凭证
+func (w *Wallet) Get(credentialID string) (*vc.SimpleCredential, bool) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	credential, exists := w.credentials[credentialID]
+	return credential, exists
+}
+
+// List 返回所有凭证，按颁发时间排序
+func (w *Wallet) List() []*vc.SimpleCredential {
+	w.mutex.RLock()
+	credentials := make([]*vc.SimpleCredential, 0, len(w.credentials))
+	for _, credential := range w.credentials {
+		credentials = append(credentials, credential)
+	}
+	w.mutex.RUnlock()
+
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].IssuanceDate.Before(credentials[j].IssuanceDate)
+	})
+	return credentials
+}
+
+// Select 为每个凭证类型选出最新颁发且未过期的凭证，任一类型没有可用凭证时返回错误
+func (w *Wallet) Select(credentialTypes ...string) ([]*vc.SimpleCredential, error) {
+	now := time.Now()
+	credentials := w.List()
+
+	selected := make([]*vc.SimpleCredential, 0, len(credentialTypes))
+	for _, credType := range credentialTypes {
+		var match *vc.SimpleCredential
+		for _, credential := range credentials {
+			if credential.ExpirationDate != nil && now.After(*credential.ExpirationDate) {
+				continue
+			}
+			if hasType(credential, credType) {
+				match = credential
+			}
+		}
+		if match == nil {
+			return nil, fmt.Errorf("wallet has no valid %s credential", credType)
+		}
+		selected = append(selected, match)
+	}
+	return selected, nil
+}
+
+// save 将钱包写回文件，内存钱包不做任何事。调用方需持有写锁
+func (w *Wallet) save() error {
+	if w.path == "" {
+		return nil
+	}
+
+	credentials := make([]*vc.SimpleCredential, 0, len(w.credentials))
+	for _, credential := range w.credentials {
+		credentials = append(credentials, credential)
+	}
+	data, err := json.MarshalIndent(credentials, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal wallet: %w", err)
+	}
+	if err := os.WriteFile(w.path, data, 0o600); err != nil {
+		return fmt.Errorf("write wallet: %w", err)
+	}
+	return nil
+}
+
+// hasType 判断凭证是否属于 credType 类型
+func hasType(credential *vc.SimpleCredential, credType string) bool {
+	for _, t := range credential.Type {
+		if t == credType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package did
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/czh0526/game/server/pkg/multibase"
+)
+
+// p256Multicodec P-256 压缩公钥的 multicodec 前缀（0x1200）
+var p256Multicodec = []byte{0x80, 0x24}
+
+// externalDocument 标准钱包发布的 DID 文档，字段形式比 DIDDocument 宽松
+type externalDocument struct {
+	Context            json.RawMessage   `json:"@context"`
+	ID                 string            `json:"id"`
+	VerificationMethod []externalMethod  `json:"verificationMethod"`
+	Authentication     []json.RawMessage `json:"authentication"`
+	AssertionMethod    []json.RawMessage `json:"assertionMethod"`
+	Service            []externalService `json:"service"`
+}
+
+// externalMethod 验证方法，公钥可以是 publicKeyHex、publicKeyMultibase 或 publicKeyJwk
+type externalMethod struct {
+	ID                 string       `json:"id"`
+	Type               string       `json:"type"`
+	Controller         string       `json:"controller"`
+	PublicKeyHex       string       `json:"publicKeyHex"`
+	PublicKeyMultibase string       `json:"publicKeyMultibase"`
+	PublicKeyJwk       *externalJWK `json:"publicKeyJwk"`
+}
+
+type externalJWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type externalService struct {
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	ServiceEndpoint json.RawMessage `json:"serviceEndpoint"`
+}
+
+// ParseDIDDocument 解析外部 DID 文档并规范化为 DIDDocument：
+// 相对 ID 展开为绝对 ID，内嵌在验证关系中的验证方法并入 verificationMethod，
+// Ed25519 和 P-256 公钥统一转换为十六进制，不支持的密钥类型被忽略
+func ParseDIDDocument(data []byte) (*DIDDocument, error) {
+	var raw externalDocument
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse DID document: %w", err)
+	}
+	if raw.ID == "" {
+		return nil, errors.New("DID document has no id")
+	}
+
+	document := &DIDDocument{
+		Context:            parseContext(raw.Context),
+		ID:                 raw.ID,
+		VerificationMethod: []VerificationMethod{},
+		Service:            []Service{},
+	}
+
+	addMethod := func(method externalMethod) {
+		normalized, err := method.normalize(raw.ID)
+		if err != nil {
+			return
+		}
+		for _, existing := range document.VerificationMethod {
+			if existing.ID == normalized.ID {
+				return
+			}
+		}
+		document.VerificationMethod = append(document.VerificationMethod, normalized)
+	}
+	for _, method := range raw.VerificationMethod {
+		addMethod(method)
+	}
+
+	// 验证关系的条目可以是验证方法 ID，也可以是内嵌的验证方法
+	relationship := func(entries []json.RawMessage) []string {
+		var refs []string
+		for _, entry := range entries {
+			var ref string
+			if err := json.Unmarshal(entry, &ref); err == nil {
+				refs = append(refs, absoluteID(raw.ID, ref))
+				continue
+			}
+			var method externalMethod
+			if err := json.Unmarshal(entry, &method); err == nil && method.ID != "" {
+				addMethod(method)
+				refs = append(refs, absoluteID(raw.ID, method.ID))
+			}
+		}
+		return refs
+	}
+	document.Authentication = relationship(raw.Authentication)
+	document.AssertionMethod = relationship(raw.AssertionMethod)
+
+	for _, service := range raw.Service {
+		document.Service = append(document.Service, Service{
+			ID:              absoluteID(raw.ID, service.ID),
+			Type:            service.Type,
+			ServiceEndpoint: parseServiceEndpoint(service.ServiceEndpoint),
+		})
+	}
+
+	return document, nil
+}
+
+// normalize 转换为 Ed25519 或 P-256 类型、十六进制公钥的验证方法
+func (m externalMethod) normalize(documentID string) (VerificationMethod, error) {
+	method := VerificationMethod{
+		ID:         absoluteID(documentID, m.ID),
+		Controller: m.Controller,
+	}
+	if method.Controller == "" {
+		method.Controller = documentID
+	}
+
+	switch {
+	case m.PublicKeyJwk != nil:
+		publicKey, err := m.PublicKeyJwk.publicKey()
+		if err != nil {
+			return VerificationMethod{}, err
+		}
+		return method.withKey(publicKey)
+	case m.PublicKeyMultibase != "":
+		decoded, err := multibase.Decode(m.PublicKeyMultibase)
+		if err != nil {
+			return VerificationMethod{}, fmt.Errorf("decode publicKeyMultibase: %w", err)
+		}
+		switch {
+		case bytes.HasPrefix(decoded, ed25519Multicodec):
+			decoded = decoded[len(ed25519Multicodec):]
+		case bytes.HasPrefix(decoded, p256Multicodec):
+			x, y := elliptic.UnmarshalCompressed(elliptic.P256(), decoded[len(p256Multicodec):])
+			if x == nil {
+				return VerificationMethod{}, fmt.Errorf("invalid P-256 public key: %s", m.ID)
+			}
+			return method.withKey(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+		}
+		// Ed25519VerificationKey2020 之外的旧格式直接是 32 字节公钥
+		if len(decoded) != ed25519.PublicKeySize {
+			return VerificationMethod{}, fmt.Errorf("unsupported publicKeyMultibase: %s", m.ID)
+		}
+		return method.withKey(ed25519.PublicKey(decoded))
+	case m.PublicKeyHex != "":
+		method.Type = m.Type
+		if method.Type != P256VerificationKeyType {
+			method.Type = Ed25519VerificationKeyType
+		}
+		method.PublicKey = m.PublicKeyHex
+		if _, err := method.CryptoPublicKey(); err != nil {
+			return VerificationMethod{}, err
+		}
+		return method, nil
+	}
+	return VerificationMethod{}, fmt.Errorf("verification method has no supported public key: %s", m.ID)
+}
+
+// withKey 按公钥类型设置验证方法类型和十六进制公钥
+func (m VerificationMethod) withKey(publicKey crypto.PublicKey) (VerificationMethod, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		m.Type = Ed25519VerificationKeyType
+		m.PublicKey = hex.EncodeToString(key)
+	case *ecdsa.PublicKey:
+		m.Type = P256VerificationKeyType
+		m.PublicKey = hex.EncodeToString(elliptic.Marshal(elliptic.P256(), key.X, key.Y))
+	default:
+		return VerificationMethod{}, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+	return m, nil
+}
+
+// publicKey 解码 OKP/Ed25519 和 EC/P-256 JWK
+func (k *externalJWK) publicKey() (crypto.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, fmt.Errorf("decode jwk x: %w", err)
+	}
+
+	switch {
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 jwk")
+		}
+		return ed25519.PublicKey(x), nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode jwk y: %w", err)
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, errors.New("invalid P-256 jwk")
+		}
+		return publicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported jwk: %s/%s", k.Kty, k.Crv)
+}
+
+// absoluteID 将 #fragment 形式的相对 ID 展开为绝对 ID
+func absoluteID(documentID, id string) string {
+	if strings.HasPrefix(id, "#") {
+		return documentID + id
+	}
+	return id
+}
+
+// parseContext 解析字符串或数组形式的 @context，忽略内嵌的上下文对象
+func parseContext(data json.RawMessage) []string {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		return []string{single}
+	}
+
+	var entries []json.RawMessage
+	json.Unmarshal(data, &entries)
+	contexts := []string{}
+	for _, entry := range entries {
+		var context string
+		if err := json.Unmarshal(entry, &context); err == nil {
+			contexts = append(contexts, context)
+		}
+	}
+	return contexts
+}
+
+// parseServiceEndpoint 字符串形式的端点转换为 {"uri": ...}，数组取第一个端点
+func parseServiceEndpoint(data json.RawMessage) map[string]interface{} {
+	var endpoint interface{}
+	json.Unmarshal(data, &endpoint)
+	if entries, ok := endpoint.([]interface{}); ok && len(entries) > 0 {
+		endpoint = entries[0]
+	}
+
+	switch value := endpoint.(type) {
+	case map[string]interface{}:
+		return value
+	case string:
+		return map[string]interface{}{"uri": value}
+	}
+	return map[string]interface{}{}
+}
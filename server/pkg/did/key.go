@@ -0,0 +1,59 @@
+package did
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/czh0526/game/server/pkg/multibase"
+)
+
+// KeyDIDPrefix did:key 方法前缀
+const KeyDIDPrefix = "did:key:"
+
+// ed25519Multicodec Ed25519 公钥的 multicodec 前缀（0xed）
+var ed25519Multicodec = []byte{0xed, 0x01}
+
+// IsKeyDID 判断是否为 did:key 标识符
+func IsKeyDID(didString string) bool {
+	return strings.HasPrefix(didString, KeyDIDPrefix) && len(didString) > len(KeyDIDPrefix)
+}
+
+// NewKeyDID 由 Ed25519 公钥生成 did:key 标识符
+func NewKeyDID(publicKey ed25519.PublicKey) string {
+	data := append(append([]byte(nil), ed25519Multicodec...), publicKey...)
+	return KeyDIDPrefix + multibase.EncodeBase58BTC(data)
+}
+
+// KeyDIDDocument 展开 did:key 标识符为 DID 文档，目前仅支持 Ed25519 密钥
+func KeyDIDDocument(didKey string) (*DIDDocument, error) {
+	if !IsKeyDID(didKey) {
+		return nil, fmt.Errorf("not a did:key identifier: %s", didKey)
+	}
+
+	fingerprint := strings.TrimPrefix(didKey, KeyDIDPrefix)
+	decoded, err := multibase.Decode(fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("decode did:key: %w", err)
+	}
+	if !bytes.HasPrefix(decoded, ed25519Multicodec) {
+		return nil, fmt.Errorf("unsupported did:key type: %s", didKey)
+	}
+
+	publicKey := decoded[len(ed25519Multicodec):]
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key length: %d", len(publicKey))
+	}
+
+	keyID := didKey + "#" + fingerprint
+	return &DIDDocument{
+		Context:            []string{"https://www.w3.org/ns/did/v1"},
+		ID:                 didKey,
+		VerificationMethod: []VerificationMethod{{ID: keyID, Type: Ed25519VerificationKeyType, Controller: didKey, PublicKey: hex.EncodeToString(publicKey)}},
+		Authentication:     []string{keyID},
+		AssertionMethod:    []string{keyID},
+		Service:            []Service{},
+	}, nil
+}
@@ -0,0 +1,59 @@
+package did
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/czh0526/game/server/pkg/multibase"
+)
+
+func TestKeyDIDRoundTrip(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	didKey := NewKeyDID(publicKey)
+	// Ed25519 的 did:key 都以 z6Mk 开头
+	if !strings.HasPrefix(didKey, KeyDIDPrefix+"z6Mk") || !IsKeyDID(didKey) {
+		t.Fatalf("unexpected did:key %s", didKey)
+	}
+
+	document, err := KeyDIDDocument(didKey)
+	if err != nil {
+		t.Fatalf("KeyDIDDocument: %v", err)
+	}
+	keyID := didKey + "#" + strings.TrimPrefix(didKey, KeyDIDPrefix)
+	method, ok := document.AuthenticationKey(keyID)
+	if !ok {
+		t.Fatalf("%s is not an authentication key", keyID)
+	}
+	if method.PublicKey != hex.EncodeToString(publicKey) || method.Controller != didKey {
+		t.Errorf("verification method %+v", method)
+	}
+	if len(document.AssertionMethod) != 1 || document.AssertionMethod[0] != keyID {
+		t.Errorf("assertion methods %v", document.AssertionMethod)
+	}
+}
+
+func TestKeyDIDDocumentRejectsInvalidIdentifiers(t *testing.T) {
+	publicKey := make([]byte, ed25519.PublicKeySize)
+	secp256k1 := append([]byte{0xe7, 0x01}, make([]byte, 33)...)
+
+	tests := map[string]string{
+		"other method":      "did:web:example.com",
+		"empty":             KeyDIDPrefix,
+		"unknown multibase": KeyDIDPrefix + "m" + hex.EncodeToString(publicKey),
+		"invalid base58":    KeyDIDPrefix + "z0OIl",
+		"other key type":    KeyDIDPrefix + multibase.EncodeBase58BTC(secp256k1),
+		"short key":         KeyDIDPrefix + multibase.EncodeBase58BTC(append([]byte{0xed, 0x01}, publicKey[:16]...)),
+	}
+	for name, didKey := range tests {
+		if _, err := KeyDIDDocument(didKey); err == nil {
+			t.Errorf("%s: %s expanded", name, didKey)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package did
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/bbs"
+)
+
+// 验证关系
+const (
+	RelationshipAuthentication  = "authentication"
+	RelationshipAssertionMethod = "assertionMethod"
+)
+
+// ErrDeactivated DID 已停用
+var ErrDeactivated = errors.New("DID has been deactivated")
+
+// Ed25519VerificationKeyType 玩家DID和颁发者使用的 Ed25519 验证方法类型
+const Ed25519VerificationKeyType = "Ed25519VerificationKey2018"
+
+// P256VerificationKeyType P-256 验证方法类型，公钥为十六进制编码的未压缩点
+const P256VerificationKeyType = "EcdsaSecp256r1VerificationKey2019"
+
+// Bls12381G2KeyType BBS+ 验证方法类型，公钥为十六进制编码的压缩 G2 点
+const Bls12381G2KeyType = "Bls12381G2Key2020"
+
+// NewVerificationKey 更新时新增的验证密钥
+type NewVerificationKey struct {
+	PublicKey     string   `json:"publicKeyHex"`
+	Relationships []string `json:"relationships"`
+}
+
+// DIDUpdate DID更新操作，需由现有的认证密钥签名
+//
+// 签名内容为去掉 signature 字段后按结构体字段顺序序列化的 JSON。
+// PreviousVersion 必须等于当前文档版本，防止更新被重放。
+type DIDUpdate struct {
+	DID             string               `json:"did"`
+	PreviousVersion int                  `json:"previousVersion"`
+	AddKeys         []NewVerificationKey `json:"addKeys,omitempty"`
+	RetireKeys      []string             `json:"retireKeys,omitempty"`
+	Deactivate      bool                 `json:"deactivate,omitempty"` // 永久停用 DID，不能与密钥变更同时使用
+	SigningKeyID    string               `json:"signingKeyId"`
+	Signature       string               `json:"signature,omitempty"`
+}
+
+// SigningPayload 返回需要签名的数据
+func (u *DIDUpdate) SigningPayload() ([]byte, error) {
+	unsigned := *u
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// Sign 使用十六进制私钥签名更新操作
+func (u *DIDUpdate) Sign(privateKeyHex string) error {
+	privateKey, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return fmt.Errorf("decode private key: %w", err)
+	}
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return errors.New("invalid ed25519 private key")
+	}
+
+	payload, err := u.SigningPayload()
+	if err != nil {
+		return fmt.Errorf("marshal update: %w", err)
+	}
+
+	u.Signature = hex.EncodeToString(ed25519.Sign(privateKey, payload))
+	return nil
+}
+
+// keySet 返回生效的验证方法和验证关系，未迁移的旧DID以 PublicKey 作为 #key-1，已停用的DID没有验证方法
+func (d *SimpleDID) keySet() ([]VerificationMethod, []string, []string) {
+	if d.Deactivated {
+		return nil, nil, nil
+	}
+	if len(d.VerificationMethods) == 0 {
+		keyID := d.ID + "#key-1"
+		return []VerificationMethod{
+			{
+				ID:         keyID,
+				Type:       Ed25519VerificationKeyType,
+				Controller: d.ID,
+				PublicKey:  d.PublicKey,
+			},
+		}, []string{keyID}, []string{keyID}
+	}
+
+	methods := append([]VerificationMethod(nil), d.VerificationMethods...)
+	authentication := append([]string(nil), d.Authentication...)
+	assertionMethod := append([]string(nil), d.AssertionMethod...)
+	return methods, authentication, assertionMethod
+}
+
+// Clone 深拷贝DID
+func (d *SimpleDID) Clone() *SimpleDID {
+	clone := *d
+	clone.VerificationMethods = append([]VerificationMethod(nil), d.VerificationMethods...)
+	clone.Authentication = append([]string(nil), d.Authentication...)
+	clone.AssertionMethod = append([]string(nil), d.AssertionMethod...)
+	if d.UpdatedAt != nil {
+		updatedAt := *d.UpdatedAt
+		clone.UpdatedAt = &updatedAt
+	}
+	return &clone
+}
+
+// PublicKeyFor 查找验证方法对应的十六进制公钥
+func (d *SimpleDID) PublicKeyFor(keyID string) (string, bool) {
+	methods, _, _ := d.keySet()
+	for _, method := range methods {
+		if method.ID == keyID {
+			return method.PublicKey, true
+		}
+	}
+	return "", false
+}
+
+// CryptoPublicKey 解码验证方法的公钥，P-256 类型返回 *ecdsa.PublicKey，BBS+ 类型返回 *bbs.PublicKey，
+// 其他类型按 Ed25519 解码
+func (m VerificationMethod) CryptoPublicKey() (crypto.PublicKey, error) {
+	keyBytes, err := hex.DecodeString(m.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode public key: %w", err)
+	}
+
+	switch m.Type {
+	case P256VerificationKeyType:
+		x, y := elliptic.Unmarshal(elliptic.P256(), keyBytes)
+		if x == nil {
+			return nil, fmt.Errorf("invalid P-256 public key: %s", m.ID)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case Bls12381G2KeyType:
+		return bbs.PublicKeyFromBytes(keyBytes)
+	default:
+		if len(keyBytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key: %s", m.ID)
+		}
+		return ed25519.PublicKey(keyBytes), nil
+	}
+}
+
+// AuthenticationKey 返回文档中列在 authentication 关系里的验证方法
+func (d *DIDDocument) AuthenticationKey(keyID string) (VerificationMethod, bool) {
+	authenticates := false
+	for _, ref := range d.Authentication {
+		if ref == keyID {
+			authenticates = true
+			break
+		}
+	}
+	if !authenticates {
+		return VerificationMethod{}, false
+	}
+
+	for _, method := range d.VerificationMethod {
+		if method.ID == keyID {
+			return method, true
+		}
+	}
+	return VerificationMethod{}, false
+}
+
+// HasRelationship 检查验证方法是否具有指定的验证关系
+func (d *SimpleDID) HasRelationship(keyID, relationship string) bool {
+	_, authentication, assertionMethod := d.keySet()
+
+	var refs []string
+	switch relationship {
+	case RelationshipAuthentication:
+		refs = authentication
+	case RelationshipAssertionMethod:
+		refs = assertionMethod
+	default:
+		return false
+	}
+
+	for _, ref := range refs {
+		if ref == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyUpdate 验证签名并应用更新操作，签名密钥必须是现有的认证密钥
+func (d *SimpleDID) ApplyUpdate(update *DIDUpdate) error {
+	if update.DID != d.ID {
+		return fmt.Errorf("update targets %s, not %s", update.DID, d.ID)
+	}
+	if d.Deactivated {
+		return ErrDeactivated
+	}
+	if update.PreviousVersion != d.Version {
+		return fmt.Errorf("version mismatch: expected %d, got %d", d.Version, update.PreviousVersion)
+	}
+	if update.Deactivate && (len(update.AddKeys) > 0 || len(update.RetireKeys) > 0) {
+		return errors.New("deactivation cannot be combined with key changes")
+	}
+	if !update.Deactivate && len(update.AddKeys) == 0 && len(update.RetireKeys) == 0 {
+		return errors.New("update contains no changes")
+	}
+
+	// 验证对现有密钥的控制权
+	if !d.HasRelationship(update.SigningKeyID, RelationshipAuthentication) {
+		return fmt.Errorf("signing key is not an authentication key: %s", update.SigningKeyID)
+	}
+	publicKeyHex, _ := d.PublicKeyFor(update.SigningKeyID)
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return fmt.Errorf("decode signing key: %w", err)
+	}
+	signature, err := hex.DecodeString(update.Signature)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	payload, err := update.SigningPayload()
+	if err != nil {
+		return fmt.Errorf("marshal update: %w", err)
+	}
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, payload, signature) {
+		return errors.New("invalid update signature")
+	}
+
+	now := time.Now()
+	if update.Deactivate {
+		d.Deactivated = true
+		d.VerificationMethods = nil
+		d.Authentication = nil
+		d.AssertionMethod = nil
+		d.Version++
+		d.UpdatedAt = &now
+		return nil
+	}
+
+	methods, authentication, assertionMethod := d.keySet()
+	lastKeyIndex := d.lastKeyIndex(methods)
+
+	for _, newKey := range update.AddKeys {
+		keyBytes, err := hex.DecodeString(newKey.PublicKey)
+		if err != nil || len(keyBytes) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid public key: %s", newKey.PublicKey)
+		}
+		if len(newKey.Relationships) == 0 {
+			return errors.New("new key must declare at least one relationship")
+		}
+
+		lastKeyIndex++
+		keyID := fmt.Sprintf("%s#key-%d", d.ID, lastKeyIndex)
+		methods = append(methods, VerificationMethod{
+			ID:         keyID,
+			Type:       Ed25519VerificationKeyType,
+			Controller: d.ID,
+			PublicKey:  newKey.PublicKey,
+		})
+
+		for _, relationship := range newKey.Relationships {
+			switch relationship {
+			case RelationshipAuthentication:
+				authentication = append(authentication, keyID)
+			case RelationshipAssertionMethod:
+				assertionMethod = append(assertionMethod, keyID)
+			default:
+				return fmt.Errorf("unsupported relationship: %s", relationship)
+			}
+		}
+	}
+
+	for _, keyID := range update.RetireKeys {
+		found := false
+		for i, method := range methods {
+			if method.ID == keyID {
+				methods = append(methods[:i], methods[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("verification method not found: %s", keyID)
+		}
+		authentication = removeRef(authentication, keyID)
+		assertionMethod = removeRef(assertionMethod, keyID)
+	}
+
+	// 至少保留一个认证密钥，否则DID将无法再被控制
+	if len(authentication) == 0 {
+		return errors.New("update would remove all authentication keys")
+	}
+
+	d.VerificationMethods = methods
+	d.Authentication = authentication
+	d.AssertionMethod = assertionMethod
+	d.LastKeyIndex = lastKeyIndex
+	d.Version++
+	d.UpdatedAt = &now
+
+	// 主公钥始终指向第一个认证密钥
+	if primary, ok := d.PublicKeyFor(authentication[0]); ok {
+		d.PublicKey = primary
+	}
+
+	return nil
+}
+
+// lastKeyIndex 返回已分配的最大 #key-N 序号。未记录 LastKeyIndex 的旧 DID 以现有验证方法中的最大序号为准
+func (d *SimpleDID) lastKeyIndex(methods []VerificationMethod) int {
+	last := d.LastKeyIndex
+	for _, method := range methods {
+		if n := KeyIndex(method.ID); n > last {
+			last = n
+		}
+	}
+	return last
+}
+
+// KeyIndex 返回验证方法 ID 中 #key-N 的序号，其他形式的 ID 返回 0
+func KeyIndex(keyID string) int {
+	idx := strings.LastIndex(keyID, "#key-")
+	if idx < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(keyID[idx+len("#key-"):])
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func removeRef(refs []string, keyID string) []string {
+	result := refs[:0]
+	for _, ref := range refs {
+		if ref != keyID {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+package did
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// newTestDID 创建玩家 DID，返回 DID 和 #key-1 的十六进制私钥
+func newTestDID(t *testing.T) (*SimpleDID, string) {
+	t.Helper()
+	d, err := CreatePlayerDID("test", "alice")
+	if err != nil {
+		t.Fatalf("CreatePlayerDID: %v", err)
+	}
+	privateKey := d.PrivateKey
+	d.PrivateKey = ""
+	return d, privateKey
+}
+
+// newTestKey 生成新的 Ed25519 密钥，返回十六进制公钥和私钥
+func newTestKey(t *testing.T) (string, string) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(publicKey), hex.EncodeToString(privateKey)
+}
+
+// signedUpdate 构造并用 signingKeyID 对应的私钥签名更新
+func signedUpdate(t *testing.T, d *SimpleDID, signingKeyID, privateKey string, addKeys []NewVerificationKey, retireKeys []string) *DIDUpdate {
+	t.Helper()
+	update := &DIDUpdate{
+		DID:             d.ID,
+		PreviousVersion: d.Version,
+		AddKeys:         addKeys,
+		RetireKeys:      retireKeys,
+		SigningKeyID:    signingKeyID,
+	}
+	if err := update.Sign(privateKey); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return update
+}
+
+func authKey(publicKey string) []NewVerificationKey {
+	return []NewVerificationKey{{PublicKey: publicKey, Relationships: []string{RelationshipAuthentication}}}
+}
+
+func TestApplyUpdateRoundTrip(t *testing.T) {
+	d, privateKey := newTestDID(t)
+	newPublic, _ := newTestKey(t)
+
+	update := signedUpdate(t, d, d.ID+"#key-1", privateKey, authKey(newPublic), nil)
+	if err := d.ApplyUpdate(update); err != nil {
+		t.Fatalf("ApplyUpdate: %v", err)
+	}
+	if d.Version != 1 {
+		t.Errorf("version %d, want 1", d.Version)
+	}
+	if publicKey, ok := d.PublicKeyFor(d.ID + "#key-2"); !ok || publicKey != newPublic {
+		t.Errorf("#key-2 = %q, %v", publicKey, ok)
+	}
+	if !d.HasRelationship(d.ID+"#key-2", RelationshipAuthentication) {
+		t.Error("#key-2 is not an authentication key")
+	}
+	if d.HasRelationship(d.ID+"#key-2", RelationshipAssertionMethod) {
+		t.Error("#key-2 gained an undeclared relationship")
+	}
+	if _, ok := d.ToDIDDocument().AuthenticationKey(d.ID + "#key-2"); !ok {
+		t.Error("document does not list #key-2 for authentication")
+	}
+}
+
+func TestApplyUpdateRejectsTampering(t *testing.T) {
+	newPublic, _ := newTestKey(t)
+	otherPublic, otherPrivate := newTestKey(t)
+
+	tests := []struct {
+		name   string
+		tamper func(d *SimpleDID, update *DIDUpdate, privateKey string) *DIDUpdate
+	}{
+		{"modified after signing", func(d *SimpleDID, update *DIDUpdate, _ string) *DIDUpdate {
+			update.AddKeys[0].PublicKey = otherPublic
+			return update
+		}},
+		{"signature from another key", func(d *SimpleDID, update *DIDUpdate, _ string) *DIDUpdate {
+			update.Signature = ""
+			if err := update.Sign(otherPrivate); err != nil {
+				t.Fatal(err)
+			}
+			return update
+		}},
+		{"corrupted signature", func(d *SimpleDID, update *DIDUpdate, _ string) *DIDUpdate {
+			update.Signature = strings.Repeat("00", ed25519.SignatureSize)
+			return update
+		}},
+		{"replayed version", func(d *SimpleDID, update *DIDUpdate, privateKey string) *DIDUpdate {
+			if err := d.ApplyUpdate(update); err != nil {
+				t.Fatal(err)
+			}
+			return update
+		}},
+		{"unknown signing key", func(d *SimpleDID, update *DIDUpdate, privateKey string) *DIDUpdate {
+			return signedUpdate(t, d, d.ID+"#key-9", privateKey, update.AddKeys, nil)
+		}},
+		{"other DID", func(d *SimpleDID, update *DIDUpdate, privateKey string) *DIDUpdate {
+			update.DID = "did:player:test:bob"
+			update.Signature = ""
+			if err := update.Sign(privateKey); err != nil {
+				t.Fatal(err)
+			}
+			return update
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, privateKey := newTestDID(t)
+			update := signedUpdate(t, d, d.ID+"#key-1", privateKey, authKey(newPublic), nil)
+			update = tt.tamper(d, update, privateKey)
+
+			before := d.Clone()
+			if err := d.ApplyUpdate(update); err == nil {
+				t.Fatal("tampered update applied")
+			}
+			if d.Version != before.Version || len(d.VerificationMethods) != len(before.VerificationMethods) {
+				t.Error("rejected update changed the DID")
+			}
+		})
+	}
+}
+
+func TestApplyUpdateRequiresAuthenticationKey(t *testing.T) {
+	d, privateKey := newTestDID(t)
+	assertPublic, assertPrivate := newTestKey(t)
+	newPublic, _ := newTestKey(t)
+
+	add := []NewVerificationKey{{PublicKey: assertPublic, Relationships: []string{RelationshipAssertionMethod}}}
+	if err := d.ApplyUpdate(signedUpdate(t, d, d.ID+"#key-1", privateKey, add, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有断言关系的密钥不能控制 DID
+	if err := d.ApplyUpdate(signedUpdate(t, d, d.ID+"#key-2", assertPrivate, authKey(newPublic), nil)); err == nil {
+		t.Fatal("update signed by an assertion-only key applied")
+	}
+	// 不能移除最后一个认证密钥
+	if err := d.ApplyUpdate(signedUpdate(t, d, d.ID+"#key-1", privateKey, nil, []string{d.ID + "#key-1"})); err == nil {
+		t.Fatal("update removed the last authentication key")
+	}
+}
+
+func TestApplyUpdateNeverReusesKeyIDs(t *testing.T) {
+	d, privateKey := newTestDID(t)
+	signingKeyID := d.ID + "#key-1"
+	seen := map[string]string{}
+
+	// 每轮新增一个密钥并移除上一轮新增的密钥
+	var previous string
+	for i := 0; i < 3; i++ {
+		publicKey, _ := newTestKey(t)
+		var retire []string
+		if previous != "" {
+			retire = []string{previous}
+		}
+		if err := d.ApplyUpdate(signedUpdate(t, d, signingKeyID, privateKey, authKey(publicKey), retire)); err != nil {
+			t.Fatalf("round %d: %v", i, err)
+		}
+		for _, method := range d.VerificationMethods {
+			if method.PublicKey != publicKey {
+				continue
+			}
+			if owner, ok := seen[method.ID]; ok && owner != publicKey {
+				t.Fatalf("%s reassigned to a new key", method.ID)
+			}
+			seen[method.ID] = publicKey
+			previous = method.ID
+		}
+	}
+	if previous != d.ID+"#key-4" {
+		t.Errorf("last key %s, want #key-4", previous)
+	}
+
+	// 序号随 DID 记录持久化
+	data, err := d.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := FromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.ApplyUpdate(signedUpdate(t, restored, signingKeyID, privateKey, nil, []string{previous})); err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := newTestKey(t)
+	if err := restored.ApplyUpdate(signedUpdate(t, restored, signingKeyID, privateKey, authKey(publicKey), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := restored.PublicKeyFor(restored.ID + "#key-5"); got != publicKey {
+		t.Errorf("key added after retiring #key-4 is not #key-5")
+	}
+}
+
+func TestKeyIndex(t *testing.T) {
+	tests := map[string]int{
+		"did:player:test:alice#key-1":  1,
+		"did:player:test:alice#key-12": 12,
+		"#key-3":                       3,
+		"did:player:test:alice#bbs-1":  0,
+		"did:player:test:alice#key-x":  0,
+		"did:player:test:alice":        0,
+	}
+	for keyID, want := range tests {
+		if got := KeyIndex(keyID); got != want {
+			t.Errorf("KeyIndex(%q) = %d, want %d", keyID, got, want)
+		}
+	}
+}
@@ -18,6 +18,19 @@ type SimpleDID struct {
 	GameID     string    `json:"gameId"`
 	PlayerID   string    `json:"playerId"`
 	CreatedAt  time.Time `json:"createdAt"`
+
+	// 多密钥支持，为空时由 PublicKey 生成默认的 #key-1
+	VerificationMethods []VerificationMethod `json:"verificationMethods,omitempty"`
+	Authentication      []string             `json:"authentication,omitempty"`
+	AssertionMethod     []string             `json:"assertionMethod,omitempty"`
+	Version             int                  `json:"version"`
+	UpdatedAt           *time.Time           `json:"updatedAt,omitempty"`
+
+	// LastKeyIndex 已分配的最大 #key-N 序号，只增不减，移除的密钥 ID 不会再分配给新密钥
+	LastKeyIndex int `json:"lastKeyIndex,omitempty"`
+
+	// Deactivated 已停用的 DID 不再有验证方法，不能认证、被颁发凭证或更新
+	Deactivated bool `json:"deactivated,omitempty"`
 }
 
 // DIDDocument DID文档
@@ -25,8 +38,11 @@ type DIDDocument struct {
 	Context            []string                   `json:"@context"`
 	ID                 string                     `json:"id"`
 	VerificationMethod []VerificationMethod       `json:"verificationMethod"`
+	Authentication     []string                   `json:"authentication,omitempty"`
+	AssertionMethod    []string                   `json:"assertionMethod,omitempty"`
 	Service            []Service                  `json:"service"`
 	CreatedAt          time.Time                  `json:"created"`
+	UpdatedAt          *time.Time                 `json:"updated,omitempty"`
 }
 
 // VerificationMethod 验证方法
@@ -65,22 +81,30 @@ func CreatePlayerDID(gameID, playerID string) (*SimpleDID, error) {
 	return did, nil
 }
 
-// ToDIDDocument 转换为DID文档
+// ToDIDDocument 转换为DID文档，已停用的 DID 只保留标识符
 func (d *SimpleDID) ToDIDDocument() *DIDDocument {
+	if d.Deactivated {
+		return &DIDDocument{
+			Context:            []string{"https://www.w3.org/ns/did/v1"},
+			ID:                 d.ID,
+			VerificationMethod: []VerificationMethod{},
+			Service:            []Service{},
+			CreatedAt:          d.CreatedAt,
+			UpdatedAt:          d.UpdatedAt,
+		}
+	}
+
+	methods, authentication, assertionMethod := d.keySet()
+
 	return &DIDDocument{
 		Context: []string{
 			"https://www.w3.org/ns/did/v1",
 			"https://game.example.com/contexts/player/v1",
 		},
-		ID: d.ID,
-		VerificationMethod: []VerificationMethod{
-			{
-				ID:         d.ID + "#key-1",
-				Type:       "Ed25519VerificationKey2018",
-				Controller: d.ID,
-				PublicKey:  d.PublicKey,
-			},
-		},
+		ID:                 d.ID,
+		VerificationMethod: methods,
+		Authentication:     authentication,
+		AssertionMethod:    assertionMethod,
 		Service: []Service{
 			{
 				ID:   d.ID + "#game-service",
@@ -93,6 +117,7 @@ func (d *SimpleDID) ToDIDDocument() *DIDDocument {
 			},
 		},
 		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
 	}
 }
 
@@ -0,0 +1,54 @@
+package did
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WebDIDPrefix did:web 方法前缀
+const WebDIDPrefix = "did:web:"
+
+// WellKnownDIDPath did:web 根路径 DID 文档的位置
+const WellKnownDIDPath = "/.well-known/did.json"
+
+// IsWebDID 判断是否为 did:web 标识符
+func IsWebDID(didString string) bool {
+	return strings.HasPrefix(didString, WebDIDPrefix) && len(didString) > len(WebDIDPrefix)
+}
+
+// WebDIDFromURL 根据服务地址生成 did:web 标识符，仅使用主机部分，端口中的冒号编码为 %3A
+func WebDIDFromURL(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parse url: %w", err)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("url has no host: %s", rawURL)
+	}
+
+	return WebDIDPrefix + strings.ReplaceAll(parsed.Host, ":", "%3A"), nil
+}
+
+// WebDIDDocumentURL 返回 did:web 标识符对应的 DID 文档地址
+func WebDIDDocumentURL(didWeb string) (string, error) {
+	if !IsWebDID(didWeb) {
+		return "", fmt.Errorf("not a did:web identifier: %s", didWeb)
+	}
+
+	segments := strings.Split(strings.TrimPrefix(didWeb, WebDIDPrefix), ":")
+	host, err := url.PathUnescape(segments[0])
+	if err != nil {
+		return "", fmt.Errorf("decode host: %w", err)
+	}
+
+	scheme := "https"
+	if strings.HasPrefix(host, "localhost") || strings.HasPrefix(host, "127.0.0.1") {
+		scheme = "http"
+	}
+
+	if len(segments) == 1 {
+		return scheme + "://" + host + WellKnownDIDPath, nil
+	}
+	return scheme + "://" + host + "/" + strings.Join(segments[1:], "/") + "/did.json", nil
+}
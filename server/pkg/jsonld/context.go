@@ -0,0 +1,432 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// maxContextDepth 远程上下文的最大嵌套层数，防止上下文互相引用
+const maxContextDepth = 10
+
+// termDefinition 上下文中的术语定义
+type termDefinition struct {
+	id         string // 映射的 IRI 或关键字，为空表示术语映射为 null
+	typ        string // 类型强制：@id、@vocab 或数据类型 IRI
+	container  map[string]bool
+	context    interface{} // 作用域上下文
+	hasContext bool
+	language   *string
+	protected  bool
+	prefix     bool
+}
+
+// equal 比较两个定义，受保护的术语只能被相同的定义覆盖
+func (d *termDefinition) equal(other *termDefinition) bool {
+	return d.id == other.id && d.typ == other.typ && d.prefix == other.prefix &&
+		reflect.DeepEqual(d.container, other.container) &&
+		d.hasContext == other.hasContext && reflect.DeepEqual(d.context, other.context) &&
+		reflect.DeepEqual(d.language, other.language)
+}
+
+func (d *termDefinition) hasContainer(container string) bool {
+	return d != nil && d.container[container]
+}
+
+// activeContext 活动上下文
+type activeContext struct {
+	vocab    string
+	base     string
+	language string
+	terms    map[string]*termDefinition
+	// previous 类型作用域上下文生效前的上下文，展开嵌套节点时恢复
+	previous *activeContext
+}
+
+func newActiveContext() *activeContext {
+	return &activeContext{terms: make(map[string]*termDefinition)}
+}
+
+func (c *activeContext) clone() *activeContext {
+	clone := *c
+	clone.terms = make(map[string]*termDefinition, len(c.terms))
+	for term, definition := range c.terms {
+		clone.terms[term] = definition
+	}
+	return &clone
+}
+
+// process 按上下文处理算法将 local 应用到活动上下文上，返回新的活动上下文。
+// propagate 为 false 时（类型作用域上下文）新上下文记住原上下文，嵌套节点不继承；
+// overrideProtected 为 true 时（属性作用域上下文）允许覆盖受保护的术语
+func (c *activeContext) process(local interface{}, propagate, overrideProtected bool, depth int) (*activeContext, error) {
+	if depth > maxContextDepth {
+		return nil, errors.New("context nesting is too deep")
+	}
+
+	// 上下文自身的 @propagate 优先
+	if object, ok := local.(map[string]interface{}); ok {
+		if value, exists := object["@propagate"]; exists {
+			flag, ok := value.(bool)
+			if !ok {
+				return nil, errors.New("invalid @propagate value")
+			}
+			propagate = flag
+		}
+	}
+
+	result := c.clone()
+	if !propagate && result.previous == nil {
+		result.previous = c
+	}
+
+	for _, entry := range asArray(local) {
+		switch context := entry.(type) {
+		case nil:
+			if !overrideProtected {
+				for term, definition := range result.terms {
+					if definition.protected {
+						return nil, fmt.Errorf("cannot clear the context: term %q is protected", term)
+					}
+				}
+			}
+			previous := result.previous
+			result = newActiveContext()
+			if !propagate {
+				result.previous = previous
+			}
+		case string:
+			document, err := loadContext(context)
+			if err != nil {
+				return nil, err
+			}
+			embedded, ok := document["@context"]
+			if !ok {
+				return nil, fmt.Errorf("context %s has no @context", context)
+			}
+			if result, err = result.process(embedded, true, overrideProtected, depth+1); err != nil {
+				return nil, fmt.Errorf("context %s: %w", context, err)
+			}
+		case map[string]interface{}:
+			if err := result.define(context, overrideProtected); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid local context of type %T", entry)
+		}
+	}
+	return result, nil
+}
+
+// define 处理一个上下文对象中的设置和术语定义
+func (c *activeContext) define(context map[string]interface{}, overrideProtected bool) error {
+	if version, exists := context["@version"]; exists {
+		if number, ok := version.(json.Number); !ok || number.String() != "1.1" {
+			return fmt.Errorf("unsupported @version: %v", version)
+		}
+	}
+	if _, exists := context["@import"]; exists {
+		return errors.New("@import is not supported")
+	}
+	if value, exists := context["@base"]; exists {
+		switch base := value.(type) {
+		case nil:
+			c.base = ""
+		case string:
+			c.base = base
+		default:
+			return errors.New("invalid @base value")
+		}
+	}
+	if value, exists := context["@vocab"]; exists {
+		switch vocab := value.(type) {
+		case nil:
+			c.vocab = ""
+		case string:
+			expanded, err := c.expandIRI(vocab, true, true, nil, nil)
+			if err != nil {
+				return err
+			}
+			if !isAbsoluteIRI(expanded) {
+				return fmt.Errorf("invalid @vocab: %s", vocab)
+			}
+			c.vocab = expanded
+		default:
+			return errors.New("invalid @vocab value")
+		}
+	}
+	if value, exists := context["@language"]; exists {
+		switch language := value.(type) {
+		case nil:
+			c.language = ""
+		case string:
+			c.language = strings.ToLower(language)
+		default:
+			return errors.New("invalid @language value")
+		}
+	}
+
+	protected := false
+	if value, exists := context["@protected"]; exists {
+		flag, ok := value.(bool)
+		if !ok {
+			return errors.New("invalid @protected value")
+		}
+		protected = flag
+	}
+
+	defined := make(map[string]bool)
+	for _, term := range sortedKeys(context) {
+		switch term {
+		case "@base", "@direction", "@import", "@language", "@propagate", "@protected", "@version", "@vocab":
+			continue
+		}
+		if err := c.createTerm(context, term, defined, protected, overrideProtected); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTerm 按创建术语定义算法定义 term，defined 记录本上下文中已处理（true）和正在处理（false）的术语
+func (c *activeContext) createTerm(local map[string]interface{}, term string, defined map[string]bool, defaultProtected, overrideProtected bool) error {
+	if done, exists := defined[term]; exists {
+		if done {
+			return nil
+		}
+		return fmt.Errorf("cyclic IRI mapping for term %q", term)
+	}
+	if term == "" {
+		return errors.New("invalid empty term")
+	}
+	defined[term] = false
+
+	value := local[term]
+	if isKeyword(term) {
+		return fmt.Errorf("keyword %s cannot be redefined", term)
+	}
+	if looksLikeKeyword(term) {
+		defined[term] = true
+		return nil
+	}
+
+	previous := c.terms[term]
+	delete(c.terms, term)
+
+	definition := &termDefinition{protected: defaultProtected}
+	simpleTerm := false
+	var object map[string]interface{}
+	switch v := value.(type) {
+	case nil:
+		object = map[string]interface{}{"@id": nil}
+	case string:
+		object = map[string]interface{}{"@id": v}
+		simpleTerm = true
+	case map[string]interface{}:
+		object = v
+	default:
+		return fmt.Errorf("invalid definition for term %q", term)
+	}
+
+	if value, exists := object["@protected"]; exists {
+		flag, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("invalid @protected for term %q", term)
+		}
+		definition.protected = flag
+	}
+
+	if value, exists := object["@type"]; exists {
+		typ, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("invalid @type mapping for term %q", term)
+		}
+		expanded, err := c.expandIRI(typ, false, true, local, defined)
+		if err != nil {
+			return err
+		}
+		switch {
+		case expanded == "@json":
+			return fmt.Errorf("term %q: @json values are not supported", term)
+		case expanded == "@id", expanded == "@vocab", expanded == "@none", isAbsoluteIRI(expanded) && !strings.HasPrefix(expanded, "_:"):
+			definition.typ = expanded
+		default:
+			return fmt.Errorf("invalid @type mapping %q for term %q", typ, term)
+		}
+	}
+
+	if _, exists := object["@reverse"]; exists {
+		return fmt.Errorf("term %q: reverse properties are not supported", term)
+	}
+
+	if id, exists := object["@id"]; exists && id != term {
+		switch v := id.(type) {
+		case nil:
+			// 映射为 null 的术语，展开时其属性被忽略
+		case string:
+			if !isKeyword(v) && looksLikeKeyword(v) {
+				defined[term] = true
+				return nil
+			}
+			expanded, err := c.expandIRI(v, false, true, local, defined)
+			if err != nil {
+				return err
+			}
+			if !isKeyword(expanded) && !isAbsoluteIRI(expanded) {
+				return fmt.Errorf("term %q has an invalid IRI mapping %q", term, v)
+			}
+			if expanded == "@context" {
+				return fmt.Errorf("term %q cannot be an alias of @context", term)
+			}
+			definition.id = expanded
+			if !strings.ContainsAny(term, ":/") && simpleTerm &&
+				(strings.HasPrefix(expanded, "_:") || strings.ContainsAny(expanded[len(expanded)-1:], ":/?#[]@")) {
+				definition.prefix = true
+			}
+		default:
+			return fmt.Errorf("invalid @id for term %q", term)
+		}
+	} else if colon := strings.IndexByte(term, ':'); colon > 0 {
+		// 紧凑 IRI 形式的术语
+		prefix, suffix := term[:colon], term[colon+1:]
+		if _, exists := local[prefix]; exists {
+			if err := c.createTerm(local, prefix, defined, defaultProtected, overrideProtected); err != nil {
+				return err
+			}
+		}
+		if prefixDefinition := c.terms[prefix]; prefixDefinition != nil && prefixDefinition.id != "" {
+			definition.id = prefixDefinition.id + suffix
+		} else {
+			definition.id = term
+		}
+	} else if strings.Contains(term, "/") {
+		expanded, err := c.expandIRI(term, false, true, nil, nil)
+		if err != nil {
+			return err
+		}
+		if !isAbsoluteIRI(expanded) {
+			return fmt.Errorf("term %q is not an IRI", term)
+		}
+		definition.id = expanded
+	} else if term == "@type" {
+		definition.id = "@type"
+	} else if c.vocab != "" {
+		definition.id = c.vocab + term
+	} else if value != nil {
+		return fmt.Errorf("term %q has no IRI mapping", term)
+	}
+
+	if value, exists := object["@container"]; exists {
+		definition.container = make(map[string]bool)
+		for _, entry := range asArray(value) {
+			container, ok := entry.(string)
+			if !ok {
+				return fmt.Errorf("invalid @container for term %q", term)
+			}
+			switch container {
+			case "@set", "@list", "@graph", "@language", "@index", "@id", "@type":
+				definition.container[container] = true
+			default:
+				return fmt.Errorf("invalid @container %q for term %q", container, term)
+			}
+		}
+	}
+
+	if value, exists := object["@context"]; exists {
+		definition.context = value
+		definition.hasContext = true
+	}
+
+	if value, exists := object["@language"]; exists {
+		language := ""
+		switch v := value.(type) {
+		case nil:
+		case string:
+			language = strings.ToLower(v)
+		default:
+			return fmt.Errorf("invalid @language for term %q", term)
+		}
+		definition.language = &language
+	}
+
+	if value, exists := object["@prefix"]; exists {
+		flag, ok := value.(bool)
+		if !ok || strings.ContainsAny(term, ":/") {
+			return fmt.Errorf("invalid @prefix for term %q", term)
+		}
+		definition.prefix = flag
+	}
+
+	if previous != nil && previous.protected && !overrideProtected {
+		if !previous.equal(definition) {
+			return fmt.Errorf("protected term %q cannot be redefined", term)
+		}
+		definition = previous
+	}
+
+	c.terms[term] = definition
+	defined[term] = true
+	return nil
+}
+
+// expandIRI 按 IRI 展开算法展开 value。vocab 为 true 时按词汇表相对展开（属性名、类型），
+// documentRelative 为 true 时按 @base 解析相对 IRI。结果为空表示映射为 null
+func (c *activeContext) expandIRI(value string, documentRelative, vocab bool, local map[string]interface{}, defined map[string]bool) (string, error) {
+	if isKeyword(value) {
+		return value, nil
+	}
+	if looksLikeKeyword(value) {
+		return "", nil
+	}
+
+	if local != nil {
+		if _, exists := local[value]; exists && !defined[value] {
+			if err := c.createTerm(local, value, defined, false, false); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if definition, exists := c.terms[value]; exists && (vocab || isKeyword(definition.id)) {
+		return definition.id, nil
+	}
+
+	if colon := strings.IndexByte(value, ':'); colon > 0 {
+		prefix, suffix := value[:colon], value[colon+1:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value, nil
+		}
+		if local != nil {
+			if _, exists := local[prefix]; exists && !defined[prefix] {
+				if err := c.createTerm(local, prefix, defined, false, false); err != nil {
+					return "", err
+				}
+			}
+		}
+		if definition := c.terms[prefix]; definition != nil && definition.id != "" && definition.prefix {
+			return definition.id + suffix, nil
+		}
+		if isAbsoluteIRI(value) {
+			return value, nil
+		}
+	}
+
+	if vocab && c.vocab != "" {
+		return c.vocab + value, nil
+	}
+	if documentRelative && c.base != "" {
+		base, err := url.Parse(c.base)
+		if err != nil {
+			return "", fmt.Errorf("invalid @base: %w", err)
+		}
+		reference, err := url.Parse(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid IRI %q: %w", value, err)
+		}
+		return base.ResolveReference(reference).String(), nil
+	}
+	return value, nil
+}
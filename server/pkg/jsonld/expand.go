@@ -0,0 +1,338 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// processor 展开时的状态
+type processor struct{}
+
+// expand 按展开算法展开 element，activeProperty 为其所在的属性
+func (p *processor) expand(active *activeContext, activeProperty string, element interface{}) (interface{}, error) {
+	definition := active.terms[activeProperty]
+
+	switch value := element.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			expanded, err := p.expand(active, activeProperty, item)
+			if err != nil {
+				return nil, err
+			}
+			if definition.hasContainer("@list") {
+				if _, nested := expanded.([]interface{}); nested {
+					return nil, errors.New("lists of lists are not supported")
+				}
+			}
+			switch e := expanded.(type) {
+			case nil:
+			case []interface{}:
+				result = append(result, e...)
+			default:
+				result = append(result, e)
+			}
+		}
+		return result, nil
+	case map[string]interface{}:
+		return p.expandMap(active, activeProperty, value)
+	default:
+		// 标量
+		if activeProperty == "" || activeProperty == "@graph" {
+			return nil, nil
+		}
+		if definition != nil && definition.hasContext {
+			var err error
+			if active, err = active.process(definition.context, true, true, 0); err != nil {
+				return nil, err
+			}
+		}
+		return expandValue(active, activeProperty, value)
+	}
+}
+
+// expandMap 展开 JSON 对象
+func (p *processor) expandMap(active *activeContext, activeProperty string, element map[string]interface{}) (interface{}, error) {
+	definition := active.terms[activeProperty]
+
+	// 类型作用域上下文不传递到嵌套的节点对象
+	if active.previous != nil && !p.isValueOrReference(active, element) {
+		active = active.previous
+	}
+	if definition != nil && definition.hasContext {
+		var err error
+		if active, err = active.process(definition.context, true, true, 0); err != nil {
+			return nil, err
+		}
+	}
+	if local, exists := element["@context"]; exists {
+		var err error
+		if active, err = active.process(local, true, false, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	// 按字典序处理 @type 的值，应用其类型作用域上下文
+	typeScoped := active
+	for _, key := range sortedKeys(element) {
+		expanded, err := active.expandIRI(key, false, true, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if expanded != "@type" {
+			continue
+		}
+		var types []string
+		for _, entry := range asArray(element[key]) {
+			if typ, ok := entry.(string); ok {
+				types = append(types, typ)
+			}
+		}
+		sort.Strings(types)
+		for _, typ := range types {
+			if typeDefinition := typeScoped.terms[typ]; typeDefinition != nil && typeDefinition.hasContext {
+				if active, err = active.process(typeDefinition.context, false, false, 0); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	result := make(map[string]interface{})
+	if err := p.expandObject(active, typeScoped, activeProperty, element, result); err != nil {
+		return nil, err
+	}
+
+	if value, exists := result["@value"]; exists {
+		for key := range result {
+			switch key {
+			case "@value", "@type", "@language", "@index":
+			default:
+				return nil, fmt.Errorf("invalid value object key %s", key)
+			}
+		}
+		if value == nil {
+			return nil, nil
+		}
+		if _, isString := value.(string); !isString && result["@language"] != nil {
+			return nil, errors.New("only strings can have a language")
+		}
+		if typ, exists := result["@type"]; exists {
+			if iri, ok := typ.(string); !ok || !isAbsoluteIRI(iri) {
+				return nil, fmt.Errorf("invalid value type %v", typ)
+			}
+		}
+	} else if typ, exists := result["@type"]; exists {
+		if _, isArray := typ.([]interface{}); !isArray {
+			result["@type"] = []interface{}{typ}
+		}
+	} else if set, exists := result["@set"]; exists {
+		if len(result) > 1 {
+			return nil, errors.New("@set objects cannot have other keys")
+		}
+		return set, nil
+	}
+
+	if len(result) == 1 && result["@language"] != nil {
+		return nil, nil
+	}
+
+	// 顶层和 @graph 中没有属性的对象不产生数据
+	if activeProperty == "" || activeProperty == "@graph" {
+		_, hasValue := result["@value"]
+		_, hasList := result["@list"]
+		_, hasID := result["@id"]
+		if len(result) == 0 || hasValue || hasList || (len(result) == 1 && hasID) {
+			return nil, nil
+		}
+	}
+	return result, nil
+}
+
+// isValueOrReference 对象是否为值对象或只有 @id 的节点引用，这两种对象保留类型作用域上下文
+func (p *processor) isValueOrReference(active *activeContext, element map[string]interface{}) bool {
+	onlyID := len(element) == 1
+	for key := range element {
+		expanded, err := active.expandIRI(key, false, true, nil, nil)
+		if err != nil {
+			return false
+		}
+		if expanded == "@value" {
+			return true
+		}
+		if expanded != "@id" {
+			onlyID = false
+		}
+	}
+	return onlyID
+}
+
+// expandObject 展开对象的每个属性写入 result，typeScoped 用于展开 @type 的值
+func (p *processor) expandObject(active, typeScoped *activeContext, activeProperty string, element, result map[string]interface{}) error {
+	for _, key := range sortedKeys(element) {
+		if key == "@context" {
+			continue
+		}
+		value := element[key]
+
+		property, err := active.expandIRI(key, false, true, nil, nil)
+		if err != nil {
+			return err
+		}
+		if property == "" || (!isKeyword(property) && !isAbsoluteIRI(property)) {
+			// 不映射为 IRI 的属性在展开时会被丢弃，签名时视为错误
+			return fmt.Errorf("property %q is not defined by the context", key)
+		}
+
+		if isKeyword(property) {
+			if _, exists := result[property]; exists && property != "@type" {
+				return fmt.Errorf("colliding keywords: %s", property)
+			}
+			expanded, err := p.expandKeyword(active, typeScoped, activeProperty, property, value)
+			if err != nil {
+				return err
+			}
+			if expanded == nil && property != "@value" {
+				continue
+			}
+			if property == "@type" {
+				if existing, exists := result["@type"]; exists {
+					expanded = append(asArray(existing), asArray(expanded)...)
+				}
+			}
+			result[property] = expanded
+			continue
+		}
+
+		definition := active.terms[key]
+		if definition.hasContainer("@language") || definition.hasContainer("@index") ||
+			definition.hasContainer("@id") || definition.hasContainer("@type") {
+			if _, isMap := value.(map[string]interface{}); isMap {
+				return fmt.Errorf("property %q: map containers are not supported", key)
+			}
+		}
+
+		expanded, err := p.expand(active, key, value)
+		if err != nil {
+			return err
+		}
+		if expanded == nil {
+			continue
+		}
+
+		if definition.hasContainer("@list") {
+			if object, isMap := expanded.(map[string]interface{}); !isMap || object["@list"] == nil {
+				expanded = map[string]interface{}{"@list": asArray(expanded)}
+			}
+		}
+		if definition.hasContainer("@graph") {
+			items := asArray(expanded)
+			graphs := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				graphs = append(graphs, map[string]interface{}{"@graph": asArray(item)})
+			}
+			expanded = graphs
+		}
+
+		existing, _ := result[property].([]interface{})
+		result[property] = append(existing, asArray(expanded)...)
+	}
+	return nil
+}
+
+// expandKeyword 展开关键字属性的值
+func (p *processor) expandKeyword(active, typeScoped *activeContext, activeProperty, keyword string, value interface{}) (interface{}, error) {
+	switch keyword {
+	case "@id":
+		id, ok := value.(string)
+		if !ok {
+			return nil, errors.New("@id value must be a string")
+		}
+		return active.expandIRI(id, true, false, nil, nil)
+	case "@type":
+		var types []interface{}
+		for _, entry := range asArray(value) {
+			typ, ok := entry.(string)
+			if !ok {
+				return nil, errors.New("@type value must be a string or an array of strings")
+			}
+			expanded, err := typeScoped.expandIRI(typ, true, true, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			types = append(types, expanded)
+		}
+		if _, isArray := value.([]interface{}); !isArray && len(types) == 1 {
+			return types[0], nil
+		}
+		return types, nil
+	case "@graph":
+		expanded, err := p.expand(active, "@graph", value)
+		if err != nil || expanded == nil {
+			return expanded, err
+		}
+		return asArray(expanded), nil
+	case "@value":
+		switch value.(type) {
+		case nil, string, bool, json.Number:
+			return value, nil
+		default:
+			return nil, errors.New("@value must be a scalar")
+		}
+	case "@language", "@index":
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s value must be a string", keyword)
+		}
+		return text, nil
+	case "@list":
+		if activeProperty == "" || activeProperty == "@graph" {
+			return nil, nil
+		}
+		expanded, err := p.expand(active, activeProperty, value)
+		if err != nil {
+			return nil, err
+		}
+		if expanded == nil {
+			return []interface{}{}, nil
+		}
+		return asArray(expanded), nil
+	case "@set":
+		return p.expand(active, activeProperty, value)
+	default:
+		return nil, fmt.Errorf("keyword %s is not supported", keyword)
+	}
+}
+
+// expandValue 展开标量值，按术语定义的类型强制生成节点引用或带类型的值对象
+func expandValue(active *activeContext, activeProperty string, value interface{}) (interface{}, error) {
+	definition := active.terms[activeProperty]
+	if text, ok := value.(string); ok && definition != nil {
+		switch definition.typ {
+		case "@id":
+			id, err := active.expandIRI(text, true, false, nil, nil)
+			return map[string]interface{}{"@id": id}, err
+		case "@vocab":
+			id, err := active.expandIRI(text, true, true, nil, nil)
+			return map[string]interface{}{"@id": id}, err
+		}
+	}
+
+	result := map[string]interface{}{"@value": value}
+	if definition != nil && definition.typ != "" && definition.typ != "@id" && definition.typ != "@vocab" && definition.typ != "@none" {
+		result["@type"] = definition.typ
+	} else if _, ok := value.(string); ok {
+		language := active.language
+		if definition != nil && definition.language != nil {
+			language = *definition.language
+		}
+		if language != "" {
+			result["@language"] = language
+		}
+	}
+	return result, nil
+}
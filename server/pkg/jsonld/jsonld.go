@@ -0,0 +1,129 @@
+// Package jsonld 实现签名所需的 JSON-LD 1.1 子集：展开、转换为 RDF 数据集和 URDNA2015 规范化。
+// 上下文只从内置文档加载，不访问网络
+package jsonld
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Canonize 将 JSON-LD 文档展开为 RDF 数据集并按 URDNA2015 规范化，返回排序后的 N-Quads。
+// 文档中不能映射为 IRI 的属性和值视为错误，以免未签名的字段被静默丢弃
+func Canonize(document interface{}) (string, error) {
+	canonical, _, err := CanonizeLabels(document)
+	return canonical, err
+}
+
+// CanonizeLabels 与 Canonize 相同，同时返回文档中的空白节点标识（如 _:n0）到其规范标识（如 _:c14n0）的映射，
+// 供选择性披露时在派生文档中指明空白节点
+func CanonizeLabels(document interface{}) (string, map[string]string, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return "", nil, fmt.Errorf("encode document: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var input interface{}
+	if err := decoder.Decode(&input); err != nil {
+		return "", nil, fmt.Errorf("decode document: %w", err)
+	}
+
+	expanded, err := Expand(input)
+	if err != nil {
+		return "", nil, err
+	}
+	dataset, generated, err := toRDF(expanded)
+	if err != nil {
+		return "", nil, err
+	}
+	canonical, issued := canonizeDataset(dataset)
+
+	labels := make(map[string]string, len(generated))
+	for label, blank := range generated {
+		labels[label] = issued[blank]
+	}
+	return canonical, labels, nil
+}
+
+// Expand 按 JSON-LD 展开算法展开文档，数字应以 json.Number 表示
+func Expand(input interface{}) ([]interface{}, error) {
+	p := &processor{}
+	expanded, err := p.expand(newActiveContext(), "", input)
+	if err != nil {
+		return nil, err
+	}
+
+	// 顶层只有 @graph 的对象展开为其内容
+	if object, ok := expanded.(map[string]interface{}); ok && len(object) == 1 && object["@graph"] != nil {
+		expanded = object["@graph"]
+	}
+	if expanded == nil {
+		return []interface{}{}, nil
+	}
+	return asArray(expanded), nil
+}
+
+// keywords JSON-LD 1.1 关键字
+var keywords = map[string]bool{
+	"@base": true, "@container": true, "@context": true, "@direction": true, "@graph": true,
+	"@id": true, "@import": true, "@included": true, "@index": true, "@json": true,
+	"@language": true, "@list": true, "@nest": true, "@none": true, "@prefix": true,
+	"@propagate": true, "@protected": true, "@reverse": true, "@set": true, "@type": true,
+	"@value": true, "@version": true, "@vocab": true,
+}
+
+func isKeyword(value string) bool {
+	return keywords[value]
+}
+
+// looksLikeKeyword 形如 @字母 的值是保留给未来关键字的，处理时忽略
+func looksLikeKeyword(value string) bool {
+	if len(value) < 2 || value[0] != '@' {
+		return false
+	}
+	for _, r := range value[1:] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isAbsoluteIRI 是否带有 scheme，空白节点标识也按绝对 IRI 处理
+func isAbsoluteIRI(value string) bool {
+	if strings.HasPrefix(value, "_:") {
+		return true
+	}
+	colon := strings.IndexByte(value, ':')
+	if colon < 1 {
+		return false
+	}
+	for i, r := range value[:colon] {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func asArray(value interface{}) []interface{} {
+	if array, ok := value.([]interface{}); ok {
+		return array
+	}
+	return []interface{}{value}
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
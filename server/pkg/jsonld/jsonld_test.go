@@ -0,0 +1,153 @@
+package jsonld
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonizeCredential(t *testing.T) {
+	document := map[string]interface{}{
+		"@context":     []interface{}{CredentialsV1, GameCredentialsV1},
+		"id":           "urn:uuid:1",
+		"type":         []interface{}{"VerifiableCredential", "LevelCredential"},
+		"issuer":       "did:example:issuer",
+		"issuanceDate": "2024-01-01T00:00:00Z",
+		"credentialSubject": map[string]interface{}{
+			"id":    "did:example:holder",
+			"level": 5,
+			"ratio": 0.5,
+		},
+	}
+
+	canonical, err := Canonize(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<did:example:holder> <https://game.example.com/vocab#level> "5"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<did:example:holder> <https://game.example.com/vocab#ratio> "5.0E-1"^^<http://www.w3.org/2001/XMLSchema#double> .
+<urn:uuid:1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://game.example.com/vocab#LevelCredential> .
+<urn:uuid:1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/2018/credentials#VerifiableCredential> .
+<urn:uuid:1> <https://www.w3.org/2018/credentials#credentialSubject> <did:example:holder> .
+<urn:uuid:1> <https://www.w3.org/2018/credentials#issuanceDate> "2024-01-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+<urn:uuid:1> <https://www.w3.org/2018/credentials#issuer> <did:example:issuer> .
+`
+	if canonical != want {
+		t.Errorf("Canonize =\n%s\nwant\n%s", canonical, want)
+	}
+}
+
+func TestCanonizeProofOptions(t *testing.T) {
+	document := map[string]interface{}{
+		"@context":           []interface{}{CredentialsV1, Ed25519Signature2020V1},
+		"type":               "Ed25519Signature2020",
+		"created":            "2024-01-01T00:00:00Z",
+		"verificationMethod": "did:example:issuer#key-1",
+		"proofPurpose":       "assertionMethod",
+	}
+
+	canonical, err := Canonize(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `_:c14n0 <http://purl.org/dc/terms/created> "2024-01-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+_:c14n0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://w3id.org/security#Ed25519Signature2020> .
+_:c14n0 <https://w3id.org/security#proofPurpose> <https://w3id.org/security#assertionMethod> .
+_:c14n0 <https://w3id.org/security#verificationMethod> <did:example:issuer#key-1> .
+`
+	if canonical != want {
+		t.Errorf("Canonize =\n%s\nwant\n%s", canonical, want)
+	}
+}
+
+func TestCanonizeRejectsUnmappedDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		document map[string]interface{}
+		want     string
+	}{
+		{
+			name: "undefined property",
+			document: map[string]interface{}{
+				"@context":          CredentialsV1,
+				"type":              "VerifiableCredential",
+				"credentialSubject": map[string]interface{}{"id": "did:example:holder", "level": 5},
+			},
+			want: `property "level" is not defined`,
+		},
+		{
+			name: "unknown context",
+			document: map[string]interface{}{
+				"@context": []interface{}{CredentialsV1, "https://example.com/unknown/v1"},
+				"type":     "VerifiableCredential",
+			},
+			want: "not available offline",
+		},
+		{
+			name: "protected term",
+			document: map[string]interface{}{
+				"@context": []interface{}{CredentialsV1, map[string]interface{}{"VerifiableCredential": "https://example.com/Credential"}},
+				"type":     "VerifiableCredential",
+			},
+			want: "protected",
+		},
+	}
+	for _, tt := range tests {
+		if _, err := Canonize(tt.document); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Canonize error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCanonizeDatasetIgnoresBlankNodeLabelsAndOrder(t *testing.T) {
+	iri := func(value string) term { return term{kind: kindIRI, value: value} }
+	blank := func(value string) term { return term{kind: kindBlank, value: value} }
+	knows := iri("http://example.com/knows")
+	name := iri("http://example.com/name")
+	literal := func(value string) term { return term{kind: kindLiteral, value: value, datatype: xsdString} }
+
+	// 两个结构相同的空白节点互相引用，一阶哈希相同，需要 N 阶哈希区分
+	dataset := func(a, b, c string) []quad {
+		return []quad{
+			{subject: blank(a), predicate: knows, object: blank(b)},
+			{subject: blank(b), predicate: knows, object: blank(a)},
+			{subject: blank(a), predicate: knows, object: blank(c)},
+			{subject: blank(c), predicate: name, object: literal("carol")},
+		}
+	}
+
+	first, _ := canonizeDataset(dataset("_:x", "_:y", "_:z"))
+	relabeled := dataset("_:z", "_:x", "_:y")
+	relabeled[0], relabeled[3] = relabeled[3], relabeled[0]
+	second, _ := canonizeDataset(relabeled)
+	if first != second {
+		t.Errorf("canonical form depends on labels or order:\n%s\n%s", first, second)
+	}
+	if strings.Contains(first, "_:x") || strings.Count(first, "_:c14n") != 7 {
+		t.Errorf("blank nodes not relabeled:\n%s", first)
+	}
+}
+
+func TestCanonizeLabelsMapsDocumentBlankNodes(t *testing.T) {
+	document := map[string]interface{}{
+		"@context": []interface{}{CredentialsV1, GameCredentialsV1},
+		"id":       "did:example:holder",
+		"attributes": map[string]interface{}{
+			"id":    "_:n0",
+			"class": "mage",
+		},
+	}
+
+	canonical, labels, err := CanonizeLabels(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels["_:n0"] != "_:c14n0" {
+		t.Fatalf("labels = %v, want _:n0 mapped to _:c14n0", labels)
+	}
+	want := `<did:example:holder> <https://game.example.com/vocab#attributes> _:c14n0 .
+_:c14n0 <https://game.example.com/vocab#class> "mage" .
+`
+	if canonical != want {
+		t.Errorf("CanonizeLabels =\n%s\nwant\n%s", canonical, want)
+	}
+}
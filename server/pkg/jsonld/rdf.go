@@ -0,0 +1,330 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RDF 词汇
+const (
+	rdfType       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	rdfFirst      = "http://www.w3.org/1999/02/22-rdf-syntax-ns#first"
+	rdfRest       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#rest"
+	rdfNil        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#nil"
+	rdfLangString = "http://www.w3.org/1999/02/22-rdf-syntax-ns#langString"
+	xsdBoolean    = "http://www.w3.org/2001/XMLSchema#boolean"
+	xsdDouble     = "http://www.w3.org/2001/XMLSchema#double"
+	xsdInteger    = "http://www.w3.org/2001/XMLSchema#integer"
+	xsdString     = "http://www.w3.org/2001/XMLSchema#string"
+)
+
+// termKind RDF 项的种类
+type termKind int
+
+const (
+	kindIRI termKind = iota
+	kindBlank
+	kindLiteral
+)
+
+// term RDF 项。空白节点的 value 带 "_:" 前缀；字面量的 datatype 不为空，语言标签只用于 rdf:langString
+type term struct {
+	kind     termKind
+	value    string
+	datatype string
+	language string
+}
+
+// quad RDF 四元组，graph 为空表示默认图
+type quad struct {
+	subject   term
+	predicate term
+	object    term
+	graph     *term
+}
+
+// rdfBuilder 将展开后的文档转换为 RDF 数据集
+type rdfBuilder struct {
+	quads  []quad
+	seen   map[string]bool
+	labels map[string]term // 文档中的空白节点标识 -> 生成的空白节点
+	blanks int
+}
+
+// toRDF 按 JSON-LD 反序列化算法将展开后的文档转换为四元组，重复的四元组只保留一个。
+// 同时返回文档中的空白节点标识到生成的空白节点标识的映射
+func toRDF(expanded []interface{}) ([]quad, map[string]string, error) {
+	b := &rdfBuilder{seen: make(map[string]bool), labels: make(map[string]term)}
+	for _, element := range expanded {
+		object, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, err := b.node(object, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	labels := make(map[string]string, len(b.labels))
+	for label, blank := range b.labels {
+		labels[label] = blank.value
+	}
+	return b.quads, labels, nil
+}
+
+func (b *rdfBuilder) newBlank() term {
+	b.blanks++
+	return term{kind: kindBlank, value: fmt.Sprintf("_:b%d", b.blanks-1)}
+}
+
+func (b *rdfBuilder) emit(q quad) {
+	key := serializeQuad(q)
+	if b.seen[key] {
+		return
+	}
+	b.seen[key] = true
+	b.quads = append(b.quads, q)
+}
+
+// resource 将 IRI 或空白节点标识转换为 RDF 项，相对 IRI 不能签名
+func (b *rdfBuilder) resource(value string) (term, error) {
+	if strings.HasPrefix(value, "_:") {
+		blank, exists := b.labels[value]
+		if !exists {
+			blank = b.newBlank()
+			b.labels[value] = blank
+		}
+		return blank, nil
+	}
+	if !isAbsoluteIRI(value) {
+		return term{}, fmt.Errorf("relative IRI %q cannot be converted to RDF", value)
+	}
+	return term{kind: kindIRI, value: value}, nil
+}
+
+// node 输出节点对象的四元组并返回其主语。带 @graph 的节点对象以主语作为命名图输出其内容
+func (b *rdfBuilder) node(object map[string]interface{}, graph *term) (term, error) {
+	var subject term
+	if id, ok := object["@id"].(string); ok {
+		var err error
+		if subject, err = b.resource(id); err != nil {
+			return term{}, err
+		}
+	} else {
+		subject = b.newBlank()
+	}
+
+	for _, key := range sortedKeys(object) {
+		values := object[key]
+		switch key {
+		case "@id", "@index":
+			continue
+		case "@type":
+			for _, entry := range asArray(values) {
+				typ, _ := entry.(string)
+				object, err := b.resource(typ)
+				if err != nil {
+					return term{}, err
+				}
+				b.emit(quad{subject: subject, predicate: term{kind: kindIRI, value: rdfType}, object: object, graph: graph})
+			}
+		case "@graph":
+			named := subject
+			for _, entry := range asArray(values) {
+				if nested, ok := entry.(map[string]interface{}); ok {
+					if _, err := b.node(nested, &named); err != nil {
+						return term{}, err
+					}
+				}
+			}
+		default:
+			if isKeyword(key) {
+				return term{}, fmt.Errorf("keyword %s cannot be converted to RDF", key)
+			}
+			predicate, err := b.resource(key)
+			if err != nil {
+				return term{}, err
+			}
+			if predicate.kind == kindBlank {
+				return term{}, fmt.Errorf("blank node predicate %s cannot be converted to RDF", key)
+			}
+			for _, entry := range asArray(values) {
+				object, err := b.object(entry, graph)
+				if err != nil {
+					return term{}, err
+				}
+				if object != nil {
+					b.emit(quad{subject: subject, predicate: predicate, object: *object, graph: graph})
+				}
+			}
+		}
+	}
+	return subject, nil
+}
+
+// object 将属性值转换为宾语，嵌套节点、列表和图对象的四元组同时输出
+func (b *rdfBuilder) object(value interface{}, graph *term) (*term, error) {
+	item, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected expanded value %v", value)
+	}
+
+	if literal, exists := item["@value"]; exists {
+		object, err := literalTerm(literal, item)
+		if err != nil {
+			return nil, err
+		}
+		return &object, nil
+	}
+
+	if list, exists := item["@list"]; exists {
+		return b.list(asArray(list), graph)
+	}
+
+	if contents, exists := item["@graph"]; exists && len(item) == 1 {
+		// 图对象：内容放入以新空白节点命名的图，属性值为该空白节点
+		named := b.newBlank()
+		for _, entry := range asArray(contents) {
+			if nested, ok := entry.(map[string]interface{}); ok {
+				if _, err := b.node(nested, &named); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return &named, nil
+	}
+
+	subject, err := b.node(item, graph)
+	if err != nil {
+		return nil, err
+	}
+	return &subject, nil
+}
+
+// list 以 rdf:first/rdf:rest 输出列表，返回列表头
+func (b *rdfBuilder) list(items []interface{}, graph *term) (*term, error) {
+	if len(items) == 0 {
+		return &term{kind: kindIRI, value: rdfNil}, nil
+	}
+
+	head := b.newBlank()
+	current := head
+	for i, entry := range items {
+		object, err := b.object(entry, graph)
+		if err != nil {
+			return nil, err
+		}
+		if object != nil {
+			b.emit(quad{subject: current, predicate: term{kind: kindIRI, value: rdfFirst}, object: *object, graph: graph})
+		}
+		rest := term{kind: kindIRI, value: rdfNil}
+		if i < len(items)-1 {
+			rest = b.newBlank()
+		}
+		b.emit(quad{subject: current, predicate: term{kind: kindIRI, value: rdfRest}, object: rest, graph: graph})
+		current = rest
+	}
+	return &head, nil
+}
+
+// literalTerm 将值对象转换为字面量，数字和布尔值按 JSON-LD 的规范形式输出
+func literalTerm(value interface{}, item map[string]interface{}) (term, error) {
+	datatype, _ := item["@type"].(string)
+
+	switch v := value.(type) {
+	case bool:
+		if datatype == "" {
+			datatype = xsdBoolean
+		}
+		return term{kind: kindLiteral, value: strconv.FormatBool(v), datatype: datatype}, nil
+	case json.Number:
+		number, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return term{}, fmt.Errorf("invalid number %s: %w", v, err)
+		}
+		if number == math.Trunc(number) && math.Abs(number) < 1e21 && datatype != xsdDouble {
+			if datatype == "" {
+				datatype = xsdInteger
+			}
+			return term{kind: kindLiteral, value: strconv.FormatFloat(number, 'f', -1, 64), datatype: datatype}, nil
+		}
+		if datatype == "" {
+			datatype = xsdDouble
+		}
+		return term{kind: kindLiteral, value: canonicalDouble(number), datatype: datatype}, nil
+	case string:
+		if language, ok := item["@language"].(string); ok {
+			return term{kind: kindLiteral, value: v, datatype: rdfLangString, language: language}, nil
+		}
+		if datatype == "" {
+			datatype = xsdString
+		}
+		return term{kind: kindLiteral, value: v, datatype: datatype}, nil
+	default:
+		return term{}, fmt.Errorf("unsupported literal %v", value)
+	}
+}
+
+// canonicalDouble 以 xsd:double 规范形式输出浮点数，如 1.5E0、1.0E21
+func canonicalDouble(number float64) string {
+	formatted := strconv.FormatFloat(number, 'E', 15, 64)
+	mantissa, exponent, _ := strings.Cut(formatted, "E")
+	mantissa = strings.TrimRight(mantissa, "0")
+	if strings.HasSuffix(mantissa, ".") {
+		mantissa += "0"
+	}
+	exp, _ := strconv.Atoi(exponent)
+	return mantissa + "E" + strconv.Itoa(exp)
+}
+
+// serializeQuad 按 N-Quads 规范输出一行，含结尾换行
+func serializeQuad(q quad) string {
+	var builder strings.Builder
+	writeTerm(&builder, q.subject)
+	builder.WriteByte(' ')
+	writeTerm(&builder, q.predicate)
+	builder.WriteByte(' ')
+	writeTerm(&builder, q.object)
+	if q.graph != nil {
+		builder.WriteByte(' ')
+		writeTerm(&builder, *q.graph)
+	}
+	builder.WriteString(" .\n")
+	return builder.String()
+}
+
+func writeTerm(builder *strings.Builder, t term) {
+	switch t.kind {
+	case kindIRI:
+		builder.WriteString("<" + t.value + ">")
+	case kindBlank:
+		builder.WriteString(t.value)
+	case kindLiteral:
+		builder.WriteByte('"')
+		for _, r := range t.value {
+			switch r {
+			case '"':
+				builder.WriteString(`\"`)
+			case '\\':
+				builder.WriteString(`\\`)
+			case '\n':
+				builder.WriteString(`\n`)
+			case '\r':
+				builder.WriteString(`\r`)
+			default:
+				builder.WriteRune(r)
+			}
+		}
+		builder.WriteByte('"')
+		switch t.datatype {
+		case rdfLangString:
+			builder.WriteString("@" + t.language)
+		case xsdString:
+		default:
+			builder.WriteString("^^<" + t.datatype + ">")
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package jsonld
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// identifierIssuer 按顺序为空白节点分配带前缀的新标识
+type identifierIssuer struct {
+	prefix  string
+	counter int
+	issued  map[string]string
+	order   []string
+}
+
+func newIdentifierIssuer(prefix string) *identifierIssuer {
+	return &identifierIssuer{prefix: prefix, issued: make(map[string]string)}
+}
+
+func (i *identifierIssuer) issue(existing string) string {
+	if id, exists := i.issued[existing]; exists {
+		return id
+	}
+	id := i.prefix + strconv.Itoa(i.counter)
+	i.counter++
+	i.issued[existing] = id
+	i.order = append(i.order, existing)
+	return id
+}
+
+func (i *identifierIssuer) has(existing string) bool {
+	_, exists := i.issued[existing]
+	return exists
+}
+
+func (i *identifierIssuer) clone() *identifierIssuer {
+	clone := &identifierIssuer{
+		prefix:  i.prefix,
+		counter: i.counter,
+		issued:  make(map[string]string, len(i.issued)),
+		order:   append([]string(nil), i.order...),
+	}
+	for existing, id := range i.issued {
+		clone.issued[existing] = id
+	}
+	return clone
+}
+
+// canonicalizer URDNA2015 规范化状态
+type canonicalizer struct {
+	blankQuads map[string][]quad // 空白节点 -> 含有它的四元组
+	canonical  *identifierIssuer
+	firstHash  map[string]string
+}
+
+// canonizeDataset 按 URDNA2015 为空白节点分配规范标识，返回按码点排序的 N-Quads 和空白节点到规范标识的映射
+func canonizeDataset(quads []quad) (string, map[string]string) {
+	c := &canonicalizer{
+		blankQuads: make(map[string][]quad),
+		canonical:  newIdentifierIssuer("_:c14n"),
+		firstHash:  make(map[string]string),
+	}
+
+	for _, q := range quads {
+		for _, t := range quadBlankNodes(q) {
+			c.blankQuads[t] = append(c.blankQuads[t], q)
+		}
+	}
+
+	// 一阶哈希唯一的空白节点直接分配规范标识
+	hashToBlanks := make(map[string][]string)
+	for _, blank := range sortedBlankNodes(c.blankQuads) {
+		hash := c.hashFirstDegree(blank)
+		hashToBlanks[hash] = append(hashToBlanks[hash], blank)
+	}
+	hashes := make([]string, 0, len(hashToBlanks))
+	for hash := range hashToBlanks {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	var shared []string
+	for _, hash := range hashes {
+		if len(hashToBlanks[hash]) > 1 {
+			shared = append(shared, hash)
+			continue
+		}
+		c.canonical.issue(hashToBlanks[hash][0])
+	}
+
+	// 一阶哈希相同的空白节点按 N 阶哈希区分
+	for _, hash := range shared {
+		type pathResult struct {
+			hash   string
+			issuer *identifierIssuer
+		}
+		var results []pathResult
+		for _, blank := range hashToBlanks[hash] {
+			if c.canonical.has(blank) {
+				continue
+			}
+			issuer := newIdentifierIssuer("_:b")
+			issuer.issue(blank)
+			nHash, nIssuer := c.hashNDegree(blank, issuer)
+			results = append(results, pathResult{hash: nHash, issuer: nIssuer})
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].hash < results[j].hash })
+		for _, result := range results {
+			for _, existing := range result.issuer.order {
+				c.canonical.issue(existing)
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(quads))
+	for _, q := range quads {
+		lines = append(lines, serializeQuad(relabel(q, func(blank string) string {
+			return c.canonical.issue(blank)
+		})))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ""), c.canonical.issued
+}
+
+// hashFirstDegree 一阶哈希：含有该空白节点的四元组中，该节点记为 _:a，其他空白节点记为 _:z
+func (c *canonicalizer) hashFirstDegree(blank string) string {
+	if hash, exists := c.firstHash[blank]; exists {
+		return hash
+	}
+	lines := make([]string, 0, len(c.blankQuads[blank]))
+	for _, q := range c.blankQuads[blank] {
+		lines = append(lines, serializeQuad(relabel(q, func(other string) string {
+			if other == blank {
+				return "_:a"
+			}
+			return "_:z"
+		})))
+	}
+	sort.Strings(lines)
+	hash := sha256Hex(strings.Join(lines, ""))
+	c.firstHash[blank] = hash
+	return hash
+}
+
+// hashRelated 相关空白节点的哈希，position 为 s、o 或 g
+func (c *canonicalizer) hashRelated(related string, q quad, issuer *identifierIssuer, position string) string {
+	var id string
+	switch {
+	case c.canonical.has(related):
+		id = c.canonical.issued[related]
+	case issuer.has(related):
+		id = issuer.issued[related]
+	default:
+		id = c.hashFirstDegree(related)
+	}
+	input := position
+	if position != "g" {
+		input += "<" + q.predicate.value + ">"
+	}
+	return sha256Hex(input + id)
+}
+
+// hashNDegree N 阶哈希，返回哈希和分配了临时标识的 issuer
+func (c *canonicalizer) hashNDegree(blank string, issuer *identifierIssuer) (string, *identifierIssuer) {
+	hashToRelated := make(map[string][]string)
+	for _, q := range c.blankQuads[blank] {
+		related := []struct {
+			term     term
+			position string
+		}{{q.subject, "s"}, {q.object, "o"}}
+		if q.graph != nil {
+			related = append(related, struct {
+				term     term
+				position string
+			}{*q.graph, "g"})
+		}
+		for _, r := range related {
+			if r.term.kind != kindBlank || r.term.value == blank {
+				continue
+			}
+			hash := c.hashRelated(r.term.value, q, issuer, r.position)
+			hashToRelated[hash] = append(hashToRelated[hash], r.term.value)
+		}
+	}
+
+	relatedHashes := make([]string, 0, len(hashToRelated))
+	for hash := range hashToRelated {
+		relatedHashes = append(relatedHashes, hash)
+	}
+	sort.Strings(relatedHashes)
+
+	var data strings.Builder
+	for _, relatedHash := range relatedHashes {
+		data.WriteString(relatedHash)
+
+		chosenPath := ""
+		var chosenIssuer *identifierIssuer
+		permute(hashToRelated[relatedHash], func(permutation []string) {
+			issuerCopy := issuer.clone()
+			path := ""
+			var recursion []string
+			longer := func() bool {
+				return chosenPath != "" && len(path) >= len(chosenPath) && path > chosenPath
+			}
+
+			for _, related := range permutation {
+				if c.canonical.has(related) {
+					path += c.canonical.issued[related]
+				} else {
+					if !issuerCopy.has(related) {
+						recursion = append(recursion, related)
+					}
+					path += issuerCopy.issue(related)
+				}
+				if longer() {
+					return
+				}
+			}
+
+			for _, related := range recursion {
+				hash, resultIssuer := c.hashNDegree(related, issuerCopy)
+				path += issuerCopy.issue(related)
+				path += "<" + hash + ">"
+				issuerCopy = resultIssuer
+				if longer() {
+					return
+				}
+			}
+
+			if chosenPath == "" || path < chosenPath {
+				chosenPath = path
+				chosenIssuer = issuerCopy
+			}
+		})
+
+		data.WriteString(chosenPath)
+		issuer = chosenIssuer
+	}
+	return sha256Hex(data.String()), issuer
+}
+
+// permute 依次以 items 的每个排列调用 visit
+func permute(items []string, visit func([]string)) {
+	items = append([]string(nil), items...)
+	sort.Strings(items)
+	var generate func(k int)
+	generate = func(k int) {
+		if k == len(items) {
+			visit(append([]string(nil), items...))
+			return
+		}
+		for i := k; i < len(items); i++ {
+			items[k], items[i] = items[i], items[k]
+			generate(k + 1)
+			items[k], items[i] = items[i], items[k]
+		}
+	}
+	generate(0)
+}
+
+// quadBlankNodes 返回四元组中的空白节点，不重复
+func quadBlankNodes(q quad) []string {
+	var blanks []string
+	add := func(t term) {
+		if t.kind != kindBlank {
+			return
+		}
+		for _, existing := range blanks {
+			if existing == t.value {
+				return
+			}
+		}
+		blanks = append(blanks, t.value)
+	}
+	add(q.subject)
+	add(q.object)
+	if q.graph != nil {
+		add(*q.graph)
+	}
+	return blanks
+}
+
+func sortedBlankNodes(blankQuads map[string][]quad) []string {
+	blanks := make([]string, 0, len(blankQuads))
+	for blank := range blankQuads {
+		blanks = append(blanks, blank)
+	}
+	sort.Strings(blanks)
+	return blanks
+}
+
+// relabel 按 label 替换四元组中的空白节点
+func relabel(q quad, label func(string) string) quad {
+	replace := func(t term) term {
+		if t.kind == kindBlank {
+			t.value = label(t.value)
+		}
+		return t
+	}
+	q.subject = replace(q.subject)
+	q.object = replace(q.object)
+	if q.graph != nil {
+		graph := replace(*q.graph)
+		q.graph = &graph
+	}
+	return q
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package multibase
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Base58BTC base58btc 编码前缀
+const Base58BTC = 'z'
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() [256]int {
+	var index [256]int
+	for i := range index {
+		index[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		index[base58Alphabet[i]] = i
+	}
+	return index
+}()
+
+// EncodeBase58BTC 以 multibase base58btc 格式编码（带 'z' 前缀）
+func EncodeBase58BTC(data []byte) string {
+	return string(Base58BTC) + EncodeBase58(data)
+}
+
+// Decode 解码 multibase 字符串，目前仅支持 base58btc
+func Decode(value string) ([]byte, error) {
+	if value == "" {
+		return nil, errors.New("empty multibase value")
+	}
+
+	switch value[0] {
+	case Base58BTC:
+		return DecodeBase58(value[1:])
+	default:
+		return nil, fmt.Errorf("unsupported multibase prefix: %q", value[0])
+	}
+}
+
+// EncodeBase58 base58（比特币字母表）编码
+func EncodeBase58(data []byte) string {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+
+	num := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	encoded := make([]byte, 0, len(data)*138/100+1)
+	for num.Sign() > 0 {
+		num.DivMod(num, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < zeros; i++ {
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	// 反转为大端序
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+// DecodeBase58 base58（比特币字母表）解码
+func DecodeBase58(value string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(value) && value[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	num := new(big.Int)
+	radix := big.NewInt(58)
+	for i := 0; i < len(value); i++ {
+		digit := base58Index[value[i]]
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character: %q", value[i])
+		}
+		num.Mul(num, radix)
+		num.Add(num, big.NewInt(int64(digit)))
+	}
+
+	decoded := num.Bytes()
+	result := make([]byte, zeros+len(decoded))
+	copy(result[zeros:], decoded)
+
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package multibase
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBase58Vectors(t *testing.T) {
+	tests := []struct {
+		data    []byte
+		encoded string
+	}{
+		{[]byte("Hello World!"), "2NEpo7TZRRrLZSi2U"},
+		{[]byte{0x00, 0x00, 0x28, 0x7f, 0xb4, 0xcd}, "11233QC4"},
+		{[]byte{0x00}, "1"},
+		{[]byte{}, ""},
+	}
+	for _, tt := range tests {
+		if encoded := EncodeBase58(tt.data); encoded != tt.encoded {
+			t.Errorf("EncodeBase58(%x) = %q, want %q", tt.data, encoded, tt.encoded)
+		}
+		decoded, err := DecodeBase58(tt.encoded)
+		if err != nil {
+			t.Fatalf("DecodeBase58(%q): %v", tt.encoded, err)
+		}
+		if !bytes.Equal(decoded, tt.data) {
+			t.Errorf("DecodeBase58(%q) = %x, want %x", tt.encoded, decoded, tt.data)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := []byte{0xed, 0x01, 0x02, 0x03}
+	decoded, err := Decode(EncodeBase58BTC(data))
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("Decode round trip = %x, %v", decoded, err)
+	}
+
+	for _, value := range []string{"", "m4pIB", "z0OIl"} {
+		if _, err := Decode(value); err == nil {
+			t.Errorf("Decode(%q) succeeded", value)
+		}
+	}
+}
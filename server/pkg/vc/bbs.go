@@ -0,0 +1,441 @@
+package vc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/bbs"
+	"github.com/czh0526/game/server/pkg/jsonld"
+)
+
+// BBS+ 证明类型（bbs-bls-signature-2020），凭证加入 https://w3id.org/security/bbs/v1 上下文
+const (
+	// ProofTypeBbsBlsSignature2020 颁发者对凭证的 BBS+ 签名，持有者由它派生选择性披露证明
+	ProofTypeBbsBlsSignature2020 = "BbsBlsSignature2020"
+	// ProofTypeBbsBlsSignatureProof2020 持有者派生的选择性披露证明，凭证主体只含披露的字段
+	ProofTypeBbsBlsSignatureProof2020 = "BbsBlsSignatureProof2020"
+)
+
+// blankNodeIRI 派生凭证以 urn:bnid:_:c14nN 形式的 IRI 指明原凭证中的空白节点，验证时还原为空白节点
+var blankNodeIRI = regexp.MustCompile(`<urn:bnid:(_:c14n[0-9]+)>`)
+
+// SignBBSCredential 使用颁发者的 BBS+ 私钥为凭证签名，证明类型为 BbsBlsSignature2020
+//
+// 签名的消息为 URDNA2015 规范化后的每条 N-Quads 语句：先是证明选项（带凭证的 @context，不含 proofValue），
+// 再是不含证明的凭证。每条语句是选择性披露的单位，DeriveBBSCredential 只允许隐藏凭证主体的字段。
+// BBS+ 不提供范围证明，要证明等级不低于某个值需由颁发者签发 levelAtLeast 门槛，持有者只披露其中一个
+func SignBBSCredential(credential *SimpleCredential, privateKey *bbs.PrivateKey, verificationMethod string) error {
+	if credential == nil {
+		return errors.New("credential is nil")
+	}
+	if privateKey == nil {
+		return errors.New("signing key is nil")
+	}
+
+	credential.Proof = nil
+	credential.Context = withSuiteContext(credential.Context, ProofTypeBbsBlsSignature2020)
+
+	proof := &Proof{
+		Type:               ProofTypeBbsBlsSignature2020,
+		Created:            time.Now().UTC().Truncate(time.Second),
+		VerificationMethod: verificationMethod,
+		ProofPurpose:       ProofPurposeAssertionMethod,
+	}
+	proofStatements, err := bbsProofStatements(credential.Context, proof)
+	if err != nil {
+		return err
+	}
+	document, _, err := bbsDocument(credential)
+	if err != nil {
+		return err
+	}
+	documentStatements, _, err := canonicalStatements(document)
+	if err != nil {
+		return err
+	}
+
+	signature, err := privateKey.Sign(bbsMessages(proofStatements, documentStatements))
+	if err != nil {
+		return fmt.Errorf("sign proof: %w", err)
+	}
+
+	proof.ProofValue = base64.StdEncoding.EncodeToString(signature)
+	credential.Proof = proof
+	return nil
+}
+
+// VerifyBBSSignature 使用颁发者的 BBS+ 公钥验证 BbsBlsSignature2020 证明
+func VerifyBBSSignature(credential *SimpleCredential, publicKey *bbs.PublicKey) error {
+	proof, err := bbsProof(credential, ProofTypeBbsBlsSignature2020)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(proof.ProofValue)
+	if err != nil {
+		return fmt.Errorf("decode proof value: %w", err)
+	}
+
+	proofStatements, err := bbsProofStatements(credential.Context, proof)
+	if err != nil {
+		return err
+	}
+	document, _, err := bbsDocument(credential)
+	if err != nil {
+		return err
+	}
+	documentStatements, _, err := canonicalStatements(document)
+	if err != nil {
+		return err
+	}
+	if err := publicKey.Verify(bbsMessages(proofStatements, documentStatements), signature); err != nil {
+		return errors.New("invalid proof signature")
+	}
+	return nil
+}
+
+// DeriveBBSCredential 持有者由 BbsBlsSignature2020 凭证派生只披露部分主体字段的凭证，证明类型为
+// BbsBlsSignatureProof2020。reveal 为披露的字段名（如 level），多值字段可以写作 名称=值（如 levelAtLeast=10）
+// 只披露其中一个值；其余字段被隐藏，凭证的其他部分总是披露。每次派生的证明互不关联。
+// nonce 应为验证方提供的 base64 随机数，为空时随机生成
+func DeriveBBSCredential(credential *SimpleCredential, publicKey *bbs.PublicKey, reveal []string, nonce string) (*SimpleCredential, error) {
+	proof, err := bbsProof(credential, ProofTypeBbsBlsSignature2020)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(proof.ProofValue)
+	if err != nil {
+		return nil, fmt.Errorf("decode proof value: %w", err)
+	}
+
+	if nonce == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("generate nonce: %w", err)
+		}
+		nonce = base64.StdEncoding.EncodeToString(random)
+	}
+	nonceBytes, err := base64.StdEncoding.DecodeString(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("nonce must be base64: %w", err)
+	}
+
+	proofStatements, err := bbsProofStatements(credential.Context, proof)
+	if err != nil {
+		return nil, err
+	}
+	document, labels, err := bbsDocument(credential)
+	if err != nil {
+		return nil, err
+	}
+	documentStatements, _, err := canonicalStatements(document)
+	if err != nil {
+		return nil, err
+	}
+
+	// 派生凭证：主体只保留披露的字段，空白节点改用 urn:bnid IRI 以保持原凭证中的规范标识
+	subject, _ := document["credentialSubject"].(map[string]interface{})
+	disclosed, err := selectClaims(subject, reveal)
+	if err != nil {
+		return nil, err
+	}
+	document["credentialSubject"] = identifyBlankNodes(disclosed, labels)
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("encode derived credential: %w", err)
+	}
+	var derived SimpleCredential
+	if err := json.Unmarshal(data, &derived); err != nil {
+		return nil, fmt.Errorf("decode derived credential: %w", err)
+	}
+
+	// 派生凭证的语句须都在原凭证中，按其在原凭证中的位置披露
+	revealedStatements, err := bbsRevealedStatements(&derived)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[string]int, len(documentStatements))
+	for i, statement := range documentStatements {
+		positions[statement] = i
+	}
+	revealed := make([]int, 0, len(proofStatements)+len(revealedStatements))
+	for i := range proofStatements {
+		revealed = append(revealed, i)
+	}
+	for _, statement := range revealedStatements {
+		position, exists := positions[statement]
+		if !exists {
+			return nil, errors.New("disclosed claims do not round-trip through the credential subject")
+		}
+		revealed = append(revealed, len(proofStatements)+position)
+	}
+
+	derivedProof, err := publicKey.DeriveProof(bbsMessages(proofStatements, documentStatements), signature, revealed, nonceBytes)
+	if err != nil {
+		return nil, fmt.Errorf("derive proof: %w", err)
+	}
+
+	derived.Proof = &Proof{
+		Type:               ProofTypeBbsBlsSignatureProof2020,
+		Created:            proof.Created,
+		VerificationMethod: proof.VerificationMethod,
+		ProofPurpose:       proof.ProofPurpose,
+		Nonce:              nonce,
+		ProofValue:         base64.StdEncoding.EncodeToString(derivedProof),
+	}
+	return &derived, nil
+}
+
+// VerifyBBSDerivedProof 使用颁发者的 BBS+ 公钥验证 BbsBlsSignatureProof2020 证明，确认凭证中的每条语句都由
+// 颁发者签名。nonce 不为空时须与证明中的 nonce 相同
+//
+// 证明不说明被隐藏的是哪些语句，验证方需要有效期、撤销状态等字段时应检查它们已披露
+func VerifyBBSDerivedProof(credential *SimpleCredential, publicKey *bbs.PublicKey, nonce string) error {
+	proof, err := bbsProof(credential, ProofTypeBbsBlsSignatureProof2020)
+	if err != nil {
+		return err
+	}
+	if nonce != "" && proof.Nonce != nonce {
+		return errors.New("nonce mismatch")
+	}
+	nonceBytes, err := base64.StdEncoding.DecodeString(proof.Nonce)
+	if err != nil {
+		return fmt.Errorf("decode nonce: %w", err)
+	}
+	derivedProof, err := base64.StdEncoding.DecodeString(proof.ProofValue)
+	if err != nil {
+		return fmt.Errorf("decode proof value: %w", err)
+	}
+
+	proofStatements, err := bbsProofStatements(credential.Context, proof)
+	if err != nil {
+		return err
+	}
+	revealedStatements, err := bbsRevealedStatements(credential)
+	if err != nil {
+		return err
+	}
+	messages := bbsMessages(proofStatements, revealedStatements)
+
+	// 证明选项的语句排在最前，须全部披露
+	_, revealed, err := bbs.ProofMessages(derivedProof)
+	if err != nil || len(revealed) != len(messages) {
+		return errors.New("disclosed statements do not match the proof")
+	}
+	for i := range proofStatements {
+		if revealed[i] != i {
+			return errors.New("disclosed statements do not match the proof")
+		}
+	}
+
+	if err := publicKey.VerifyProof(messages, derivedProof, nonceBytes); err != nil {
+		return errors.New("invalid derived proof")
+	}
+	return nil
+}
+
+// bbsProof 检查凭证带有指定类型、用途为 assertionMethod 的证明
+func bbsProof(credential *SimpleCredential, proofType string) (*Proof, error) {
+	if credential == nil {
+		return nil, errors.New("credential is nil")
+	}
+	if credential.Proof == nil {
+		return nil, errors.New("credential has no proof")
+	}
+	if credential.Proof.Type != proofType {
+		return nil, fmt.Errorf("unexpected proof type: %s", credential.Proof.Type)
+	}
+	if credential.Proof.ProofPurpose != ProofPurposeAssertionMethod {
+		return nil, fmt.Errorf("unexpected proof purpose: %s", credential.Proof.ProofPurpose)
+	}
+	return credential.Proof, nil
+}
+
+// bbsProofStatements 返回颁发者签名时证明选项的规范化语句，派生证明沿用其创建时间和验证方法
+func bbsProofStatements(contexts []string, proof *Proof) ([]string, error) {
+	options, err := canonicalObject(&Proof{
+		Type:               ProofTypeBbsBlsSignature2020,
+		Created:            proof.Created,
+		VerificationMethod: proof.VerificationMethod,
+		ProofPurpose:       proof.ProofPurpose,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("canonicalize proof options: %w", err)
+	}
+	options["@context"] = contexts
+
+	statements, _, err := canonicalStatements(options)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalize proof options: %w", err)
+	}
+	return statements, nil
+}
+
+// bbsDocument 返回不含证明的凭证文档，凭证主体中没有 id 的嵌套对象按字段名顺序标记为空白节点 _:n0、_:n1…，
+// 同时返回这些标识到规范标识的映射
+func bbsDocument(credential *SimpleCredential) (map[string]interface{}, map[string]string, error) {
+	unsigned := *credential
+	unsigned.Proof = nil
+
+	document, err := canonicalObject(&unsigned)
+	if err != nil {
+		return nil, nil, fmt.Errorf("canonicalize credential: %w", err)
+	}
+	if subject, ok := document["credentialSubject"].(map[string]interface{}); ok {
+		next := 0
+		for _, name := range sortedNames(subject) {
+			labelBlankNodes(subject[name], &next)
+		}
+	}
+
+	_, labels, err := jsonld.CanonizeLabels(document)
+	if err != nil {
+		return nil, nil, fmt.Errorf("canonicalize credential: %w", err)
+	}
+	return document, labels, nil
+}
+
+// bbsRevealedStatements 返回派生凭证的规范化语句，urn:bnid IRI 还原为原凭证中的空白节点后按码点排序
+func bbsRevealedStatements(credential *SimpleCredential) ([]string, error) {
+	unsigned := *credential
+	unsigned.Proof = nil
+
+	statements, _, err := canonicalStatements(&unsigned)
+	if err != nil {
+		return nil, err
+	}
+	for i, statement := range statements {
+		statements[i] = blankNodeIRI.ReplaceAllString(statement, "$1")
+	}
+	sort.Strings(statements)
+	return statements, nil
+}
+
+// canonicalStatements 将 JSON-LD 文档规范化为 N-Quads 语句，每条语句不含结尾的换行
+func canonicalStatements(document interface{}) ([]string, map[string]string, error) {
+	canonical, labels, err := jsonld.CanonizeLabels(document)
+	if err != nil {
+		return nil, nil, fmt.Errorf("canonicalize credential: %w", err)
+	}
+	statements := strings.Split(strings.TrimSuffix(canonical, "\n"), "\n")
+	if canonical == "" {
+		statements = nil
+	}
+	return statements, labels, nil
+}
+
+// bbsMessages 按签名顺序将证明选项和凭证的语句转换为 BBS+ 消息
+func bbsMessages(proofStatements, documentStatements []string) [][]byte {
+	messages := make([][]byte, 0, len(proofStatements)+len(documentStatements))
+	for _, statement := range proofStatements {
+		messages = append(messages, []byte(statement))
+	}
+	for _, statement := range documentStatements {
+		messages = append(messages, []byte(statement))
+	}
+	return messages
+}
+
+// selectClaims 返回只保留 id 和 reveal 中字段的凭证主体，名称=值 形式只保留多值字段中的该值
+func selectClaims(subject map[string]interface{}, reveal []string) (map[string]interface{}, error) {
+	selected := map[string]interface{}{"id": subject["id"]}
+	for _, entry := range reveal {
+		name, value, single := strings.Cut(entry, "=")
+		if name == "id" {
+			continue
+		}
+		claim, exists := subject[name]
+		if !exists {
+			return nil, fmt.Errorf("claim %s is not in the credential subject", name)
+		}
+		if !single {
+			selected[name] = claim
+			continue
+		}
+
+		values, _ := claim.([]interface{})
+		found := false
+		for _, item := range values {
+			if fmt.Sprint(item) == value {
+				existing, _ := selected[name].([]interface{})
+				selected[name] = append(existing, item)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("claim %s has no value %s", name, value)
+		}
+	}
+	return selected, nil
+}
+
+// labelBlankNodes 为 value 中没有 id 的对象依次标记空白节点标识
+func labelBlankNodes(value interface{}, next *int) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, exists := v["id"]; !exists {
+			v["id"] = fmt.Sprintf("_:n%d", *next)
+			*next++
+		}
+		for _, name := range sortedNames(v) {
+			labelBlankNodes(v[name], next)
+		}
+	case []interface{}:
+		for _, item := range v {
+			labelBlankNodes(item, next)
+		}
+	}
+}
+
+// identifyBlankNodes 将 value 中标记的空白节点标识替换为 urn:bnid 形式的规范标识
+func identifyBlankNodes(value interface{}, labels map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, item := range v {
+			if id, ok := item.(string); name == "id" && ok && strings.HasPrefix(id, "_:") {
+				v[name] = "urn:bnid:" + labels[id]
+				continue
+			}
+			v[name] = identifyBlankNodes(item, labels)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = identifyBlankNodes(item, labels)
+		}
+	}
+	return value
+}
+
+// sortedNames 返回对象的字段名，按字典序排列
+func sortedNames(object map[string]interface{}) []string {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// canonicalObject 将值编码为 JSON 对象，数字保留原始表示
+func canonicalObject(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
@@ -0,0 +1,184 @@
+package vc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/czh0526/game/server/pkg/bbs"
+	"github.com/czh0526/game/server/pkg/jsonld"
+)
+
+func newBBSCredential(t *testing.T) (*SimpleCredential, *bbs.PrivateKey) {
+	t.Helper()
+	key, err := bbs.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := IssueCredential("did:example:issuer", "did:example:holder", "LevelCredential", CredentialSubject{
+		PlayerID: "player-1",
+		GameID:   "game-1",
+		Level:    12,
+		Score:    900,
+		// 等级门槛由颁发者签发，测试中直接写入
+		LevelAtLeast: []int{5, 10},
+		Attributes:   map[string]interface{}{"class": "mage", "stats": map[string]interface{}{"mana": 40}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignBBSCredential(credential, key, "did:example:issuer#bbs-key-1"); err != nil {
+		t.Fatalf("SignBBSCredential: %v", err)
+	}
+	return credential, key
+}
+
+func TestBBSSignatureRoundTrip(t *testing.T) {
+	credential, key := newBBSCredential(t)
+	if credential.Proof.Type != ProofTypeBbsBlsSignature2020 {
+		t.Fatalf("proof type %s", credential.Proof.Type)
+	}
+	if err := VerifyBBSSignature(credential, key.Public()); err != nil {
+		t.Fatalf("VerifyBBSSignature: %v", err)
+	}
+
+	tampered := *credential
+	tampered.CredentialSubject.Level = 99
+	if err := VerifyBBSSignature(&tampered, key.Public()); err == nil {
+		t.Error("tampered claim verified")
+	}
+	tampered = *credential
+	tampered.Issuer = "did:example:other"
+	if err := VerifyBBSSignature(&tampered, key.Public()); err == nil {
+		t.Error("tampered frame verified")
+	}
+	other, _ := bbs.GenerateKey(rand.Reader)
+	if err := VerifyBBSSignature(credential, other.Public()); err == nil {
+		t.Error("signature verified with another key")
+	}
+}
+
+// 验证方提供的 base64 随机数
+const (
+	nonce1 = "bm9uY2UtMQ=="
+	nonce2 = "bm9uY2UtMg=="
+)
+
+func TestBBSCredentialUsesStandardSuite(t *testing.T) {
+	credential, _ := newBBSCredential(t)
+	if len(credential.Context) < 2 || credential.Context[1] != jsonld.BbsV1 {
+		t.Errorf("credential context %v lacks the BBS suite context", credential.Context)
+	}
+	if _, err := base64.StdEncoding.DecodeString(credential.Proof.ProofValue); err != nil {
+		t.Errorf("proofValue is not base64: %v", err)
+	}
+}
+
+func TestDeriveBBSCredentialDisclosesOnlyRequestedClaims(t *testing.T) {
+	credential, key := newBBSCredential(t)
+
+	derived, err := DeriveBBSCredential(credential, key.Public(), []string{"level"}, nonce1)
+	if err != nil {
+		t.Fatalf("DeriveBBSCredential: %v", err)
+	}
+	if derived.Proof.Type != ProofTypeBbsBlsSignatureProof2020 {
+		t.Fatalf("proof type %s", derived.Proof.Type)
+	}
+	subject := derived.CredentialSubject
+	if subject.Level != 12 || subject.Score != 0 || subject.PlayerID != "" || subject.GameID != "" || subject.LevelAtLeast != nil || subject.Attributes != nil {
+		t.Fatalf("unexpected disclosed subject: %+v", subject)
+	}
+	if subject.ID != "did:example:holder" {
+		t.Errorf("subject id %q was not disclosed", subject.ID)
+	}
+
+	if err := VerifyBBSDerivedProof(derived, key.Public(), nonce1); err != nil {
+		t.Fatalf("VerifyBBSDerivedProof: %v", err)
+	}
+	if err := VerifyBBSDerivedProof(derived, key.Public(), nonce2); err == nil {
+		t.Error("derived proof accepted with another nonce")
+	}
+
+	forged := *derived
+	forged.CredentialSubject.Level = 99
+	if err := VerifyBBSDerivedProof(&forged, key.Public(), nonce1); err == nil {
+		t.Error("forged disclosed claim verified")
+	}
+	forged = *derived
+	forged.CredentialSubject.Score = 900
+	if err := VerifyBBSDerivedProof(&forged, key.Public(), nonce1); err == nil {
+		t.Error("claim added after derivation verified")
+	}
+	forged = *derived
+	forged.Issuer = "did:example:other"
+	if err := VerifyBBSDerivedProof(&forged, key.Public(), nonce1); err == nil {
+		t.Error("tampered frame verified")
+	}
+	other, _ := bbs.GenerateKey(rand.Reader)
+	if err := VerifyBBSDerivedProof(derived, other.Public(), nonce1); err == nil {
+		t.Error("derived proof verified with another key")
+	}
+}
+
+func TestDeriveBBSCredentialRejectsUnknownClaim(t *testing.T) {
+	credential, key := newBBSCredential(t)
+	if _, err := DeriveBBSCredential(credential, key.Public(), []string{"items"}, ""); err == nil {
+		t.Error("derived a proof for a claim the credential does not have")
+	}
+}
+
+func TestDeriveBBSCredentialDisclosesOneThreshold(t *testing.T) {
+	credential, key := newBBSCredential(t)
+
+	// 只披露等级不低于 10，不披露等级本身和其他门槛
+	derived, err := DeriveBBSCredential(credential, key.Public(), []string{"levelAtLeast=10"}, nonce1)
+	if err != nil {
+		t.Fatalf("DeriveBBSCredential: %v", err)
+	}
+	subject := derived.CredentialSubject
+	if subject.Level != 0 || len(subject.LevelAtLeast) != 1 || subject.LevelAtLeast[0] != 10 {
+		t.Fatalf("unexpected disclosed subject: %+v", subject)
+	}
+	if err := VerifyBBSDerivedProof(derived, key.Public(), nonce1); err != nil {
+		t.Fatalf("VerifyBBSDerivedProof: %v", err)
+	}
+
+	forged := *derived
+	forged.CredentialSubject.LevelAtLeast = []int{20}
+	if err := VerifyBBSDerivedProof(&forged, key.Public(), nonce1); err == nil {
+		t.Error("threshold the issuer did not sign verified")
+	}
+	if _, err := DeriveBBSCredential(credential, key.Public(), []string{"levelAtLeast=20"}, nonce1); err == nil {
+		t.Error("derived a proof for a threshold the credential does not have")
+	}
+}
+
+func TestDeriveBBSCredentialDisclosesNestedClaims(t *testing.T) {
+	credential, key := newBBSCredential(t)
+
+	// 嵌套对象在原凭证中是空白节点，派生凭证以 urn:bnid 标识它们
+	derived, err := DeriveBBSCredential(credential, key.Public(), []string{"attributes"}, nonce1)
+	if err != nil {
+		t.Fatalf("DeriveBBSCredential: %v", err)
+	}
+	if derived.CredentialSubject.Attributes["class"] != "mage" || derived.CredentialSubject.Score != 0 {
+		t.Fatalf("unexpected disclosed subject: %+v", derived.CredentialSubject)
+	}
+	if err := VerifyBBSDerivedProof(derived, key.Public(), nonce1); err != nil {
+		t.Fatalf("VerifyBBSDerivedProof: %v", err)
+	}
+
+	data, err := json.Marshal(derived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded SimpleCredential
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	decoded.CredentialSubject.Attributes["class"] = "warrior"
+	if err := VerifyBBSDerivedProof(&decoded, key.Public(), nonce1); err == nil {
+		t.Error("forged nested claim verified")
+	}
+}
@@ -0,0 +1,246 @@
+package vc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// 凭证格式
+const (
+	FormatLDPVC     = "ldp_vc"      // JSON-LD 凭证，内嵌 Linked-Data 证明
+	FormatJWTVCJSON = "jwt_vc_json" // 签名 JWT 凭证，凭证内容位于 vc 声明
+)
+
+// JWT 签名算法
+const (
+	JWTAlgEdDSA = "EdDSA"
+	JWTAlgES256 = "ES256"
+)
+
+// JWTHeader JWT 凭证头部
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	KID string `json:"kid"`
+}
+
+// JWTClaims JWT 凭证声明，按 VC Data Model 的 JWT 编码规则映射标准声明
+type JWTClaims struct {
+	Issuer    string            `json:"iss"`
+	Subject   string            `json:"sub,omitempty"`
+	ID        string            `json:"jti,omitempty"`
+	NotBefore int64             `json:"nbf"`
+	IssuedAt  int64             `json:"iat"`
+	ExpiresAt int64             `json:"exp,omitempty"`
+	VC        *SimpleCredential `json:"vc"`
+}
+
+// JWTCredential 解析后的 JWT 凭证，签名尚未验证
+type JWTCredential struct {
+	Header     JWTHeader
+	Claims     JWTClaims
+	Credential *SimpleCredential
+
+	signingInput string
+	signature    []byte
+}
+
+// EncodeJWTCredential 将凭证编码为签名 JWT，算法由签名密钥的公钥类型决定：
+// Ed25519 使用 EdDSA，P-256 使用 ES256。privateKey 可以是内存中的私钥或 KMS 提供的 crypto.Signer
+func EncodeJWTCredential(credential *SimpleCredential, privateKey crypto.Signer, kid string) (string, error) {
+	if credential == nil {
+		return "", errors.New("credential is nil")
+	}
+
+	unsigned := *credential
+	unsigned.Proof = nil
+
+	claims := JWTClaims{
+		Issuer:    credential.Issuer,
+		Subject:   credential.CredentialSubject.ID,
+		ID:        credential.ID,
+		NotBefore: credential.IssuanceDate.Unix(),
+		IssuedAt:  time.Now().Unix(),
+		VC:        &unsigned,
+	}
+	if credential.ExpirationDate != nil {
+		claims.ExpiresAt = credential.ExpirationDate.Unix()
+	}
+
+	return SignCompactJWT("JWT", kid, claims, privateKey)
+}
+
+// ParseJWTCredential 解析 JWT 凭证并用标准声明还原凭证字段，不验证签名
+func ParseJWTCredential(token string) (*JWTCredential, error) {
+	parsed := &JWTCredential{}
+	signingInput, signature, err := ParseCompactJWT(token, &parsed.Header, &parsed.Claims)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Claims.VC == nil {
+		return nil, errors.New("JWT has no vc claim")
+	}
+	parsed.signingInput = signingInput
+	parsed.signature = signature
+
+	// 标准声明优先于 vc 声明中的同名字段
+	credential := *parsed.Claims.VC
+	credential.Issuer = parsed.Claims.Issuer
+	if parsed.Claims.ID != "" {
+		credential.ID = parsed.Claims.ID
+	}
+	if parsed.Claims.Subject != "" {
+		credential.CredentialSubject.ID = parsed.Claims.Subject
+	}
+	credential.IssuanceDate = time.Unix(parsed.Claims.NotBefore, 0).UTC()
+	if parsed.Claims.ExpiresAt != 0 {
+		expiresAt := time.Unix(parsed.Claims.ExpiresAt, 0).UTC()
+		credential.ExpirationDate = &expiresAt
+	}
+	credential.Proof = nil
+	parsed.Credential = &credential
+
+	return parsed, nil
+}
+
+// Verify 使用颁发者公钥验证 JWT 签名，公钥类型必须与 alg 一致
+func (j *JWTCredential) Verify(publicKey crypto.PublicKey) error {
+	return VerifyJWTSignature(j.Header.Alg, j.signingInput, j.signature, publicKey)
+}
+
+// SignCompactJWT 生成紧凑序列化的签名 JWT，也用于凭证之外的 JWT（如 DID 认证令牌）
+func SignCompactJWT(typ, kid string, claims interface{}, privateKey crypto.Signer) (string, error) {
+	alg, err := jwtAlgFor(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(JWTHeader{Alg: alg, Typ: typ, KID: kid})
+	if err != nil {
+		return "", fmt.Errorf("marshal JWT header: %w", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("marshal JWT claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := signJWT(privateKey, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyJWTSignature 按 alg 验证 JWT 签名，也用于凭证之外的 JWT（如 DID 认证令牌）
+func VerifyJWTSignature(alg, signingInput string, signature []byte, publicKey crypto.PublicKey) error {
+	switch alg {
+	case JWTAlgEdDSA:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("EdDSA requires an Ed25519 key")
+		}
+		if !ed25519.Verify(key, []byte(signingInput), signature) {
+			return errors.New("invalid JWT signature")
+		}
+	case JWTAlgES256:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok || key.Curve != elliptic.P256() {
+			return errors.New("ES256 requires a P-256 key")
+		}
+		if len(signature) != 64 {
+			return errors.New("invalid ES256 signature length")
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return errors.New("invalid JWT signature")
+		}
+	default:
+		return fmt.Errorf("unsupported JWT alg: %s", alg)
+	}
+	return nil
+}
+
+// jwtAlgFor 按签名密钥的公钥类型返回 JWT 算法
+func jwtAlgFor(privateKey crypto.Signer) (string, error) {
+	switch key := privateKey.Public().(type) {
+	case ed25519.PublicKey:
+		return JWTAlgEdDSA, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", errors.New("ES256 requires a P-256 key")
+		}
+		return JWTAlgES256, nil
+	default:
+		return "", fmt.Errorf("unsupported signing key type %T", privateKey)
+	}
+}
+
+// signJWT 签名 JWT，ES256 签名由 ASN.1 DER 转为 JWS 要求的定长 r || s
+func signJWT(privateKey crypto.Signer, signingInput []byte) ([]byte, error) {
+	if _, ok := privateKey.Public().(*ecdsa.PublicKey); ok {
+		digest := sha256.Sum256(signingInput)
+		der, err := privateKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("sign JWT: %w", err)
+		}
+		var parsed struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(der, &parsed); err != nil {
+			return nil, fmt.Errorf("decode ES256 signature: %w", err)
+		}
+		signature := make([]byte, 64)
+		parsed.R.FillBytes(signature[:32])
+		parsed.S.FillBytes(signature[32:])
+		return signature, nil
+	}
+
+	signature, err := privateKey.Sign(rand.Reader, signingInput, crypto.Hash(0))
+	if err != nil {
+		return nil, fmt.Errorf("sign JWT: %w", err)
+	}
+	return signature, nil
+}
+
+// ParseCompactJWT 解码紧凑序列化 JWT 的头部和声明，返回签名输入和签名，不验证签名
+func ParseCompactJWT(token string, header, claims interface{}) (string, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", nil, errors.New("malformed JWT")
+	}
+
+	if err := decodeSegment(parts[0], header); err != nil {
+		return "", nil, fmt.Errorf("decode JWT header: %w", err)
+	}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return "", nil, fmt.Errorf("decode JWT claims: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, fmt.Errorf("decode JWT signature: %w", err)
+	}
+	return parts[0] + "." + parts[1], signature, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
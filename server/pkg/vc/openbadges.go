@@ -0,0 +1,177 @@
+package vc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// OpenBadgeCredentialType Open Badges 3.0 的成就凭证类型
+const OpenBadgeCredentialType = "OpenBadgeCredential"
+
+// OpenBadgesContext Open Badges 3.0 的 JSON-LD 上下文
+const OpenBadgesContext = "https://purl.imsglobal.org/spec/ob/v3p0/context-3.0.3.json"
+
+// BadgeAchievement Open Badges 3.0 的成就描述。凭证主体的 Badge 不为空时凭证按 Open Badges 3.0 格式颁发，
+// 徽章钱包和学习平台据此显示成就的名称、图片和获得条件
+type BadgeAchievement struct {
+	// ID 成就的 URI，通常为成就的公开页面地址
+	ID          string        `json:"id"`
+	Type        []string      `json:"type"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Criteria    BadgeCriteria `json:"criteria"`
+	Image       *BadgeImage   `json:"image,omitempty"`
+	// Alignment 成就对应的外部能力框架或课程目标
+	Alignment []BadgeAlignment `json:"alignment,omitempty"`
+	// Creator 成就的创建者，颁发时填入颁发者 DID，只需设置名称
+	Creator *BadgeProfile `json:"creator,omitempty"`
+}
+
+// BadgeCriteria 获得成就的条件，至少需要 ID（条件说明页面的地址）和 Narrative 之一
+type BadgeCriteria struct {
+	ID        string `json:"id,omitempty"`
+	Narrative string `json:"narrative,omitempty"`
+}
+
+// BadgeImage 徽章图片
+type BadgeImage struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// BadgeAlignment 成就与外部框架中一项目标的对应关系
+type BadgeAlignment struct {
+	Type              []string `json:"type"`
+	TargetName        string   `json:"targetName"`
+	TargetURL         string   `json:"targetUrl"`
+	TargetDescription string   `json:"targetDescription,omitempty"`
+	TargetFramework   string   `json:"targetFramework,omitempty"`
+	TargetCode        string   `json:"targetCode,omitempty"`
+}
+
+// BadgeProfile 颁发者或成就创建者的描述
+type BadgeProfile struct {
+	ID   string   `json:"id"`
+	Type []string `json:"type"`
+	Name string   `json:"name,omitempty"`
+}
+
+// applyOpenBadge 将凭证转为 Open Badges 3.0 的 OpenBadgeCredential：加入上下文和类型，
+// 颁发者编码为 Profile 对象，补全成就各部分的类型。成就被复制，不修改调用方的模板
+func (c *SimpleCredential) applyOpenBadge() {
+	badge := *c.CredentialSubject.Badge
+	if badge.Image != nil {
+		image := *badge.Image
+		badge.Image = &image
+	}
+	badge.Alignment = append([]BadgeAlignment(nil), badge.Alignment...)
+	c.CredentialSubject.Badge = &badge
+
+	c.Context = append(c.Context[:1], append([]string{OpenBadgesContext}, c.Context[1:]...)...)
+	c.Type = append(c.Type, OpenBadgeCredentialType)
+	c.Name = badge.Name
+
+	profile := &BadgeProfile{ID: c.Issuer, Type: []string{"Profile"}}
+	if badge.Creator != nil {
+		profile.Name = badge.Creator.Name
+		badge.Creator = profile
+	}
+	c.IssuerProfile = profile
+
+	c.CredentialSubject.Type = []string{"AchievementSubject"}
+	c.CredentialSubject.Achievement = badge.Name
+	badge.Type = []string{"Achievement"}
+	if badge.Image != nil {
+		badge.Image.Type = "Image"
+	}
+	for i := range badge.Alignment {
+		badge.Alignment[i].Type = []string{"Alignment"}
+	}
+}
+
+// credentialJSON 与 SimpleCredential 字段相同，用于默认的 JSON 编解码
+type credentialJSON SimpleCredential
+
+// MarshalJSON 设置了 IssuerProfile 时颁发者编码为 Profile 对象
+func (c SimpleCredential) MarshalJSON() ([]byte, error) {
+	if c.IssuerProfile == nil {
+		return json.Marshal(credentialJSON(c))
+	}
+	return json.Marshal(struct {
+		credentialJSON
+		Issuer *BadgeProfile `json:"issuer"`
+	}{credentialJSON(c), c.IssuerProfile})
+}
+
+// UnmarshalJSON 颁发者可以是 DID 或 Profile 对象，后者的 ID 作为 Issuer
+func (c *SimpleCredential) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		credentialJSON
+		Issuer json.RawMessage `json:"issuer"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*c = SimpleCredential(decoded.credentialJSON)
+
+	if isJSONObject(decoded.Issuer) {
+		var profile BadgeProfile
+		if err := json.Unmarshal(decoded.Issuer, &profile); err != nil {
+			return fmt.Errorf("decode issuer profile: %w", err)
+		}
+		c.Issuer = profile.ID
+		c.IssuerProfile = &profile
+		return nil
+	}
+	if len(decoded.Issuer) > 0 {
+		return json.Unmarshal(decoded.Issuer, &c.Issuer)
+	}
+	return nil
+}
+
+// subjectJSON 与 CredentialSubject 字段相同，用于默认的 JSON 编解码
+type subjectJSON CredentialSubject
+
+// MarshalJSON 设置了 Badge 时 achievement 编码为 Open Badges 的成就对象
+func (s CredentialSubject) MarshalJSON() ([]byte, error) {
+	if s.Badge == nil {
+		return json.Marshal(subjectJSON(s))
+	}
+	return json.Marshal(struct {
+		subjectJSON
+		Achievement *BadgeAchievement `json:"achievement"`
+	}{subjectJSON(s), s.Badge})
+}
+
+// UnmarshalJSON achievement 可以是成就名或 Open Badges 的成就对象，后者的名称作为 Achievement
+func (s *CredentialSubject) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		subjectJSON
+		Achievement json.RawMessage `json:"achievement"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*s = CredentialSubject(decoded.subjectJSON)
+
+	if isJSONObject(decoded.Achievement) {
+		var badge BadgeAchievement
+		if err := json.Unmarshal(decoded.Achievement, &badge); err != nil {
+			return fmt.Errorf("decode achievement: %w", err)
+		}
+		s.Achievement = badge.Name
+		s.Badge = &badge
+		return nil
+	}
+	if len(decoded.Achievement) > 0 {
+		return json.Unmarshal(decoded.Achievement, &s.Achievement)
+	}
+	return nil
+}
+
+// isJSONObject 判断 JSON 值是否为对象
+func isJSONObject(data json.RawMessage) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
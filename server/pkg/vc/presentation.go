@@ -0,0 +1,121 @@
+package vc
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// SimplePresentation 简化的可验证表述
+type SimplePresentation struct {
+	Context              []string            `json:"@context"`
+	ID                   string              `json:"id"`
+	Type                 []string            `json:"type"`
+	Holder               string              `json:"holder"`
+	VerifiableCredential []*SimpleCredential `json:"verifiableCredential"`
+	Proof                *Proof              `json:"proof,omitempty"`
+}
+
+// CreatePresentation 将一个或多个凭证封装为可验证表述
+func CreatePresentation(holderDID string, credentials ...*SimpleCredential) (*SimplePresentation, error) {
+	if holderDID == "" {
+		return nil, errors.New("holder DID is required")
+	}
+	if len(credentials) == 0 {
+		return nil, errors.New("at least one credential is required")
+	}
+
+	return &SimplePresentation{
+		Context: []string{
+			"https://www.w3.org/2018/credentials/v1",
+		},
+		ID:                   fmt.Sprintf("urn:uuid:%s", uuid.New().String()),
+		Type:                 []string{"VerifiablePresentation"},
+		Holder:               holderDID,
+		VerifiableCredential: credentials,
+	}, nil
+}
+
+// SignPresentation 使用持有者私钥为表述生成认证证明，challenge 和 domain 用于防重放
+func SignPresentation(presentation *SimplePresentation, privateKey crypto.Signer, verificationMethod, proofType, challenge, domain string) error {
+	if presentation == nil {
+		return errors.New("presentation is nil")
+	}
+
+	proof := &Proof{
+		Type:               proofType,
+		VerificationMethod: verificationMethod,
+		ProofPurpose:       ProofPurposeAuthentication,
+		Challenge:          challenge,
+		Domain:             domain,
+	}
+
+	presentation.Proof = nil
+	if err := createProof(presentation, privateKey, proof); err != nil {
+		return err
+	}
+
+	presentation.Proof = proof
+	return nil
+}
+
+// VerifyPresentationProof 使用持有者公钥验证表述证明，并检查 challenge 和 domain。
+// 两者都必须由验证方给出，否则表述可以被重放到其他验证方或其他会话
+func VerifyPresentationProof(presentation *SimplePresentation, publicKey ed25519.PublicKey, challenge, domain string) error {
+	if presentation == nil {
+		return errors.New("presentation is nil")
+	}
+	if challenge == "" || domain == "" {
+		return errors.New("challenge and domain are required")
+	}
+	if presentation.Proof == nil {
+		return errors.New("presentation has no proof")
+	}
+
+	proof := presentation.Proof
+	if proof.ProofPurpose != ProofPurposeAuthentication {
+		return fmt.Errorf("unexpected proof purpose: %s", proof.ProofPurpose)
+	}
+	if proof.Challenge != challenge {
+		return errors.New("challenge mismatch")
+	}
+	if proof.Domain != domain {
+		return errors.New("domain mismatch")
+	}
+
+	unsigned := *presentation
+	unsigned.Proof = nil
+
+	return verifyProof(&unsigned, proof, publicKey)
+}
+
+// HasCredentialType 检查表述中是否包含指定类型的凭证
+func (p *SimplePresentation) HasCredentialType(credType string) bool {
+	for _, credential := range p.VerifiableCredential {
+		if credential == nil {
+			continue
+		}
+		for _, t := range credential.Type {
+			if t == credType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ToJSON 转换为JSON
+func (p *SimplePresentation) ToJSON() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// PresentationFromJSON 从JSON解析
+func PresentationFromJSON(data []byte) (*SimplePresentation, error) {
+	var presentation SimplePresentation
+	err := json.Unmarshal(data, &presentation)
+	return &presentation, err
+}
@@ -0,0 +1,51 @@
+package vc
+
+import (
+	"testing"
+)
+
+func newSignedPresentation(t *testing.T, challenge, domain string) (*SimplePresentation, []byte) {
+	t.Helper()
+	_, issuerKey := newTestSigner(t)
+	holderPublic, holderKey := newTestSigner(t)
+
+	presentation, err := CreatePresentation("did:example:holder", newSignedCredential(t, issuerKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignPresentation(presentation, holderKey, "did:example:holder#key-1", ProofTypeDataIntegrity, challenge, domain); err != nil {
+		t.Fatalf("SignPresentation: %v", err)
+	}
+	return presentation, holderPublic
+}
+
+func TestVerifyPresentationProofBindsChallengeAndDomain(t *testing.T) {
+	presentation, publicKey := newSignedPresentation(t, "nonce-1", "room:1")
+
+	if err := VerifyPresentationProof(presentation, publicKey, "nonce-1", "room:1"); err != nil {
+		t.Fatalf("VerifyPresentationProof: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		challenge, domain string
+	}{
+		{"other challenge", "nonce-2", "room:1"},
+		{"other domain", "nonce-1", "room:2"},
+		{"no challenge", "", "room:1"},
+		{"no domain", "nonce-1", ""},
+	}
+	for _, tt := range tests {
+		if err := VerifyPresentationProof(presentation, publicKey, tt.challenge, tt.domain); err == nil {
+			t.Errorf("%s: presentation verified", tt.name)
+		}
+	}
+}
+
+func TestVerifyPresentationProofRequiresChallengeAndDomain(t *testing.T) {
+	// 未绑定 challenge 和 domain 的表述可以被任意重放，验证方不能接受
+	presentation, publicKey := newSignedPresentation(t, "", "")
+	if err := VerifyPresentationProof(presentation, publicKey, "", ""); err == nil {
+		t.Error("presentation without challenge and domain verified")
+	}
+}
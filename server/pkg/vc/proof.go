@@ -0,0 +1,292 @@
+package vc
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/jsonld"
+	"github.com/czh0526/game/server/pkg/multibase"
+	"github.com/gowebpki/jcs"
+)
+
+// 证明类型
+const (
+	// ProofTypeDataIntegrity W3C Data Integrity 证明类型，具体算法由 cryptosuite 指定
+	ProofTypeDataIntegrity = "DataIntegrityProof"
+	// ProofTypeEd25519Signature2020 Ed25519 签名、URDNA2015 规范化的链接数据证明，签名值为 multibase 编码的 proofValue
+	ProofTypeEd25519Signature2020 = "Ed25519Signature2020"
+	// ProofTypeEd25519Signature2018 与 Ed25519Signature2020 相同的签名数据，签名值为分离载荷的 JWS（b64=false）
+	ProofTypeEd25519Signature2018 = "Ed25519Signature2018"
+)
+
+// ed25519JWSHeader Ed25519Signature2018 的 JWS 头 {"alg":"EdDSA","b64":false,"crit":["b64"]}
+const ed25519JWSHeader = "eyJhbGciOiJFZERTQSIsImI2NCI6ZmFsc2UsImNyaXQiOlsiYjY0Il19"
+
+// CryptosuiteEddsaJcs2022 Ed25519 签名、JCS（RFC 8785）规范化的 cryptosuite
+const CryptosuiteEddsaJcs2022 = "eddsa-jcs-2022"
+
+// 证明用途
+const (
+	ProofPurposeAssertionMethod = "assertionMethod"
+	ProofPurposeAuthentication  = "authentication"
+)
+
+// SignCredential 使用颁发者的 Ed25519 签名密钥为凭证生成证明，privateKey 可以是 ed25519.PrivateKey，
+// 也可以是由 KMS 签名的 crypto.Signer。proofType 为空时使用 DataIntegrityProof（eddsa-jcs-2022），
+// 也可以是 Ed25519Signature2020 或 Ed25519Signature2018，使用 Ed25519Signature2020 时凭证加入其上下文
+//
+// 签名数据为 SHA-256(证明配置) || SHA-256(凭证文档)，证明配置带有文档的 @context。DataIntegrityProof 以 JCS
+// 规范化，Ed25519Signature2020/2018 以 URDNA2015 规范化为 N-Quads，只能使用 jsonld 包内置的上下文
+func SignCredential(credential *SimpleCredential, privateKey crypto.Signer, verificationMethod, proofType string) error {
+	if credential == nil {
+		return errors.New("credential is nil")
+	}
+
+	proof := &Proof{
+		Type:               proofType,
+		VerificationMethod: verificationMethod,
+		ProofPurpose:       ProofPurposeAssertionMethod,
+	}
+
+	credential.Proof = nil
+	credential.Context = withSuiteContext(credential.Context, proofType)
+	if err := createProof(credential, privateKey, proof); err != nil {
+		return err
+	}
+
+	credential.Proof = proof
+	return nil
+}
+
+// VerifyProof 使用颁发者公钥验证凭证的 DataIntegrityProof、Ed25519Signature2020 或 Ed25519Signature2018 证明
+func VerifyProof(credential *SimpleCredential, publicKey ed25519.PublicKey) error {
+	if credential == nil {
+		return errors.New("credential is nil")
+	}
+	if credential.Proof == nil {
+		return errors.New("credential has no proof")
+	}
+	if credential.Proof.ProofPurpose != ProofPurposeAssertionMethod {
+		return fmt.Errorf("unexpected proof purpose: %s", credential.Proof.ProofPurpose)
+	}
+
+	unsigned := *credential
+	unsigned.Proof = nil
+
+	return verifyProof(&unsigned, credential.Proof, publicKey)
+}
+
+// SignDocument 为凭证之外的任意 JSON 文档（如玩家数据导出）生成 eddsa-jcs-2022 证明，文档不必是 JSON-LD，
+// document 序列化后不能包含证明本身，签名和验证使用与凭证相同的规范化方式
+func SignDocument(document interface{}, privateKey crypto.Signer, verificationMethod string) (*Proof, error) {
+	proof := &Proof{
+		Type:               ProofTypeDataIntegrity,
+		VerificationMethod: verificationMethod,
+		ProofPurpose:       ProofPurposeAssertionMethod,
+	}
+	if err := createProof(document, privateKey, proof); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// VerifyDocument 验证 SignDocument 生成的证明，document 为去除证明后的文档
+func VerifyDocument(document interface{}, proof *Proof, publicKey ed25519.PublicKey) error {
+	if proof == nil {
+		return errors.New("document has no proof")
+	}
+	return verifyProof(document, proof, publicKey)
+}
+
+// createProof 对不含证明的文档签名，并将签名值写入 proof
+func createProof(document interface{}, privateKey crypto.Signer, proof *Proof) error {
+	if privateKey == nil {
+		return errors.New("signing key is nil")
+	}
+	if _, ok := privateKey.Public().(ed25519.PublicKey); !ok {
+		return errors.New("invalid ed25519 private key")
+	}
+
+	if proof.Type == "" {
+		proof.Type = ProofTypeDataIntegrity
+	}
+	if proof.Type == ProofTypeDataIntegrity && proof.Cryptosuite == "" {
+		proof.Cryptosuite = CryptosuiteEddsaJcs2022
+	}
+	if err := checkCryptosuite(proof); err != nil {
+		return err
+	}
+
+	proof.Created = time.Now().UTC().Truncate(time.Second)
+	proof.ProofValue = ""
+	proof.JWS = ""
+
+	signingInput, err := proofSigningInput(document, proof)
+	if err != nil {
+		return err
+	}
+	if proof.Type == ProofTypeEd25519Signature2018 {
+		signingInput = append([]byte(ed25519JWSHeader+"."), signingInput...)
+	}
+	signature, err := privateKey.Sign(rand.Reader, signingInput, crypto.Hash(0))
+	if err != nil {
+		return fmt.Errorf("sign proof: %w", err)
+	}
+
+	if proof.Type == ProofTypeEd25519Signature2018 {
+		proof.JWS = ed25519JWSHeader + ".." + base64.RawURLEncoding.EncodeToString(signature)
+	} else {
+		proof.ProofValue = multibase.EncodeBase58BTC(signature)
+	}
+	return nil
+}
+
+// verifyProof 验证不含证明的文档与 proof 中的签名是否匹配
+func verifyProof(document interface{}, proof *Proof, publicKey ed25519.PublicKey) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid ed25519 public key")
+	}
+
+	if err := checkCryptosuite(proof); err != nil {
+		return err
+	}
+
+	// 计算签名数据时需去除证明值本身
+	options := *proof
+	options.ProofValue = ""
+	options.JWS = ""
+
+	signingInput, err := proofSigningInput(document, &options)
+	if err != nil {
+		return err
+	}
+
+	var signature []byte
+	if proof.Type == ProofTypeEd25519Signature2018 {
+		header, encoded, found := strings.Cut(proof.JWS, "..")
+		if !found || header != ed25519JWSHeader {
+			return errors.New("jws must be a detached EdDSA JWS with an unencoded payload")
+		}
+		if signature, err = base64.RawURLEncoding.DecodeString(encoded); err != nil {
+			return fmt.Errorf("decode jws signature: %w", err)
+		}
+		signingInput = append([]byte(header+"."), signingInput...)
+	} else if signature, err = multibase.Decode(proof.ProofValue); err != nil {
+		return fmt.Errorf("decode proof value: %w", err)
+	}
+	if !ed25519.Verify(publicKey, signingInput, signature) {
+		return errors.New("invalid proof signature")
+	}
+	return nil
+}
+
+// checkCryptosuite 接受 eddsa-jcs-2022 的 DataIntegrityProof 和不带 cryptosuite 的 Ed25519Signature2020/2018
+func checkCryptosuite(proof *Proof) error {
+	switch proof.Type {
+	case ProofTypeDataIntegrity:
+		if proof.Cryptosuite != CryptosuiteEddsaJcs2022 {
+			return fmt.Errorf("unsupported cryptosuite: %s", proof.Cryptosuite)
+		}
+	case ProofTypeEd25519Signature2020, ProofTypeEd25519Signature2018:
+		if proof.Cryptosuite != "" {
+			return fmt.Errorf("%s proofs have no cryptosuite", proof.Type)
+		}
+	default:
+		return fmt.Errorf("unsupported proof type: %s", proof.Type)
+	}
+	return nil
+}
+
+// IsLinkedDataProofType 证明类型是否以 URDNA2015 规范化 JSON-LD 文档，这类证明只能用于上下文均已内置的文档
+func IsLinkedDataProofType(proofType string) bool {
+	return proofType == ProofTypeEd25519Signature2020 || proofType == ProofTypeEd25519Signature2018
+}
+
+// HasOfflineContexts 凭证的 @context 是否均已内置，只有这样才能签发 Ed25519Signature2020/2018 证明
+func (c *SimpleCredential) HasOfflineContexts() bool {
+	for _, context := range c.Context {
+		if !jsonld.HasContext(context) {
+			return false
+		}
+	}
+	return true
+}
+
+// suiteContexts 术语不在凭证 v1 上下文中的证明类型所需的套件上下文
+var suiteContexts = map[string]string{
+	ProofTypeEd25519Signature2020:     jsonld.Ed25519Signature2020V1,
+	ProofTypeBbsBlsSignature2020:      jsonld.BbsV1,
+	ProofTypeBbsBlsSignatureProof2020: jsonld.BbsV1,
+}
+
+// withSuiteContext 返回加入证明套件上下文后的 @context，套件上下文排在凭证 v1 上下文之后
+func withSuiteContext(contexts []string, proofType string) []string {
+	suite, exists := suiteContexts[proofType]
+	if !exists {
+		return contexts
+	}
+	for _, context := range contexts {
+		if context == suite {
+			return contexts
+		}
+	}
+	position := 0
+	if len(contexts) > 0 {
+		position = 1
+	}
+	result := make([]string, 0, len(contexts)+1)
+	result = append(result, contexts[:position]...)
+	result = append(result, suite)
+	return append(result, contexts[position:]...)
+}
+
+// proofSigningInput 计算 SHA-256(规范化的证明配置) || SHA-256(规范化的文档)，证明配置为不含证明值的证明选项，
+// 文档有 @context 时证明配置带上相同的 @context
+func proofSigningInput(document interface{}, proofOptions *Proof) ([]byte, error) {
+	canonicalize := CanonicalJSON
+	if IsLinkedDataProofType(proofOptions.Type) {
+		canonicalize = func(v interface{}) ([]byte, error) {
+			nquads, err := jsonld.Canonize(v)
+			return []byte(nquads), err
+		}
+	}
+
+	config, err := canonicalObject(proofOptions)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalize proof options: %w", err)
+	}
+	if object, err := canonicalObject(document); err == nil && object["@context"] != nil {
+		config["@context"] = object["@context"]
+	}
+	canonicalProof, err := canonicalize(config)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalize proof options: %w", err)
+	}
+
+	canonicalDoc, err := canonicalize(document)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalize document: %w", err)
+	}
+
+	proofHash := sha256.Sum256(canonicalProof)
+	docHash := sha256.Sum256(canonicalDoc)
+
+	return append(proofHash[:], docHash[:]...), nil
+}
+
+// CanonicalJSON 生成 JCS（RFC 8785）规范化的 JSON 表示
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return jcs.Transform(data)
+}
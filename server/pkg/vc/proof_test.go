@@ -0,0 +1,181 @@
+package vc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/czh0526/game/server/pkg/jsonld"
+)
+
+func newTestSigner(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicKey, privateKey
+}
+
+func newSignedCredential(t *testing.T, privateKey ed25519.PrivateKey) *SimpleCredential {
+	t.Helper()
+	return newSignedCredentialWithProof(t, privateKey, "")
+}
+
+func newSignedCredentialWithProof(t *testing.T, privateKey ed25519.PrivateKey, proofType string) *SimpleCredential {
+	t.Helper()
+	credential, err := IssueCredential("did:example:issuer", "did:example:holder", "LevelCredential", CredentialSubject{
+		PlayerID: "player-1",
+		GameID:   "game-1",
+		Level:    12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential.CredentialSubject.Attributes = map[string]interface{}{"class": "mage", "ratio": 0.5}
+	if err := SignCredential(credential, privateKey, "did:example:issuer#key-1", proofType); err != nil {
+		t.Fatalf("SignCredential: %v", err)
+	}
+	return credential
+}
+
+// proofTypes 支持的全部 Ed25519 证明类型
+var proofTypes = []string{ProofTypeDataIntegrity, ProofTypeEd25519Signature2020, ProofTypeEd25519Signature2018}
+
+func TestSignCredentialRoundTrip(t *testing.T) {
+	publicKey, privateKey := newTestSigner(t)
+	for _, proofType := range proofTypes {
+		t.Run(proofType, func(t *testing.T) {
+			credential := newSignedCredentialWithProof(t, privateKey, proofType)
+
+			if credential.Proof.Type != proofType {
+				t.Fatalf("proof type is %s", credential.Proof.Type)
+			}
+			if err := VerifyProof(credential, publicKey); err != nil {
+				t.Fatalf("VerifyProof: %v", err)
+			}
+
+			data, err := credential.ToJSON()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := CredentialFromJSON(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyProof(decoded, publicKey); err != nil {
+				t.Fatalf("VerifyProof after a JSON round trip: %v", err)
+			}
+		})
+	}
+}
+
+func TestLinkedDataProofEncoding(t *testing.T) {
+	_, privateKey := newTestSigner(t)
+
+	credential := newSignedCredentialWithProof(t, privateKey, ProofTypeEd25519Signature2020)
+	if credential.Proof.Cryptosuite != "" || credential.Proof.JWS != "" || !strings.HasPrefix(credential.Proof.ProofValue, "z") {
+		t.Errorf("Ed25519Signature2020 proof = %+v", credential.Proof)
+	}
+	if len(credential.Context) != 3 || credential.Context[1] != jsonld.Ed25519Signature2020V1 {
+		t.Errorf("Ed25519Signature2020 context not added: %v", credential.Context)
+	}
+
+	credential = newSignedCredentialWithProof(t, privateKey, ProofTypeEd25519Signature2018)
+	header, _, found := strings.Cut(credential.Proof.JWS, "..")
+	if !found || credential.Proof.ProofValue != "" {
+		t.Fatalf("Ed25519Signature2018 proof = %+v", credential.Proof)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil || string(decoded) != `{"alg":"EdDSA","b64":false,"crit":["b64"]}` {
+		t.Errorf("JWS header = %s (%v)", decoded, err)
+	}
+	if len(credential.Context) != 2 {
+		t.Errorf("Ed25519Signature2018 needs no extra context: %v", credential.Context)
+	}
+}
+
+func TestVerifyProofRejectsTampering(t *testing.T) {
+	publicKey, privateKey := newTestSigner(t)
+	otherKey, _ := newTestSigner(t)
+
+	tests := []struct {
+		name   string
+		tamper func(*SimpleCredential)
+		key    ed25519.PublicKey
+	}{
+		{"claim", func(c *SimpleCredential) { c.CredentialSubject.Level = 99 }, publicKey},
+		{"attribute", func(c *SimpleCredential) { c.CredentialSubject.Attributes["ratio"] = 0.25 }, publicKey},
+		{"proof type", func(c *SimpleCredential) { c.Proof.Type = "RsaSignature2018" }, publicKey},
+		{"issuer", func(c *SimpleCredential) { c.Issuer = "did:example:other" }, publicKey},
+		{"context", func(c *SimpleCredential) { c.Context = c.Context[:1] }, publicKey},
+		{"proof created", func(c *SimpleCredential) { c.Proof.Created = c.Proof.Created.Add(time.Second) }, publicKey},
+		{"verification method", func(c *SimpleCredential) { c.Proof.VerificationMethod = "did:example:issuer#key-2" }, publicKey},
+		{"cryptosuite", func(c *SimpleCredential) { c.Proof.Cryptosuite = "eddsa-rdfc-2022" }, publicKey},
+		{"other key", func(*SimpleCredential) {}, otherKey},
+	}
+	for _, proofType := range proofTypes {
+		for _, test := range tests {
+			t.Run(proofType+"/"+test.name, func(t *testing.T) {
+				credential := newSignedCredentialWithProof(t, privateKey, proofType)
+				test.tamper(credential)
+				if err := VerifyProof(credential, test.key); err == nil {
+					t.Error("tampered credential verified")
+				}
+			})
+		}
+	}
+}
+
+func TestSignCredentialRejectsUnsupportedProofType(t *testing.T) {
+	_, privateKey := newTestSigner(t)
+	credential, err := IssueCredential("did:example:issuer", "did:example:holder", "LevelCredential", CredentialSubject{Level: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignCredential(credential, privateKey, "did:example:issuer#key-1", "RsaSignature2018"); err == nil {
+		t.Error("signed with an unsupported proof type")
+	}
+
+	// URDNA2015 只能使用内置的上下文，否则部分字段不会被签名
+	credential.Context = append(credential.Context, "https://example.com/contexts/unknown/v1")
+	if err := SignCredential(credential, privateKey, "did:example:issuer#key-1", ProofTypeEd25519Signature2020); err == nil {
+		t.Error("signed a credential with a context that is not available offline")
+	}
+}
+
+func TestSignDocumentRoundTrip(t *testing.T) {
+	publicKey, privateKey := newTestSigner(t)
+	document := map[string]interface{}{"did": "did:example:holder", "items": []string{"sword"}}
+
+	proof, err := SignDocument(document, privateKey, "did:example:issuer#key-1")
+	if err != nil {
+		t.Fatalf("SignDocument: %v", err)
+	}
+	if err := VerifyDocument(document, proof, publicKey); err != nil {
+		t.Fatalf("VerifyDocument: %v", err)
+	}
+	document["items"] = []string{"sword", "shield"}
+	if err := VerifyDocument(document, proof, publicKey); err == nil {
+		t.Error("tampered document verified")
+	}
+}
+
+func TestCanonicalJSONFollowsJCS(t *testing.T) {
+	canonical, err := CanonicalJSON(map[string]interface{}{
+		"b": 1.0,
+		"a": "<é>",
+		"c": []interface{}{1e21, 0.5},
+		"€": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":"<é>","b":1,"c":[1e+21,0.5],"€":true}`
+	if string(canonical) != want {
+		t.Errorf("CanonicalJSON = %s, want %s", canonical, want)
+	}
+}
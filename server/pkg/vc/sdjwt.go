@@ -0,0 +1,287 @@
+package vc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormatSDJWT 支持选择性披露的 SD-JWT 凭证
+const FormatSDJWT = "vc+sd-jwt"
+
+// sdAlgSHA256 披露摘要算法
+const sdAlgSHA256 = "sha-256"
+
+// sdJWTSeparator SD-JWT 各部分的分隔符：<JWT>~<披露1>~...~<披露N>~
+const sdJWTSeparator = "~"
+
+// SDJWTClaims SD-JWT 凭证声明。凭证主体的每个字段都作为可选择披露的声明，
+// 只以摘要形式出现在 _sd 中；颁发者、主体 DID、类型、有效期和撤销状态始终可见
+type SDJWTClaims struct {
+	Issuer    string            `json:"iss"`
+	Subject   string            `json:"sub,omitempty"`
+	ID        string            `json:"jti,omitempty"`
+	NotBefore int64             `json:"nbf"`
+	IssuedAt  int64             `json:"iat"`
+	ExpiresAt int64             `json:"exp,omitempty"`
+	VCT       string            `json:"vct"`
+	Status    *CredentialStatus `json:"status,omitempty"`
+	SD        []string          `json:"_sd"`
+	SDAlg     string            `json:"_sd_alg"`
+}
+
+// Disclosure 一条披露：[salt, 声明名, 声明值] 的 base64url 编码
+type Disclosure struct {
+	Name    string
+	Value   interface{}
+	Encoded string
+}
+
+// SDJWTCredential 解析后的 SD-JWT 凭证，签名尚未验证
+type SDJWTCredential struct {
+	Header      JWTHeader
+	Claims      SDJWTClaims
+	Disclosures []Disclosure
+	// Credential 由始终可见的声明和已披露的主体字段还原，未披露字段为零值
+	Credential *SimpleCredential
+
+	signingInput string
+	signature    []byte
+}
+
+// EncodeSDJWTCredential 将凭证编码为 SD-JWT，凭证主体的每个非空字段生成一条披露
+func EncodeSDJWTCredential(credential *SimpleCredential, privateKey crypto.Signer, kid string) (string, error) {
+	if credential == nil {
+		return "", errors.New("credential is nil")
+	}
+
+	subject, err := subjectClaims(credential.CredentialSubject)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(subject))
+	for name := range subject {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	disclosures := make([]string, 0, len(names))
+	digests := make([]string, 0, len(names))
+	for _, name := range names {
+		encoded, err := encodeDisclosure(name, subject[name])
+		if err != nil {
+			return "", err
+		}
+		disclosures = append(disclosures, encoded)
+		digests = append(digests, disclosureDigest(encoded))
+	}
+	// 摘要排序，避免从顺序推断字段
+	sort.Strings(digests)
+
+	claims := SDJWTClaims{
+		Issuer:    credential.Issuer,
+		Subject:   credential.CredentialSubject.ID,
+		ID:        credential.ID,
+		NotBefore: credential.IssuanceDate.Unix(),
+		IssuedAt:  time.Now().Unix(),
+		VCT:       credentialType(credential),
+		Status:    credential.CredentialStatus,
+		SD:        digests,
+		SDAlg:     sdAlgSHA256,
+	}
+	if credential.ExpirationDate != nil {
+		claims.ExpiresAt = credential.ExpirationDate.Unix()
+	}
+
+	token, err := SignCompactJWT(FormatSDJWT, kid, claims, privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(append([]string{token}, disclosures...), sdJWTSeparator) + sdJWTSeparator, nil
+}
+
+// SelectDisclosures 持有者只保留指定字段的披露，生成用于出示的 SD-JWT
+func SelectDisclosures(sdJWT string, names ...string) (string, error) {
+	parts := strings.Split(sdJWT, sdJWTSeparator)
+	if len(parts) < 2 {
+		return "", errors.New("malformed SD-JWT")
+	}
+
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	selected := []string{parts[0]}
+	for _, encoded := range parts[1 : len(parts)-1] {
+		disclosure, err := decodeDisclosure(encoded)
+		if err != nil {
+			return "", err
+		}
+		if keep[disclosure.Name] {
+			selected = append(selected, encoded)
+		}
+	}
+
+	return strings.Join(selected, sdJWTSeparator) + sdJWTSeparator, nil
+}
+
+// ParseSDJWTCredential 解析 SD-JWT，校验每条披露的摘要都出现在 _sd 中，并还原已披露的凭证字段
+func ParseSDJWTCredential(sdJWT string) (*SDJWTCredential, error) {
+	parts := strings.Split(sdJWT, sdJWTSeparator)
+	if len(parts) < 2 {
+		return nil, errors.New("malformed SD-JWT")
+	}
+	if parts[len(parts)-1] != "" {
+		return nil, errors.New("key binding JWT is not supported")
+	}
+
+	parsed := &SDJWTCredential{}
+	signingInput, signature, err := ParseCompactJWT(parts[0], &parsed.Header, &parsed.Claims)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Header.Typ != FormatSDJWT {
+		return nil, fmt.Errorf("typ must be %s", FormatSDJWT)
+	}
+	if parsed.Claims.SDAlg != sdAlgSHA256 {
+		return nil, fmt.Errorf("unsupported _sd_alg: %s", parsed.Claims.SDAlg)
+	}
+	parsed.signingInput = signingInput
+	parsed.signature = signature
+
+	digests := make(map[string]bool, len(parsed.Claims.SD))
+	for _, digest := range parsed.Claims.SD {
+		digests[digest] = true
+	}
+
+	subject := make(map[string]interface{})
+	for _, encoded := range parts[1 : len(parts)-1] {
+		digest := disclosureDigest(encoded)
+		if !digests[digest] {
+			return nil, errors.New("disclosure does not match any digest")
+		}
+		// 每个摘要只能使用一次
+		delete(digests, digest)
+
+		disclosure, err := decodeDisclosure(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if disclosure.Name == "id" {
+			return nil, errors.New("disclosure must not override the subject id")
+		}
+		parsed.Disclosures = append(parsed.Disclosures, *disclosure)
+		subject[disclosure.Name] = disclosure.Value
+	}
+
+	data, err := json.Marshal(subject)
+	if err != nil {
+		return nil, fmt.Errorf("marshal disclosed claims: %w", err)
+	}
+	var credentialSubject CredentialSubject
+	if err := json.Unmarshal(data, &credentialSubject); err != nil {
+		return nil, fmt.Errorf("decode disclosed claims: %w", err)
+	}
+	credentialSubject.ID = parsed.Claims.Subject
+
+	credential := &SimpleCredential{
+		Context:           append([]string(nil), credentialContexts...),
+		ID:                parsed.Claims.ID,
+		Type:              []string{"VerifiableCredential", parsed.Claims.VCT},
+		Issuer:            parsed.Claims.Issuer,
+		IssuanceDate:      time.Unix(parsed.Claims.NotBefore, 0).UTC(),
+		CredentialSubject: credentialSubject,
+		CredentialStatus:  parsed.Claims.Status,
+	}
+	if parsed.Claims.ExpiresAt != 0 {
+		expiresAt := time.Unix(parsed.Claims.ExpiresAt, 0).UTC()
+		credential.ExpirationDate = &expiresAt
+	}
+	parsed.Credential = credential
+
+	return parsed, nil
+}
+
+// Verify 使用颁发者公钥验证 SD-JWT 签名
+func (c *SDJWTCredential) Verify(publicKey crypto.PublicKey) error {
+	return VerifyJWTSignature(c.Header.Alg, c.signingInput, c.signature, publicKey)
+}
+
+// DisclosedClaims 返回已披露的字段名
+func (c *SDJWTCredential) DisclosedClaims() []string {
+	names := make([]string, 0, len(c.Disclosures))
+	for _, disclosure := range c.Disclosures {
+		names = append(names, disclosure.Name)
+	}
+	return names
+}
+
+// subjectClaims 将凭证主体转换为可披露的声明，主体 DID 不参与选择性披露
+func subjectClaims(subject CredentialSubject) (map[string]interface{}, error) {
+	data, err := json.Marshal(subject)
+	if err != nil {
+		return nil, fmt.Errorf("marshal credential subject: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("decode credential subject: %w", err)
+	}
+	delete(claims, "id")
+	return claims, nil
+}
+
+// credentialType 返回凭证的具体类型
+func credentialType(credential *SimpleCredential) string {
+	for i := len(credential.Type) - 1; i >= 0; i-- {
+		if credential.Type[i] != "VerifiableCredential" {
+			return credential.Type[i]
+		}
+	}
+	return "VerifiableCredential"
+}
+
+func encodeDisclosure(name string, value interface{}) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate disclosure salt: %w", err)
+	}
+
+	data, err := json.Marshal([]interface{}{base64.RawURLEncoding.EncodeToString(salt), name, value})
+	if err != nil {
+		return "", fmt.Errorf("marshal disclosure: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeDisclosure(encoded string) (*Disclosure, error) {
+	var parts []interface{}
+	if err := decodeSegment(encoded, &parts); err != nil {
+		return nil, fmt.Errorf("decode disclosure: %w", err)
+	}
+	if len(parts) != 3 {
+		return nil, errors.New("disclosure must be [salt, name, value]")
+	}
+	name, ok := parts[1].(string)
+	if !ok || name == "" {
+		return nil, errors.New("disclosure name must be a non-empty string")
+	}
+
+	return &Disclosure{Name: name, Value: parts[2], Encoded: encoded}, nil
+}
+
+// disclosureDigest 披露摘要：base64url(SHA-256(披露的 ASCII 编码))
+func disclosureDigest(encoded string) string {
+	digest := sha256.Sum256([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
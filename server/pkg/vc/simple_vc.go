@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/czh0526/game/server/pkg/jsonld"
 )
 
 // SimpleCredential 简化的可验证凭证
@@ -13,34 +15,56 @@ type SimpleCredential struct {
 	Context           []string               `json:"@context"`
 	ID                string                 `json:"id"`
 	Type              []string               `json:"type"`
+	Name              string                 `json:"name,omitempty"`
 	Issuer            string                 `json:"issuer"`
+	// IssuerProfile 不为空时颁发者编码为 Profile 对象（Open Badges 3.0），其 ID 与 Issuer 相同
+	IssuerProfile     *BadgeProfile          `json:"-"`
 	IssuanceDate      time.Time              `json:"issuanceDate"`
 	ExpirationDate    *time.Time             `json:"expirationDate,omitempty"`
 	CredentialSubject CredentialSubject      `json:"credentialSubject"`
+	CredentialStatus  *CredentialStatus      `json:"credentialStatus,omitempty"`
 	Proof             *Proof                 `json:"proof,omitempty"`
 }
 
 // CredentialSubject 凭证主体
 type CredentialSubject struct {
-	ID          string                 `json:"id"`
-	PlayerID    string                 `json:"playerId"`
-	GameID      string                 `json:"gameId"`
-	Achievement string                 `json:"achievement,omitempty"`
-	Level       int                    `json:"level,omitempty"`
-	Score       int                    `json:"score,omitempty"`
-	Skills      []string               `json:"skills,omitempty"`
-	Items       []string               `json:"items,omitempty"`
-	Attributes  map[string]interface{} `json:"attributes,omitempty"`
-	CompletedAt *time.Time             `json:"completedAt,omitempty"`
+	ID           string                 `json:"id"`
+	Type         []string               `json:"type,omitempty"`
+	PlayerID     string                 `json:"playerId,omitempty"`
+	GameID       string                 `json:"gameId,omitempty"`
+	Achievement  string                 `json:"achievement,omitempty"`
+	Level        int                    `json:"level,omitempty"`
+	// LevelAtLeast 服务器按 Level 签发的等级门槛，BBS+ 凭证的持有者可以只披露其中一个来证明等级不低于它
+	LevelAtLeast []int                  `json:"levelAtLeast,omitempty"`
+	Score        int                    `json:"score,omitempty"`
+	Skills       []string               `json:"skills,omitempty"`
+	Items        []string               `json:"items,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	CompletedAt  *time.Time             `json:"completedAt,omitempty"`
+	// Badge 不为空时凭证按 Open Badges 3.0 颁发，achievement 编码为该成就对象，Achievement 为其名称
+	Badge        *BadgeAchievement      `json:"-"`
 }
 
 // Proof 证明
 type Proof struct {
 	Type               string    `json:"type"`
+	Cryptosuite        string    `json:"cryptosuite,omitempty"`
 	Created            time.Time `json:"created"`
 	VerificationMethod string    `json:"verificationMethod"`
 	ProofPurpose       string    `json:"proofPurpose"`
-	ProofValue         string    `json:"proofValue"`
+	ProofValue         string    `json:"proofValue,omitempty"`
+	// JWS Ed25519Signature2018 的分离载荷 JWS
+	JWS                string    `json:"jws,omitempty"`
+	Challenge          string    `json:"challenge,omitempty"`
+	Domain             string    `json:"domain,omitempty"`
+	// Nonce BbsBlsSignatureProof2020 派生证明绑定的验证方随机数（base64）
+	Nonce              string    `json:"nonce,omitempty"`
+}
+
+// credentialContexts 凭证默认使用的 JSON-LD 上下文
+var credentialContexts = []string{
+	"https://www.w3.org/2018/credentials/v1",
+	jsonld.GameCredentialsV1,
 }
 
 // IssueCredential 颁发凭证
@@ -49,10 +73,7 @@ func IssueCredential(issuerDID, subjectDID string, credType string, subject Cred
 	now := time.Now()
 
 	credential := &SimpleCredential{
-		Context: []string{
-			"https://www.w3.org/2018/credentials/v1",
-			"https://game.example.com/contexts/credentials/v1",
-		},
+		Context: append([]string(nil), credentialContexts...),
 		ID:     credentialID,
 		Type:   []string{"VerifiableCredential", credType},
 		Issuer: issuerDID,
@@ -63,6 +84,11 @@ func IssueCredential(issuerDID, subjectDID string, credType string, subject Cred
 	// 设置凭证主体ID
 	credential.CredentialSubject.ID = subjectDID
 
+	// Open Badges 3.0 成就凭证
+	if subject.Badge != nil {
+		credential.applyOpenBadge()
+	}
+
 	return credential, nil
 }
 
@@ -0,0 +1,183 @@
+package vc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StatusList2021 相关常量
+const (
+	StatusList2021EntryType      = "StatusList2021Entry"
+	StatusList2021Type           = "StatusList2021"
+	StatusList2021CredentialType = "StatusList2021Credential"
+	StatusPurposeRevocation      = "revocation"
+
+	// DefaultStatusListSize 规范要求的最小列表长度（16KB），保证持有者隐私
+	DefaultStatusListSize = 131072
+)
+
+// CredentialStatus 凭证状态条目
+type CredentialStatus struct {
+	ID                   string `json:"id"`
+	Type                 string `json:"type"`
+	StatusPurpose        string `json:"statusPurpose"`
+	StatusListIndex      string `json:"statusListIndex"`
+	StatusListCredential string `json:"statusListCredential"`
+}
+
+// StatusList 状态位串，每个凭证占用一位，置位表示已撤销
+type StatusList struct {
+	bits []byte
+	size int
+}
+
+// NewStatusList 创建指定长度的状态位串
+func NewStatusList(size int) *StatusList {
+	if size <= 0 {
+		size = DefaultStatusListSize
+	}
+	return &StatusList{
+		bits: make([]byte, (size+7)/8),
+		size: size,
+	}
+}
+
+// Size 返回位串长度
+func (l *StatusList) Size() int {
+	return l.size
+}
+
+// Set 设置指定索引的状态位
+func (l *StatusList) Set(index int, value bool) error {
+	if index < 0 || index >= l.size {
+		return fmt.Errorf("status list index out of range: %d", index)
+	}
+
+	// 位序为大端，索引 0 对应第一个字节的最高位
+	mask := byte(1 << (7 - uint(index%8)))
+	if value {
+		l.bits[index/8] |= mask
+	} else {
+		l.bits[index/8] &^= mask
+	}
+	return nil
+}
+
+// Get 读取指定索引的状态位
+func (l *StatusList) Get(index int) (bool, error) {
+	if index < 0 || index >= l.size {
+		return false, fmt.Errorf("status list index out of range: %d", index)
+	}
+
+	mask := byte(1 << (7 - uint(index%8)))
+	return l.bits[index/8]&mask != 0, nil
+}
+
+// Encode 以 GZIP 压缩后 base64url 编码
+func (l *StatusList) Encode() (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(l.bits); err != nil {
+		return "", fmt.Errorf("compress status list: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("compress status list: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeStatusList 解码 encodedList
+func DecodeStatusList(encoded string) (*StatusList, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode status list: %w", err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("decompress status list: %w", err)
+	}
+	defer reader.Close()
+
+	bits, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("decompress status list: %w", err)
+	}
+
+	return &StatusList{bits: bits, size: len(bits) * 8}, nil
+}
+
+// StatusListSubject 状态列表凭证主体
+type StatusListSubject struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	StatusPurpose string `json:"statusPurpose"`
+	EncodedList   string `json:"encodedList"`
+}
+
+// StatusListCredential 状态列表凭证
+type StatusListCredential struct {
+	Context           []string          `json:"@context"`
+	ID                string            `json:"id"`
+	Type              []string          `json:"type"`
+	Issuer            string            `json:"issuer"`
+	IssuanceDate      time.Time         `json:"issuanceDate"`
+	CredentialSubject StatusListSubject `json:"credentialSubject"`
+	Proof             *Proof            `json:"proof,omitempty"`
+}
+
+// IssueStatusListCredential 生成并签名状态列表凭证
+func IssueStatusListCredential(issuerDID, credentialURL string, list *StatusList, privateKey crypto.Signer, verificationMethod string) (*StatusListCredential, error) {
+	encoded, err := list.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	credential := &StatusListCredential{
+		Context: []string{
+			"https://www.w3.org/2018/credentials/v1",
+			"https://w3id.org/vc/status-list/2021/v1",
+		},
+		ID:           credentialURL,
+		Type:         []string{"VerifiableCredential", StatusList2021CredentialType},
+		Issuer:       issuerDID,
+		IssuanceDate: time.Now().UTC().Truncate(time.Second),
+		CredentialSubject: StatusListSubject{
+			ID:            credentialURL + "#list",
+			Type:          StatusList2021Type,
+			StatusPurpose: StatusPurposeRevocation,
+			EncodedList:   encoded,
+		},
+	}
+
+	proof := &Proof{
+		VerificationMethod: verificationMethod,
+		ProofPurpose:       ProofPurposeAssertionMethod,
+	}
+	if err := createProof(credential, privateKey, proof); err != nil {
+		return nil, err
+	}
+	credential.Proof = proof
+
+	return credential, nil
+}
+
+// VerifyStatusListCredential 验证状态列表凭证的签名
+func VerifyStatusListCredential(credential *StatusListCredential, publicKey ed25519.PublicKey) error {
+	if credential == nil || credential.Proof == nil {
+		return errors.New("status list credential has no proof")
+	}
+
+	unsigned := *credential
+	unsigned.Proof = nil
+
+	return verifyProof(&unsigned, credential.Proof, publicKey)
+}